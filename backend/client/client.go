@@ -0,0 +1,139 @@
+// Package client is a Go client for the vessel tracker API. The types and
+// methods in generated.go are generated from the API's DTOs by
+// cmd/genclient; this file holds the transport they share. The package only
+// depends on the standard library, so tools outside this module can vendor it.
+package client
+
+//go:generate go run ../cmd/genclient -go-out generated.go -ts-out ../../frontend/src/types/api.generated.ts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client calls the API as one actor. Token is sent as a bearer token and may
+// be a role token or a session access token; machine clients set APIKey to
+// an issued API key instead.
+type Client struct {
+	BaseURL    string // e.g. https://tracker.example.org, without /api
+	Token      string
+	APIKey     string
+	HTTPClient *http.Client
+}
+
+func NewClient(baseURL, token string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		Token:      token,
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// APIError is an error response of the API
+type APIError struct {
+	StatusCode int
+	Message    string `json:"error"`
+	Details    string `json:"details,omitempty"`
+
+	// RetryAfter is set on 429 and 503 responses, e.g. while the provider
+	// quota is spent or the API is in maintenance mode
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	if e.Details != "" {
+		return fmt.Sprintf("vessel tracker API: %d %s: %s", e.StatusCode, e.Message, e.Details)
+	}
+	return fmt.Sprintf("vessel tracker API: %d %s", e.StatusCode, e.Message)
+}
+
+// do sends a request and decodes a JSON response into out
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body interface{}, out interface{}) error {
+	target := c.BaseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	if c.APIKey != "" {
+		req.Header.Set("X-API-Key", c.APIKey)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		if json.Unmarshal(data, apiErr) != nil || apiErr.Message == "" {
+			apiErr.Message = http.StatusText(resp.StatusCode)
+		}
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			apiErr.RetryAfter = time.Duration(seconds) * time.Second
+		}
+		return apiErr
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s %s response: %w", method, path, err)
+	}
+	return nil
+}
+
+// setQuery adds a query parameter unless value is the zero value of its type
+func setQuery(query url.Values, name string, value interface{}) {
+	switch v := value.(type) {
+	case string:
+		if v != "" {
+			query.Set(name, v)
+		}
+	case int:
+		if v != 0 {
+			query.Set(name, strconv.Itoa(v))
+		}
+	case bool:
+		if v {
+			query.Set(name, "true")
+		}
+	case time.Time:
+		if !v.IsZero() {
+			query.Set(name, v.UTC().Format(time.RFC3339))
+		}
+	default:
+		panic(fmt.Sprintf("unsupported query parameter type %T", value))
+	}
+}
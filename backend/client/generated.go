@@ -0,0 +1,958 @@
+// Code generated by genclient. DO NOT EDIT.
+
+package client
+
+import (
+	"context"
+	"net/url"
+	"time"
+)
+
+type AnchoredVessel struct {
+	ID                uint           `json:"id"`
+	VesselUUID        string         `json:"vessel_uuid"`
+	ParkID            uint           `json:"park_id"`
+	StartedAt         time.Time      `json:"started_at"`
+	EndedAt           *time.Time     `json:"ended_at"`
+	LastSeenAt        time.Time      `json:"last_seen_at"`
+	Latitude          float64        `json:"latitude"`
+	Longitude         float64        `json:"longitude"`
+	DriftRadiusMeters float64        `json:"drift_radius_meters"`
+	DwellMinutes      float64        `json:"dwell_minutes"`
+	PositionCount     int            `json:"position_count"`
+	MaxCurrentKnots   *float64       `json:"max_current_knots"`
+	AnchorLatitude    float64        `json:"anchor_latitude"`
+	AnchorLongitude   float64        `json:"anchor_longitude"`
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+	Vessel            VesselRecord   `json:"vessel,omitempty"`
+	AnchoredMinutes   float64        `json:"anchored_minutes"`
+	Habitat           []HabitatLayer `json:"habitat"`
+	OnPosidonia       bool           `json:"on_posidonia"`
+}
+
+type AnchoredVesselList struct {
+	Park       string              `json:"park"`
+	Thresholds AnchoringThresholds `json:"thresholds"`
+	MaxAge     string              `json:"max_age"`
+	Vessels    []AnchoredVessel    `json:"vessels"`
+	Count      int                 `json:"count"`
+	DataAsOf   *time.Time          `json:"data_as_of"`
+	Source     string              `json:"source"`
+}
+
+type AnchoringEvent struct {
+	ID                uint         `json:"id"`
+	VesselUUID        string       `json:"vessel_uuid"`
+	ParkID            uint         `json:"park_id"`
+	StartedAt         time.Time    `json:"started_at"`
+	EndedAt           *time.Time   `json:"ended_at"`
+	LastSeenAt        time.Time    `json:"last_seen_at"`
+	Latitude          float64      `json:"latitude"`
+	Longitude         float64      `json:"longitude"`
+	DriftRadiusMeters float64      `json:"drift_radius_meters"`
+	DwellMinutes      float64      `json:"dwell_minutes"`
+	PositionCount     int          `json:"position_count"`
+	MaxCurrentKnots   *float64     `json:"max_current_knots"`
+	AnchorLatitude    float64      `json:"anchor_latitude"`
+	AnchorLongitude   float64      `json:"anchor_longitude"`
+	CreatedAt         time.Time    `json:"created_at"`
+	UpdatedAt         time.Time    `json:"updated_at"`
+	Vessel            VesselRecord `json:"vessel,omitempty"`
+}
+
+type AnchoringEventList struct {
+	Park   string           `json:"park"`
+	Events []AnchoringEvent `json:"events"`
+	Count  int              `json:"count"`
+}
+
+type AnchoringThresholds struct {
+	MaxSpeedKnots  float64 `json:"max_speed_knots"`
+	MaxDriftMeters float64 `json:"max_drift_meters"`
+	MinPositions   int     `json:"min_positions"`
+}
+
+type ArrivalList struct {
+	Park     string        `json:"park"`
+	Arrivals []ParkArrival `json:"arrivals"`
+	Count    int           `json:"count"`
+}
+
+type Deprecation struct {
+	Method       string     `json:"method"`
+	Path         string     `json:"path"`
+	DeprecatedAt time.Time  `json:"deprecated_at"`
+	Sunset       *time.Time `json:"sunset,omitempty"`
+	Successor    string     `json:"successor,omitempty"`
+	Note         string     `json:"note,omitempty"`
+}
+
+type DeprecationList struct {
+	Deprecations []Deprecation `json:"deprecations"`
+	Count        int           `json:"count"`
+}
+
+type HabitatLayer struct {
+	ID          uint       `json:"id"`
+	ParkID      uint       `json:"park_id"`
+	Name        string     `json:"name"`
+	Kind        string     `json:"kind"`
+	Format      string     `json:"format"`
+	FileName    string     `json:"file_name"`
+	Features    int        `json:"features"`
+	SHA256      string     `json:"sha256"`
+	ActiveFrom  *time.Time `json:"active_from,omitempty"`
+	ActiveUntil *time.Time `json:"active_until,omitempty"`
+	UploadedBy  string     `json:"uploaded_by"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+type LatLon struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+type LatestPosition struct {
+	Latitude       float64   `json:"latitude"`
+	Longitude      float64   `json:"longitude"`
+	Speed          float64   `json:"speed"`
+	Course         float64   `json:"course"`
+	Heading        *int      `json:"heading"`
+	Destination    string    `json:"destination"`
+	Distance       float64   `json:"distance"`
+	IsInPark       bool      `json:"is_in_park"`
+	IsInBufferZone bool      `json:"is_in_buffer_zone"`
+	Timestamp      string    `json:"timestamp"`
+	RecordedAt     time.Time `json:"recorded_at"`
+}
+
+type LocalVesselList struct {
+	Vessels  []VesselRecord `json:"vessels"`
+	Count    int            `json:"count"`
+	DataAsOf *time.Time     `json:"data_as_of"`
+	Source   string         `json:"source"`
+}
+
+type LoginRequest struct {
+	DeviceName string `json:"device_name"`
+}
+
+type MapView struct {
+	Latitude  float64   `json:"latitude"`
+	Longitude float64   `json:"longitude"`
+	Zoom      int       `json:"zoom"`
+	Bounds    []float64 `json:"bounds"`
+}
+
+type Park struct {
+	ID              uint      `json:"id"`
+	Slug            string    `json:"slug"`
+	Name            string    `json:"name"`
+	BoundariesPath  string    `json:"boundaries_path,omitempty"`
+	BufferedPath    string    `json:"buffered_path,omitempty"`
+	BufferMeters    *float64  `json:"buffer_meters,omitempty"`
+	CenterLat       *float64  `json:"center_lat,omitempty"`
+	CenterLon       *float64  `json:"center_lon,omitempty"`
+	RadiusNM        int       `json:"radius_nm,omitempty"`
+	DataRegion      string    `json:"data_region,omitempty"`
+	RestrictExports bool      `json:"restrict_exports"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+type ParkArrival struct {
+	ID          uint         `json:"id"`
+	ParkID      uint         `json:"park_id"`
+	VesselUUID  string       `json:"vessel_uuid"`
+	MMSI        string       `json:"mmsi"`
+	Latitude    float64      `json:"latitude"`
+	Longitude   float64      `json:"longitude"`
+	Speed       float64      `json:"speed"`
+	FirstSeenAt time.Time    `json:"first_seen_at"`
+	Seeded      bool         `json:"seeded"`
+	CreatedAt   time.Time    `json:"created_at"`
+	Vessel      VesselRecord `json:"vessel,omitempty"`
+}
+
+type ParkList struct {
+	Parks []ParkSummary `json:"parks"`
+	Count int           `json:"count"`
+}
+
+type ParkSummary struct {
+	Park                Park   `json:"park"`
+	Default             bool   `json:"default"`
+	Center              LatLon `json:"center"`
+	BoundariesHealthy   bool   `json:"boundaries_healthy"`
+	BufferZoneAvailable bool   `json:"buffer_zone_available"`
+}
+
+type ParkVessel struct {
+	Vessel         VesselSummary  `json:"vessel"`
+	Latitude       float64        `json:"latitude"`
+	Longitude      float64        `json:"longitude"`
+	IsInPark       bool           `json:"is_in_park"`
+	IsInBufferZone bool           `json:"is_in_buffer_zone"`
+	IsWhitelisted  bool           `json:"is_whitelisted,omitempty"`
+	Timestamp      string         `json:"timestamp,omitempty"`
+	WhitelistInfo  *WhitelistInfo `json:"whitelist_info,omitempty"`
+}
+
+type Playback struct {
+	Park        string                    `json:"park"`
+	Start       time.Time                 `json:"start"`
+	End         time.Time                 `json:"end"`
+	Step        string                    `json:"step"`
+	Interpolate bool                      `json:"interpolate"`
+	MaxGap      string                    `json:"max_gap"`
+	MaxAge      string                    `json:"max_age,omitempty"`
+	Vessels     map[string]PlaybackVessel `json:"vessels"`
+	Frames      []PlaybackFrame           `json:"frames"`
+}
+
+type PlaybackFrame struct {
+	Timestamp time.Time          `json:"timestamp"`
+	Positions []PlaybackPosition `json:"positions"`
+	Count     int                `json:"count"`
+}
+
+type PlaybackPosition struct {
+	VesselUUID   string    `json:"vessel_uuid"`
+	Latitude     float64   `json:"latitude"`
+	Longitude    float64   `json:"longitude"`
+	Speed        float64   `json:"speed"`
+	Course       float64   `json:"course"`
+	Heading      *int      `json:"heading"`
+	IsInPark     bool      `json:"is_in_park"`
+	FixTime      time.Time `json:"fix_time"`
+	AgeSeconds   int64     `json:"position_age_seconds"`
+	Interpolated bool      `json:"interpolated"`
+}
+
+type PlaybackVessel struct {
+	UUID         string `json:"uuid"`
+	Name         string `json:"name"`
+	MMSI         string `json:"mmsi"`
+	IMO          string `json:"imo"`
+	Type         string `json:"type"`
+	TypeSpecific string `json:"type_specific"`
+	CountryISO   string `json:"country_iso"`
+}
+
+type PreviousPosition struct {
+	Latitude    float64   `json:"latitude"`
+	Longitude   float64   `json:"longitude"`
+	Speed       float64   `json:"speed"`
+	Course      float64   `json:"course"`
+	Heading     *int      `json:"heading"`
+	Destination string    `json:"destination"`
+	Distance    float64   `json:"distance"`
+	IsInPark    bool      `json:"is_in_park"`
+	Timestamp   string    `json:"timestamp"`
+	RecordedAt  time.Time `json:"recorded_at"`
+}
+
+type PreviousPositions struct {
+	VesselUUID        string             `json:"vessel_uuid"`
+	PreviousPositions []PreviousPosition `json:"previous_positions"`
+	Count             int                `json:"count"`
+	StartTime         string             `json:"start_time"`
+	EndTime           string             `json:"end_time"`
+	Limit             int                `json:"limit"`
+}
+
+type ProjectedIntrusion struct {
+	Minutes   float64   `json:"minutes"`
+	Time      time.Time `json:"time"`
+	Latitude  float64   `json:"latitude"`
+	Longitude float64   `json:"longitude"`
+}
+
+type ProjectedPoint struct {
+	Minutes      int       `json:"minutes"`
+	Time         time.Time `json:"time"`
+	Latitude     float64   `json:"latitude"`
+	Longitude    float64   `json:"longitude"`
+	InPark       bool      `json:"in_park"`
+	InBufferZone bool      `json:"in_buffer_zone"`
+}
+
+type QuotaStatus struct {
+	Provider          string    `json:"provider"`
+	Day               string    `json:"day"`
+	DailyCredits      int64     `json:"daily_credits"`
+	Used              int64     `json:"used"`
+	Remaining         *int64    `json:"remaining"`
+	Reserve           int64     `json:"reserve"`
+	Deferring         bool      `json:"deferring"`
+	Exhausted         bool      `json:"exhausted"`
+	ResetsAt          time.Time `json:"resets_at"`
+	RequestsPerMinute int       `json:"requests_per_minute"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type SchedulerStatus struct {
+	Running               bool       `json:"running"`
+	Paused                bool       `json:"paused"`
+	LastRunID             string     `json:"last_run_id,omitempty"`
+	LastRunAt             *time.Time `json:"last_run_at"`
+	LastSuccessAt         *time.Time `json:"last_success_at"`
+	LastFailureAt         *time.Time `json:"last_failure_at"`
+	LastError             string     `json:"last_error,omitempty"`
+	VesselsFetched        int        `json:"vessels_fetched"`
+	PositionsStored       int        `json:"positions_stored"`
+	DuplicateSkipped      int        `json:"duplicate_skipped"`
+	DuplicateSkippedTotal int64      `json:"duplicate_skipped_total"`
+	NextRunAt             *time.Time `json:"next_run_at"`
+}
+
+type SiteContact struct {
+	Organization string `json:"organization,omitempty"`
+	Email        string `json:"email,omitempty"`
+	Phone        string `json:"phone,omitempty"`
+	URL          string `json:"url,omitempty"`
+}
+
+type SiteInfo struct {
+	Park     string          `json:"park"`
+	Name     string          `json:"name"`
+	Title    string          `json:"title"`
+	LogoURL  string          `json:"logo_url,omitempty"`
+	Map      MapView         `json:"map"`
+	Layers   []SiteLayer     `json:"layers"`
+	Contact  SiteContact     `json:"contact"`
+	Features map[string]bool `json:"features"`
+}
+
+type SiteLayer struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+	URL  string `json:"url"`
+}
+
+type TokenPair struct {
+	AccessToken      string    `json:"access_token"`
+	AccessExpiresAt  time.Time `json:"access_expires_at"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+	SessionID        uint      `json:"session_id"`
+}
+
+type VesselEvents struct {
+	Park       string      `json:"park"`
+	VesselUUID string      `json:"vessel_uuid"`
+	Start      time.Time   `json:"start"`
+	End        time.Time   `json:"end"`
+	Events     []ZoneEvent `json:"events"`
+	Count      int         `json:"count"`
+}
+
+type VesselProfile struct {
+	Park                string                 `json:"park"`
+	Vessel              VesselRecord           `json:"vessel"`
+	LatestPosition      *LatestPosition        `json:"latest_position"`
+	IsWhitelisted       bool                   `json:"is_whitelisted"`
+	WhitelistInfo       *WhitelistInfo         `json:"whitelist_info,omitempty"`
+	Violations          VesselViolationSummary `json:"violations"`
+	BufferZoneAvailable bool                   `json:"buffer_zone_available"`
+}
+
+type VesselProjection struct {
+	VesselUUID string              `json:"vessel_uuid"`
+	ParkID     uint                `json:"park_id"`
+	FixTime    time.Time           `json:"fix_time"`
+	Latitude   float64             `json:"latitude"`
+	Longitude  float64             `json:"longitude"`
+	Speed      float64             `json:"speed"`
+	Course     float64             `json:"course"`
+	InPark     bool                `json:"in_park"`
+	Points     []ProjectedPoint    `json:"points"`
+	Intrusion  *ProjectedIntrusion `json:"intrusion"`
+	Notes      []string            `json:"notes"`
+}
+
+type VesselRecord struct {
+	ID           uint       `json:"id"`
+	UUID         string     `json:"uuid"`
+	Name         string     `json:"name"`
+	NameAIS      string     `json:"name_ais"`
+	MMSI         string     `json:"mmsi"`
+	IMO          string     `json:"imo"`
+	ENI          *string    `json:"eni"`
+	CountryISO   string     `json:"country_iso"`
+	CountryName  string     `json:"country_name"`
+	Callsign     string     `json:"callsign"`
+	Type         string     `json:"type"`
+	TypeSpecific string     `json:"type_specific"`
+	GrossTonnage *float64   `json:"gross_tonnage"`
+	Deadweight   *float64   `json:"deadweight"`
+	TEU          *int       `json:"teu"`
+	LiquidGas    *float64   `json:"liquid_gas"`
+	Length       float64    `json:"length"`
+	Breadth      float64    `json:"breadth"`
+	DraughtAvg   *float64   `json:"draught_avg"`
+	DraughtMax   *float64   `json:"draught_max"`
+	SpeedAvg     *float64   `json:"speed_avg"`
+	SpeedMax     *float64   `json:"speed_max"`
+	YearBuilt    string     `json:"year_built"`
+	IsNavaid     bool       `json:"is_navaid"`
+	HomePort     *string    `json:"home_port"`
+	Destination  string     `json:"destination"`
+	OperatorID   *uint      `json:"operator_id"`
+	LastSeenAt   *time.Time `json:"last_seen_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+type VesselSummary struct {
+	UUID         string  `json:"uuid,omitempty"`
+	Name         string  `json:"name"`
+	MMSI         string  `json:"mmsi"`
+	IMO          string  `json:"imo,omitempty"`
+	Type         string  `json:"type"`
+	TypeSpecific string  `json:"type_specific,omitempty"`
+	CountryISO   string  `json:"country_iso,omitempty"`
+	CountryName  string  `json:"country_name,omitempty"`
+	Speed        float64 `json:"speed,omitempty"`
+	Course       float64 `json:"course,omitempty"`
+	Heading      *int    `json:"heading,omitempty"`
+	Destination  string  `json:"destination,omitempty"`
+	Distance     float64 `json:"distance,omitempty"`
+}
+
+type VesselViolationSummary struct {
+	Since  time.Time        `json:"since"`
+	Total  int64            `json:"total"`
+	Open   int64            `json:"open"`
+	ByType map[string]int64 `json:"by_type"`
+}
+
+type VesselsInPark struct {
+	Park                string       `json:"park,omitempty"`
+	VesselsInPark       []ParkVessel `json:"vessels_in_park"`
+	TotalInPark         int          `json:"total_in_park"`
+	ParkCenter          LatLon       `json:"park_center"`
+	BufferZoneAvailable bool         `json:"buffer_zone_available,omitempty"`
+	DataAsOf            *time.Time   `json:"data_as_of"`
+	Source              string       `json:"source"`
+}
+
+type WeatherFrame struct {
+	Timestamp   time.Time           `json:"timestamp"`
+	Observation *WeatherObservation `json:"observation"`
+	AgeSeconds  int64               `json:"observation_age_seconds,omitempty"`
+}
+
+type WeatherHistory struct {
+	Park         string               `json:"park"`
+	Start        time.Time            `json:"start"`
+	End          time.Time            `json:"end"`
+	Step         string               `json:"step"`
+	MaxAge       string               `json:"max_age"`
+	Observations []WeatherObservation `json:"observations"`
+	Frames       []WeatherFrame       `json:"frames"`
+}
+
+type WeatherObservation struct {
+	ID               uint      `json:"id"`
+	ParkID           uint      `json:"park_id"`
+	ObservedAt       time.Time `json:"observed_at"`
+	FetchedAt        time.Time `json:"fetched_at"`
+	Source           string    `json:"source"`
+	Latitude         float64   `json:"latitude"`
+	Longitude        float64   `json:"longitude"`
+	TemperatureC     float64   `json:"temperature_c"`
+	WindSpeedKnots   float64   `json:"wind_speed_knots"`
+	WindGustKnots    float64   `json:"wind_gust_knots"`
+	WindDirectionDeg float64   `json:"wind_direction_deg"`
+	PressureHPa      float64   `json:"pressure_hpa"`
+	PrecipitationMm  float64   `json:"precipitation_mm"`
+	CloudCoverPct    float64   `json:"cloud_cover_pct"`
+	WeatherCode      int       `json:"weather_code"`
+}
+
+type WhitelistCheck struct {
+	IsWhitelisted  bool            `json:"is_whitelisted"`
+	UUID           string          `json:"uuid"`
+	MMSI           string          `json:"mmsi"`
+	IMO            string          `json:"imo"`
+	WhitelistEntry *WhitelistEntry `json:"whitelist_entry,omitempty"`
+}
+
+type WhitelistEntry struct {
+	ID         uint         `json:"id"`
+	VesselUUID string       `json:"vessel_uuid"`
+	MMSI       string       `json:"mmsi"`
+	IMO        string       `json:"imo"`
+	Name       string       `json:"name"`
+	Reason     string       `json:"reason"`
+	AddedBy    string       `json:"added_by"`
+	OperatorID *uint        `json:"operator_id"`
+	ExpiresAt  *time.Time   `json:"expires_at"`
+	IsActive   bool         `json:"is_active"`
+	CreatedAt  time.Time    `json:"created_at"`
+	UpdatedAt  time.Time    `json:"updated_at"`
+	Vessel     VesselRecord `json:"vessel,omitempty"`
+}
+
+type WhitelistInfo struct {
+	Reason  string `json:"reason"`
+	AddedBy string `json:"added_by,omitempty"`
+}
+
+type ZoneEvent struct {
+	ID         uint         `json:"id"`
+	ParkID     uint         `json:"park_id"`
+	VesselUUID string       `json:"vessel_uuid"`
+	Type       string       `json:"type"`
+	FromZone   string       `json:"from_zone"`
+	ToZone     string       `json:"to_zone"`
+	Latitude   float64      `json:"latitude"`
+	Longitude  float64      `json:"longitude"`
+	Speed      float64      `json:"speed"`
+	OccurredAt time.Time    `json:"occurred_at"`
+	PreviousAt time.Time    `json:"previous_at"`
+	CreatedAt  time.Time    `json:"created_at"`
+	Vessel     VesselRecord `json:"vessel,omitempty"`
+}
+
+type ZoneEventList struct {
+	Park   string      `json:"park"`
+	Events []ZoneEvent `json:"events"`
+	Count  int         `json:"count"`
+}
+
+// GetParks lists the monitored parks; the first is the default.
+//
+//	GET /api/parks
+func (c *Client) GetParks(ctx context.Context) (*ParkList, error) {
+	query := url.Values{}
+
+	var out ParkList
+	if err := c.do(ctx, "GET", "/api/parks", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetVesselsInParkParams holds the query parameters of GetVesselsInPark
+type GetVesselsInParkParams struct {
+	Park string // park slug, the default park when empty
+}
+
+// GetVesselsInPark returns the latest position of every vessel inside a park.
+//
+//	GET /api/vessels/in-park
+func (c *Client) GetVesselsInPark(ctx context.Context, params *GetVesselsInParkParams) (*VesselsInPark, error) {
+	query := url.Values{}
+	if params != nil {
+		setQuery(query, "park", params.Park)
+	}
+
+	var out VesselsInPark
+	if err := c.do(ctx, "GET", "/api/vessels/in-park", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetVesselParams holds the query parameters of GetVessel
+type GetVesselParams struct {
+	Park string // park slug, the default park when empty
+}
+
+// GetVessel returns a vessel's profile with its latest position and recent violation counts.
+//
+//	GET /api/vessels/:uuid
+func (c *Client) GetVessel(ctx context.Context, uuid string, params *GetVesselParams) (*VesselProfile, error) {
+	query := url.Values{}
+	if params != nil {
+		setQuery(query, "park", params.Park)
+	}
+
+	var out VesselProfile
+	if err := c.do(ctx, "GET", "/api/vessels/"+url.PathEscape(uuid), query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetPreviousPositionsParams holds the query parameters of GetPreviousPositions
+type GetPreviousPositionsParams struct {
+	Park      string    // park slug, the default park when empty
+	StartTime time.Time // start of the range
+	EndTime   time.Time // end of the range
+	Limit     int       // maximum number of results, 100 when zero
+}
+
+// GetPreviousPositions returns the stored positions of a vessel, over the last 7 days by default.
+//
+//	GET /api/vessels/:uuid/previous-positions
+func (c *Client) GetPreviousPositions(ctx context.Context, uuid string, params *GetPreviousPositionsParams) (*PreviousPositions, error) {
+	query := url.Values{}
+	if params != nil {
+		setQuery(query, "park", params.Park)
+		setQuery(query, "start_time", params.StartTime)
+		setQuery(query, "end_time", params.EndTime)
+		setQuery(query, "limit", params.Limit)
+	}
+
+	var out PreviousPositions
+	if err := c.do(ctx, "GET", "/api/vessels/"+url.PathEscape(uuid)+"/previous-positions", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetVesselEventsParams holds the query parameters of GetVesselEvents
+type GetVesselEventsParams struct {
+	Park  string    // park slug, the default park when empty
+	Start time.Time // start of the range
+	End   time.Time // end of the range
+	Limit int       // maximum number of results, 100 when zero
+}
+
+// GetVesselEvents returns a vessel's park and buffer zone entries and exits, over the last 7 days by default.
+//
+//	GET /api/vessels/:uuid/events
+func (c *Client) GetVesselEvents(ctx context.Context, uuid string, params *GetVesselEventsParams) (*VesselEvents, error) {
+	query := url.Values{}
+	if params != nil {
+		setQuery(query, "park", params.Park)
+		setQuery(query, "start", params.Start)
+		setQuery(query, "end", params.End)
+		setQuery(query, "limit", params.Limit)
+	}
+
+	var out VesselEvents
+	if err := c.do(ctx, "GET", "/api/vessels/"+url.PathEscape(uuid)+"/events", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetVesselProjectionParams holds the query parameters of GetVesselProjection
+type GetVesselProjectionParams struct {
+	Park string // park slug, the default park when empty
+}
+
+// GetVesselProjection projects a vessel's track forward from its latest position, with where it would enter the park.
+//
+//	GET /api/vessels/:uuid/projection
+func (c *Client) GetVesselProjection(ctx context.Context, uuid string, params *GetVesselProjectionParams) (*VesselProjection, error) {
+	query := url.Values{}
+	if params != nil {
+		setQuery(query, "park", params.Park)
+	}
+
+	var out VesselProjection
+	if err := c.do(ctx, "GET", "/api/vessels/"+url.PathEscape(uuid)+"/projection", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetPlaybackParams holds the query parameters of GetPlayback
+type GetPlaybackParams struct {
+	Park        string    // park slug, the default park when empty
+	Start       time.Time // time of the first frame
+	End         time.Time // latest time of a frame
+	Step        string    // time between frames, such as 5m; 5m when empty
+	Interpolate bool      // place vessels between the fixes around each frame
+	MaxGap      string    // longest time between two fixes to interpolate across; 1h when empty
+	MaxAge      string    // leave out positions older than this at a frame
+}
+
+// GetPlayback returns the vessel positions of a park at every step of a time range, over the last hour by default.
+//
+//	GET /api/playback
+func (c *Client) GetPlayback(ctx context.Context, params *GetPlaybackParams) (*Playback, error) {
+	query := url.Values{}
+	if params != nil {
+		setQuery(query, "park", params.Park)
+		setQuery(query, "start", params.Start)
+		setQuery(query, "end", params.End)
+		setQuery(query, "step", params.Step)
+		setQuery(query, "interpolate", params.Interpolate)
+		setQuery(query, "max_gap", params.MaxGap)
+		setQuery(query, "max_age", params.MaxAge)
+	}
+
+	var out Playback
+	if err := c.do(ctx, "GET", "/api/playback", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetWeatherHistoryParams holds the query parameters of GetWeatherHistory
+type GetWeatherHistoryParams struct {
+	Park string    // park slug, the default park when empty
+	From time.Time // start of the range, an hour before to when zero
+	To   time.Time // end of the range, now when zero
+	Step string    // time between frames, such as 5m; 5m when empty
+}
+
+// GetWeatherHistory returns the weather stored for a park over a time range and at every step, the frame times of the playback of that range.
+//
+//	GET /api/weather/history
+func (c *Client) GetWeatherHistory(ctx context.Context, params *GetWeatherHistoryParams) (*WeatherHistory, error) {
+	query := url.Values{}
+	if params != nil {
+		setQuery(query, "park", params.Park)
+		setQuery(query, "from", params.From)
+		setQuery(query, "to", params.To)
+		setQuery(query, "step", params.Step)
+	}
+
+	var out WeatherHistory
+	if err := c.do(ctx, "GET", "/api/weather/history", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetEventsParams holds the query parameters of GetEvents
+type GetEventsParams struct {
+	Park  string    // park slug, the default park when empty
+	Since time.Time // earliest event time
+	Type  string    // entered_park, left_park, entered_buffer or left_buffer
+	Limit int       // maximum number of results, 100 when zero
+}
+
+// GetEvents lists the park and buffer zone entries and exits of all vessels, over the last 24 hours by default.
+//
+//	GET /api/events
+func (c *Client) GetEvents(ctx context.Context, params *GetEventsParams) (*ZoneEventList, error) {
+	query := url.Values{}
+	if params != nil {
+		setQuery(query, "park", params.Park)
+		setQuery(query, "since", params.Since)
+		setQuery(query, "type", params.Type)
+		setQuery(query, "limit", params.Limit)
+	}
+
+	var out ZoneEventList
+	if err := c.do(ctx, "GET", "/api/events", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SearchLocalVesselsParams holds the query parameters of SearchLocalVessels
+type SearchLocalVesselsParams struct {
+	Name  string // part of the vessel name, or a similar name where the database supports it
+	MMSI  string // MMSI prefix
+	Type  string // vessel type, case-insensitive
+	Limit int    // maximum number of results, 50 when zero
+}
+
+// SearchLocalVessels looks up vessels already observed in the stored records, without spending provider credits.
+//
+//	GET /api/vessels/local
+func (c *Client) SearchLocalVessels(ctx context.Context, params *SearchLocalVesselsParams) (*LocalVesselList, error) {
+	query := url.Values{}
+	if params != nil {
+		setQuery(query, "name", params.Name)
+		setQuery(query, "mmsi", params.MMSI)
+		setQuery(query, "type", params.Type)
+		setQuery(query, "limit", params.Limit)
+	}
+
+	var out LocalVesselList
+	if err := c.do(ctx, "GET", "/api/vessels/local", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetAnchoredVesselsParams holds the query parameters of GetAnchoredVessels
+type GetAnchoredVesselsParams struct {
+	Park   string // park slug, the default park when empty
+	MaxAge string // leave out vessels whose anchoring was last confirmed longer ago, such as 30m; 1h when empty
+}
+
+// GetAnchoredVessels lists the vessels anchored in a park now, longest anchored first, with the habitat under each.
+//
+//	GET /api/vessels/anchored
+func (c *Client) GetAnchoredVessels(ctx context.Context, params *GetAnchoredVesselsParams) (*AnchoredVesselList, error) {
+	query := url.Values{}
+	if params != nil {
+		setQuery(query, "park", params.Park)
+		setQuery(query, "max_age", params.MaxAge)
+	}
+
+	var out AnchoredVesselList
+	if err := c.do(ctx, "GET", "/api/vessels/anchored", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetAnchoringEventsParams holds the query parameters of GetAnchoringEvents
+type GetAnchoringEventsParams struct {
+	Park       string // park slug, the default park when empty
+	VesselUUID string // only events of this vessel
+	Active     bool   // only vessels still anchored
+	Limit      int    // maximum number of results, 100 when zero
+}
+
+// GetAnchoringEvents lists the anchoring events of a park.
+//
+//	GET /api/anchoring/events
+func (c *Client) GetAnchoringEvents(ctx context.Context, params *GetAnchoringEventsParams) (*AnchoringEventList, error) {
+	query := url.Values{}
+	if params != nil {
+		setQuery(query, "park", params.Park)
+		setQuery(query, "vessel_uuid", params.VesselUUID)
+		setQuery(query, "active", params.Active)
+		setQuery(query, "limit", params.Limit)
+	}
+
+	var out AnchoringEventList
+	if err := c.do(ctx, "GET", "/api/anchoring/events", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetArrivalsParams holds the query parameters of GetArrivals
+type GetArrivalsParams struct {
+	Park  string    // park slug, the default park when empty
+	Since time.Time // earliest first sighting
+	Limit int       // maximum number of results, 100 when zero
+}
+
+// GetArrivals lists the vessels seen in a park for the first time, over the last 24 hours by default.
+//
+//	GET /api/arrivals
+func (c *Client) GetArrivals(ctx context.Context, params *GetArrivalsParams) (*ArrivalList, error) {
+	query := url.Values{}
+	if params != nil {
+		setQuery(query, "park", params.Park)
+		setQuery(query, "since", params.Since)
+		setQuery(query, "limit", params.Limit)
+	}
+
+	var out ArrivalList
+	if err := c.do(ctx, "GET", "/api/arrivals", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CheckWhitelistParams holds the query parameters of CheckWhitelist
+type CheckWhitelistParams struct {
+	UUID string
+	MMSI string
+	IMO  string
+}
+
+// CheckWhitelist reports whether a vessel is whitelisted; at least one identifier is required.
+//
+//	GET /api/whitelist/check
+func (c *Client) CheckWhitelist(ctx context.Context, params *CheckWhitelistParams) (*WhitelistCheck, error) {
+	query := url.Values{}
+	if params != nil {
+		setQuery(query, "uuid", params.UUID)
+		setQuery(query, "mmsi", params.MMSI)
+		setQuery(query, "imo", params.IMO)
+	}
+
+	var out WhitelistCheck
+	if err := c.do(ctx, "GET", "/api/whitelist/check", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetSchedulerStatus returns the outcome of recent fetches and the next scheduled run.
+//
+//	GET /api/scheduler/status
+func (c *Client) GetSchedulerStatus(ctx context.Context) (*SchedulerStatus, error) {
+	query := url.Values{}
+
+	var out SchedulerStatus
+	if err := c.do(ctx, "GET", "/api/scheduler/status", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetDatalasticQuota returns the Datalastic credits spent today.
+//
+//	GET /api/datalastic/quota
+func (c *Client) GetDatalasticQuota(ctx context.Context) (*QuotaStatus, error) {
+	query := url.Values{}
+
+	var out QuotaStatus
+	if err := c.do(ctx, "GET", "/api/datalastic/quota", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetDeprecations lists the endpoints slated for removal.
+//
+//	GET /api/meta/deprecations
+func (c *Client) GetDeprecations(ctx context.Context) (*DeprecationList, error) {
+	query := url.Values{}
+
+	var out DeprecationList
+	if err := c.do(ctx, "GET", "/api/meta/deprecations", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetSiteParams holds the query parameters of GetSite
+type GetSiteParams struct {
+	Park string // park slug, the default park when empty
+}
+
+// GetSite describes how the web app presents a park: map view, layers, contacts and features.
+//
+//	GET /api/meta/site
+func (c *Client) GetSite(ctx context.Context, params *GetSiteParams) (*SiteInfo, error) {
+	query := url.Values{}
+	if params != nil {
+		setQuery(query, "park", params.Park)
+	}
+
+	var out SiteInfo
+	if err := c.do(ctx, "GET", "/api/meta/site", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Login starts a device session; the client must be authenticated with a role token.
+//
+//	POST /api/auth/login
+func (c *Client) Login(ctx context.Context, body LoginRequest) (*TokenPair, error) {
+	query := url.Values{}
+
+	var out TokenPair
+	if err := c.do(ctx, "POST", "/api/auth/login", query, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Refresh exchanges a refresh token for a new token pair.
+//
+//	POST /api/auth/refresh
+func (c *Client) Refresh(ctx context.Context, body RefreshRequest) (*TokenPair, error) {
+	query := url.Values{}
+
+	var out TokenPair
+	if err := c.do(ctx, "POST", "/api/auth/refresh", query, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
@@ -0,0 +1,66 @@
+// Command coastline builds the land mask from the OpenStreetMap coastline.
+//
+// Positions that plot on land are GPS errors; the server flags them instead
+// of counting them as presence in a park, using the land polygons in
+// LAND_FILE. This command fetches the coastline around a region from an
+// Overpass API instance (OVERPASS_URL), closes it into land polygons clipped
+// to the region and writes them to LAND_FILE or -out. The region defaults to
+// EXPECTED_REGION_BBOX, and should cover every park. Restart the server to
+// load a new mask; with COASTLINE_AUTO_FETCH=true it fetches the mask itself
+// when the file is missing.
+//
+// Run it from the backend directory:
+//
+//	go run ./cmd/coastline -region 9.0,40.8,10.0,41.6
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"vessel-tracker/services"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	godotenv.Load()
+
+	config, err := services.LoadLandMaskConfig()
+	if err != nil {
+		fatalf("Invalid land mask configuration: %v", err)
+	}
+
+	defaultRegion := os.Getenv("EXPECTED_REGION_BBOX")
+	if defaultRegion == "" {
+		r := services.DefaultExpectedRegion
+		defaultRegion = fmt.Sprintf("%g,%g,%g,%g", r.MinLon, r.MinLat, r.MaxLon, r.MaxLat)
+	}
+
+	regionStr := flag.String("region", defaultRegion, "area to fetch as minLon,minLat,maxLon,maxLat")
+	out := flag.String("out", config.Path, "GeoJSON file to write the land polygons to")
+	overpass := flag.String("overpass", config.OverpassURL, "Overpass API interpreter URL")
+	flag.Parse()
+
+	region, err := services.ParseRegion(*regionStr)
+	if err != nil {
+		fatalf("Invalid -region %q: %v", *regionStr, err)
+	}
+
+	fmt.Printf("Fetching the coastline of %s from %s\n", *regionStr, *overpass)
+	fc, err := services.FetchCoastline(context.Background(), *overpass, region)
+	if err != nil {
+		fatalf("Failed to build the land mask: %v", err)
+	}
+	if err := services.WriteLandFile(*out, fc); err != nil {
+		fatalf("%v", err)
+	}
+
+	fmt.Printf("Wrote %d land polygons to %s\n", len(fc.Features), *out)
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,376 @@
+package main
+
+import (
+	"net/http"
+	"time"
+	"vessel-tracker/handlers"
+	"vessel-tracker/middleware"
+	"vessel-tracker/models"
+	"vessel-tracker/services"
+)
+
+// param is a query parameter of an endpoint. Type is string, int, bool or
+// time; zero values are not sent.
+type param struct {
+	Name string
+	Type string
+	Doc  string
+}
+
+// endpoint is a route the client gets a method for. Path parameters become
+// string arguments in the order they appear in the path.
+type endpoint struct {
+	Name     string
+	Method   string
+	Path     string
+	Doc      string
+	Query    []param
+	Body     interface{} // request DTO, nil for none
+	Response interface{} // response DTO
+}
+
+var parkParam = param{Name: "park", Type: "string", Doc: "park slug, the default park when empty"}
+var limitParam = param{Name: "limit", Type: "int", Doc: "maximum number of results, 100 when zero"}
+
+// endpoints lists the routes covered by the client, chosen for the internal
+// tools and the coast guard integration. Keep it in step with main.go and
+// docs/openapi.yaml.
+var endpoints = []endpoint{
+	{
+		Name: "GetParks", Method: http.MethodGet, Path: "/api/parks",
+		Doc:      "lists the monitored parks; the first is the default",
+		Response: ParkList{},
+	},
+	{
+		Name: "GetVesselsInPark", Method: http.MethodGet, Path: "/api/vessels/in-park",
+		Doc:      "returns the latest position of every vessel inside a park",
+		Query:    []param{parkParam},
+		Response: VesselsInPark{},
+	},
+	{
+		Name: "GetVessel", Method: http.MethodGet, Path: "/api/vessels/:uuid",
+		Doc:      "returns a vessel's profile with its latest position and recent violation counts",
+		Query:    []param{parkParam},
+		Response: VesselProfile{},
+	},
+	{
+		Name: "GetPreviousPositions", Method: http.MethodGet, Path: "/api/vessels/:uuid/previous-positions",
+		Doc: "returns the stored positions of a vessel, over the last 7 days by default",
+		Query: []param{
+			parkParam,
+			{Name: "start_time", Type: "time", Doc: "start of the range"},
+			{Name: "end_time", Type: "time", Doc: "end of the range"},
+			limitParam,
+		},
+		Response: PreviousPositions{},
+	},
+	{
+		Name: "GetVesselEvents", Method: http.MethodGet, Path: "/api/vessels/:uuid/events",
+		Doc: "returns a vessel's park and buffer zone entries and exits, over the last 7 days by default",
+		Query: []param{
+			parkParam,
+			{Name: "start", Type: "time", Doc: "start of the range"},
+			{Name: "end", Type: "time", Doc: "end of the range"},
+			limitParam,
+		},
+		Response: VesselEvents{},
+	},
+	{
+		Name: "GetVesselProjection", Method: http.MethodGet, Path: "/api/vessels/:uuid/projection",
+		Doc:      "projects a vessel's track forward from its latest position, with where it would enter the park",
+		Query:    []param{parkParam},
+		Response: models.VesselProjection{},
+	},
+	{
+		Name: "GetPlayback", Method: http.MethodGet, Path: "/api/playback",
+		Doc: "returns the vessel positions of a park at every step of a time range, over the last hour by default",
+		Query: []param{
+			parkParam,
+			{Name: "start", Type: "time", Doc: "time of the first frame"},
+			{Name: "end", Type: "time", Doc: "latest time of a frame"},
+			{Name: "step", Type: "string", Doc: "time between frames, such as 5m; 5m when empty"},
+			{Name: "interpolate", Type: "bool", Doc: "place vessels between the fixes around each frame"},
+			{Name: "max_gap", Type: "string", Doc: "longest time between two fixes to interpolate across; 1h when empty"},
+			{Name: "max_age", Type: "string", Doc: "leave out positions older than this at a frame"},
+		},
+		Response: models.Playback{},
+	},
+	{
+		Name: "GetWeatherHistory", Method: http.MethodGet, Path: "/api/weather/history",
+		Doc: "returns the weather stored for a park over a time range and at every step, the frame times of the playback of that range",
+		Query: []param{
+			parkParam,
+			{Name: "from", Type: "time", Doc: "start of the range, an hour before to when zero"},
+			{Name: "to", Type: "time", Doc: "end of the range, now when zero"},
+			{Name: "step", Type: "string", Doc: "time between frames, such as 5m; 5m when empty"},
+		},
+		Response: models.WeatherHistory{},
+	},
+	{
+		Name: "GetEvents", Method: http.MethodGet, Path: "/api/events",
+		Doc: "lists the park and buffer zone entries and exits of all vessels, over the last 24 hours by default",
+		Query: []param{
+			parkParam,
+			{Name: "since", Type: "time", Doc: "earliest event time"},
+			{Name: "type", Type: "string", Doc: "entered_park, left_park, entered_buffer or left_buffer"},
+			limitParam,
+		},
+		Response: ZoneEventList{},
+	},
+	{
+		Name: "SearchLocalVessels", Method: http.MethodGet, Path: "/api/vessels/local",
+		Doc: "looks up vessels already observed in the stored records, without spending provider credits",
+		Query: []param{
+			{Name: "name", Type: "string", Doc: "part of the vessel name, or a similar name where the database supports it"},
+			{Name: "mmsi", Type: "string", Doc: "MMSI prefix"},
+			{Name: "type", Type: "string", Doc: "vessel type, case-insensitive"},
+			{Name: "limit", Type: "int", Doc: "maximum number of results, 50 when zero"},
+		},
+		Response: LocalVesselList{},
+	},
+	{
+		Name: "GetAnchoredVessels", Method: http.MethodGet, Path: "/api/vessels/anchored",
+		Doc: "lists the vessels anchored in a park now, longest anchored first, with the habitat under each",
+		Query: []param{
+			parkParam,
+			{Name: "max_age", Type: "string", Doc: "leave out vessels whose anchoring was last confirmed longer ago, such as 30m; 1h when empty"},
+		},
+		Response: AnchoredVesselList{},
+	},
+	{
+		Name: "GetAnchoringEvents", Method: http.MethodGet, Path: "/api/anchoring/events",
+		Doc: "lists the anchoring events of a park",
+		Query: []param{
+			parkParam,
+			{Name: "vessel_uuid", Type: "string", Doc: "only events of this vessel"},
+			{Name: "active", Type: "bool", Doc: "only vessels still anchored"},
+			limitParam,
+		},
+		Response: AnchoringEventList{},
+	},
+	{
+		Name: "GetArrivals", Method: http.MethodGet, Path: "/api/arrivals",
+		Doc: "lists the vessels seen in a park for the first time, over the last 24 hours by default",
+		Query: []param{
+			parkParam,
+			{Name: "since", Type: "time", Doc: "earliest first sighting"},
+			limitParam,
+		},
+		Response: ArrivalList{},
+	},
+	{
+		Name: "CheckWhitelist", Method: http.MethodGet, Path: "/api/whitelist/check",
+		Doc: "reports whether a vessel is whitelisted; at least one identifier is required",
+		Query: []param{
+			{Name: "uuid", Type: "string"},
+			{Name: "mmsi", Type: "string"},
+			{Name: "imo", Type: "string"},
+		},
+		Response: WhitelistCheck{},
+	},
+	{
+		Name: "GetSchedulerStatus", Method: http.MethodGet, Path: "/api/scheduler/status",
+		Doc:      "returns the outcome of recent fetches and the next scheduled run",
+		Response: services.SchedulerStatus{},
+	},
+	{
+		Name: "GetDatalasticQuota", Method: http.MethodGet, Path: "/api/datalastic/quota",
+		Doc:      "returns the Datalastic credits spent today",
+		Response: services.QuotaStatus{},
+	},
+	{
+		Name: "GetDeprecations", Method: http.MethodGet, Path: middleware.DeprecationsPath,
+		Doc:      "lists the endpoints slated for removal",
+		Response: DeprecationList{},
+	},
+	{
+		Name: "GetSite", Method: http.MethodGet, Path: "/api/meta/site",
+		Doc:      "describes how the web app presents a park: map view, layers, contacts and features",
+		Query:    []param{parkParam},
+		Response: services.SiteInfo{},
+	},
+	{
+		Name: "Login", Method: http.MethodPost, Path: "/api/auth/login",
+		Doc:      "starts a device session; the client must be authenticated with a role token",
+		Body:     handlers.LoginRequest{},
+		Response: services.TokenPair{},
+	},
+	{
+		Name: "Refresh", Method: http.MethodPost, Path: "/api/auth/refresh",
+		Doc:      "exchanges a refresh token for a new token pair",
+		Body:     handlers.RefreshRequest{},
+		Response: services.TokenPair{},
+	},
+}
+
+// The handlers answer most routes with gin.H maps; the types below describe
+// those responses for the generated code.
+
+type LatLon struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+type ParkSummary struct {
+	Park                models.Park `json:"park"`
+	Default             bool        `json:"default"`
+	Center              LatLon      `json:"center"`
+	BoundariesHealthy   bool        `json:"boundaries_healthy"`
+	BufferZoneAvailable bool        `json:"buffer_zone_available"`
+}
+
+type ParkList struct {
+	Parks []ParkSummary `json:"parks"`
+	Count int           `json:"count"`
+}
+
+type VesselSummary struct {
+	UUID         string  `json:"uuid,omitempty"`
+	Name         string  `json:"name"`
+	MMSI         string  `json:"mmsi"`
+	IMO          string  `json:"imo,omitempty"`
+	Type         string  `json:"type"`
+	TypeSpecific string  `json:"type_specific,omitempty"`
+	CountryISO   string  `json:"country_iso,omitempty"`
+	CountryName  string  `json:"country_name,omitempty"`
+	Speed        float64 `json:"speed,omitempty"`
+	Course       float64 `json:"course,omitempty"`
+	Heading      *int    `json:"heading,omitempty"`
+	Destination  string  `json:"destination,omitempty"`
+	Distance     float64 `json:"distance,omitempty"`
+}
+
+type WhitelistInfo struct {
+	Reason  string `json:"reason"`
+	AddedBy string `json:"added_by,omitempty"` // rangers and administrators only
+}
+
+type ParkVessel struct {
+	Vessel         VesselSummary  `json:"vessel"`
+	Latitude       float64        `json:"latitude"`
+	Longitude      float64        `json:"longitude"`
+	IsInPark       bool           `json:"is_in_park"`
+	IsInBufferZone bool           `json:"is_in_buffer_zone"`
+	IsWhitelisted  bool           `json:"is_whitelisted,omitempty"`
+	Timestamp      string         `json:"timestamp,omitempty"`
+	WhitelistInfo  *WhitelistInfo `json:"whitelist_info,omitempty"`
+}
+
+type VesselsInPark struct {
+	Park                string       `json:"park,omitempty"`
+	VesselsInPark       []ParkVessel `json:"vessels_in_park"`
+	TotalInPark         int          `json:"total_in_park"`
+	ParkCenter          LatLon       `json:"park_center"`
+	BufferZoneAvailable bool         `json:"buffer_zone_available,omitempty"`
+	DataAsOf            *time.Time   `json:"data_as_of"`
+	Source              string       `json:"source"`
+}
+
+type LatestPosition struct {
+	Latitude       float64   `json:"latitude"`
+	Longitude      float64   `json:"longitude"`
+	Speed          float64   `json:"speed"`
+	Course         float64   `json:"course"`
+	Heading        *int      `json:"heading"`
+	Destination    string    `json:"destination"`
+	Distance       float64   `json:"distance"`
+	IsInPark       bool      `json:"is_in_park"`
+	IsInBufferZone bool      `json:"is_in_buffer_zone"`
+	Timestamp      string    `json:"timestamp"`
+	RecordedAt     time.Time `json:"recorded_at"`
+}
+
+type VesselProfile struct {
+	Park                string                        `json:"park"`
+	Vessel              models.VesselRecord           `json:"vessel"`
+	LatestPosition      *LatestPosition               `json:"latest_position"`
+	IsWhitelisted       bool                          `json:"is_whitelisted"`
+	WhitelistInfo       *WhitelistInfo                `json:"whitelist_info,omitempty"`
+	Violations          models.VesselViolationSummary `json:"violations"`
+	BufferZoneAvailable bool                          `json:"buffer_zone_available"`
+}
+
+type PreviousPosition struct {
+	Latitude    float64   `json:"latitude"`
+	Longitude   float64   `json:"longitude"`
+	Speed       float64   `json:"speed"`
+	Course      float64   `json:"course"`
+	Heading     *int      `json:"heading"`
+	Destination string    `json:"destination"`
+	Distance    float64   `json:"distance"`
+	IsInPark    bool      `json:"is_in_park"`
+	Timestamp   string    `json:"timestamp"`
+	RecordedAt  time.Time `json:"recorded_at"`
+}
+
+type PreviousPositions struct {
+	VesselUUID        string             `json:"vessel_uuid"`
+	PreviousPositions []PreviousPosition `json:"previous_positions"`
+	Count             int                `json:"count"`
+	StartTime         string             `json:"start_time"`
+	EndTime           string             `json:"end_time"`
+	Limit             int                `json:"limit"`
+}
+
+type VesselEvents struct {
+	Park       string             `json:"park"`
+	VesselUUID string             `json:"vessel_uuid"`
+	Start      time.Time          `json:"start"`
+	End        time.Time          `json:"end"`
+	Events     []models.ZoneEvent `json:"events"`
+	Count      int                `json:"count"`
+}
+
+type ZoneEventList struct {
+	Park   string             `json:"park"`
+	Events []models.ZoneEvent `json:"events"`
+	Count  int                `json:"count"`
+}
+
+type LocalVesselList struct {
+	Vessels  []models.VesselRecord `json:"vessels"`
+	Count    int                   `json:"count"`
+	DataAsOf *time.Time            `json:"data_as_of"`
+	Source   string                `json:"source"`
+}
+
+type AnchoringEventList struct {
+	Park   string                  `json:"park"`
+	Events []models.AnchoringEvent `json:"events"`
+	Count  int                     `json:"count"`
+}
+
+type AnchoringThresholds struct {
+	MaxSpeedKnots  float64 `json:"max_speed_knots"`
+	MaxDriftMeters float64 `json:"max_drift_meters"`
+	MinPositions   int     `json:"min_positions"`
+}
+
+type AnchoredVesselList struct {
+	Park       string                  `json:"park"`
+	Thresholds AnchoringThresholds     `json:"thresholds"`
+	MaxAge     string                  `json:"max_age"`
+	Vessels    []models.AnchoredVessel `json:"vessels"`
+	Count      int                     `json:"count"`
+	DataAsOf   *time.Time              `json:"data_as_of"`
+	Source     string                  `json:"source"`
+}
+
+type ArrivalList struct {
+	Park     string               `json:"park"`
+	Arrivals []models.ParkArrival `json:"arrivals"`
+	Count    int                  `json:"count"`
+}
+
+type WhitelistCheck struct {
+	IsWhitelisted  bool                   `json:"is_whitelisted"`
+	UUID           string                 `json:"uuid"`
+	MMSI           string                 `json:"mmsi"`
+	IMO            string                 `json:"imo"`
+	WhitelistEntry *models.WhitelistEntry `json:"whitelist_entry,omitempty"`
+}
+
+type DeprecationList struct {
+	Deprecations []middleware.Deprecation `json:"deprecations"`
+	Count        int                      `json:"count"`
+}
@@ -0,0 +1,519 @@
+// Command genclient generates the Go client package in client/ and the
+// TypeScript types used by the frontend from the API's response DTOs, so
+// internal tools and integrations do not hand-roll HTTP calls.
+//
+// The routes covered are listed in endpoints.go, together with the types
+// describing the responses the handlers build as maps. The request and
+// response types are read by reflection, so the generated types follow the
+// json tags of the models; fields visible to rangers or administrators only
+// are optional in TypeScript. Regenerate after changing a covered route or a
+// type it returns, from the backend directory:
+//
+//	go generate ./client
+//
+// and check in CI that the generated files are current with:
+//
+//	go run ./cmd/genclient -check
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	rawMessageType    = reflect.TypeOf(json.RawMessage{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+const generatedHeader = "Code generated by genclient. DO NOT EDIT."
+
+func main() {
+	goOut := flag.String("go-out", "client/generated.go", "file the Go client types and methods are written to")
+	tsOut := flag.String("ts-out", "../frontend/src/types/api.generated.ts", "file the TypeScript types are written to")
+	check := flag.Bool("check", false, "only report whether the generated files are current")
+	flag.Parse()
+
+	types, err := collectTypes(endpoints)
+	if err != nil {
+		fatalf("Failed to collect types: %v", err)
+	}
+
+	goSource, err := generateGo(endpoints, types)
+	if err != nil {
+		fatalf("Failed to generate the Go client: %v", err)
+	}
+	tsSource := generateTS(types)
+
+	outputs := []struct {
+		path string
+		data []byte
+	}{
+		{*goOut, goSource},
+		{*tsOut, tsSource},
+	}
+
+	stale := false
+	for _, output := range outputs {
+		if *check {
+			current, err := os.ReadFile(output.path)
+			if err != nil || !bytes.Equal(current, output.data) {
+				fmt.Fprintf(os.Stderr, "%s is out of date, run go generate ./client\n", output.path)
+				stale = true
+			}
+			continue
+		}
+		if err := os.WriteFile(output.path, output.data, 0644); err != nil {
+			fatalf("Failed to write %s: %v", output.path, err)
+		}
+		fmt.Printf("Wrote %s\n", output.path)
+	}
+	if stale {
+		os.Exit(1)
+	}
+}
+
+// collectTypes returns every named struct type reachable from the request
+// and response types of the endpoints, sorted by name. Two types with the
+// same name in different packages are an error, as the generated code puts
+// them in one namespace.
+func collectTypes(endpoints []endpoint) ([]reflect.Type, error) {
+	byName := make(map[string]reflect.Type)
+
+	var visit func(t reflect.Type) error
+	visit = func(t reflect.Type) error {
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+			return visit(t.Elem())
+		case reflect.Struct:
+		default:
+			return nil
+		}
+		if t == timeType || opaque(t) {
+			return nil
+		}
+		if t.Name() == "" {
+			return fmt.Errorf("anonymous struct types are not supported, name the type")
+		}
+
+		if existing, ok := byName[t.Name()]; ok {
+			if existing != t {
+				return fmt.Errorf("type name %s is used by both %s and %s", t.Name(), existing.PkgPath(), t.PkgPath())
+			}
+			return nil
+		}
+		byName[t.Name()] = t
+
+		for _, field := range jsonFields(t) {
+			if err := visit(field.Type); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, e := range endpoints {
+		if e.Body != nil {
+			if err := visit(reflect.TypeOf(e.Body)); err != nil {
+				return nil, err
+			}
+		}
+		if err := visit(reflect.TypeOf(e.Response)); err != nil {
+			return nil, err
+		}
+	}
+
+	types := make([]reflect.Type, 0, len(byName))
+	for _, t := range byName {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i].Name() < types[j].Name() })
+	return types, nil
+}
+
+// opaque reports whether a type marshals itself, so its JSON shape cannot
+// be derived from its fields
+func opaque(t reflect.Type) bool {
+	if t == timeType {
+		return false
+	}
+	return t.Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(jsonMarshalerType)
+}
+
+// jsonField is a struct field as it appears in JSON
+type jsonField struct {
+	GoName    string
+	Name      string
+	Type      reflect.Type
+	OmitEmpty bool
+	Role      string // minimum role the field is shown to
+}
+
+// jsonFields lists the fields encoding/json writes for t, with embedded
+// structs flattened
+func jsonFields(t reflect.Type) []jsonField {
+	var fields []jsonField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" && opts == "" {
+			continue
+		}
+
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			fields = append(fields, jsonFields(field.Type)...)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		fields = append(fields, jsonField{
+			GoName:    field.Name,
+			Name:      name,
+			Type:      field.Type,
+			OmitEmpty: strings.Contains(","+opts+",", ",omitempty,"),
+			Role:      field.Tag.Get("role"),
+		})
+	}
+	return fields
+}
+
+// goType returns the Go spelling of t in the client package
+func goType(t reflect.Type) string {
+	switch {
+	case t == timeType:
+		return "time.Time"
+	case t == rawMessageType || (t.Kind() == reflect.Struct && opaque(t)):
+		return "json.RawMessage"
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return "*" + goType(t.Elem())
+	case reflect.Slice:
+		return "[]" + goType(t.Elem())
+	case reflect.Array:
+		return fmt.Sprintf("[%d]%s", t.Len(), goType(t.Elem()))
+	case reflect.Map:
+		return "map[" + goType(t.Key()) + "]" + goType(t.Elem())
+	case reflect.Interface:
+		return "interface{}"
+	case reflect.Struct:
+		return t.Name()
+	default:
+		// Named basic types are spelled as their underlying type
+		return t.Kind().String()
+	}
+}
+
+// tsType returns the TypeScript spelling of t
+func tsType(t reflect.Type) string {
+	switch {
+	case t == timeType:
+		return "string"
+	case t == rawMessageType || (t.Kind() == reflect.Struct && opaque(t)):
+		return "unknown"
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return tsType(t.Elem()) + " | null"
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "string" // base64
+		}
+		elem := tsType(t.Elem())
+		if strings.Contains(elem, " ") {
+			elem = "(" + elem + ")"
+		}
+		return elem + "[]"
+	case reflect.Map:
+		return "Record<string, " + tsType(t.Elem()) + ">"
+	case reflect.Interface:
+		return "unknown"
+	case reflect.Struct:
+		return t.Name()
+	case reflect.Bool:
+		return "boolean"
+	case reflect.String:
+		return "string"
+	default:
+		return "number"
+	}
+}
+
+// pathArg is a path parameter of an endpoint
+type pathArg struct {
+	Name    string // route parameter name
+	GoName  string // argument name
+	Segment int
+}
+
+// goEndpoint is an endpoint as rendered in the Go template
+type goEndpoint struct {
+	endpoint
+	Args       []pathArg
+	Segments   []string
+	ParamsType string
+	Fields     []goParam
+	BodyType   string
+	Response   string
+}
+
+type goParam struct {
+	param
+	GoName string
+	GoType string
+}
+
+func newGoEndpoint(e endpoint) goEndpoint {
+	g := goEndpoint{endpoint: e, Response: goType(reflect.TypeOf(e.Response))}
+	if e.Body != nil {
+		g.BodyType = goType(reflect.TypeOf(e.Body))
+	}
+
+	for i, segment := range strings.Split(strings.TrimPrefix(e.Path, "/"), "/") {
+		if name, ok := strings.CutPrefix(segment, ":"); ok {
+			g.Args = append(g.Args, pathArg{Name: name, GoName: lowerCamel(name), Segment: i})
+			g.Segments = append(g.Segments, "")
+			continue
+		}
+		g.Segments = append(g.Segments, segment)
+	}
+
+	if len(e.Query) > 0 {
+		g.ParamsType = e.Name + "Params"
+		for _, p := range e.Query {
+			gp := goParam{param: p, GoName: upperCamel(p.Name)}
+			switch p.Type {
+			case "time":
+				gp.GoType = "time.Time"
+			default:
+				gp.GoType = p.Type
+			}
+			g.Fields = append(g.Fields, gp)
+		}
+	}
+	return g
+}
+
+// PathExpr returns the Go expression building the request path
+func (g goEndpoint) PathExpr() string {
+	if len(g.Args) == 0 {
+		return fmt.Sprintf("%q", g.Path)
+	}
+
+	var parts []string
+	literal := ""
+	argAt := make(map[int]pathArg, len(g.Args))
+	for _, arg := range g.Args {
+		argAt[arg.Segment] = arg
+	}
+	for i, segment := range g.Segments {
+		literal += "/"
+		arg, ok := argAt[i]
+		if !ok {
+			literal += segment
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%q", literal), "url.PathEscape("+arg.GoName+")")
+		literal = ""
+	}
+	if literal != "" {
+		parts = append(parts, fmt.Sprintf("%q", literal))
+	}
+	return strings.Join(parts, " + ")
+}
+
+// Signature returns the method parameters after ctx
+func (g goEndpoint) Signature() string {
+	var args []string
+	for _, arg := range g.Args {
+		args = append(args, arg.GoName+" string")
+	}
+	if g.BodyType != "" {
+		args = append(args, "body "+g.BodyType)
+	}
+	if g.ParamsType != "" {
+		args = append(args, "params *"+g.ParamsType)
+	}
+	if len(args) == 0 {
+		return ""
+	}
+	return ", " + strings.Join(args, ", ")
+}
+
+// goStruct is a DTO as rendered in the Go template
+type goStruct struct {
+	Name   string
+	Fields []goStructField
+}
+
+type goStructField struct {
+	Name string
+	Type string
+	Tag  string
+}
+
+var goTemplate = template.Must(template.New("go").Parse(`// {{.Header}}
+
+package client
+
+import (
+{{- range .Imports}}
+	"{{.}}"
+{{- end}}
+)
+{{range .Structs}}
+type {{.Name}} struct {
+{{- range .Fields}}
+	{{.Name}} {{.Type}} ` + "`{{.Tag}}`" + `
+{{- end}}
+}
+{{end}}
+{{- range .Endpoints}}{{if .ParamsType}}
+// {{.ParamsType}} holds the query parameters of {{.Name}}
+type {{.ParamsType}} struct {
+{{- range .Fields}}
+	{{.GoName}} {{.GoType}}{{if .Doc}} // {{.Doc}}{{end}}
+{{- end}}
+}
+{{end}}
+// {{.Name}} {{.Doc}}.
+//
+//	{{.Method}} {{.Path}}
+func (c *Client) {{.Name}}(ctx context.Context{{.Signature}}) (*{{.Response}}, error) {
+	query := url.Values{}
+{{- if .ParamsType}}
+	if params != nil {
+{{- range .Fields}}
+		setQuery(query, "{{.Name}}", params.{{.GoName}})
+{{- end}}
+	}
+{{- end}}
+
+	var out {{.Response}}
+	if err := c.do(ctx, "{{.Method}}", {{.PathExpr}}, query, {{if .BodyType}}body{{else}}nil{{end}}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+{{end}}`))
+
+func generateGo(endpoints []endpoint, types []reflect.Type) ([]byte, error) {
+	var data struct {
+		Header    string
+		Imports   []string
+		Structs   []goStruct
+		Endpoints []goEndpoint
+	}
+	data.Header = generatedHeader
+	usesJSON, usesTime := false, false
+
+	for _, t := range types {
+		s := goStruct{Name: t.Name()}
+		for _, field := range jsonFields(t) {
+			tag := field.Name
+			if field.OmitEmpty {
+				tag += ",omitempty"
+			}
+			fieldType := goType(field.Type)
+			usesJSON = usesJSON || strings.Contains(fieldType, "json.")
+			usesTime = usesTime || strings.Contains(fieldType, "time.")
+			s.Fields = append(s.Fields, goStructField{
+				Name: field.GoName,
+				Type: fieldType,
+				Tag:  fmt.Sprintf("json:%q", tag),
+			})
+		}
+		data.Structs = append(data.Structs, s)
+	}
+	for _, e := range endpoints {
+		g := newGoEndpoint(e)
+		for _, field := range g.Fields {
+			usesTime = usesTime || field.GoType == "time.Time"
+		}
+		data.Endpoints = append(data.Endpoints, g)
+	}
+
+	data.Imports = []string{"context"}
+	if usesJSON {
+		data.Imports = append(data.Imports, "encoding/json")
+	}
+	data.Imports = append(data.Imports, "net/url")
+	if usesTime {
+		data.Imports = append(data.Imports, "time")
+	}
+
+	var buf bytes.Buffer
+	if err := goTemplate.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	formatted, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("generated Go does not parse: %w", err)
+	}
+	return formatted, nil
+}
+
+func generateTS(types []reflect.Type) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// %s\n// Run `go generate ./client` in the backend to update it.\n", generatedHeader)
+
+	for _, t := range types {
+		fmt.Fprintf(&buf, "\nexport interface %s {\n", t.Name())
+		for _, field := range jsonFields(t) {
+			optional := ""
+			if field.OmitEmpty || field.Role != "" {
+				optional = "?"
+			}
+			fmt.Fprintf(&buf, "  %s%s: %s;\n", field.Name, optional, tsType(field.Type))
+		}
+		buf.WriteString("}\n")
+	}
+	return buf.Bytes()
+}
+
+// upperCamel turns a snake_case name into an exported Go identifier
+func upperCamel(name string) string {
+	var b strings.Builder
+	for _, part := range strings.Split(name, "_") {
+		switch upper := strings.ToUpper(part); upper {
+		case "ID", "UUID", "MMSI", "IMO", "URL":
+			b.WriteString(upper)
+		default:
+			if part != "" {
+				b.WriteString(upper[:1] + part[1:])
+			}
+		}
+	}
+	return b.String()
+}
+
+// lowerCamel turns a snake_case name into an unexported Go identifier
+func lowerCamel(name string) string {
+	camel := upperCamel(name)
+	if strings.ToUpper(camel) == camel {
+		return strings.ToLower(camel)
+	}
+	return strings.ToLower(camel[:1]) + camel[1:]
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,277 @@
+// Command loadtest drives the vessel ingestion pipeline with synthetic
+// vessels and reports throughput and per-cycle latency.
+//
+// Each cycle runs the same fetch the scheduler runs - provider request, JSON
+// decoding, deduplication, storage, violation and anchoring detection -
+// against a synthetic Datalastic that returns the requested number of
+// vessels around the park center. By default a throwaway SQLite database is
+// used; -driver postgres uses the DB_* environment variables instead and
+// must point at a test database.
+//
+// Run it from the backend directory:
+//
+//	go run ./cmd/loadtest -vessels 2000 -cycles 10
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+	"vessel-tracker/database"
+	"vessel-tracker/models"
+	"vessel-tracker/services"
+)
+
+func main() {
+	vessels := flag.Int("vessels", 500, "synthetic vessels returned per fetch cycle")
+	cycles := flag.Int("cycles", 5, "number of fetch cycles to run")
+	moving := flag.Float64("moving", 1.0, "fraction of vessels that move between cycles (the rest are deduplicated)")
+	radius := flag.Int("radius", 20, "fetch radius in nautical miles")
+	driver := flag.String("driver", "sqlite", "database driver: sqlite or postgres")
+	dbPath := flag.String("db", "", "SQLite database file (default: a temporary file)")
+	dataDir := flag.String("data", "./data", "directory holding the park boundary GeoJSON files")
+	seed := flag.Int64("seed", 1, "random seed for the synthetic fleet")
+	verbose := flag.Bool("v", false, "keep pipeline and SQL logging")
+	flag.Parse()
+
+	if *vessels < 1 || *cycles < 1 || *moving < 0 || *moving > 1 {
+		log.Fatal("vessels and cycles must be positive and moving between 0 and 1")
+	}
+
+	os.Setenv("DB_DRIVER", *driver)
+	if *driver == "sqlite" {
+		path := *dbPath
+		if path == "" {
+			dir, err := os.MkdirTemp("", "vessel-loadtest")
+			if err != nil {
+				log.Fatalf("Failed to create temporary directory: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			path = filepath.Join(dir, "loadtest.db")
+		}
+		os.Setenv("DB_PATH", path)
+	}
+
+	if !*verbose {
+		log.SetOutput(io.Discard)
+		os.Setenv("DB_LOG_LEVEL", "silent")
+	}
+
+	dbConfig, err := database.LoadConfig()
+	if err != nil {
+		fatalf("Invalid database configuration: %v", err)
+	}
+	if err := database.InitDatabase(dbConfig); err != nil {
+		fatalf("Failed to initialize database: %v", err)
+	}
+
+	parkConfig := services.DefaultParkConfigs()[0]
+	parkConfig.Boundaries = filepath.Join(*dataDir, "national-park.geojson")
+	parkConfig.Buffered = filepath.Join(*dataDir, "buffered.geojson")
+
+	boundaryConfig, err := services.LoadBoundaryConfig()
+	if err != nil {
+		fatalf("Invalid boundary configuration: %v", err)
+	}
+	parks, err := services.NewParkRegistry([]services.ParkConfig{parkConfig}, boundaryConfig, services.S3Credentials{}, "")
+	if err != nil {
+		fatalf("Failed to initialize park: %v", err)
+	}
+
+	centerLat, centerLon := parks.Default().Geo.GetParkCenter()
+	fleet := newFleet(*vessels, centerLat, centerLon, *radius, *seed)
+	client := &http.Client{Transport: &syntheticProvider{fleet: fleet}}
+
+	schedulerConfig := services.DefaultSchedulerConfig()
+	schedulerConfig.RadiusNM = *radius
+	if err := schedulerConfig.Validate(); err != nil {
+		fatalf("Invalid radius: %v", err)
+	}
+
+	vesselRepo := services.NewVesselRepository(services.DefaultPositionDedupConfig())
+	violationService := services.NewViolationService(services.NewWhitelistService())
+	scheduler := services.NewSchedulerService(
+		schedulerConfig,
+		services.NewVesselService(services.NewDatalasticProviderWithClient("loadtest", client)),
+		parks,
+		vesselRepo,
+		violationService,
+		services.NewWatchlistService(services.DefaultWatchlistConfig(), violationService),
+		services.NewTrajectoryService(services.DefaultTrajectoryConfig(), vesselRepo, violationService),
+		services.NewAnchoringDetector(vesselRepo, services.DefaultAnchoringConfig()),
+		nil,
+		services.NewArrivalService(),
+		services.NewZoneEventService(),
+		services.NewSanctionService(),
+		services.NewRetentionService(services.DefaultRetentionConfig(schedulerConfig.RetentionDays), nil, parks, nil),
+		services.NewWeatherService(services.DefaultWeatherConfig()),
+		nil,
+	)
+
+	fmt.Printf("Ingesting %d vessels x %d cycles (%.0f%% moving) into %s\n", *vessels, *cycles, *moving*100, *driver)
+
+	latencies := make([]time.Duration, 0, *cycles)
+	var stored, skipped int
+	start := time.Now()
+
+	for cycle := 1; cycle <= *cycles; cycle++ {
+		if cycle > 1 {
+			fleet.advance(*moving)
+		}
+
+		cycleStart := time.Now()
+		if err := scheduler.RunFetch(); err != nil {
+			fatalf("Cycle %d failed: %v", cycle, err)
+		}
+		elapsed := time.Since(cycleStart)
+		latencies = append(latencies, elapsed)
+
+		status := scheduler.Status()
+		stored += status.PositionsStored
+		skipped += status.DuplicateSkipped
+
+		fmt.Printf("cycle %3d  %8s  stored %6d  duplicates %6d\n",
+			cycle, elapsed.Round(time.Millisecond), status.PositionsStored, status.DuplicateSkipped)
+	}
+
+	total := time.Since(start)
+	processed := *vessels * *cycles
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	fmt.Println()
+	fmt.Printf("total       %s\n", total.Round(time.Millisecond))
+	fmt.Printf("throughput  %.0f positions/s\n", float64(processed)/total.Seconds())
+	fmt.Printf("stored      %d (%d duplicates skipped)\n", stored, skipped)
+	fmt.Printf("latency     min %s  p50 %s  p95 %s  max %s\n",
+		latencies[0].Round(time.Millisecond),
+		percentile(latencies, 50).Round(time.Millisecond),
+		percentile(latencies, 95).Round(time.Millisecond),
+		latencies[len(latencies)-1].Round(time.Millisecond))
+}
+
+// fatalf reports to stderr even when logging is silenced
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
+
+// percentile returns the nearest-rank percentile of sorted durations
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank]
+}
+
+// fleet is a set of synthetic vessels scattered around the park center
+type fleet struct {
+	rng       *rand.Rand
+	epoch     int64
+	positions []models.VesselPosition
+}
+
+var vesselTypes = []string{"Passenger", "Pleasure Craft", "Sailing", "Fishing", "Cargo", "Tanker", "Tug"}
+
+func newFleet(size int, centerLat, centerLon float64, radiusNM int, seed int64) *fleet {
+	f := &fleet{
+		rng:   rand.New(rand.NewSource(seed)),
+		epoch: time.Now().Unix(),
+	}
+
+	radiusMeters := float64(radiusNM) * 1852
+	for i := 0; i < size; i++ {
+		// Uniform over the disc around the center
+		distance := radiusMeters * math.Sqrt(f.rng.Float64())
+		lat, lon := offset(centerLat, centerLon, distance, f.rng.Float64()*360)
+
+		mmsi := strconv.Itoa(247000000 + i)
+		f.positions = append(f.positions, models.VesselPosition{
+			UUID:         fmt.Sprintf("loadtest-%06d", i),
+			Name:         fmt.Sprintf("LOADTEST %d", i),
+			MMSI:         mmsi,
+			Type:         vesselTypes[i%len(vesselTypes)],
+			Latitude:     lat,
+			Longitude:    lon,
+			Speed:        f.rng.Float64() * 15,
+			Course:       f.rng.Float64() * 360,
+			Destination:  "LA MADDALENA",
+			CountryISO:   "IT",
+			Distance:     distance / 1852,
+			LastPosEpoch: f.epoch,
+			LastPosUTC:   time.Unix(f.epoch, 0).UTC().Format("2006-01-02 15:04:05"),
+		})
+	}
+
+	return f
+}
+
+// advance moves the given fraction of vessels about 100 m along their
+// course and gives them a new position timestamp
+func (f *fleet) advance(moving float64) {
+	f.epoch += 60
+	for i := range f.positions {
+		if f.rng.Float64() >= moving {
+			continue
+		}
+
+		pos := &f.positions[i]
+		pos.Latitude, pos.Longitude = offset(pos.Latitude, pos.Longitude, 100, pos.Course)
+		pos.LastPosEpoch = f.epoch
+		pos.LastPosUTC = time.Unix(f.epoch, 0).UTC().Format("2006-01-02 15:04:05")
+	}
+}
+
+// offset moves a point by a distance in meters along a bearing in degrees
+func offset(lat, lon, meters, bearing float64) (float64, float64) {
+	const earthRadius = 6371000.0
+	rad := bearing * math.Pi / 180
+	dLat := meters * math.Cos(rad) / earthRadius
+	dLon := meters * math.Sin(rad) / (earthRadius * math.Cos(lat*math.Pi/180))
+	return lat + dLat*180/math.Pi, lon + dLon*180/math.Pi
+}
+
+// syntheticProvider answers Datalastic in-radius requests with the fleet
+type syntheticProvider struct {
+	fleet *fleet
+}
+
+func (p *syntheticProvider) RoundTrip(req *http.Request) (*http.Response, error) {
+	if filepath.Base(req.URL.Path) != "vessel_inradius" {
+		return jsonResponse(req, http.StatusNotFound, map[string]string{"error": "not simulated"})
+	}
+
+	return jsonResponse(req, http.StatusOK, models.VesselPositionResponse{
+		Data: models.VesselPositionData{
+			Total:   len(p.fleet.positions),
+			Vessels: p.fleet.positions,
+		},
+	})
+}
+
+func jsonResponse(req *http.Request, status int, body interface{}) (*http.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Response{
+		StatusCode:    status,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(data)),
+		ContentLength: int64(len(data)),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,172 @@
+// Command replay rebuilds vessel positions and the records derived from them
+// from the raw provider responses kept with PROVIDER_AUDIT=true.
+//
+// After a classification bug fix or a boundary change, replay deletes the
+// positions, zone events, first arrivals and anchoring events recorded since
+// -since and runs the recorded responses through the ingestion pipeline
+// again, in the order they were received and as of the time they were
+// received. Violations are left alone. Positions stored since -since without
+// a recorded response are lost, so -since must not predate the provider
+// audit (or RETENTION_PROVIDER_RESPONSES_DAYS). Stop the server first so no
+// fetch runs during the replay. The database and parks are selected with the
+// same environment variables (and .env file) as the server.
+//
+// Run it from the backend directory, first with -dry-run to see what would be
+// replaced:
+//
+//	go run ./cmd/replay -since 2026-10-01T00:00:00Z -dry-run
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"time"
+	"vessel-tracker/database"
+	"vessel-tracker/services"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	sinceStr := flag.String("since", "", "replay the responses recorded since this RFC3339 time (required)")
+	dryRun := flag.Bool("dry-run", false, "only report the responses and the rows that would be replaced")
+	flag.Parse()
+
+	if *sinceStr == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	since, err := time.Parse(time.RFC3339, *sinceStr)
+	if err != nil {
+		fatalf("Invalid -since %q, use RFC3339: %v", *sinceStr, err)
+	}
+
+	godotenv.Load()
+
+	dbConfig, err := database.LoadConfig()
+	if err != nil {
+		fatalf("Invalid database configuration: %v", err)
+	}
+	if err := database.InitDatabase(dbConfig); err != nil {
+		fatalf("Failed to initialize database: %v", err)
+	}
+	defer database.Close()
+
+	parkConfigs, err := services.LoadParkConfigs()
+	if err != nil {
+		fatalf("Invalid park configuration: %v", err)
+	}
+	boundaryConfig, err := services.LoadBoundaryConfig()
+	if err != nil {
+		fatalf("Invalid boundary configuration: %v", err)
+	}
+	region, err := services.LoadDeploymentRegion()
+	if err != nil {
+		fatalf("Invalid deployment region: %v", err)
+	}
+	parks, err := services.NewParkRegistry(parkConfigs, boundaryConfig, services.LoadS3Credentials(), region)
+	if err != nil {
+		fatalf("Failed to initialize parks: %v", err)
+	}
+
+	// Positions are replayed against the same land mask as the server uses,
+	// without fetching one
+	landConfig, err := services.LoadLandMaskConfig()
+	if err != nil {
+		fatalf("Invalid land mask configuration: %v", err)
+	}
+	landConfig.AutoFetch = false
+	landMask, err := services.LoadLandMask(context.Background(), landConfig, parks.ExpectedRegion())
+	if err != nil {
+		fatalf("Failed to load the land mask: %v", err)
+	}
+	parks.SetLandMask(landMask)
+
+	currentsConfig, err := services.LoadCurrentsConfig()
+	if err != nil {
+		fatalf("Invalid tidal currents configuration: %v", err)
+	}
+	currents, err := services.LoadTidalCurrents(currentsConfig)
+	if err != nil {
+		fatalf("Failed to load tidal currents: %v", err)
+	}
+	parks.SetTidalCurrents(currents)
+
+	dedupConfig, err := services.LoadPositionDedupConfig()
+	if err != nil {
+		fatalf("Invalid position deduplication configuration: %v", err)
+	}
+	vesselRepo := services.NewVesselRepository(dedupConfig)
+
+	replayer := services.NewReplayer(
+		parks,
+		vesselRepo,
+		services.NewZoneEventService(),
+		services.NewArrivalService(),
+		services.NewAnchoringDetector(vesselRepo, services.DefaultAnchoringConfig()),
+	)
+
+	if *dryRun {
+		responses, err := replayer.Responses(since)
+		if err != nil {
+			fatalf("Failed to load provider responses: %v", err)
+		}
+		rows, err := replayer.DerivedRows(since)
+		if err != nil {
+			fatalf("Failed to count derived rows: %v", err)
+		}
+
+		runs := make(map[string]bool)
+		for _, response := range responses {
+			runs[response.FetchRunID] = true
+		}
+		fmt.Printf("%d responses from %d fetch runs recorded since %s\n", len(responses), len(runs), since.Format(time.RFC3339))
+		if len(responses) > 0 {
+			fmt.Printf("first recorded %s, last %s\n", responses[0].RecordedAt.Format(time.RFC3339), responses[len(responses)-1].RecordedAt.Format(time.RFC3339))
+		}
+		printTables("rows to replace", rows)
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	start := time.Now()
+	result, err := replayer.Replay(ctx, since)
+	if result != nil {
+		printTables("deleted", result.Deleted)
+		fmt.Printf("reopened %d anchoring events\n", result.Reopened)
+		fmt.Printf("replayed %d responses from %d fetch runs (%d skipped)\n", result.Responses, result.Runs, result.Skipped)
+		fmt.Printf("stored %d positions (%d duplicates skipped), %d zone events, %d arrivals\n", result.Stored, result.Duplicates, result.ZoneEvents, result.Arrivals)
+	}
+	if err != nil && result != nil {
+		fatalf("Replay failed part way, run it again to start over: %v", err)
+	}
+	if err != nil {
+		fatalf("Replay failed: %v", err)
+	}
+	fmt.Printf("done in %s\n", time.Since(start).Round(time.Millisecond))
+}
+
+func printTables(label string, counts map[string]int64) {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Printf("%s:\n", label)
+	for _, name := range names {
+		fmt.Printf("  %-24s %d\n", name, counts[name])
+	}
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,83 @@
+// Command restorearchive loads a retention archive back into the database.
+//
+// Archives are the gzip-compressed NDJSON files the daily cleanup writes to
+// ARCHIVE_DIR or ARCHIVE_S3_BUCKET before deleting expired rows, named
+// <table>/<table>-<timestamp>.ndjson.gz. The table is taken from the file
+// name unless -table is given. Rows that are already present are skipped, so
+// an archive can be restored more than once. The database is selected with
+// the same DB_* environment variables (and .env file) as the server.
+//
+// Run it from the backend directory:
+//
+//	go run ./cmd/restorearchive archives/access_logs/access_logs-20260101T020000Z.ndjson.gz
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"vessel-tracker/database"
+	"vessel-tracker/services"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	table := flag.String("table", "", "table to restore into (default: taken from the archive file name)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: restorearchive [-table name] archive.ndjson.gz...\n\nTables: %s\n\n", strings.Join(services.RetentionTables(), ", "))
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	godotenv.Load()
+
+	dbConfig, err := database.LoadConfig()
+	if err != nil {
+		fatalf("Invalid database configuration: %v", err)
+	}
+	if err := database.InitDatabase(dbConfig); err != nil {
+		fatalf("Failed to initialize database: %v", err)
+	}
+
+	for _, path := range flag.Args() {
+		name := *table
+		if name == "" {
+			name = tableFromFileName(path)
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			fatalf("Failed to open archive: %v", err)
+		}
+
+		restored, err := services.RestoreArchive(database.GetDB(), name, file)
+		file.Close()
+		if err != nil {
+			fatalf("Failed to restore %s: %v", path, err)
+		}
+
+		fmt.Printf("%s: restored %d rows into %s\n", path, restored, name)
+	}
+}
+
+// tableFromFileName strips the timestamp and extension from an archive name
+func tableFromFileName(path string) string {
+	base := strings.TrimSuffix(filepath.Base(path), ".ndjson.gz")
+	if i := strings.LastIndex(base, "-"); i > 0 {
+		return base[:i]
+	}
+	return base
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,241 @@
+// Package config loads and validates every setting of the server from the
+// environment at startup, so a misconfigured deployment fails with all of
+// its problems listed instead of falling back to defaults one at a time.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"vessel-tracker/database"
+	"vessel-tracker/logging"
+	"vessel-tracker/middleware"
+	"vessel-tracker/services"
+)
+
+// ServerConfig holds the settings of the HTTP server
+type ServerConfig struct {
+	Port            int
+	ShutdownTimeout time.Duration // how long requests in flight may take to finish
+	TrustedProxies  []string      // addresses and CIDR ranges whose X-Forwarded-For is believed
+}
+
+func DefaultServerConfig() ServerConfig {
+	return ServerConfig{
+		Port:            8080,
+		ShutdownTimeout: 30 * time.Second,
+	}
+}
+
+// LoadServerConfig reads PORT, SHUTDOWN_TIMEOUT and TRUSTED_PROXIES, a
+// comma-separated list of the reverse proxies in front of the server. Without
+// it no proxy is trusted and clients are identified by their own address.
+func LoadServerConfig() (ServerConfig, error) {
+	config := DefaultServerConfig()
+
+	if value := os.Getenv("PORT"); value != "" {
+		port, err := strconv.Atoi(value)
+		if err != nil || port < 1 || port > 65535 {
+			return config, fmt.Errorf("invalid PORT %q: must be a port number", value)
+		}
+		config.Port = port
+	}
+
+	if value := os.Getenv("SHUTDOWN_TIMEOUT"); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout <= 0 {
+			return config, fmt.Errorf("invalid SHUTDOWN_TIMEOUT %q: must be a positive duration", value)
+		}
+		config.ShutdownTimeout = timeout
+	}
+
+	if value := os.Getenv("TRUSTED_PROXIES"); value != "" {
+		for _, proxy := range strings.Split(value, ",") {
+			proxy = strings.TrimSpace(proxy)
+			if proxy == "" {
+				continue
+			}
+			if net.ParseIP(proxy) == nil {
+				if _, _, err := net.ParseCIDR(proxy); err != nil {
+					return config, fmt.Errorf("invalid TRUSTED_PROXIES %q: %q is not an IP address or CIDR range", value, proxy)
+				}
+			}
+			config.TrustedProxies = append(config.TrustedProxies, proxy)
+		}
+	}
+
+	return config, nil
+}
+
+// Config holds every setting of the server. Fields tagged secret are
+// redacted by Redacted.
+type Config struct {
+	Server   ServerConfig
+	Logging  logging.Config
+	Database database.Config
+	Auth     middleware.AuthConfig
+	Sessions services.SessionConfig
+
+	Provider        services.ProviderConfig
+	ProviderAudit   bool
+	DatalasticQuota *services.DatalasticQuotaConfig // with the Datalastic provider only
+	Scheduler       services.SchedulerConfig
+	AISReceiver     *services.AISReceiverConfig // when positions are received over AIS only
+	Dedup           services.PositionDedupConfig
+	Faults          services.FaultConfig
+
+	DeploymentRegion string // region this deployment and its database run in, empty when unset
+	Parks            []services.ParkConfig
+	Boundaries       services.BoundaryConfig
+	Rules            services.RulesConfig
+	PosidoniaFile    string
+	Tiles            services.TileConfig
+	HabitatDir       string
+	LandMask         services.LandMaskConfig
+	Currents         services.CurrentsConfig
+	Weather          services.WeatherConfig
+	Calendar         services.CalendarConfig
+	Emissions        services.EmissionConfig
+
+	Shadow          services.ShadowConfig
+	AnchorDrag      services.AnchorDragConfig
+	Watchlist       services.WatchlistConfig
+	WhitelistSeed   string // seed file of the whitelist, empty when none
+	Notices         services.NoticeConfig
+	Trajectory      services.TrajectoryConfig
+	PositionPrivacy services.PositionPrivacyConfig
+	Notifications   services.NotificationConfig
+	LoginGuard      services.LoginGuardConfig
+	RateLimit       services.RateLimitConfig
+
+	Retention services.RetentionConfig
+	Archive   services.ArchiveConfig
+	LogStore  services.LogStoreConfig
+
+	Maintenance  bool // whether the server starts in maintenance mode
+	Probe        services.ProbeConfig
+	IndexAdvisor services.IndexAdvisorConfig
+	APIUsage     services.APIUsageConfig
+	Health       services.HealthConfig
+
+	PaymentWebhookSecret string `secret:"true"` // empty disables the payment webhook
+}
+
+// Load reads and validates every setting. Its error lists each invalid
+// setting, one per line, so they can all be fixed before the next start.
+func Load() (Config, error) {
+	var config Config
+	var errs []error
+
+	// load records why a setting is invalid, under the name of its section
+	load := func(section string, err error) {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", section, err))
+		}
+	}
+
+	var err error
+	config.Server, err = LoadServerConfig()
+	load("server", err)
+	config.Logging, err = logging.LoadConfig()
+	load("logging", err)
+	config.Database, err = database.LoadConfig()
+	load("database", err)
+	config.Auth, err = middleware.LoadAuthConfig()
+	load("auth", err)
+	config.Sessions, err = services.LoadSessionConfig()
+	load("sessions", err)
+
+	config.Provider, err = services.LoadProviderConfig()
+	load("provider", err)
+	config.ProviderAudit, err = services.LoadProviderAuditMode()
+	load("provider audit", err)
+	if err == nil && config.ProviderAudit && config.Provider.Name != services.ProviderDatalastic {
+		load("provider audit", fmt.Errorf("PROVIDER_AUDIT requires VESSEL_PROVIDER=datalastic"))
+	}
+	if config.Provider.Name == services.ProviderDatalastic {
+		quota, err := services.LoadDatalasticQuotaConfig()
+		load("datalastic quota", err)
+		config.DatalasticQuota = &quota
+	}
+	config.Scheduler, err = services.LoadSchedulerConfig()
+	load("scheduler", err)
+	if config.Scheduler.Source == services.DataSourceAIS {
+		receiver, err := services.LoadAISReceiverConfig()
+		load("AIS receiver", err)
+		config.AISReceiver = &receiver
+	}
+	config.Dedup, err = services.LoadPositionDedupConfig()
+	load("position deduplication", err)
+	config.Faults, err = services.LoadFaultConfig()
+	load("fault injection", err)
+
+	config.DeploymentRegion, err = services.LoadDeploymentRegion()
+	load("deployment region", err)
+	config.Parks, err = services.LoadParkConfigs()
+	load("parks", err)
+	config.Boundaries, err = services.LoadBoundaryConfig()
+	load("boundaries", err)
+	config.Rules, err = services.LoadRulesConfig()
+	load("zone rules", err)
+	config.PosidoniaFile = services.PosidoniaPath()
+	config.Tiles, err = services.LoadTileConfig()
+	load("vector tiles", err)
+	config.HabitatDir = services.HabitatLayersDir()
+	config.LandMask, err = services.LoadLandMaskConfig()
+	load("land mask", err)
+	config.Currents, err = services.LoadCurrentsConfig()
+	load("tidal currents", err)
+	config.Weather, err = services.LoadWeatherConfig()
+	load("weather", err)
+	config.Calendar, err = services.LoadCalendarConfig()
+	load("peak calendar", err)
+	config.Emissions, err = services.LoadEmissionConfig()
+	load("emission factors", err)
+
+	config.Shadow, err = services.LoadShadowConfig(services.DefaultAnchoringConfig())
+	load("shadow mode", err)
+	config.AnchorDrag, err = services.LoadAnchorDragConfig()
+	load("anchor drag", err)
+	config.Watchlist, err = services.LoadWatchlistConfig()
+	load("watchlist", err)
+	config.WhitelistSeed, err = services.LoadWhitelistSeedFile()
+	load("whitelist seed", err)
+	config.Notices = services.LoadNoticeConfig()
+	config.Trajectory, err = services.LoadTrajectoryConfig()
+	load("projection", err)
+	config.PositionPrivacy, err = services.LoadPositionPrivacyConfig()
+	load("position privacy", err)
+	config.Notifications, err = services.LoadNotificationConfig()
+	load("notifications", err)
+	config.LoginGuard, err = services.LoadLoginGuardConfig()
+	load("login guard", err)
+	config.RateLimit, err = services.LoadRateLimitConfig()
+	load("rate limit", err)
+
+	config.Retention, err = services.LoadRetentionConfig(config.Scheduler.RetentionDays)
+	load("retention", err)
+	config.Archive, err = services.LoadArchiveConfig(config.DeploymentRegion)
+	load("archive", err)
+	config.LogStore, err = services.LoadLogStoreConfig()
+	load("log store", err)
+
+	config.Maintenance, err = services.LoadMaintenanceMode()
+	load("maintenance", err)
+	config.Probe, err = services.LoadProbeConfig()
+	load("probe", err)
+	config.IndexAdvisor, err = services.LoadIndexAdvisorConfig()
+	load("index advisor", err)
+	config.APIUsage, err = services.LoadAPIUsageConfig()
+	load("API usage", err)
+	config.Health, err = services.LoadHealthConfig()
+	load("health checks", err)
+
+	config.PaymentWebhookSecret = os.Getenv("PAYMENT_WEBHOOK_SECRET")
+
+	return config, errors.Join(errs...)
+}
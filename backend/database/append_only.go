@@ -0,0 +1,39 @@
+package database
+
+import "fmt"
+
+// appendOnlyTables are audit trails whose rows may be inserted but never
+// updated or deleted, whichever code path or SQL session tries
+var appendOnlyTables = []string{"whitelist_audit"}
+
+// protectAppendOnlyTables installs triggers rejecting UPDATE and DELETE on
+// the append-only tables
+func protectAppendOnlyTables() error {
+	for _, table := range appendOnlyTables {
+		var statements []string
+		switch DB.Dialector.Name() {
+		case "postgres":
+			statements = []string{
+				fmt.Sprintf(`CREATE OR REPLACE FUNCTION %[1]s_append_only() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION '%[1]s is append-only';
+END;
+$$ LANGUAGE plpgsql`, table),
+				fmt.Sprintf("DROP TRIGGER IF EXISTS %[1]s_append_only ON %[1]s", table),
+				fmt.Sprintf("CREATE TRIGGER %[1]s_append_only BEFORE UPDATE OR DELETE ON %[1]s FOR EACH ROW EXECUTE FUNCTION %[1]s_append_only()", table),
+			}
+		case "sqlite":
+			statements = []string{
+				fmt.Sprintf("CREATE TRIGGER IF NOT EXISTS %[1]s_no_update BEFORE UPDATE ON %[1]s BEGIN SELECT RAISE(ABORT, '%[1]s is append-only'); END", table),
+				fmt.Sprintf("CREATE TRIGGER IF NOT EXISTS %[1]s_no_delete BEFORE DELETE ON %[1]s BEGIN SELECT RAISE(ABORT, '%[1]s is append-only'); END", table),
+			}
+		}
+
+		for _, statement := range statements {
+			if err := DB.Exec(statement).Error; err != nil {
+				return fmt.Errorf("failed to make %s append-only: %w", table, err)
+			}
+		}
+	}
+	return nil
+}
@@ -2,8 +2,10 @@ package database
 
 import (
 	"fmt"
-	"log"
 	"os"
+	"strconv"
+	"time"
+	"vessel-tracker/logging"
 	"vessel-tracker/models"
 
 	"gorm.io/driver/postgres"
@@ -13,66 +15,243 @@ import (
 
 var DB *gorm.DB
 
-func InitDatabase() error {
-	host := os.Getenv("DB_HOST")
-	if host == "" {
-		host = "localhost"
+// postgresDSN is kept for the dedicated LISTEN connection of Listen
+var postgresDSN string
+
+// Config selects the database and how it is connected to and migrated
+type Config struct {
+	Driver   string // "postgres" or "sqlite"
+	Host     string
+	Port     int
+	User     string
+	Password string `secret:"true"`
+	Name     string
+	SSLMode  string
+	Path     string // SQLite database file
+	LogLevel string // SQL statements logged: info, warn, error or silent
+
+	Timescale              bool          // store positions in a TimescaleDB hypertable when available
+	TimescaleCompressAfter time.Duration // 0 leaves position chunks uncompressed
+
+	ConnectRetries  int           // attempts after the first while the database is unreachable
+	ConnectBackoff  time.Duration // wait before the first retry, doubled after each
+	MaxOpenConns    int           // PostgreSQL pool only; SQLite keeps one connection
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
+
+// LoadConfig reads DB_DRIVER ("postgres", the default, or "sqlite" for small
+// deployments), DB_HOST, DB_PORT, DB_USER, DB_PASSWORD, DB_NAME and
+// DB_SSLMODE for PostgreSQL, DB_PATH for SQLite, DB_LOG_LEVEL, DB_TIMESCALE,
+// DB_TIMESCALE_COMPRESS_AFTER and the connection retry and pool settings,
+// falling back to the defaults for unset variables
+func LoadConfig() (Config, error) {
+	config := Config{
+		Driver:                 "postgres",
+		Host:                   "localhost",
+		Port:                   5432,
+		User:                   "postgres",
+		Password:               "postgres",
+		Name:                   "vessel_tracker",
+		SSLMode:                "disable",
+		Path:                   "vessel_tracker.db",
+		LogLevel:               "info",
+		TimescaleCompressAfter: defaultCompressAfter,
+		ConnectRetries:         5,
+		ConnectBackoff:         time.Second,
+		MaxOpenConns:           25,
+		MaxIdleConns:           5,
+		ConnMaxLifetime:        30 * time.Minute,
 	}
 
-	port := os.Getenv("DB_PORT")
-	if port == "" {
-		port = "5432"
+	stringVars := map[string]*string{
+		"DB_DRIVER":    &config.Driver,
+		"DB_HOST":      &config.Host,
+		"DB_USER":      &config.User,
+		"DB_PASSWORD":  &config.Password,
+		"DB_NAME":      &config.Name,
+		"DB_SSLMODE":   &config.SSLMode,
+		"DB_PATH":      &config.Path,
+		"DB_LOG_LEVEL": &config.LogLevel,
+	}
+	for name, target := range stringVars {
+		if value := os.Getenv(name); value != "" {
+			*target = value
+		}
 	}
 
-	user := os.Getenv("DB_USER")
-	if user == "" {
-		user = "postgres"
+	if config.Driver != "postgres" && config.Driver != "sqlite" {
+		return config, fmt.Errorf("unsupported DB_DRIVER %q (expected postgres or sqlite)", config.Driver)
+	}
+	if _, err := parseLogLevel(config.LogLevel); err != nil {
+		return config, err
 	}
 
-	password := os.Getenv("DB_PASSWORD")
-	if password == "" {
-		password = "postgres"
+	if value := os.Getenv("DB_PORT"); value != "" {
+		port, err := strconv.Atoi(value)
+		if err != nil || port < 1 || port > 65535 {
+			return config, fmt.Errorf("invalid DB_PORT %q: must be a port number", value)
+		}
+		config.Port = port
 	}
 
-	dbname := os.Getenv("DB_NAME")
-	if dbname == "" {
-		dbname = "vessel_tracker"
+	if err := loadTimescaleConfig(&config); err != nil {
+		return config, err
+	}
+	if err := loadPoolConfig(&config); err != nil {
+		return config, err
 	}
 
-	sslmode := os.Getenv("DB_SSLMODE")
-	if sslmode == "" {
-		sslmode = "disable"
+	return config, nil
+}
+
+// parseLogLevel reads DB_LOG_LEVEL; SQL statements are logged by default
+func parseLogLevel(value string) (logger.LogLevel, error) {
+	switch value {
+	case "", "info":
+		return logger.Info, nil
+	case "warn":
+		return logger.Warn, nil
+	case "error":
+		return logger.Error, nil
+	case "silent":
+		return logger.Silent, nil
+	default:
+		return 0, fmt.Errorf("unsupported DB_LOG_LEVEL %q (expected info, warn, error or silent)", value)
+	}
+}
+
+// InitDatabase connects to the configured database and runs migrations
+func InitDatabase(config Config) error {
+	var dialector gorm.Dialector
+	var err error
+
+	switch config.Driver {
+	case "postgres":
+		dialector = openPostgres(config)
+	case "sqlite":
+		dialector, err = openSQLite(config.Path)
+		if err != nil {
+			return fmt.Errorf("failed to open SQLite database: %w", err)
+		}
+	default:
+		return fmt.Errorf("unsupported DB_DRIVER %q (expected postgres or sqlite)", config.Driver)
 	}
 
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s TimeZone=UTC",
-		host, user, password, dbname, port, sslmode)
+	logLevel, err := parseLogLevel(config.LogLevel)
+	if err != nil {
+		return err
+	}
+	dbLogger := logging.Component("database")
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
+	db, err := openWithRetry(dbLogger, config, func() (*gorm.DB, error) {
+		return gorm.Open(dialector, &gorm.Config{
+			Logger: logger.NewSlogLogger(dbLogger, logger.Config{
+				SlowThreshold: 200 * time.Millisecond,
+				LogLevel:      logLevel,
+			}),
+			NowFunc: func() time.Time {
+				return time.Now().UTC()
+			},
+		})
 	})
 
 	if err != nil {
 		return fmt.Errorf("failed to connect to database: %w", err)
 	}
+	if config.Driver == "postgres" {
+		if err := configurePool(db, config); err != nil {
+			return err
+		}
+	}
 
 	DB = db
-	log.Println("Connected to PostgreSQL database")
+	dbLogger.Info("Connected to database", "driver", db.Dialector.Name())
 
 	// Run migrations
 	err = DB.AutoMigrate(
+		&models.Park{},
 		&models.VesselRecord{},
 		&models.VesselPositionRecord{},
 		&models.WhitelistEntry{},
+		&models.WhitelistAudit{},
+		&models.WatchlistEntry{},
+		&models.Operator{},
+		&models.OperatorContact{},
+		&models.Violation{},
+		&models.ViolationStatusChange{},
+		&models.AnchoringEvent{},
+		&models.ViolationAppeal{},
+		&models.Sanction{},
+		&models.AccessLog{},
+		&models.Session{},
+		&models.RevokedToken{},
+		&models.SecurityEvent{},
+		&models.ShadowDivergence{},
+		&models.ShadowTally{},
+		&models.ParkArrival{},
+		&models.ProviderResponse{},
+		&models.ZoneEvent{},
+		&models.ProviderCreditUsage{},
+		&models.HabitatLayer{},
+		&models.APIUsage{},
+		&models.NotificationDelivery{},
+		&models.NotificationSuppression{},
+		&models.APIKey{},
+		&models.DailyParkAggregate{},
+		&models.DailyParkEmission{},
+		&models.LogEntry{},
+		&models.WeatherObservation{},
 	)
 
 	if err != nil {
 		return fmt.Errorf("failed to run migrations: %w", err)
 	}
 
-	log.Println("Database migration completed")
+	if err := protectAppendOnlyTables(); err != nil {
+		return err
+	}
+
+	dbLogger.Info("Database migration completed")
+
+	enableTrigramSearch(dbLogger)
+	enableTimescale(dbLogger, config)
 	return nil
 }
 
+func openPostgres(config Config) gorm.Dialector {
+	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=%s TimeZone=UTC",
+		config.Host, config.User, config.Password, config.Name, config.Port, config.SSLMode)
+	postgresDSN = dsn
+
+	return postgres.Open(dsn)
+}
+
 func GetDB() *gorm.DB {
 	return DB
-}
\ No newline at end of file
+}
+
+// Close closes the connection pool once in-flight queries have finished
+func Close() error {
+	if DB == nil {
+		return nil
+	}
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+// Size returns the disk space taken by the database in bytes
+func Size() (int64, error) {
+	var size int64
+	query := "SELECT pg_database_size(current_database())"
+	if DB.Dialector.Name() == "sqlite" {
+		query = "SELECT page_count * page_size FROM pragma_page_count(), pragma_page_size()"
+	}
+	if err := DB.Raw(query).Scan(&size).Error; err != nil {
+		return 0, fmt.Errorf("failed to measure database size: %w", err)
+	}
+	return size, nil
+}
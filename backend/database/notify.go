@@ -0,0 +1,85 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"vessel-tracker/logging"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// listenRetryDelay is how long Listen waits before reconnecting after its
+// connection fails
+const listenRetryDelay = 5 * time.Second
+
+// NotificationsSupported reports whether Notify reaches other instances. Only
+// PostgreSQL has a notification channel; a SQLite database is used by a
+// single instance anyway.
+func NotificationsSupported() bool {
+	return DB != nil && DB.Dialector.Name() == "postgres"
+}
+
+// Notify sends payload to every instance listening on channel. It does
+// nothing without PostgreSQL.
+func Notify(channel, payload string) error {
+	if !NotificationsSupported() {
+		return nil
+	}
+	if err := DB.Exec("SELECT pg_notify(?, ?)", channel, payload).Error; err != nil {
+		return fmt.Errorf("failed to notify %s: %w", channel, err)
+	}
+	return nil
+}
+
+// Listen calls handle with the payload of every notification on channel until
+// ctx is cancelled. It holds its own connection, reconnecting after failures,
+// and calls onConnect each time LISTEN is (re)established so callers can
+// catch up on notifications missed while disconnected. It returns false
+// without PostgreSQL.
+func Listen(ctx context.Context, channel string, onConnect func(), handle func(payload string)) bool {
+	if !NotificationsSupported() {
+		return false
+	}
+
+	logger := logging.Component("database").With("channel", channel)
+
+	go func() {
+		for {
+			err := listen(ctx, channel, onConnect, handle)
+			if ctx.Err() != nil {
+				return
+			}
+			logger.Warn("Notification listener disconnected, reconnecting", "error", err, "retry_in", listenRetryDelay.String())
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(listenRetryDelay):
+			}
+		}
+	}()
+
+	return true
+}
+
+func listen(ctx context.Context, channel string, onConnect func(), handle func(payload string)) error {
+	conn, err := pgx.Connect(ctx, postgresDSN)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+		return err
+	}
+	onConnect()
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		handle(notification.Payload)
+	}
+}
@@ -0,0 +1,87 @@
+package database
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// maxConnectBackoff caps the doubling wait between connection attempts
+const maxConnectBackoff = 30 * time.Second
+
+// loadPoolConfig reads DB_CONNECT_RETRIES, DB_CONNECT_BACKOFF,
+// DB_MAX_OPEN_CONNS, DB_MAX_IDLE_CONNS and DB_CONN_MAX_LIFETIME
+func loadPoolConfig(config *Config) error {
+	intVars := map[string]*int{
+		"DB_CONNECT_RETRIES": &config.ConnectRetries,
+		"DB_MAX_OPEN_CONNS":  &config.MaxOpenConns,
+		"DB_MAX_IDLE_CONNS":  &config.MaxIdleConns,
+	}
+	for name, target := range intVars {
+		if value := os.Getenv(name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return fmt.Errorf("invalid %s %q: must be a non-negative integer", name, value)
+			}
+			*target = n
+		}
+	}
+
+	durationVars := map[string]*time.Duration{
+		"DB_CONNECT_BACKOFF":   &config.ConnectBackoff,
+		"DB_CONN_MAX_LIFETIME": &config.ConnMaxLifetime,
+	}
+	for name, target := range durationVars {
+		if value := os.Getenv(name); value != "" {
+			d, err := time.ParseDuration(value)
+			if err != nil || d < 0 {
+				return fmt.Errorf("invalid %s %q: must be a non-negative duration", name, value)
+			}
+			*target = d
+		}
+	}
+
+	if config.MaxOpenConns > 0 && config.MaxIdleConns > config.MaxOpenConns {
+		return fmt.Errorf("DB_MAX_IDLE_CONNS (%d) exceeds DB_MAX_OPEN_CONNS (%d)", config.MaxIdleConns, config.MaxOpenConns)
+	}
+
+	return nil
+}
+
+// openWithRetry opens the database, retrying up to config.ConnectRetries
+// times while it is unreachable, as when PostgreSQL is still starting next
+// to the server. The wait doubles after each attempt up to maxConnectBackoff.
+func openWithRetry(logger *slog.Logger, config Config, open func() (*gorm.DB, error)) (*gorm.DB, error) {
+	backoff := config.ConnectBackoff
+	for attempt := 0; ; attempt++ {
+		db, err := open()
+		if err == nil {
+			return db, nil
+		}
+		if attempt >= config.ConnectRetries {
+			return nil, err
+		}
+
+		logger.Warn("Database unreachable, retrying",
+			"attempt", attempt+1, "retries", config.ConnectRetries, "wait", backoff, "error", err)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, maxConnectBackoff)
+	}
+}
+
+// configurePool applies the PostgreSQL connection pool limits. Zero lifts the
+// limit on open connections and their lifetime but keeps no idle connections.
+func configurePool(db *gorm.DB, config Config) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return fmt.Errorf("failed to configure the connection pool: %w", err)
+	}
+	sqlDB.SetMaxOpenConns(config.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(config.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(config.ConnMaxLifetime)
+	return nil
+}
@@ -0,0 +1,38 @@
+package database
+
+import "log/slog"
+
+// trigramSearch is set once pg_trgm is installed and vessel names are indexed
+// for similarity matching
+var trigramSearch bool
+
+// TrigramSearchSupported reports whether vessel names can be matched and
+// ranked by trigram similarity. Only PostgreSQL with the pg_trgm extension
+// can; elsewhere names are matched by substring only.
+func TrigramSearchSupported() bool {
+	return trigramSearch
+}
+
+// enableTrigramSearch installs pg_trgm and indexes vessel names with it. A
+// database user without the privilege to create the extension keeps
+// substring matching, which needs no index but scans every vessel.
+func enableTrigramSearch(logger *slog.Logger) {
+	if DB.Dialector.Name() != "postgres" {
+		return
+	}
+
+	if err := DB.Exec("CREATE EXTENSION IF NOT EXISTS pg_trgm").Error; err != nil {
+		logger.Warn("pg_trgm unavailable, vessel search matches names by substring only", "error", err)
+		return
+	}
+
+	for _, column := range []string{"name", "name_ais"} {
+		err := DB.Exec("CREATE INDEX IF NOT EXISTS idx_vessel_records_" + column + "_trgm ON vessel_records USING gin (" + column + " gin_trgm_ops)").Error
+		if err != nil {
+			logger.Warn("Failed to index vessel names for search", "column", column, "error", err)
+			return
+		}
+	}
+
+	trigramSearch = true
+}
@@ -0,0 +1,181 @@
+package database
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+)
+
+// The hourly rollup of positions kept as a TimescaleDB continuous aggregate.
+// Each row counts the positions of a vessel in one hour and one cell of
+// PositionRollupCellDegrees, with columns bucket, park_id, vessel_uuid,
+// cell_row, cell_col (the floor of latitude and longitude over the cell size),
+// positions and in_park.
+const (
+	PositionRollupView        = "vessel_position_hourly"
+	PositionRollupBucket      = time.Hour
+	PositionRollupCellDegrees = 0.0005
+)
+
+// defaultCompressAfter is the age at which position chunks are compressed
+const defaultCompressAfter = 30 * 24 * time.Hour
+
+// timescale is set once positions are stored in a hypertable and rolled up
+var timescale bool
+
+// TimescaleEnabled reports whether positions are stored in a TimescaleDB
+// hypertable and rolled up hourly into PositionRollupView. It is decided when
+// migrations run: DB_TIMESCALE must be set and the extension available.
+func TimescaleEnabled() bool {
+	return timescale
+}
+
+// loadTimescaleConfig reads DB_TIMESCALE and DB_TIMESCALE_COMPRESS_AFTER
+func loadTimescaleConfig(config *Config) error {
+	if value := os.Getenv("DB_TIMESCALE"); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid DB_TIMESCALE %q: %w", value, err)
+		}
+		config.Timescale = enabled
+	}
+
+	if value := os.Getenv("DB_TIMESCALE_COMPRESS_AFTER"); value != "" {
+		after, err := time.ParseDuration(value)
+		if err != nil || after < 0 {
+			return fmt.Errorf("invalid DB_TIMESCALE_COMPRESS_AFTER %q: must be a non-negative duration", value)
+		}
+		config.TimescaleCompressAfter = after
+	}
+
+	return nil
+}
+
+// enableTimescale turns vessel_position_records into a hypertable partitioned
+// by recorded_at, keeps the hourly rollup of positions and compresses old
+// chunks. Without PostgreSQL or the timescaledb extension positions stay in a
+// plain table and statistics are computed from them directly.
+func enableTimescale(logger *slog.Logger, config Config) {
+	if !config.Timescale {
+		return
+	}
+	if DB.Dialector.Name() != "postgres" {
+		logger.Warn("DB_TIMESCALE needs PostgreSQL, positions are kept in a plain table")
+		return
+	}
+
+	var available bool
+	if err := DB.Raw("SELECT EXISTS (SELECT 1 FROM pg_available_extensions WHERE name = 'timescaledb')").Scan(&available).Error; err != nil || !available {
+		logger.Warn("timescaledb unavailable, positions are kept in a plain table", "error", err)
+		return
+	}
+	if err := DB.Exec("CREATE EXTENSION IF NOT EXISTS timescaledb").Error; err != nil {
+		logger.Warn("timescaledb unavailable, positions are kept in a plain table", "error", err)
+		return
+	}
+
+	if err := createPositionHypertable(logger); err != nil {
+		logger.Error("Failed to convert positions to a hypertable", "error", err)
+		return
+	}
+	if err := createPositionRollup(logger); err != nil {
+		logger.Error("Failed to create the hourly position rollup", "error", err)
+		return
+	}
+	timescale = true
+
+	if err := setPositionCompression(config.TimescaleCompressAfter); err != nil {
+		logger.Warn("Failed to set the compression policy of positions", "error", err)
+	}
+	logger.Info("Positions stored in a TimescaleDB hypertable", "compress_after", config.TimescaleCompressAfter.String())
+}
+
+// createPositionHypertable converts the positions table, moving the rows it
+// already holds into chunks. A hypertable's unique keys must include its time
+// column, so the primary key becomes (id, recorded_at).
+func createPositionHypertable(logger *slog.Logger) error {
+	var exists bool
+	err := DB.Raw("SELECT EXISTS (SELECT 1 FROM timescaledb_information.hypertables WHERE hypertable_name = 'vessel_position_records')").Scan(&exists).Error
+	if err != nil || exists {
+		return err
+	}
+
+	logger.Info("Converting positions to a hypertable, this can take a while on a large table")
+	return DB.Exec(`
+		ALTER TABLE vessel_position_records DROP CONSTRAINT IF EXISTS vessel_position_records_pkey;
+		ALTER TABLE vessel_position_records ADD PRIMARY KEY (id, recorded_at);
+		SELECT create_hypertable('vessel_position_records', 'recorded_at',
+			chunk_time_interval => INTERVAL '7 days', migrate_data => true);
+	`).Error
+}
+
+// createPositionRollup creates the continuous aggregate behind occupancy and
+// heatmap statistics and fills it from the positions already stored. The
+// rollup is refreshed every half hour over the last three days; hours not yet
+// materialized are computed from the positions when queried, and hours whose
+// positions the retention job has since deleted are kept.
+func createPositionRollup(logger *slog.Logger) error {
+	var exists bool
+	err := DB.Raw("SELECT EXISTS (SELECT 1 FROM timescaledb_information.continuous_aggregates WHERE view_name = ?)", PositionRollupView).Scan(&exists).Error
+	if err != nil || exists {
+		return err
+	}
+
+	cell := strconv.FormatFloat(PositionRollupCellDegrees, 'f', -1, 64)
+	err = DB.Exec(`
+		CREATE MATERIALIZED VIEW ` + PositionRollupView + `
+		WITH (timescaledb.continuous, timescaledb.materialized_only = false) AS
+		SELECT time_bucket(INTERVAL '1 hour', recorded_at) AS bucket,
+			park_id,
+			vessel_uuid,
+			FLOOR(latitude / ` + cell + `)::bigint AS cell_row,
+			FLOOR(longitude / ` + cell + `)::bigint AS cell_col,
+			COUNT(*) AS positions,
+			SUM(CASE WHEN is_in_park THEN 1 ELSE 0 END) AS in_park
+		FROM vessel_position_records
+		GROUP BY bucket, park_id, vessel_uuid, cell_row, cell_col
+		WITH NO DATA`).Error
+	if err != nil {
+		return err
+	}
+
+	err = DB.Exec(`SELECT add_continuous_aggregate_policy('` + PositionRollupView + `',
+		start_offset => INTERVAL '3 days', end_offset => INTERVAL '1 hour',
+		schedule_interval => INTERVAL '30 minutes', if_not_exists => true)`).Error
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Rolling up stored positions by hour, this can take a while on a large table")
+	return DB.Exec("CALL refresh_continuous_aggregate('" + PositionRollupView + "', NULL, date_trunc('hour', now()))").Error
+}
+
+// setPositionCompression compresses the chunks of positions older than after,
+// segmented by park and vessel so a vessel's track stays cheap to read. An
+// after of zero removes the policy; chunks already compressed stay so.
+func setPositionCompression(after time.Duration) error {
+	if err := DB.Exec("SELECT remove_compression_policy('vessel_position_records', if_exists => true)").Error; err != nil {
+		return err
+	}
+	if after == 0 {
+		return nil
+	}
+
+	var enabled bool
+	err := DB.Raw("SELECT compression_enabled FROM timescaledb_information.hypertables WHERE hypertable_name = 'vessel_position_records'").Scan(&enabled).Error
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		err = DB.Exec(`ALTER TABLE vessel_position_records SET (timescaledb.compress,
+			timescaledb.compress_segmentby = 'park_id, vessel_uuid',
+			timescaledb.compress_orderby = 'recorded_at DESC')`).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return DB.Exec("SELECT add_compression_policy('vessel_position_records', compress_after => make_interval(secs => ?))", after.Seconds()).Error
+}
@@ -0,0 +1,12 @@
+// Package docs holds the OpenAPI description of the HTTP API and the Swagger
+// UI page that renders it. The spec is maintained by hand alongside the
+// routes in main.go.
+package docs
+
+import _ "embed"
+
+//go:embed openapi.yaml
+var OpenAPISpec []byte
+
+//go:embed swagger.html
+var SwaggerUI []byte
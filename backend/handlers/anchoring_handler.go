@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+	"vessel-tracker/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AnchoringHandler struct {
+	anchoringDetector *services.AnchoringDetector
+	parks             *services.ParkRegistry
+	scheduler         *services.SchedulerService
+}
+
+func NewAnchoringHandler(anchoringDetector *services.AnchoringDetector, parks *services.ParkRegistry, scheduler *services.SchedulerService) *AnchoringHandler {
+	return &AnchoringHandler{
+		anchoringDetector: anchoringDetector,
+		parks:             parks,
+		scheduler:         scheduler,
+	}
+}
+
+// GetAnchoringEvents lists the anchoring events of a park, optionally
+// filtered by vessel and active state
+func (h *AnchoringHandler) GetAnchoringEvents(c *gin.Context) {
+	park, ok := resolvePark(c, h.parks)
+	if !ok {
+		return
+	}
+
+	vesselUUID := c.Query("vessel_uuid")
+	activeOnly := c.Query("active") == "true"
+
+	limit := 100
+	if limitStr := c.Query("limit"); limitStr != "" {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid limit parameter",
+			})
+			return
+		}
+	}
+
+	events, err := h.anchoringDetector.GetEvents(c.Request.Context(), park.Record.ID, vesselUUID, activeOnly, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to fetch anchoring events",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"park":   park.Record.Slug,
+		"events": redact(c, events),
+		"count":  len(events),
+	})
+}
+
+// GetAnchoredVessels lists the vessels anchored in a park now, longest
+// anchored first, with the habitat under each. Vessels whose anchoring was
+// last confirmed more than max_age ago (1h by default) are left out.
+func (h *AnchoringHandler) GetAnchoredVessels(c *gin.Context) {
+	park, ok := resolvePark(c, h.parks)
+	if !ok {
+		return
+	}
+
+	maxAge := time.Hour
+	if value := c.Query("max_age"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid max_age, use a positive duration such as 30m",
+			})
+			return
+		}
+		maxAge = parsed
+	}
+
+	vessels, err := h.anchoringDetector.GetAnchoredVessels(c.Request.Context(), park, maxAge)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to fetch anchored vessels",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	config := h.anchoringDetector.Config()
+	c.JSON(http.StatusOK, gin.H{
+		"park": park.Record.Slug,
+		"thresholds": gin.H{
+			"max_speed_knots":  config.MaxSpeedKnots,
+			"max_drift_meters": config.MaxDriftMeters,
+			"min_positions":    config.MinPositions,
+		},
+		"max_age":    maxAge.String(),
+		"vessels":    redact(c, vessels),
+		"count":      len(vessels),
+		"data_as_of": h.scheduler.DataAsOf(c.Request.Context(), park),
+		"source":     services.ResponseSourceDatabase,
+	})
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+	"vessel-tracker/middleware"
+	"vessel-tracker/models"
+	"vessel-tracker/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type APIKeyHandler struct {
+	apiKeyService *services.APIKeyService
+}
+
+func NewAPIKeyHandler(apiKeyService *services.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeyService: apiKeyService,
+	}
+}
+
+type IssueAPIKeyRequest struct {
+	Name      string     `json:"name" binding:"required"`
+	Role      string     `json:"role"` // researcher when empty
+	Scopes    []string   `json:"scopes" binding:"required"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// List the issued API keys, with revoked ones when include_revoked is true
+func (h *APIKeyHandler) GetAPIKeys(c *gin.Context) {
+	keys, err := h.apiKeyService.GetKeys(c.Query("include_revoked") == "true")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to fetch API keys",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"api_keys": keys,
+		"count":    len(keys),
+	})
+}
+
+// Issue an API key for a machine client. The key is only shown in this
+// response.
+func (h *APIKeyHandler) IssueAPIKey(c *gin.Context) {
+	var req IssueAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	// Machine clients never get administrator access
+	if req.Role == "" {
+		req.Role = middleware.RoleResearcher
+	}
+	switch req.Role {
+	case middleware.RolePublic, middleware.RoleResearcher, middleware.RoleRanger:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid role, use public, researcher or ranger",
+		})
+		return
+	}
+
+	// Positions are pushed to a park staff endpoint
+	for _, scope := range req.Scopes {
+		if scope == models.APIKeyScopeIngest && middleware.RoleRank(req.Role) < middleware.RoleRank(middleware.RoleRanger) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "the ingest scope needs the ranger role",
+			})
+			return
+		}
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "expires_at must be in the future",
+		})
+		return
+	}
+
+	key, plain, err := h.apiKeyService.IssueKey(req.Name, req.Role, req.Scopes, req.ExpiresAt, middleware.GetActor(c))
+	if err != nil {
+		if errors.Is(err, services.ErrUnknownAPIKeyScope) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to issue API key",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "API key issued; store it now, it cannot be shown again",
+		"key":     plain,
+		"api_key": key,
+	})
+}
+
+// Revoke an API key
+func (h *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid API key id",
+		})
+		return
+	}
+
+	var req RevokeRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid request body",
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	key, err := h.apiKeyService.RevokeKey(uint(id), middleware.GetActor(c), req.Reason)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "API key not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to revoke API key",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "API key revoked",
+		"api_key": key,
+	})
+}
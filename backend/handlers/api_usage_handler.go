@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+	"vessel-tracker/middleware"
+	"vessel-tracker/models"
+	"vessel-tracker/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type APIUsageHandler struct {
+	tracker      *services.APIUsageTracker
+	deprecations map[string]middleware.Deprecation
+}
+
+func NewAPIUsageHandler(tracker *services.APIUsageTracker, deprecations []middleware.Deprecation) *APIUsageHandler {
+	byRoute := make(map[string]middleware.Deprecation, len(deprecations))
+	for _, deprecation := range deprecations {
+		byRoute[deprecation.Method+" "+deprecation.Path] = deprecation
+	}
+	return &APIUsageHandler{
+		tracker:      tracker,
+		deprecations: byRoute,
+	}
+}
+
+// apiUsageEntry is the usage of an endpoint by one API key, flagged when the
+// endpoint is deprecated
+type apiUsageEntry struct {
+	models.APIUsage
+	Deprecated bool       `json:"deprecated"`
+	Sunset     *time.Time `json:"sunset,omitempty"`
+}
+
+// GetAPIUsage lists request counts and last use per API key and endpoint,
+// with totals per key. It can be filtered by api_key, actor, route and
+// since; deprecated=true only lists calls to deprecated endpoints, the
+// integrations to contact before they are removed.
+func (h *APIUsageHandler) GetAPIUsage(c *gin.Context) {
+	filter := services.APIUsageFilter{
+		APIKey: c.Query("api_key"),
+		Actor:  c.Query("actor"),
+		Route:  c.Query("route"),
+	}
+
+	if since := c.Query("since"); since != "" {
+		parsed, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid since format, use RFC3339",
+			})
+			return
+		}
+		filter.Since = parsed
+	}
+
+	usage, err := h.tracker.GetUsage(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to fetch API usage",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	deprecatedOnly := c.Query("deprecated") == "true"
+	entries := make([]apiUsageEntry, 0, len(usage))
+	kept := make([]models.APIUsage, 0, len(usage))
+	for _, row := range usage {
+		deprecation, deprecated := h.deprecations[row.Method+" "+row.Route]
+		if deprecatedOnly && !deprecated {
+			continue
+		}
+		entry := apiUsageEntry{APIUsage: row, Deprecated: deprecated}
+		if deprecated {
+			entry.Sunset = deprecation.Sunset
+		}
+		entries = append(entries, entry)
+		kept = append(kept, row)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"keys":  services.SummarizeKeys(kept),
+		"usage": entries,
+		"count": len(entries),
+	})
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+	"vessel-tracker/models"
+	"vessel-tracker/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type AppealHandler struct {
+	appealService *services.AppealService
+}
+
+func NewAppealHandler(appealService *services.AppealService) *AppealHandler {
+	return &AppealHandler{
+		appealService: appealService,
+	}
+}
+
+// Get all appeals lodged against a violation
+func (h *AppealHandler) GetAppeals(c *gin.Context) {
+	violationID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid violation id",
+		})
+		return
+	}
+
+	appeals, err := h.appealService.GetAppeals(uint(violationID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to fetch appeals",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"violation_id": violationID,
+		"appeals":      appeals,
+		"count":        len(appeals),
+	})
+}
+
+// File an appeal against a violation
+func (h *AppealHandler) FileAppeal(c *gin.Context) {
+	violationID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid violation id",
+		})
+		return
+	}
+
+	var req struct {
+		FiledAt string `json:"filed_at"`
+		FiledBy string `json:"filed_by"`
+		Grounds string `json:"grounds"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if req.Grounds == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Grounds are required",
+		})
+		return
+	}
+
+	appeal := &models.ViolationAppeal{
+		ViolationID: uint(violationID),
+		FiledBy:     req.FiledBy,
+		Grounds:     req.Grounds,
+	}
+
+	if req.FiledAt != "" {
+		appeal.FiledAt, err = time.Parse(time.RFC3339, req.FiledAt)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid filed_at format, use RFC3339",
+			})
+			return
+		}
+	}
+
+	if err := h.appealService.FileAppeal(appeal); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Violation not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to file appeal",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, appeal)
+}
+
+// Move an appeal through its review states
+func (h *AppealHandler) UpdateAppeal(c *gin.Context) {
+	violationID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid violation id",
+		})
+		return
+	}
+
+	appealID, err := strconv.ParseUint(c.Param("appeal_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid appeal id",
+		})
+		return
+	}
+
+	var req struct {
+		Status    string `json:"status"`
+		Decision  string `json:"decision"`
+		Outcome   string `json:"outcome"`
+		DecidedBy string `json:"decided_by"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil || req.Status == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "status is required",
+		})
+		return
+	}
+
+	appeal, err := h.appealService.TransitionAppeal(uint(violationID), uint(appealID), req.Status, req.Decision, req.Outcome, req.DecidedBy)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Appeal not found",
+			})
+		case errors.Is(err, services.ErrInvalidTransition):
+			c.JSON(http.StatusConflict, gin.H{
+				"error": err.Error(),
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to update appeal",
+				"details": err.Error(),
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, appeal)
+}
+
+// Get appeal outcome statistics per violation type
+func (h *AppealHandler) GetAppealStats(c *gin.Context) {
+	stats, err := h.appealService.GetOutcomeStats()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to compute appeal statistics",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"stats": stats,
+		"count": len(stats),
+	})
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+	"vessel-tracker/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ArrivalHandler struct {
+	arrivalService *services.ArrivalService
+	parks          *services.ParkRegistry
+}
+
+func NewArrivalHandler(arrivalService *services.ArrivalService, parks *services.ParkRegistry) *ArrivalHandler {
+	return &ArrivalHandler{
+		arrivalService: arrivalService,
+		parks:          parks,
+	}
+}
+
+// GetArrivals lists the vessels seen in a park for the first time, newest
+// first, since the given time (the last 24 hours by default)
+func (h *ArrivalHandler) GetArrivals(c *gin.Context) {
+	park, ok := resolvePark(c, h.parks)
+	if !ok {
+		return
+	}
+
+	since := time.Now().Add(-24 * time.Hour)
+	if sinceStr := c.Query("since"); sinceStr != "" {
+		parsed, err := time.Parse(time.RFC3339, sinceStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid since format, use RFC3339",
+			})
+			return
+		}
+		since = parsed
+	}
+
+	limit := 100
+	if limitStr := c.Query("limit"); limitStr != "" {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid limit parameter",
+			})
+			return
+		}
+	}
+
+	arrivals, err := h.arrivalService.GetArrivals(c.Request.Context(), park.Record.ID, since, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to fetch arrivals",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"park":     park.Record.Slug,
+		"arrivals": redact(c, arrivals),
+		"count":    len(arrivals),
+	})
+}
+
+// GetArrivalDigest groups the first arrivals in a park by UTC day, over the
+// last 7 days by default
+func (h *ArrivalHandler) GetArrivalDigest(c *gin.Context) {
+	park, ok := resolvePark(c, h.parks)
+	if !ok {
+		return
+	}
+
+	start, end, ok := parseTimeRange(c, "start", "end", 7*24*time.Hour)
+	if !ok {
+		return
+	}
+	if end.Sub(start) > 366*24*time.Hour {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "the digest covers at most 366 days",
+		})
+		return
+	}
+
+	digest, err := h.arrivalService.Digest(park, start, end)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to build arrivals digest",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, redact(c, digest))
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+	"vessel-tracker/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuditHandler struct {
+	auditService *services.AuditService
+}
+
+func NewAuditHandler(auditService *services.AuditService) *AuditHandler {
+	return &AuditHandler{
+		auditService: auditService,
+	}
+}
+
+// GetAccessLogs lists reads of sensitive records, filterable by actor and record
+func (h *AuditHandler) GetAccessLogs(c *gin.Context) {
+	filter := services.AccessLogFilter{
+		Actor:      c.Query("actor"),
+		RecordType: c.Query("record_type"),
+		RecordID:   c.Query("record_id"),
+		Limit:      200,
+	}
+
+	if since := c.Query("since"); since != "" {
+		parsed, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid since format, use RFC3339",
+			})
+			return
+		}
+		filter.Since = parsed
+	}
+
+	if limitStr := c.Query("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid limit parameter",
+			})
+			return
+		}
+		filter.Limit = limit
+	}
+
+	logs, err := h.auditService.GetAccessLogs(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to fetch access logs",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"access_logs": logs,
+		"count":       len(logs),
+	})
+}
+
+// GetSecurityEvents lists authentication failures, lockouts and alerts
+func (h *AuditHandler) GetSecurityEvents(c *gin.Context) {
+	filter := services.SecurityEventFilter{
+		Type:       c.Query("type"),
+		ClientIP:   c.Query("client_ip"),
+		AlertsOnly: c.Query("alerts") == "true",
+		Limit:      200,
+	}
+
+	if since := c.Query("since"); since != "" {
+		parsed, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid since format, use RFC3339",
+			})
+			return
+		}
+		filter.Since = parsed
+	}
+
+	if limitStr := c.Query("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid limit parameter",
+			})
+			return
+		}
+		filter.Limit = limit
+	}
+
+	events, err := h.auditService.GetSecurityEvents(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to fetch security events",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"security_events": events,
+		"count":           len(events),
+	})
+}
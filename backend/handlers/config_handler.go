@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"net/http"
+	"vessel-tracker/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ConfigHandler struct {
+	config config.Config
+}
+
+func NewConfigHandler(cfg config.Config) *ConfigHandler {
+	return &ConfigHandler{
+		config: cfg,
+	}
+}
+
+// GetConfig returns the settings the server started with, secrets redacted
+func (h *ConfigHandler) GetConfig(c *gin.Context) {
+	c.JSON(http.StatusOK, h.config.Redacted())
+}
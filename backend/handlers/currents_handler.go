@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+	"vessel-tracker/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CurrentsHandler struct {
+	config services.CurrentsConfig
+	parks  *services.ParkRegistry
+}
+
+func NewCurrentsHandler(config services.CurrentsConfig, parks *services.ParkRegistry) *CurrentsHandler {
+	return &CurrentsHandler{
+		config: config,
+		parks:  parks,
+	}
+}
+
+// GetCurrents returns the tidal current predicted at every station at time
+// (now by default), and at lat/lon when both are given
+func (h *CurrentsHandler) GetCurrents(c *gin.Context) {
+	currents := h.parks.Default().Geo.TidalCurrents()
+	if currents == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "tidal currents not loaded",
+		})
+		return
+	}
+
+	at := time.Now()
+	if value := c.Query("time"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid time format, use RFC3339",
+			})
+			return
+		}
+		at = parsed
+	}
+
+	start, end := currents.Span()
+	response := gin.H{
+		"source":              currents.Source(),
+		"time":                at,
+		"start":               start,
+		"end":                 end,
+		"max_distance_meters": currents.MaxDistanceMeters(),
+		"updated_at":          currents.LastModified(),
+		"stations":            currents.StationsAt(at),
+	}
+
+	if c.Query("lat") != "" || c.Query("lon") != "" {
+		lat, lon, ok := parseLatLon(c)
+		if !ok {
+			return
+		}
+		response["position"] = gin.H{
+			"lat":     lat,
+			"lon":     lon,
+			"current": currents.At(lat, lon, at),
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ImportCurrents replaces the tidal current predictions with the JSON body
+// and applies them to positions fetched from now on
+func (h *CurrentsHandler) ImportCurrents(c *gin.Context) {
+	var predictions services.CurrentPredictions
+	if err := c.ShouldBindJSON(&predictions); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	currents, err := services.ImportTidalCurrents(h.config, predictions)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid tidal current predictions",
+			"details": err.Error(),
+		})
+		return
+	}
+	h.parks.SetTidalCurrents(currents)
+
+	start, end := currents.Span()
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Tidal current predictions imported successfully",
+		"source":   currents.Source(),
+		"stations": len(predictions.Stations),
+		"start":    start,
+		"end":      end,
+	})
+}
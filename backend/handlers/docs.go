@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"net/http"
+	"vessel-tracker/docs"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Serve the Swagger UI page
+func GetAPIDocs(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", docs.SwaggerUI)
+}
+
+// Serve the OpenAPI specification
+func GetOpenAPISpec(c *gin.Context) {
+	c.Data(http.StatusOK, "application/yaml", docs.OpenAPISpec)
+}
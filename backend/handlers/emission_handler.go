@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+	"vessel-tracker/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type EmissionHandler struct {
+	emissions *services.EmissionService
+	parks     *services.ParkRegistry
+}
+
+func NewEmissionHandler(emissions *services.EmissionService, parks *services.ParkRegistry) *EmissionHandler {
+	return &EmissionHandler{
+		emissions: emissions,
+		parks:     parks,
+	}
+}
+
+// GetEmissionReport estimates the fuel burn and emissions of vessels inside
+// a park per month, from `from` to `to` (YYYY-MM, inclusive), as JSON
+// (default) or CSV. Both default to the current month, with from at most
+// MaxEmissionMonths before to. CSV downloads are refused for parks with
+// restricted exports.
+func (h *EmissionHandler) GetEmissionReport(c *gin.Context) {
+	park, ok := resolvePark(c, h.parks)
+	if !ok {
+		return
+	}
+
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "format must be json or csv",
+		})
+		return
+	}
+	if format != "json" && !allowExport(c, park) {
+		return
+	}
+
+	now := time.Now().UTC()
+	to := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if value := c.Query("to"); value != "" {
+		parsed, err := services.ParseEmissionMonth(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid to: " + err.Error(),
+			})
+			return
+		}
+		to = parsed
+	}
+	from := to
+	if value := c.Query("from"); value != "" {
+		parsed, err := services.ParseEmissionMonth(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid from: " + err.Error(),
+			})
+			return
+		}
+		from = parsed
+	}
+	if from.After(to) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "from must not be after to",
+		})
+		return
+	}
+	if from.AddDate(0, services.MaxEmissionMonths, 0).Before(to.AddDate(0, 1, 0)) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("a report covers at most %d months", services.MaxEmissionMonths),
+		})
+		return
+	}
+
+	report, err := h.emissions.GetMonthlyReport(c.Request.Context(), park, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to estimate emissions",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if format == "json" {
+		c.JSON(http.StatusOK, gin.H{
+			"report": report,
+			"config": h.emissions.Config(),
+		})
+		return
+	}
+
+	data, err := services.RenderEmissionCSV(report)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to estimate emissions",
+			"details": err.Error(),
+		})
+		return
+	}
+	filename := fmt.Sprintf("emissions-%s-%s-%s.csv", park.Record.Slug, report.From, report.To)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", data)
+}
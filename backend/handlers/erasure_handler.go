@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"vessel-tracker/models"
+	"vessel-tracker/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ErasureHandler struct {
+	erasureService *services.ErasureService
+}
+
+func NewErasureHandler(erasureService *services.ErasureService) *ErasureHandler {
+	return &ErasureHandler{
+		erasureService: erasureService,
+	}
+}
+
+// EraseVesselData purges or, with mode=anonymize, anonymizes the stored data
+// of a vessel to honor a data removal request
+func (h *ErasureHandler) EraseVesselData(c *gin.Context) {
+	mode := c.DefaultQuery("mode", models.ErasurePurge)
+	if mode != models.ErasurePurge && mode != models.ErasureAnonymize {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid mode, use purge or anonymize",
+		})
+		return
+	}
+
+	by, ok := changedBy(c)
+	if !ok {
+		return
+	}
+
+	erasure, err := h.erasureService.EraseVessel(c.Request.Context(), c.Param("uuid"), mode, by)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Vessel not found",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to erase vessel data",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, erasure)
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"vessel-tracker/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ExplainHandler struct {
+	explainService *services.ExplainService
+}
+
+func NewExplainHandler(explainService *services.ExplainService) *ExplainHandler {
+	return &ExplainHandler{
+		explainService: explainService,
+	}
+}
+
+// ExplainPosition shows how the detection rules classify a stored position:
+// the zones containing it, the whitelist decision, each rule evaluated with
+// its outcome and the violations recorded for it
+func (h *ExplainHandler) ExplainPosition(c *gin.Context) {
+	positionID, err := strconv.ParseUint(c.Query("position_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "position_id is required and must be a position record ID",
+		})
+		return
+	}
+
+	explanation, err := h.explainService.ExplainPosition(c.Request.Context(), uint(positionID))
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Position not found",
+			})
+		case errors.Is(err, services.ErrParkNotMonitored):
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Position belongs to a park that is no longer monitored",
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to explain position",
+				"details": err.Error(),
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, redact(c, explanation))
+}
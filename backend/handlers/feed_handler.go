@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"vessel-tracker/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type FeedHandler struct {
+	feedService *services.FeedService
+	parks       *services.ParkRegistry
+}
+
+func NewFeedHandler(feedService *services.FeedService, parks *services.ParkRegistry) *FeedHandler {
+	return &FeedHandler{
+		feedService: feedService,
+		parks:       parks,
+	}
+}
+
+// requestBaseURL is the address the request reached the tracker at, e.g.
+// https://tracker.example.org, honoring a TLS-terminating proxy
+func requestBaseURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+	return scheme + "://" + c.Request.Host
+}
+
+// GetFeed returns the park statistics feed: one entry per finalized day with
+// the vessel counts, violations and new temporary zones, as Atom (the
+// default), RSS or JSON Feed, over the last 30 days by default
+func (h *FeedHandler) GetFeed(c *gin.Context) {
+	park, ok := resolvePark(c, h.parks)
+	if !ok {
+		return
+	}
+
+	format := c.DefaultQuery("format", services.FeedFormatAtom)
+	if format != services.FeedFormatAtom && format != services.FeedFormatRSS && format != services.FeedFormatJSON {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "format must be atom, rss or json",
+		})
+		return
+	}
+
+	days := services.DefaultFeedDays
+	if daysStr := c.Query("days"); daysStr != "" {
+		var err error
+		days, err = strconv.Atoi(daysStr)
+		if err != nil || days < 1 || days > services.MaxFeedDays {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("days must be between 1 and %d", services.MaxFeedDays),
+			})
+			return
+		}
+	}
+
+	summaries, err := h.feedService.GetDailySummaries(park, days)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to build feed",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	baseURL := requestBaseURL(c)
+	feed := services.NewParkFeed(park, summaries, baseURL, baseURL+c.Request.URL.RequestURI())
+
+	var data []byte
+	var contentType string
+	switch format {
+	case services.FeedFormatRSS:
+		data, err = services.RenderFeedRSS(feed)
+		contentType = "application/rss+xml; charset=utf-8"
+	case services.FeedFormatJSON:
+		data, err = services.RenderFeedJSON(feed)
+		contentType = "application/feed+json; charset=utf-8"
+	default:
+		data, err = services.RenderFeedAtom(feed)
+		contentType = "application/atom+xml; charset=utf-8"
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to build feed",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.Data(http.StatusOK, contentType, data)
+}
@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+	"vessel-tracker/services"
+
+	"github.com/gin-gonic/gin"
+	geojson "github.com/paulmach/go.geojson"
+)
+
+// maxBoundaryUploadBytes caps the size of imported boundary files
+const maxBoundaryUploadBytes = 20 << 20
+
+type GeoHandler struct {
+	parks          *services.ParkRegistry
+	previewService *services.BoundaryPreviewService
+}
+
+func NewGeoHandler(parks *services.ParkRegistry, previewService *services.BoundaryPreviewService) *GeoHandler {
+	return &GeoHandler{
+		parks:          parks,
+		previewService: previewService,
+	}
+}
+
+// GetParks lists the monitored parks with the health of their boundaries
+func (h *GeoHandler) GetParks(c *gin.Context) {
+	parks := make([]gin.H, 0, len(h.parks.All()))
+	for i, park := range h.parks.All() {
+		centerLat, centerLon := park.Geo.GetParkCenter()
+		parks = append(parks, gin.H{
+			"park":    park.Record,
+			"default": i == 0,
+			"center": gin.H{
+				"latitude":  centerLat,
+				"longitude": centerLon,
+			},
+			"boundaries_healthy":    park.Geo.BoundariesHealthy(),
+			"buffer_zone_available": park.Geo.BufferZoneAvailable(),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"parks": parks,
+		"count": len(parks),
+	})
+}
+
+// parseLatLon reads and validates the lat/lon query parameters
+func parseLatLon(c *gin.Context) (float64, float64, bool) {
+	lat, err := strconv.ParseFloat(c.Query("lat"), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "lat parameter is required and must be between -90 and 90",
+		})
+		return 0, 0, false
+	}
+
+	lon, err := strconv.ParseFloat(c.Query("lon"), 64)
+	if err != nil || lon < -180 || lon > 180 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "lon parameter is required and must be between -180 and 180",
+		})
+		return 0, 0, false
+	}
+
+	return lat, lon, true
+}
+
+// GetBoundaryDistance reports zone membership for a point, its distance in
+// meters to the nearest park and buffer zone boundaries, and the habitat
+// layers in force that contain it
+func (h *GeoHandler) GetBoundaryDistance(c *gin.Context) {
+	park, ok := resolvePark(c, h.parks)
+	if !ok {
+		return
+	}
+
+	lat, lon, ok := parseLatLon(c)
+	if !ok {
+		return
+	}
+
+	response := gin.H{
+		"park":                  park.Record.Slug,
+		"latitude":              lat,
+		"longitude":             lon,
+		"is_in_park":            park.Geo.IsPointInPark(lat, lon),
+		"inside_boundary":       park.Geo.IsPointInsideParkBoundary(lat, lon),
+		"is_in_buffer_zone":     park.Geo.IsPointInBufferZone(lat, lon),
+		"buffer_zone_available": park.Geo.BufferZoneAvailable(),
+		"park_boundary":         park.Geo.DistanceToParkBoundary(lat, lon),
+		"habitat_layers":        redact(c, park.Geo.HabitatLayersAt(lat, lon, time.Now())),
+	}
+
+	if buffer := park.Geo.DistanceToBufferBoundary(lat, lon); buffer != nil {
+		response["buffer_boundary"] = buffer
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// readBoundaryUpload resolves the park and layer of a boundary upload and
+// parses the uploaded FeatureCollection with its input report, writing an
+// error response when any of them is invalid
+func (h *GeoHandler) readBoundaryUpload(c *gin.Context) (*services.Park, string, *geojson.FeatureCollection, *services.GeoInputReport, bool) {
+	park, ok := resolvePark(c, h.parks)
+	if !ok {
+		return nil, "", nil, nil, false
+	}
+
+	layer := c.Param("layer")
+	if layer != services.LayerPark && layer != services.LayerBuffer {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "layer must be park or buffer",
+		})
+		return nil, "", nil, nil, false
+	}
+
+	data, err := io.ReadAll(io.LimitReader(c.Request.Body, maxBoundaryUploadBytes))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Failed to read request body",
+			"details": err.Error(),
+		})
+		return nil, "", nil, nil, false
+	}
+
+	fc, err := geojson.UnmarshalFeatureCollection(data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid GeoJSON FeatureCollection",
+			"details": err.Error(),
+		})
+		return nil, "", nil, nil, false
+	}
+
+	report := park.Geo.AnalyzeInput(fc)
+	if report.Coordinates == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":  "no Polygon or MultiPolygon features found",
+			"report": report,
+		})
+		return nil, "", nil, nil, false
+	}
+
+	return park, layer, fc, report, true
+}
+
+// ImportBoundaries replaces the park or buffer zone boundaries of a park with
+// an uploaded GeoJSON FeatureCollection. The upload is checked for swapped
+// coordinates and placement outside the expected region first: dry_run=true
+// only reports, swapped input is rejected unless fix_coordinates=true confirms
+// the swap, and input outside the region is rejected unless force=true.
+func (h *GeoHandler) ImportBoundaries(c *gin.Context) {
+	park, layer, fc, report, ok := h.readBoundaryUpload(c)
+	if !ok {
+		return
+	}
+
+	if c.Query("dry_run") == "true" {
+		c.JSON(http.StatusOK, gin.H{
+			"applied":         false,
+			"report":          report,
+			"expected_region": park.Geo.ExpectedRegion(),
+		})
+		return
+	}
+
+	swapped := false
+	if report.LikelySwapped {
+		if c.Query("fix_coordinates") != "true" {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"error":           "coordinates appear to be swapped; resubmit with fix_coordinates=true to swap them",
+				"report":          report,
+				"expected_region": park.Geo.ExpectedRegion(),
+			})
+			return
+		}
+
+		services.SwapCoordinates(fc)
+		swapped = true
+		report = park.Geo.AnalyzeInput(fc)
+	}
+
+	if !report.InRegion && c.Query("force") != "true" {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":           "boundaries fall outside the expected region; resubmit with force=true to import anyway",
+			"report":          report,
+			"expected_region": park.Geo.ExpectedRegion(),
+		})
+		return
+	}
+
+	if err := park.Geo.ReplaceBoundaries(layer, fc); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to store boundaries",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"applied":             true,
+		"park":                park.Record.Slug,
+		"layer":               layer,
+		"coordinates_swapped": swapped,
+		"report":              report,
+		"region_checks":       park.Geo.BoundaryChecks(),
+	})
+}
+
+// GetBoundaryStatus reports whether the loaded boundaries lie within the
+// expected region
+func (h *GeoHandler) GetBoundaryStatus(c *gin.Context) {
+	park, ok := resolvePark(c, h.parks)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"park":            park.Record.Slug,
+		"healthy":         park.Geo.BoundariesHealthy(),
+		"expected_region": park.Geo.ExpectedRegion(),
+		"layers":          park.Geo.BoundaryChecks(),
+		"layer_status":    park.Geo.LayerStatuses(),
+		"zone_grids":      park.Geo.ZoneGridStats(),
+	})
+}
+
+// PreviewBoundaries diffs an uploaded park or buffer layer against the active
+// one without importing it: the area each covers, and the positions stored
+// between start and end (the last 30 days by default) whose classification
+// would change, with the vessels affected. fix_coordinates=true previews the
+// upload with its coordinates swapped, as the import would store it.
+func (h *GeoHandler) PreviewBoundaries(c *gin.Context) {
+	park, layer, fc, report, ok := h.readBoundaryUpload(c)
+	if !ok {
+		return
+	}
+
+	start, end, ok := parseTimeRange(c, "start", "end", 30*24*time.Hour)
+	if !ok {
+		return
+	}
+
+	limit, ok := parseEventLimit(c)
+	if !ok {
+		return
+	}
+
+	swapped := false
+	if report.LikelySwapped && c.Query("fix_coordinates") == "true" {
+		services.SwapCoordinates(fc)
+		swapped = true
+		report = park.Geo.AnalyzeInput(fc)
+	}
+
+	preview, err := h.previewService.Preview(c.Request.Context(), park, layer, fc, start, end, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to preview boundaries",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"applied":             false,
+		"coordinates_swapped": swapped,
+		"report":              report,
+		"expected_region":     park.Geo.ExpectedRegion(),
+		"preview":             preview,
+	})
+}
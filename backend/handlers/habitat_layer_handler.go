@@ -0,0 +1,292 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"vessel-tracker/middleware"
+	"vessel-tracker/models"
+	"vessel-tracker/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type HabitatLayerHandler struct {
+	layers *services.HabitatLayerService
+	parks  *services.ParkRegistry
+}
+
+func NewHabitatLayerHandler(layers *services.HabitatLayerService, parks *services.ParkRegistry) *HabitatLayerHandler {
+	return &HabitatLayerHandler{
+		layers: layers,
+		parks:  parks,
+	}
+}
+
+// validHabitatKind reports whether kind is a known habitat layer kind
+func validHabitatKind(kind string) bool {
+	switch kind {
+	case models.HabitatKindPosidonia, models.HabitatKindClosure, models.HabitatKindOther:
+		return true
+	}
+	return false
+}
+
+// parseFormTime reads an optional RFC3339 form field. It writes a 400
+// response and returns false on bad input.
+func parseFormTime(c *gin.Context, key string) (*time.Time, bool) {
+	value := c.PostForm(key)
+	if value == "" {
+		return nil, true
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid " + key + " format, use RFC3339",
+		})
+		return nil, false
+	}
+	return &parsed, true
+}
+
+// UploadLayer adds a habitat layer to a park from a multipart upload: the
+// file field holds GeoJSON, KMZ, a GeoPackage or a zipped shapefile, and the
+// name, kind, active_from and active_until fields describe it. The layer is
+// checked like imported boundaries: dry_run=true only reports, and layers
+// with swapped coordinates or outside the expected region are rejected
+// unless force=true.
+func (h *HabitatLayerHandler) UploadLayer(c *gin.Context) {
+	park, ok := resolvePark(c, h.parks)
+	if !ok {
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, services.MaxHabitatFileBytes+1<<20)
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "a multipart file field is required",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	kind := c.PostForm("kind")
+	if !validHabitatKind(kind) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "kind must be posidonia, closure or other",
+		})
+		return
+	}
+
+	activeFrom, ok := parseFormTime(c, "active_from")
+	if !ok {
+		return
+	}
+	activeUntil, ok := parseFormTime(c, "active_until")
+	if !ok {
+		return
+	}
+	if activeFrom != nil && activeUntil != nil && !activeFrom.Before(*activeUntil) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "active_from must be before active_until",
+		})
+		return
+	}
+
+	name := strings.TrimSpace(c.PostForm("name"))
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(header.Filename), filepath.Ext(header.Filename))
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Failed to read upload",
+			"details": err.Error(),
+		})
+		return
+	}
+	defer file.Close()
+
+	staged, err := h.layers.Stage(header.Filename, file)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidHabitatFile) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
+			"error":   "Failed to read habitat layer",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	report := park.Geo.AnalyzeInput(staged.Features)
+	if c.Query("dry_run") == "true" {
+		staged.Discard()
+		c.JSON(http.StatusOK, gin.H{
+			"applied":         false,
+			"format":          staged.Format,
+			"features":        len(staged.Features.Features),
+			"report":          report,
+			"expected_region": park.Geo.ExpectedRegion(),
+		})
+		return
+	}
+
+	if report.Coordinates == 0 {
+		staged.Discard()
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":  "no Polygon or MultiPolygon features found",
+			"report": report,
+		})
+		return
+	}
+
+	if (report.LikelySwapped || !report.InRegion) && c.Query("force") != "true" {
+		staged.Discard()
+		message := "layer falls outside the expected region; resubmit with force=true to add it anyway"
+		if report.LikelySwapped {
+			message = "coordinates appear to be swapped; fix the file or resubmit with force=true to add it anyway"
+		}
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":           message,
+			"report":          report,
+			"expected_region": park.Geo.ExpectedRegion(),
+		})
+		return
+	}
+
+	layer := &models.HabitatLayer{
+		Name:        name,
+		Kind:        kind,
+		ActiveFrom:  activeFrom,
+		ActiveUntil: activeUntil,
+		UploadedBy:  middleware.GetActor(c),
+	}
+	if err := h.layers.Add(park, staged, layer); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to add habitat layer",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"applied": true,
+		"park":    park.Record.Slug,
+		"layer":   redact(c, layer),
+		"report":  report,
+	})
+}
+
+// GetLayers lists the habitat layers of a park, optionally of one kind
+func (h *HabitatLayerHandler) GetLayers(c *gin.Context) {
+	park, ok := resolvePark(c, h.parks)
+	if !ok {
+		return
+	}
+
+	kind := c.Query("kind")
+	if kind != "" && !validHabitatKind(kind) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "kind must be posidonia, closure or other",
+		})
+		return
+	}
+
+	layers, err := h.layers.List(park.Record.ID, kind)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to fetch habitat layers",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"park":   park.Record.Slug,
+		"layers": redact(c, layers),
+		"count":  len(layers),
+	})
+}
+
+// getLayer reads the id parameter and returns the layer, writing an error
+// response and returning nil when it does not exist
+func (h *HabitatLayerHandler) getLayer(c *gin.Context) *models.HabitatLayer {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid layer id",
+		})
+		return nil
+	}
+
+	layer, err := h.layers.Get(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Habitat layer not found",
+			})
+			return nil
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to fetch habitat layer",
+			"details": err.Error(),
+		})
+		return nil
+	}
+	return layer
+}
+
+// GetLayerGeoJSON returns the features of a habitat layer as GeoJSON
+func (h *HabitatLayerHandler) GetLayerGeoJSON(c *gin.Context) {
+	layer := h.getLayer(c)
+	if layer == nil {
+		return
+	}
+
+	payload, err := h.layers.Payload(layer)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to load habitat layer",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	servePayload(c, payload)
+}
+
+// DeleteLayer removes a habitat layer and its file
+func (h *HabitatLayerHandler) DeleteLayer(c *gin.Context) {
+	layer := h.getLayer(c)
+	if layer == nil {
+		return
+	}
+
+	deleted, err := h.layers.Delete(layer.ID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Habitat layer not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to delete habitat layer",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"deleted": true,
+		"layer":   redact(c, deleted),
+	})
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"net/http"
+	"vessel-tracker/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HealthHandler struct {
+	healthService *services.HealthService
+	parks         *services.ParkRegistry
+	probe         *services.ProbeService
+	maintenance   *services.MaintenanceService
+	vesselService *services.VesselService
+	aisReceiver   *services.AISReceiverService // nil unless receiving AIS
+}
+
+func NewHealthHandler(healthService *services.HealthService, parks *services.ParkRegistry, probe *services.ProbeService, maintenance *services.MaintenanceService, vesselService *services.VesselService, aisReceiver *services.AISReceiverService) *HealthHandler {
+	return &HealthHandler{
+		healthService: healthService,
+		parks:         parks,
+		probe:         probe,
+		maintenance:   maintenance,
+		vesselService: vesselService,
+		aisReceiver:   aisReceiver,
+	}
+}
+
+// GetHealth is the readiness probe: 503 with status "unavailable" when a
+// dependency check fails, otherwise 200 with status "healthy", "degraded"
+// or "maintenance"
+func (h *HealthHandler) GetHealth(c *gin.Context) {
+	report := h.healthService.Check(c.Request.Context())
+
+	// Missing or misplaced boundaries or a failing self test leave the API up
+	// but its results wrong
+	status := "healthy"
+	if !h.parks.BoundariesHealthy() || !h.probe.Healthy() {
+		status = "degraded"
+	}
+	if h.maintenance.Enabled() {
+		status = "maintenance"
+	}
+	code := http.StatusOK
+	if !report.Ready {
+		status = "unavailable"
+		code = http.StatusServiceUnavailable
+	}
+
+	parkHealth := make(map[string]gin.H, len(h.parks.All()))
+	for _, park := range h.parks.All() {
+		parkHealth[park.Record.Slug] = gin.H{
+			"layers":                park.Geo.LayerStatuses(),
+			"buffer_zone_available": park.Geo.BufferZoneAvailable(),
+			"boundaries":            park.Geo.BoundaryChecks(),
+		}
+	}
+
+	// The top-level layer fields describe the default park, as they did
+	// before more than one park could be monitored
+	defaultPark := h.parks.Default()
+	health := gin.H{
+		"status":                status,
+		"ready":                 report.Ready,
+		"checks":                report.Checks,
+		"layers":                defaultPark.Geo.LayerStatuses(),
+		"buffer_zone_available": defaultPark.Geo.BufferZoneAvailable(),
+		"boundaries":            defaultPark.Geo.BoundaryChecks(),
+		"parks":                 parkHealth,
+		"probe":                 h.probe.Report(),
+		"provider":              h.vesselService.ProviderName(),
+	}
+	if h.aisReceiver != nil {
+		health["ais_receiver"] = h.aisReceiver.Status()
+	}
+	c.JSON(code, health)
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+	"vessel-tracker/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type LandMaskHandler struct {
+	vesselRepo *services.VesselRepository
+	parks      *services.ParkRegistry
+}
+
+func NewLandMaskHandler(vesselRepo *services.VesselRepository, parks *services.ParkRegistry) *LandMaskHandler {
+	return &LandMaskHandler{
+		vesselRepo: vesselRepo,
+		parks:      parks,
+	}
+}
+
+// GetLandMask returns the land polygons positions are checked against
+func (h *LandMaskHandler) GetLandMask(c *gin.Context) {
+	park, ok := resolvePark(c, h.parks)
+	if !ok {
+		return
+	}
+
+	mask := park.Geo.LandMask()
+	if mask == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "land mask not loaded",
+		})
+		return
+	}
+
+	servePayload(c, mask.Payload())
+}
+
+// GetOnLandPositions lists the positions of a park that plotted on land
+// between start and end (the last 7 days by default), most recent first.
+// They are GPS errors, kept out of park presence, zone events and
+// violations.
+func (h *LandMaskHandler) GetOnLandPositions(c *gin.Context) {
+	park, ok := resolvePark(c, h.parks)
+	if !ok {
+		return
+	}
+
+	start, end, ok := parseTimeRange(c, "start", "end", 7*24*time.Hour)
+	if !ok {
+		return
+	}
+
+	limit, ok := parseEventLimit(c)
+	if !ok {
+		return
+	}
+
+	positions, err := h.vesselRepo.GetOnLandPositions(c.Request.Context(), park.Record.ID, start, end, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to fetch positions on land",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"park":      park.Record.Slug,
+		"land_mask": park.Geo.LandMask() != nil,
+		"start":     start,
+		"end":       end,
+		"positions": redact(c, positions),
+		"count":     len(positions),
+	})
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"vessel-tracker/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type LogHandler struct {
+	logStore *services.LogStore
+}
+
+func NewLogHandler(logStore *services.LogStore) *LogHandler {
+	return &LogHandler{
+		logStore: logStore,
+	}
+}
+
+// GetLogs lists stored log records of the scheduled jobs and ingestion, and
+// warnings and errors of any component, most recent first. It can be
+// filtered by job (the component), minimum level and fetch run_id; since is
+// an RFC3339 time or a duration before now such as 6h, the last 24 hours by
+// default.
+func (h *LogHandler) GetLogs(c *gin.Context) {
+	filter := services.LogFilter{
+		Job:   c.Query("job"),
+		RunID: c.Query("run_id"),
+		Since: time.Now().Add(-24 * time.Hour),
+		Level: slog.LevelDebug,
+		Limit: 500,
+	}
+
+	if since := c.Query("since"); since != "" {
+		if ago, err := time.ParseDuration(since); err == nil && ago > 0 {
+			filter.Since = time.Now().Add(-ago)
+		} else if parsed, err := time.Parse(time.RFC3339, since); err == nil {
+			filter.Since = parsed
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid since format, use RFC3339 or a duration such as 6h",
+			})
+			return
+		}
+	}
+
+	if level := c.Query("level"); level != "" {
+		if err := filter.Level.UnmarshalText([]byte(level)); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid level, use debug, info, warn or error",
+			})
+			return
+		}
+	}
+
+	if limitStr := c.Query("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid limit parameter",
+			})
+			return
+		}
+		filter.Limit = limit
+	}
+
+	logs, err := h.logStore.GetLogs(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to fetch logs",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"logs":  logs,
+		"count": len(logs),
+		"since": filter.Since,
+		"jobs":  services.LogJobComponents,
+		"level": strings.ToLower(filter.Level.String()),
+	})
+}
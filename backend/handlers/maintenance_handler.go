@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+	"vessel-tracker/middleware"
+	"vessel-tracker/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MaintenanceHandler struct {
+	maintenance *services.MaintenanceService
+}
+
+func NewMaintenanceHandler(maintenance *services.MaintenanceService) *MaintenanceHandler {
+	return &MaintenanceHandler{
+		maintenance: maintenance,
+	}
+}
+
+type MaintenanceRequest struct {
+	Enabled    *bool  `json:"enabled" binding:"required"`
+	Message    string `json:"message"`
+	RetryAfter string `json:"retry_after"` // expected duration, e.g. "30m"
+}
+
+// Get the maintenance mode
+func (h *MaintenanceHandler) GetMaintenance(c *gin.Context) {
+	c.JSON(http.StatusOK, h.maintenance.Status())
+}
+
+// SetMaintenance enables or disables maintenance mode. While enabled,
+// non-admin API requests get a 503 and the scheduler is paused.
+func (h *MaintenanceHandler) SetMaintenance(c *gin.Context) {
+	var req MaintenanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if !*req.Enabled {
+		c.JSON(http.StatusOK, h.maintenance.Disable(middleware.GetActor(c)))
+		return
+	}
+
+	var retryAfter time.Duration
+	if req.RetryAfter != "" {
+		var err error
+		retryAfter, err = time.ParseDuration(req.RetryAfter)
+		if err != nil || retryAfter <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid retry_after, use a positive duration such as 30m",
+			})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, h.maintenance.Enable(req.Message, retryAfter, middleware.GetActor(c)))
+}
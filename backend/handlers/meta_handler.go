@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"net/http"
+	"vessel-tracker/middleware"
+	"vessel-tracker/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MetaHandler struct {
+	deprecations []middleware.Deprecation
+	site         *services.SiteService
+	parks        *services.ParkRegistry
+}
+
+func NewMetaHandler(deprecations []middleware.Deprecation, site *services.SiteService, parks *services.ParkRegistry) *MetaHandler {
+	return &MetaHandler{
+		deprecations: deprecations,
+		site:         site,
+		parks:        parks,
+	}
+}
+
+// GetDeprecations lists the endpoints slated for removal, with their removal
+// dates and successors, for integrators to check programmatically
+func (h *MetaHandler) GetDeprecations(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"deprecations": h.deprecations,
+		"count":        len(h.deprecations),
+	})
+}
+
+// GetSite returns what the web app needs to present a park: its name, the
+// map view to open on, the layers to offer, contacts and enabled features.
+// One frontend build serves every deployment by reading it at startup.
+func (h *MetaHandler) GetSite(c *gin.Context) {
+	park, ok := resolvePark(c, h.parks)
+	if !ok {
+		return
+	}
+
+	site, err := h.site.Site(park)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to describe the site",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, site)
+}
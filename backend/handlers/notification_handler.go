@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+	"vessel-tracker/middleware"
+	"vessel-tracker/models"
+	"vessel-tracker/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type NotificationHandler struct {
+	notificationService *services.NotificationService
+}
+
+func NewNotificationHandler(notificationService *services.NotificationService) *NotificationHandler {
+	return &NotificationHandler{
+		notificationService: notificationService,
+	}
+}
+
+// Get the configured channels with their delivery record and the routes of
+// every severity
+func (h *NotificationHandler) GetNotifications(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"channels": h.notificationService.Channels(),
+		"routes":   h.notificationService.Routes(),
+	})
+}
+
+// Replace the channels alerts of each severity are sent on
+func (h *NotificationHandler) SetRoutes(c *gin.Context) {
+	var req struct {
+		Routes map[string][]string `json:"routes" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := h.notificationService.SetRoutes(req.Routes); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid notification routes",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Notification routes updated successfully",
+		"routes":     h.notificationService.Routes(),
+		"updated_by": middleware.GetActor(c),
+	})
+}
+
+// Send a test alert on one channel, or through the routes of a severity
+// when no channel is given
+func (h *NotificationHandler) SendTest(c *gin.Context) {
+	var req struct {
+		Channel  string `json:"channel"`
+		Severity string `json:"severity"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+	if req.Severity == "" {
+		req.Severity = models.SeverityCritical
+	}
+
+	alert := services.Alert{
+		Severity: req.Severity,
+		Subject:  "[TEST] Vessel tracker notification",
+		Message:  "This is a test alert sent by " + middleware.GetActor(c) + ".",
+	}
+
+	channel := req.Channel
+	var err error
+	if channel != "" {
+		err = h.notificationService.SendTest(channel, alert)
+	} else {
+		channel, err = h.notificationService.Notify(alert)
+	}
+	switch {
+	case errors.Is(err, services.ErrUnknownNotificationChannel), errors.Is(err, services.ErrNoNotificationChannel):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	case err != nil:
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error":   "Failed to send test alert",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Test alert sent",
+		"channel": channel,
+	})
+}
+
+// List the alert suppressions not yet over, every one with
+// include_expired=true
+func (h *NotificationHandler) GetSuppressions(c *gin.Context) {
+	includeExpired, _ := strconv.ParseBool(c.Query("include_expired"))
+
+	suppressions, err := h.notificationService.GetSuppressions(includeExpired)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to fetch suppressions",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"suppressions": suppressions,
+		"count":        len(suppressions),
+	})
+}
+
+// Suppress the alerts about a vessel until ends_at, from starts_at or now
+func (h *NotificationHandler) SuppressVessel(c *gin.Context) {
+	var req struct {
+		Reason   string    `json:"reason" binding:"required"`
+		StartsAt time.Time `json:"starts_at"`
+		EndsAt   time.Time `json:"ends_at" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	suppression, err := h.notificationService.Suppress(c.Param("uuid"), req.Reason, middleware.GetActor(c), req.StartsAt, req.EndsAt)
+	if errors.Is(err, services.ErrInvalidSuppression) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to suppress alerts",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, suppression)
+}
+
+// Cancel an alert suppression before it expires
+func (h *NotificationHandler) CancelSuppression(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid suppression id",
+		})
+		return
+	}
+
+	suppression, err := h.notificationService.CancelSuppression(uint(id), middleware.GetActor(c))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Suppression not found",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to cancel suppression",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, suppression)
+}
@@ -0,0 +1,378 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+	"vessel-tracker/models"
+	"vessel-tracker/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type OperatorHandler struct {
+	operatorService  *services.OperatorService
+	whitelistService *services.WhitelistService
+}
+
+func NewOperatorHandler(operatorService *services.OperatorService, whitelistService *services.WhitelistService) *OperatorHandler {
+	return &OperatorHandler{
+		operatorService:  operatorService,
+		whitelistService: whitelistService,
+	}
+}
+
+// Get all operators
+func (h *OperatorHandler) GetOperators(c *gin.Context) {
+	operators, err := h.operatorService.GetAllOperators()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to fetch operators",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"operators": redact(c, operators),
+		"count":     len(operators),
+	})
+}
+
+// Get a single operator with its vessels
+func (h *OperatorHandler) GetOperator(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid operator id",
+		})
+		return
+	}
+
+	operator, err := h.operatorService.GetOperator(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Operator not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to fetch operator",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, redact(c, operator))
+}
+
+// Create a new operator
+func (h *OperatorHandler) CreateOperator(c *gin.Context) {
+	var req struct {
+		Name       string `json:"name"`
+		RegistryID string `json:"registry_id"`
+		Notes      string `json:"notes"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if req.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Name is required",
+		})
+		return
+	}
+
+	operator := &models.Operator{
+		Name:       req.Name,
+		RegistryID: req.RegistryID,
+		Notes:      req.Notes,
+	}
+
+	if err := h.operatorService.CreateOperator(operator); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to create operator",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, operator)
+}
+
+// Link a vessel to an operator so the operator's permits cover it
+func (h *OperatorHandler) AssignVessel(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid operator id",
+		})
+		return
+	}
+
+	var req struct {
+		VesselUUID string `json:"vessel_uuid"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil || req.VesselUUID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "vessel_uuid is required",
+		})
+		return
+	}
+
+	if err := h.operatorService.AssignVessel(uint(id), req.VesselUUID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Operator or vessel not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to assign vessel to operator",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	// Operator permits apply to the newly linked vessel right away
+	h.whitelistService.Reload()
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Vessel assigned to operator successfully",
+		"operator_id": id,
+		"vessel_uuid": req.VesselUUID,
+	})
+}
+
+// Unlink a vessel from an operator
+func (h *OperatorHandler) UnassignVessel(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid operator id",
+		})
+		return
+	}
+
+	vesselUUID := c.Param("uuid")
+	if err := h.operatorService.UnassignVessel(uint(id), vesselUUID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Vessel is not assigned to this operator",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to unassign vessel from operator",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	h.whitelistService.Reload()
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Vessel unassigned from operator successfully",
+		"operator_id": id,
+		"vessel_uuid": vesselUUID,
+	})
+}
+
+// Get activity statistics grouped by operator
+func (h *OperatorHandler) GetOperatorStats(c *gin.Context) {
+	startTime, endTime, ok := parseTimeRange(c, "start", "end", 30*24*time.Hour)
+	if !ok {
+		return
+	}
+
+	stats, err := h.operatorService.GetOperatorStats(startTime, endTime)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to compute operator statistics",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"stats": redact(c, stats),
+		"count": len(stats),
+		"start": startTime,
+		"end":   endTime,
+	})
+}
+
+// Get violations attributed to an operator across all of its vessels
+func (h *OperatorHandler) GetOperatorViolations(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid operator id",
+		})
+		return
+	}
+
+	limit := 100
+	if limitStr := c.Query("limit"); limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid limit parameter",
+			})
+			return
+		}
+	}
+
+	violations, err := h.operatorService.GetOperatorViolations(uint(id), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to fetch operator violations",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	lastYear, err := h.operatorService.CountOperatorViolations(uint(id), time.Now().AddDate(-1, 0, 0))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to count operator violations",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"operator_id":          id,
+		"violations":           redact(c, violations),
+		"count":                len(violations),
+		"violations_last_year": lastYear,
+		"is_repeat_offender":   lastYear > 1,
+	})
+}
+
+// Get contact details for an operator (admin only)
+func (h *OperatorHandler) GetOperatorContacts(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid operator id",
+		})
+		return
+	}
+
+	contacts, err := h.operatorService.GetContacts(uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to fetch operator contacts",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"operator_id": id,
+		"contacts":    contacts,
+		"count":       len(contacts),
+	})
+}
+
+// Add a contact to an operator (admin only)
+func (h *OperatorHandler) AddOperatorContact(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid operator id",
+		})
+		return
+	}
+
+	var req struct {
+		Name    string `json:"name"`
+		Role    string `json:"role"`
+		Email   string `json:"email"`
+		Phone   string `json:"phone"`
+		Address string `json:"address"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if req.Email == "" && req.Phone == "" && req.Address == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "At least one of email, phone, or address must be provided",
+		})
+		return
+	}
+
+	contact := &models.OperatorContact{
+		OperatorID: uint(id),
+		Name:       req.Name,
+		Role:       req.Role,
+		Email:      req.Email,
+		Phone:      req.Phone,
+		Address:    req.Address,
+	}
+
+	if err := h.operatorService.AddContact(contact); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Operator not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to add operator contact",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, contact)
+}
+
+// Remove a contact from an operator (admin only)
+func (h *OperatorHandler) DeleteOperatorContact(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid operator id",
+		})
+		return
+	}
+
+	contactID, err := strconv.ParseUint(c.Param("contact_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid contact id",
+		})
+		return
+	}
+
+	if err := h.operatorService.DeleteContact(uint(id), uint(contactID)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to delete operator contact",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Operator contact deleted successfully",
+		"contact_id": contactID,
+	})
+}
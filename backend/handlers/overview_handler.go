@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"net/http"
+	"vessel-tracker/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type OverviewHandler struct {
+	overviewService *services.OverviewService
+}
+
+func NewOverviewHandler(overviewService *services.OverviewService) *OverviewHandler {
+	return &OverviewHandler{
+		overviewService: overviewService,
+	}
+}
+
+// GetOverview returns the state of the scheduler, provider credits, database,
+// open violations, today's alerts and caches in one call
+func (h *OverviewHandler) GetOverview(c *gin.Context) {
+	overview, err := h.overviewService.GetOverview()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to build overview",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, overview)
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+	"vessel-tracker/middleware"
+	"vessel-tracker/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// changedBy identifies the caller making a change, for audit records. It
+// writes a 401 response and returns false for anonymous callers, whose
+// changes could not be attributed to anyone.
+func changedBy(c *gin.Context) (services.ChangedBy, bool) {
+	if middleware.GetAPIKey(c) == middleware.APIKeyAnonymous {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "changes must be made by an authenticated caller",
+		})
+		return services.ChangedBy{}, false
+	}
+
+	return services.ChangedBy{
+		Actor:    middleware.GetActor(c),
+		Role:     middleware.GetRole(c),
+		ClientIP: c.ClientIP(),
+	}, true
+}
+
+// resolvePark returns the park named by the park query parameter, or the
+// default park when none is given. It writes a 404 response and returns
+// false for an unknown park.
+func resolvePark(c *gin.Context, parks *services.ParkRegistry) (*services.Park, bool) {
+	slug := c.Query("park")
+	if slug == "" {
+		return parks.Default(), true
+	}
+
+	park, ok := parks.Get(slug)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "unknown park " + slug,
+		})
+		return nil, false
+	}
+	return park, true
+}
+
+// allowExport reports whether a park's data may be handed out as a file. It
+// writes a 403 response and returns false for a park with restrict_exports.
+func allowExport(c *gin.Context, park *services.Park) bool {
+	if !park.Record.RestrictExports {
+		return true
+	}
+	c.JSON(http.StatusForbidden, gin.H{
+		"error":       "exports of park " + park.Record.Slug + " data are restricted",
+		"data_region": park.Record.DataRegion,
+	})
+	return false
+}
+
+// parseTimeRange reads RFC3339 start/end query parameters, defaulting to the
+// window ending now. It writes a 400 response and returns false on bad input.
+func parseTimeRange(c *gin.Context, startKey, endKey string, defaultWindow time.Duration) (time.Time, time.Time, bool) {
+	endTime := time.Now()
+	startTime := endTime.Add(-defaultWindow)
+
+	if endStr := c.Query(endKey); endStr != "" {
+		parsed, err := time.Parse(time.RFC3339, endStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid " + endKey + " format, use RFC3339",
+			})
+			return startTime, endTime, false
+		}
+		endTime = parsed
+		startTime = endTime.Add(-defaultWindow)
+	}
+
+	if startStr := c.Query(startKey); startStr != "" {
+		parsed, err := time.Parse(time.RFC3339, startStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid " + startKey + " format, use RFC3339",
+			})
+			return startTime, endTime, false
+		}
+		startTime = parsed
+	}
+
+	if !startTime.Before(endTime) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": startKey + " must be before " + endKey,
+		})
+		return startTime, endTime, false
+	}
+
+	return startTime, endTime, true
+}
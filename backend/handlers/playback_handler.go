@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"vessel-tracker/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PlaybackHandler struct {
+	playbackService *services.PlaybackService
+	parks           *services.ParkRegistry
+}
+
+func NewPlaybackHandler(playbackService *services.PlaybackService, parks *services.ParkRegistry) *PlaybackHandler {
+	return &PlaybackHandler{
+		playbackService: playbackService,
+		parks:           parks,
+	}
+}
+
+// GetPlayback returns the vessel positions of a park at every step (5m by
+// default) from start to end (the last hour by default), each frame placed as
+// /vessels/at-time places vessels, so a replay needs a single request
+func (h *PlaybackHandler) GetPlayback(c *gin.Context) {
+	park, ok := resolvePark(c, h.parks)
+	if !ok {
+		return
+	}
+
+	start, end, ok := parseTimeRange(c, "start", "end", time.Hour)
+	if !ok {
+		return
+	}
+
+	options := services.PlaybackOptions{
+		Start:  start,
+		End:    end,
+		Step:   5 * time.Minute,
+		MaxGap: services.DefaultInterpolationMaxGap,
+	}
+
+	durations := []struct {
+		name   string
+		target *time.Duration
+	}{
+		{"step", &options.Step},
+		{"max_gap", &options.MaxGap},
+		{"max_age", &options.MaxAge},
+	}
+	for _, duration := range durations {
+		if value := c.Query(duration.name); value != "" {
+			parsed, err := time.ParseDuration(value)
+			if err != nil || parsed <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": "invalid " + duration.name + " parameter, use a positive duration such as 5m",
+				})
+				return
+			}
+			*duration.target = parsed
+		}
+	}
+
+	if value := c.Query("interpolate"); value != "" {
+		interpolate, err := strconv.ParseBool(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid interpolate parameter, use true or false",
+			})
+			return
+		}
+		options.Interpolate = interpolate
+	}
+
+	if frames := services.PlaybackFrameCount(start, end, options.Step); frames > services.MaxPlaybackFrames {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("the range would take %d frames, at most %d are returned; use a longer step or a shorter range", frames, services.MaxPlaybackFrames),
+		})
+		return
+	}
+
+	playback, err := h.playbackService.BuildPlayback(c.Request.Context(), park, options)
+	if err != nil {
+		if errors.Is(err, services.ErrPlaybackTooLarge) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Playback range too large, use a shorter range",
+				"details": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to build playback",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, playback)
+}
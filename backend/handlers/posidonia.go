@@ -1,18 +1,88 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
 	"vessel-tracker/services"
 
 	"github.com/gin-gonic/gin"
 )
 
-func GetPosidoniaData(c *gin.Context) {
-	geoJSON, err := services.LoadPosidoniaData()
+// mvtContentType is the media type of Mapbox Vector Tiles
+const mvtContentType = "application/vnd.mapbox-vector-tile"
+
+type PosidoniaHandler struct {
+	layer *services.PosidoniaLayer
+	tiles *services.PosidoniaTiles
+}
+
+func NewPosidoniaHandler(layer *services.PosidoniaLayer, tiles *services.PosidoniaTiles) *PosidoniaHandler {
+	return &PosidoniaHandler{
+		layer: layer,
+		tiles: tiles,
+	}
+}
+
+func (h *PosidoniaHandler) GetPosidoniaData(c *gin.Context) {
+	payload, err := h.layer.Payload()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, geoJSON)
-}
\ No newline at end of file
+	servePayload(c, payload)
+}
+
+// GetPosidoniaTile serves a Mapbox Vector Tile of the posidonia meadows at
+// /tiles/posidonia/:z/:x/:y.mvt. Tiles without meadows are served as 204 No
+// Content.
+func (h *PosidoniaHandler) GetPosidoniaTile(c *gin.Context) {
+	yParam, ok := strings.CutSuffix(c.Param("y"), ".mvt")
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "tiles are served as .mvt"})
+		return
+	}
+	z, errZ := strconv.Atoi(c.Param("z"))
+	x, errX := strconv.Atoi(c.Param("x"))
+	y, errY := strconv.Atoi(yParam)
+	if errZ != nil || errX != nil || errY != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "z, x and y must be integers"})
+		return
+	}
+
+	tile, err := h.tiles.Tile(z, x, y)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidTile) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to render tile",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.Header("ETag", tile.ETag)
+	c.Header("Last-Modified", tile.LastModified.Format(http.TimeFormat))
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Vary", "Accept-Encoding")
+
+	if notModified(c, tile.ETag, tile.LastModified) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	if len(tile.Data) == 0 {
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	if acceptsGzip(c.GetHeader("Accept-Encoding")) {
+		c.Header("Content-Encoding", "gzip")
+		c.Data(http.StatusOK, mvtContentType, tile.Gzip)
+		return
+	}
+	c.Data(http.StatusOK, mvtContentType, tile.Data)
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"vessel-tracker/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ProviderAuditHandler struct {
+	audit *services.ProviderAudit
+	parks *services.ParkRegistry
+}
+
+func NewProviderAuditHandler(audit *services.ProviderAudit, parks *services.ParkRegistry) *ProviderAuditHandler {
+	return &ProviderAuditHandler{
+		audit: audit,
+		parks: parks,
+	}
+}
+
+// GetProviderResponses lists the raw provider responses recorded during
+// fetch runs, newest first, optionally those of one run
+func (h *ProviderAuditHandler) GetProviderResponses(c *gin.Context) {
+	limit := 100
+	if limitStr := c.Query("limit"); limitStr != "" {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid limit parameter",
+			})
+			return
+		}
+	}
+
+	responses, err := h.audit.GetResponses(c.Query("run"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to fetch provider responses",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"responses": responses,
+		"count":     len(responses),
+	})
+}
+
+// GetProviderResponsePayload serves a recorded response body exactly as the
+// provider sent it, unless it was fetched for a park with restricted exports
+func (h *ProviderAuditHandler) GetProviderResponsePayload(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid response ID",
+		})
+		return
+	}
+
+	response, payload, err := h.audit.GetPayload(uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Provider response not found",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to fetch provider response",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if park, ok := h.parks.ForProviderEndpoint(response.Endpoint); ok && !allowExport(c, park) {
+		return
+	}
+
+	contentType := "text/plain; charset=utf-8"
+	if json.Valid(payload) {
+		contentType = "application/json"
+	}
+	c.Header("X-Fetch-Run-ID", response.FetchRunID)
+	c.Header("X-Provider-Status", strconv.Itoa(response.StatusCode))
+	c.Data(http.StatusOK, contentType, payload)
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+	"vessel-tracker/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type QuotaHandler struct {
+	vesselService *services.VesselService
+}
+
+func NewQuotaHandler(vesselService *services.VesselService) *QuotaHandler {
+	return &QuotaHandler{
+		vesselService: vesselService,
+	}
+}
+
+// GetDatalasticQuota returns the Datalastic credits spent today and how many
+// are left
+func (h *QuotaHandler) GetDatalasticQuota(c *gin.Context) {
+	quota := h.vesselService.Quota()
+	if quota == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "vessel data is not fetched from Datalastic",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, quota.Status())
+}
+
+// respondQuotaError writes a 429 response, with Retry-After set to when the
+// credits reset, for a call refused by the Datalastic quota. It returns false
+// for any other error.
+func respondQuotaError(c *gin.Context, vesselService *services.VesselService, err error) bool {
+	if !errors.Is(err, services.ErrQuotaExhausted) && !errors.Is(err, services.ErrQuotaDeferred) {
+		return false
+	}
+
+	status := vesselService.Quota().Status()
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(time.Until(status.ResetsAt).Seconds()))))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":     err.Error(),
+		"resets_at": status.ResetsAt,
+	})
+	return true
+}
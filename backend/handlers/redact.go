@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"vessel-tracker/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// redact prepares a response value for the requester's role. Struct fields
+// tagged with `role:"<minimum role>"` are dropped for less privileged
+// requesters; everything else is serialized exactly as encoding/json would.
+func redact(c *gin.Context, v interface{}) interface{} {
+	rank := middleware.RoleRank(middleware.GetRole(c))
+	if rank >= middleware.RoleRank(middleware.RoleAdmin) {
+		return v
+	}
+	return redactValue(rank, reflect.ValueOf(v))
+}
+
+func redactValue(rank int, v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+
+	if v.Type().Implements(jsonMarshalerType) {
+		return v.Interface()
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return redactValue(rank, v.Elem())
+	case reflect.Struct:
+		out := make(map[string]interface{})
+		redactStruct(rank, v, out)
+		return out
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return v.Interface()
+		}
+		fallthrough
+	case reflect.Array:
+		out := make([]interface{}, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			out[i] = redactValue(rank, v.Index(i))
+		}
+		return out
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		out := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key, ok := iter.Key().Interface().(string)
+			if !ok {
+				return v.Interface()
+			}
+			out[key] = redactValue(rank, iter.Value())
+		}
+		return out
+	}
+
+	return v.Interface()
+}
+
+func redactStruct(rank int, v reflect.Value, out map[string]interface{}) {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		if minRole := field.Tag.Get("role"); minRole != "" && rank < middleware.RoleRank(minRole) {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" && opts == "" {
+			continue
+		}
+
+		value := v.Field(i)
+		if field.Anonymous && name == "" && value.Kind() == reflect.Struct {
+			redactStruct(rank, value, out)
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		if strings.Contains(opts, "omitempty") && isEmptyValue(value) {
+			continue
+		}
+
+		out[name] = redactValue(rank, value)
+	}
+}
+
+// isEmptyValue mirrors the omitempty rules of encoding/json
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"vessel-tracker/middleware"
+	"vessel-tracker/models"
+	"vessel-tracker/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRedactHidesPersonalDataFromAnonymousCallers(t *testing.T) {
+	operatorID := uint(7)
+	violation := models.Violation{
+		ID:         1,
+		VesselUUID: "charter",
+		VesselName: "Charter",
+		OperatorID: &operatorID,
+		Type:       models.ViolationExcessiveSpeed,
+		Details:    "12 knots reported by the patrol",
+		Operator: &models.Operator{
+			ID:       operatorID,
+			Name:     "Blue Charters",
+			Contacts: []models.OperatorContact{{ID: 1, OperatorID: operatorID, Email: "office@bluecharters.example"}},
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	auth := middleware.AuthConfig{
+		AdminToken: "admin-secret",
+		RoleTokens: []middleware.RoleToken{{Token: "ranger-secret", Role: middleware.RoleRanger}},
+	}
+	r.Use(middleware.Authenticate(auth, nil, nil, services.NewLoginGuard(services.DefaultLoginGuardConfig(), nil)))
+	r.GET("/violations", func(c *gin.Context) {
+		c.JSON(http.StatusOK, redact(c, []models.Violation{violation}))
+	})
+
+	get := func(token string) map[string]interface{} {
+		req := httptest.NewRequest(http.MethodGet, "/violations", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET /violations answered %d: %s", w.Code, w.Body.String())
+		}
+		var body []map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || len(body) != 1 {
+			t.Fatalf("response %s: %v", w.Body.String(), err)
+		}
+		return body[0]
+	}
+
+	anonymous := get("")
+	for _, field := range []string{"operator_id", "details", "operator"} {
+		if value, ok := anonymous[field]; ok {
+			t.Fatalf("anonymous caller was served %s = %v", field, value)
+		}
+	}
+	if anonymous["vessel_name"] != "Charter" || anonymous["type"] != models.ViolationExcessiveSpeed {
+		t.Fatalf("anonymous caller was served %v, want the public fields of the violation", anonymous)
+	}
+
+	ranger := get("ranger-secret")
+	operator, ok := ranger["operator"].(map[string]interface{})
+	if !ok || operator["name"] != "Blue Charters" || ranger["details"] == nil {
+		t.Fatalf("ranger was served %v, want the operator and details", ranger)
+	}
+	if _, ok := operator["contacts"]; ok {
+		t.Fatalf("ranger was served the operator's contacts: %v", operator["contacts"])
+	}
+
+	admin := get("admin-secret")
+	operator, _ = admin["operator"].(map[string]interface{})
+	if contacts, ok := operator["contacts"].([]interface{}); !ok || len(contacts) != 1 {
+		t.Fatalf("admin was served %v, want the operator's contacts", admin["operator"])
+	}
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+	"vessel-tracker/models"
+	"vessel-tracker/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ReportHandler struct {
+	reportService *services.ReportService
+	parks         *services.ParkRegistry
+}
+
+func NewReportHandler(reportService *services.ReportService, parks *services.ParkRegistry) *ReportHandler {
+	return &ReportHandler{
+		reportService: reportService,
+		parks:         parks,
+	}
+}
+
+// GetViolationReport compiles a park's daily or weekly violation report as
+// JSON (default), CSV (one row per violation) or PDF. The period ends at end,
+// which defaults to the start of the current UTC day so reports cover whole
+// days. CSV and PDF downloads are refused for parks with restricted exports.
+func (h *ReportHandler) GetViolationReport(c *gin.Context) {
+	park, ok := resolvePark(c, h.parks)
+	if !ok {
+		return
+	}
+
+	period := c.DefaultQuery("period", models.ReportPeriodWeekly)
+	if period != models.ReportPeriodDaily && period != models.ReportPeriodWeekly {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "period must be daily or weekly",
+		})
+		return
+	}
+
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" && format != "pdf" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "format must be json, csv or pdf",
+		})
+		return
+	}
+	if format != "json" && !allowExport(c, park) {
+		return
+	}
+
+	end := time.Now().UTC().Truncate(24 * time.Hour)
+	if endStr := c.Query("end"); endStr != "" {
+		parsed, err := time.Parse(time.RFC3339, endStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid end format, use RFC3339",
+			})
+			return
+		}
+		end = parsed
+	}
+
+	report, err := h.reportService.GenerateViolationReport(park, period, end)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to generate report",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if format == "json" {
+		c.JSON(http.StatusOK, redact(c, report))
+		return
+	}
+
+	filename := fmt.Sprintf("violations-%s-%s-%s.%s", park.Record.Slug, period, report.End.UTC().Format("2006-01-02"), format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	if format == "csv" {
+		data, err := services.RenderReportCSV(report)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to generate report",
+				"details": err.Error(),
+			})
+			return
+		}
+		c.Data(http.StatusOK, "text/csv; charset=utf-8", data)
+		return
+	}
+
+	c.Data(http.StatusOK, "application/pdf", services.RenderReportPDF(report))
+}
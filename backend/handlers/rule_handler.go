@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"net/http"
+	"vessel-tracker/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RuleHandler struct {
+	parks *services.ParkRegistry
+}
+
+func NewRuleHandler(parks *services.ParkRegistry) *RuleHandler {
+	return &RuleHandler{
+		parks: parks,
+	}
+}
+
+// GetRules returns the zone rules applying to a park, in the order they are
+// evaluated, with how each zone treats vessels no rule matches and the
+// whitelist exceptions
+func (h *RuleHandler) GetRules(c *gin.Context) {
+	park, ok := resolvePark(c, h.parks)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"park":     park.Record.Slug,
+		"defaults": services.ZoneDefaults(),
+		"rules":    park.Rules,
+		"count":    len(park.Rules),
+
+		"whitelist_exceptions": park.WhitelistExceptions,
+	})
+}
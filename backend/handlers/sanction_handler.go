@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+	"vessel-tracker/models"
+	"vessel-tracker/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type SanctionHandler struct {
+	sanctionService *services.SanctionService
+	webhookSecret   string
+}
+
+// NewSanctionHandler verifies payment webhooks with webhookSecret; with an
+// empty secret the webhook is disabled
+func NewSanctionHandler(sanctionService *services.SanctionService, webhookSecret string) *SanctionHandler {
+	return &SanctionHandler{
+		sanctionService: sanctionService,
+		webhookSecret:   webhookSecret,
+	}
+}
+
+func (h *SanctionHandler) writeSanctionError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Sanction or violation not found",
+		})
+	case errors.Is(err, services.ErrInvalidTransition):
+		c.JSON(http.StatusConflict, gin.H{
+			"error": err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   message,
+			"details": err.Error(),
+		})
+	}
+}
+
+// Issue a fine for a violation
+func (h *SanctionHandler) IssueSanction(c *gin.Context) {
+	violationID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid violation id",
+		})
+		return
+	}
+
+	var req struct {
+		Amount           float64 `json:"amount"`
+		Currency         string  `json:"currency"`
+		DueDate          string  `json:"due_date"`
+		PaymentReference string  `json:"payment_reference"`
+		Notes            string  `json:"notes"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if req.Amount <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "amount must be greater than zero",
+		})
+		return
+	}
+
+	sanction := &models.Sanction{
+		ViolationID:      uint(violationID),
+		Amount:           req.Amount,
+		Currency:         req.Currency,
+		PaymentReference: req.PaymentReference,
+		Notes:            req.Notes,
+	}
+
+	if req.DueDate != "" {
+		sanction.DueDate, err = time.Parse(time.RFC3339, req.DueDate)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid due_date format, use RFC3339",
+			})
+			return
+		}
+	}
+
+	if err := h.sanctionService.IssueSanction(sanction); err != nil {
+		h.writeSanctionError(c, err, "Failed to issue sanction")
+		return
+	}
+
+	c.JSON(http.StatusCreated, sanction)
+}
+
+// List sanctions, optionally filtered by status
+func (h *SanctionHandler) GetSanctions(c *gin.Context) {
+	sanctions, err := h.sanctionService.GetSanctions(c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to fetch sanctions",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"sanctions": sanctions,
+		"count":     len(sanctions),
+	})
+}
+
+// Manually update the payment status of a sanction
+func (h *SanctionHandler) UpdateSanction(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid sanction id",
+		})
+		return
+	}
+
+	var req struct {
+		Status           string  `json:"status"`
+		AmountPaid       float64 `json:"amount_paid"`
+		PaymentReference string  `json:"payment_reference"`
+		Notes            string  `json:"notes"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil || req.Status == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "status is required",
+		})
+		return
+	}
+
+	sanction, err := h.sanctionService.UpdateStatus(uint(id), req.Status, req.AmountPaid, req.PaymentReference, req.Notes)
+	if err != nil {
+		h.writeSanctionError(c, err, "Failed to update sanction")
+		return
+	}
+
+	c.JSON(http.StatusOK, sanction)
+}
+
+// PaymentWebhook receives payment confirmations from the payment provider.
+// Requests must carry an X-Signature header with the hex HMAC-SHA256 of the
+// body using PAYMENT_WEBHOOK_SECRET.
+func (h *SanctionHandler) PaymentWebhook(c *gin.Context) {
+	if h.webhookSecret == "" {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "payment webhook is not configured",
+		})
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "failed to read request body",
+		})
+		return
+	}
+
+	mac := hmac.New(sha256.New, []byte(h.webhookSecret))
+	mac.Write(body)
+	expected := hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(c.GetHeader("X-Signature"))) {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "invalid signature",
+		})
+		return
+	}
+
+	var req struct {
+		PaymentReference string  `json:"payment_reference"`
+		AmountPaid       float64 `json:"amount_paid"`
+		Status           string  `json:"status"`
+	}
+
+	if err := json.Unmarshal(body, &req); err != nil || req.PaymentReference == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "payment_reference is required",
+		})
+		return
+	}
+
+	if req.Status != "" && req.Status != "paid" && req.Status != "succeeded" {
+		// Only settled payments change sanction state
+		c.JSON(http.StatusOK, gin.H{
+			"message": "ignored",
+		})
+		return
+	}
+
+	sanction, err := h.sanctionService.RecordPayment(req.PaymentReference, req.AmountPaid)
+	if err != nil {
+		h.writeSanctionError(c, err, "Failed to record payment")
+		return
+	}
+
+	c.JSON(http.StatusOK, sanction)
+}
+
+// Get fine collection statistics for a period
+func (h *SanctionHandler) GetCollectionStats(c *gin.Context) {
+	startTime, endTime, ok := parseTimeRange(c, "start", "end", 365*24*time.Hour)
+	if !ok {
+		return
+	}
+
+	stats, err := h.sanctionService.GetCollectionStats(startTime, endTime)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to compute collection statistics",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"stats": stats,
+		"start": startTime,
+		"end":   endTime,
+	})
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"vessel-tracker/models"
+	"vessel-tracker/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SchedulerHandler struct {
+	scheduler *services.SchedulerService
+}
+
+func NewSchedulerHandler(scheduler *services.SchedulerService) *SchedulerHandler {
+	return &SchedulerHandler{
+		scheduler: scheduler,
+	}
+}
+
+// Get the active scheduler settings
+func (h *SchedulerHandler) GetConfig(c *gin.Context) {
+	config := h.scheduler.Config()
+
+	c.JSON(http.StatusOK, gin.H{
+		"source":                 config.Source,
+		"fetch_interval":         config.FetchInterval.String(),
+		"fetch_interval_seconds": int64(config.FetchInterval.Seconds()),
+		"radius_nm":              config.RadiusNM,
+		"retention_days":         config.RetentionDays,
+		"fetch_timeout":          config.FetchTimeout.String(),
+	})
+}
+
+// Get the outcome of recent fetches and the next scheduled run
+func (h *SchedulerHandler) GetStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, h.scheduler.Status())
+}
+
+// Trigger a vessel data fetch immediately
+func (h *SchedulerHandler) FetchNow(c *gin.Context) {
+	if err := h.scheduler.FetchNow(); err != nil {
+		if errors.Is(err, services.ErrSchedulerPaused) {
+			c.JSON(http.StatusConflict, gin.H{
+				"error": "the scheduler is paused for maintenance",
+			})
+			return
+		}
+		if errors.Is(err, services.ErrPollingDisabled) {
+			c.JSON(http.StatusConflict, gin.H{
+				"error": "vessel data is not polled, positions come from the AIS receiver",
+			})
+			return
+		}
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "a fetch is already running",
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Vessel data fetch started",
+	})
+}
+
+// maxIngestPositions bounds the positions of one ingest request
+const maxIngestPositions = 5000
+
+type IngestPositionsRequest struct {
+	Positions []models.VesselPosition `json:"positions" binding:"required"`
+}
+
+// Push vessel positions, such as those reported by patrol boats, through the
+// same pipeline as a fetch: each park stores and checks the positions within
+// its search radius
+func (h *SchedulerHandler) IngestPositions(c *gin.Context) {
+	var req IngestPositionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+	if len(req.Positions) == 0 || len(req.Positions) > maxIngestPositions {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("send between 1 and %d positions", maxIngestPositions),
+		})
+		return
+	}
+	for i, pos := range req.Positions {
+		if pos.UUID == "" || pos.Latitude < -90 || pos.Latitude > 90 || pos.Longitude < -180 || pos.Longitude > 180 || pos.Speed < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("position %d needs a uuid, a latitude and longitude in range and a non-negative speed", i),
+			})
+			return
+		}
+	}
+
+	if err := h.scheduler.IngestPositions(req.Positions); err != nil {
+		if errors.Is(err, services.ErrSchedulerPaused) {
+			c.JSON(http.StatusConflict, gin.H{
+				"error": "the scheduler is paused for maintenance",
+			})
+			return
+		}
+		if errors.Is(err, services.ErrFetchRunning) {
+			c.JSON(http.StatusConflict, gin.H{
+				"error": "a fetch is running, retry shortly",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to process positions",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Positions processed",
+		"positions": len(req.Positions),
+	})
+}
+
+// Get the per-table retention policies and the outcome of the last cleanup
+func (h *SchedulerHandler) GetRetention(c *gin.Context) {
+	retention := h.scheduler.Retention()
+
+	c.JSON(http.StatusOK, gin.H{
+		"archiving_enabled": retention.ArchivingEnabled(),
+		"policies":          retention.Policies(),
+		"last_run":          retention.LastRun(),
+	})
+}
@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"vessel-tracker/middleware"
+	"vessel-tracker/models"
+	"vessel-tracker/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type SessionHandler struct {
+	sessionService *services.SessionService
+	loginGuard     *services.LoginGuard
+}
+
+func NewSessionHandler(sessionService *services.SessionService, loginGuard *services.LoginGuard) *SessionHandler {
+	return &SessionHandler{
+		sessionService: sessionService,
+		loginGuard:     loginGuard,
+	}
+}
+
+type LoginRequest struct {
+	DeviceName string `json:"device_name" binding:"required"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type RevokeRequest struct {
+	Reason string `json:"reason"`
+}
+
+type RevokeActorRequest struct {
+	Actor  string `json:"actor" binding:"required"`
+	Reason string `json:"reason"`
+}
+
+type RevokeTokenRequest struct {
+	Token  string `json:"token" binding:"required"`
+	Reason string `json:"reason"`
+}
+
+// Start a device session, authenticated with a static role token
+func (h *SessionHandler) Login(c *gin.Context) {
+	// Sessions may only be started from an enrolment credential, otherwise a
+	// stolen device could mint sessions that survive revoking its own
+	if _, isSession := middleware.GetSession(c); isSession || middleware.GetRole(c) == middleware.RolePublic {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "a valid role token is required to log in",
+		})
+		return
+	}
+
+	var req LoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	tokens, err := h.sessionService.CreateSession(middleware.GetActor(c), middleware.GetRole(c), req.DeviceName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to create session",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, tokens)
+}
+
+// Exchange a refresh token for a new token pair
+func (h *SessionHandler) Refresh(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	tokens, err := h.sessionService.Refresh(req.RefreshToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrRefreshTokenReused):
+			// A rotated token coming back means it was copied off the device
+			h.loginGuard.Alert(models.SecurityEvent{
+				Type:     models.SecurityEventTokenReuse,
+				ClientIP: c.ClientIP(),
+				PeerIP:   c.RemoteIP(),
+				Path:     c.Request.URL.Path,
+				Details:  err.Error(),
+			})
+		case errors.Is(err, services.ErrInvalidToken), errors.Is(err, services.ErrTokenRevoked):
+			h.loginGuard.RecordFailure(c.ClientIP(), c.RemoteIP(), "", c.Request.URL.Path, "refresh rejected: "+err.Error())
+		}
+
+		if errors.Is(err, services.ErrInvalidToken) || errors.Is(err, services.ErrTokenExpired) || errors.Is(err, services.ErrTokenRevoked) || errors.Is(err, services.ErrRefreshTokenReused) {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to refresh session",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// End the caller's own session
+func (h *SessionHandler) Logout(c *gin.Context) {
+	claims, ok := middleware.GetSession(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "request is not authenticated with a session token",
+		})
+		return
+	}
+
+	if _, err := h.sessionService.RevokeSession(claims.SessionID, claims.Actor, "logout"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to end session",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Logged out",
+	})
+}
+
+// List sessions, optionally filtered by actor and active state
+func (h *SessionHandler) GetSessions(c *gin.Context) {
+	sessions, err := h.sessionService.GetSessions(c.Query("actor"), c.Query("active") == "true")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to fetch sessions",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"sessions": sessions,
+		"count":    len(sessions),
+	})
+}
+
+// Revoke a session and all of its tokens
+func (h *SessionHandler) RevokeSession(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid session id",
+		})
+		return
+	}
+
+	var req RevokeRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid request body",
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	session, err := h.sessionService.RevokeSession(uint(id), middleware.GetActor(c), req.Reason)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Session not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to revoke session",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Session revoked",
+		"session": session,
+	})
+}
+
+// Revoke every active session of an actor
+func (h *SessionHandler) RevokeActorSessions(c *gin.Context) {
+	var req RevokeActorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	count, err := h.sessionService.RevokeActorSessions(req.Actor, middleware.GetActor(c), req.Reason)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to revoke sessions",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Sessions revoked",
+		"revoked": count,
+	})
+}
+
+// Blacklist a single access token
+func (h *SessionHandler) RevokeToken(c *gin.Context) {
+	var req RevokeTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := h.sessionService.RevokeAccessToken(req.Token, middleware.GetActor(c), req.Reason); err != nil {
+		if errors.Is(err, services.ErrInvalidToken) || errors.Is(err, services.ErrTokenExpired) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to revoke token",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Token revoked",
+	})
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+	"vessel-tracker/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ShadowHandler struct {
+	shadowDetector *services.ShadowDetector
+	parks          *services.ParkRegistry
+}
+
+func NewShadowHandler(shadowDetector *services.ShadowDetector, parks *services.ParkRegistry) *ShadowHandler {
+	return &ShadowHandler{
+		shadowDetector: shadowDetector,
+		parks:          parks,
+	}
+}
+
+// GetShadowReport compares the shadow detection logic with the live logic
+// over a period, defaulting to the last 7 days, with the most recent
+// divergences
+func (h *ShadowHandler) GetShadowReport(c *gin.Context) {
+	park, ok := resolvePark(c, h.parks)
+	if !ok {
+		return
+	}
+
+	start, end, ok := parseTimeRange(c, "start", "end", 7*24*time.Hour)
+	if !ok {
+		return
+	}
+
+	limit := 100
+	if limitStr := c.Query("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed <= 0 || parsed > 1000 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid limit parameter, must be between 1 and 1000",
+			})
+			return
+		}
+		limit = parsed
+	}
+
+	report, err := h.shadowDetector.Report(park, start, end, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to build shadow report",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, redact(c, report))
+}
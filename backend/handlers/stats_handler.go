@@ -0,0 +1,321 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+	"vessel-tracker/models"
+	"vessel-tracker/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type StatsHandler struct {
+	statsService *services.StatsService
+	aggregates   *services.DailyAggregateService
+	parks        *services.ParkRegistry
+}
+
+func NewStatsHandler(statsService *services.StatsService, aggregates *services.DailyAggregateService, parks *services.ParkRegistry) *StatsHandler {
+	return &StatsHandler{
+		statsService: statsService,
+		aggregates:   aggregates,
+		parks:        parks,
+	}
+}
+
+// GetDailyAggregates returns the finalized daily aggregates of a park, which
+// outlive the positions they summarize, over the last 30 days by default
+func (h *StatsHandler) GetDailyAggregates(c *gin.Context) {
+	park, ok := resolvePark(c, h.parks)
+	if !ok {
+		return
+	}
+
+	startTime, endTime, ok := parseTimeRange(c, "start", "end", 30*24*time.Hour)
+	if !ok {
+		return
+	}
+
+	days, err := h.aggregates.GetDailyAggregates(park.Record.ID, startTime, endTime)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to fetch daily aggregates",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"park":  park.Record.Slug,
+		"days":  days,
+		"count": len(days),
+		"start": startTime,
+		"end":   endTime,
+	})
+}
+
+// GetDwellTime returns per-vessel time spent inside a park and its buffer zone
+func (h *StatsHandler) GetDwellTime(c *gin.Context) {
+	park, ok := resolvePark(c, h.parks)
+	if !ok {
+		return
+	}
+
+	startTime, endTime, ok := parseTimeRange(c, "start", "end", 7*24*time.Hour)
+	if !ok {
+		return
+	}
+
+	sortBy := c.DefaultQuery("sort", "park")
+	if sortBy != "park" && sortBy != "buffer" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "sort must be park or buffer",
+		})
+		return
+	}
+
+	limit := 0
+	if limitStr := c.Query("limit"); limitStr != "" {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid limit parameter",
+			})
+			return
+		}
+	}
+
+	dwellTimes, err := h.statsService.GetDwellTimes(park, startTime, endTime, sortBy, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to compute dwell times",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"park":    park.Record.Slug,
+		"vessels": dwellTimes,
+		"count":   len(dwellTimes),
+		"start":   startTime,
+		"end":     endTime,
+		"sort":    sortBy,
+	})
+}
+
+// GetPeakComparison compares the traffic and violations of a park on peak
+// days of the calendar with those of off-peak days, over the last 365 days by
+// default
+func (h *StatsHandler) GetPeakComparison(c *gin.Context) {
+	park, ok := resolvePark(c, h.parks)
+	if !ok {
+		return
+	}
+
+	startTime, endTime, ok := parseTimeRange(c, "start", "end", 365*24*time.Hour)
+	if !ok {
+		return
+	}
+	if endTime.Sub(startTime) > 2*366*24*time.Hour {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "the comparison covers at most two years",
+		})
+		return
+	}
+
+	comparison, err := h.statsService.ComparePeak(park, startTime, endTime)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to compare peak and off-peak days",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"park":     park.Record.Slug,
+		"start":    comparison.Start,
+		"end":      comparison.End,
+		"peak":     comparison.Peak,
+		"off_peak": comparison.OffPeak,
+		"days":     comparison.Days,
+		"calendar": h.statsService.Calendar().Config(),
+	})
+}
+
+// GetMovements returns the entries into and exits from a park on one UTC day
+// (today by default), in chronological order
+func (h *StatsHandler) GetMovements(c *gin.Context) {
+	park, ok := resolvePark(c, h.parks)
+	if !ok {
+		return
+	}
+
+	start := time.Now().UTC().Truncate(24 * time.Hour)
+	if dateStr := c.Query("date"); dateStr != "" {
+		parsed, err := time.Parse("2006-01-02", dateStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid date format, use YYYY-MM-DD",
+			})
+			return
+		}
+		start = parsed
+	}
+	end := start.Add(24 * time.Hour)
+
+	movements, err := h.statsService.GetMovements(park, start, end)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to build movement log",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	entries, exits := 0, 0
+	for _, movement := range movements {
+		if movement.Kind == models.MovementEntry {
+			entries++
+		} else {
+			exits++
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"park":      park.Record.Slug,
+		"date":      start.Format("2006-01-02"),
+		"movements": movements,
+		"count":     len(movements),
+		"entries":   entries,
+		"exits":     exits,
+	})
+}
+
+// GetHeatmap returns position density per grid cell over a park, as a list
+// of cells or a GeoJSON FeatureCollection of cell polygons when format=geojson
+func (h *StatsHandler) GetHeatmap(c *gin.Context) {
+	park, ok := resolvePark(c, h.parks)
+	if !ok {
+		return
+	}
+
+	startTime, endTime, ok := parseTimeRange(c, "start", "end", 7*24*time.Hour)
+	if !ok {
+		return
+	}
+
+	cellSize := 500.0
+	if cellStr := c.Query("cell_size"); cellStr != "" {
+		var err error
+		cellSize, err = strconv.ParseFloat(cellStr, 64)
+		if err != nil || cellSize < 50 || cellSize > 10000 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "cell_size must be between 50 and 10000 meters",
+			})
+			return
+		}
+	}
+
+	cells, err := h.statsService.GetHeatmap(c.Request.Context(), park, startTime, endTime, cellSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to compute heatmap",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if c.Query("format") == "geojson" {
+		features := make([]gin.H, 0, len(cells))
+		for _, cell := range cells {
+			features = append(features, gin.H{
+				"type": "Feature",
+				"geometry": gin.H{
+					"type": "Polygon",
+					"coordinates": [][][]float64{{
+						{cell.MinLon, cell.MinLat},
+						{cell.MaxLon, cell.MinLat},
+						{cell.MaxLon, cell.MaxLat},
+						{cell.MinLon, cell.MaxLat},
+						{cell.MinLon, cell.MinLat},
+					}},
+				},
+				"properties": gin.H{
+					"count":       cell.Count,
+					"vessels":     cell.Vessels,
+					"in_park_pct": cell.InParkPct,
+				},
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"type":     "FeatureCollection",
+			"features": features,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"park":             park.Record.Slug,
+		"cells":            cells,
+		"count":            len(cells),
+		"cell_size_meters": cellSize,
+		"start":            startTime,
+		"end":              endTime,
+	})
+}
+
+// GetOccupancy returns a time series of the number of vessels in a park and
+// its buffer zone per interval (1h by default), broken down by vessel type,
+// over the last 7 days by default
+func (h *StatsHandler) GetOccupancy(c *gin.Context) {
+	park, ok := resolvePark(c, h.parks)
+	if !ok {
+		return
+	}
+
+	startTime, endTime, ok := parseTimeRange(c, "start", "end", 7*24*time.Hour)
+	if !ok {
+		return
+	}
+
+	interval := time.Hour
+	if value := c.Query("interval"); value != "" {
+		var err error
+		interval, err = time.ParseDuration(value)
+		if err != nil || interval < 5*time.Minute {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid interval parameter, use a duration of at least 5m such as 1h or 24h",
+			})
+			return
+		}
+	}
+	if endTime.Sub(startTime.UTC().Truncate(interval)) > time.Duration(services.MaxOccupancyIntervals)*interval {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "too many intervals, use a longer interval or a shorter period",
+		})
+		return
+	}
+
+	series, err := h.statsService.GetOccupancy(park, startTime, endTime, interval)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to compute zone occupancy",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"park":      park.Record.Slug,
+		"start":     startTime,
+		"end":       endTime,
+		"interval":  interval.String(),
+		"intervals": series,
+		"count":     len(series),
+	})
+}
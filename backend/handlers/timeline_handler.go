@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+	"vessel-tracker/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type TimelineHandler struct {
+	timelineService *services.TimelineService
+}
+
+func NewTimelineHandler(timelineService *services.TimelineService) *TimelineHandler {
+	return &TimelineHandler{
+		timelineService: timelineService,
+	}
+}
+
+// Get the ordered record of everything known around a violation for its case
+// file, with the vessel's track within window (2h by default) of the incident
+func (h *TimelineHandler) GetViolationTimeline(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid violation id",
+		})
+		return
+	}
+
+	window := services.DefaultTimelineWindow
+	if value := c.Query("window"); value != "" {
+		window, err = time.ParseDuration(value)
+		if err != nil || window <= 0 || window > services.MaxTimelineWindow {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid window parameter, use a positive duration of at most 24h such as 90m",
+			})
+			return
+		}
+	}
+
+	timeline, err := h.timelineService.GetTimeline(uint(id), window)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Violation not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to build incident timeline",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, timeline)
+}
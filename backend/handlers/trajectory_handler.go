@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"net/http"
+	"vessel-tracker/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TrajectoryHandler struct {
+	trajectoryService *services.TrajectoryService
+	parks             *services.ParkRegistry
+}
+
+func NewTrajectoryHandler(trajectoryService *services.TrajectoryService, parks *services.ParkRegistry) *TrajectoryHandler {
+	return &TrajectoryHandler{
+		trajectoryService: trajectoryService,
+		parks:             parks,
+	}
+}
+
+// Get a vessel's track projected forward from its latest position in a park,
+// with where and when it would enter the park holding its speed and course
+func (h *TrajectoryHandler) GetProjection(c *gin.Context) {
+	park, ok := resolvePark(c, h.parks)
+	if !ok {
+		return
+	}
+
+	projection, err := h.trajectoryService.GetProjection(c.Request.Context(), park, c.Param("uuid"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to project vessel track",
+			"details": err.Error(),
+		})
+		return
+	}
+	if projection == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "No position recorded for the vessel in park " + park.Record.Slug,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, projection)
+}
@@ -1,31 +1,56 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
+	"vessel-tracker/middleware"
 	"vessel-tracker/models"
 	"vessel-tracker/services"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type VesselHandler struct {
 	vesselService    *services.VesselService
-	geoService       *services.GeoService
+	parks            *services.ParkRegistry
 	vesselRepo       *services.VesselRepository
 	whitelistService *services.WhitelistService
+	violationService *services.ViolationService
+	scheduler        *services.SchedulerService
 }
 
-func NewVesselHandler(vesselService *services.VesselService, geoService *services.GeoService, vesselRepo *services.VesselRepository, whitelistService *services.WhitelistService) *VesselHandler {
+func NewVesselHandler(vesselService *services.VesselService, parks *services.ParkRegistry, vesselRepo *services.VesselRepository, whitelistService *services.WhitelistService, violationService *services.ViolationService, scheduler *services.SchedulerService) *VesselHandler {
 	return &VesselHandler{
 		vesselService:    vesselService,
-		geoService:       geoService,
+		parks:            parks,
 		vesselRepo:       vesselRepo,
 		whitelistService: whitelistService,
+		violationService: violationService,
+		scheduler:        scheduler,
 	}
 }
 
+// vesselProfileViolationDays is the period covered by the violation summary
+// of the vessel profile
+const vesselProfileViolationDays = 30
+
+// whitelistInfo summarizes a whitelist entry, hiding staff details from
+// requesters below the ranger role
+func whitelistInfo(c *gin.Context, entry *models.WhitelistEntry) gin.H {
+	info := gin.H{
+		"reason": entry.Reason,
+	}
+	if middleware.HasRole(c, middleware.RoleRanger) {
+		info["added_by"] = entry.AddedBy
+	}
+	return info
+}
+
 func (h *VesselHandler) GetVessels(c *gin.Context) {
 	// Get query parameters
 	params := make(map[string]string)
@@ -50,7 +75,10 @@ func (h *VesselHandler) GetVessels(c *gin.Context) {
 		}
 	}
 
-	vessels, err := h.vesselService.GetAllVessels(params, maxResults)
+	vessels, err := h.vesselService.GetAllVessels(c.Request.Context(), params, maxResults)
+	if respondQuotaError(c, h.vesselService, err) {
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to fetch vessels",
@@ -60,17 +88,24 @@ func (h *VesselHandler) GetVessels(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"vessels": vessels,
-		"count":   len(vessels),
+		"vessels":    vessels,
+		"count":      len(vessels),
+		"data_as_of": time.Now(),
+		"source":     h.vesselService.ProviderName(),
 	})
 }
 
 func (h *VesselHandler) GetVesselsInPark(c *gin.Context) {
+	park, ok := resolvePark(c, h.parks)
+	if !ok {
+		return
+	}
+
 	// Get park center coordinates
-	centerLat, centerLon := h.geoService.GetParkCenter()
+	centerLat, centerLon := park.Geo.GetParkCenter()
 
 	// Get latest vessel positions from database
-	positions, err := h.vesselRepo.GetLatestVesselPositions()
+	positions, err := h.vesselRepo.GetLatestVesselPositions(c.Request.Context(), park.Record.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to fetch vessel positions from database",
@@ -81,7 +116,8 @@ func (h *VesselHandler) GetVesselsInPark(c *gin.Context) {
 
 	// If no data in database, try to fetch from API as fallback
 	if len(positions) == 0 {
-		vesselPositions, apiErr := h.vesselService.GetVesselsInRadius(centerLat, centerLon, 20)
+		fetchedAt := time.Now()
+		vesselPositions, apiErr := h.vesselService.GetVesselsInRadius(c.Request.Context(), centerLat, centerLon, 20)
 		if apiErr != nil {
 			// No data available anywhere, return demo data
 			demoVessels := []gin.H{
@@ -130,6 +166,8 @@ func (h *VesselHandler) GetVesselsInPark(c *gin.Context) {
 					"latitude":  centerLat,
 					"longitude": centerLon,
 				},
+				"data_as_of": nil,
+				"source":     services.ResponseSourceDemo,
 			})
 			return
 		}
@@ -137,14 +175,14 @@ func (h *VesselHandler) GetVesselsInPark(c *gin.Context) {
 		// Process API data directly
 		var vesselsFromAPI []gin.H
 		for _, vesselPos := range vesselPositions.Data.Vessels {
-			isInPark := h.geoService.IsPointInPark(vesselPos.Latitude, vesselPos.Longitude)
+			isInPark := park.Geo.IsPointInPark(vesselPos.Latitude, vesselPos.Longitude)
 
 			// Skip vessels that are not in the park - only return vessels within park boundaries
 			if !isInPark {
 				continue
 			}
 
-			isInBufferZone := h.geoService.IsPointInBufferZone(vesselPos.Latitude, vesselPos.Longitude)
+			isInBufferZone := park.Geo.IsPointInBufferZone(vesselPos.Latitude, vesselPos.Longitude)
 
 			// Check if vessel is whitelisted
 			isWhitelisted := h.whitelistService.IsVesselWhitelisted(vesselPos.UUID, vesselPos.MMSI, vesselPos.IMO)
@@ -174,22 +212,23 @@ func (h *VesselHandler) GetVesselsInPark(c *gin.Context) {
 			}
 
 			if whitelistEntry != nil {
-				vesselData["whitelist_info"] = gin.H{
-					"reason":   whitelistEntry.Reason,
-					"added_by": whitelistEntry.AddedBy,
-				}
+				vesselData["whitelist_info"] = whitelistInfo(c, whitelistEntry)
 			}
 
 			vesselsFromAPI = append(vesselsFromAPI, vesselData)
 		}
 
 		c.JSON(http.StatusOK, gin.H{
+			"park":            park.Record.Slug,
 			"vessels_in_park": vesselsFromAPI,
 			"total_in_park":   len(vesselsFromAPI),
 			"park_center": gin.H{
 				"latitude":  centerLat,
 				"longitude": centerLon,
 			},
+			"buffer_zone_available": park.Geo.BufferZoneAvailable(),
+			"data_as_of":            fetchedAt,
+			"source":                h.vesselService.ProviderName(),
 		})
 		return
 	}
@@ -197,7 +236,7 @@ func (h *VesselHandler) GetVesselsInPark(c *gin.Context) {
 	// Process database data - vessels are already filtered to only include those in park
 	var vesselsInPark []gin.H
 	for _, pos := range positions {
-		isInBufferZone := h.geoService.IsPointInBufferZone(pos.Latitude, pos.Longitude)
+		isInBufferZone := park.Geo.IsPointInBufferZone(pos.Latitude, pos.Longitude)
 
 		// Check if vessel is whitelisted
 		isWhitelisted := h.whitelistService.IsVesselWhitelisted(pos.VesselUUID, pos.Vessel.MMSI, pos.Vessel.IMO)
@@ -227,27 +266,33 @@ func (h *VesselHandler) GetVesselsInPark(c *gin.Context) {
 		}
 
 		if whitelistEntry != nil {
-			vesselData["whitelist_info"] = gin.H{
-				"reason":   whitelistEntry.Reason,
-				"added_by": whitelistEntry.AddedBy,
-			}
+			vesselData["whitelist_info"] = whitelistInfo(c, whitelistEntry)
 		}
 
 		vesselsInPark = append(vesselsInPark, vesselData)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
+		"park":            park.Record.Slug,
 		"vessels_in_park": vesselsInPark,
 		"total_in_park":   len(vesselsInPark),
 		"park_center": gin.H{
 			"latitude":  centerLat,
 			"longitude": centerLon,
 		},
+		"buffer_zone_available": park.Geo.BufferZoneAvailable(),
+		"data_as_of":            h.scheduler.DataAsOf(c.Request.Context(), park),
+		"source":                services.ResponseSourceDatabase,
 	})
 }
 
 func (h *VesselHandler) GetParkBoundaries(c *gin.Context) {
-	boundaries, err := h.geoService.GetParkBoundaries()
+	park, ok := resolvePark(c, h.parks)
+	if !ok {
+		return
+	}
+
+	boundaries, err := park.Geo.GetParkBoundaries()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to get park boundaries",
@@ -255,11 +300,16 @@ func (h *VesselHandler) GetParkBoundaries(c *gin.Context) {
 		return
 	}
 
-	c.Data(http.StatusOK, "application/json", boundaries)
+	servePayload(c, boundaries)
 }
 
 func (h *VesselHandler) GetBufferedBoundaries(c *gin.Context) {
-	boundaries, err := h.geoService.GetBufferedBoundaries()
+	park, ok := resolvePark(c, h.parks)
+	if !ok {
+		return
+	}
+
+	boundaries, err := park.Geo.GetBufferedBoundaries()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to get buffered boundaries",
@@ -267,10 +317,68 @@ func (h *VesselHandler) GetBufferedBoundaries(c *gin.Context) {
 		return
 	}
 
-	c.Data(http.StatusOK, "application/json", boundaries)
+	servePayload(c, boundaries)
 }
 
+// servePayload writes a pre-serialized payload, gzipped when the client
+// accepts it. Clients revalidate with the ETag or Last-Modified, which change
+// whenever the payload is rebuilt from a replaced or modified source.
+func servePayload(c *gin.Context, payload *services.StaticPayload) {
+	c.Header("ETag", payload.ETag)
+	c.Header("Last-Modified", payload.LastModified.Format(http.TimeFormat))
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Vary", "Accept-Encoding")
+
+	if notModified(c, payload.ETag, payload.LastModified) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	if acceptsGzip(c.GetHeader("Accept-Encoding")) {
+		c.Header("Content-Encoding", "gzip")
+		c.Data(http.StatusOK, "application/json", payload.Gzip)
+		return
+	}
+
+	c.Data(http.StatusOK, "application/json", payload.JSON)
+}
+
+// notModified evaluates If-None-Match, or If-Modified-Since when no ETag is
+// given, as RFC 9110 orders them
+func notModified(c *gin.Context, etag string, lastModified time.Time) bool {
+	if match := c.GetHeader("If-None-Match"); match != "" {
+		return match == "*" || strings.Contains(match, etag)
+	}
+	if since := c.GetHeader("If-Modified-Since"); since != "" {
+		t, err := http.ParseTime(since)
+		return err == nil && !lastModified.After(t)
+	}
+	return false
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip, honoring
+// an explicit q=0 refusal
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			continue
+		}
+		q := strings.ReplaceAll(strings.TrimSpace(params), " ", "")
+		return q != "q=0" && q != "q=0.0" && q != "q=0.00" && q != "q=0.000"
+	}
+	return false
+}
+
+// GetVesselsAtTime returns the last stored fix of each vessel at or before
+// the timestamp. With interpolate=true, vessels with a fix on either side no
+// more than max_gap apart are placed between the two instead.
 func (h *VesselHandler) GetVesselsAtTime(c *gin.Context) {
+	park, ok := resolvePark(c, h.parks)
+	if !ok {
+		return
+	}
+
 	timestampStr := c.Query("timestamp")
 	if timestampStr == "" {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -287,7 +395,29 @@ func (h *VesselHandler) GetVesselsAtTime(c *gin.Context) {
 		return
 	}
 
-	positions, err := h.vesselRepo.GetVesselPositionsAtTime(timestamp)
+	interpolate := false
+	if value := c.Query("interpolate"); value != "" {
+		interpolate, err = strconv.ParseBool(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid interpolate parameter, use true or false",
+			})
+			return
+		}
+	}
+
+	maxGap := services.DefaultInterpolationMaxGap
+	if value := c.Query("max_gap"); value != "" {
+		maxGap, err = time.ParseDuration(value)
+		if err != nil || maxGap <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid max_gap parameter, use a positive duration such as 90m",
+			})
+			return
+		}
+	}
+
+	positions, err := h.vesselRepo.GetVesselPositionsAtTime(c.Request.Context(), park.Record.ID, timestamp)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to fetch vessel positions",
@@ -296,8 +426,29 @@ func (h *VesselHandler) GetVesselsAtTime(c *gin.Context) {
 		return
 	}
 
+	// The fixes after the timestamp are only needed to interpolate
+	var next map[string]models.VesselPositionRecord
+	if interpolate {
+		vesselUUIDs := make([]string, 0, len(positions))
+		for _, pos := range positions {
+			vesselUUIDs = append(vesselUUIDs, pos.VesselUUID)
+		}
+		next, err = h.vesselRepo.GetNextVesselPositions(c.Request.Context(), park.Record.ID, vesselUUIDs, timestamp, timestamp.Add(maxGap))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to fetch vessel positions",
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
 	var vessels []gin.H
-	for _, pos := range positions {
+	for _, pos := range services.PlacePositions(park.Geo, positions, next, timestamp, maxGap) {
+		fixTime := pos.LastPosUTC
+		if pos.Interpolated {
+			fixTime = timestamp.UTC().Format(time.RFC3339)
+		}
 		vesselData := gin.H{
 			"vessel": gin.H{
 				"uuid":         pos.VesselUUID,
@@ -313,22 +464,32 @@ func (h *VesselHandler) GetVesselsAtTime(c *gin.Context) {
 				"destination":  pos.Destination,
 				"distance":     pos.Distance,
 			},
-			"latitude":   pos.Latitude,
-			"longitude":  pos.Longitude,
-			"is_in_park": pos.IsInPark,
-			"timestamp":  pos.LastPosUTC,
+			"latitude":             pos.Latitude,
+			"longitude":            pos.Longitude,
+			"is_in_park":           pos.IsInPark,
+			"timestamp":            fixTime,
+			"position_age_seconds": int64(pos.Age.Round(time.Second) / time.Second),
+			"interpolated":         pos.Interpolated,
 		}
 		vessels = append(vessels, vesselData)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"vessels":   vessels,
-		"count":     len(vessels),
-		"timestamp": timestampStr,
+		"park":       park.Record.Slug,
+		"vessels":    vessels,
+		"count":      len(vessels),
+		"timestamp":  timestampStr,
+		"data_as_of": h.scheduler.DataAsOf(c.Request.Context(), park),
+		"source":     services.ResponseSourceDatabase,
 	})
 }
 
 func (h *VesselHandler) GetVesselsInParkAtTime(c *gin.Context) {
+	park, ok := resolvePark(c, h.parks)
+	if !ok {
+		return
+	}
+
 	timestampStr := c.Query("timestamp")
 	if timestampStr == "" {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -345,7 +506,7 @@ func (h *VesselHandler) GetVesselsInParkAtTime(c *gin.Context) {
 		return
 	}
 
-	positions, err := h.vesselRepo.GetVesselsInParkAtTime(timestamp)
+	positions, err := h.vesselRepo.GetVesselsInParkAtTime(c.Request.Context(), park.Record.ID, timestamp)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to fetch vessel positions",
@@ -379,9 +540,10 @@ func (h *VesselHandler) GetVesselsInParkAtTime(c *gin.Context) {
 		vessels = append(vessels, vesselData)
 	}
 
-	centerLat, centerLon := h.geoService.GetParkCenter()
+	centerLat, centerLon := park.Geo.GetParkCenter()
 
 	c.JSON(http.StatusOK, gin.H{
+		"park":            park.Record.Slug,
 		"vessels_in_park": vessels,
 		"total_in_park":   len(vessels),
 		"timestamp":       timestampStr,
@@ -389,11 +551,96 @@ func (h *VesselHandler) GetVesselsInParkAtTime(c *gin.Context) {
 			"latitude":  centerLat,
 			"longitude": centerLon,
 		},
+		"data_as_of": h.scheduler.DataAsOf(c.Request.Context(), park),
+		"source":     services.ResponseSourceDatabase,
 	})
 }
 
+// GetVessel returns a vessel's profile: its stored record, latest position
+// with zone flags, whitelist status and recent violation counts
+func (h *VesselHandler) GetVessel(c *gin.Context) {
+	park, ok := resolvePark(c, h.parks)
+	if !ok {
+		return
+	}
+
+	vesselUUID := c.Param("uuid")
+
+	vessel, err := h.vesselRepo.GetVessel(c.Request.Context(), vesselUUID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Vessel not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to fetch vessel",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	position, err := h.vesselRepo.GetLatestPosition(c.Request.Context(), park.Record.ID, vesselUUID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to fetch latest position",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	since := time.Now().AddDate(0, 0, -vesselProfileViolationDays)
+	violations, err := h.violationService.GetVesselViolationSummary(vesselUUID, since)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to count violations",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	var latestPosition gin.H
+	if position != nil {
+		latestPosition = gin.H{
+			"latitude":          position.Latitude,
+			"longitude":         position.Longitude,
+			"speed":             position.Speed,
+			"course":            position.Course,
+			"heading":           position.Heading,
+			"destination":       position.Destination,
+			"distance":          position.Distance,
+			"is_in_park":        position.IsInPark,
+			"is_in_buffer_zone": park.Geo.IsPointInBufferZone(position.Latitude, position.Longitude),
+			"timestamp":         position.LastPosUTC,
+			"recorded_at":       position.RecordedAt,
+		}
+	}
+
+	response := gin.H{
+		"park":                  park.Record.Slug,
+		"vessel":                redact(c, vessel),
+		"latest_position":       latestPosition,
+		"is_whitelisted":        false,
+		"violations":            violations,
+		"buffer_zone_available": park.Geo.BufferZoneAvailable(),
+	}
+
+	if entry := h.whitelistService.GetWhitelistEntry(vessel.UUID, vessel.MMSI, vessel.IMO); entry != nil {
+		response["is_whitelisted"] = true
+		response["whitelist_info"] = whitelistInfo(c, entry)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
 // GetPreviousPositions returns previous positions from local database (renamed from GetVesselHistory)
 func (h *VesselHandler) GetPreviousPositions(c *gin.Context) {
+	park, ok := resolvePark(c, h.parks)
+	if !ok {
+		return
+	}
+
 	vesselUUID := c.Param("uuid")
 	if vesselUUID == "" {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -445,7 +692,7 @@ func (h *VesselHandler) GetPreviousPositions(c *gin.Context) {
 		}
 	}
 
-	positions, err := h.vesselRepo.GetVesselHistory(vesselUUID, startTime, endTime, limit)
+	positions, err := h.vesselRepo.GetVesselHistory(c.Request.Context(), park.Record.ID, vesselUUID, startTime, endTime, limit)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to fetch previous positions",
@@ -482,8 +729,98 @@ func (h *VesselHandler) GetPreviousPositions(c *gin.Context) {
 	})
 }
 
-// GetVesselHistoricalData fetches historical data from Datalastic API
+// ExportVesselHistory returns the stored track of a vessel as a GPX
+// (default) or KML file, over the last 7 days unless start_time and end_time
+// say otherwise
+func (h *VesselHandler) ExportVesselHistory(c *gin.Context) {
+	park, ok := resolvePark(c, h.parks)
+	if !ok {
+		return
+	}
+
+	format := c.DefaultQuery("format", services.TrackFormatGPX)
+	if format != services.TrackFormatGPX && format != services.TrackFormatKML {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "format must be gpx or kml",
+		})
+		return
+	}
+
+	startTime, endTime, ok := parseTimeRange(c, "start_time", "end_time", 7*24*time.Hour)
+	if !ok {
+		return
+	}
+
+	limit := services.DefaultTrackPoints
+	if limitStr := c.Query("limit"); limitStr != "" {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 || limit > services.MaxTrackPoints {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("limit must be between 1 and %d", services.MaxTrackPoints),
+			})
+			return
+		}
+	}
+
+	vesselUUID := c.Param("uuid")
+	vessel, err := h.vesselRepo.GetVessel(c.Request.Context(), vesselUUID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Vessel not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to fetch vessel",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	positions, err := h.vesselRepo.GetVesselHistory(c.Request.Context(), park.Record.ID, vesselUUID, startTime, endTime, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to fetch previous positions",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	track := services.NewTrack(park, vessel, positions)
+	if privacy := middleware.GetPositionPrivacy(c); privacy != nil {
+		privacy.FuzzTrack(track, vessel, time.Now())
+	}
+	var data []byte
+	var contentType string
+	if format == services.TrackFormatKML {
+		data, err = services.RenderTrackKML(track)
+		contentType = "application/vnd.google-earth.kml+xml"
+	} else {
+		data, err = services.RenderTrackGPX(track)
+		contentType = "application/gpx+xml"
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to export track",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	filename := fmt.Sprintf("%s-%s.%s", vesselUUID, startTime.UTC().Format("20060102"), format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// GetVesselHistoricalData fetches historical data from the vessel data provider
 func (h *VesselHandler) GetVesselHistoricalData(c *gin.Context) {
+	park, ok := resolvePark(c, h.parks)
+	if !ok {
+		return
+	}
+
 	// Get vessel identifier (can be uuid, mmsi, or imo)
 	uuid := c.Query("uuid")
 	mmsi := c.Query("mmsi")
@@ -496,7 +833,7 @@ func (h *VesselHandler) GetVesselHistoricalData(c *gin.Context) {
 		return
 	}
 
-	// Build parameters for Datalastic API
+	// Build parameters in the Datalastic format all providers accept
 	params := make(map[string]string)
 	if uuid != "" {
 		params["uuid"] = uuid
@@ -523,11 +860,21 @@ func (h *VesselHandler) GetVesselHistoricalData(c *gin.Context) {
 		params["days"] = "2"
 	}
 
-	// Fetch from Datalastic API
-	historyResp, err := h.vesselService.GetVesselHistoryFromAPI(params)
+	// Fetch from the vessel data provider
+	historyResp, err := h.vesselService.GetVesselHistory(c.Request.Context(), params)
+	if errors.Is(err, services.ErrProviderUnsupported) {
+		c.JSON(http.StatusNotImplemented, gin.H{
+			"error":   "The vessel data provider does not serve historical data",
+			"details": err.Error(),
+		})
+		return
+	}
+	if respondQuotaError(c, h.vesselService, err) {
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to fetch historical data from Datalastic",
+			"error": "Failed to fetch historical data from the vessel data provider",
 			"details": err.Error(),
 		})
 		return
@@ -535,7 +882,15 @@ func (h *VesselHandler) GetVesselHistoricalData(c *gin.Context) {
 
 	// Store the historical positions in database for future use
 	if historyResp.Data.UUID != "" && len(historyResp.Data.Positions) > 0 {
-		// Store vessel info if not exists
+		var lastSeenEpoch int64
+		for _, pos := range historyResp.Data.Positions {
+			if pos.LastPositionEpoch > lastSeenEpoch {
+				lastSeenEpoch = pos.LastPositionEpoch
+			}
+		}
+		lastSeenAt := time.Unix(lastSeenEpoch, 0)
+
+		// Store vessel info, refreshing it if the vessel is already known
 		vessel := &models.VesselRecord{
 			UUID:         historyResp.Data.UUID,
 			Name:         historyResp.Data.Name,
@@ -545,19 +900,21 @@ func (h *VesselHandler) GetVesselHistoricalData(c *gin.Context) {
 			CountryISO:   historyResp.Data.CountryISO,
 			Type:         historyResp.Data.Type,
 			TypeSpecific: historyResp.Data.TypeSpecific,
+			LastSeenAt:   &lastSeenAt,
 		}
 
 		// Store vessel (will update if exists)
-		err = h.vesselRepo.StoreVessel(vessel)
+		err = h.vesselRepo.StoreVessel(c.Request.Context(), vessel)
 		if err != nil {
 			// Log error but don't fail the request
-			println("Warning: Failed to store vessel:", err.Error())
+			middleware.Logger(c).Warn("Failed to store vessel", "vessel_uuid", vessel.UUID, "error", err)
 		}
 
 		// Store historical positions
 		for _, pos := range historyResp.Data.Positions {
 			positionRecord := &models.VesselPositionRecord{
 				VesselUUID:   historyResp.Data.UUID,
+				ParkID:       park.Record.ID,
 				Latitude:     pos.Latitude,
 				Longitude:    pos.Longitude,
 				Speed:        pos.Speed,
@@ -566,14 +923,14 @@ func (h *VesselHandler) GetVesselHistoricalData(c *gin.Context) {
 				Destination:  pos.Destination,
 				LastPosEpoch: pos.LastPositionEpoch,
 				LastPosUTC:   pos.LastPositionUTC,
-				IsInPark:     h.geoService.IsPointInPark(pos.Latitude, pos.Longitude),
+				IsInPark:     park.Geo.IsPointInPark(pos.Latitude, pos.Longitude),
 				RecordedAt:   time.Unix(pos.LastPositionEpoch, 0),
 			}
 
-			err = h.vesselRepo.StoreVesselPosition(positionRecord)
+			err = h.vesselRepo.StoreVesselPosition(c.Request.Context(), positionRecord)
 			if err != nil {
 				// Log error but continue storing other positions
-				println("Warning: Failed to store position:", err.Error())
+				middleware.Logger(c).Warn("Failed to store position", "vessel_uuid", positionRecord.VesselUUID, "error", err)
 			}
 		}
 	}
@@ -593,4 +950,67 @@ func (h *VesselHandler) GetVesselHistoricalData(c *gin.Context) {
 		"count":               len(historyResp.Data.Positions),
 		"source":              "datalastic",
 	})
-}
\ No newline at end of file
+}
+
+// GetKnownVessels lists every vessel ever observed with its last-seen time
+func (h *VesselHandler) GetKnownVessels(c *gin.Context) {
+	vessels, err := h.vesselRepo.GetKnownVessels(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to fetch known vessels",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"vessels":    redact(c, vessels),
+		"count":      len(vessels),
+		"data_as_of": h.scheduler.DataAsOf(c.Request.Context(), nil),
+		"source":     services.ResponseSourceDatabase,
+	})
+}
+
+// SearchLocalVessels looks up vessels already observed by name, MMSI prefix
+// and type in the stored records, without calling the provider
+func (h *VesselHandler) SearchLocalVessels(c *gin.Context) {
+	search := services.VesselSearch{
+		Name:  strings.TrimSpace(c.Query("name")),
+		MMSI:  strings.TrimSpace(c.Query("mmsi")),
+		Type:  strings.TrimSpace(c.Query("type")),
+		Limit: 50,
+	}
+	if search.Name == "" && search.MMSI == "" && search.Type == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "at least one of name, mmsi or type is required",
+		})
+		return
+	}
+
+	if limitStr := c.Query("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 || limit > 500 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "limit must be between 1 and 500",
+			})
+			return
+		}
+		search.Limit = limit
+	}
+
+	vessels, err := h.vesselRepo.SearchVessels(c.Request.Context(), search)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to search vessels",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"vessels":    redact(c, vessels),
+		"count":      len(vessels),
+		"data_as_of": h.scheduler.DataAsOf(c.Request.Context(), nil),
+		"source":     services.ResponseSourceDatabase,
+	})
+}
@@ -1,26 +1,37 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"math/rand"
 	"net/http"
+	"strconv"
 	"time"
+	"vessel-tracker/middleware"
 	"vessel-tracker/services"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type ViolationHandler struct {
-	vesselService *services.VesselService
-	geoService    *services.GeoService
-	vesselRepo    *services.VesselRepository
+	vesselService    *services.VesselService
+	parks            *services.ParkRegistry
+	vesselRepo       *services.VesselRepository
+	violationService *services.ViolationService
+	noticeService    *services.NoticeService
 }
 
-func NewViolationHandler(vesselService *services.VesselService, geoService *services.GeoService, vesselRepo *services.VesselRepository) *ViolationHandler {
+func NewViolationHandler(vesselService *services.VesselService, parks *services.ParkRegistry, vesselRepo *services.VesselRepository, violationService *services.ViolationService, noticeService *services.NoticeService) *ViolationHandler {
 	return &ViolationHandler{
-		vesselService: vesselService,
-		geoService:    geoService,
-		vesselRepo:    vesselRepo,
+		vesselService:    vesselService,
+		parks:            parks,
+		vesselRepo:       vesselRepo,
+		violationService: violationService,
+		noticeService:    noticeService,
 	}
 }
 
@@ -136,4 +147,371 @@ func (h *ViolationHandler) ClearTestViolations(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, response)
-}
\ No newline at end of file
+}
+
+// publicStreamPollInterval is how often the violation stream of the public
+// reads the violations that have become old enough for them
+const publicStreamPollInterval = 15 * time.Second
+
+// StreamViolations pushes new violations to the client as Server-Sent Events,
+// from every park unless the park parameter names one. Clients reconnecting
+// with a Last-Event-ID header receive any violations recorded since that ID
+// before switching to live events. The public is not sent live events: it
+// is sent the stored violations as they become older than the position
+// privacy delay, read with the advancing cutoff.
+func (h *ViolationHandler) StreamViolations(c *gin.Context) {
+	var parkID uint
+	if c.Query("park") != "" {
+		park, ok := resolvePark(c, h.parks)
+		if !ok {
+			return
+		}
+		parkID = park.Record.ID
+	}
+
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+
+	privacy := middleware.GetPositionPrivacy(c)
+	delayed := privacy != nil && privacy.Config().Delay > 0
+	storedContext := func() context.Context {
+		if delayed {
+			return privacy.PublicContext(c.Request.Context(), time.Now())
+		}
+		return c.Request.Context()
+	}
+
+	// Subscribe before replaying so nothing recorded in between is missed
+	var events <-chan services.ViolationEvent
+	var poll <-chan time.Time
+	if delayed {
+		ticker := time.NewTicker(publicStreamPollInterval)
+		defer ticker.Stop()
+		poll = ticker.C
+	} else {
+		subscribed, unsubscribe := h.violationService.Subscribe()
+		defer unsubscribe()
+		events = subscribed
+	}
+
+	var lastSent uint
+	replay := false
+	if id, err := strconv.ParseUint(lastEventID, 10, 64); err == nil {
+		lastSent = uint(id)
+		replay = true
+	} else if delayed {
+		// Start after the violations already old enough, so the ones still
+		// within the delay follow once they are
+		latest, err := h.violationService.GetLatestViolationID(storedContext())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to start violation stream",
+				"details": err.Error(),
+			})
+			return
+		}
+		lastSent = latest
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	if replay {
+		lastSent = h.writeStoredViolations(storedContext(), c.Writer, parkID, lastSent)
+	}
+	c.Writer.Flush()
+
+	keepAlive := time.NewTicker(30 * time.Second)
+	defer keepAlive.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case event, ok := <-events:
+			if !ok {
+				return false
+			}
+			if event.ID <= lastSent || (parkID != 0 && event.ParkID != parkID) {
+				return true
+			}
+			writeViolationEvent(w, event)
+			lastSent = event.ID
+			return true
+		case <-poll:
+			lastSent = h.writeStoredViolations(storedContext(), w, parkID, lastSent)
+			return true
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			return true
+		}
+	})
+}
+
+// writeStoredViolations writes the stored violations recorded after lastSent,
+// of the park when parkID is set, and returns the ID of the last one read
+func (h *ViolationHandler) writeStoredViolations(ctx context.Context, w io.Writer, parkID, lastSent uint) uint {
+	missed, err := h.violationService.GetViolationsSince(ctx, lastSent, 500)
+	if err != nil {
+		return lastSent
+	}
+	for i := range missed {
+		if parkID == 0 || missed[i].ParkID == parkID {
+			writeViolationEvent(w, services.NewViolationEvent(&missed[i]))
+		}
+		lastSent = missed[i].ID
+	}
+	return lastSent
+}
+
+func writeViolationEvent(w io.Writer, event services.ViolationEvent) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "id: %d\nevent: violation\ndata: %s\n\n", event.ID, data)
+}
+
+// GetViolationNotice renders a formal violation notice from the configured
+// template for the violation type, as PDF (default) or plain text. Notices
+// for parks with restricted exports are refused.
+func (h *ViolationHandler) GetViolationNotice(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid violation id",
+		})
+		return
+	}
+
+	format := c.DefaultQuery("format", "pdf")
+	if format != "pdf" && format != "txt" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "format must be pdf or txt",
+		})
+		return
+	}
+
+	violation, err := h.violationService.GetViolation(c.Request.Context(), uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Violation not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to fetch violation",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if park, ok := h.parks.GetByID(violation.ParkID); ok && !allowExport(c, park) {
+		return
+	}
+
+	notice, err := h.noticeService.RenderNotice(violation)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to generate notice",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	filename := fmt.Sprintf("violation-%d-notice.%s", violation.ID, format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	if format == "txt" {
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(notice))
+		return
+	}
+
+	c.Data(http.StatusOK, "application/pdf", services.RenderTextPDF("Violation notice", notice))
+}
+
+// GetViolationEvidence returns the evidence captured when a violation was
+// detected: the triggering position, the preceding position trail, the zone
+// polygons at that moment, the speed profile and the whitelist check
+func (h *ViolationHandler) GetViolationEvidence(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid violation id",
+		})
+		return
+	}
+
+	violation, err := h.violationService.GetViolation(c.Request.Context(), uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Violation not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to fetch violation",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if violation.Evidence == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "No evidence was captured for this violation",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"violation_id": violation.ID,
+		"vessel_uuid":  violation.VesselUUID,
+		"type":         violation.Type,
+		"detected_at":  violation.DetectedAt,
+		"evidence":     violation.Evidence,
+	})
+}
+
+// UpdateViolation triages a violation: it moves the violation to a new
+// status (acknowledged, dismissed as a false positive, escalated or fined)
+// and/or adds a note, recording the acting user in the violation's history
+func (h *ViolationHandler) UpdateViolation(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid violation id",
+		})
+		return
+	}
+
+	var req struct {
+		Status string `json:"status"`
+		Note   string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || (req.Status == "" && req.Note == "") {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "status or note is required",
+		})
+		return
+	}
+
+	violation, err := h.violationService.UpdateViolationStatus(uint(id), req.Status, req.Note, middleware.GetActor(c))
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Violation not found",
+			})
+		case errors.Is(err, services.ErrInvalidTransition):
+			c.JSON(http.StatusConflict, gin.H{
+				"error": err.Error(),
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to update violation",
+				"details": err.Error(),
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, redact(c, violation))
+}
+
+// GetViolationHistory returns the status history of a violation, oldest
+// first
+func (h *ViolationHandler) GetViolationHistory(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid violation id",
+		})
+		return
+	}
+
+	violation, err := h.violationService.GetViolation(c.Request.Context(), uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Violation not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to fetch violation",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	history, err := h.violationService.GetStatusHistory(services.ViolationHistoryFilter{ViolationID: violation.ID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to fetch violation history",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"violation_id": violation.ID,
+		"status":       violation.Status,
+		"history":      history,
+		"count":        len(history),
+	})
+}
+
+// GetStatusHistory returns the status changes of every violation, oldest
+// first, filtered by actor, status changed to and time (the last 7 days by
+// default)
+func (h *ViolationHandler) GetStatusHistory(c *gin.Context) {
+	filter := services.ViolationHistoryFilter{
+		Actor:  c.Query("actor"),
+		Status: c.Query("status"),
+		Since:  time.Now().Add(-7 * 24 * time.Hour),
+		Limit:  500,
+	}
+
+	if since := c.Query("since"); since != "" {
+		parsed, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid since format, use RFC3339",
+			})
+			return
+		}
+		filter.Since = parsed
+	}
+
+	if limitStr := c.Query("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid limit parameter",
+			})
+			return
+		}
+		filter.Limit = limit
+	}
+
+	history, err := h.violationService.GetStatusHistory(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to fetch violation history",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"history": history,
+		"count":   len(history),
+		"since":   filter.Since,
+	})
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"vessel-tracker/middleware"
+	"vessel-tracker/models"
+	"vessel-tracker/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type WatchlistHandler struct {
+	watchlistService *services.WatchlistService
+}
+
+func NewWatchlistHandler(watchlistService *services.WatchlistService) *WatchlistHandler {
+	return &WatchlistHandler{
+		watchlistService: watchlistService,
+	}
+}
+
+func (h *WatchlistHandler) writeWatchlistError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Watchlist entry not found",
+		})
+	case errors.Is(err, services.ErrWatchlistEntryExists):
+		c.JSON(http.StatusConflict, gin.H{
+			"error": err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   message,
+			"details": err.Error(),
+		})
+	}
+}
+
+func parseWatchlistID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid watchlist entry id",
+		})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// List watchlist entries, the removed ones too with include_inactive=true
+func (h *WatchlistHandler) GetWatchlist(c *gin.Context) {
+	includeInactive, _ := strconv.ParseBool(c.Query("include_inactive"))
+
+	entries, err := h.watchlistService.GetEntries(includeInactive)
+	if err != nil {
+		h.writeWatchlistError(c, err, "Failed to fetch watchlist")
+		return
+	}
+
+	config := h.watchlistService.Config()
+	c.JSON(http.StatusOK, gin.H{
+		"watchlist": redact(c, entries),
+		"count":     len(entries),
+		"automatic": gin.H{
+			"violations":  config.AutoViolations,
+			"window_days": int(config.AutoWindow.Hours() / 24),
+		},
+	})
+}
+
+// Get a single watchlist entry
+func (h *WatchlistHandler) GetWatchlistEntry(c *gin.Context) {
+	id, ok := parseWatchlistID(c)
+	if !ok {
+		return
+	}
+
+	entry, err := h.watchlistService.GetEntry(id)
+	if err != nil {
+		h.writeWatchlistError(c, err, "Failed to fetch watchlist entry")
+		return
+	}
+
+	c.JSON(http.StatusOK, redact(c, entry))
+}
+
+// Put a vessel on the watchlist
+func (h *WatchlistHandler) AddToWatchlist(c *gin.Context) {
+	var req struct {
+		VesselUUID string `json:"vessel_uuid"`
+		MMSI       string `json:"mmsi"`
+		IMO        string `json:"imo"`
+		Name       string `json:"name"`
+		Reason     string `json:"reason"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if req.VesselUUID == "" && req.MMSI == "" && req.IMO == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "At least one of vessel_uuid, mmsi, or imo must be provided",
+		})
+		return
+	}
+
+	if req.Reason == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Reason is required",
+		})
+		return
+	}
+
+	entry := &models.WatchlistEntry{
+		VesselUUID: req.VesselUUID,
+		MMSI:       req.MMSI,
+		IMO:        req.IMO,
+		Name:       req.Name,
+		Reason:     req.Reason,
+		Source:     models.WatchlistSourceManual,
+		AddedBy:    middleware.GetActor(c),
+	}
+	if err := h.watchlistService.AddEntry(entry); err != nil {
+		h.writeWatchlistError(c, err, "Failed to add vessel to watchlist")
+		return
+	}
+
+	c.JSON(http.StatusCreated, entry)
+}
+
+// Change the name or reason of a watchlist entry, or reactivate it
+func (h *WatchlistHandler) UpdateWatchlistEntry(c *gin.Context) {
+	id, ok := parseWatchlistID(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Name     *string `json:"name"`
+		Reason   *string `json:"reason"`
+		IsActive *bool   `json:"is_active"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if req.Reason != nil && *req.Reason == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Reason cannot be empty",
+		})
+		return
+	}
+
+	entry, err := h.watchlistService.UpdateEntry(id, req.Name, req.Reason, req.IsActive)
+	if err != nil {
+		h.writeWatchlistError(c, err, "Failed to update watchlist entry")
+		return
+	}
+
+	c.JSON(http.StatusOK, entry)
+}
+
+// Take a vessel off the watchlist
+func (h *WatchlistHandler) RemoveFromWatchlist(c *gin.Context) {
+	id, ok := parseWatchlistID(c)
+	if !ok {
+		return
+	}
+
+	entry, err := h.watchlistService.RemoveEntry(id)
+	if err != nil {
+		h.writeWatchlistError(c, err, "Failed to remove vessel from watchlist")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Vessel removed from watchlist successfully",
+		"entry":   entry,
+	})
+}
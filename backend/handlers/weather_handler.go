@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+	"vessel-tracker/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WeatherHandler struct {
+	weatherService *services.WeatherService
+	parks          *services.ParkRegistry
+}
+
+func NewWeatherHandler(weatherService *services.WeatherService, parks *services.ParkRegistry) *WeatherHandler {
+	return &WeatherHandler{
+		weatherService: weatherService,
+		parks:          parks,
+	}
+}
+
+// GetWeatherHistory returns the weather stored for a park from `from` to `to`
+// (the last hour by default) and the conditions at every step (5m by
+// default), the frame times /playback returns for the same range and step
+func (h *WeatherHandler) GetWeatherHistory(c *gin.Context) {
+	park, ok := resolvePark(c, h.parks)
+	if !ok {
+		return
+	}
+
+	start, end, ok := parseTimeRange(c, "from", "to", time.Hour)
+	if !ok {
+		return
+	}
+
+	step := 5 * time.Minute
+	if value := c.Query("step"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid step parameter, use a positive duration such as 5m",
+			})
+			return
+		}
+		step = parsed
+	}
+
+	if frames := services.PlaybackFrameCount(start, end, step); frames > services.MaxPlaybackFrames {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("the range would take %d frames, at most %d are returned; use a longer step or a shorter range", frames, services.MaxPlaybackFrames),
+		})
+		return
+	}
+
+	history, err := h.weatherService.GetHistory(c.Request.Context(), park, start, end, step)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to fetch weather history",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
@@ -1,19 +1,25 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
+	"time"
 	"vessel-tracker/services"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type WhitelistHandler struct {
 	whitelistService *services.WhitelistService
+	seedFile         string // WHITELIST_SEED_FILE, empty when none is configured
 }
 
-func NewWhitelistHandler(whitelistService *services.WhitelistService) *WhitelistHandler {
+func NewWhitelistHandler(whitelistService *services.WhitelistService, seedFile string) *WhitelistHandler {
 	return &WhitelistHandler{
 		whitelistService: whitelistService,
+		seedFile:         seedFile,
 	}
 }
 
@@ -29,7 +35,7 @@ func (h *WhitelistHandler) GetWhitelistEntries(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"whitelist": entries,
+		"whitelist": redact(c, entries),
 		"count":     len(entries),
 	})
 }
@@ -58,7 +64,7 @@ func (h *WhitelistHandler) CheckVesselWhitelist(c *gin.Context) {
 	}
 
 	if entry != nil {
-		response["whitelist_entry"] = entry
+		response["whitelist_entry"] = redact(c, entry)
 	}
 
 	c.JSON(http.StatusOK, response)
@@ -73,6 +79,7 @@ func (h *WhitelistHandler) AddToWhitelist(c *gin.Context) {
 		Name       string `json:"name"`
 		Reason     string `json:"reason"`
 		AddedBy    string `json:"added_by"`
+		OperatorID *uint  `json:"operator_id"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -83,9 +90,9 @@ func (h *WhitelistHandler) AddToWhitelist(c *gin.Context) {
 		return
 	}
 
-	if req.VesselUUID == "" && req.MMSI == "" && req.IMO == "" {
+	if req.VesselUUID == "" && req.MMSI == "" && req.IMO == "" && req.OperatorID == nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "At least one of vessel_uuid, mmsi, or imo must be provided",
+			"error": "At least one of vessel_uuid, mmsi, imo, or operator_id must be provided",
 		})
 		return
 	}
@@ -97,11 +104,16 @@ func (h *WhitelistHandler) AddToWhitelist(c *gin.Context) {
 		return
 	}
 
+	by, ok := changedBy(c)
+	if !ok {
+		return
+	}
+
 	if req.AddedBy == "" {
 		req.AddedBy = "manual"
 	}
 
-	err := h.whitelistService.AddToWhitelist(req.VesselUUID, req.MMSI, req.IMO, req.Name, req.Reason, req.AddedBy)
+	err := h.whitelistService.AddToWhitelist(req.VesselUUID, req.MMSI, req.IMO, req.Name, req.Reason, req.AddedBy, req.OperatorID, by)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to add vessel to whitelist",
@@ -118,6 +130,7 @@ func (h *WhitelistHandler) AddToWhitelist(c *gin.Context) {
 			"imo":  req.IMO,
 			"name": req.Name,
 		},
+		"operator_id": req.OperatorID,
 	})
 }
 
@@ -131,7 +144,12 @@ func (h *WhitelistHandler) RemoveFromWhitelist(c *gin.Context) {
 		return
 	}
 
-	err := h.whitelistService.RemoveFromWhitelist(vesselUUID)
+	by, ok := changedBy(c)
+	if !ok {
+		return
+	}
+
+	err := h.whitelistService.RemoveFromWhitelist(vesselUUID, by)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to remove vessel from whitelist",
@@ -146,22 +164,102 @@ func (h *WhitelistHandler) RemoveFromWhitelist(c *gin.Context) {
 	})
 }
 
-// Initialize hardcoded whitelist
-func (h *WhitelistHandler) InitializeHardcodedWhitelist(c *gin.Context) {
-	err := h.whitelistService.InitializeHardcodedWhitelist()
+// Remove a single whitelist entry by its ID
+func (h *WhitelistHandler) RemoveWhitelistEntry(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
 	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid entry id",
+		})
+		return
+	}
+
+	by, ok := changedBy(c)
+	if !ok {
+		return
+	}
+
+	if err := h.whitelistService.RemoveWhitelistEntry(uint(id), by); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Whitelist entry not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to remove whitelist entry",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Whitelist entry removed successfully",
+		"entry_id": id,
+	})
+}
+
+// Revoke the permits of an operator, which covered all of its vessels
+func (h *WhitelistHandler) RemoveOperatorFromWhitelist(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid operator id",
+		})
+		return
+	}
+
+	by, ok := changedBy(c)
+	if !ok {
+		return
+	}
+
+	if err := h.whitelistService.RemoveOperatorFromWhitelist(uint(id), by); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Operator has no whitelist permit",
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to initialize hardcoded whitelist",
+			"error":   "Failed to remove operator from whitelist",
 			"details": err.Error(),
 		})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Hardcoded whitelist initialized successfully",
+		"message":     "Operator removed from whitelist successfully",
+		"operator_id": id,
 	})
 }
 
+// Reload the whitelist seed file, adding its new entries and updating the
+// ones it changed
+func (h *WhitelistHandler) SeedWhitelist(c *gin.Context) {
+	by, ok := changedBy(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.whitelistService.SeedFromFile(h.seedFile, by)
+	if errors.Is(err, services.ErrNoWhitelistSeed) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to seed whitelist",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
 // Refresh whitelist cache
 func (h *WhitelistHandler) RefreshWhitelist(c *gin.Context) {
 	err := h.whitelistService.RefreshIfNeeded()
@@ -176,4 +274,50 @@ func (h *WhitelistHandler) RefreshWhitelist(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Whitelist refreshed successfully",
 	})
-}
\ No newline at end of file
+}
+
+// List whitelist changes with the entry before and after each, most recent
+// first, filterable by vessel, actor, action and period
+func (h *WhitelistHandler) GetWhitelistAudit(c *gin.Context) {
+	filter := services.WhitelistAuditFilter{
+		VesselUUID: c.Query("vessel_uuid"),
+		Actor:      c.Query("actor"),
+		Action:     c.Query("action"),
+		Limit:      200,
+	}
+
+	if since := c.Query("since"); since != "" {
+		parsed, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid since format, use RFC3339",
+			})
+			return
+		}
+		filter.Since = parsed
+	}
+	if limitStr := c.Query("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid limit parameter",
+			})
+			return
+		}
+		filter.Limit = limit
+	}
+
+	audits, err := h.whitelistService.GetWhitelistAudit(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to fetch whitelist audit",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"audit": audits,
+		"count": len(audits),
+	})
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+	"vessel-tracker/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ZoneEventHandler struct {
+	zoneEventService *services.ZoneEventService
+	parks            *services.ParkRegistry
+}
+
+func NewZoneEventHandler(zoneEventService *services.ZoneEventService, parks *services.ParkRegistry) *ZoneEventHandler {
+	return &ZoneEventHandler{
+		zoneEventService: zoneEventService,
+		parks:            parks,
+	}
+}
+
+// parseEventLimit reads the limit query parameter, 100 by default. It writes
+// a 400 response and returns false on bad input.
+func parseEventLimit(c *gin.Context) (int, bool) {
+	limit := 100
+	if limitStr := c.Query("limit"); limitStr != "" {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid limit parameter",
+			})
+			return 0, false
+		}
+	}
+	return limit, true
+}
+
+// GetEvents lists the park and buffer zone entries and exits of all vessels
+// in a park since the given time (the last 24 hours by default), oldest first
+func (h *ZoneEventHandler) GetEvents(c *gin.Context) {
+	park, ok := resolvePark(c, h.parks)
+	if !ok {
+		return
+	}
+
+	since := time.Now().Add(-24 * time.Hour)
+	if sinceStr := c.Query("since"); sinceStr != "" {
+		parsed, err := time.Parse(time.RFC3339, sinceStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid since format, use RFC3339",
+			})
+			return
+		}
+		since = parsed
+	}
+
+	eventType := c.Query("type")
+	if eventType != "" && !services.IsZoneEventType(eventType) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid type, use entered_park, left_park, entered_buffer or left_buffer",
+		})
+		return
+	}
+
+	limit, ok := parseEventLimit(c)
+	if !ok {
+		return
+	}
+
+	events, err := h.zoneEventService.GetEvents(c.Request.Context(), park.Record.ID, since, eventType, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to fetch zone events",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"park":   park.Record.Slug,
+		"events": redact(c, events),
+		"count":  len(events),
+	})
+}
+
+// GetVesselEvents returns the timeline of a vessel's park and buffer zone
+// entries and exits, over the last 7 days by default
+func (h *ZoneEventHandler) GetVesselEvents(c *gin.Context) {
+	park, ok := resolvePark(c, h.parks)
+	if !ok {
+		return
+	}
+
+	start, end, ok := parseTimeRange(c, "start", "end", 7*24*time.Hour)
+	if !ok {
+		return
+	}
+
+	limit, ok := parseEventLimit(c)
+	if !ok {
+		return
+	}
+
+	vesselUUID := c.Param("uuid")
+	events, err := h.zoneEventService.GetVesselEvents(c.Request.Context(), park.Record.ID, vesselUUID, start, end, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to fetch zone events",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"park":        park.Record.Slug,
+		"vessel_uuid": vesselUUID,
+		"start":       start,
+		"end":         end,
+		"events":      redact(c, events),
+		"count":       len(events),
+	})
+}
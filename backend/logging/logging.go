@@ -0,0 +1,78 @@
+// Package logging configures the structured logger shared by the server,
+// its services and the request logging middleware.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Output formats
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Config selects the minimum level and the output format of log records
+type Config struct {
+	Level  slog.Level
+	Format string
+}
+
+// DefaultConfig logs info and above as text
+func DefaultConfig() Config {
+	return Config{
+		Level:  slog.LevelInfo,
+		Format: FormatText,
+	}
+}
+
+// LoadConfig reads LOG_LEVEL (debug, info, warn or error) and LOG_FORMAT
+// (text or json), falling back to the defaults for unset variables
+func LoadConfig() (Config, error) {
+	config := DefaultConfig()
+
+	if value := os.Getenv("LOG_LEVEL"); value != "" {
+		if err := config.Level.UnmarshalText([]byte(value)); err != nil {
+			return config, fmt.Errorf("invalid LOG_LEVEL %q: expected debug, info, warn or error", value)
+		}
+	}
+
+	if value := os.Getenv("LOG_FORMAT"); value != "" {
+		format := strings.ToLower(value)
+		if format != FormatText && format != FormatJSON {
+			return config, fmt.Errorf("invalid LOG_FORMAT %q: expected text or json", value)
+		}
+		config.Format = format
+	}
+
+	return config, nil
+}
+
+// New creates a logger writing records in the configured format
+func New(config Config, w io.Writer) *slog.Logger {
+	options := &slog.HandlerOptions{Level: config.Level}
+
+	if config.Format == FormatJSON {
+		return slog.New(slog.NewJSONHandler(w, options))
+	}
+	return slog.New(slog.NewTextHandler(w, options))
+}
+
+// Setup installs a logger writing to stderr as the process default. Output
+// of the standard log package is routed through it as well, so libraries
+// that still use log.Printf share the format and level.
+func Setup(config Config) *slog.Logger {
+	logger := New(config, os.Stderr)
+	slog.SetDefault(logger)
+	return logger
+}
+
+// Component returns the default logger tagged with the name of the service
+// or subsystem emitting the records
+func Component(name string) *slog.Logger {
+	return slog.Default().With("component", name)
+}
@@ -0,0 +1,133 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Entry is a log record with the attributes of its logger and the record
+// flattened into one map. Attributes of groups are keyed "group.name".
+type Entry struct {
+	Time      time.Time
+	Level     slog.Level
+	Component string
+	Message   string
+	Attrs     map[string]interface{}
+}
+
+// Sink receives a copy of the log records it accepts, e.g. to store them
+// where they can be read without access to the server's output. Write must
+// not block.
+type Sink interface {
+	Accept(level slog.Level, component string) bool
+	Write(entry Entry)
+}
+
+// AddSink installs a process default logger that writes records as before and
+// hands those the sink accepts to it as well. Only loggers created
+// afterwards, e.g. by Component, feed the sink.
+func AddSink(sink Sink) {
+	slog.SetDefault(slog.New(&sinkHandler{inner: slog.Default().Handler(), sink: sink}))
+}
+
+// sinkHandler passes records on to inner and copies them to sink
+type sinkHandler struct {
+	inner     slog.Handler
+	sink      Sink
+	component string
+	group     string // prefix of attribute keys, "" outside groups
+	attrs     map[string]interface{}
+}
+
+func (h *sinkHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level) || h.sink.Accept(level, h.component)
+}
+
+func (h *sinkHandler) Handle(ctx context.Context, record slog.Record) error {
+	var err error
+	if h.inner.Enabled(ctx, record.Level) {
+		err = h.inner.Handle(ctx, record)
+	}
+
+	if h.sink.Accept(record.Level, h.component) {
+		entry := Entry{
+			Time:      record.Time,
+			Level:     record.Level,
+			Component: h.component,
+			Message:   record.Message,
+			Attrs:     make(map[string]interface{}, len(h.attrs)+record.NumAttrs()),
+		}
+		for key, value := range h.attrs {
+			entry.Attrs[key] = value
+		}
+		record.Attrs(func(attr slog.Attr) bool {
+			flattenAttr(entry.Attrs, h.group, attr)
+			return true
+		})
+		h.sink.Write(entry)
+	}
+
+	return err
+}
+
+func (h *sinkHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	next := &sinkHandler{
+		inner:     h.inner.WithAttrs(attrs),
+		sink:      h.sink,
+		component: h.component,
+		group:     h.group,
+		attrs:     make(map[string]interface{}, len(h.attrs)+len(attrs)),
+	}
+	for key, value := range h.attrs {
+		next.attrs[key] = value
+	}
+	for _, attr := range attrs {
+		if attr.Key == "component" && h.group == "" {
+			next.component = attr.Value.String()
+			continue
+		}
+		flattenAttr(next.attrs, h.group, attr)
+	}
+	return next
+}
+
+func (h *sinkHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &sinkHandler{
+		inner:     h.inner.WithGroup(name),
+		sink:      h.sink,
+		component: h.component,
+		group:     h.group + name + ".",
+		attrs:     h.attrs,
+	}
+}
+
+// flattenAttr adds an attribute to attrs, prefixing the keys of group members
+// with the group names. Errors and durations are stored as their text.
+func flattenAttr(attrs map[string]interface{}, prefix string, attr slog.Attr) {
+	value := attr.Value.Resolve()
+	if value.Kind() == slog.KindGroup {
+		if attr.Key != "" {
+			prefix += attr.Key + "."
+		}
+		for _, member := range value.Group() {
+			flattenAttr(attrs, prefix, member)
+		}
+		return
+	}
+	if attr.Key == "" {
+		return
+	}
+
+	switch v := value.Any().(type) {
+	case error:
+		attrs[prefix+attr.Key] = v.Error()
+	case time.Duration:
+		attrs[prefix+attr.Key] = v.String()
+	default:
+		attrs[prefix+attr.Key] = v
+	}
+}
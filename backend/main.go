@@ -1,12 +1,22 @@
 package main
 
 import (
-	"log"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
+	"vessel-tracker/config"
 	"vessel-tracker/database"
 	"vessel-tracker/handlers"
+	"vessel-tracker/logging"
+	"vessel-tracker/middleware"
+	"vessel-tracker/models"
 	"vessel-tracker/services"
 
 	"github.com/gin-contrib/cors"
@@ -15,115 +25,581 @@ import (
 )
 
 func main() {
-	err := godotenv.Load()
+	envErr := godotenv.Load()
+
+	// Every setting is read and checked before anything starts, so a bad
+	// deployment reports all of its mistakes at once
+	cfg, err := config.Load()
 	if err != nil {
-		log.Println("No .env file found")
+		for _, problem := range strings.Split(err.Error(), "\n") {
+			slog.Error("Invalid configuration", "error", problem)
+		}
+		os.Exit(1)
+	}
+	logger := logging.Setup(cfg.Logging)
+
+	if envErr != nil {
+		logger.Info("No .env file found")
 	}
 
 	// Initialize database
-	err = database.InitDatabase()
+	err = database.InitDatabase(cfg.Database)
 	if err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
+		fatal("Failed to initialize database", err)
 	}
 
-	apiKey := os.Getenv("DATALASTIC_API_KEY")
-	if apiKey == "" {
-		log.Fatal("DATALASTIC_API_KEY environment variable is required")
+	// Job and ingestion logs are stored for the admin log export; the sink is
+	// installed before the services create their loggers
+	logStore := services.NewLogStore(cfg.LogStore)
+	if cfg.LogStore.Enabled {
+		logging.AddSink(logStore)
+	}
+	if err := logStore.Start(); err != nil {
+		fatal("Failed to start log store", err)
+	}
+
+	provider, err := services.NewVesselDataProvider(cfg.Provider)
+	if err != nil {
+		fatal("Failed to initialize vessel data provider", err)
 	}
 
 	// Initialize services
-	vesselService := services.NewVesselService(apiKey)
-	geoService, err := services.NewGeoService("./data/national-park.geojson", "./data/buffered.geojson")
+	vesselService := services.NewVesselService(provider)
+
+	if err := services.EnableFaultInjection(cfg.Faults, provider, database.GetDB()); err != nil {
+		fatal("Failed to enable fault injection", err)
+	}
+
+	// Raw provider responses are kept so derived records can be checked
+	// against them
+	providerAudit := services.NewProviderAudit()
+	if cfg.ProviderAudit {
+		if err := services.EnableProviderAudit(provider, providerAudit); err != nil {
+			fatal("Invalid provider audit configuration", err)
+		}
+		logger.Info("Recording raw provider responses of fetch runs")
+	}
+
+	// Datalastic credits are counted per day, and limited when
+	// DATALASTIC_DAILY_CREDITS is set
+	if cfg.DatalasticQuota != nil {
+		if err := vesselService.EnableQuota(services.NewDatalasticQuota(*cfg.DatalasticQuota)); err != nil {
+			fatal("Failed to enable the Datalastic quota", err)
+		}
+	}
+
+	parks, err := services.NewParkRegistry(cfg.Parks, cfg.Boundaries, cfg.Archive.Credentials, cfg.DeploymentRegion)
 	if err != nil {
-		log.Fatalf("Failed to initialize geo service: %v", err)
+		fatal("Failed to initialize parks", err)
 	}
 
-	vesselRepo := services.NewVesselRepository()
+	if err := parks.SetZoneRules(cfg.Rules); err != nil {
+		fatal("Invalid zone rules configuration", err)
+	}
+
+	vesselRepo := services.NewVesselRepository(cfg.Dedup)
 	whitelistService := services.NewWhitelistService()
+	operatorService := services.NewOperatorService()
 
-	// Initialize hardcoded whitelist on startup
-	if err := whitelistService.InitializeHardcodedWhitelist(); err != nil {
-		log.Printf("Warning: Failed to initialize hardcoded whitelist: %v", err)
-	} else {
-		log.Println("Hardcoded whitelist initialized successfully")
+	// Seed the whitelist from WHITELIST_SEED_FILE on startup
+	if cfg.WhitelistSeed != "" {
+		if _, err := whitelistService.SeedFromFile(cfg.WhitelistSeed, services.SystemChange); err != nil {
+			logger.Warn("Failed to seed whitelist", "path", cfg.WhitelistSeed, "error", err)
+		}
+	}
+
+	// Apply whitelist changes made by other instances within seconds rather
+	// than at the next periodic refresh
+	syncCtx, stopSync := context.WithCancel(context.Background())
+	defer stopSync()
+	if whitelistService.StartSync(syncCtx) {
+		logger.Info("Whitelist cache synced across instances")
+	}
+
+	violationService := services.NewViolationService(whitelistService)
+	noticeService := services.NewNoticeService("./templates/notices", cfg.Notices, operatorService)
+	appealService := services.NewAppealService()
+	sanctionService := services.NewSanctionService()
+
+	calendar, err := services.NewPeakCalendar(cfg.Calendar)
+	if err != nil {
+		fatal("Invalid peak calendar configuration", err)
+	}
+	statsService := services.NewStatsService(calendar)
+	reportService := services.NewReportService()
+	auditService := services.NewAuditService()
+
+	sessionService, err := services.NewSessionService(cfg.Sessions)
+	if err != nil {
+		fatal("Failed to initialize session service", err)
+	}
+	if sessionService.StartSync(syncCtx) {
+		logger.Info("Session revocations synced across instances")
+	}
+
+	apiKeyService, err := services.NewAPIKeyService()
+	if err != nil {
+		fatal("Failed to initialize API key service", err)
+	}
+
+	loginGuard := services.NewLoginGuard(cfg.LoginGuard, auditService)
+
+	// Raise unavailable boundary layers as admin alerts alongside the
+	// security alerts
+	parks.SetLayerAlert(func(park *services.Park, status services.LayerStatus) {
+		loginGuard.Alert(models.SecurityEvent{
+			Type:    models.SecurityEventGeoLayerUnavailable,
+			Path:    status.Path,
+			Details: fmt.Sprintf("%s: %s layer unavailable: %s", park.Record.Slug, status.Layer, status.Error),
+		})
+	})
+
+	landMask, err := services.LoadLandMask(context.Background(), cfg.LandMask, parks.ExpectedRegion())
+	if err != nil {
+		fatal("Failed to load the land mask", err)
+	}
+	parks.SetLandMask(landMask)
+
+	currents, err := services.LoadTidalCurrents(cfg.Currents)
+	if err != nil {
+		fatal("Failed to load tidal currents", err)
+	}
+	parks.SetTidalCurrents(currents)
+
+	habitatLayers := services.NewHabitatLayerService(cfg.HabitatDir, parks)
+	if err := habitatLayers.LoadAll(); err != nil {
+		fatal("Failed to load habitat layers", err)
+	}
+
+	anchoringDetector := services.NewAnchoringDetector(vesselRepo, services.DefaultAnchoringConfig())
+
+	shadowDetector := services.NewShadowDetector(cfg.Shadow, anchoringDetector.Config(), vesselRepo, whitelistService)
+
+	archiver, err := services.NewArchiver(cfg.Archive)
+	if err != nil {
+		fatal("Invalid archive configuration", err)
+	}
+	if err := services.CheckArchiveResidency(parks, archiver); err != nil {
+		fatal("Invalid archive configuration", err)
+	}
+
+	emissionService := services.NewEmissionService(cfg.Emissions)
+	dailyAggregateService := services.NewDailyAggregateService(parks, violationService, emissionService)
+	retentionService := services.NewRetentionService(cfg.Retention, archiver, parks, dailyAggregateService)
+	explainService := services.NewExplainService(parks, whitelistService)
+	arrivalService := services.NewArrivalService()
+	if err := arrivalService.SeedArrivals(parks.All()); err != nil {
+		fatal("Failed to seed park arrivals", err)
+	}
+
+	zoneEventService := services.NewZoneEventService()
+
+	watchlistService := services.NewWatchlistService(cfg.Watchlist, violationService)
+
+	trajectoryService := services.NewTrajectoryService(cfg.Trajectory, vesselRepo, violationService)
+
+	boundaryPreviewService := services.NewBoundaryPreviewService(vesselRepo)
+
+	positionPrivacy := services.NewPositionPrivacy(cfg.PositionPrivacy)
+	if err := services.InstallPositionCutoff(database.GetDB()); err != nil {
+		fatal("Failed to install the position cutoff", err)
 	}
 
-	scheduler := services.NewSchedulerService(vesselService, geoService, vesselRepo)
+	weatherService := services.NewWeatherService(cfg.Weather)
+	anchorDragDetector := services.NewAnchorDragDetector(cfg.AnchorDrag, anchoringDetector.Config(), vesselRepo, violationService, weatherService)
+
+	scheduler := services.NewSchedulerService(cfg.Scheduler, vesselService, parks, vesselRepo, violationService, watchlistService, trajectoryService, anchoringDetector, shadowDetector, arrivalService, zoneEventService, sanctionService, retentionService, weatherService, anchorDragDetector)
+
+	// Maintenance mode pauses the scheduler, so it is set up before the
+	// scheduler starts its first fetch
+	maintenance := services.NewMaintenanceService(scheduler)
+	if cfg.Maintenance {
+		maintenance.Enable("", 0, "MAINTENANCE_MODE")
+	}
+
+	// Alerts for new violations go out from the first fetch on
+	notifications, err := services.NewNotificationService(cfg.Notifications, violationService, operatorService, parks)
+	if err != nil {
+		fatal("Invalid notification configuration", err)
+	}
+	notifications.Start()
 
 	// Start scheduler
 	err = scheduler.Start()
 	if err != nil {
-		log.Fatalf("Failed to start scheduler: %v", err)
+		fatal("Failed to start scheduler", err)
 	}
 
-	// Handle graceful shutdown
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
-	go func() {
-		<-c
-		log.Println("Shutting down gracefully...")
-		scheduler.Stop()
-		os.Exit(0)
-	}()
+	// With VESSEL_DATA_SOURCE=ais positions come from a local AIS base
+	// station instead of Datalastic polling
+	var aisReceiver *services.AISReceiverService
+	if cfg.AISReceiver != nil {
+		aisReceiver = services.NewAISReceiverService(*cfg.AISReceiver, scheduler)
+		if err := aisReceiver.Start(); err != nil {
+			fatal("Failed to start AIS receiver", err)
+		}
+	}
+
+	// The self test exercises the default park
+	probe := services.NewProbeService(cfg.Probe, parks.Default().Geo, vesselService)
+	if err := probe.Start(); err != nil {
+		fatal("Failed to start probe", err)
+	}
+
+	indexAdvisor := services.NewIndexAdvisor(cfg.IndexAdvisor)
+	if err := indexAdvisor.Start(); err != nil {
+		fatal("Failed to start index advisor", err)
+	}
 
-	r := gin.Default()
+	apiUsage := services.NewAPIUsageTracker(cfg.APIUsage)
+	if err := apiUsage.Start(); err != nil {
+		fatal("Failed to start API usage tracking", err)
+	}
+
+	rateLimiter := services.NewRateLimiter(cfg.RateLimit)
+
+	r := gin.New()
+	r.HandleMethodNotAllowed = true
+	// Gin trusts X-Forwarded-For from any peer unless told otherwise, which
+	// would let clients pick the address they are rate limited under
+	if err := r.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		fatal("Invalid trusted proxies", err)
+	}
+	r.Use(gin.Recovery(), middleware.RequestLogger(logging.Component("http")), middleware.ResponseMetadata())
 
-	config := cors.DefaultConfig()
-	config.AllowAllOrigins = true
-	config.AllowHeaders = []string{"Origin", "Content-Type", "Accept"}
-	r.Use(cors.New(config))
+	corsConfig := cors.DefaultConfig()
+	corsConfig.AllowAllOrigins = true
+	corsConfig.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Admin-Token", middleware.APIKeyHeader, middleware.RequestIDHeader}
+	corsConfig.ExposeHeaders = []string{middleware.RequestIDHeader, "ETag", "Allow", "Deprecation", "Sunset", "Link", "Retry-After", middleware.RateLimitLimitHeader, middleware.RateLimitRemainingHeader}
+	r.Use(cors.New(corsConfig))
 
 	// Serve static files (Frontend)
 	r.Static("/static", "./static")
 	r.StaticFile("/", "./static/index.html")
 	r.StaticFile("/favicon.ico", "./static/favicon.ico")
 
-	vesselHandler := handlers.NewVesselHandler(vesselService, geoService, vesselRepo, whitelistService)
-	whitelistHandler := handlers.NewWhitelistHandler(whitelistService)
-	violationHandler := handlers.NewViolationHandler(vesselService, geoService, vesselRepo)
+	vesselHandler := handlers.NewVesselHandler(vesselService, parks, vesselRepo, whitelistService, violationService, scheduler)
+	whitelistHandler := handlers.NewWhitelistHandler(whitelistService, cfg.WhitelistSeed)
+	watchlistHandler := handlers.NewWatchlistHandler(watchlistService)
+	violationHandler := handlers.NewViolationHandler(vesselService, parks, vesselRepo, violationService, noticeService)
+	operatorHandler := handlers.NewOperatorHandler(operatorService, whitelistService)
+	geoHandler := handlers.NewGeoHandler(parks, boundaryPreviewService)
+	habitatLayerHandler := handlers.NewHabitatLayerHandler(habitatLayers, parks)
+	posidonia := services.NewPosidoniaLayer(cfg.PosidoniaFile)
+	posidoniaTiles := services.NewPosidoniaTiles(posidonia, cfg.Tiles)
+	posidoniaHandler := handlers.NewPosidoniaHandler(posidonia, posidoniaTiles)
+	anchoringHandler := handlers.NewAnchoringHandler(anchoringDetector, parks, scheduler)
+	appealHandler := handlers.NewAppealHandler(appealService)
+	sanctionHandler := handlers.NewSanctionHandler(sanctionService, cfg.PaymentWebhookSecret)
+	statsHandler := handlers.NewStatsHandler(statsService, dailyAggregateService, parks)
+	feedHandler := handlers.NewFeedHandler(services.NewFeedService(dailyAggregateService), parks)
+	reportHandler := handlers.NewReportHandler(reportService, parks)
+	emissionHandler := handlers.NewEmissionHandler(emissionService, parks)
+	auditHandler := handlers.NewAuditHandler(auditService)
+	providerAuditHandler := handlers.NewProviderAuditHandler(providerAudit, parks)
+	schedulerHandler := handlers.NewSchedulerHandler(scheduler)
+	sessionHandler := handlers.NewSessionHandler(sessionService, loginGuard)
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyService)
+	explainHandler := handlers.NewExplainHandler(explainService)
+	shadowHandler := handlers.NewShadowHandler(shadowDetector, parks)
+	arrivalHandler := handlers.NewArrivalHandler(arrivalService, parks)
+	zoneEventHandler := handlers.NewZoneEventHandler(zoneEventService, parks)
+	trajectoryHandler := handlers.NewTrajectoryHandler(trajectoryService, parks)
+	playbackHandler := handlers.NewPlaybackHandler(services.NewPlaybackService(vesselRepo), parks)
+	weatherHandler := handlers.NewWeatherHandler(weatherService, parks)
+	landMaskHandler := handlers.NewLandMaskHandler(vesselRepo, parks)
+	currentsHandler := handlers.NewCurrentsHandler(cfg.Currents, parks)
+	ruleHandler := handlers.NewRuleHandler(parks)
+	erasureHandler := handlers.NewErasureHandler(services.NewErasureService(whitelistService))
+
+	maintenanceHandler := handlers.NewMaintenanceHandler(maintenance)
+	notificationHandler := handlers.NewNotificationHandler(notifications)
+	healthHandler := handlers.NewHealthHandler(services.NewHealthService(cfg.Health, scheduler, vesselService, parks), parks, probe, maintenance, vesselService, aisReceiver)
+	overviewHandler := handlers.NewOverviewHandler(services.NewOverviewService(scheduler, vesselService, violationService, notifications, whitelistService, posidonia, posidoniaTiles, parks, maintenance, indexAdvisor))
+	configHandler := handlers.NewConfigHandler(cfg)
+	timelineHandler := handlers.NewTimelineHandler(services.NewTimelineService(zoneEventService, auditService, appealService, sanctionService, notifications))
+
+	// Endpoints slated for removal, announced with Deprecation and Sunset
+	// headers and listed at /api/meta/deprecations
+	demoDeprecated := time.Date(2026, time.October, 16, 0, 0, 0, 0, time.UTC)
+	demoSunset := time.Date(2027, time.January, 15, 0, 0, 0, 0, time.UTC)
+	demoNote := "Creates fake violations; use VESSEL_PROVIDER=file with a positions file or go run ./cmd/loadtest for test data"
+	deprecations := []middleware.Deprecation{
+		{Method: http.MethodPost, Path: "/api/violations/generate-buffer", DeprecatedAt: demoDeprecated, Sunset: &demoSunset, Note: demoNote},
+		{Method: http.MethodPost, Path: "/api/violations/generate-posidonia", DeprecatedAt: demoDeprecated, Sunset: &demoSunset, Note: demoNote},
+		{Method: http.MethodPost, Path: "/api/violations/clear-test", DeprecatedAt: demoDeprecated, Sunset: &demoSunset, Note: "Removes the fake violations of the generate endpoints"},
+		{Method: http.MethodPost, Path: "/api/whitelist/initialize", DeprecatedAt: demoDeprecated, Sunset: &demoSunset, Note: "Reloads WHITELIST_SEED_FILE; use POST /api/whitelist/seed"},
+	}
+	siteService := services.NewSiteService(posidonia, habitatLayers, map[string]bool{
+		services.FeatureAISReceiver: cfg.Scheduler.Source == services.DataSourceAIS,
+	})
+	apiUsageHandler := handlers.NewAPIUsageHandler(apiUsage, deprecations)
+	logHandler := handlers.NewLogHandler(logStore)
+	metaHandler := handlers.NewMetaHandler(deprecations, siteService, parks)
+	quotaHandler := handlers.NewQuotaHandler(vesselService)
+
+	// Writes API keys may make besides reads, given the scope
+	scopedRoutes := []middleware.ScopedRoute{
+		{Method: http.MethodPost, Path: "/api/whitelist", Scope: models.APIKeyScopeWhitelistAdmin},
+		{Method: http.MethodDelete, Path: "/api/whitelist/:uuid", Scope: models.APIKeyScopeWhitelistAdmin},
+		{Method: http.MethodDelete, Path: "/api/whitelist/entries/:id", Scope: models.APIKeyScopeWhitelistAdmin},
+		{Method: http.MethodDelete, Path: "/api/whitelist/operators/:id", Scope: models.APIKeyScopeWhitelistAdmin},
+		{Method: http.MethodPost, Path: "/api/whitelist/initialize", Scope: models.APIKeyScopeWhitelistAdmin},
+		{Method: http.MethodPost, Path: "/api/whitelist/seed", Scope: models.APIKeyScopeWhitelistAdmin},
+		{Method: http.MethodPost, Path: "/api/whitelist/refresh", Scope: models.APIKeyScopeWhitelistAdmin},
+		{Method: http.MethodPost, Path: "/api/ingest/positions", Scope: models.APIKeyScopeIngest},
+	}
 
-	api := r.Group("/api")
+	api := r.Group("/api",
+		middleware.Authenticate(cfg.Auth, sessionService, apiKeyService, loginGuard),
+		middleware.EnforceAPIKeyScopes(scopedRoutes),
+		middleware.TrackUsage(apiUsage),
+		// A map view loads dozens of posidonia tiles at once; they are cached
+		// and cheap to serve
+		middleware.RateLimit(rateLimiter, "/api/health", "/api/docs", "/api/docs/openapi.yaml", "/api/tiles/posidonia/:z/:x/:y"),
+		middleware.RejectDuringMaintenance(maintenance, "/api/health", "/api/docs", "/api/docs/openapi.yaml", middleware.DeprecationsPath, "/api/meta/site"),
+		middleware.AnnounceDeprecations(deprecations),
+		middleware.ProtectPublicPositions(positionPrivacy),
+	)
 	{
 		api.GET("/vessels", vesselHandler.GetVessels)
+		api.GET("/vessels/known", vesselHandler.GetKnownVessels)
+		api.GET("/vessels/local", vesselHandler.SearchLocalVessels)
+		api.GET("/vessels/anchored", anchoringHandler.GetAnchoredVessels)
 		api.GET("/vessels/in-park", vesselHandler.GetVesselsInPark)
 		api.GET("/vessels/at-time", vesselHandler.GetVesselsAtTime)
 		api.GET("/vessels/in-park/at-time", vesselHandler.GetVesselsInParkAtTime)
+		api.GET("/vessels/:uuid", vesselHandler.GetVessel)
 		api.GET("/vessels/:uuid/previous-positions", vesselHandler.GetPreviousPositions)
+		api.GET("/vessels/:uuid/history/export", vesselHandler.ExportVesselHistory)
+		api.GET("/vessels/:uuid/events", zoneEventHandler.GetVesselEvents)
+		api.GET("/vessels/:uuid/projection", trajectoryHandler.GetProjection)
 		api.GET("/vessels/historical-data", vesselHandler.GetVesselHistoricalData)
+		api.GET("/playback", playbackHandler.GetPlayback)
+		api.GET("/weather/history", weatherHandler.GetWeatherHistory)
 		api.GET("/park-boundaries", vesselHandler.GetParkBoundaries)
 		api.GET("/buffered-boundaries", vesselHandler.GetBufferedBoundaries)
-		api.GET("/posidonia", handlers.GetPosidoniaData)
+		api.GET("/posidonia", posidoniaHandler.GetPosidoniaData)
+		api.GET("/tiles/posidonia/:z/:x/:y", posidoniaHandler.GetPosidoniaTile)
+		api.GET("/parks", geoHandler.GetParks)
+		api.GET("/geo/distance", geoHandler.GetBoundaryDistance)
+		api.GET("/geo/boundaries/status", geoHandler.GetBoundaryStatus)
+		api.GET("/layers", habitatLayerHandler.GetLayers)
+		api.GET("/layers/:id", habitatLayerHandler.GetLayerGeoJSON)
+		api.GET("/land-mask", landMaskHandler.GetLandMask)
+		api.GET("/anomalies/on-land", landMaskHandler.GetOnLandPositions)
+		api.GET("/currents", currentsHandler.GetCurrents)
+		api.GET("/rules", ruleHandler.GetRules)
 
 		// Whitelist endpoints
 		api.GET("/whitelist", whitelistHandler.GetWhitelistEntries)
 		api.GET("/whitelist/check", whitelistHandler.CheckVesselWhitelist)
-		api.POST("/whitelist", whitelistHandler.AddToWhitelist)
-		api.DELETE("/whitelist/:uuid", whitelistHandler.RemoveFromWhitelist)
-		api.POST("/whitelist/initialize", whitelistHandler.InitializeHardcodedWhitelist)
-		api.POST("/whitelist/refresh", whitelistHandler.RefreshWhitelist)
+
+		// Device sessions
+		api.POST("/auth/login", middleware.RateLimitAuth(loginGuard), sessionHandler.Login)
+		api.POST("/auth/refresh", middleware.RateLimitAuth(loginGuard), sessionHandler.Refresh)
+		api.POST("/auth/logout", sessionHandler.Logout)
+
+		// Operator endpoints
+		api.GET("/operators", operatorHandler.GetOperators)
+		api.GET("/operators/stats", operatorHandler.GetOperatorStats)
+		api.GET("/operators/:id", operatorHandler.GetOperator)
+		api.GET("/operators/:id/violations", middleware.AuditAccess(auditService, "operator_violations", "id"), operatorHandler.GetOperatorViolations)
+
+		// Operator registry and whitelist changes, reports, violation triage and
+		// the watchlist are limited to park staff
+		ranger := api.Group("", middleware.RequireRole(middleware.RoleRanger))
+		{
+			ranger.POST("/whitelist", whitelistHandler.AddToWhitelist)
+			ranger.DELETE("/whitelist/:uuid", whitelistHandler.RemoveFromWhitelist)
+			ranger.DELETE("/whitelist/entries/:id", whitelistHandler.RemoveWhitelistEntry)
+			ranger.DELETE("/whitelist/operators/:id", whitelistHandler.RemoveOperatorFromWhitelist)
+			ranger.POST("/whitelist/refresh", whitelistHandler.RefreshWhitelist)
+			ranger.POST("/operators", operatorHandler.CreateOperator)
+			ranger.POST("/operators/:id/vessels", operatorHandler.AssignVessel)
+			ranger.DELETE("/operators/:id/vessels/:uuid", operatorHandler.UnassignVessel)
+			ranger.GET("/reports/violations", reportHandler.GetViolationReport)
+			ranger.GET("/reports/emissions", emissionHandler.GetEmissionReport)
+			ranger.GET("/shadow/report", shadowHandler.GetShadowReport)
+			ranger.GET("/violations/history", violationHandler.GetStatusHistory)
+			ranger.PATCH("/violations/:id", violationHandler.UpdateViolation)
+			ranger.GET("/violations/:id/history", violationHandler.GetViolationHistory)
+			ranger.GET("/watchlist", watchlistHandler.GetWatchlist)
+			ranger.GET("/watchlist/:id", watchlistHandler.GetWatchlistEntry)
+			ranger.POST("/ingest/positions", schedulerHandler.IngestPositions)
+		}
+
+		// Operator personal data and case files are restricted to administrators,
+		// and every read is recorded in the access log
+		admin := api.Group("", middleware.RequireAdmin())
+		{
+			admin.GET("/operators/:id/contacts", middleware.AuditAccess(auditService, "operator_contacts", "id"), operatorHandler.GetOperatorContacts)
+			admin.POST("/operators/:id/contacts", operatorHandler.AddOperatorContact)
+			admin.DELETE("/operators/:id/contacts/:contact_id", operatorHandler.DeleteOperatorContact)
+			admin.GET("/violations/:id/notice", middleware.AuditAccess(auditService, "violation_notice", "id"), violationHandler.GetViolationNotice)
+			admin.GET("/violations/:id/evidence", middleware.AuditAccess(auditService, "violation_evidence", "id"), violationHandler.GetViolationEvidence)
+			admin.GET("/violations/:id/timeline", middleware.AuditAccess(auditService, "violation_timeline", "id"), timelineHandler.GetViolationTimeline)
+			admin.GET("/violations/:id/appeals", middleware.AuditAccess(auditService, "violation_appeals", "id"), appealHandler.GetAppeals)
+			admin.POST("/violations/:id/appeals", appealHandler.FileAppeal)
+			admin.PATCH("/violations/:id/appeals/:appeal_id", appealHandler.UpdateAppeal)
+			admin.POST("/violations/:id/sanctions", sanctionHandler.IssueSanction)
+			admin.GET("/sanctions", middleware.AuditAccess(auditService, "sanctions", ""), sanctionHandler.GetSanctions)
+			admin.PATCH("/sanctions/:id", sanctionHandler.UpdateSanction)
+			admin.POST("/watchlist", watchlistHandler.AddToWatchlist)
+			admin.PATCH("/watchlist/:id", watchlistHandler.UpdateWatchlistEntry)
+			admin.DELETE("/watchlist/:id", watchlistHandler.RemoveFromWatchlist)
+			admin.DELETE("/vessels/:uuid/data", middleware.AuditAccess(auditService, "vessel_data", "uuid"), erasureHandler.EraseVesselData)
+			admin.POST("/whitelist/seed", whitelistHandler.SeedWhitelist)
+			admin.POST("/whitelist/initialize", whitelistHandler.SeedWhitelist)
+			admin.GET("/whitelist/audit", whitelistHandler.GetWhitelistAudit)
+			admin.GET("/admin/access-logs", auditHandler.GetAccessLogs)
+			admin.GET("/admin/security-events", auditHandler.GetSecurityEvents)
+			admin.GET("/admin/api-usage", apiUsageHandler.GetAPIUsage)
+			admin.GET("/admin/logs", logHandler.GetLogs)
+			admin.GET("/admin/retention", schedulerHandler.GetRetention)
+			admin.GET("/admin/maintenance", maintenanceHandler.GetMaintenance)
+			admin.GET("/admin/overview", overviewHandler.GetOverview)
+			admin.POST("/admin/maintenance", maintenanceHandler.SetMaintenance)
+			admin.GET("/admin/notifications", notificationHandler.GetNotifications)
+			admin.PUT("/admin/notifications/routes", notificationHandler.SetRoutes)
+			admin.POST("/admin/notifications/test", notificationHandler.SendTest)
+			admin.GET("/admin/notifications/suppressions", notificationHandler.GetSuppressions)
+			admin.DELETE("/admin/notifications/suppressions/:id", middleware.AuditAccess(auditService, "notification_suppression", "id"), notificationHandler.CancelSuppression)
+			admin.POST("/vessels/:uuid/notification-suppressions", middleware.AuditAccess(auditService, "notification_suppression", "uuid"), notificationHandler.SuppressVessel)
+			admin.GET("/admin/provider-responses", providerAuditHandler.GetProviderResponses)
+			admin.GET("/admin/provider-responses/:id", providerAuditHandler.GetProviderResponsePayload)
+			admin.POST("/scheduler/fetch-now", schedulerHandler.FetchNow)
+			admin.POST("/geo/boundaries/:layer", geoHandler.ImportBoundaries)
+			admin.POST("/geo/boundaries/:layer/preview", geoHandler.PreviewBoundaries)
+			admin.POST("/layers", habitatLayerHandler.UploadLayer)
+			admin.DELETE("/layers/:id", habitatLayerHandler.DeleteLayer)
+			admin.PUT("/currents", currentsHandler.ImportCurrents)
+			admin.GET("/auth/sessions", sessionHandler.GetSessions)
+			admin.POST("/auth/sessions/:id/revoke", sessionHandler.RevokeSession)
+			admin.POST("/auth/sessions/revoke-actor", sessionHandler.RevokeActorSessions)
+			admin.POST("/auth/tokens/revoke", sessionHandler.RevokeToken)
+			admin.GET("/admin/api-keys", apiKeyHandler.GetAPIKeys)
+			admin.POST("/admin/api-keys", apiKeyHandler.IssueAPIKey)
+			admin.POST("/admin/api-keys/:id/revoke", apiKeyHandler.RevokeAPIKey)
+			admin.GET("/config", configHandler.GetConfig)
+		}
+
+		// Scheduler
+		api.GET("/scheduler/config", schedulerHandler.GetConfig)
+		api.GET("/scheduler/status", schedulerHandler.GetStatus)
+		api.GET("/datalastic/quota", quotaHandler.GetDatalasticQuota)
+
+		// Traffic statistics
+		api.GET("/stats/daily", statsHandler.GetDailyAggregates)
+		api.GET("/stats/dwell-time", statsHandler.GetDwellTime)
+		api.GET("/stats/heatmap", statsHandler.GetHeatmap)
+		api.GET("/stats/occupancy", statsHandler.GetOccupancy)
+		api.GET("/stats/peak", statsHandler.GetPeakComparison)
+		api.GET("/feed", feedHandler.GetFeed)
+
+		// Daily log of park entries and exits
+		api.GET("/log/movements", statsHandler.GetMovements)
+
+		// Anchoring events
+		api.GET("/anchoring/events", anchoringHandler.GetAnchoringEvents)
+
+		// Rule evaluation of a stored position
+		api.GET("/explain", explainHandler.ExplainPosition)
+
+		// Vessels seen in a park for the first time
+		api.GET("/arrivals", arrivalHandler.GetArrivals)
+		api.GET("/arrivals/digest", arrivalHandler.GetArrivalDigest)
+
+		// Park and buffer zone entries and exits
+		api.GET("/events", zoneEventHandler.GetEvents)
+
+		// Violation alert stream
+		api.GET("/violations/stream", violationHandler.StreamViolations)
+		api.GET("/appeals/stats", appealHandler.GetAppealStats)
+		api.GET("/sanctions/stats", sanctionHandler.GetCollectionStats)
+		api.POST("/sanctions/webhook", sanctionHandler.PaymentWebhook)
 
 		// Violation generation endpoints (for testing/demo purposes)
 		api.POST("/violations/generate-buffer", violationHandler.GenerateBufferViolations)
 		api.POST("/violations/generate-posidonia", violationHandler.GeneratePosidoniaViolations)
 		api.POST("/violations/clear-test", violationHandler.ClearTestViolations)
 
-		api.GET("/health", func(c *gin.Context) {
-			c.JSON(200, gin.H{"status": "healthy"})
-		})
+		api.GET("/health", healthHandler.GetHealth)
+
+		// API documentation
+		api.GET("/docs", handlers.GetAPIDocs)
+		api.GET("/docs/openapi.yaml", handlers.GetOpenAPISpec)
+		api.GET("/meta/deprecations", metaHandler.GetDeprecations)
+		api.GET("/meta/site", metaHandler.GetSite)
+	}
+
+	if err := middleware.CheckDeprecations(r.Routes(), deprecations); err != nil {
+		fatal("Invalid deprecation list", err)
 	}
 
 	// Serve index.html for all non-API routes (SPA fallback)
 	r.NoRoute(func(c *gin.Context) {
 		c.File("./static/index.html")
 	})
+	r.NoMethod(middleware.AllowedMethods(r))
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	srv := &http.Server{
+		Addr:    fmt.Sprintf(":%d", cfg.Server.Port),
+		Handler: middleware.HeadAsGet(r),
 	}
+	// Open violation streams never finish on their own
+	srv.RegisterOnShutdown(violationService.CloseSubscriptions)
 
-	log.Printf("Server starting on port %s", port)
-	if err := r.Run(":" + port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	serverErr := make(chan error, 1)
+	go func() {
+		logger.Info("Server starting", "port", cfg.Server.Port)
+		serverErr <- srv.ListenAndServe()
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	select {
+	case err := <-serverErr:
+		fatal("Failed to start server", err)
+	case <-quit:
+	}
+
+	// Stop taking requests and let the ones in flight finish, then stop the
+	// background jobs so pending positions are stored before the database
+	// closes
+	logger.Info("Shutting down gracefully", "timeout", cfg.Server.ShutdownTimeout.String())
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		logger.Error("HTTP server did not drain in time", "error", err)
+	}
+
+	if aisReceiver != nil {
+		aisReceiver.Stop()
+	}
+	scheduler.Stop()
+	notifications.Stop()
+	probe.Stop()
+	indexAdvisor.Stop()
+	apiUsage.Stop()
+	stopSync()
+	if closer, ok := provider.(io.Closer); ok {
+		closer.Close()
+	}
+	logStore.Stop()
+
+	if err := database.Close(); err != nil {
+		logger.Error("Failed to close database", "error", err)
+	}
+	logger.Info("Shutdown complete")
+}
+
+// fatal logs a startup failure and exits
+func fatal(msg string, err error) {
+	if err != nil {
+		slog.Error(msg, "error", err)
+	} else {
+		slog.Error(msg)
 	}
+	os.Exit(1)
 }
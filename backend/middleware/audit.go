@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"vessel-tracker/models"
+	"vessel-tracker/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuditAccess records who accessed a sensitive record. recordType names the
+// kind of data and idParam the route parameter holding the record ID; routes
+// without an ID (listings) are logged with record ID "*". Only successful
+// responses are logged since failed requests disclosed nothing.
+func AuditAccess(auditService *services.AuditService, recordType, idParam string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if c.Writer.Status() >= 400 {
+			return
+		}
+
+		recordID := "*"
+		if idParam != "" {
+			recordID = c.Param(idParam)
+		}
+
+		entry := &models.AccessLog{
+			Actor:      GetActor(c),
+			Role:       GetRole(c),
+			RecordType: recordType,
+			RecordID:   recordID,
+			Method:     c.Request.Method,
+			Path:       c.Request.URL.Path,
+			ClientIP:   c.ClientIP(),
+			Status:     c.Writer.Status(),
+		}
+
+		if err := auditService.LogAccess(entry); err != nil {
+			Logger(c).Error("Failed to record access", "record_type", recordType, "record_id", recordID, "error", err)
+		}
+	}
+}
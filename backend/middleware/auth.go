@@ -0,0 +1,396 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"vessel-tracker/models"
+	"vessel-tracker/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Requester roles, from least to most privileged
+const (
+	RolePublic     = "public"
+	RoleResearcher = "researcher"
+	RoleRanger     = "ranger"
+	RoleAdmin      = "admin"
+)
+
+const (
+	roleContextKey    = "role"
+	actorContextKey   = "actor"
+	sessionContextKey = "session"
+	apiKeyContextKey  = "api_key"
+	scopesContextKey  = "api_key_scopes"
+)
+
+// APIKeyHeader carries the API keys issued to machine clients
+const APIKeyHeader = "X-API-Key"
+
+// API key kinds, the prefixes of the key identifiers usage is tracked by
+const (
+	APIKeyAnonymous  = "anonymous"
+	APIKeyAdminToken = "admin_token"
+	APIKeyRoleToken  = "role_token"
+	APIKeySession    = "session"
+	APIKeyIssued     = "api_key"
+)
+
+var roleRanks = map[string]int{
+	RolePublic:     0,
+	RoleResearcher: 1,
+	RoleRanger:     2,
+	RoleAdmin:      3,
+}
+
+// RoleRank returns the privilege level of a role; unknown roles rank as public
+func RoleRank(role string) int {
+	return roleRanks[role]
+}
+
+// RoleToken is a configured token and the identity it grants
+type RoleToken struct {
+	Token string `secret:"true"`
+	Role  string
+	Name  string // identifies the holder in access logs, defaults to the role
+}
+
+// AuthConfig holds the static tokens requests can authenticate with
+type AuthConfig struct {
+	AdminToken string `secret:"true"`
+	RoleTokens []RoleToken
+}
+
+// LoadAuthConfig reads ADMIN_TOKEN and ROLE_TOKENS
+func LoadAuthConfig() (AuthConfig, error) {
+	roleTokens, err := parseRoleTokens(os.Getenv("ROLE_TOKENS"))
+	if err != nil {
+		return AuthConfig{}, err
+	}
+	return AuthConfig{
+		AdminToken: os.Getenv("ADMIN_TOKEN"),
+		RoleTokens: roleTokens,
+	}, nil
+}
+
+// roleToken is the identity a configured token grants
+type roleToken struct {
+	role  string
+	actor string
+	key   string // identifies the token in usage statistics
+}
+
+// tokenKeyID identifies a static token by a prefix of its SHA-256 hash, so
+// usage can be told apart per token without storing the token
+func tokenKeyID(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return APIKeyRoleToken + ":" + hex.EncodeToString(sum[:4])
+}
+
+// parseRoleTokens reads ROLE_TOKENS in the form "token:role[:name],..." where
+// the optional name identifies the holder in access logs. Entries are
+// numbered from 1 in errors so the tokens are not logged.
+func parseRoleTokens(value string) ([]RoleToken, error) {
+	var tokens []RoleToken
+
+	for i, entry := range strings.Split(value, ",") {
+		parts := strings.Split(strings.TrimSpace(entry), ":")
+		if len(parts) == 1 && parts[0] == "" {
+			continue
+		}
+
+		if len(parts) < 2 || len(parts) > 3 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid ROLE_TOKENS entry %d: expected token:role[:name]", i+1)
+		}
+		if _, known := roleRanks[parts[1]]; !known {
+			return nil, fmt.Errorf("invalid ROLE_TOKENS entry %d: unknown role %q", i+1, parts[1])
+		}
+
+		rt := RoleToken{Token: parts[0], Role: parts[1]}
+		if len(parts) == 3 {
+			rt.Name = parts[2]
+		}
+		tokens = append(tokens, rt)
+	}
+
+	return tokens, nil
+}
+
+// requestToken returns the token presented as X-Admin-Token or an
+// Authorization bearer token
+func requestToken(c *gin.Context) string {
+	if token := c.GetHeader("X-Admin-Token"); token != "" {
+		return token
+	}
+	return strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+}
+
+// Authenticate resolves the requester role from the presented token. The
+// AdminToken grants the admin role, the role tokens map further tokens to roles,
+// and session access tokens carry their own role. An issued API key in the
+// X-API-Key header grants the key's role, limited to its scopes by
+// EnforceAPIKeyScopes. Requests without a token are treated as public.
+// Unknown, tampered or revoked tokens are rejected and counted by the login
+// guard, which locks out clients that keep failing.
+func Authenticate(config AuthConfig, sessionService *services.SessionService, apiKeyService *services.APIKeyService, loginGuard *services.LoginGuard) gin.HandlerFunc {
+	adminToken := config.AdminToken
+	roleTokens := make(map[string]roleToken, len(config.RoleTokens))
+	for _, configured := range config.RoleTokens {
+		rt := roleToken{role: configured.Role, actor: configured.Role, key: tokenKeyID(configured.Token)}
+		if configured.Name != "" {
+			rt.actor = configured.Name
+		}
+		roleTokens[configured.Token] = rt
+	}
+
+	return func(c *gin.Context) {
+		role := RolePublic
+		actor := "anonymous"
+		apiKey := APIKeyAnonymous
+		token := requestToken(c)
+		presentedKey := c.GetHeader(APIKeyHeader)
+
+		if token != "" || presentedKey != "" {
+			if until, locked := loginGuard.LockedUntil(c.ClientIP()); locked {
+				abortLockedOut(c, until)
+				return
+			}
+		}
+
+		if presentedKey != "" {
+			key, err := apiKeyService.ValidateKey(presentedKey)
+			if err != nil {
+				// Expired keys are a client to reconfigure, not an attack
+				if !errors.Is(err, services.ErrAPIKeyExpired) {
+					loginGuard.RecordFailure(c.ClientIP(), c.RemoteIP(), "", c.Request.URL.Path, "API key rejected: "+err.Error())
+				}
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+					"error": err.Error(),
+				})
+				return
+			}
+
+			role = key.Role
+			actor = "api-key:" + key.Name
+			apiKey = APIKeyIssued + ":" + key.Prefix
+			c.Set(scopesContextKey, key.Scopes)
+		} else if services.IsAccessToken(token) {
+			claims, err := sessionService.ValidateAccessToken(token)
+			if err != nil {
+				// Expired tokens are routine and only need a refresh
+				if !errors.Is(err, services.ErrTokenExpired) {
+					loginGuard.RecordFailure(c.ClientIP(), c.RemoteIP(), "", c.Request.URL.Path, "access token rejected: "+err.Error())
+				}
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+					"error": err.Error(),
+				})
+				return
+			}
+
+			role = claims.Role
+			actor = claims.Actor
+			apiKey = APIKeySession + ":" + claims.Actor
+			c.Set(sessionContextKey, claims)
+		} else if token != "" {
+			matched := false
+			if adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1 {
+				role = RoleAdmin
+				actor = "admin"
+				apiKey = APIKeyAdminToken
+				matched = true
+			} else {
+				for candidate, rt := range roleTokens {
+					if subtle.ConstantTimeCompare([]byte(token), []byte(candidate)) == 1 {
+						role = rt.role
+						actor = rt.actor
+						apiKey = rt.key
+						matched = true
+						break
+					}
+				}
+			}
+
+			if !matched {
+				loginGuard.RecordFailure(c.ClientIP(), c.RemoteIP(), "", c.Request.URL.Path, "unknown token")
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+					"error": "invalid token",
+				})
+				return
+			}
+		}
+
+		c.Set(roleContextKey, role)
+		c.Set(actorContextKey, actor)
+		c.Set(apiKeyContextKey, apiKey)
+		c.Next()
+	}
+}
+
+// RateLimitAuth limits how often a client may call the login and refresh
+// endpoints
+func RateLimitAuth(loginGuard *services.LoginGuard) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if until, locked := loginGuard.LockedUntil(c.ClientIP()); locked {
+			abortLockedOut(c, until)
+			return
+		}
+
+		if ok, retryAfter := loginGuard.Allow(c.ClientIP(), c.RemoteIP(), c.Request.URL.Path); !ok {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"error": "too many authentication requests",
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func abortLockedOut(c *gin.Context, until time.Time) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(time.Until(until).Seconds()))))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+		"error": "too many failed authentication attempts, try again later",
+	})
+}
+
+// GetRole returns the requester role set by Authenticate
+func GetRole(c *gin.Context) string {
+	if role, ok := c.Get(roleContextKey); ok {
+		if s, ok := role.(string); ok {
+			return s
+		}
+	}
+	return RolePublic
+}
+
+// GetActor returns the identity of the requester set by Authenticate
+func GetActor(c *gin.Context) string {
+	if actor, ok := c.Get(actorContextKey); ok {
+		if s, ok := actor.(string); ok {
+			return s
+		}
+	}
+	return "anonymous"
+}
+
+// GetAPIKey returns the identifier of the credential the request was made
+// with, set by Authenticate: anonymous, admin_token, role_token:<hash prefix>,
+// session:<actor> or api_key:<key prefix>
+func GetAPIKey(c *gin.Context) string {
+	if key, ok := c.Get(apiKeyContextKey); ok {
+		if s, ok := key.(string); ok {
+			return s
+		}
+	}
+	return APIKeyAnonymous
+}
+
+// GetSession returns the access token claims when the request was
+// authenticated with a session token rather than a static token
+func GetSession(c *gin.Context) (*services.AccessClaims, bool) {
+	if claims, ok := c.Get(sessionContextKey); ok {
+		if s, ok := claims.(*services.AccessClaims); ok {
+			return s, true
+		}
+	}
+	return nil, false
+}
+
+// GetAPIKeyScopes returns the scopes of the issued API key the request was
+// made with, if it was
+func GetAPIKeyScopes(c *gin.Context) (models.APIKeyScopes, bool) {
+	if scopes, ok := c.Get(scopesContextKey); ok {
+		if s, ok := scopes.(models.APIKeyScopes); ok {
+			return s, true
+		}
+	}
+	return nil, false
+}
+
+// HasRole reports whether the requester has at least the given role
+func HasRole(c *gin.Context, role string) bool {
+	return RoleRank(GetRole(c)) >= RoleRank(role)
+}
+
+// RequireRole restricts a route to requesters with at least the given role
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !HasRole(c, role) {
+			status := http.StatusForbidden
+			if GetRole(c) == RolePublic {
+				status = http.StatusUnauthorized
+			}
+			c.AbortWithStatusJSON(status, gin.H{
+				"error": role + " access required",
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireAdmin restricts a route to administrators
+func RequireAdmin() gin.HandlerFunc {
+	return RequireRole(RoleAdmin)
+}
+
+// ScopedRoute is a route other than a read that API keys with Scope may call
+type ScopedRoute struct {
+	Method string
+	Path   string // as registered, e.g. /api/whitelist/:uuid
+	Scope  string
+}
+
+// EnforceAPIKeyScopes limits requests made with an issued API key to its
+// scopes: GET and HEAD requests need the read-only scope, and any other
+// request the scope its route is listed with; unlisted routes only accept
+// reads from API keys. Requests without an API key pass. It must run after
+// Authenticate.
+func EnforceAPIKeyScopes(routes []ScopedRoute) gin.HandlerFunc {
+	scoped := make(map[string]string, len(routes))
+	for _, route := range routes {
+		scoped[route.Method+" "+route.Path] = route.Scope
+	}
+
+	return func(c *gin.Context) {
+		scopes, ok := GetAPIKeyScopes(c)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		required := models.APIKeyScopeReadOnly
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			required = scoped[c.Request.Method+" "+c.FullPath()]
+			if required == "" {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+					"error": "API keys cannot make this request",
+				})
+				return
+			}
+		}
+
+		if !scopes.Has(required) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "the API key does not have the " + required + " scope",
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,138 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"vessel-tracker/database"
+	"vessel-tracker/models"
+	"vessel-tracker/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requester stands in for Authenticate, giving the request a role and, for
+// API keys, its scopes
+type requester struct {
+	role   string
+	scopes models.APIKeyScopes
+}
+
+// newWhitelistRouter routes the whitelist like main.go: reads open to
+// everyone, changes limited to rangers and API keys with the whitelist-admin
+// scope
+func newWhitelistRouter(who requester) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+
+	api := r.Group("/api",
+		func(c *gin.Context) {
+			c.Set(roleContextKey, who.role)
+			if who.scopes != nil {
+				c.Set(scopesContextKey, who.scopes)
+			}
+		},
+		EnforceAPIKeyScopes([]ScopedRoute{
+			{Method: http.MethodPost, Path: "/api/whitelist", Scope: models.APIKeyScopeWhitelistAdmin},
+			{Method: http.MethodDelete, Path: "/api/whitelist/:uuid", Scope: models.APIKeyScopeWhitelistAdmin},
+		}),
+	)
+	api.GET("/whitelist", ok)
+	api.POST("/vessels/lookup", ok)
+	ranger := api.Group("", RequireRole(RoleRanger))
+	ranger.POST("/whitelist", ok)
+	ranger.DELETE("/whitelist/:uuid", ok)
+	return r
+}
+
+func TestWhitelistChangesNeedRangerAndScope(t *testing.T) {
+	tests := []struct {
+		name   string
+		who    requester
+		method string
+		path   string
+		want   int
+	}{
+		{"anonymous read", requester{role: RolePublic}, http.MethodGet, "/api/whitelist", http.StatusOK},
+		{"anonymous add", requester{role: RolePublic}, http.MethodPost, "/api/whitelist", http.StatusUnauthorized},
+		{"anonymous remove", requester{role: RolePublic}, http.MethodDelete, "/api/whitelist/v1", http.StatusUnauthorized},
+		{"researcher add", requester{role: RoleResearcher}, http.MethodPost, "/api/whitelist", http.StatusForbidden},
+		{"ranger add", requester{role: RoleRanger}, http.MethodPost, "/api/whitelist", http.StatusOK},
+		{"admin remove", requester{role: RoleAdmin}, http.MethodDelete, "/api/whitelist/v1", http.StatusOK},
+		{"read-only key add", requester{role: RoleRanger, scopes: models.APIKeyScopes{models.APIKeyScopeReadOnly}}, http.MethodPost, "/api/whitelist", http.StatusForbidden},
+		{"ingest key remove", requester{role: RoleRanger, scopes: models.APIKeyScopes{models.APIKeyScopeIngest}}, http.MethodDelete, "/api/whitelist/v1", http.StatusForbidden},
+		{"whitelist-admin key add", requester{role: RoleRanger, scopes: models.APIKeyScopes{models.APIKeyScopeWhitelistAdmin}}, http.MethodPost, "/api/whitelist", http.StatusOK},
+		{"whitelist-admin key of a researcher", requester{role: RoleResearcher, scopes: models.APIKeyScopes{models.APIKeyScopeWhitelistAdmin}}, http.MethodPost, "/api/whitelist", http.StatusForbidden},
+		{"whitelist-admin key read", requester{role: RoleRanger, scopes: models.APIKeyScopes{models.APIKeyScopeWhitelistAdmin}}, http.MethodGet, "/api/whitelist", http.StatusForbidden},
+		{"whitelist-admin key unlisted write", requester{role: RoleRanger, scopes: models.APIKeyScopes{models.APIKeyScopeWhitelistAdmin}}, http.MethodPost, "/api/vessels/lookup", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			newWhitelistRouter(tt.who).ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+			if w.Code != tt.want {
+				t.Fatalf("%s %s answered %d, want %d: %s", tt.method, tt.path, w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
+// openTestDatabase migrates a fresh in-memory SQLite database, closed when
+// the test ends
+func openTestDatabase(t *testing.T) {
+	if err := database.InitDatabase(database.Config{Driver: "sqlite", Path: ":memory:", LogLevel: "silent"}); err != nil {
+		t.Fatalf("InitDatabase: %v", err)
+	}
+	t.Cleanup(func() {
+		database.Close()
+		database.DB = nil
+	})
+}
+
+func TestRepeatedAuthFailuresLockOutClient(t *testing.T) {
+	openTestDatabase(t)
+	guard := services.NewLoginGuard(services.LoginGuardConfig{
+		MaxFailures:     3,
+		FailureWindow:   time.Minute,
+		LockoutDuration: time.Hour,
+		RateLimit:       100,
+	}, services.NewAuditService())
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Authenticate(AuthConfig{AdminToken: "admin-secret"}, nil, nil, guard))
+	r.GET("/api/vessels", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	request := func(remoteAddr, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/vessels", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 1; i <= 3; i++ {
+		if w := request("192.0.2.10:4000", "guessed-token"); w.Code != http.StatusUnauthorized {
+			t.Fatalf("failure %d answered %d, want 401", i, w.Code)
+		}
+	}
+
+	// Locked out clients are refused even with the right token
+	w := request("192.0.2.10:4000", "admin-secret")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("locked out client answered %d with Retry-After %q, want 429 with a Retry-After", w.Code, w.Header().Get("Retry-After"))
+	}
+	if w := request("192.0.2.11:4000", "admin-secret"); w.Code != http.StatusOK {
+		t.Fatalf("another client answered %d, want 200", w.Code)
+	}
+
+	var lockouts []models.SecurityEvent
+	database.DB.Where("type = ?", models.SecurityEventLockout).Find(&lockouts)
+	if len(lockouts) != 1 || lockouts[0].ClientIP != "192.0.2.10" || !lockouts[0].Alert {
+		t.Fatalf("lockout events = %+v, want one alert for 192.0.2.10", lockouts)
+	}
+}
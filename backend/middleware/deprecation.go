@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"vessel-tracker/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DeprecationsPath lists every deprecated endpoint
+const DeprecationsPath = "/api/meta/deprecations"
+
+// Deprecation announces that an endpoint is slated for removal
+type Deprecation struct {
+	Method       string     `json:"method"`
+	Path         string     `json:"path"` // full route path, e.g. /api/vessels/:uuid
+	DeprecatedAt time.Time  `json:"deprecated_at"`
+	Sunset       *time.Time `json:"sunset,omitempty"`    // when the endpoint is removed
+	Successor    string     `json:"successor,omitempty"` // path of the endpoint to use instead
+	Note         string     `json:"note,omitempty"`
+}
+
+func deprecationKey(method, path string) string {
+	return method + " " + path
+}
+
+// AnnounceDeprecations marks the responses of deprecated endpoints with a
+// Deprecation header (RFC 9745), a Sunset header (RFC 8594) when a removal
+// date is set, and Link headers to the deprecation list and the successor.
+// Each call is logged with the requester so remaining users can be found.
+// It must run after Authenticate.
+func AnnounceDeprecations(deprecations []Deprecation) gin.HandlerFunc {
+	byRoute := make(map[string]Deprecation, len(deprecations))
+	for _, deprecation := range deprecations {
+		byRoute[deprecationKey(deprecation.Method, deprecation.Path)] = deprecation
+	}
+	logger := logging.Component("deprecation")
+
+	return func(c *gin.Context) {
+		deprecation, ok := byRoute[deprecationKey(c.Request.Method, c.FullPath())]
+		if !ok {
+			c.Next()
+			return
+		}
+
+		c.Header("Deprecation", "@"+strconv.FormatInt(deprecation.DeprecatedAt.Unix(), 10))
+		if deprecation.Sunset != nil {
+			c.Header("Sunset", deprecation.Sunset.UTC().Format(http.TimeFormat))
+		}
+		c.Writer.Header().Add("Link", fmt.Sprintf("<%s>; rel=\"deprecation\"", DeprecationsPath))
+		if deprecation.Successor != "" {
+			c.Writer.Header().Add("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", deprecation.Successor))
+		}
+
+		logger.Warn("Deprecated endpoint called", "method", deprecation.Method, "path", deprecation.Path, "actor", GetActor(c), "role", GetRole(c), "sunset", deprecation.Sunset)
+		c.Next()
+	}
+}
+
+// CheckDeprecations verifies that every deprecation names a registered route,
+// so a typo does not silently leave an endpoint unannounced
+func CheckDeprecations(routes gin.RoutesInfo, deprecations []Deprecation) error {
+	registered := make(map[string]bool, len(routes))
+	for _, route := range routes {
+		registered[deprecationKey(route.Method, route.Path)] = true
+	}
+
+	for _, deprecation := range deprecations {
+		if !registered[deprecationKey(deprecation.Method, deprecation.Path)] {
+			return fmt.Errorf("deprecation of unknown route %s %s", deprecation.Method, deprecation.Path)
+		}
+	}
+	return nil
+}
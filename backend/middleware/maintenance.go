@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"vessel-tracker/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RejectDuringMaintenance answers requests with 503 and a Retry-After header
+// while maintenance mode is enabled. Administrators, who run the maintenance,
+// are let through, as are the routes given by their full path, e.g. the
+// health check load balancers poll. It must run after Authenticate.
+func RejectDuringMaintenance(maintenance *services.MaintenanceService, exempt ...string) gin.HandlerFunc {
+	exemptPaths := make(map[string]bool, len(exempt))
+	for _, path := range exempt {
+		exemptPaths[path] = true
+	}
+
+	return func(c *gin.Context) {
+		if !maintenance.Enabled() || HasRole(c, RoleAdmin) || exemptPaths[c.FullPath()] {
+			c.Next()
+			return
+		}
+
+		status := maintenance.Status()
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(maintenance.RetryAfter().Seconds()))))
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+			"error":   "maintenance in progress",
+			"message": status.Message,
+			"until":   status.Until,
+		})
+	}
+}
@@ -0,0 +1,316 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxBufferedBody is the largest response body ResponseMetadata holds back to
+// compute its ETag and Content-Length; larger bodies are streamed as they are
+// written
+const maxBufferedBody = 1 << 20
+
+type headRequestKey struct{}
+
+// methodOrder is the order methods are listed in an Allow header
+var methodOrder = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+	http.MethodPatch, http.MethodDelete, http.MethodOptions,
+}
+
+// HeadAsGet serves HEAD requests through the GET route of the same path, so
+// every GET endpoint answers HEAD without registering it twice.
+// ResponseMetadata drops the body and keeps the headers GET would send.
+func HeadAsGet(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			r = r.WithContext(context.WithValue(r.Context(), headRequestKey{}, true))
+			r.Method = http.MethodGet
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func isHeadRequest(r *http.Request) bool {
+	head, _ := r.Context().Value(headRequestKey{}).(bool)
+	return head
+}
+
+// RequestMethod returns the method the client sent, HEAD for requests
+// HeadAsGet passed on as GET
+func RequestMethod(c *gin.Context) string {
+	if isHeadRequest(c.Request) {
+		return http.MethodHead
+	}
+	return c.Request.Method
+}
+
+// ResponseMetadata gives GET and HEAD responses a Content-Length and, for
+// successful responses up to 1 MiB whose handler set none, a weak ETag
+// computed from the body, answering a matching If-None-Match with 304 Not
+// Modified. HEAD responses carry the headers of the GET response without the
+// body. Streamed responses, which flush before they finish, are passed
+// through as they are written; a HEAD request for one ends at the first
+// flush.
+func ResponseMetadata() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet {
+			c.Next()
+			return
+		}
+
+		w := &metadataWriter{
+			ResponseWriter: c.Writer,
+			head:           isHeadRequest(c.Request),
+			ifNoneMatch:    c.GetHeader("If-None-Match"),
+		}
+		if w.head {
+			ctx, cancel := context.WithCancel(c.Request.Context())
+			defer cancel()
+			c.Request = c.Request.WithContext(ctx)
+			w.cancel = cancel
+		}
+
+		c.Writer = w
+		c.Next()
+		w.finish()
+		c.Writer = w.ResponseWriter
+	}
+}
+
+// metadataWriter holds back the status line and body of a response until the
+// handler finishes, so the headers can describe the complete body
+type metadataWriter struct {
+	gin.ResponseWriter
+	head        bool
+	ifNoneMatch string
+	cancel      context.CancelFunc
+
+	body      bytes.Buffer
+	size      int
+	overflow  bool // the body outgrew the buffer
+	committed bool // the headers were sent
+}
+
+func (w *metadataWriter) Write(data []byte) (int, error) {
+	if w.committed {
+		if w.head {
+			return len(data), nil
+		}
+		return w.ResponseWriter.Write(data)
+	}
+
+	w.size += len(data)
+	if w.overflow {
+		return len(data), nil
+	}
+	if w.body.Len()+len(data) > maxBufferedBody {
+		if w.head {
+			// Only the length matters now
+			w.overflow = true
+			w.body.Reset()
+			return len(data), nil
+		}
+		w.commit()
+		return w.ResponseWriter.Write(data)
+	}
+	return w.body.Write(data)
+}
+
+func (w *metadataWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// WriteHeaderNow is deferred until the handler finishes or flushes
+func (w *metadataWriter) WriteHeaderNow() {}
+
+func (w *metadataWriter) Written() bool {
+	return w.committed || w.size > 0 || w.ResponseWriter.Written()
+}
+
+func (w *metadataWriter) Flush() {
+	if !w.committed {
+		w.commit()
+		if w.head {
+			// A stream has no end to wait for
+			w.cancel()
+		}
+	}
+	w.ResponseWriter.Flush()
+}
+
+// commit sends the headers and the buffered body as they stand, without
+// describing the rest of the body
+func (w *metadataWriter) commit() {
+	w.committed = true
+	w.ResponseWriter.WriteHeaderNow()
+	if !w.head && w.body.Len() > 0 {
+		w.ResponseWriter.Write(w.body.Bytes())
+	}
+	w.body.Reset()
+}
+
+// finish sends the headers, now describing the complete body, and the body
+func (w *metadataWriter) finish() {
+	if w.committed {
+		return
+	}
+	w.committed = true
+
+	header := w.ResponseWriter.Header()
+	status := w.ResponseWriter.Status()
+	if status == http.StatusOK && !w.overflow && header.Get("ETag") == "" {
+		sum := sha256.Sum256(w.body.Bytes())
+		header.Set("ETag", `W/"`+hex.EncodeToString(sum[:16])+`"`)
+	}
+	if status == http.StatusOK && etagMatches(w.ifNoneMatch, header.Get("ETag")) {
+		for _, name := range []string{"Content-Type", "Content-Length", "Content-Encoding"} {
+			header.Del(name)
+		}
+		w.ResponseWriter.WriteHeader(http.StatusNotModified)
+		w.ResponseWriter.WriteHeaderNow()
+		return
+	}
+
+	if header.Get("Content-Length") == "" && bodyAllowed(status) {
+		header.Set("Content-Length", strconv.Itoa(w.size))
+	}
+	w.ResponseWriter.WriteHeaderNow()
+	if !w.head && w.body.Len() > 0 {
+		w.ResponseWriter.Write(w.body.Bytes())
+	}
+}
+
+// etagMatches applies the weak comparison of If-None-Match
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" || etag == "" {
+		return false
+	}
+	if strings.TrimSpace(ifNoneMatch) == "*" {
+		return true
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func bodyAllowed(status int) bool {
+	return status >= 200 && status != http.StatusNoContent && status != http.StatusNotModified
+}
+
+// routeMethods maps request paths to the methods registered for them
+type routeMethods struct {
+	routes []routePattern
+}
+
+type routePattern struct {
+	method   string
+	segments []string
+}
+
+func newRouteMethods(routes gin.RoutesInfo) *routeMethods {
+	table := &routeMethods{}
+	for _, route := range routes {
+		table.routes = append(table.routes, routePattern{
+			method:   route.Method,
+			segments: strings.Split(strings.Trim(route.Path, "/"), "/"),
+		})
+	}
+	return table
+}
+
+// matches reports whether a route path such as /api/vessels/:uuid or
+// /static/*filepath matches the request path's segments
+func (p routePattern) matches(segments []string) bool {
+	for i, segment := range p.segments {
+		if strings.HasPrefix(segment, "*") {
+			return true
+		}
+		if i >= len(segments) {
+			return false
+		}
+		if strings.HasPrefix(segment, ":") {
+			if segments[i] == "" {
+				return false
+			}
+			continue
+		}
+		if segment != segments[i] {
+			return false
+		}
+	}
+	return len(segments) == len(p.segments)
+}
+
+// allowed lists the methods the path can be requested with, HEAD wherever
+// GET is registered and OPTIONS everywhere, or none for an unknown path
+func (t *routeMethods) allowed(path string) []string {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	registered := map[string]bool{}
+	for _, route := range t.routes {
+		if route.matches(segments) {
+			registered[route.method] = true
+		}
+	}
+	if len(registered) == 0 {
+		return nil
+	}
+	if registered[http.MethodGet] {
+		registered[http.MethodHead] = true
+	}
+	registered[http.MethodOptions] = true
+
+	methods := make([]string, 0, len(registered))
+	for _, method := range methodOrder {
+		if registered[method] {
+			methods = append(methods, method)
+		}
+	}
+	return methods
+}
+
+// AllowedMethods handles requests for a known path with a method it is not
+// registered for, as the engine's NoMethod handler. OPTIONS requests, other
+// than CORS preflights which the CORS middleware answers, get 204 No Content
+// with the Allow header listing the path's methods; any other method gets
+// 405 Method Not Allowed with the same header. Routes are read on the first
+// request, once all are registered.
+func AllowedMethods(engine *gin.Engine) gin.HandlerFunc {
+	var once sync.Once
+	var table *routeMethods
+
+	return func(c *gin.Context) {
+		once.Do(func() {
+			table = newRouteMethods(engine.Routes())
+		})
+
+		methods := table.allowed(c.Request.URL.Path)
+		allow := strings.Join(methods, ", ")
+		if allow == "" {
+			allow = http.MethodOptions
+		}
+		c.Header("Allow", allow)
+
+		if c.Request.Method == http.MethodOptions {
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusMethodNotAllowed, gin.H{
+			"error":   "Method not allowed",
+			"details": fmt.Sprintf("%s %s is not supported, use %s", RequestMethod(c), c.Request.URL.Path, allow),
+		})
+	}
+}
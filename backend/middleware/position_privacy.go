@@ -0,0 +1,408 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"vessel-tracker/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+const positionPrivacyContextKey = "position_privacy"
+
+// Keys of the times a JSON object is dated by. An object is as recent as the
+// latest of them; one with an ended_at of null is ongoing, so current.
+var positionTimeKeys = []string{
+	"recorded_at", "detected_at", "occurred_at", "started_at", "last_seen_at",
+	"first_seen_at", "timestamp", "time", "last_updated", "last_position_utc",
+}
+
+// Layouts of the position times found in responses, the provider's first
+var positionTimeLayouts = []string{"2006-01-02 15:04:05", time.RFC3339Nano}
+
+// ProtectPublicPositions keeps live and precise vessel positions from the
+// public role. The request context limits every read of stored positions to
+// those older than the privacy delay, see services.InstallPositionCutoff.
+// Vessel positions in JSON responses and event streams are snapped to the
+// privacy grid for small pleasure craft and, as a safety net for positions
+// that were not read from the database, removed when younger than the delay
+// or undated. Other responses are left alone. It must run after
+// Authenticate.
+func ProtectPublicPositions(privacy *services.PositionPrivacy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !privacy.Enabled() || RoleRank(GetRole(c)) >= RoleRank(RoleResearcher) {
+			c.Next()
+			return
+		}
+
+		now := time.Now()
+		c.Set(positionPrivacyContextKey, privacy)
+		c.Request = c.Request.WithContext(privacy.PublicContext(c.Request.Context(), now))
+
+		w := &privacyWriter{ResponseWriter: c.Writer, filter: &positionFilter{privacy: privacy, now: now}}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+
+		if !w.buffering {
+			return
+		}
+		data, err := w.filter.filterJSON(w.body.Bytes())
+		if err != nil {
+			Logger(c).Error("Failed to protect public positions", "error", err)
+			w.ResponseWriter.Header().Del("Content-Length")
+			w.ResponseWriter.WriteHeader(http.StatusInternalServerError)
+			w.ResponseWriter.Write([]byte(`{"error":"Failed to prepare response"}`))
+			return
+		}
+		w.ResponseWriter.Header().Del("Content-Length")
+		w.ResponseWriter.Write(data)
+	}
+}
+
+// GetPositionPrivacy returns the privacy the request's positions are served
+// with, or nil when they are served live and precise
+func GetPositionPrivacy(c *gin.Context) *services.PositionPrivacy {
+	if value, ok := c.Get(positionPrivacyContextKey); ok {
+		if privacy, ok := value.(*services.PositionPrivacy); ok {
+			return privacy
+		}
+	}
+	return nil
+}
+
+// privacyWriter holds back JSON bodies until the handler finishes and filters
+// event streams one event at a time
+type privacyWriter struct {
+	gin.ResponseWriter
+	filter    *positionFilter
+	decided   bool
+	buffering bool // a JSON body
+	streaming bool // an event stream
+	body      bytes.Buffer
+}
+
+func (w *privacyWriter) decide() {
+	if w.decided {
+		return
+	}
+	w.decided = true
+	contentType := w.Header().Get("Content-Type")
+	w.buffering = strings.Contains(contentType, "json")
+	w.streaming = strings.HasPrefix(contentType, "text/event-stream")
+}
+
+func (w *privacyWriter) Write(data []byte) (int, error) {
+	w.decide()
+	switch {
+	case w.buffering:
+		return w.body.Write(data)
+	case w.streaming:
+		filtered, err := w.filter.filterEvents(data)
+		if err != nil || len(filtered) == 0 {
+			return len(data), err
+		}
+		if _, err := w.ResponseWriter.Write(filtered); err != nil {
+			return 0, err
+		}
+		return len(data), nil
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *privacyWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *privacyWriter) WriteHeaderNow() {
+	w.decide()
+	if !w.buffering {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *privacyWriter) Flush() {
+	w.decide()
+	if !w.buffering {
+		w.ResponseWriter.Flush()
+	}
+}
+
+// positionFilter removes and fuzzes the vessel positions of decoded JSON. A
+// position is an object with latitude and longitude (or lat and lon) that
+// belongs to a vessel: it, or an object enclosing it, names a vessel by
+// vessel_uuid, mmsi or a nested vessel, or it carries its vessel's uuid.
+type positionFilter struct {
+	privacy *services.PositionPrivacy
+	now     time.Time
+	fuzzed  map[string]bool
+}
+
+// positionScope is what the objects enclosing a value say about it
+type positionScope struct {
+	vessel   string // uuid, when known
+	ofVessel bool
+	at       time.Time
+	dated    bool
+}
+
+func (f *positionFilter) filterJSON(data []byte) ([]byte, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return data, nil
+	}
+	doc, err := decodeJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.classify(doc); err != nil {
+		return nil, err
+	}
+	doc, withheld := f.filterValue(doc, positionScope{})
+	if withheld {
+		if object, ok := doc.(map[string]interface{}); ok {
+			clearCoordinates(object)
+		}
+	}
+	return json.Marshal(doc)
+}
+
+// filterEvents filters the data of server-sent events, dropping events about
+// a withheld position
+func (f *positionFilter) filterEvents(data []byte) ([]byte, error) {
+	var out bytes.Buffer
+	for _, event := range bytes.SplitAfter(data, []byte("\n\n")) {
+		if len(event) == 0 {
+			continue
+		}
+		keep := true
+		lines := bytes.Split(event, []byte("\n"))
+		for i, line := range lines {
+			payload, ok := bytes.CutPrefix(line, []byte("data: "))
+			if !ok {
+				continue
+			}
+			doc, err := decodeJSON(payload)
+			if err != nil {
+				continue
+			}
+			if err := f.classify(doc); err != nil {
+				return nil, err
+			}
+			if _, withheld := f.filterValue(doc, positionScope{}); withheld {
+				keep = false
+				break
+			}
+			filtered, err := json.Marshal(doc)
+			if err != nil {
+				return nil, err
+			}
+			lines[i] = append([]byte("data: "), filtered...)
+		}
+		if keep {
+			out.Write(bytes.Join(lines, []byte("\n")))
+		}
+	}
+	return out.Bytes(), nil
+}
+
+func decodeJSON(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// classify looks up which of the vessels named in the document are fuzzed
+func (f *positionFilter) classify(doc interface{}) error {
+	seen := make(map[string]bool)
+	var collect func(value interface{})
+	collect = func(value interface{}) {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if uuid := vesselUUID(v); uuid != "" {
+				seen[uuid] = true
+			}
+			for _, child := range v {
+				collect(child)
+			}
+		case []interface{}:
+			for _, child := range v {
+				collect(child)
+			}
+		}
+	}
+	collect(doc)
+
+	uuids := make([]string, 0, len(seen))
+	for uuid := range seen {
+		if _, known := f.fuzzed[uuid]; !known {
+			uuids = append(uuids, uuid)
+		}
+	}
+	if len(uuids) == 0 {
+		return nil
+	}
+
+	fuzzed, err := f.privacy.FuzzedVessels(uuids)
+	if err != nil {
+		return err
+	}
+	if f.fuzzed == nil {
+		f.fuzzed = make(map[string]bool)
+	}
+	for _, uuid := range uuids {
+		f.fuzzed[uuid] = fuzzed[uuid]
+	}
+	return nil
+}
+
+// filterValue removes withheld positions from the arrays and objects within
+// value and fuzzes the kept ones, returning the filtered value and whether
+// value is itself withheld
+func (f *positionFilter) filterValue(value interface{}, scope positionScope) (interface{}, bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return v, f.filterObject(v, scope)
+	case []interface{}:
+		kept := make([]interface{}, 0, len(v))
+		for _, child := range v {
+			if filtered, withheld := f.filterValue(child, scope); !withheld {
+				kept = append(kept, filtered)
+			}
+		}
+		return kept, false
+	}
+	return value, false
+}
+
+func (f *positionFilter) filterObject(object map[string]interface{}, scope positionScope) bool {
+	if uuid := vesselUUID(object); uuid != "" {
+		scope.vessel = uuid
+		scope.ofVessel = true
+	}
+	if _, ok := object["mmsi"]; ok {
+		scope.ofVessel = true
+	}
+	if _, ok := object["vessel_uuid"]; ok {
+		scope.ofVessel = true
+	}
+	if at, ok := f.objectTime(object); ok {
+		scope.at = at
+		scope.dated = true
+	}
+
+	for key, child := range object {
+		filtered, withheld := f.filterValue(child, scope)
+		if withheld {
+			filtered = nil
+		}
+		object[key] = filtered
+	}
+
+	latKey, lonKey, ok := coordinateKeys(object)
+	if !ok || !scope.ofVessel {
+		return false
+	}
+	if !scope.dated || f.privacy.Withheld(scope.at, f.now) {
+		return true
+	}
+	if f.fuzzed[scope.vessel] {
+		lat, latOK := number(object[latKey])
+		lon, lonOK := number(object[lonKey])
+		if latOK && lonOK {
+			object[latKey], object[lonKey] = f.privacy.Fuzz(lat, lon)
+		}
+	}
+	return false
+}
+
+// vesselUUID returns the vessel an object is about: its vessel_uuid, the uuid
+// of a nested vessel, or its own uuid when it carries a vessel's mmsi or
+// position
+func vesselUUID(object map[string]interface{}) string {
+	if uuid, ok := object["vessel_uuid"].(string); ok && uuid != "" {
+		return uuid
+	}
+	if vessel, ok := object["vessel"].(map[string]interface{}); ok {
+		if uuid, ok := vessel["uuid"].(string); ok && uuid != "" {
+			return uuid
+		}
+	}
+	if uuid, ok := object["uuid"].(string); ok && uuid != "" {
+		_, hasMMSI := object["mmsi"]
+		_, _, hasPosition := coordinateKeys(object)
+		if hasMMSI || hasPosition {
+			return uuid
+		}
+	}
+	return ""
+}
+
+func coordinateKeys(object map[string]interface{}) (string, string, bool) {
+	for _, keys := range [][2]string{{"latitude", "longitude"}, {"lat", "lon"}} {
+		_, hasLat := object[keys[0]]
+		_, hasLon := object[keys[1]]
+		if hasLat && hasLon {
+			return keys[0], keys[1], true
+		}
+	}
+	return "", "", false
+}
+
+func clearCoordinates(object map[string]interface{}) {
+	if latKey, lonKey, ok := coordinateKeys(object); ok {
+		object[latKey] = nil
+		object[lonKey] = nil
+	}
+}
+
+// objectTime returns the latest time an object is dated by
+func (f *positionFilter) objectTime(object map[string]interface{}) (time.Time, bool) {
+	if ended, ok := object["ended_at"]; ok && ended == nil {
+		return f.now, true
+	}
+
+	var latest time.Time
+	found := false
+	consider := func(at time.Time) {
+		if !found || at.After(latest) {
+			latest = at
+			found = true
+		}
+	}
+
+	for _, key := range positionTimeKeys {
+		text, ok := object[key].(string)
+		if !ok || text == "" {
+			continue
+		}
+		for _, layout := range positionTimeLayouts {
+			if at, err := time.Parse(layout, text); err == nil {
+				consider(at)
+				break
+			}
+		}
+	}
+	if epoch, ok := number(object["last_position_epoch"]); ok && epoch > 0 {
+		consider(time.Unix(int64(epoch), 0))
+	}
+	return latest, found
+}
+
+func number(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case json.Number:
+		f, err := strconv.ParseFloat(string(v), 64)
+		return f, err == nil
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
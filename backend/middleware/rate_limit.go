@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"vessel-tracker/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Rate limit headers sent with every limited response
+const (
+	RateLimitLimitHeader     = "X-RateLimit-Limit"
+	RateLimitRemainingHeader = "X-RateLimit-Remaining"
+)
+
+// RateLimit holds each client to the request rate of the limiter, answering
+// requests over it with 429 and a Retry-After header. Requests with
+// credentials are limited per API key, token or session, anonymous ones per
+// client IP: the peer address, or the X-Forwarded-For address when the peer
+// is one of the engine's trusted proxies (TRUSTED_PROXIES), so clients cannot
+// spread their requests over made-up addresses. Administrators are not
+// limited, nor are the routes given by their full path, e.g. the health check
+// load balancers poll. It must run after Authenticate.
+func RateLimit(limiter *services.RateLimiter, exempt ...string) gin.HandlerFunc {
+	exemptPaths := make(map[string]bool, len(exempt))
+	for _, path := range exempt {
+		exemptPaths[path] = true
+	}
+
+	return func(c *gin.Context) {
+		if !limiter.Config().Enabled || HasRole(c, RoleAdmin) || exemptPaths[c.FullPath()] {
+			c.Next()
+			return
+		}
+
+		client, keyed := "ip:"+c.ClientIP(), false
+		if key := GetAPIKey(c); key != APIKeyAnonymous {
+			client, keyed = key, true
+		}
+
+		result := limiter.Allow(client, keyed)
+		c.Header(RateLimitLimitHeader, strconv.Itoa(result.Limit))
+		c.Header(RateLimitRemainingHeader, strconv.Itoa(result.Remaining))
+		if !result.Allowed {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(result.RetryAfter.Seconds()))))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"error": "rate limit exceeded, try again later",
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"vessel-tracker/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRateLimitRefusesClientOnceBurstIsSpent(t *testing.T) {
+	limiter := services.NewRateLimiter(services.RateLimitConfig{
+		Enabled:      true,
+		PerMinute:    1,
+		Burst:        3,
+		KeyPerMinute: 1,
+		KeyBurst:     5,
+	})
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	if err := r.SetTrustedProxies(nil); err != nil {
+		t.Fatalf("SetTrustedProxies: %v", err)
+	}
+	r.Use(func(c *gin.Context) {
+		// Stands in for Authenticate
+		c.Set(roleContextKey, c.GetHeader("X-Test-Role"))
+		if key := c.GetHeader("X-Test-Key"); key != "" {
+			c.Set(apiKeyContextKey, key)
+		}
+	}, RateLimit(limiter, "/health"))
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	r.GET("/api/vessels", ok)
+	r.GET("/health", ok)
+
+	request := func(path, remoteAddr string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = remoteAddr
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	anonymous := map[string]string{"X-Test-Role": RolePublic}
+
+	for i := 1; i <= 3; i++ {
+		w := request("/api/vessels", "192.0.2.20:4000", anonymous)
+		if w.Code != http.StatusOK {
+			t.Fatalf("request %d within the burst answered %d, want 200", i, w.Code)
+		}
+		if w.Header().Get(RateLimitLimitHeader) != "3" {
+			t.Fatalf("%s = %q, want 3", RateLimitLimitHeader, w.Header().Get(RateLimitLimitHeader))
+		}
+	}
+
+	w := request("/api/vessels", "192.0.2.20:4000", anonymous)
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("request over the burst answered %d with Retry-After %q, want 429 with a Retry-After", w.Code, w.Header().Get("Retry-After"))
+	}
+	if w.Header().Get(RateLimitRemainingHeader) != "0" {
+		t.Fatalf("%s = %q, want 0", RateLimitRemainingHeader, w.Header().Get(RateLimitRemainingHeader))
+	}
+
+	// A made-up forwarded address from an untrusted peer is not a new client
+	spoofed := map[string]string{"X-Test-Role": RolePublic, "X-Forwarded-For": "198.51.100.7"}
+	if w := request("/api/vessels", "192.0.2.20:4000", spoofed); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("request with a spoofed X-Forwarded-For answered %d, want 429", w.Code)
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		remoteAddr string
+		headers    map[string]string
+	}{
+		{"another address", "/api/vessels", "192.0.2.21:4000", anonymous},
+		{"API key from the limited address", "/api/vessels", "192.0.2.20:4000", map[string]string{"X-Test-Role": RoleRanger, "X-Test-Key": APIKeyIssued + ":abcd1234"}},
+		{"administrator", "/api/vessels", "192.0.2.20:4000", map[string]string{"X-Test-Role": RoleAdmin}},
+		{"exempt route", "/health", "192.0.2.20:4000", anonymous},
+	}
+	for _, tt := range tests {
+		if w := request(tt.path, tt.remoteAddr, tt.headers); w.Code != http.StatusOK {
+			t.Fatalf("%s answered %d, want 200", tt.name, w.Code)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"time"
+	"vessel-tracker/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TrackUsage counts each request per API key and route, so the integrations
+// still using an endpoint are known before it changes. Requests to unknown
+// routes are not counted. It must run after Authenticate.
+func TrackUsage(tracker *services.APIUsageTracker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			return
+		}
+		tracker.Record(GetAPIKey(c), GetActor(c), GetRole(c), RequestMethod(c), route, c.Writer.Status(), time.Now())
+	}
+}
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// AnchoringEvent records a period during which a vessel stayed stationary
+// inside the park. EndedAt is nil while the vessel is still anchored. A
+// vessel holding its position against a strong current is anchored or
+// moored, where in slack water it may merely be stopped.
+type AnchoringEvent struct {
+	ID                uint       `gorm:"primaryKey" json:"id"`
+	VesselUUID        string     `gorm:"index;not null" json:"vessel_uuid"`
+	ParkID            uint       `gorm:"index;not null;default:0" json:"park_id"`
+	StartedAt         time.Time  `gorm:"index;not null" json:"started_at"`
+	EndedAt           *time.Time `gorm:"index" json:"ended_at"`
+	LastSeenAt        time.Time  `json:"last_seen_at"`
+	Latitude          float64    `gorm:"type:decimal(10,6)" json:"latitude"`
+	Longitude         float64    `gorm:"type:decimal(10,6)" json:"longitude"`
+	DriftRadiusMeters float64    `gorm:"type:decimal(10,2)" json:"drift_radius_meters"`
+	DwellMinutes      float64    `gorm:"type:decimal(10,2)" json:"dwell_minutes"`
+	PositionCount     int        `json:"position_count"`
+	MaxCurrentKnots   *float64   `gorm:"type:decimal(6,2)" json:"max_current_knots"` // strongest tidal current the vessel held against, nil when unknown
+	AnchorLatitude    float64    `gorm:"type:decimal(10,6)" json:"anchor_latitude"`  // center of the fixes that opened the event, where the anchor was set
+	AnchorLongitude   float64    `gorm:"type:decimal(10,6)" json:"anchor_longitude"` // unlike Latitude and Longitude it does not follow the vessel
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+
+	Vessel VesselRecord `gorm:"foreignKey:VesselUUID;references:UUID" json:"vessel,omitempty"`
+}
+
+// AnchoredVessel is a vessel anchored in the park now, with how long it has
+// been anchored and the habitat layers under its position
+type AnchoredVessel struct {
+	AnchoringEvent
+	AnchoredMinutes float64        `json:"anchored_minutes"` // from the start of the event until now
+	Habitat         []HabitatLayer `json:"habitat"`
+	OnPosidonia     bool           `json:"on_posidonia"` // a posidonia layer is among the habitat
+}
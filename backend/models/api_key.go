@@ -0,0 +1,84 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// API key scopes, the kinds of requests a machine client may make
+const (
+	APIKeyScopeReadOnly       = "read-only"       // GET and HEAD requests
+	APIKeyScopeWhitelistAdmin = "whitelist-admin" // whitelist changes
+	APIKeyScopeIngest         = "ingest"          // pushing vessel positions
+)
+
+// IsAPIKeyScope reports whether scope is a known API key scope
+func IsAPIKeyScope(scope string) bool {
+	switch scope {
+	case APIKeyScopeReadOnly, APIKeyScopeWhitelistAdmin, APIKeyScopeIngest:
+		return true
+	}
+	return false
+}
+
+// APIKeyScopes is the scopes of a key, stored comma separated
+type APIKeyScopes []string
+
+// Has reports whether the scopes include scope
+func (s APIKeyScopes) Has(scope string) bool {
+	for _, candidate := range s {
+		if candidate == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Value stores the scopes comma separated
+func (s APIKeyScopes) Value() (driver.Value, error) {
+	return strings.Join(s, ","), nil
+}
+
+// Scan reads comma separated scopes
+func (s *APIKeyScopes) Scan(value interface{}) error {
+	var text string
+	switch data := value.(type) {
+	case []byte:
+		text = string(data)
+	case string:
+		text = data
+	default:
+		return fmt.Errorf("unsupported scopes value type %T", value)
+	}
+
+	*s = APIKeyScopes{}
+	for _, scope := range strings.Split(text, ",") {
+		if scope != "" {
+			*s = append(*s, scope)
+		}
+	}
+	return nil
+}
+
+// APIKey lets a machine client such as a dashboard or patrol app call the API
+// with the X-API-Key header. Requests made with it have the key's role and
+// are limited to its scopes. Only a hash of the key is stored; the prefix
+// tells keys apart in listings and usage statistics.
+type APIKey struct {
+	ID           uint         `gorm:"primaryKey" json:"id"`
+	Name         string       `gorm:"not null" json:"name"`
+	Prefix       string       `gorm:"uniqueIndex;not null" json:"prefix"`
+	KeyHash      string       `gorm:"uniqueIndex;not null" json:"-"`
+	Role         string       `gorm:"not null" json:"role"`
+	Scopes       APIKeyScopes `gorm:"type:text;not null" json:"scopes"`
+	ExpiresAt    *time.Time   `json:"expires_at"`
+	LastUsedAt   *time.Time   `json:"last_used_at"`
+	CreatedBy    string       `json:"created_by"`
+	RevokedAt    *time.Time   `gorm:"index" json:"revoked_at,omitempty"`
+	RevokedBy    string       `json:"revoked_by,omitempty"`
+	RevokeReason string       `json:"revoke_reason,omitempty"`
+	CreatedAt    time.Time    `json:"created_at"`
+	UpdatedAt    time.Time    `json:"updated_at"`
+}
@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// Appeal states
+const (
+	AppealStatusFiled       = "filed"
+	AppealStatusUnderReview = "under_review"
+	AppealStatusDecided     = "decided"
+	AppealStatusWithdrawn   = "withdrawn"
+)
+
+// Appeal outcomes, set when an appeal is decided
+const (
+	AppealOutcomeUpheld     = "upheld"     // violation confirmed, appeal rejected
+	AppealOutcomeOverturned = "overturned" // violation annulled
+	AppealOutcomeReduced    = "reduced"    // violation confirmed with reduced sanction
+)
+
+// ViolationAppeal is an appeal lodged by the vessel owner against a violation
+type ViolationAppeal struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	ViolationID uint       `gorm:"index;not null" json:"violation_id"`
+	FiledAt     time.Time  `gorm:"not null" json:"filed_at"`
+	FiledBy     string     `json:"filed_by" role:"ranger"`
+	Grounds     string     `gorm:"type:text" json:"grounds" role:"ranger"`
+	Status      string     `gorm:"index;not null" json:"status"`
+	Decision    string     `gorm:"type:text" json:"decision" role:"ranger"`
+	Outcome     string     `gorm:"index" json:"outcome"`
+	DecidedAt   *time.Time `json:"decided_at"`
+	DecidedBy   string     `json:"decided_by" role:"ranger"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+
+	Violation *Violation `gorm:"foreignKey:ViolationID" json:"violation,omitempty"`
+}
+
+// AppealOutcomeStats counts appeal outcomes for a violation type
+type AppealOutcomeStats struct {
+	ViolationType string `json:"violation_type"`
+	Total         int64  `json:"total"`
+	Pending       int64  `json:"pending"`
+	Upheld        int64  `json:"upheld"`
+	Overturned    int64  `json:"overturned"`
+	Reduced       int64  `json:"reduced"`
+	Withdrawn     int64  `json:"withdrawn"`
+}
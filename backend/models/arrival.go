@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// ParkArrival records the first time a vessel was seen inside a park. A
+// vessel counts as seen before when either its UUID or its MMSI has an
+// arrival, so a vessel first tracked under a provisional AIS identity is not
+// announced twice. Seeded arrivals were reconstructed from the position
+// history when arrivals started being tracked and are not announced.
+type ParkArrival struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	ParkID      uint      `gorm:"uniqueIndex:idx_park_arrival_vessel;not null" json:"park_id"`
+	VesselUUID  string    `gorm:"uniqueIndex:idx_park_arrival_vessel;not null" json:"vessel_uuid"`
+	MMSI        string    `gorm:"index" json:"mmsi"`
+	Latitude    float64   `gorm:"type:decimal(10,6)" json:"latitude"`
+	Longitude   float64   `gorm:"type:decimal(10,6)" json:"longitude"`
+	Speed       float64   `gorm:"type:decimal(8,2)" json:"speed"`
+	FirstSeenAt time.Time `gorm:"index;not null" json:"first_seen_at"`
+	Seeded      bool      `json:"seeded"`
+	CreatedAt   time.Time `json:"created_at"`
+
+	Vessel VesselRecord `gorm:"foreignKey:VesselUUID;references:UUID" json:"vessel,omitempty"`
+}
+
+// ArrivalDigestDay lists the vessels seen in a park for the first time on
+// one UTC day
+type ArrivalDigestDay struct {
+	Date     string        `json:"date"`
+	Count    int           `json:"count"`
+	Arrivals []ParkArrival `json:"arrivals"`
+}
+
+// ArrivalDigest summarizes the new arrivals in a park per day
+type ArrivalDigest struct {
+	Park  Park               `json:"park"`
+	Start time.Time          `json:"start"`
+	End   time.Time          `json:"end"`
+	Total int                `json:"total"`
+	Days  []ArrivalDigestDay `json:"days"`
+}
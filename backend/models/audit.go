@@ -0,0 +1,82 @@
+package models
+
+import "time"
+
+// AccessLog records a read of a sensitive record (personal or case data)
+type AccessLog struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	Actor      string    `gorm:"index;not null" json:"actor"`
+	Role       string    `json:"role"`
+	RecordType string    `gorm:"index:idx_access_logs_record;not null" json:"record_type"`
+	RecordID   string    `gorm:"index:idx_access_logs_record" json:"record_id"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	ClientIP   string    `json:"client_ip"`
+	Status     int       `json:"status"`
+	AccessedAt time.Time `gorm:"index;not null" json:"accessed_at"`
+}
+
+// Security event types
+const (
+	SecurityEventLoginFailed = "login_failed"
+	SecurityEventLockout     = "lockout"
+	SecurityEventRateLimited = "rate_limited"
+	SecurityEventTokenReuse  = "refresh_token_reuse"
+
+	// SecurityEventGeoLayerUnavailable alerts that a boundary layer failed
+	// to load and the checks depending on it are disabled
+	SecurityEventGeoLayerUnavailable = "geo_layer_unavailable"
+)
+
+// SecurityEvent records an authentication failure or anomaly. Events flagged
+// as alerts need an administrator's attention.
+type SecurityEvent struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Type      string    `gorm:"index;not null" json:"type"`
+	Actor     string    `gorm:"index" json:"actor,omitempty"`
+	ClientIP  string    `gorm:"index" json:"client_ip"`
+	PeerIP    string    `json:"peer_ip,omitempty"` // address the request came from, a proxy when ClientIP was forwarded
+	Path      string    `json:"path"`
+	Details   string    `json:"details"`
+	Alert     bool      `gorm:"index" json:"alert"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+// ProviderResponse is a raw vessel data provider response kept, gzip
+// compressed, so records derived from it can be checked against what the
+// provider actually sent. Payload is left out of listings.
+type ProviderResponse struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	FetchRunID string    `gorm:"index;not null" json:"fetch_run_id"`
+	Provider   string    `json:"provider"`
+	Endpoint   string    `json:"endpoint"` // request path and query, without the API key
+	StatusCode int       `json:"status_code"`
+	Size       int       `json:"size"` // uncompressed payload bytes
+	Payload    []byte    `json:"payload,omitempty"`
+	RecordedAt time.Time `gorm:"index;not null" json:"recorded_at"`
+}
+
+// ProviderCreditUsage counts the credits spent on a paid vessel data provider
+// on one UTC day, so the daily quota survives restarts
+type ProviderCreditUsage struct {
+	Day       string    `gorm:"primaryKey;size:10" json:"day"` // YYYY-MM-DD
+	Provider  string    `gorm:"primaryKey;size:32" json:"provider"`
+	Credits   int64     `gorm:"not null;default:0" json:"credits"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// APIUsage counts the requests one API key made to one endpoint, so the
+// integrations still calling an endpoint are known before it is changed or
+// removed. APIKey identifies the credential without revealing it, e.g.
+// role_token:1f2e3d4c; Route is the route pattern such as /api/vessels/:uuid.
+type APIUsage struct {
+	APIKey      string    `gorm:"primaryKey;size:64" json:"api_key"`
+	Method      string    `gorm:"primaryKey;size:8" json:"method"`
+	Route       string    `gorm:"primaryKey;size:191" json:"route"`
+	Actor       string    `gorm:"index" json:"actor"`
+	Role        string    `json:"role"`
+	Requests    int64     `gorm:"not null;default:0" json:"requests"`
+	Errors      int64     `gorm:"not null;default:0" json:"errors"` // responses with status 400 or above
+	FirstUsedAt time.Time `json:"first_used_at"`
+	LastUsedAt  time.Time `gorm:"index" json:"last_used_at"`
+}
@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// BoundaryAreaChange compares the area of an uploaded boundary layer with the
+// active one. Added and removed areas are estimated by sampling both layers
+// on a grid, so they are approximate for small or narrow changes.
+type BoundaryAreaChange struct {
+	ActiveKm2   float64 `json:"active_km2"`
+	RevisionKm2 float64 `json:"revision_km2"`
+	ChangeKm2   float64 `json:"change_km2"`
+	ChangePct   float64 `json:"change_pct"` // of the active area, 0 when there is no active layer
+	AddedKm2    float64 `json:"added_km2"`
+	RemovedKm2  float64 `json:"removed_km2"`
+}
+
+// BoundaryVesselChange is a vessel whose stored positions would be classified
+// differently under an uploaded boundary layer
+type BoundaryVesselChange struct {
+	VesselUUID       string    `json:"vessel_uuid"`
+	Name             string    `json:"name,omitempty"`
+	MMSI             string    `json:"mmsi,omitempty"`
+	Positions        int       `json:"positions"` // positions of the vessel checked
+	Entered          int       `json:"entered"`   // positions outside the zone now that would be inside it
+	Left             int       `json:"left"`      // positions inside the zone now that would be outside it
+	FirstChangedAt   time.Time `json:"first_changed_at"`
+	LastChangedAt    time.Time `json:"last_changed_at"`
+	ChangedLatitude  float64   `json:"changed_latitude"` // the latest reclassified position
+	ChangedLongitude float64   `json:"changed_longitude"`
+}
+
+// BoundaryPreview is the difference an uploaded boundary layer would make if
+// it replaced the active one: its area, and the stored positions and vessels
+// whose park or buffer zone classification would change
+type BoundaryPreview struct {
+	Park             string                 `json:"park"`
+	Layer            string                 `json:"layer"`
+	ActiveFeatures   int                    `json:"active_features"`
+	RevisionFeatures int                    `json:"revision_features"`
+	Area             BoundaryAreaChange     `json:"area"`
+	Start            time.Time              `json:"start"`
+	End              time.Time              `json:"end"`
+	PositionsChecked int                    `json:"positions_checked"`
+	Truncated        bool                   `json:"truncated"` // more positions were stored than were checked
+	PositionsChanged int                    `json:"positions_changed"`
+	Entered          int                    `json:"entered"`
+	Left             int                    `json:"left"`
+	VesselsChecked   int                    `json:"vessels_checked"`
+	VesselsChanged   int                    `json:"vessels_changed"`
+	Vessels          []BoundaryVesselChange `json:"vessels"` // most reclassified positions first, up to the limit
+}
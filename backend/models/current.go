@@ -0,0 +1,52 @@
+package models
+
+import "math"
+
+// TidalCurrent is the tidal current predicted at a place and time.
+// DirectionDeg is where the current flows toward, in degrees true.
+type TidalCurrent struct {
+	SpeedKnots   float64 `json:"speed_knots"`
+	DirectionDeg float64 `json:"direction_deg"`
+}
+
+// newTidalCurrent returns the current stored in a pair of nullable columns
+func newTidalCurrent(speed, direction *float64) *TidalCurrent {
+	if speed == nil || direction == nil {
+		return nil
+	}
+	return &TidalCurrent{SpeedKnots: *speed, DirectionDeg: *direction}
+}
+
+// Columns returns the current as the pair of nullable columns it is stored
+// in, both nil for an unknown current
+func (c *TidalCurrent) Columns() (*float64, *float64) {
+	if c == nil {
+		return nil, nil
+	}
+	speed, direction := c.SpeedKnots, c.DirectionDeg
+	return &speed, &direction
+}
+
+// ThroughWater estimates the speed through the water of a vessel making speed
+// knots over ground on course, by taking the current's drift out of its
+// velocity over ground. Without a current it is the speed over ground.
+func (c *TidalCurrent) ThroughWater(speed, course float64) float64 {
+	if c == nil {
+		return speed
+	}
+	courseRad := course * math.Pi / 180
+	currentRad := c.DirectionDeg * math.Pi / 180
+	east := speed*math.Sin(courseRad) - c.SpeedKnots*math.Sin(currentRad)
+	north := speed*math.Cos(courseRad) - c.SpeedKnots*math.Cos(currentRad)
+	return math.Round(math.Hypot(east, north)*100) / 100
+}
+
+// SpeedThroughWater returns ThroughWater as a nullable column, nil without a
+// current
+func (c *TidalCurrent) SpeedThroughWater(speed, course float64) *float64 {
+	if c == nil {
+		return nil
+	}
+	stw := c.ThroughWater(speed, course)
+	return &stw
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// DailyParkAggregate summarizes one UTC day of a park. It is written once
+// the day is over, before the retention job may delete the day's positions,
+// so the figures outlive the raw data.
+type DailyParkAggregate struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	ParkID          uint      `gorm:"uniqueIndex:idx_daily_park_day;not null" json:"park_id"`
+	Day             string    `gorm:"uniqueIndex:idx_daily_park_day;not null" json:"day"` // YYYY-MM-DD, UTC
+	Positions       int64     `json:"positions"`
+	PositionsInPark int64     `json:"positions_in_park"`
+	PositionsOnLand int64     `json:"positions_on_land"`
+	Vessels         int       `json:"vessels"`
+	VesselsInPark   int       `json:"vessels_in_park"`
+	MaxSpeedKnots   float64   `json:"max_speed_knots"`
+	ParkEntries     int64     `json:"park_entries"`
+	Violations      int64     `json:"violations"`
+	FinalizedAt     time.Time `json:"finalized_at"`
+}
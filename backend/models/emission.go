@@ -0,0 +1,69 @@
+package models
+
+import "time"
+
+// EmissionTotals is the estimated fuel burn and emissions of vessels while
+// inside a park
+type EmissionTotals struct {
+	VesselDays  int     `json:"vessel_days"` // a vessel inside the park on two days counts twice
+	VesselHours float64 `json:"vessel_hours"`
+	EnergyKWh   float64 `gorm:"column:energy_kwh" json:"energy_kwh"`
+	FuelKg      float64 `json:"fuel_kg"`
+	CO2Kg       float64 `gorm:"column:co2_kg" json:"co2_kg"`
+	NOxKg       float64 `gorm:"column:nox_kg" json:"nox_kg"`
+	SOxKg       float64 `gorm:"column:sox_kg" json:"sox_kg"`
+	PMKg        float64 `gorm:"column:pm_kg" json:"pm_kg"`
+}
+
+// Add sums other into t
+func (t *EmissionTotals) Add(other EmissionTotals) {
+	t.VesselDays += other.VesselDays
+	t.VesselHours += other.VesselHours
+	t.EnergyKWh += other.EnergyKWh
+	t.FuelKg += other.FuelKg
+	t.CO2Kg += other.CO2Kg
+	t.NOxKg += other.NOxKg
+	t.SOxKg += other.SOxKg
+	t.PMKg += other.PMKg
+}
+
+// DailyParkEmission is the estimate for one size class of vessels in a park
+// on one UTC day. Like DailyParkAggregate it is written once the day is over,
+// before the retention job may delete the positions it is estimated from.
+type DailyParkEmission struct {
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	ParkID      uint           `gorm:"uniqueIndex:idx_emission_park_day_class;not null" json:"park_id"`
+	Day         string         `gorm:"uniqueIndex:idx_emission_park_day_class;not null" json:"day"` // YYYY-MM-DD, UTC
+	SizeClass   string         `gorm:"uniqueIndex:idx_emission_park_day_class;not null" json:"size_class"`
+	Totals      EmissionTotals `gorm:"embedded" json:"totals"`
+	FinalizedAt time.Time      `json:"finalized_at"`
+}
+
+// EmissionClassTotals is the estimate for one size class over a month
+type EmissionClassTotals struct {
+	SizeClass string         `json:"size_class"`
+	Totals    EmissionTotals `json:"totals"`
+}
+
+// EmissionMonth is the estimate for a park over one calendar month (UTC).
+// Final is set once every day of the month is over and finalized; days not
+// finalized yet are estimated from the positions still stored.
+type EmissionMonth struct {
+	Month         string                `json:"month"` // YYYY-MM
+	Final         bool                  `json:"final"`
+	DaysFinalized int                   `json:"days_finalized"`
+	DaysEstimated int                   `json:"days_estimated"`
+	Totals        EmissionTotals        `json:"totals"`
+	Classes       []EmissionClassTotals `json:"classes"`
+}
+
+// EmissionReport is the monthly estimate of a park's vessel emissions for its
+// environmental reporting
+type EmissionReport struct {
+	Park        string          `json:"park"`
+	From        string          `json:"from"` // YYYY-MM
+	To          string          `json:"to"`   // YYYY-MM
+	Months      []EmissionMonth `json:"months"`
+	Totals      EmissionTotals  `json:"totals"`
+	GeneratedAt time.Time       `json:"generated_at"`
+}
@@ -0,0 +1,64 @@
+package models
+
+import "time"
+
+// Classifications of an explained position
+const (
+	ClassificationWhitelisted = "whitelisted"
+	ClassificationViolation   = "violation"
+	ClassificationCompliant   = "compliant"
+	ClassificationOutsidePark = "outside_park"
+)
+
+// PositionExplanation re-evaluates a stored position against the detection
+// rules and shows each step: the zones containing it, the whitelist decision,
+// every rule with its outcome and the violations recorded for it
+type PositionExplanation struct {
+	Position       VesselPositionRecord `json:"position"`
+	Park           Park                 `json:"park"`
+	EvaluatedAt    time.Time            `json:"evaluated_at"`
+	Zones          []ZoneMatch          `json:"zones"`
+	Whitelist      WhitelistDecision    `json:"whitelist"`
+	Rules          []RuleEvaluation     `json:"rules"`
+	Classification string               `json:"classification"`
+	Notes          []string             `json:"notes"`
+}
+
+// ZoneMatch is how a position relates to one boundary layer. Matched is what
+// the rules saw; it can differ from Inside, the strict polygon test, when the
+// park's boundary tolerance applies. Properties are those of the containing,
+// or nearest, zone.
+type ZoneMatch struct {
+	Layer                    string                 `json:"layer"`
+	Loaded                   bool                   `json:"loaded"`
+	Version                  string                 `json:"version,omitempty"`
+	Matched                  bool                   `json:"matched"`
+	Inside                   bool                   `json:"inside"`
+	DistanceToBoundaryMeters *float64               `json:"distance_to_boundary_meters,omitempty"`
+	Properties               map[string]interface{} `json:"properties,omitempty"`
+}
+
+// WhitelistDecision is the whitelist lookup for a position. MatchedBy names
+// the identifier (uuid, mmsi or imo) that matched the entry.
+type WhitelistDecision struct {
+	VesselUUID  string          `json:"vessel_uuid"`
+	MMSI        string          `json:"mmsi"`
+	IMO         string          `json:"imo"`
+	Whitelisted bool            `json:"whitelisted"`
+	MatchedBy   string          `json:"matched_by,omitempty"`
+	Entry       *WhitelistEntry `json:"entry,omitempty"`
+	CheckedAt   time.Time       `json:"checked_at"`
+}
+
+// RuleEvaluation is the outcome of one violation rule for a position.
+// ViolationID is the violation recorded for the position under this rule.
+type RuleEvaluation struct {
+	Rule        string `json:"rule"`
+	Severity    string `json:"severity"`
+	Evaluated   bool   `json:"evaluated"`
+	Matched     bool   `json:"matched"`
+	Reason      string `json:"reason"`
+	ZoneRule    string `json:"zone_rule,omitempty"`           // zone rule deciding the outcome, empty for the zone default
+	Exception   string `json:"whitelist_exception,omitempty"` // whitelist exception evaluating the rule for a whitelisted vessel
+	ViolationID *uint  `json:"violation_id,omitempty"`
+}
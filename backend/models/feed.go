@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// ParkDaySummary is the public summary of one finalized UTC day of a park,
+// an entry of the park statistics feed. It carries counts only, no vessel
+// identities.
+type ParkDaySummary struct {
+	Park             string           `json:"park"`
+	Day              string           `json:"day"` // YYYY-MM-DD, UTC
+	Vessels          int              `json:"vessels"`
+	VesselsInPark    int              `json:"vessels_in_park"`
+	ParkEntries      int64            `json:"park_entries"`
+	MaxSpeedKnots    float64          `json:"max_speed_knots"`
+	Violations       int64            `json:"violations"`
+	ViolationsByType map[string]int64 `json:"violations_by_type"`
+	NewZones         []FeedZone       `json:"new_zones"`
+	FinalizedAt      time.Time        `json:"finalized_at"`
+}
+
+// FeedZone is a temporary zone, a habitat layer with a validity window,
+// announced on the day it was added
+type FeedZone struct {
+	ID          uint       `json:"id"`
+	Name        string     `json:"name"`
+	Kind        string     `json:"kind"`
+	ActiveFrom  *time.Time `json:"active_from,omitempty"`
+	ActiveUntil *time.Time `json:"active_until,omitempty"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Habitat layer kinds
+const (
+	HabitatKindPosidonia = "posidonia"
+	HabitatKindClosure   = "closure"
+	HabitatKindOther     = "other"
+)
+
+// Formats habitat layers can be uploaded in
+const (
+	HabitatFormatGeoJSON    = "geojson"
+	HabitatFormatKMZ        = "kmz"
+	HabitatFormatShapefile  = "shapefile"
+	HabitatFormatGeoPackage = "gpkg"
+)
+
+// HabitatLayer is a habitat map uploaded for a park at runtime, such as an
+// updated posidonia survey or a seasonal closure area. The file is kept in
+// its own directory under HABITAT_LAYERS_DIR; Path is relative to it. A layer
+// with ActiveFrom or ActiveUntil only applies within that window.
+type HabitatLayer struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	ParkID      uint       `gorm:"index;not null" json:"park_id"`
+	Name        string     `gorm:"not null" json:"name"`
+	Kind        string     `gorm:"index;not null" json:"kind"`
+	Format      string     `gorm:"not null" json:"format"`
+	FileName    string     `json:"file_name"` // name of the uploaded file
+	Path        string     `gorm:"not null" json:"-"`
+	Features    int        `json:"features"`
+	SHA256      string     `json:"sha256"`
+	ActiveFrom  *time.Time `json:"active_from,omitempty"`
+	ActiveUntil *time.Time `json:"active_until,omitempty"`
+	UploadedBy  string     `json:"uploaded_by" role:"ranger"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
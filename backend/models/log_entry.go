@@ -0,0 +1,48 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// LogEntry is a log record of a scheduled job or ingestion, or a warning or
+// error of any component, stored so it can be read through the API
+type LogEntry struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	LoggedAt  time.Time `gorm:"index;not null" json:"time"`
+	Level     string    `gorm:"index;not null" json:"level"` // DEBUG, INFO, WARN or ERROR
+	Severity  int       `gorm:"index;not null" json:"-"`     // the slog level, for filtering by minimum level
+	Component string    `gorm:"index" json:"component"`
+	RunID     string    `gorm:"index" json:"run_id,omitempty"` // the fetch run the record belongs to
+	Message   string    `json:"message"`
+	Attrs     LogAttrs  `gorm:"type:jsonb" json:"attrs"`
+}
+
+// LogAttrs holds the attributes of a log record
+type LogAttrs map[string]interface{}
+
+// Value stores the attributes as JSON
+func (a LogAttrs) Value() (driver.Value, error) {
+	data, err := json.Marshal(a)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan reads attributes stored as JSON
+func (a *LogAttrs) Scan(value interface{}) error {
+	switch data := value.(type) {
+	case []byte:
+		return json.Unmarshal(data, a)
+	case string:
+		return json.Unmarshal([]byte(data), a)
+	case nil:
+		*a = nil
+		return nil
+	default:
+		return fmt.Errorf("unsupported log attributes value type %T", value)
+	}
+}
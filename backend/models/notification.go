@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// Notification delivery outcomes
+const (
+	NotificationStatusSent       = "sent"
+	NotificationStatusFailed     = "failed"
+	NotificationStatusSuppressed = "suppressed" // not sent, see SuppressionID
+)
+
+// NotificationDelivery records one attempt to send an alert on a channel.
+// ViolationID is nil for alerts not about a violation, such as test alerts.
+// An alert withheld by a suppression is recorded once, without a channel.
+type NotificationDelivery struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	ViolationID   *uint     `gorm:"index" json:"violation_id"`
+	Channel       string    `gorm:"not null" json:"channel"`
+	Severity      string    `json:"severity"`
+	Subject       string    `json:"subject"`
+	Status        string    `gorm:"index;not null" json:"status"`
+	Error         string    `json:"error,omitempty"`
+	SuppressionID *uint     `json:"suppression_id,omitempty"`
+	AttemptedAt   time.Time `gorm:"index;not null" json:"attempted_at"`
+}
+
+// NotificationSuppression silences the alerts about a vessel from StartsAt
+// until EndsAt, while a known situation such as a salvage operation is being
+// handled. It expires by itself at EndsAt and can be cancelled earlier.
+type NotificationSuppression struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	VesselUUID  string     `gorm:"index;not null" json:"vessel_uuid"`
+	Reason      string     `gorm:"not null" json:"reason"`
+	StartsAt    time.Time  `gorm:"not null" json:"starts_at"`
+	EndsAt      time.Time  `gorm:"index;not null" json:"ends_at"`
+	CreatedBy   string     `json:"created_by"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CancelledAt *time.Time `json:"cancelled_at,omitempty"`
+	CancelledBy string     `json:"cancelled_by,omitempty"`
+}
+
+// Active reports whether the suppression silences alerts at t
+func (s *NotificationSuppression) Active(t time.Time) bool {
+	return s.CancelledAt == nil && !t.Before(s.StartsAt) && t.Before(s.EndsAt)
+}
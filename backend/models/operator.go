@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// Operator represents the owner or operator of one or more vessels. Linking
+// hulls to an operator lets permits and statistics follow the operator when
+// they replace or add a boat.
+type Operator struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	Name       string    `gorm:"not null" json:"name" role:"ranger"`
+	RegistryID string    `gorm:"index" json:"registry_id" role:"ranger"`
+	Notes      string    `json:"notes" role:"ranger"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+
+	Vessels  []VesselRecord    `gorm:"foreignKey:OperatorID" json:"vessels,omitempty"`
+	Contacts []OperatorContact `gorm:"foreignKey:OperatorID" json:"contacts,omitempty" role:"admin"`
+}
+
+// OperatorContact holds personal contact details for an operator. Contacts are
+// only loaded by admin endpoints and never embedded in public responses.
+type OperatorContact struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	OperatorID uint      `gorm:"index;not null" json:"operator_id"`
+	Name       string    `json:"name" role:"admin"`
+	Role       string    `json:"role" role:"admin"`
+	Email      string    `json:"email" role:"admin"`
+	Phone      string    `json:"phone" role:"admin"`
+	Address    string    `json:"address" role:"admin"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// OperatorStats summarizes park activity for all vessels linked to an operator
+type OperatorStats struct {
+	OperatorID      uint   `json:"operator_id"`
+	OperatorName    string `json:"operator_name" role:"ranger"`
+	VesselCount     int64  `json:"vessel_count"`
+	PositionsTotal  int64  `json:"positions_total"`
+	PositionsInPark int64  `json:"positions_in_park"`
+	DaysSeen        int64  `json:"days_seen"`
+	Violations      int64  `json:"violations"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Park is a protected area monitored by the deployment. Its boundaries are
+// loaded from the park configuration at startup; the row gives the park a
+// stable ID that positions and violations are tagged with.
+type Park struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	Slug            string    `gorm:"uniqueIndex;not null" json:"slug"`
+	Name            string    `gorm:"not null" json:"name"`
+	BoundariesPath  string    `json:"boundaries_path,omitempty"`
+	BufferedPath    string    `json:"buffered_path,omitempty"`
+	BufferMeters    *float64  `json:"buffer_meters,omitempty"`
+	CenterLat       *float64  `json:"center_lat,omitempty"`
+	CenterLon       *float64  `json:"center_lon,omitempty"`
+	RadiusNM        int       `json:"radius_nm,omitempty"`
+	DataRegion      string    `json:"data_region,omitempty"`
+	RestrictExports bool      `gorm:"not null;default:false" json:"restrict_exports"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// PlaybackVessel describes a vessel appearing in a playback once, so frames
+// only carry positions
+type PlaybackVessel struct {
+	UUID         string `json:"uuid"`
+	Name         string `json:"name"`
+	MMSI         string `json:"mmsi"`
+	IMO          string `json:"imo"`
+	Type         string `json:"type"`
+	TypeSpecific string `json:"type_specific"`
+	CountryISO   string `json:"country_iso"`
+}
+
+// PlaybackPosition is where a vessel was at the time of a frame, as
+// /vessels/at-time places it
+type PlaybackPosition struct {
+	VesselUUID   string    `json:"vessel_uuid"`
+	Latitude     float64   `json:"latitude"`
+	Longitude    float64   `json:"longitude"`
+	Speed        float64   `json:"speed"`
+	Course       float64   `json:"course"`
+	Heading      *int      `json:"heading"`
+	IsInPark     bool      `json:"is_in_park"`
+	FixTime      time.Time `json:"fix_time"`             // the frame time for interpolated positions
+	AgeSeconds   int64     `json:"position_age_seconds"` // from the frame time to the nearest fix the position is based on
+	Interpolated bool      `json:"interpolated"`
+}
+
+// PlaybackFrame is the vessel positions at one time of a playback
+type PlaybackFrame struct {
+	Timestamp time.Time          `json:"timestamp"`
+	Positions []PlaybackPosition `json:"positions"`
+	Count     int                `json:"count"`
+}
+
+// Playback is a sequence of frames evenly spaced over a time range, for
+// replaying vessel movements without a request per frame
+type Playback struct {
+	Park        string                    `json:"park"`
+	Start       time.Time                 `json:"start"`
+	End         time.Time                 `json:"end"`
+	Step        string                    `json:"step"`
+	Interpolate bool                      `json:"interpolate"`
+	MaxGap      string                    `json:"max_gap"`
+	MaxAge      string                    `json:"max_age,omitempty"` // positions older than it are left out of frames
+	Vessels     map[string]PlaybackVessel `json:"vessels"`
+	Frames      []PlaybackFrame           `json:"frames"`
+}
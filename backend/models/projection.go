@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// ProjectedPoint is where a vessel is expected to be some minutes after its
+// fix if it holds its speed and course
+type ProjectedPoint struct {
+	Minutes      int       `json:"minutes"` // ahead of the fix
+	Time         time.Time `json:"time"`
+	Latitude     float64   `json:"latitude"`
+	Longitude    float64   `json:"longitude"`
+	InPark       bool      `json:"in_park"`
+	InBufferZone bool      `json:"in_buffer_zone"`
+}
+
+// ProjectedIntrusion is where and when a projected track first enters a park
+type ProjectedIntrusion struct {
+	Minutes   float64   `json:"minutes"` // ahead of the fix
+	Time      time.Time `json:"time"`
+	Latitude  float64   `json:"latitude"`
+	Longitude float64   `json:"longitude"`
+}
+
+// VesselProjection is a vessel's track dead-reckoned forward from its latest
+// fix. Only the points up to where the track would run aground are given;
+// Notes say why a track was cut short or not projected at all.
+type VesselProjection struct {
+	VesselUUID string              `json:"vessel_uuid"`
+	ParkID     uint                `json:"park_id"`
+	FixTime    time.Time           `json:"fix_time"`
+	Latitude   float64             `json:"latitude"`
+	Longitude  float64             `json:"longitude"`
+	Speed      float64             `json:"speed"`
+	Course     float64             `json:"course"`
+	InPark     bool                `json:"in_park"` // the fix itself is in the park
+	Points     []ProjectedPoint    `json:"points"`
+	Intrusion  *ProjectedIntrusion `json:"intrusion"` // nil when the track stays outside the park
+	Notes      []string            `json:"notes"`
+}
@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// Report periods
+const (
+	ReportPeriodDaily  = "daily"
+	ReportPeriodWeekly = "weekly"
+)
+
+// ViolationReport summarizes the violations and vessel traffic of a park over
+// a period
+type ViolationReport struct {
+	Park         Park             `json:"park"`
+	Period       string           `json:"period"`
+	Start        time.Time        `json:"start"`
+	End          time.Time        `json:"end"`
+	GeneratedAt  time.Time        `json:"generated_at"`
+	Summary      ViolationTotals  `json:"summary"`
+	Traffic      TrafficCounts    `json:"traffic"`
+	TopOffenders []ReportOffender `json:"top_offenders"`
+	Violations   []Violation      `json:"violations"`
+}
+
+// ViolationTotals counts the violations of a report
+type ViolationTotals struct {
+	Total      int64            `json:"total"`
+	ByType     map[string]int64 `json:"by_type"`
+	BySeverity map[string]int64 `json:"by_severity"`
+	ByStatus   map[string]int64 `json:"by_status"`
+}
+
+// TrafficCounts counts the vessels seen during a report period
+type TrafficCounts struct {
+	Positions     int64          `json:"positions"`
+	Vessels       int            `json:"vessels"`
+	VesselsInPark int            `json:"vessels_in_park"`
+	Daily         []DailyTraffic `json:"daily"`
+}
+
+// DailyTraffic counts the vessels seen on one UTC day
+type DailyTraffic struct {
+	Date          string `json:"date"`
+	Vessels       int    `json:"vessels"`
+	VesselsInPark int    `json:"vessels_in_park"`
+}
+
+// ReportOffender is a vessel ranked by its violations in a report period
+type ReportOffender struct {
+	VesselUUID string `json:"vessel_uuid"`
+	VesselName string `json:"vessel_name"`
+	MMSI       string `json:"mmsi"`
+	Violations int64  `json:"violations"`
+}
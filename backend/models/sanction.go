@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// Sanction payment states
+const (
+	SanctionStatusIssued    = "issued"
+	SanctionStatusPaid      = "paid"
+	SanctionStatusOverdue   = "overdue"
+	SanctionStatusCancelled = "cancelled"
+)
+
+// Sanction is a fine issued for a violation and its payment status
+type Sanction struct {
+	ID               uint       `gorm:"primaryKey" json:"id"`
+	ViolationID      uint       `gorm:"index;not null" json:"violation_id"`
+	Amount           float64    `gorm:"type:decimal(10,2);not null" json:"amount"`
+	Currency         string     `gorm:"default:EUR" json:"currency"`
+	Status           string     `gorm:"index;not null" json:"status"`
+	IssuedAt         time.Time  `gorm:"index;not null" json:"issued_at"`
+	DueDate          time.Time  `gorm:"index;not null" json:"due_date"`
+	PaidAt           *time.Time `json:"paid_at"`
+	AmountPaid       float64    `gorm:"type:decimal(10,2)" json:"amount_paid"`
+	PaymentReference string     `gorm:"index" json:"payment_reference" role:"ranger"`
+	Notes            string     `json:"notes" role:"ranger"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+
+	Violation *Violation `gorm:"foreignKey:ViolationID" json:"violation,omitempty"`
+}
+
+// SanctionCollectionStats summarizes fine collection over a period
+type SanctionCollectionStats struct {
+	Issued          int64   `json:"issued"`
+	Paid            int64   `json:"paid"`
+	Overdue         int64   `json:"overdue"`
+	Cancelled       int64   `json:"cancelled"`
+	AmountIssued    float64 `json:"amount_issued"`
+	AmountCollected float64 `json:"amount_collected"`
+	CollectionRate  float64 `json:"collection_rate"`
+}
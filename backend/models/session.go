@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// Session is a device login. The device holds a short-lived access token and
+// a refresh token that is rotated on every use; only token hashes are stored.
+type Session struct {
+	ID                uint       `gorm:"primaryKey" json:"id"`
+	Actor             string     `gorm:"index;not null" json:"actor"`
+	Role              string     `gorm:"not null" json:"role"`
+	DeviceName        string     `json:"device_name"`
+	RefreshTokenHash  string     `gorm:"uniqueIndex;not null" json:"-"`
+	PreviousTokenHash string     `gorm:"index" json:"-"`
+	ExpiresAt         time.Time  `gorm:"not null" json:"expires_at"`
+	LastUsedAt        time.Time  `json:"last_used_at"`
+	RevokedAt         *time.Time `gorm:"index" json:"revoked_at,omitempty"`
+	RevokedBy         string     `json:"revoked_by,omitempty"`
+	RevokeReason      string     `json:"revoke_reason,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+// RevokedToken blacklists a single access token until it would have expired
+type RevokedToken struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	JTI       string    `gorm:"uniqueIndex;not null" json:"jti"`
+	SessionID uint      `gorm:"index" json:"session_id"`
+	Reason    string    `json:"reason"`
+	RevokedBy string    `json:"revoked_by"`
+	ExpiresAt time.Time `gorm:"index;not null" json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,77 @@
+package models
+
+import "time"
+
+// Checks compared in shadow mode: each violation rule by its violation type,
+// and anchoring
+const (
+	ShadowCheckAnchoring = "anchoring"
+)
+
+// ShadowDivergence is a decision on which the shadow detection logic disagreed
+// with the live logic. It is only logged and stored; no violation or
+// anchoring event is created from it.
+type ShadowDivergence struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	ParkID        uint      `gorm:"index;not null" json:"park_id"`
+	VesselUUID    string    `gorm:"index;not null" json:"vessel_uuid"`
+	Check         string    `gorm:"column:check_name;index;not null" json:"check"`
+	LiveMatched   bool      `json:"live_matched"`
+	ShadowMatched bool      `json:"shadow_matched"`
+	Latitude      float64   `gorm:"type:decimal(10,6)" json:"latitude"`
+	Longitude     float64   `gorm:"type:decimal(10,6)" json:"longitude"`
+	Speed         float64   `gorm:"type:decimal(8,2)" json:"speed"`
+	LiveReason    string    `json:"live_reason"`
+	ShadowReason  string    `json:"shadow_reason"`
+	DetectedAt    time.Time `gorm:"index;not null" json:"detected_at"`
+}
+
+// ShadowTally counts the decisions of one check in one detection cycle of a
+// park, so agreement can be reported without storing every decision
+type ShadowTally struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	ParkID        uint      `gorm:"index;not null" json:"park_id"`
+	Check         string    `gorm:"column:check_name;not null" json:"check"`
+	Evaluated     int       `json:"evaluated"`
+	LiveMatched   int       `json:"live_matched"`
+	ShadowMatched int       `json:"shadow_matched"`
+	LiveOnly      int       `json:"live_only"`
+	ShadowOnly    int       `json:"shadow_only"`
+	RecordedAt    time.Time `gorm:"index;not null" json:"recorded_at"`
+}
+
+// DetectionSettings are the parameters of one version of the detection logic
+type DetectionSettings struct {
+	SpeedLimitKnots         float64 `json:"speed_limit_knots"`
+	AnchoringMaxSpeedKnots  float64 `json:"anchoring_max_speed_knots"`
+	AnchoringMaxDriftMeters float64 `json:"anchoring_max_drift_meters"`
+	AnchoringMinPositions   int     `json:"anchoring_min_positions"`
+	AnchoringLookback       string  `json:"anchoring_lookback"`
+}
+
+// ShadowCheckSummary compares the live and shadow decisions of one check
+// over a period. AgreementRate is the share of evaluations both agreed on,
+// nil when there were none.
+type ShadowCheckSummary struct {
+	Check         string   `json:"check"`
+	Evaluated     int64    `json:"evaluated"`
+	LiveMatched   int64    `json:"live_matched"`
+	ShadowMatched int64    `json:"shadow_matched"`
+	LiveOnly      int64    `json:"live_only"`
+	ShadowOnly    int64    `json:"shadow_only"`
+	AgreementRate *float64 `json:"agreement_rate"`
+}
+
+// ShadowReport compares the shadow detection logic with the live logic in a
+// park over a period, with the most recent divergences
+type ShadowReport struct {
+	Park        Park                 `json:"park"`
+	Start       time.Time            `json:"start"`
+	End         time.Time            `json:"end"`
+	Active      bool                 `json:"active"`
+	Until       *time.Time           `json:"until"`
+	Live        DetectionSettings    `json:"live"`
+	Shadow      DetectionSettings    `json:"shadow"`
+	Checks      []ShadowCheckSummary `json:"checks"`
+	Divergences []ShadowDivergence   `json:"divergences"`
+}
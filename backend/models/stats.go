@@ -0,0 +1,102 @@
+package models
+
+import "time"
+
+// VesselDwellTime is the time a vessel spent inside the park and buffer zone over a period
+type VesselDwellTime struct {
+	VesselUUID    string  `json:"vessel_uuid"`
+	Name          string  `json:"name"`
+	MMSI          string  `json:"mmsi"`
+	Type          string  `json:"type"`
+	ParkMinutes   float64 `json:"park_minutes"`
+	BufferMinutes float64 `json:"buffer_minutes"`
+	PositionCount int     `json:"position_count"`
+}
+
+// HeatmapCell is the number of recorded positions within one grid cell
+type HeatmapCell struct {
+	Row       int64   `json:"row"`
+	Col       int64   `json:"col"`
+	MinLat    float64 `json:"min_lat"`
+	MinLon    float64 `json:"min_lon"`
+	MaxLat    float64 `json:"max_lat"`
+	MaxLon    float64 `json:"max_lon"`
+	Count     int64   `json:"count"`
+	Vessels   int64   `json:"vessels"`
+	InParkPct float64 `json:"in_park_pct"`
+}
+
+// OccupancyCount is the number of distinct vessels with at least one fix in
+// the park and in its buffer zone during an interval
+type OccupancyCount struct {
+	InPark   int `json:"in_park"`
+	InBuffer int `json:"in_buffer"`
+}
+
+// OccupancyInterval is the zone occupancy of a park during one interval of a
+// time series, in total and per vessel type
+type OccupancyInterval struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	OccupancyCount
+	ByType map[string]OccupancyCount `json:"by_type"`
+}
+
+// Kinds of park movement
+const (
+	MovementEntry = "entry"
+	MovementExit  = "exit"
+)
+
+// ParkMovement is a vessel entering or leaving a park, one line of the daily
+// movement log. Exits carry the time the visit began and its dwell time,
+// unless the vessel was already inside when the log starts looking back.
+type ParkMovement struct {
+	Time         time.Time  `json:"time"`
+	Kind         string     `json:"kind"`
+	VesselUUID   string     `json:"vessel_uuid"`
+	Name         string     `json:"name"`
+	MMSI         string     `json:"mmsi"`
+	IMO          string     `json:"imo"`
+	Callsign     string     `json:"callsign"`
+	Type         string     `json:"type"`
+	CountryISO   string     `json:"country_iso"`
+	Latitude     float64    `json:"latitude"`
+	Longitude    float64    `json:"longitude"`
+	EnteredAt    *time.Time `json:"entered_at,omitempty"`
+	DwellMinutes *float64   `json:"dwell_minutes,omitempty"`
+	SignalLost   bool       `json:"signal_lost"` // the exit is inferred from the vessel no longer being heard in the park
+}
+
+// PeakDay is the traffic and violations of one UTC day of a peak comparison
+type PeakDay struct {
+	Date          string `json:"date"`
+	Peak          bool   `json:"peak"`
+	Reason        string `json:"reason,omitempty"` // the holiday or period that makes it a peak day
+	Positions     int64  `json:"positions"`
+	Vessels       int    `json:"vessels"`
+	VesselsInPark int    `json:"vessels_in_park"`
+	Violations    int64  `json:"violations"`
+}
+
+// PeakSegment totals the peak or the off-peak days of a comparison. Per day
+// averages make segments of different lengths comparable.
+type PeakSegment struct {
+	Days                int              `json:"days"`
+	Positions           int64            `json:"positions"`
+	Violations          int64            `json:"violations"`
+	ViolationsByType    map[string]int64 `json:"violations_by_type"`
+	VesselsPerDay       float64          `json:"vessels_per_day"`
+	VesselsInParkPerDay float64          `json:"vessels_in_park_per_day"`
+	ViolationsPerDay    float64          `json:"violations_per_day"`
+}
+
+// PeakComparison splits the traffic and violations of a park over a period
+// into peak and off-peak days
+type PeakComparison struct {
+	Start   time.Time   `json:"start"`
+	End     time.Time   `json:"end"`
+	Peak    PeakSegment `json:"peak"`
+	OffPeak PeakSegment `json:"off_peak"`
+	Days    []PeakDay   `json:"days"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// Incident timeline entry kinds
+const (
+	TimelineKindPosition       = "position"
+	TimelineKindZoneTransition = "zone_transition"
+	TimelineKindViolation      = "violation"
+	TimelineKindAlert          = "alert"
+	TimelineKindRecordAccess   = "record_access"
+	TimelineKindAppeal         = "appeal"
+	TimelineKindSanction       = "sanction"
+)
+
+// TimelineEntry is one thing that happened around an incident, told in a
+// sentence. Data holds the figures behind it.
+type TimelineEntry struct {
+	Time      time.Time   `json:"time"`
+	Kind      string      `json:"kind"`
+	Summary   string      `json:"summary"`
+	Actor     string      `json:"actor,omitempty"`
+	Latitude  *float64    `json:"latitude,omitempty"`
+	Longitude *float64    `json:"longitude,omitempty"`
+	Data      interface{} `json:"data,omitempty"`
+}
+
+// IncidentTimeline is everything recorded around a violation, oldest first:
+// the vessel's track and zone transitions within the window around the
+// incident, and the alerts, record accesses, appeals and sanctions of the
+// case whenever they happened. Notes explain what is missing.
+type IncidentTimeline struct {
+	Violation    Violation       `json:"violation"`
+	IncidentTime time.Time       `json:"incident_time"` // time of the triggering position
+	WindowStart  time.Time       `json:"window_start"`
+	WindowEnd    time.Time       `json:"window_end"`
+	Entries      []TimelineEntry `json:"entries"`
+	Counts       map[string]int  `json:"counts"`
+	Notes        []string        `json:"notes"`
+}
@@ -104,6 +104,9 @@ type VesselRecord struct {
 	YearBuilt    string  `json:"year_built"`
 	IsNavaid     bool    `json:"is_navaid"`
 	HomePort     *string `json:"home_port"`
+	Destination  string  `json:"destination"`
+	OperatorID   *uint   `gorm:"index" json:"operator_id" role:"ranger"`
+	LastSeenAt   *time.Time `gorm:"index" json:"last_seen_at"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 }
@@ -111,6 +114,7 @@ type VesselRecord struct {
 type VesselPositionRecord struct {
 	ID           uint    `gorm:"primaryKey" json:"id"`
 	VesselUUID   string  `gorm:"index;not null" json:"vessel_uuid"`
+	ParkID       uint    `gorm:"index;not null;default:0" json:"park_id"`
 	Latitude     float64 `gorm:"type:decimal(10,6);not null" json:"latitude"`
 	Longitude    float64 `gorm:"type:decimal(10,6);not null" json:"longitude"`
 	Speed        float64 `gorm:"type:decimal(8,2)" json:"speed"`
@@ -119,6 +123,10 @@ type VesselPositionRecord struct {
 	Destination  string  `json:"destination"`
 	Distance     float64 `gorm:"type:decimal(10,2)" json:"distance"`
 	IsInPark     bool    `gorm:"index" json:"is_in_park"`
+	OnLand       bool    `gorm:"index;not null;default:false" json:"on_land"` // plotted on land, a GPS error
+	CurrentSpeed     *float64 `gorm:"type:decimal(6,2)" json:"current_speed_knots"`   // predicted tidal current, nil where no predictions cover the position
+	CurrentDirection *float64 `gorm:"type:decimal(6,1)" json:"current_direction_deg"` // toward which the current flows, degrees true
+	SpeedThroughWater *float64 `gorm:"type:decimal(8,2)" json:"speed_through_water"` // speed over ground less the current, nil without one
 	LastPosEpoch int64   `gorm:"index" json:"last_position_epoch"`
 	LastPosUTC   string  `json:"last_position_utc"`
 	ETAEpoch     *int64  `json:"eta_epoch"`
@@ -128,17 +136,24 @@ type VesselPositionRecord struct {
 	Vessel VesselRecord `gorm:"foreignKey:VesselUUID;references:UUID" json:"vessel,omitempty"`
 }
 
+// Current returns the tidal current predicted at the position, or nil
+func (r VesselPositionRecord) Current() *TidalCurrent {
+	return newTidalCurrent(r.CurrentSpeed, r.CurrentDirection)
+}
+
 type WhitelistEntry struct {
-	ID          uint      `gorm:"primaryKey" json:"id"`
-	VesselUUID  string    `gorm:"uniqueIndex;not null" json:"vessel_uuid"`
-	MMSI        string    `gorm:"index" json:"mmsi"`
-	IMO         string    `gorm:"index" json:"imo"`
-	Name        string    `json:"name"`
-	Reason      string    `json:"reason"`
-	AddedBy     string    `json:"added_by"`
-	IsActive    bool      `gorm:"default:true" json:"is_active"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	VesselUUID string     `gorm:"uniqueIndex;not null" json:"vessel_uuid"`
+	MMSI       string     `gorm:"index" json:"mmsi"`
+	IMO        string     `gorm:"index" json:"imo"`
+	Name       string     `json:"name"`
+	Reason     string     `json:"reason"`
+	AddedBy    string     `json:"added_by" role:"ranger"`
+	OperatorID *uint      `gorm:"index" json:"operator_id" role:"ranger"`
+	ExpiresAt  *time.Time `gorm:"index" json:"expires_at"` // nil for a permit without an end date
+	IsActive   bool       `gorm:"default:true" json:"is_active"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
 
 	Vessel VesselRecord `gorm:"foreignKey:VesselUUID;references:UUID" json:"vessel,omitempty"`
 }
@@ -172,4 +187,20 @@ type VesselHistoryData struct {
 type VesselHistoryResponse struct {
 	Data VesselHistoryData `json:"data"`
 	Meta Meta              `json:"meta"`
-}
\ No newline at end of file
+}
+// Vessel data erasure modes
+const (
+	ErasurePurge     = "purge"
+	ErasureAnonymize = "anonymize"
+)
+
+// VesselErasure reports the outcome of a data removal request for a vessel.
+// Rows counts the rows deleted, or rewritten to the pseudonym, per table.
+type VesselErasure struct {
+	VesselUUID string           `json:"vessel_uuid"`
+	Mode       string           `json:"mode"`
+	Pseudonym  string           `json:"pseudonym,omitempty"`
+	Rows       map[string]int64 `json:"rows"`
+	ErasedBy   string           `json:"erased_by"`
+	ErasedAt   time.Time        `json:"erased_at"`
+}
@@ -0,0 +1,209 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Violation severity levels, matching the frontend violations worker
+const (
+	SeverityLow      = "low"
+	SeverityMedium   = "medium"
+	SeverityHigh     = "high"
+	SeverityCritical = "critical"
+
+	// SeverityAuthorizedInfraction marks a violation by a whitelisted vessel
+	// that a whitelist exception reports anyway, so it is routed and counted
+	// apart from the violations of vessels without authorization
+	SeverityAuthorizedInfraction = "authorized_infraction"
+)
+
+// Violation types, matching the frontend violations worker
+const (
+	ViolationAnchoredOnPosidonia = "anchored_on_posidonia"
+	ViolationInBufferZone        = "in_buffer_zone"
+	ViolationInRestrictedArea    = "in_restricted_area"
+	ViolationExcessiveSpeed      = "excessive_speed"
+
+	// ViolationWatchlistedVessel alerts that a watchlisted vessel was
+	// sighted within the search radius of a park, inside the park or not
+	ViolationWatchlistedVessel = "watchlisted_vessel"
+
+	// ViolationProjectedIntrusion is a pre-alert that a vessel outside a
+	// park is on course to enter it soon, so rangers can intercept it
+	ViolationProjectedIntrusion = "projected_intrusion"
+
+	// ViolationAnchorDragging is a safety alert that an anchored vessel is
+	// slowly migrating beyond the swing radius of its anchor, dragging it
+	// across the seabed
+	ViolationAnchorDragging = "anchor_dragging"
+)
+
+// Violation statuses. A violation is open until rangers triage it, or until
+// the first later fix showing the vessel no longer breaks the rule resolves
+// it. Triage and resolution are independent: a triaged violation keeps its
+// status when the vessel leaves, only ResolvedAt is set.
+const (
+	ViolationStatusOpen         = "open"
+	ViolationStatusResolved     = "resolved"
+	ViolationStatusAcknowledged = "acknowledged"
+	ViolationStatusDismissed    = "dismissed" // a false positive
+	ViolationStatusEscalated    = "escalated"
+	ViolationStatusFined        = "fined"
+)
+
+// Violation is a persisted rule infraction by a vessel
+type Violation struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	VesselUUID string    `gorm:"index;not null" json:"vessel_uuid"`
+	ParkID     uint      `gorm:"index;not null;default:0" json:"park_id"`
+	MMSI       string    `gorm:"index" json:"mmsi"`
+	IMO        string    `json:"imo"`
+	VesselName string    `json:"vessel_name"`
+	OperatorID *uint     `gorm:"index" json:"operator_id" role:"ranger"`
+	Type       string    `gorm:"index;not null" json:"type"`
+	Severity   string    `json:"severity"`
+	Status     string    `gorm:"index;default:open" json:"status"`
+	Latitude   float64   `gorm:"type:decimal(10,6)" json:"latitude"`
+	Longitude  float64   `gorm:"type:decimal(10,6)" json:"longitude"`
+	Speed      float64   `gorm:"type:decimal(8,2)" json:"speed"`
+	Details    string    `json:"details" role:"ranger"`
+	Rule       string    `gorm:"index" json:"rule,omitempty"` // zone rule that produced the violation, empty for the zone defaults
+	DetectedAt time.Time `gorm:"index;not null" json:"detected_at"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+
+	// Set when a fix shows the vessel no longer breaks the rule; the
+	// duration runs from detection to that fix
+	ResolvedAt      *time.Time `json:"resolved_at"`
+	DurationMinutes *float64   `gorm:"type:decimal(10,2)" json:"duration_minutes"`
+
+	// Tidal current predicted at the position and the vessel's speed through
+	// the water, nil where no predictions cover it
+	CurrentSpeed      *float64 `gorm:"type:decimal(6,2)" json:"current_speed_knots"`
+	CurrentDirection  *float64 `gorm:"type:decimal(6,1)" json:"current_direction_deg"`
+	SpeedThroughWater *float64 `gorm:"type:decimal(8,2)" json:"speed_through_water"` // the speed speed limits are judged on
+
+	// Evidence is captured when the violation is detected and only served by
+	// the evidence endpoint
+	Evidence *ViolationEvidence `gorm:"type:jsonb" json:"-"`
+
+	Operator *Operator `gorm:"foreignKey:OperatorID" json:"operator,omitempty" role:"ranger"`
+}
+
+// ViolationStatusChange is an entry of a violation's status history: a
+// transition or a note made by a ranger, or the automatic resolution
+type ViolationStatusChange struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	ViolationID uint      `gorm:"index;not null" json:"violation_id"`
+	FromStatus  string    `json:"from_status"`
+	ToStatus    string    `gorm:"index" json:"to_status"` // FromStatus for a note or the resolution of a triaged violation
+	Note        string    `gorm:"type:text" json:"note,omitempty"`
+	Actor       string    `gorm:"index" json:"actor"` // "system" for the automatic resolution
+	ChangedAt   time.Time `gorm:"index;not null" json:"changed_at"`
+}
+
+// Current returns the tidal current predicted at the violation, or nil
+func (v Violation) Current() *TidalCurrent {
+	return newTidalCurrent(v.CurrentSpeed, v.CurrentDirection)
+}
+
+// ViolationEvidence is the state the detection was based on, kept with the
+// violation for legal follow-up
+type ViolationEvidence struct {
+	CapturedAt         time.Time          `json:"captured_at"`
+	TriggeringPosition EvidencePosition   `json:"triggering_position"`
+	PreviousPositions  []EvidencePosition `json:"previous_positions"`
+	Zones              []EvidenceZone     `json:"zones"`
+	SpeedProfile       SpeedProfile       `json:"speed_profile"`
+	Whitelist          WhitelistCheck     `json:"whitelist"`
+}
+
+// EvidencePosition is a reported vessel position
+type EvidencePosition struct {
+	Latitude     float64    `json:"latitude"`
+	Longitude    float64    `json:"longitude"`
+	Speed        float64    `json:"speed"`
+	Course       float64    `json:"course"`
+	Heading      *int       `json:"heading"`
+	LastPosEpoch int64      `json:"last_position_epoch"`
+	LastPosUTC   string     `json:"last_position_utc"`
+	RecordedAt   *time.Time `json:"recorded_at,omitempty"`
+
+	Current           *TidalCurrent `json:"current,omitempty"`             // predicted tidal current, when known
+	SpeedThroughWater *float64      `json:"speed_through_water,omitempty"` // speed less the current, when known
+}
+
+// LimitSpeed returns the speed judged against speed limits: through the
+// water where the current is known, else over ground
+func (p EvidencePosition) LimitSpeed() float64 {
+	if p.SpeedThroughWater != nil {
+		return *p.SpeedThroughWater
+	}
+	return p.Speed
+}
+
+// EvidenceZone is the polygon of a boundary layer containing, or nearest to,
+// the triggering position, clipped to Window ([min lon, min lat, max lon,
+// max lat]). Version identifies the loaded layer (the ETag its boundaries
+// endpoint served at the time).
+type EvidenceZone struct {
+	Layer                    string                 `json:"layer"`
+	Version                  string                 `json:"version"`
+	Inside                   bool                   `json:"inside"`
+	DistanceToBoundaryMeters float64                `json:"distance_to_boundary_meters"`
+	Properties               map[string]interface{} `json:"properties,omitempty"`
+	Window                   []float64              `json:"window"`
+	Geometry                 json.RawMessage        `json:"geometry"`
+}
+
+// SpeedProfile summarizes the speeds of the triggering and previous positions
+type SpeedProfile struct {
+	Samples          int     `json:"samples"`
+	MinKnots         float64 `json:"min_knots"`
+	MaxKnots         float64 `json:"max_knots"`
+	MeanKnots        float64 `json:"mean_knots"`
+	LimitKnots       float64 `json:"limit_knots"` // applying to the vessel at the triggering position, 0 for none
+	SamplesOverLimit int     `json:"samples_over_limit"`
+}
+
+// WhitelistCheck records the whitelist lookup made before the violation was
+// recorded
+type WhitelistCheck struct {
+	VesselUUID  string    `json:"vessel_uuid"`
+	MMSI        string    `json:"mmsi"`
+	IMO         string    `json:"imo"`
+	Whitelisted bool      `json:"whitelisted"`
+	CheckedAt   time.Time `json:"checked_at"`
+}
+
+// Value stores the evidence as JSON
+func (e ViolationEvidence) Value() (driver.Value, error) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan reads evidence stored as JSON
+func (e *ViolationEvidence) Scan(value interface{}) error {
+	switch data := value.(type) {
+	case []byte:
+		return json.Unmarshal(data, e)
+	case string:
+		return json.Unmarshal([]byte(data), e)
+	default:
+		return fmt.Errorf("unsupported evidence value type %T", value)
+	}
+}
+
+// VesselViolationSummary counts a vessel's violations over a period
+type VesselViolationSummary struct {
+	Since  time.Time        `json:"since"`
+	Total  int64            `json:"total"`
+	Open   int64            `json:"open"`
+	ByType map[string]int64 `json:"by_type"`
+}
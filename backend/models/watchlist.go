@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// Watchlist entry sources
+const (
+	WatchlistSourceManual    = "manual"
+	WatchlistSourceAutomatic = "automatic" // added after repeated violations
+)
+
+// WatchlistEntry flags a vessel whose appearance anywhere within the search
+// radius of a park raises an alert, whether or not it enters the park.
+// Removed entries are kept inactive so automatic flagging does not undo the
+// removal.
+type WatchlistEntry struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	VesselUUID string    `gorm:"index" json:"vessel_uuid"`
+	MMSI       string    `gorm:"index" json:"mmsi"`
+	IMO        string    `gorm:"index" json:"imo"`
+	Name       string    `json:"name"`
+	Reason     string    `json:"reason"`
+	Source     string    `gorm:"not null;default:manual" json:"source"`
+	Violations int64     `json:"violations,omitempty"` // counted when flagged automatically
+	AddedBy    string    `json:"added_by" role:"ranger"`
+	IsActive   bool      `gorm:"index;default:true" json:"is_active"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// TableName names the table watchlist rather than watchlist_entries
+func (WatchlistEntry) TableName() string {
+	return "watchlist"
+}
@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// WeatherObservation is the weather at the center of a park as reported by
+// the weather source during one fetch cycle. ObservedAt is the time the
+// source gives for the conditions, FetchedAt when they were requested.
+type WeatherObservation struct {
+	ID               uint      `gorm:"primaryKey" json:"id"`
+	ParkID           uint      `gorm:"uniqueIndex:idx_weather_park_observed;not null" json:"park_id"`
+	ObservedAt       time.Time `gorm:"uniqueIndex:idx_weather_park_observed;not null" json:"observed_at"`
+	FetchedAt        time.Time `json:"fetched_at"`
+	Source           string    `json:"source"`
+	Latitude         float64   `json:"latitude"`
+	Longitude        float64   `json:"longitude"`
+	TemperatureC     float64   `json:"temperature_c"`
+	WindSpeedKnots   float64   `json:"wind_speed_knots"`
+	WindGustKnots    float64   `json:"wind_gust_knots"`
+	WindDirectionDeg float64   `json:"wind_direction_deg"` // from which the wind blows, degrees true
+	PressureHPa      float64   `json:"pressure_hpa"`
+	PrecipitationMm  float64   `json:"precipitation_mm"`
+	CloudCoverPct    float64   `json:"cloud_cover_pct"`
+	WeatherCode      int       `json:"weather_code"` // WMO weather interpretation code
+}
+
+// WeatherFrame is the latest observation at the time of a playback frame;
+// Observation is nil when none is recent enough
+type WeatherFrame struct {
+	Timestamp   time.Time           `json:"timestamp"`
+	Observation *WeatherObservation `json:"observation"`
+	AgeSeconds  int64               `json:"observation_age_seconds,omitempty"`
+}
+
+// WeatherHistory is the weather of a park over a time range, at the same
+// frame times as a playback of that range
+type WeatherHistory struct {
+	Park         string               `json:"park"`
+	Start        time.Time            `json:"start"`
+	End          time.Time            `json:"end"`
+	Step         string               `json:"step"`
+	MaxAge       string               `json:"max_age"`
+	Observations []WeatherObservation `json:"observations"`
+	Frames       []WeatherFrame       `json:"frames"`
+}
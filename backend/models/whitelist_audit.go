@@ -0,0 +1,100 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Whitelist audit actions
+const (
+	WhitelistAuditAdd    = "add"
+	WhitelistAuditRemove = "remove"
+	WhitelistAuditModify = "modify"
+	WhitelistAuditErase  = "erase" // deleted with the vessel's data on an erasure request
+)
+
+// WhitelistSnapshot is the state of a whitelist entry before or after a
+// change
+type WhitelistSnapshot struct {
+	VesselUUID string     `json:"vessel_uuid"`
+	MMSI       string     `json:"mmsi"`
+	IMO        string     `json:"imo"`
+	Name       string     `json:"name"`
+	Reason     string     `json:"reason"`
+	AddedBy    string     `json:"added_by"`
+	OperatorID *uint      `json:"operator_id"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	IsActive   bool       `json:"is_active"`
+}
+
+// NewWhitelistSnapshot captures the audited fields of an entry
+func NewWhitelistSnapshot(entry *WhitelistEntry) *WhitelistSnapshot {
+	return &WhitelistSnapshot{
+		VesselUUID: entry.VesselUUID,
+		MMSI:       entry.MMSI,
+		IMO:        entry.IMO,
+		Name:       entry.Name,
+		Reason:     entry.Reason,
+		AddedBy:    entry.AddedBy,
+		OperatorID: entry.OperatorID,
+		ExpiresAt:  entry.ExpiresAt,
+		IsActive:   entry.IsActive,
+	}
+}
+
+// Equal reports whether two snapshots record the same entry state
+func (s *WhitelistSnapshot) Equal(other *WhitelistSnapshot) bool {
+	sameOperator := (s.OperatorID == nil) == (other.OperatorID == nil) &&
+		(s.OperatorID == nil || *s.OperatorID == *other.OperatorID)
+	sameExpiry := (s.ExpiresAt == nil) == (other.ExpiresAt == nil) &&
+		(s.ExpiresAt == nil || s.ExpiresAt.Equal(*other.ExpiresAt))
+	return sameOperator && sameExpiry &&
+		s.VesselUUID == other.VesselUUID && s.MMSI == other.MMSI && s.IMO == other.IMO &&
+		s.Name == other.Name && s.Reason == other.Reason && s.AddedBy == other.AddedBy &&
+		s.IsActive == other.IsActive
+}
+
+// Value stores the snapshot as JSON
+func (s WhitelistSnapshot) Value() (driver.Value, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan reads a snapshot stored as JSON
+func (s *WhitelistSnapshot) Scan(value interface{}) error {
+	switch data := value.(type) {
+	case []byte:
+		return json.Unmarshal(data, s)
+	case string:
+		return json.Unmarshal([]byte(data), s)
+	default:
+		return fmt.Errorf("unsupported whitelist snapshot value type %T", value)
+	}
+}
+
+// WhitelistAudit records one change to a whitelist entry: who made it, from
+// where, and the entry before and after. Before is nil for an addition and
+// After for an erasure. Rows are never updated or deleted; the database
+// rejects both.
+type WhitelistAudit struct {
+	ID         uint               `gorm:"primaryKey" json:"id"`
+	EntryID    uint               `gorm:"index;not null" json:"entry_id"`
+	VesselUUID string             `gorm:"index" json:"vessel_uuid"`
+	Action     string             `gorm:"index;not null" json:"action"`
+	Actor      string             `gorm:"index;not null" json:"actor"`
+	Role       string             `json:"role"`
+	ClientIP   string             `json:"client_ip"`
+	Before     *WhitelistSnapshot `gorm:"type:jsonb" json:"before"`
+	After      *WhitelistSnapshot `gorm:"type:jsonb" json:"after"`
+	CreatedAt  time.Time          `gorm:"index;not null" json:"created_at"`
+}
+
+// TableName keeps the audit trail in whitelist_audit
+func (WhitelistAudit) TableName() string {
+	return "whitelist_audit"
+}
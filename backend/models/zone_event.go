@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// Zones a vessel position can be in. The buffer zone surrounds the park, so a
+// position inside the park is in the park zone only.
+const (
+	ZoneOutside = "outside"
+	ZoneBuffer  = "buffer"
+	ZonePark    = "park"
+)
+
+// Zone transition event types
+const (
+	ZoneEventEnteredPark   = "entered_park"
+	ZoneEventLeftPark      = "left_park"
+	ZoneEventEnteredBuffer = "entered_buffer"
+	ZoneEventLeftBuffer    = "left_buffer"
+)
+
+// ZoneEvent records a vessel moving from one zone of a park to another
+// between two consecutive stored positions. OccurredAt is the time of the
+// position in the new zone and PreviousAt that of the position before it, so
+// the crossing happened in between.
+type ZoneEvent struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	ParkID     uint      `gorm:"index;not null" json:"park_id"`
+	VesselUUID string    `gorm:"index;not null" json:"vessel_uuid"`
+	Type       string    `gorm:"index;not null" json:"type"`
+	FromZone   string    `gorm:"not null" json:"from_zone"`
+	ToZone     string    `gorm:"not null" json:"to_zone"`
+	Latitude   float64   `gorm:"type:decimal(10,6)" json:"latitude"`
+	Longitude  float64   `gorm:"type:decimal(10,6)" json:"longitude"`
+	Speed      float64   `gorm:"type:decimal(8,2)" json:"speed"`
+	OccurredAt time.Time `gorm:"index;not null" json:"occurred_at"`
+	PreviousAt time.Time `json:"previous_at"`
+	CreatedAt  time.Time `json:"created_at"`
+
+	Vessel VesselRecord `gorm:"foreignKey:VesselUUID;references:UUID" json:"vessel,omitempty"`
+}
@@ -0,0 +1,282 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// vdmFragment is one NMEA 0183 AIVDM sentence. Messages longer than one
+// sentence are split into fragments sharing a sequential message ID.
+type vdmFragment struct {
+	count    int
+	number   int
+	sequence string
+	channel  string
+	payload  string
+	fillBits int
+}
+
+// parseVDM parses an AIVDM sentence such as
+// "!AIVDM,1,1,,A,13aEOK?P00PD2wVMdLDRhgvL289?,0*26", verifying its checksum.
+// A leading NMEA 4.0 tag block is skipped. Sentences from any talker are
+// accepted (AI, AB, BS, ...), but only VDM: VDO reports the station itself.
+func parseVDM(line string) (*vdmFragment, error) {
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, `\`) {
+		end := strings.Index(line[1:], `\`)
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated tag block")
+		}
+		line = line[end+2:]
+	}
+
+	if !strings.HasPrefix(line, "!") {
+		return nil, fmt.Errorf("not an encapsulated sentence")
+	}
+
+	star := strings.LastIndex(line, "*")
+	if star < 0 || len(line) < star+3 {
+		return nil, fmt.Errorf("missing checksum")
+	}
+	want, err := strconv.ParseUint(line[star+1:star+3], 16, 8)
+	if err != nil {
+		return nil, fmt.Errorf("invalid checksum %q", line[star+1:star+3])
+	}
+	var sum byte
+	for i := 1; i < star; i++ {
+		sum ^= line[i]
+	}
+	if sum != byte(want) {
+		return nil, fmt.Errorf("checksum mismatch: got %02X, want %02X", sum, want)
+	}
+
+	fields := strings.Split(line[1:star], ",")
+	if len(fields) != 7 {
+		return nil, fmt.Errorf("expected 7 fields, got %d", len(fields))
+	}
+	if len(fields[0]) != 5 || fields[0][2:] != "VDM" {
+		return nil, fmt.Errorf("unsupported sentence %s", fields[0])
+	}
+
+	fragment := &vdmFragment{
+		sequence: fields[3],
+		channel:  fields[4],
+		payload:  fields[5],
+	}
+	if fragment.count, err = strconv.Atoi(fields[1]); err != nil || fragment.count < 1 {
+		return nil, fmt.Errorf("invalid fragment count %q", fields[1])
+	}
+	if fragment.number, err = strconv.Atoi(fields[2]); err != nil || fragment.number < 1 || fragment.number > fragment.count {
+		return nil, fmt.Errorf("invalid fragment number %q", fields[2])
+	}
+	if fragment.fillBits, err = strconv.Atoi(fields[6]); err != nil || fragment.fillBits < 0 || fragment.fillBits > 5 {
+		return nil, fmt.Errorf("invalid fill bits %q", fields[6])
+	}
+
+	return fragment, nil
+}
+
+// aisBits is a decoded AIS payload, one bit per byte
+type aisBits []byte
+
+// unarmor decodes the 6-bit ASCII armoring of an AIS payload
+func unarmor(payload string, fillBits int) (aisBits, error) {
+	bits := make(aisBits, 0, len(payload)*6)
+	for i := 0; i < len(payload); i++ {
+		c := payload[i]
+		if c < 48 || c > 119 || (c > 87 && c < 96) {
+			return nil, fmt.Errorf("invalid payload character %q", c)
+		}
+		value := c - 48
+		if value > 40 {
+			value -= 8
+		}
+		for shift := 5; shift >= 0; shift-- {
+			bits = append(bits, (value>>shift)&1)
+		}
+	}
+
+	if fillBits > len(bits) {
+		return nil, fmt.Errorf("fill bits exceed payload")
+	}
+	return bits[:len(bits)-fillBits], nil
+}
+
+func (b aisBits) uint(start, length int) uint64 {
+	var value uint64
+	for i := start; i < start+length && i < len(b); i++ {
+		value = value<<1 | uint64(b[i])
+	}
+	return value
+}
+
+func (b aisBits) int(start, length int) int64 {
+	value := int64(b.uint(start, length))
+	if value&(1<<(length-1)) != 0 {
+		value -= 1 << length
+	}
+	return value
+}
+
+// text decodes a field of 6-bit characters, dropping the "@" padding and
+// trailing spaces. A field cut short by the payload is decoded as far as it
+// goes.
+func (b aisBits) text(start, chars int) string {
+	var sb strings.Builder
+	for i := 0; i < chars && start+i*6+6 <= len(b); i++ {
+		c := byte(b.uint(start+i*6, 6))
+		if c < 32 {
+			c += 64
+		}
+		if c == '@' {
+			break
+		}
+		sb.WriteByte(c)
+	}
+	return strings.TrimRight(sb.String(), " ")
+}
+
+// aisMessage is the part of a decoded AIS message the tracker uses. Position
+// reports (types 1, 2, 3 and 18) set HasPosition; static data (types 5 and
+// 24) sets the fields it carries.
+type aisMessage struct {
+	Type int
+	MMSI string
+
+	HasPosition bool
+	Latitude    float64
+	Longitude   float64
+	Speed       float64 // knots
+	Course      float64 // degrees
+	Heading     *int
+
+	Name        string
+	IMO         string
+	ShipType    int
+	Destination string
+}
+
+// Minimum payload lengths in bits of the supported message types
+const (
+	aisPositionReportBits = 168
+	aisStaticVoyageBits   = 420
+	aisStaticPartABits    = 160
+	aisStaticPartBBits    = 48
+)
+
+// decodeAIS decodes the payload of a complete AIS message. Unsupported types
+// return a nil message and no error.
+func decodeAIS(payload string, fillBits int) (*aisMessage, error) {
+	bits, err := unarmor(payload, fillBits)
+	if err != nil {
+		return nil, err
+	}
+	if len(bits) < 38 {
+		return nil, fmt.Errorf("payload too short: %d bits", len(bits))
+	}
+
+	msg := &aisMessage{
+		Type: int(bits.uint(0, 6)),
+		MMSI: fmt.Sprintf("%09d", bits.uint(8, 30)),
+	}
+
+	switch msg.Type {
+	case 1, 2, 3:
+		if len(bits) < aisPositionReportBits {
+			return nil, fmt.Errorf("type %d message too short: %d bits", msg.Type, len(bits))
+		}
+		msg.decodePosition(bits, 50, 61, 89, 116, 128)
+	case 18:
+		if len(bits) < aisPositionReportBits {
+			return nil, fmt.Errorf("type 18 message too short: %d bits", len(bits))
+		}
+		msg.decodePosition(bits, 46, 57, 85, 112, 124)
+	case 5:
+		if len(bits) < aisStaticVoyageBits {
+			return nil, fmt.Errorf("type 5 message too short: %d bits", len(bits))
+		}
+		if imo := bits.uint(40, 30); imo != 0 {
+			msg.IMO = strconv.FormatUint(imo, 10)
+		}
+		msg.Name = bits.text(112, 20)
+		msg.ShipType = int(bits.uint(232, 8))
+		msg.Destination = bits.text(302, 20)
+	case 24:
+		switch bits.uint(38, 2) {
+		case 0:
+			if len(bits) < aisStaticPartABits {
+				return nil, fmt.Errorf("type 24 part A message too short: %d bits", len(bits))
+			}
+			msg.Name = bits.text(40, 20)
+		case 1:
+			if len(bits) < aisStaticPartBBits {
+				return nil, fmt.Errorf("type 24 part B message too short: %d bits", len(bits))
+			}
+			msg.ShipType = int(bits.uint(40, 8))
+		}
+	default:
+		return nil, nil
+	}
+
+	return msg, nil
+}
+
+// decodePosition reads the fields of a position report, which sit at
+// different offsets in class A (types 1-3) and class B (type 18) reports.
+// Positions flagged as not available leave HasPosition unset.
+func (m *aisMessage) decodePosition(bits aisBits, speedAt, lonAt, latAt, courseAt, headingAt int) {
+	lon := float64(bits.int(lonAt, 28)) / 600000
+	lat := float64(bits.int(latAt, 27)) / 600000
+	if lon < -180 || lon > 180 || lat < -90 || lat > 90 {
+		return
+	}
+
+	m.HasPosition = true
+	m.Latitude = lat
+	m.Longitude = lon
+
+	if speed := bits.uint(speedAt, 10); speed != 1023 {
+		m.Speed = float64(speed) / 10
+	}
+	if course := bits.uint(courseAt, 12); course < 3600 {
+		m.Course = float64(course) / 10
+	}
+	if heading := int(bits.uint(headingAt, 9)); heading < 360 {
+		m.Heading = &heading
+	}
+}
+
+// aisShipTypeName maps an AIS ship type code to the vessel type names used by
+// the Datalastic feed
+func aisShipTypeName(code int) string {
+	switch {
+	case code == 30:
+		return "Fishing"
+	case code == 31 || code == 32 || code == 52:
+		return "Tug"
+	case code == 35:
+		return "Military"
+	case code == 36:
+		return "Sailing"
+	case code == 37:
+		return "Pleasure Craft"
+	case code >= 40 && code <= 49:
+		return "High Speed Craft"
+	case code == 50:
+		return "Pilot"
+	case code == 51:
+		return "Search and Rescue"
+	case code == 55:
+		return "Law Enforcement"
+	case code >= 60 && code <= 69:
+		return "Passenger"
+	case code >= 70 && code <= 79:
+		return "Cargo"
+	case code >= 80 && code <= 89:
+		return "Tanker"
+	case code == 0:
+		return ""
+	}
+	return "Other"
+}
@@ -0,0 +1,587 @@
+package services
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"vessel-tracker/database"
+	"vessel-tracker/logging"
+	"vessel-tracker/models"
+
+	"gorm.io/gorm"
+)
+
+// AIS receiver transports
+const (
+	AISNetworkTCP    = "tcp"
+	AISNetworkUDP    = "udp"
+	AISNetworkSerial = "serial"
+)
+
+const (
+	minAISFlushInterval = 10 * time.Second
+
+	// aisFragmentTimeout is how long the fragments of a multi-sentence
+	// message are kept while waiting for the rest
+	aisFragmentTimeout = time.Minute
+
+	// aisSerialRetryDelay is the wait before reopening a serial device that
+	// failed, e.g. a USB receiver that was unplugged
+	aisSerialRetryDelay = 5 * time.Second
+)
+
+// AISReceiverConfig holds the settings of the AIS receiver
+type AISReceiverConfig struct {
+	Network       string        // tcp, udp or serial
+	Address       string        // listen address, or the serial device path
+	FlushInterval time.Duration // how often received positions are stored and analyzed
+}
+
+func DefaultAISReceiverConfig() AISReceiverConfig {
+	return AISReceiverConfig{
+		Network:       AISNetworkUDP,
+		Address:       ":10110",
+		FlushInterval: time.Minute,
+	}
+}
+
+// LoadAISReceiverConfig reads AIS_RECEIVER_NETWORK, AIS_RECEIVER_ADDRESS and
+// AIS_RECEIVER_FLUSH_INTERVAL, falling back to the defaults for unset
+// variables
+func LoadAISReceiverConfig() (AISReceiverConfig, error) {
+	config := DefaultAISReceiverConfig()
+
+	if value := os.Getenv("AIS_RECEIVER_NETWORK"); value != "" {
+		config.Network = value
+	}
+
+	if value := os.Getenv("AIS_RECEIVER_ADDRESS"); value != "" {
+		config.Address = value
+	}
+
+	if value := os.Getenv("AIS_RECEIVER_FLUSH_INTERVAL"); value != "" {
+		interval, err := time.ParseDuration(value)
+		if err != nil {
+			return config, fmt.Errorf("invalid AIS_RECEIVER_FLUSH_INTERVAL %q: %w", value, err)
+		}
+		config.FlushInterval = interval
+	}
+
+	return config, config.Validate()
+}
+
+// Validate checks that the settings are usable
+func (c AISReceiverConfig) Validate() error {
+	switch c.Network {
+	case AISNetworkTCP, AISNetworkUDP, AISNetworkSerial:
+	default:
+		return fmt.Errorf("network must be %s, %s or %s, got %q", AISNetworkTCP, AISNetworkUDP, AISNetworkSerial, c.Network)
+	}
+	if c.Address == "" {
+		return fmt.Errorf("address is required")
+	}
+	if c.FlushInterval < minAISFlushInterval {
+		return fmt.Errorf("flush interval must be at least %s, got %s", minAISFlushInterval, c.FlushInterval)
+	}
+	return nil
+}
+
+// AISReceiverStatus reports what the receiver has decoded so far
+type AISReceiverStatus struct {
+	Network           string     `json:"network"`
+	Address           string     `json:"address"`
+	SentencesReceived int64      `json:"sentences_received"`
+	InvalidSentences  int64      `json:"invalid_sentences"`
+	MessagesDecoded   int64      `json:"messages_decoded"`
+	PositionsPending  int        `json:"positions_pending"`
+	VesselsWithStatic int        `json:"vessels_with_static_data"`
+	LastSentenceAt    *time.Time `json:"last_sentence_at"`
+	LastFlushAt       *time.Time `json:"last_flush_at"`
+	LastFlushError    string     `json:"last_flush_error,omitempty"`
+}
+
+// aisReport is the latest position report of a vessel
+type aisReport struct {
+	message    aisMessage
+	receivedAt time.Time
+}
+
+// aisStatic is the static and voyage data last reported by a vessel
+type aisStatic struct {
+	name        string
+	imo         string
+	shipType    int
+	destination string
+}
+
+// aisPartial collects the fragments of a multi-sentence message
+type aisPartial struct {
+	payloads []string
+	received int
+	started  time.Time
+}
+
+// AISReceiverService decodes NMEA 0183 AIVDM sentences from a local AIS base
+// station and feeds the positions into the scheduler's storage and detection
+// pipeline every flush interval, in place of Datalastic polling. Only the
+// latest report of each vessel in an interval is kept.
+//
+// With the serial transport the device is read as is; set its line speed
+// beforehand, e.g. `stty -F /dev/ttyUSB0 38400 raw`.
+type AISReceiverService struct {
+	config    AISReceiverConfig
+	scheduler *SchedulerService
+	directory *aisVesselDirectory
+	logger    *slog.Logger
+
+	mu        sync.Mutex
+	pending   map[string]aisReport
+	static    map[string]aisStatic
+	partials  map[string]*aisPartial
+	status    AISReceiverStatus
+	closers   map[io.Closer]struct{}
+	stop      chan struct{}
+	stopped   bool
+	waitGroup sync.WaitGroup
+}
+
+func NewAISReceiverService(config AISReceiverConfig, scheduler *SchedulerService) *AISReceiverService {
+	logger := logging.Component("ais")
+	return &AISReceiverService{
+		config:    config,
+		scheduler: scheduler,
+		directory: newAISVesselDirectory(logger),
+		logger:    logger,
+		pending:   make(map[string]aisReport),
+		static:    make(map[string]aisStatic),
+		partials:  make(map[string]*aisPartial),
+		closers:   make(map[io.Closer]struct{}),
+		status: AISReceiverStatus{
+			Network: config.Network,
+			Address: config.Address,
+		},
+		stop: make(chan struct{}),
+	}
+}
+
+// Start opens the configured transport and begins decoding and flushing
+func (s *AISReceiverService) Start() error {
+	switch s.config.Network {
+	case AISNetworkTCP:
+		listener, err := net.Listen("tcp", s.config.Address)
+		if err != nil {
+			return fmt.Errorf("failed to listen on tcp %s: %w", s.config.Address, err)
+		}
+		s.closers[listener] = struct{}{}
+		s.goRun(func() { s.acceptTCP(listener) })
+	case AISNetworkUDP:
+		conn, err := net.ListenPacket("udp", s.config.Address)
+		if err != nil {
+			return fmt.Errorf("failed to listen on udp %s: %w", s.config.Address, err)
+		}
+		s.closers[conn] = struct{}{}
+		s.goRun(func() { s.readUDP(conn) })
+	case AISNetworkSerial:
+		// Fail fast on a wrong path; later failures are retried
+		device, err := os.Open(s.config.Address)
+		if err != nil {
+			return fmt.Errorf("failed to open serial device %s: %w", s.config.Address, err)
+		}
+		s.goRun(func() { s.readSerial(device) })
+	}
+
+	s.goRun(s.flushLoop)
+
+	s.logger.Info("AIS receiver started", "network", s.config.Network, "address", s.config.Address, "flush_interval", s.config.FlushInterval.String())
+	return nil
+}
+
+// Stop closes the transport and stores the positions still pending
+func (s *AISReceiverService) Stop() {
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		return
+	}
+	s.stopped = true
+	for closer := range s.closers {
+		closer.Close()
+	}
+	s.mu.Unlock()
+
+	close(s.stop)
+	s.waitGroup.Wait()
+
+	s.flush()
+	s.logger.Info("AIS receiver stopped")
+}
+
+func (s *AISReceiverService) goRun(fn func()) {
+	s.waitGroup.Add(1)
+	go func() {
+		defer s.waitGroup.Done()
+		fn()
+	}()
+}
+
+// track registers a connection to close on Stop; it returns false when the
+// receiver is already stopping
+func (s *AISReceiverService) track(closer io.Closer) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped {
+		return false
+	}
+	s.closers[closer] = struct{}{}
+	return true
+}
+
+// untrack closes a connection that ended and forgets it
+func (s *AISReceiverService) untrack(closer io.Closer) {
+	s.mu.Lock()
+	delete(s.closers, closer)
+	s.mu.Unlock()
+	closer.Close()
+}
+
+func (s *AISReceiverService) acceptTCP(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				s.logger.Error("Failed to accept AIS connection", "error", err)
+			}
+			return
+		}
+		if !s.track(conn) {
+			conn.Close()
+			return
+		}
+
+		s.logger.Info("AIS feed connected", "remote", conn.RemoteAddr().String())
+		s.goRun(func() {
+			defer s.untrack(conn)
+			s.readLines(conn)
+			s.logger.Info("AIS feed disconnected", "remote", conn.RemoteAddr().String())
+		})
+	}
+}
+
+func (s *AISReceiverService) readUDP(conn net.PacketConn) {
+	buffer := make([]byte, 65535)
+	for {
+		n, _, err := conn.ReadFrom(buffer)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				s.logger.Error("Failed to read AIS datagram", "error", err)
+			}
+			return
+		}
+
+		// A datagram can carry several sentences
+		for _, line := range strings.Split(string(buffer[:n]), "\n") {
+			s.handleLine(line, time.Now())
+		}
+	}
+}
+
+func (s *AISReceiverService) readSerial(device *os.File) {
+	for device != nil {
+		if !s.track(device) {
+			device.Close()
+			return
+		}
+		s.readLines(device)
+		s.untrack(device)
+
+		device = s.reopenSerial()
+	}
+}
+
+// reopenSerial waits and reopens the serial device until it succeeds. It
+// returns nil once the receiver is stopping.
+func (s *AISReceiverService) reopenSerial() *os.File {
+	for {
+		select {
+		case <-s.stop:
+			return nil
+		case <-time.After(aisSerialRetryDelay):
+		}
+
+		device, err := os.Open(s.config.Address)
+		if err == nil {
+			s.logger.Info("Reopened AIS serial device", "device", s.config.Address)
+			return device
+		}
+		s.logger.Error("Failed to reopen AIS serial device", "device", s.config.Address, "error", err)
+	}
+}
+
+func (s *AISReceiverService) readLines(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		s.handleLine(scanner.Text(), time.Now())
+	}
+	if err := scanner.Err(); err != nil && !errors.Is(err, net.ErrClosed) && !errors.Is(err, os.ErrClosed) {
+		s.logger.Warn("AIS feed read failed", "error", err)
+	}
+}
+
+// handleLine decodes one sentence, assembling multi-sentence messages, and
+// keeps the position or static data it carries
+func (s *AISReceiverService) handleLine(line string, receivedAt time.Time) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.status.SentencesReceived++
+	s.status.LastSentenceAt = &receivedAt
+
+	fragment, err := parseVDM(line)
+	if err != nil {
+		s.status.InvalidSentences++
+		s.logger.Debug("Ignoring AIS sentence", "sentence", line, "error", err)
+		return
+	}
+
+	payload := fragment.payload
+	if fragment.count > 1 {
+		var complete bool
+		if payload, complete = s.assemble(fragment, receivedAt); !complete {
+			return
+		}
+	}
+
+	msg, err := decodeAIS(payload, fragment.fillBits)
+	if err != nil {
+		s.status.InvalidSentences++
+		s.logger.Debug("Ignoring AIS message", "sentence", line, "error", err)
+		return
+	}
+	if msg == nil {
+		return
+	}
+	s.status.MessagesDecoded++
+
+	if msg.HasPosition {
+		s.pending[msg.MMSI] = aisReport{message: *msg, receivedAt: receivedAt}
+		return
+	}
+
+	static := s.static[msg.MMSI]
+	if msg.Name != "" {
+		static.name = msg.Name
+	}
+	if msg.IMO != "" {
+		static.imo = msg.IMO
+	}
+	if msg.ShipType != 0 {
+		static.shipType = msg.ShipType
+	}
+	if msg.Destination != "" {
+		static.destination = msg.Destination
+	}
+	s.static[msg.MMSI] = static
+}
+
+// assemble adds a fragment to its message and returns the whole payload once
+// every fragment arrived. Fragments are keyed by sequence ID and channel.
+func (s *AISReceiverService) assemble(fragment *vdmFragment, receivedAt time.Time) (string, bool) {
+	key := fragment.sequence + "/" + fragment.channel
+
+	partial, ok := s.partials[key]
+	if !ok || fragment.number == 1 || len(partial.payloads) != fragment.count {
+		partial = &aisPartial{payloads: make([]string, fragment.count), started: receivedAt}
+		s.partials[key] = partial
+	}
+	if partial.payloads[fragment.number-1] == "" {
+		partial.received++
+	}
+	partial.payloads[fragment.number-1] = fragment.payload
+
+	if partial.received < fragment.count {
+		return "", false
+	}
+
+	delete(s.partials, key)
+	return strings.Join(partial.payloads, ""), true
+}
+
+func (s *AISReceiverService) flushLoop() {
+	ticker := time.NewTicker(s.config.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.flush()
+		}
+	}
+}
+
+// flush runs the positions received since the last flush through the
+// scheduler pipeline. If a fetch is still running they are kept for the next
+// flush, unless a newer report arrived in the meantime.
+func (s *AISReceiverService) flush() {
+	now := time.Now()
+
+	s.mu.Lock()
+	reports := s.pending
+	s.pending = make(map[string]aisReport)
+	static := make(map[string]aisStatic, len(reports))
+	for mmsi := range reports {
+		static[mmsi] = s.static[mmsi]
+	}
+	for key, partial := range s.partials {
+		if now.Sub(partial.started) > aisFragmentTimeout {
+			delete(s.partials, key)
+		}
+	}
+	s.mu.Unlock()
+
+	if len(reports) == 0 {
+		return
+	}
+
+	positions := make([]models.VesselPosition, 0, len(reports))
+	for mmsi, report := range reports {
+		positions = append(positions, s.directory.position(mmsi, report, static[mmsi]))
+	}
+
+	err := s.scheduler.IngestPositions(positions)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if errors.Is(err, ErrFetchRunning) || errors.Is(err, ErrSchedulerPaused) {
+		for mmsi, report := range reports {
+			if _, newer := s.pending[mmsi]; !newer {
+				s.pending[mmsi] = report
+			}
+		}
+		if errors.Is(err, ErrSchedulerPaused) {
+			s.logger.Info("Scheduler paused, keeping AIS positions for the next flush", "positions", len(reports))
+		} else {
+			s.logger.Warn("Fetch still running, keeping AIS positions for the next flush", "positions", len(reports))
+		}
+		return
+	}
+
+	s.status.LastFlushAt = &now
+	s.status.LastFlushError = ""
+	if err != nil {
+		s.status.LastFlushError = err.Error()
+		s.logger.Error("Failed to process AIS positions", "positions", len(positions), "error", err)
+		return
+	}
+	s.logger.Info("Processed AIS positions", "positions", len(positions))
+}
+
+// Status returns the receiver counters and the outcome of the last flush
+func (s *AISReceiverService) Status() AISReceiverStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := s.status
+	status.PositionsPending = len(s.pending)
+	status.VesselsWithStatic = len(s.static)
+	return status
+}
+
+// aisVesselDirectory maps the MMSIs heard over AIS to the vessels the tracker
+// knows, caching the lookups
+type aisVesselDirectory struct {
+	db      *gorm.DB
+	logger  *slog.Logger
+	mu      sync.Mutex
+	vessels map[string]models.VesselRecord
+}
+
+func newAISVesselDirectory(logger *slog.Logger) *aisVesselDirectory {
+	return &aisVesselDirectory{
+		db:      database.GetDB(),
+		logger:  logger,
+		vessels: make(map[string]models.VesselRecord),
+	}
+}
+
+// vesselPosition turns a position report into the feed format. A vessel the
+// tracker already knows keeps its UUID, and its stored details fill in static
+// data not received yet, so storing the position does not blank them.
+// Unknown vessels are identified as "ais-<MMSI>".
+func (d *aisVesselDirectory) position(mmsi string, report aisReport, static aisStatic) models.VesselPosition {
+	vessel := d.lookup(mmsi)
+
+	pos := models.VesselPosition{
+		UUID:         vessel.UUID,
+		Name:         vessel.Name,
+		MMSI:         mmsi,
+		IMO:          vessel.IMO,
+		Type:         vessel.Type,
+		TypeSpecific: vessel.TypeSpecific,
+		CountryISO:   vessel.CountryISO,
+		Destination:  vessel.Destination,
+		Latitude:     report.message.Latitude,
+		Longitude:    report.message.Longitude,
+		Speed:        report.message.Speed,
+		Course:       report.message.Course,
+		Heading:      report.message.Heading,
+		LastPosEpoch: report.receivedAt.Unix(),
+		LastPosUTC:   report.receivedAt.UTC().Format("2006-01-02 15:04:05"),
+	}
+
+	if static.name != "" {
+		pos.Name = static.name
+	}
+	if static.imo != "" {
+		pos.IMO = static.imo
+	}
+	if static.shipType != 0 {
+		pos.Type = aisShipTypeName(static.shipType)
+		pos.TypeSpecific = ""
+	}
+	if static.destination != "" {
+		pos.Destination = static.destination
+	}
+
+	return pos
+}
+
+// lookup returns the stored vessel with the given MMSI, or a new identity for
+// one the tracker has not seen
+func (d *aisVesselDirectory) lookup(mmsi string) models.VesselRecord {
+	d.mu.Lock()
+	vessel, ok := d.vessels[mmsi]
+	d.mu.Unlock()
+	if ok {
+		return vessel
+	}
+
+	err := d.db.Where("mmsi = ?", mmsi).Order("last_seen_at DESC").First(&vessel).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		vessel = models.VesselRecord{UUID: "ais-" + mmsi, MMSI: mmsi}
+	case err != nil:
+		// Not cached, the lookup is retried on the next report
+		d.logger.Warn("Failed to look up vessel by MMSI", "mmsi", mmsi, "error", err)
+		return models.VesselRecord{UUID: "ais-" + mmsi, MMSI: mmsi}
+	}
+
+	d.mu.Lock()
+	d.vessels[mmsi] = vessel
+	d.mu.Unlock()
+	return vessel
+}
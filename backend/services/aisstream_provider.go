@@ -0,0 +1,482 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"vessel-tracker/logging"
+	"vessel-tracker/models"
+
+	"golang.org/x/net/websocket"
+)
+
+const (
+	aisStreamURL    = "wss://stream.aisstream.io/v0/stream"
+	aisStreamOrigin = "https://aisstream.io"
+
+	// aisStreamMaxAge is how long a position is served as current; vessels
+	// not heard from for longer are dropped
+	aisStreamMaxAge = 30 * time.Minute
+
+	// aisStreamReadTimeout reconnects a stream that went silent
+	aisStreamReadTimeout = 2 * time.Minute
+
+	aisStreamDialTimeout   = 30 * time.Second
+	aisStreamRetryDelay    = 5 * time.Second
+	aisStreamMaxRetryDelay = 5 * time.Minute
+)
+
+// aisStreamMessageTypes are the AISStream.io message types subscribed to
+var aisStreamMessageTypes = []string{"PositionReport", "StandardClassBPositionReport", "ShipStaticData", "StaticDataReport"}
+
+// aisStreamSubscription is the message that opens or updates a subscription.
+// Bounding boxes are pairs of [latitude, longitude] corners.
+type aisStreamSubscription struct {
+	APIKey             string          `json:"APIKey"`
+	BoundingBoxes      [][2][2]float64 `json:"BoundingBoxes"`
+	FilterMessageTypes []string        `json:"FilterMessageTypes"`
+}
+
+// aisStreamPosition is the part of a class A or class B position report used
+type aisStreamPosition struct {
+	Latitude    float64
+	Longitude   float64
+	Sog         float64
+	Cog         float64
+	TrueHeading int
+}
+
+// aisStreamMessage is a decoded AIS message as AISStream.io delivers it
+type aisStreamMessage struct {
+	Error       string `json:"error"`
+	MessageType string
+	MetaData    struct {
+		MMSI    int64
+		TimeUTC string `json:"time_utc"`
+	}
+	Message struct {
+		PositionReport               *aisStreamPosition
+		StandardClassBPositionReport *aisStreamPosition
+		ShipStaticData               *struct {
+			Name        string
+			ImoNumber   int64
+			Type        int
+			Destination string
+		}
+		StaticDataReport *struct {
+			ReportA struct {
+				Valid bool
+				Name  string
+			}
+			ReportB struct {
+				Valid    bool
+				ShipType int
+			}
+		}
+	}
+}
+
+// aisStreamVessel is what the stream reported last about a vessel
+type aisStreamVessel struct {
+	report  *aisReport
+	static  aisStatic
+	heardAt time.Time
+}
+
+// AISStreamProvider serves positions streamed over a WebSocket from
+// AISStream.io, a free terrestrial AIS feed. Each area queried is added to
+// the subscription, so the first query of an area returns what has been
+// heard so far, usually nothing; positions fill in as vessels report.
+// The stream carries no history or vessel registry data beyond what AIS
+// broadcasts.
+type AISStreamProvider struct {
+	apiKey    string
+	url       string
+	directory *aisVesselDirectory
+	logger    *slog.Logger
+
+	mu            sync.Mutex
+	areas         map[[2][2]float64]bool
+	vessels       map[string]*aisStreamVessel
+	conn          *websocket.Conn
+	started       bool
+	stopped       bool
+	lastMessageAt time.Time
+	lastError     error
+	stop          chan struct{}
+	waitGroup     sync.WaitGroup
+}
+
+func NewAISStreamProvider(apiKey, url string) *AISStreamProvider {
+	logger := logging.Component("aisstream")
+	return &AISStreamProvider{
+		apiKey:    apiKey,
+		url:       url,
+		directory: newAISVesselDirectory(logger),
+		logger:    logger,
+		areas:     make(map[[2][2]float64]bool),
+		vessels:   make(map[string]*aisStreamVessel),
+		stop:      make(chan struct{}),
+	}
+}
+
+func (p *AISStreamProvider) Name() string {
+	return ProviderAISStream
+}
+
+// Close ends the stream
+func (p *AISStreamProvider) Close() error {
+	p.mu.Lock()
+	if p.stopped {
+		p.mu.Unlock()
+		return nil
+	}
+	p.stopped = true
+	if p.conn != nil {
+		p.conn.Close()
+	}
+	p.mu.Unlock()
+
+	close(p.stop)
+	p.waitGroup.Wait()
+	return nil
+}
+
+// GetVesselsInRadius returns the vessels heard within radius nautical miles
+// in the last aisStreamMaxAge, subscribing to the area if it is new
+func (p *AISStreamProvider) GetVesselsInRadius(ctx context.Context, lat, lon float64, radius int) (*models.VesselPositionResponse, error) {
+	p.watch(aisStreamBox(lat, lon, float64(radius)))
+
+	vessels := []models.VesselPosition{}
+	for _, pos := range p.positions() {
+		distance := HaversineDistance(lat, lon, pos.Latitude, pos.Longitude) / metersPerNauticalMile
+		if distance > float64(radius) {
+			continue
+		}
+		pos.Distance = distance
+		vessels = append(vessels, pos)
+	}
+
+	return &models.VesselPositionResponse{
+		Data: models.VesselPositionData{
+			Point:   map[string]interface{}{"lat": lat, "lon": lon, "radius": radius},
+			Total:   len(vessels),
+			Vessels: vessels,
+		},
+	}, nil
+}
+
+// SearchVessels matches the vessels currently heard against the search
+// parameters
+func (p *AISStreamProvider) SearchVessels(ctx context.Context, params map[string]string) (*models.VesselResponse, error) {
+	vessels := []models.Vessel{}
+	for _, pos := range p.positions() {
+		if matchesVesselQuery(params, pos) {
+			vessels = append(vessels, vesselFromPosition(pos))
+		}
+	}
+
+	return &models.VesselResponse{
+		Data: vessels,
+		Meta: models.Meta{Endpoint: "vessel_find", Success: true},
+	}, nil
+}
+
+// GetHistory is not supported: the stream only carries live positions
+func (p *AISStreamProvider) GetHistory(ctx context.Context, params map[string]string) (*models.VesselHistoryResponse, error) {
+	return nil, fmt.Errorf("vessel history: %w", ErrProviderUnsupported)
+}
+
+// Ping reports the last stream failure, or a stream that went silent. Before
+// any area is queried, or while connecting the first time, there is nothing
+// to report.
+func (p *AISStreamProvider) Ping(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch {
+	case p.conn == nil && p.lastError != nil:
+		return fmt.Errorf("stream disconnected: %w", p.lastError)
+	case p.conn != nil && !p.lastMessageAt.IsZero() && time.Since(p.lastMessageAt) > aisStreamReadTimeout:
+		return fmt.Errorf("no messages since %s", p.lastMessageAt.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// aisStreamBox returns the bounding box around a circle, as the
+// [[south, west], [north, east]] corners
+func aisStreamBox(lat, lon, radiusNM float64) [2][2]float64 {
+	dLat := radiusNM / 60
+	dLon := 180.0
+	if cos := math.Cos(toRadians(lat)); cos > 0.01 {
+		dLon = math.Min(dLat/cos, 180)
+	}
+	return [2][2]float64{
+		{math.Max(lat-dLat, -90), math.Max(lon-dLon, -180)},
+		{math.Min(lat+dLat, 90), math.Min(lon+dLon, 180)},
+	}
+}
+
+// watch adds an area to the subscription, starting the stream on the first
+// one
+func (p *AISStreamProvider) watch(box [2][2]float64) {
+	p.mu.Lock()
+	if p.stopped || p.areas[box] {
+		p.mu.Unlock()
+		return
+	}
+	p.areas[box] = true
+	conn := p.conn
+
+	if !p.started {
+		p.started = true
+		p.waitGroup.Add(1)
+		go func() {
+			defer p.waitGroup.Done()
+			p.run()
+		}()
+	}
+	p.mu.Unlock()
+
+	p.logger.Info("Subscribing to AIS area", "south_west", box[0], "north_east", box[1])
+	if conn != nil {
+		if err := p.subscribe(conn); err != nil {
+			// The read loop sees the broken connection and reconnects with
+			// every area
+			p.logger.Warn("Failed to update AIS subscription", "error", err)
+		}
+	}
+}
+
+func (p *AISStreamProvider) subscribe(conn *websocket.Conn) error {
+	p.mu.Lock()
+	subscription := aisStreamSubscription{
+		APIKey:             p.apiKey,
+		BoundingBoxes:      make([][2][2]float64, 0, len(p.areas)),
+		FilterMessageTypes: aisStreamMessageTypes,
+	}
+	for box := range p.areas {
+		subscription.BoundingBoxes = append(subscription.BoundingBoxes, box)
+	}
+	p.mu.Unlock()
+
+	return websocket.JSON.Send(conn, subscription)
+}
+
+// run keeps the stream connected until Close, backing off while connecting
+// fails
+func (p *AISStreamProvider) run() {
+	delay := aisStreamRetryDelay
+	for {
+		received, err := p.stream()
+
+		p.mu.Lock()
+		stopped := p.stopped
+		p.lastError = err
+		p.mu.Unlock()
+		if stopped {
+			return
+		}
+
+		if received {
+			delay = aisStreamRetryDelay
+		}
+		p.logger.Warn("AIS stream disconnected, reconnecting", "error", err, "retry_in", delay.String())
+
+		select {
+		case <-p.stop:
+			return
+		case <-time.After(delay):
+		}
+		if !received {
+			delay = min(delay*2, aisStreamMaxRetryDelay)
+		}
+	}
+}
+
+// stream connects, subscribes and reads messages until the connection fails.
+// It reports whether any message was received.
+func (p *AISStreamProvider) stream() (bool, error) {
+	config, err := websocket.NewConfig(p.url, aisStreamOrigin)
+	if err != nil {
+		return false, fmt.Errorf("invalid stream URL: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), aisStreamDialTimeout)
+	conn, err := config.DialContext(ctx)
+	cancel()
+	if err != nil {
+		return false, fmt.Errorf("failed to connect: %w", err)
+	}
+
+	p.mu.Lock()
+	if p.stopped {
+		p.mu.Unlock()
+		conn.Close()
+		return false, nil
+	}
+	p.conn = conn
+	p.mu.Unlock()
+
+	defer func() {
+		p.mu.Lock()
+		p.conn = nil
+		p.mu.Unlock()
+		conn.Close()
+	}()
+
+	if err := p.subscribe(conn); err != nil {
+		return false, fmt.Errorf("failed to subscribe: %w", err)
+	}
+	p.logger.Info("AIS stream connected", "url", p.url)
+
+	received := false
+	for {
+		conn.SetReadDeadline(time.Now().Add(aisStreamReadTimeout))
+
+		var data []byte
+		if err := websocket.Message.Receive(conn, &data); err != nil {
+			return received, fmt.Errorf("failed to read: %w", err)
+		}
+
+		var msg aisStreamMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			p.logger.Debug("Ignoring undecodable AIS stream message", "error", err)
+			continue
+		}
+		if msg.Error != "" {
+			return received, fmt.Errorf("stream error: %s", msg.Error)
+		}
+
+		received = true
+		p.handleMessage(&msg, time.Now())
+	}
+}
+
+// handleMessage records a position report or the static data of a vessel
+func (p *AISStreamProvider) handleMessage(msg *aisStreamMessage, receivedAt time.Time) {
+	if msg.MetaData.MMSI == 0 {
+		return
+	}
+	mmsi := fmt.Sprintf("%09d", msg.MetaData.MMSI)
+
+	// The stream stamps each message when it was received from the station
+	if at, err := time.Parse("2006-01-02 15:04:05.999999999 -0700 MST", msg.MetaData.TimeUTC); err == nil && at.Before(receivedAt) {
+		receivedAt = at
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.lastMessageAt = time.Now()
+	vessel, ok := p.vessels[mmsi]
+	if !ok {
+		vessel = &aisStreamVessel{}
+		p.vessels[mmsi] = vessel
+	}
+	vessel.heardAt = receivedAt
+
+	report := msg.Message.PositionReport
+	if report == nil {
+		report = msg.Message.StandardClassBPositionReport
+	}
+	if report != nil {
+		if position, ok := aisStreamReport(mmsi, report, receivedAt); ok {
+			vessel.report = &position
+		}
+		return
+	}
+
+	if static := msg.Message.ShipStaticData; static != nil {
+		vessel.static.name = aisStreamText(static.Name)
+		if static.ImoNumber > 0 {
+			vessel.static.imo = fmt.Sprintf("%d", static.ImoNumber)
+		}
+		if static.Type != 0 {
+			vessel.static.shipType = static.Type
+		}
+		if destination := aisStreamText(static.Destination); destination != "" {
+			vessel.static.destination = destination
+		}
+	}
+	if static := msg.Message.StaticDataReport; static != nil {
+		if static.ReportA.Valid {
+			vessel.static.name = aisStreamText(static.ReportA.Name)
+		}
+		if static.ReportB.Valid && static.ReportB.ShipType != 0 {
+			vessel.static.shipType = static.ReportB.ShipType
+		}
+	}
+}
+
+// aisStreamReport converts a streamed position report, dropping the "not
+// available" values as the NMEA decoder does
+func aisStreamReport(mmsi string, report *aisStreamPosition, receivedAt time.Time) (aisReport, bool) {
+	if report.Latitude < -90 || report.Latitude > 90 || report.Longitude < -180 || report.Longitude > 180 {
+		return aisReport{}, false
+	}
+
+	msg := aisMessage{
+		MMSI:        mmsi,
+		HasPosition: true,
+		Latitude:    report.Latitude,
+		Longitude:   report.Longitude,
+	}
+	if report.Sog < 102.3 {
+		msg.Speed = report.Sog
+	}
+	if report.Cog < 360 {
+		msg.Course = report.Cog
+	}
+	if heading := report.TrueHeading; heading >= 0 && heading < 360 {
+		msg.Heading = &heading
+	}
+
+	return aisReport{message: msg, receivedAt: receivedAt}, true
+}
+
+// aisStreamText drops the "@" padding and spaces around a text field
+func aisStreamText(value string) string {
+	if at := strings.IndexByte(value, '@'); at >= 0 {
+		value = value[:at]
+	}
+	return strings.TrimSpace(value)
+}
+
+// positions returns the latest position of every vessel heard recently,
+// dropping the vessels not heard from in aisStreamMaxAge
+func (p *AISStreamProvider) positions() []models.VesselPosition {
+	cutoff := time.Now().Add(-aisStreamMaxAge)
+
+	type current struct {
+		mmsi   string
+		report aisReport
+		static aisStatic
+	}
+	var heard []current
+
+	p.mu.Lock()
+	for mmsi, vessel := range p.vessels {
+		if vessel.heardAt.Before(cutoff) {
+			delete(p.vessels, mmsi)
+			continue
+		}
+		if vessel.report != nil && !vessel.report.receivedAt.Before(cutoff) {
+			heard = append(heard, current{mmsi: mmsi, report: *vessel.report, static: vessel.static})
+		}
+	}
+	p.mu.Unlock()
+
+	positions := make([]models.VesselPosition, 0, len(heard))
+	for _, vessel := range heard {
+		positions = append(positions, p.directory.position(vessel.mmsi, vessel.report, vessel.static))
+	}
+	sort.Slice(positions, func(i, j int) bool { return positions[i].MMSI < positions[j].MMSI })
+	return positions
+}
@@ -0,0 +1,329 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"strconv"
+	"time"
+	"vessel-tracker/database"
+	"vessel-tracker/logging"
+	"vessel-tracker/models"
+
+	"gorm.io/gorm"
+)
+
+// AnchorDragConfig holds when an anchored vessel is taken to be dragging its
+// anchor: its last MinPositions fixes each further from where the anchor was
+// set than SwingRadiusMeters plus the vessel's length, without the vessel
+// having moved faster than MaxSpeedKnots since it anchored. In wind of at
+// least MinWindKnots the vessel must also have moved downwind, within
+// DownwindToleranceDeg of the direction the wind blows toward.
+type AnchorDragConfig struct {
+	Enabled              bool
+	SwingRadiusMeters    float64
+	MaxSpeedKnots        float64
+	MinPositions         int
+	Window               time.Duration // how long after its anchoring event ended a vessel is still checked
+	MinWindKnots         float64
+	DownwindToleranceDeg float64
+}
+
+func DefaultAnchorDragConfig() AnchorDragConfig {
+	return AnchorDragConfig{
+		Enabled:              true,
+		SwingRadiusMeters:    75,
+		MaxSpeedKnots:        2,
+		MinPositions:         3,
+		Window:               time.Hour,
+		MinWindKnots:         10,
+		DownwindToleranceDeg: 60,
+	}
+}
+
+// LoadAnchorDragConfig reads ANCHOR_DRAG_ENABLED,
+// ANCHOR_DRAG_SWING_RADIUS_METERS, ANCHOR_DRAG_MAX_SPEED,
+// ANCHOR_DRAG_MIN_POSITIONS, ANCHOR_DRAG_WINDOW, ANCHOR_DRAG_MIN_WIND and
+// ANCHOR_DRAG_DOWNWIND_TOLERANCE_DEG
+func LoadAnchorDragConfig() (AnchorDragConfig, error) {
+	config := DefaultAnchorDragConfig()
+
+	if value := os.Getenv("ANCHOR_DRAG_ENABLED"); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return config, fmt.Errorf("invalid ANCHOR_DRAG_ENABLED %q: %w", value, err)
+		}
+		config.Enabled = enabled
+	}
+
+	floats := map[string]*float64{
+		"ANCHOR_DRAG_SWING_RADIUS_METERS": &config.SwingRadiusMeters,
+		"ANCHOR_DRAG_MAX_SPEED":           &config.MaxSpeedKnots,
+		"ANCHOR_DRAG_MIN_WIND":            &config.MinWindKnots,
+	}
+	for name, target := range floats {
+		if value := os.Getenv(name); value != "" {
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil || f <= 0 {
+				return config, fmt.Errorf("invalid %s %q: must be a positive number", name, value)
+			}
+			*target = f
+		}
+	}
+
+	if value := os.Getenv("ANCHOR_DRAG_DOWNWIND_TOLERANCE_DEG"); value != "" {
+		deg, err := strconv.ParseFloat(value, 64)
+		if err != nil || deg <= 0 || deg > 180 {
+			return config, fmt.Errorf("invalid ANCHOR_DRAG_DOWNWIND_TOLERANCE_DEG %q: must be between 0 and 180", value)
+		}
+		config.DownwindToleranceDeg = deg
+	}
+	if value := os.Getenv("ANCHOR_DRAG_MIN_POSITIONS"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 2 {
+			return config, fmt.Errorf("invalid ANCHOR_DRAG_MIN_POSITIONS %q: must be at least 2", value)
+		}
+		config.MinPositions = n
+	}
+	if value := os.Getenv("ANCHOR_DRAG_WINDOW"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			return config, fmt.Errorf("invalid ANCHOR_DRAG_WINDOW %q: must be a non-negative duration", value)
+		}
+		config.Window = d
+	}
+
+	return config, nil
+}
+
+// AnchorDrag is an anchored vessel found dragging its anchor
+type AnchorDrag struct {
+	Event             models.AnchoringEvent
+	AnchorLatitude    float64
+	AnchorLongitude   float64
+	Latest            models.VesselPositionRecord // the fix furthest from the anchor
+	DistanceMeters    float64                     // of the latest fix from the anchor
+	SwingRadiusMeters float64
+	Since             time.Time // first of the fixes beyond the swing radius
+	BearingDeg        float64   // from the anchor to the latest fix
+	Wind              *models.WeatherObservation
+	OnPosidonia       *models.HabitatLayer // a posidonia layer under the latest fix, nil when none
+}
+
+// anchorDragState is what the positions since a vessel anchored say
+type anchorDragState int
+
+const (
+	anchorHolding anchorDragState = iota // within the swing radius, or not migrating
+	anchorDragging
+	anchorWeighed // moved faster than a dragging anchor allows since anchoring
+)
+
+// AnchorDragDetector raises a safety alert for anchored vessels whose
+// position slowly migrates beyond the swing radius of their anchor, which
+// both endangers them and scours the seagrass the anchor is dragged across
+type AnchorDragDetector struct {
+	db               *gorm.DB
+	config           AnchorDragConfig
+	anchoring        AnchoringConfig
+	vesselRepo       *VesselRepository
+	violationService *ViolationService
+	weatherService   *WeatherService
+	logger           *slog.Logger
+}
+
+func NewAnchorDragDetector(config AnchorDragConfig, anchoring AnchoringConfig, vesselRepo *VesselRepository, violationService *ViolationService, weatherService *WeatherService) *AnchorDragDetector {
+	return &AnchorDragDetector{
+		db:               database.GetDB(),
+		config:           config,
+		anchoring:        anchoring,
+		vesselRepo:       vesselRepo,
+		violationService: violationService,
+		weatherService:   weatherService,
+		logger:           logging.Component("anchor-drag"),
+	}
+}
+
+// Config returns the thresholds dragging is detected with
+func (d *AnchorDragDetector) Config() AnchorDragConfig {
+	return d.config
+}
+
+// Check looks for dragging anchors among the vessels of the positions that
+// are anchored in a park or were within Window, raises an alert for each one
+// found and resolves the alerts of vessels that weighed anchor. It runs after
+// the anchoring analysis of the positions and returns the number of alerts
+// raised and resolved.
+func (d *AnchorDragDetector) Check(ctx context.Context, park *Park, positions []models.VesselPosition) (int, int) {
+	if !d.config.Enabled || len(positions) == 0 {
+		return 0, 0
+	}
+	now := time.Now()
+
+	uuids := make([]string, 0, len(positions))
+	for _, pos := range positions {
+		uuids = append(uuids, pos.UUID)
+	}
+	var events []models.AnchoringEvent
+	err := d.db.WithContext(ctx).
+		Where("park_id = ? AND vessel_uuid IN ? AND (ended_at IS NULL OR ended_at >= ?)", park.Record.ID, uuids, now.Add(-d.config.Window)).
+		Order("started_at ASC").
+		Find(&events).Error
+	if err != nil {
+		d.logger.Error("Failed to load anchoring events for drag detection", "park", park.Record.Slug, "error", err)
+		return 0, 0
+	}
+	// A dragged vessel may have left the drift radius of its first event and
+	// opened another where it drifted to; the first one holds the anchor
+	byVessel := make(map[string][]models.AnchoringEvent)
+	for _, event := range events {
+		byVessel[event.VesselUUID] = append(byVessel[event.VesselUUID], event)
+	}
+
+	var wind *models.WeatherObservation
+	if d.weatherService != nil && d.weatherService.Config().Enabled {
+		wind, err = d.weatherService.Latest(ctx, park.Record.ID, now)
+		if err != nil {
+			d.logger.Warn("Checking anchors without the wind", "park", park.Record.Slug, "error", err)
+		}
+	}
+
+	detected, resolved := 0, 0
+	for _, pos := range positions {
+		if ctx.Err() != nil {
+			break
+		}
+
+		state := anchorWeighed
+		var drag *AnchorDrag
+		for _, event := range byVessel[pos.UUID] {
+			eventState, found, err := d.checkEvent(ctx, park, event, wind)
+			if err != nil {
+				d.logger.Error("Anchor drag check failed", "vessel_uuid", pos.UUID, "event_id", event.ID, "error", err)
+				state = anchorHolding
+				break
+			}
+			if eventState == anchorDragging {
+				state, drag = eventState, found
+				break
+			}
+			if eventState == anchorHolding {
+				state = anchorHolding
+			}
+		}
+
+		switch state {
+		case anchorDragging:
+			raised, err := d.violationService.RecordAnchorDrag(park, pos, drag)
+			if err != nil {
+				d.logger.Error("Failed to record anchor drag", "vessel_uuid", pos.UUID, "error", err)
+			} else if raised {
+				detected++
+			}
+		case anchorWeighed:
+			closed, err := d.violationService.ResolveAnchorDrag(park, pos)
+			if err != nil {
+				d.logger.Error("Failed to resolve anchor drag", "vessel_uuid", pos.UUID, "error", err)
+			} else if closed {
+				resolved++
+			}
+		}
+	}
+
+	return detected, resolved
+}
+
+// checkEvent tells from the positions since an anchoring event started
+// whether its anchor holds, drags or was weighed
+func (d *AnchorDragDetector) checkEvent(ctx context.Context, park *Park, event models.AnchoringEvent, wind *models.WeatherObservation) (anchorDragState, *AnchorDrag, error) {
+	anchorLat, anchorLon := event.AnchorLatitude, event.AnchorLongitude
+	if anchorLat == 0 && anchorLon == 0 {
+		// Events opened before the anchor point was recorded
+		anchorLat, anchorLon = event.Latitude, event.Longitude
+	}
+
+	since := event.StartedAt
+	if lookback := time.Now().Add(-d.anchoring.LookbackWindow); since.Before(lookback) {
+		since = lookback
+	}
+	positions, err := d.vesselRepo.GetRecentPositions(ctx, event.ParkID, event.VesselUUID, since)
+	if err != nil {
+		return anchorHolding, nil, fmt.Errorf("failed to load recent positions: %w", err)
+	}
+
+	// A dragging anchor moves the vessel slowly; any faster fix since it
+	// anchored means it got under way
+	fixes := make([]models.VesselPositionRecord, 0, len(positions))
+	for _, pos := range positions {
+		if pos.OnLand {
+			continue
+		}
+		if pos.Speed > d.config.MaxSpeedKnots {
+			return anchorWeighed, nil, nil
+		}
+		fixes = append(fixes, pos)
+	}
+	if len(fixes) < d.config.MinPositions {
+		return anchorHolding, nil, nil
+	}
+
+	radius := d.config.SwingRadiusMeters + d.vesselLength(event.VesselUUID)
+
+	// The trailing fixes must each lie beyond the swing radius, each at least
+	// as far out as the one before
+	trailing := fixes[len(fixes)-d.config.MinPositions:]
+	previous := 0.0
+	for _, fix := range trailing {
+		distance := HaversineDistance(anchorLat, anchorLon, fix.Latitude, fix.Longitude)
+		if distance <= radius || distance < previous {
+			return anchorHolding, nil, nil
+		}
+		previous = distance
+	}
+
+	latest := trailing[len(trailing)-1]
+	drag := &AnchorDrag{
+		Event:             event,
+		AnchorLatitude:    anchorLat,
+		AnchorLongitude:   anchorLon,
+		Latest:            latest,
+		DistanceMeters:    previous,
+		SwingRadiusMeters: radius,
+		Since:             trailing[0].RecordedAt,
+		BearingDeg:        InitialBearing(anchorLat, anchorLon, latest.Latitude, latest.Longitude),
+	}
+
+	// In a steady wind a vessel lies downwind of its anchor, and a dragging
+	// anchor lets it go further downwind; moving against the wind it is not
+	// dragged
+	if wind != nil && wind.WindSpeedKnots >= d.config.MinWindKnots {
+		downwind := math.Mod(wind.WindDirectionDeg+180, 360)
+		off := math.Abs(math.Mod(drag.BearingDeg-downwind+540, 360) - 180)
+		if off > d.config.DownwindToleranceDeg {
+			return anchorHolding, nil, nil
+		}
+		drag.Wind = wind
+	}
+
+	for _, layer := range park.Geo.HabitatLayersAt(latest.Latitude, latest.Longitude, latest.RecordedAt) {
+		if layer.Kind == models.HabitatKindPosidonia {
+			drag.OnPosidonia = &layer
+			break
+		}
+	}
+
+	return anchorDragging, drag, nil
+}
+
+// vesselLength returns the stored length of a vessel in meters, 0 when it is
+// unknown
+func (d *AnchorDragDetector) vesselLength(vesselUUID string) float64 {
+	var lengths []float64
+	d.db.Model(&models.VesselRecord{}).Where("uuid = ?", vesselUUID).Limit(1).Pluck("length", &lengths)
+	if len(lengths) == 0 {
+		return 0
+	}
+	return lengths[0]
+}
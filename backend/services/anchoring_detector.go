@@ -0,0 +1,288 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+	"vessel-tracker/database"
+	"vessel-tracker/logging"
+	"vessel-tracker/models"
+
+	"gorm.io/gorm"
+)
+
+// AnchoringConfig holds the thresholds used to classify a vessel as anchored
+type AnchoringConfig struct {
+	MaxSpeedKnots  float64       // positions at or below this speed count as stationary
+	MaxDriftMeters float64       // maximum distance from the centroid of the stationary run
+	MinPositions   int           // consecutive stationary positions required
+	LookbackWindow time.Duration // how far back to analyze positions
+
+	// A slow position moving with a tidal current of at least MinCurrentKnots,
+	// its velocity within CurrentMatchKnots of the current's, is drifting
+	// rather than held by an anchor. Weaker currents cannot tell them apart.
+	MinCurrentKnots   float64
+	CurrentMatchKnots float64
+}
+
+func DefaultAnchoringConfig() AnchoringConfig {
+	return AnchoringConfig{
+		MaxSpeedKnots:     0.5,
+		MaxDriftMeters:    150,
+		MinPositions:      3,
+		LookbackWindow:    12 * time.Hour,
+		MinCurrentKnots:   0.2,
+		CurrentMatchKnots: 0.15,
+	}
+}
+
+// AnchoringDetector analyzes consecutive stored positions per vessel and
+// maintains anchoring events with start and end timestamps
+type AnchoringDetector struct {
+	db         *gorm.DB
+	vesselRepo *VesselRepository
+	config     AnchoringConfig
+	logger     *slog.Logger
+}
+
+func NewAnchoringDetector(vesselRepo *VesselRepository, config AnchoringConfig) *AnchoringDetector {
+	return &AnchoringDetector{
+		db:         database.GetDB(),
+		vesselRepo: vesselRepo,
+		config:     config,
+		logger:     logging.Component("anchoring"),
+	}
+}
+
+// Config returns the thresholds the detector classifies anchoring with
+func (d *AnchoringDetector) Config() AnchoringConfig {
+	return d.config
+}
+
+// stationaryRun is the trailing run of stationary positions for a vessel
+type stationaryRun struct {
+	positions         []models.VesselPositionRecord
+	centerLat         float64
+	centerLon         float64
+	driftRadiusMeters float64
+}
+
+// driftingWithCurrent reports whether a position moves with the tidal
+// current predicted there, as a vessel adrift does, rather than being held
+// against it
+func driftingWithCurrent(pos models.VesselPositionRecord, config AnchoringConfig) bool {
+	current := pos.Current()
+	if current == nil || current.SpeedKnots < config.MinCurrentKnots || pos.Speed <= 0 {
+		return false
+	}
+	return current.ThroughWater(pos.Speed, pos.Course) <= config.CurrentMatchKnots
+}
+
+// maxCurrent returns the strongest tidal current predicted during a run, or
+// nil when none is known
+func (run stationaryRun) maxCurrent() *float64 {
+	var strongest *float64
+	for _, pos := range run.positions {
+		if current := pos.Current(); current != nil && (strongest == nil || current.SpeedKnots > *strongest) {
+			speed := current.SpeedKnots
+			strongest = &speed
+		}
+	}
+	return strongest
+}
+
+// findStationaryRun walks positions from newest to oldest and returns the
+// longest trailing run that is slow, inside the park, not drifting with the
+// current and within the drift radius of the given thresholds
+func findStationaryRun(positions []models.VesselPositionRecord, config AnchoringConfig) stationaryRun {
+	var run stationaryRun
+
+	for i := len(positions) - 1; i >= 0; i-- {
+		pos := positions[i]
+		if pos.Speed > config.MaxSpeedKnots || !pos.IsInPark || driftingWithCurrent(pos, config) {
+			break
+		}
+
+		candidate := append([]models.VesselPositionRecord{pos}, run.positions...)
+		lat, lon, radius := driftStats(candidate)
+		if radius > config.MaxDriftMeters {
+			break
+		}
+
+		run = stationaryRun{
+			positions:         candidate,
+			centerLat:         lat,
+			centerLon:         lon,
+			driftRadiusMeters: radius,
+		}
+	}
+
+	return run
+}
+
+// driftStats returns the centroid of the positions and the largest distance
+// from it in meters
+func driftStats(positions []models.VesselPositionRecord) (float64, float64, float64) {
+	var sumLat, sumLon float64
+	for _, pos := range positions {
+		sumLat += pos.Latitude
+		sumLon += pos.Longitude
+	}
+	lat := sumLat / float64(len(positions))
+	lon := sumLon / float64(len(positions))
+
+	radius := 0.0
+	for _, pos := range positions {
+		if d := HaversineDistance(lat, lon, pos.Latitude, pos.Longitude); d > radius {
+			radius = d
+		}
+	}
+
+	return lat, lon, radius
+}
+
+// AnalyzeVessel updates the anchoring state of a single vessel in a park
+func (d *AnchoringDetector) AnalyzeVessel(ctx context.Context, parkID uint, vesselUUID string) (*models.AnchoringEvent, error) {
+	return d.analyzeVessel(ctx, parkID, vesselUUID, time.Now())
+}
+
+// analyzeVessel updates the anchoring state of a vessel from the positions
+// within the lookback window before now
+func (d *AnchoringDetector) analyzeVessel(ctx context.Context, parkID uint, vesselUUID string, now time.Time) (*models.AnchoringEvent, error) {
+	positions, err := d.vesselRepo.GetRecentPositions(ctx, parkID, vesselUUID, now.Add(-d.config.LookbackWindow))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load recent positions: %w", err)
+	}
+
+	var active models.AnchoringEvent
+	err = d.db.WithContext(ctx).Where("park_id = ? AND vessel_uuid = ? AND ended_at IS NULL", parkID, vesselUUID).First(&active).Error
+	hasActive := err == nil
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
+	run := findStationaryRun(positions, d.config)
+
+	if len(run.positions) < d.config.MinPositions {
+		if hasActive {
+			// Vessel has moved on, close the event at the last stationary fix
+			endedAt := active.LastSeenAt
+			active.EndedAt = &endedAt
+			if err := d.db.WithContext(ctx).Save(&active).Error; err != nil {
+				return nil, err
+			}
+			return &active, nil
+		}
+		return nil, nil
+	}
+
+	first := run.positions[0]
+	last := run.positions[len(run.positions)-1]
+
+	event := active
+	if !hasActive {
+		event = models.AnchoringEvent{
+			VesselUUID:      vesselUUID,
+			ParkID:          parkID,
+			StartedAt:       first.RecordedAt,
+			AnchorLatitude:  run.centerLat,
+			AnchorLongitude: run.centerLon,
+		}
+	}
+
+	event.LastSeenAt = last.RecordedAt
+	event.Latitude = run.centerLat
+	event.Longitude = run.centerLon
+	event.DriftRadiusMeters = run.driftRadiusMeters
+	event.DwellMinutes = last.RecordedAt.Sub(event.StartedAt).Minutes()
+	event.PositionCount = len(run.positions)
+	event.MaxCurrentKnots = run.maxCurrent()
+
+	if err := d.db.WithContext(ctx).Save(&event).Error; err != nil {
+		return nil, err
+	}
+
+	return &event, nil
+}
+
+// AnalyzeVessels runs anchoring detection in a park for each of the given
+// vessels, stopping early when ctx is done
+func (d *AnchoringDetector) AnalyzeVessels(ctx context.Context, parkID uint, vesselUUIDs []string) int {
+	return d.analyzeVessels(ctx, parkID, vesselUUIDs, time.Now())
+}
+
+func (d *AnchoringDetector) analyzeVessels(ctx context.Context, parkID uint, vesselUUIDs []string, now time.Time) int {
+	anchored := 0
+	for _, uuid := range vesselUUIDs {
+		if ctx.Err() != nil {
+			break
+		}
+		event, err := d.analyzeVessel(ctx, parkID, uuid, now)
+		if err != nil {
+			d.logger.Error("Anchoring analysis failed", "vessel_uuid", uuid, "error", err)
+			continue
+		}
+		if event != nil && event.EndedAt == nil {
+			anchored++
+		}
+	}
+	return anchored
+}
+
+// GetEvents returns the anchoring events of a park, optionally only those still active or for one vessel
+func (d *AnchoringDetector) GetEvents(ctx context.Context, parkID uint, vesselUUID string, activeOnly bool, limit int) ([]models.AnchoringEvent, error) {
+	var events []models.AnchoringEvent
+
+	query := d.db.WithContext(ctx).Preload("Vessel").Where("park_id = ?", parkID).Order("started_at DESC")
+	if vesselUUID != "" {
+		query = query.Where("vessel_uuid = ?", vesselUUID)
+	}
+	if activeOnly {
+		query = query.Where("ended_at IS NULL")
+	}
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	err := query.Find(&events).Error
+	return events, err
+}
+
+// GetAnchoredVessels returns the vessels anchored in a park now: those with an
+// active anchoring event last confirmed within maxAge, so vessels that went
+// out of range while anchored are left out. The longest anchored come first,
+// each with the uploaded habitat layers in force under its position.
+func (d *AnchoringDetector) GetAnchoredVessels(ctx context.Context, park *Park, maxAge time.Duration) ([]models.AnchoredVessel, error) {
+	now := time.Now()
+
+	var events []models.AnchoringEvent
+	err := d.db.WithContext(ctx).Preload("Vessel").
+		Where("park_id = ? AND ended_at IS NULL AND last_seen_at >= ?", park.Record.ID, now.Add(-maxAge)).
+		Order("started_at ASC").
+		Find(&events).Error
+	if err != nil {
+		return nil, err
+	}
+
+	vessels := make([]models.AnchoredVessel, 0, len(events))
+	for _, event := range events {
+		vessel := models.AnchoredVessel{
+			AnchoringEvent:  event,
+			AnchoredMinutes: now.Sub(event.StartedAt).Minutes(),
+			Habitat:         park.Geo.HabitatLayersAt(event.Latitude, event.Longitude, now),
+		}
+		for _, layer := range vessel.Habitat {
+			if layer.Kind == models.HabitatKindPosidonia {
+				vessel.OnPosidonia = true
+			}
+		}
+		vessels = append(vessels, vessel)
+	}
+	sort.SliceStable(vessels, func(i, j int) bool {
+		return vessels[i].AnchoredMinutes > vessels[j].AnchoredMinutes
+	})
+
+	return vessels, nil
+}
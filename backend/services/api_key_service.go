@@ -0,0 +1,204 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+	"vessel-tracker/database"
+	"vessel-tracker/logging"
+	"vessel-tracker/models"
+
+	"gorm.io/gorm"
+)
+
+// apiKeyPrefix marks issued API keys so they are recognizable in
+// configuration files and secret scanners
+const apiKeyPrefix = "vtk_"
+
+// apiKeyPrefixLength is how much of a key is stored in the clear to tell
+// keys apart
+const apiKeyPrefixLength = 12
+
+// apiKeyReloadInterval bounds how long keys issued or revoked on another
+// instance take to apply here
+const apiKeyReloadInterval = time.Minute
+
+// apiKeyUsageInterval is how often the last use of a key is written, so
+// busy clients do not cost a write per request
+const apiKeyUsageInterval = time.Minute
+
+var (
+	ErrInvalidAPIKey      = errors.New("invalid API key")
+	ErrAPIKeyExpired      = errors.New("API key has expired")
+	ErrUnknownAPIKeyScope = errors.New("unknown API key scope")
+)
+
+// APIKeyService issues and revokes API keys for machine clients. Active keys
+// are kept in memory by hash, so the auth middleware checks them without a
+// database round trip.
+type APIKeyService struct {
+	db     *gorm.DB
+	logger *slog.Logger
+
+	mu       sync.RWMutex
+	keys     map[string]*models.APIKey // key hash -> active key
+	loadedAt time.Time
+}
+
+func NewAPIKeyService() (*APIKeyService, error) {
+	s := &APIKeyService{
+		db:     database.GetDB(),
+		logger: logging.Component("api_keys"),
+		keys:   make(map[string]*models.APIKey),
+	}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload reads the active keys from the database
+func (s *APIKeyService) Reload() error {
+	var active []models.APIKey
+	if err := s.db.Where("revoked_at IS NULL").Find(&active).Error; err != nil {
+		return fmt.Errorf("failed to load API keys: %w", err)
+	}
+
+	keys := make(map[string]*models.APIKey, len(active))
+	for i := range active {
+		keys[active[i].KeyHash] = &active[i]
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.loadedAt = time.Now()
+	s.mu.Unlock()
+	return nil
+}
+
+// GetKeys lists the issued keys, newest first, leaving out revoked ones
+// unless asked for
+func (s *APIKeyService) GetKeys(includeRevoked bool) ([]models.APIKey, error) {
+	query := s.db.Order("created_at DESC")
+	if !includeRevoked {
+		query = query.Where("revoked_at IS NULL")
+	}
+
+	var keys []models.APIKey
+	err := query.Find(&keys).Error
+	return keys, err
+}
+
+// IssueKey creates a key for a machine client with the given role and
+// scopes, and returns it with the key itself, which is not stored and
+// cannot be shown again
+func (s *APIKeyService) IssueKey(name, role string, scopes []string, expiresAt *time.Time, createdBy string) (*models.APIKey, string, error) {
+	if len(scopes) == 0 {
+		return nil, "", fmt.Errorf("%w: at least one scope is required", ErrUnknownAPIKeyScope)
+	}
+	unique := make(models.APIKeyScopes, 0, len(scopes))
+	for _, scope := range scopes {
+		if !models.IsAPIKeyScope(scope) {
+			return nil, "", fmt.Errorf("%w %q, use %s, %s or %s", ErrUnknownAPIKeyScope, scope, models.APIKeyScopeReadOnly, models.APIKeyScopeWhitelistAdmin, models.APIKeyScopeIngest)
+		}
+		if !unique.Has(scope) {
+			unique = append(unique, scope)
+		}
+	}
+
+	random, err := randomToken()
+	if err != nil {
+		return nil, "", err
+	}
+	plain := apiKeyPrefix + random
+
+	key := &models.APIKey{
+		Name:      name,
+		Prefix:    plain[:apiKeyPrefixLength],
+		KeyHash:   hashToken(plain),
+		Role:      role,
+		Scopes:    unique,
+		ExpiresAt: expiresAt,
+		CreatedBy: createdBy,
+	}
+	if err := s.db.Create(key).Error; err != nil {
+		return nil, "", fmt.Errorf("failed to store API key: %w", err)
+	}
+
+	cached := *key
+	s.mu.Lock()
+	s.keys[key.KeyHash] = &cached
+	s.mu.Unlock()
+
+	s.logger.Info("Issued API key", "id", key.ID, "name", name, "prefix", key.Prefix, "role", role, "scopes", []string(unique), "created_by", createdBy)
+	return key, plain, nil
+}
+
+// RevokeKey revokes a key; requests made with it are rejected immediately on
+// this instance and within a minute on the others
+func (s *APIKeyService) RevokeKey(id uint, revokedBy, reason string) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := s.db.First(&key, id).Error; err != nil {
+		return nil, err
+	}
+
+	if key.RevokedAt == nil {
+		now := time.Now()
+		key.RevokedAt = &now
+		key.RevokedBy = revokedBy
+		key.RevokeReason = reason
+
+		if err := s.db.Save(&key).Error; err != nil {
+			return nil, fmt.Errorf("failed to revoke API key: %w", err)
+		}
+		s.logger.Info("Revoked API key", "id", key.ID, "name", key.Name, "prefix", key.Prefix, "revoked_by", revokedBy)
+	}
+
+	s.mu.Lock()
+	delete(s.keys, key.KeyHash)
+	s.mu.Unlock()
+
+	return &key, nil
+}
+
+// ValidateKey returns the active key matching a presented key, recording its
+// use. Unknown and revoked keys are ErrInvalidAPIKey.
+func (s *APIKeyService) ValidateKey(plain string) (*models.APIKey, error) {
+	s.mu.RLock()
+	stale := time.Since(s.loadedAt) > apiKeyReloadInterval
+	s.mu.RUnlock()
+	if stale {
+		if err := s.Reload(); err != nil {
+			s.logger.Error("Failed to reload API keys, using the keys loaded before", "error", err)
+		}
+	}
+
+	hash := hashToken(plain)
+	now := time.Now()
+
+	s.mu.Lock()
+	cached, ok := s.keys[hash]
+	if !ok {
+		s.mu.Unlock()
+		return nil, ErrInvalidAPIKey
+	}
+	if cached.ExpiresAt != nil && now.After(*cached.ExpiresAt) {
+		s.mu.Unlock()
+		return nil, ErrAPIKeyExpired
+	}
+	record := cached.LastUsedAt == nil || now.Sub(*cached.LastUsedAt) > apiKeyUsageInterval
+	if record {
+		cached.LastUsedAt = &now
+	}
+	key := *cached
+	s.mu.Unlock()
+
+	if record {
+		if err := s.db.Model(&models.APIKey{}).Where("id = ?", key.ID).UpdateColumn("last_used_at", now).Error; err != nil {
+			s.logger.Warn("Failed to record API key use", "id", key.ID, "error", err)
+		}
+	}
+	return &key, nil
+}
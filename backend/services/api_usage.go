@@ -0,0 +1,251 @@
+package services
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+	"vessel-tracker/database"
+	"vessel-tracker/logging"
+	"vessel-tracker/models"
+
+	"github.com/robfig/cron/v3"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// APIUsageConfig holds how often API usage counts are written to the
+// database. Counts of the current interval are lost if the process crashes.
+type APIUsageConfig struct {
+	FlushInterval time.Duration
+}
+
+func DefaultAPIUsageConfig() APIUsageConfig {
+	return APIUsageConfig{
+		FlushInterval: time.Minute,
+	}
+}
+
+// LoadAPIUsageConfig reads API_USAGE_FLUSH_INTERVAL, falling back to the
+// default when unset
+func LoadAPIUsageConfig() (APIUsageConfig, error) {
+	config := DefaultAPIUsageConfig()
+
+	if value := os.Getenv("API_USAGE_FLUSH_INTERVAL"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d < time.Second {
+			return config, fmt.Errorf("invalid API_USAGE_FLUSH_INTERVAL %q: must be a duration of at least 1s", value)
+		}
+		config.FlushInterval = d
+	}
+
+	return config, nil
+}
+
+// APIUsageFilter narrows an API usage query; empty fields match everything
+type APIUsageFilter struct {
+	APIKey string
+	Actor  string
+	Route  string
+	Since  time.Time // last used at or after
+}
+
+// APIKeyUsage sums the usage of one API key over all endpoints
+type APIKeyUsage struct {
+	APIKey      string    `json:"api_key"`
+	Actor       string    `json:"actor"`
+	Role        string    `json:"role"`
+	Requests    int64     `json:"requests"`
+	Errors      int64     `json:"errors"`
+	Endpoints   int       `json:"endpoints"`
+	FirstUsedAt time.Time `json:"first_used_at"`
+	LastUsedAt  time.Time `json:"last_used_at"`
+}
+
+type apiUsageKey struct {
+	apiKey, method, route string
+}
+
+// APIUsageTracker counts requests per API key and endpoint. Requests are
+// counted in memory and added to the stored counts every flush interval and
+// on Stop, so tracking costs no database write per request.
+type APIUsageTracker struct {
+	db     *gorm.DB
+	config APIUsageConfig
+	cron   *cron.Cron
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	pending map[apiUsageKey]*models.APIUsage
+
+	flushMu sync.Mutex
+}
+
+func NewAPIUsageTracker(config APIUsageConfig) *APIUsageTracker {
+	return &APIUsageTracker{
+		db:      database.GetDB(),
+		config:  config,
+		cron:    cron.New(),
+		logger:  logging.Component("api_usage"),
+		pending: make(map[apiUsageKey]*models.APIUsage),
+	}
+}
+
+func (t *APIUsageTracker) Start() error {
+	if _, err := t.cron.AddFunc(fmt.Sprintf("@every %s", t.config.FlushInterval), t.flushLogged); err != nil {
+		return err
+	}
+	t.cron.Start()
+	return nil
+}
+
+// Stop ends the periodic flushes and writes the counts not yet stored
+func (t *APIUsageTracker) Stop() {
+	<-t.cron.Stop().Done()
+	t.flushLogged()
+}
+
+// Record counts a request of an API key to a route
+func (t *APIUsageTracker) Record(apiKey, actor, role, method, route string, status int, at time.Time) {
+	key := apiUsageKey{apiKey: apiKey, method: method, route: route}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	usage, ok := t.pending[key]
+	if !ok {
+		usage = &models.APIUsage{APIKey: apiKey, Method: method, Route: route, FirstUsedAt: at}
+		t.pending[key] = usage
+	}
+	usage.Actor = actor
+	usage.Role = role
+	usage.Requests++
+	if status >= 400 {
+		usage.Errors++
+	}
+	usage.LastUsedAt = at
+}
+
+func (t *APIUsageTracker) flushLogged() {
+	if err := t.Flush(); err != nil {
+		t.logger.Error("Failed to store API usage", "error", err)
+	}
+}
+
+// Flush adds the counts recorded since the last flush to the stored ones.
+// Counts that fail to store are kept for the next flush.
+func (t *APIUsageTracker) Flush() error {
+	t.flushMu.Lock()
+	defer t.flushMu.Unlock()
+
+	t.mu.Lock()
+	pending := t.pending
+	t.pending = make(map[apiUsageKey]*models.APIUsage)
+	t.mu.Unlock()
+
+	for key, usage := range pending {
+		if err := t.store(usage); err != nil {
+			for key, usage := range pending {
+				t.restore(key, usage)
+			}
+			return err
+		}
+		delete(pending, key)
+	}
+	return nil
+}
+
+// store adds the counts of one API key and endpoint to the stored ones
+func (t *APIUsageTracker) store(usage *models.APIUsage) error {
+	err := t.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "api_key"}, {Name: "method"}, {Name: "route"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"requests":     gorm.Expr("api_usages.requests + ?", usage.Requests),
+			"errors":       gorm.Expr("api_usages.errors + ?", usage.Errors),
+			"actor":        usage.Actor,
+			"role":         usage.Role,
+			"last_used_at": usage.LastUsedAt,
+		}),
+	}).Create(usage).Error
+	if err != nil {
+		return fmt.Errorf("failed to store usage of %s %s by %s: %w", usage.Method, usage.Route, usage.APIKey, err)
+	}
+	return nil
+}
+
+// restore puts counts that could not be stored back into the pending ones
+func (t *APIUsageTracker) restore(key apiUsageKey, usage *models.APIUsage) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if newer, ok := t.pending[key]; ok {
+		usage.Requests += newer.Requests
+		usage.Errors += newer.Errors
+		usage.Actor = newer.Actor
+		usage.Role = newer.Role
+		usage.LastUsedAt = newer.LastUsedAt
+	}
+	t.pending[key] = usage
+}
+
+// GetUsage returns the stored usage per API key and endpoint matching the
+// filter, most recently used first. Pending counts are flushed first.
+func (t *APIUsageTracker) GetUsage(filter APIUsageFilter) ([]models.APIUsage, error) {
+	if err := t.Flush(); err != nil {
+		return nil, err
+	}
+
+	query := t.db.Order("last_used_at DESC")
+	if filter.APIKey != "" {
+		query = query.Where("api_key = ?", filter.APIKey)
+	}
+	if filter.Actor != "" {
+		query = query.Where("actor = ?", filter.Actor)
+	}
+	if filter.Route != "" {
+		query = query.Where("route = ?", filter.Route)
+	}
+	if !filter.Since.IsZero() {
+		query = query.Where("last_used_at >= ?", filter.Since)
+	}
+
+	var usage []models.APIUsage
+	err := query.Find(&usage).Error
+	return usage, err
+}
+
+// SummarizeKeys sums usage rows per API key, most recently used first
+func SummarizeKeys(usage []models.APIUsage) []APIKeyUsage {
+	byKey := make(map[string]*APIKeyUsage)
+	keys := []APIKeyUsage{}
+	order := []string{}
+
+	for _, row := range usage {
+		summary, ok := byKey[row.APIKey]
+		if !ok {
+			// Rows come most recently used first, so the first row of a key
+			// has its latest actor and role
+			summary = &APIKeyUsage{
+				APIKey:      row.APIKey,
+				Actor:       row.Actor,
+				Role:        row.Role,
+				FirstUsedAt: row.FirstUsedAt,
+				LastUsedAt:  row.LastUsedAt,
+			}
+			byKey[row.APIKey] = summary
+			order = append(order, row.APIKey)
+		}
+		summary.Requests += row.Requests
+		summary.Errors += row.Errors
+		summary.Endpoints++
+		if row.FirstUsedAt.Before(summary.FirstUsedAt) {
+			summary.FirstUsedAt = row.FirstUsedAt
+		}
+	}
+
+	for _, key := range order {
+		keys = append(keys, *byKey[key])
+	}
+	return keys
+}
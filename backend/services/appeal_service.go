@@ -0,0 +1,122 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"vessel-tracker/database"
+	"vessel-tracker/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidTransition is returned when a state change is not allowed from the current state
+var ErrInvalidTransition = errors.New("invalid state transition")
+
+// Allowed appeal state transitions
+var appealTransitions = map[string][]string{
+	models.AppealStatusFiled:       {models.AppealStatusUnderReview, models.AppealStatusWithdrawn},
+	models.AppealStatusUnderReview: {models.AppealStatusDecided, models.AppealStatusWithdrawn},
+}
+
+var appealOutcomes = map[string]bool{
+	models.AppealOutcomeUpheld:     true,
+	models.AppealOutcomeOverturned: true,
+	models.AppealOutcomeReduced:    true,
+}
+
+type AppealService struct {
+	db *gorm.DB
+}
+
+func NewAppealService() *AppealService {
+	return &AppealService{
+		db: database.GetDB(),
+	}
+}
+
+// FileAppeal lodges a new appeal against a violation
+func (s *AppealService) FileAppeal(appeal *models.ViolationAppeal) error {
+	var violation models.Violation
+	if err := s.db.First(&violation, appeal.ViolationID).Error; err != nil {
+		return err
+	}
+
+	appeal.Status = models.AppealStatusFiled
+	if appeal.FiledAt.IsZero() {
+		appeal.FiledAt = time.Now()
+	}
+
+	if err := s.db.Create(appeal).Error; err != nil {
+		return fmt.Errorf("failed to file appeal: %w", err)
+	}
+	return nil
+}
+
+// GetAppeals returns all appeals for a violation, oldest first
+func (s *AppealService) GetAppeals(violationID uint) ([]models.ViolationAppeal, error) {
+	var appeals []models.ViolationAppeal
+	err := s.db.Where("violation_id = ?", violationID).Order("filed_at ASC").Find(&appeals).Error
+	return appeals, err
+}
+
+// TransitionAppeal moves an appeal to a new state. Decision and outcome are
+// required when the appeal is decided.
+func (s *AppealService) TransitionAppeal(violationID, appealID uint, status, decision, outcome, actor string) (*models.ViolationAppeal, error) {
+	var appeal models.ViolationAppeal
+	if err := s.db.Where("id = ? AND violation_id = ?", appealID, violationID).First(&appeal).Error; err != nil {
+		return nil, err
+	}
+
+	allowed := false
+	for _, next := range appealTransitions[appeal.Status] {
+		if next == status {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return nil, fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, appeal.Status, status)
+	}
+
+	if status == models.AppealStatusDecided {
+		if !appealOutcomes[outcome] {
+			return nil, fmt.Errorf("%w: outcome must be one of upheld, overturned, reduced", ErrInvalidTransition)
+		}
+		now := time.Now()
+		appeal.Decision = decision
+		appeal.Outcome = outcome
+		appeal.DecidedAt = &now
+		appeal.DecidedBy = actor
+	}
+
+	appeal.Status = status
+	if err := s.db.Save(&appeal).Error; err != nil {
+		return nil, fmt.Errorf("failed to update appeal: %w", err)
+	}
+
+	return &appeal, nil
+}
+
+// GetOutcomeStats aggregates appeal outcomes per violation type
+func (s *AppealService) GetOutcomeStats() ([]models.AppealOutcomeStats, error) {
+	var stats []models.AppealOutcomeStats
+
+	err := s.db.Table("violation_appeals").
+		Select(`violations.type AS violation_type,
+			COUNT(*) AS total,
+			COUNT(CASE WHEN violation_appeals.status IN (?, ?) THEN 1 END) AS pending,
+			COUNT(CASE WHEN violation_appeals.outcome = ? THEN 1 END) AS upheld,
+			COUNT(CASE WHEN violation_appeals.outcome = ? THEN 1 END) AS overturned,
+			COUNT(CASE WHEN violation_appeals.outcome = ? THEN 1 END) AS reduced,
+			COUNT(CASE WHEN violation_appeals.status = ? THEN 1 END) AS withdrawn`,
+			models.AppealStatusFiled, models.AppealStatusUnderReview,
+			models.AppealOutcomeUpheld, models.AppealOutcomeOverturned, models.AppealOutcomeReduced,
+			models.AppealStatusWithdrawn).
+		Joins("JOIN violations ON violations.id = violation_appeals.violation_id").
+		Group("violations.type").
+		Order("violations.type").
+		Scan(&stats).Error
+
+	return stats, err
+}
@@ -0,0 +1,281 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Archiver stores an archive file produced by the retention job under a
+// slash separated key and returns where it was stored, giving up when ctx is
+// done
+type Archiver interface {
+	Put(ctx context.Context, key string, file *os.File) (string, error)
+}
+
+// S3Credentials are the AWS_* credentials shared by every S3 archive target
+type S3Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string `secret:"true"`
+	SessionToken    string `secret:"true"`
+}
+
+// LoadS3Credentials reads AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// AWS_SESSION_TOKEN
+func LoadS3Credentials() S3Credentials {
+	return S3Credentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+}
+
+// ArchiveConfig is the deployment's storage for retention archives. With
+// neither a directory nor a bucket, expired rows are deleted without being
+// archived.
+type ArchiveConfig struct {
+	Target           ArchiveTarget
+	Credentials      S3Credentials
+	DeploymentRegion string // region of the local directory
+}
+
+// LoadArchiveConfig reads ARCHIVE_DIR for archives on the local filesystem or
+// ARCHIVE_S3_BUCKET (with ARCHIVE_S3_REGION, ARCHIVE_S3_ENDPOINT,
+// ARCHIVE_S3_PREFIX and the AWS_* credentials) for an S3 compatible bucket.
+// A local directory is in the deployment's region.
+func LoadArchiveConfig(deploymentRegion string) (ArchiveConfig, error) {
+	config := ArchiveConfig{
+		DeploymentRegion: deploymentRegion,
+		Target: ArchiveTarget{
+			Dir:        os.Getenv("ARCHIVE_DIR"),
+			S3Bucket:   os.Getenv("ARCHIVE_S3_BUCKET"),
+			S3Region:   os.Getenv("ARCHIVE_S3_REGION"),
+			S3Endpoint: os.Getenv("ARCHIVE_S3_ENDPOINT"),
+			S3Prefix:   os.Getenv("ARCHIVE_S3_PREFIX"),
+		},
+		Credentials: LoadS3Credentials(),
+	}
+
+	if config.Target.Dir != "" && config.Target.S3Bucket != "" {
+		return config, fmt.Errorf("ARCHIVE_DIR and ARCHIVE_S3_BUCKET are mutually exclusive")
+	}
+	if config.Target.S3Bucket != "" && (config.Credentials.AccessKeyID == "" || config.Credentials.SecretAccessKey == "") {
+		return config, fmt.Errorf("ARCHIVE_S3_BUCKET requires AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	return config, nil
+}
+
+// NewArchiver builds the deployment's archiver, or returns nil when archiving
+// is not configured
+func NewArchiver(config ArchiveConfig) (Archiver, error) {
+	if config.Target.Dir == "" && config.Target.S3Bucket == "" {
+		return nil, nil
+	}
+	return config.Target.archiver(config.Credentials, config.DeploymentRegion)
+}
+
+// newS3Archiver configures an upload to the bucket with the credentials,
+// defaulting to AWS in us-east-1
+func newS3Archiver(bucket, region, endpoint, prefix string, credentials S3Credentials) (*S3Archiver, error) {
+	archiver := &S3Archiver{
+		Bucket:       bucket,
+		Region:       region,
+		Endpoint:     endpoint,
+		Prefix:       strings.Trim(prefix, "/"),
+		AccessKey:    credentials.AccessKeyID,
+		SecretKey:    credentials.SecretAccessKey,
+		SessionToken: credentials.SessionToken,
+		client:       &http.Client{Timeout: 5 * time.Minute},
+	}
+	if archiver.Region == "" {
+		archiver.Region = "us-east-1"
+	}
+	if archiver.Endpoint == "" {
+		archiver.Endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", archiver.Region)
+	}
+	if archiver.AccessKey == "" || archiver.SecretKey == "" {
+		return nil, fmt.Errorf("S3 archives require AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	return archiver, nil
+}
+
+// archiverRegion returns the region an archiver stores in: the bucket's region
+// for S3, the deployment's region for a local directory
+func archiverRegion(archiver Archiver) string {
+	switch archiver := archiver.(type) {
+	case *S3Archiver:
+		return archiver.Region
+	case *LocalArchiver:
+		return archiver.Region
+	}
+	return ""
+}
+
+// LocalArchiver copies archives into a directory
+type LocalArchiver struct {
+	Dir    string
+	Region string // the deployment's region, where the directory is
+}
+
+func (a *LocalArchiver) Put(ctx context.Context, key string, file *os.File) (string, error) {
+	path := filepath.Join(a.Dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create archive directory: %w", err)
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	// Write under a temporary name so a partial file is never mistaken for an archive
+	out, err := os.CreateTemp(filepath.Dir(path), ".archive-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create archive file: %w", err)
+	}
+	defer os.Remove(out.Name())
+
+	if _, err := io.Copy(out, file); err != nil {
+		out.Close()
+		return "", fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		return "", fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := os.Rename(out.Name(), path); err != nil {
+		return "", fmt.Errorf("failed to write archive: %w", err)
+	}
+
+	return path, nil
+}
+
+// S3Archiver uploads archives to an S3 compatible bucket with path-style
+// requests signed with AWS Signature Version 4
+type S3Archiver struct {
+	Bucket       string
+	Region       string
+	Endpoint     string
+	Prefix       string
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+
+	client *http.Client
+}
+
+func (a *S3Archiver) Put(ctx context.Context, key string, file *os.File) (string, error) {
+	if a.Prefix != "" {
+		key = a.Prefix + "/" + key
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash archive: %w", err)
+	}
+	payloadHash := hex.EncodeToString(hash.Sum(nil))
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	endpoint, err := url.Parse(strings.TrimRight(a.Endpoint, "/"))
+	if err != nil {
+		return "", fmt.Errorf("invalid ARCHIVE_S3_ENDPOINT: %w", err)
+	}
+
+	segments := strings.Split(a.Bucket+"/"+key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	canonicalURI := endpoint.EscapedPath() + "/" + strings.Join(segments, "/")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint.Scheme+"://"+endpoint.Host+canonicalURI, io.NopCloser(file))
+	if err != nil {
+		return "", err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/gzip")
+	a.sign(req, canonicalURI, payloadHash, time.Now().UTC())
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload archive: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("archive upload returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	return fmt.Sprintf("s3://%s/%s", a.Bucket, key), nil
+}
+
+// sign adds the SigV4 headers for a request without a query string
+func (a *S3Archiver) sign(req *http.Request, canonicalURI, payloadHash string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if a.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", a.SessionToken)
+	}
+
+	headers := []string{"content-type", "host", "x-amz-content-sha256", "x-amz-date"}
+	values := map[string]string{
+		"content-type":         req.Header.Get("Content-Type"),
+		"host":                 req.URL.Host,
+		"x-amz-content-sha256": payloadHash,
+		"x-amz-date":           amzDate,
+	}
+	if a.SessionToken != "" {
+		headers = append(headers, "x-amz-security-token")
+		values["x-amz-security-token"] = a.SessionToken
+	}
+
+	var canonicalHeaders strings.Builder
+	for _, name := range headers {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(values[name]) + "\n")
+	}
+	signedHeaders := strings.Join(headers, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI,
+		"",
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + a.Region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+a.SecretKey), date)
+	key = hmacSHA256(key, a.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		a.AccessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,240 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+	"vessel-tracker/database"
+	"vessel-tracker/logging"
+	"vessel-tracker/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ArrivalService records the first time each vessel is seen inside a park, so
+// newcomers who may not know the park rules can be approached early
+type ArrivalService struct {
+	db     *gorm.DB
+	logger *slog.Logger
+}
+
+func NewArrivalService() *ArrivalService {
+	return &ArrivalService{
+		db:     database.GetDB(),
+		logger: logging.Component("arrivals"),
+	}
+}
+
+// SeedArrivals reconstructs the arrivals of parks that have none yet from
+// their earliest stored in-park positions, so vessels that visited before
+// arrivals were tracked are not announced as newcomers. Seeding a park with
+// no position history does nothing.
+func (s *ArrivalService) SeedArrivals(parks []*Park) error {
+	for _, park := range parks {
+		var count int64
+		if err := s.db.Model(&models.ParkArrival{}).Where("park_id = ?", park.Record.ID).Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to count arrivals of %s: %w", park.Record.Slug, err)
+		}
+		if count > 0 {
+			continue
+		}
+
+		var first []models.VesselPositionRecord
+		subQuery := s.db.Model(&models.VesselPositionRecord{}).
+			Select("vessel_uuid, MIN(recorded_at) as min_recorded_at").
+			Where("park_id = ? AND is_in_park = ?", park.Record.ID, true).
+			Group("vessel_uuid")
+		err := s.db.Preload("Vessel").
+			Joins("JOIN (?) as first ON vessel_position_records.vessel_uuid = first.vessel_uuid AND vessel_position_records.recorded_at = first.min_recorded_at", subQuery).
+			Where("vessel_position_records.park_id = ?", park.Record.ID).
+			Find(&first).Error
+		if err != nil {
+			return fmt.Errorf("failed to load first positions in %s: %w", park.Record.Slug, err)
+		}
+		if len(first) == 0 {
+			continue
+		}
+
+		arrivals := make([]models.ParkArrival, 0, len(first))
+		for _, pos := range first {
+			arrivals = append(arrivals, models.ParkArrival{
+				ParkID:      park.Record.ID,
+				VesselUUID:  pos.VesselUUID,
+				MMSI:        pos.Vessel.MMSI,
+				Latitude:    pos.Latitude,
+				Longitude:   pos.Longitude,
+				Speed:       pos.Speed,
+				FirstSeenAt: pos.RecordedAt,
+				Seeded:      true,
+			})
+		}
+
+		err = s.db.Omit(clause.Associations).
+			Clauses(clause.OnConflict{DoNothing: true}).
+			CreateInBatches(&arrivals, 500).Error
+		if err != nil {
+			return fmt.Errorf("failed to seed arrivals of %s: %w", park.Record.Slug, err)
+		}
+		s.logger.Info("Seeded park arrivals from position history", "park", park.Record.Slug, "vessels", len(arrivals))
+	}
+
+	return nil
+}
+
+// RecordArrivals records the vessels inside the park that have never been
+// seen there, by UUID or MMSI, and returns how many there were. It runs
+// after the positions are stored, so the vessels exist.
+func (s *ArrivalService) RecordArrivals(park *Park, positions []models.VesselPosition, zones []PositionZones) (int, error) {
+	return s.recordArrivals(park, positions, zones, time.Now())
+}
+
+// recordArrivals records first arrivals as seen at the given time
+func (s *ArrivalService) recordArrivals(park *Park, positions []models.VesselPosition, zones []PositionZones, seenAt time.Time) (int, error) {
+	candidates := make(map[string]models.VesselPosition)
+	var uuids, mmsis []string
+	for i, pos := range positions {
+		if !zones[i].InPark {
+			continue
+		}
+		if _, ok := candidates[pos.UUID]; ok {
+			continue
+		}
+		candidates[pos.UUID] = pos
+		uuids = append(uuids, pos.UUID)
+		if pos.MMSI != "" {
+			mmsis = append(mmsis, pos.MMSI)
+		}
+	}
+	if len(candidates) == 0 {
+		return 0, nil
+	}
+
+	var known []models.ParkArrival
+	query := s.db.Select("vessel_uuid", "mmsi").Where("park_id = ?", park.Record.ID)
+	if len(mmsis) > 0 {
+		query = query.Where(s.db.Where("vessel_uuid IN ?", uuids).Or("mmsi IN ?", mmsis))
+	} else {
+		query = query.Where("vessel_uuid IN ?", uuids)
+	}
+	if err := query.Find(&known).Error; err != nil {
+		return 0, fmt.Errorf("failed to load known arrivals: %w", err)
+	}
+
+	knownUUIDs := make(map[string]bool, len(known))
+	knownMMSIs := make(map[string]bool, len(known))
+	for _, arrival := range known {
+		knownUUIDs[arrival.VesselUUID] = true
+		if arrival.MMSI != "" {
+			knownMMSIs[arrival.MMSI] = true
+		}
+	}
+
+	var arrivals []models.ParkArrival
+	for _, uuid := range uuids {
+		pos := candidates[uuid]
+		if knownUUIDs[uuid] || (pos.MMSI != "" && knownMMSIs[pos.MMSI]) {
+			continue
+		}
+		arrivals = append(arrivals, models.ParkArrival{
+			ParkID:      park.Record.ID,
+			VesselUUID:  pos.UUID,
+			MMSI:        pos.MMSI,
+			Latitude:    pos.Latitude,
+			Longitude:   pos.Longitude,
+			Speed:       pos.Speed,
+			FirstSeenAt: seenAt,
+		})
+	}
+	if len(arrivals) == 0 {
+		return 0, nil
+	}
+
+	err := s.db.Omit(clause.Associations).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&arrivals).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to record arrivals: %w", err)
+	}
+
+	for _, arrival := range arrivals {
+		pos := candidates[arrival.VesselUUID]
+		s.logger.Info("Vessel seen in park for the first time", "park", park.Record.Slug,
+			"vessel_uuid", pos.UUID, "name", pos.Name, "mmsi", pos.MMSI, "type", pos.Type)
+	}
+
+	return len(arrivals), nil
+}
+
+// GetArrivals lists the latest first arrivals in a park since the given time,
+// leaving out the seeded ones
+func (s *ArrivalService) GetArrivals(ctx context.Context, parkID uint, since time.Time, limit int) ([]models.ParkArrival, error) {
+	var arrivals []models.ParkArrival
+	err := s.db.WithContext(ctx).Preload("Vessel").
+		Where("park_id = ? AND first_seen_at >= ? AND seeded = ?", parkID, since, false).
+		Order("first_seen_at DESC, id DESC").
+		Limit(limit).
+		Find(&arrivals).Error
+	return arrivals, err
+}
+
+// Digest groups the first arrivals in a park between start and end by UTC
+// day, oldest day first. Days without arrivals are listed with a count of 0.
+func (s *ArrivalService) Digest(park *Park, start, end time.Time) (*models.ArrivalDigest, error) {
+	var arrivals []models.ParkArrival
+	err := s.db.Preload("Vessel").
+		Where("park_id = ? AND first_seen_at >= ? AND first_seen_at < ? AND seeded = ?", park.Record.ID, start, end, false).
+		Order("first_seen_at ASC, id ASC").
+		Find(&arrivals).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load arrivals: %w", err)
+	}
+
+	digest := &models.ArrivalDigest{
+		Park:  park.Record,
+		Start: start,
+		End:   end,
+		Total: len(arrivals),
+		Days:  []models.ArrivalDigestDay{},
+	}
+
+	index := make(map[string]int)
+	for day := start.UTC().Truncate(24 * time.Hour); day.Before(end); day = day.Add(24 * time.Hour) {
+		date := day.Format("2006-01-02")
+		index[date] = len(digest.Days)
+		digest.Days = append(digest.Days, models.ArrivalDigestDay{Date: date, Arrivals: []models.ParkArrival{}})
+	}
+	for _, arrival := range arrivals {
+		day := &digest.Days[index[arrival.FirstSeenAt.UTC().Format("2006-01-02")]]
+		day.Arrivals = append(day.Arrivals, arrival)
+		day.Count++
+	}
+
+	return digest, nil
+}
+
+// LogDailyDigest logs the previous UTC day's new arrivals of every park
+func (s *ArrivalService) LogDailyDigest(parks []*Park) {
+	end := time.Now().UTC().Truncate(24 * time.Hour)
+	start := end.Add(-24 * time.Hour)
+
+	for _, park := range parks {
+		digest, err := s.Digest(park, start, end)
+		if err != nil {
+			s.logger.Error("Failed to build arrivals digest", "park", park.Record.Slug, "error", err)
+			continue
+		}
+
+		names := make([]string, 0, digest.Total)
+		for _, arrival := range digest.Days[0].Arrivals {
+			name := arrival.Vessel.Name
+			if name == "" {
+				name = arrival.VesselUUID
+			}
+			names = append(names, name)
+		}
+		s.logger.Info("Daily new arrivals digest", "park", park.Record.Slug, "date", start.Format("2006-01-02"),
+			"count", digest.Total, "vessels", names)
+	}
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"fmt"
+	"time"
+	"vessel-tracker/database"
+	"vessel-tracker/models"
+
+	"gorm.io/gorm"
+)
+
+// AccessLogFilter narrows an access log query; empty fields match everything
+type AccessLogFilter struct {
+	Actor      string
+	RecordType string
+	RecordID   string
+	Since      time.Time
+	Limit      int
+}
+
+// SecurityEventFilter narrows a security event query
+type SecurityEventFilter struct {
+	Type       string
+	ClientIP   string
+	AlertsOnly bool
+	Since      time.Time
+	Limit      int
+}
+
+type AuditService struct {
+	db *gorm.DB
+}
+
+func NewAuditService() *AuditService {
+	return &AuditService{
+		db: database.GetDB(),
+	}
+}
+
+// LogAccess stores a sensitive record access
+func (s *AuditService) LogAccess(entry *models.AccessLog) error {
+	if entry.AccessedAt.IsZero() {
+		entry.AccessedAt = time.Now()
+	}
+
+	if err := s.db.Create(entry).Error; err != nil {
+		return fmt.Errorf("failed to write access log: %w", err)
+	}
+	return nil
+}
+
+// GetAccessLogs returns access log entries matching the filter, most recent first
+func (s *AuditService) GetAccessLogs(filter AccessLogFilter) ([]models.AccessLog, error) {
+	var logs []models.AccessLog
+
+	query := s.db.Order("accessed_at DESC")
+	if filter.Actor != "" {
+		query = query.Where("actor = ?", filter.Actor)
+	}
+	if filter.RecordType != "" {
+		query = query.Where("record_type = ?", filter.RecordType)
+	}
+	if filter.RecordID != "" {
+		query = query.Where("record_id = ?", filter.RecordID)
+	}
+	if !filter.Since.IsZero() {
+		query = query.Where("accessed_at >= ?", filter.Since)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	err := query.Find(&logs).Error
+	return logs, err
+}
+
+// RecordSecurityEvent stores an authentication failure or anomaly
+func (s *AuditService) RecordSecurityEvent(event *models.SecurityEvent) error {
+	if err := s.db.Create(event).Error; err != nil {
+		return fmt.Errorf("failed to write security event: %w", err)
+	}
+	return nil
+}
+
+// GetSecurityEvents returns security events matching the filter, most recent first
+func (s *AuditService) GetSecurityEvents(filter SecurityEventFilter) ([]models.SecurityEvent, error) {
+	var events []models.SecurityEvent
+
+	query := s.db.Order("created_at DESC")
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if filter.ClientIP != "" {
+		query = query.Where("client_ip = ?", filter.ClientIP)
+	}
+	if filter.AlertsOnly {
+		query = query.Where("alert = ?", true)
+	}
+	if !filter.Since.IsZero() {
+		query = query.Where("created_at >= ?", filter.Since)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	err := query.Find(&events).Error
+	return events, err
+}
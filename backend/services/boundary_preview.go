@@ -0,0 +1,316 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"time"
+	"vessel-tracker/logging"
+	"vessel-tracker/models"
+
+	geojson "github.com/paulmach/go.geojson"
+)
+
+// maxBoundaryPreviewPositions bounds how many stored positions a preview
+// classifies against both layers; the most recent are checked
+const maxBoundaryPreviewPositions = 200000
+
+// boundaryPreviewSamples is the number of sample points along the longer side
+// of the layers' bounding box used to estimate added and removed area
+const boundaryPreviewSamples = 400
+
+// BoundaryPreviewService compares an uploaded boundary layer with the active
+// one before it is imported, so administrators can see what it would change
+type BoundaryPreviewService struct {
+	vesselRepo *VesselRepository
+	logger     *slog.Logger
+}
+
+func NewBoundaryPreviewService(vesselRepo *VesselRepository) *BoundaryPreviewService {
+	return &BoundaryPreviewService{
+		vesselRepo: vesselRepo,
+		logger:     logging.Component("boundary_preview"),
+	}
+}
+
+// Preview diffs a revision of a park's park or buffer layer against the
+// active layer: the area each covers, and the positions stored between start
+// and end whose classification would change, with the vessels affected, most
+// reclassified first and up to limit. Park positions are classified with the
+// near-boundary tolerance, as they are when violations are detected.
+func (s *BoundaryPreviewService) Preview(ctx context.Context, park *Park, layer string, revision *geojson.FeatureCollection, start, end time.Time, limit int) (*models.BoundaryPreview, error) {
+	active := park.Geo
+	candidate, err := active.withLayer(layer, revision)
+	if err != nil {
+		return nil, err
+	}
+
+	activeLayer := active.layer(layer)
+	preview := &models.BoundaryPreview{
+		Park:             park.Record.Slug,
+		Layer:            layer,
+		RevisionFeatures: len(revision.Features),
+		Area:             compareLayerAreas(activeLayer, revision),
+		Start:            start,
+		End:              end,
+		Vessels:          []models.BoundaryVesselChange{},
+	}
+	if activeLayer != nil {
+		preview.ActiveFeatures = len(activeLayer.Features)
+	}
+
+	classify := func(geo *GeoService, lat, lon float64) bool {
+		if layer == LayerPark {
+			return geo.IsPointInPark(lat, lon)
+		}
+		return geo.IsPointInBufferZone(lat, lon)
+	}
+
+	positions, err := s.vesselRepo.GetPositionsBetween(ctx, park.Record.ID, start, end, maxBoundaryPreviewPositions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch positions: %w", err)
+	}
+	preview.PositionsChecked = len(positions)
+	preview.Truncated = len(positions) == maxBoundaryPreviewPositions
+
+	checked := make(map[string]int)
+	changed := make(map[string]*models.BoundaryVesselChange)
+	for _, pos := range positions {
+		checked[pos.VesselUUID]++
+
+		before := classify(active, pos.Latitude, pos.Longitude)
+		after := classify(candidate, pos.Latitude, pos.Longitude)
+		if before == after {
+			continue
+		}
+
+		vessel := changed[pos.VesselUUID]
+		if vessel == nil {
+			// Positions are most recent first, so the first seen is the latest
+			vessel = &models.BoundaryVesselChange{
+				VesselUUID:       pos.VesselUUID,
+				LastChangedAt:    pos.RecordedAt,
+				ChangedLatitude:  pos.Latitude,
+				ChangedLongitude: pos.Longitude,
+			}
+			changed[pos.VesselUUID] = vessel
+		}
+		vessel.FirstChangedAt = pos.RecordedAt
+		if after {
+			vessel.Entered++
+			preview.Entered++
+		} else {
+			vessel.Left++
+			preview.Left++
+		}
+		preview.PositionsChanged++
+	}
+	preview.VesselsChecked = len(checked)
+	preview.VesselsChanged = len(changed)
+
+	vessels := make([]models.BoundaryVesselChange, 0, len(changed))
+	for uuid, vessel := range changed {
+		vessel.Positions = checked[uuid]
+		vessels = append(vessels, *vessel)
+	}
+	sort.Slice(vessels, func(i, j int) bool {
+		a, b := vessels[i].Entered+vessels[i].Left, vessels[j].Entered+vessels[j].Left
+		if a != b {
+			return a > b
+		}
+		return vessels[i].VesselUUID < vessels[j].VesselUUID
+	})
+	if len(vessels) > limit {
+		vessels = vessels[:limit]
+	}
+
+	for i := range vessels {
+		record, err := s.vesselRepo.GetVessel(ctx, vessels[i].VesselUUID)
+		if err != nil {
+			s.logger.Warn("Failed to look up vessel for boundary preview", "vessel_uuid", vessels[i].VesselUUID, "error", err)
+			continue
+		}
+		if record != nil {
+			vessels[i].Name = record.Name
+			vessels[i].MMSI = record.MMSI
+		}
+	}
+	preview.Vessels = vessels
+
+	s.logger.Info("Previewed boundary revision",
+		"park", park.Record.Slug,
+		"layer", layer,
+		"positions_checked", preview.PositionsChecked,
+		"positions_changed", preview.PositionsChanged,
+		"vessels_changed", preview.VesselsChanged)
+	return preview, nil
+}
+
+// layer returns the active boundaries of a layer, nil while it is not loaded
+func (s *GeoService) layer(layer string) *geojson.FeatureCollection {
+	if layer == LayerPark {
+		return s.park()
+	}
+	return s.buffer()
+}
+
+// withLayer returns a copy of the service with one layer replaced in memory
+// only, for classifying positions against a revision before it is imported
+func (s *GeoService) withLayer(layer string, fc *geojson.FeatureCollection) (*GeoService, error) {
+	if layer != LayerPark && layer != LayerBuffer {
+		return nil, fmt.Errorf("unknown boundary layer %q", layer)
+	}
+
+	s.mu.RLock()
+	candidate := &GeoService{
+		parkBoundaries:      s.parkBoundaries,
+		bufferedBoundaries:  s.bufferedBoundaries,
+		defaultBufferMeters: s.defaultBufferMeters,
+		gridCellDegrees:     s.gridCellDegrees,
+		parkGrid:            s.parkGrid,
+		bufferGrid:          s.bufferGrid,
+		logger:              s.logger.With("revision", true),
+	}
+	s.mu.RUnlock()
+
+	grid := candidate.buildGrid(layer, fc)
+	if layer == LayerPark {
+		candidate.parkBoundaries = fc
+		candidate.parkGrid = grid
+	} else {
+		candidate.bufferedBoundaries = fc
+		candidate.bufferGrid = grid
+	}
+	return candidate, nil
+}
+
+// compareLayerAreas measures both layers and estimates the area only one of
+// them covers by sampling their combined bounding box. Containment follows
+// isPointInFeature, without the near-boundary tolerance.
+func compareLayerAreas(active, revision *geojson.FeatureCollection) models.BoundaryAreaChange {
+	change := models.BoundaryAreaChange{
+		ActiveKm2:   layerAreaKm2(active),
+		RevisionKm2: layerAreaKm2(revision),
+	}
+	change.ChangeKm2 = change.RevisionKm2 - change.ActiveKm2
+	if change.ActiveKm2 > 0 {
+		change.ChangePct = change.ChangeKm2 / change.ActiveKm2 * 100
+	}
+
+	activeZones := layerZoneRings(active)
+	revisionZones := layerZoneRings(revision)
+	minLat, minLon := math.MaxFloat64, math.MaxFloat64
+	maxLat, maxLon := -math.MaxFloat64, -math.MaxFloat64
+	for _, zone := range append(append([]zoneRings{}, activeZones...), revisionZones...) {
+		for _, ring := range zone.outer {
+			for _, coord := range ring {
+				minLon = math.Min(minLon, coord[0])
+				maxLon = math.Max(maxLon, coord[0])
+				minLat = math.Min(minLat, coord[1])
+				maxLat = math.Max(maxLat, coord[1])
+			}
+		}
+	}
+	if minLat >= maxLat || minLon >= maxLon {
+		return change
+	}
+
+	step := math.Max(maxLat-minLat, maxLon-minLon) / boundaryPreviewSamples
+	activeGrid := buildZoneGrid(activeZones, step*4)
+	revisionGrid := buildZoneGrid(revisionZones, step*4)
+	metersPerDegree := EarthRadiusMeters * math.Pi / 180
+
+	for lat := minLat + step/2; lat < maxLat; lat += step {
+		cellKm2 := step * metersPerDegree * step * metersPerDegree * math.Cos(toRadians(lat)) / 1e6
+		for lon := minLon + step/2; lon < maxLon; lon += step {
+			inActive := zonesContain(activeGrid, activeZones, lat, lon)
+			inRevision := zonesContain(revisionGrid, revisionZones, lat, lon)
+			switch {
+			case inRevision && !inActive:
+				change.AddedKm2 += cellKm2
+			case inActive && !inRevision:
+				change.RemovedKm2 += cellKm2
+			}
+		}
+	}
+
+	return change
+}
+
+// layerZoneRings returns the rings of every feature of a layer
+func layerZoneRings(fc *geojson.FeatureCollection) []zoneRings {
+	if fc == nil {
+		return nil
+	}
+	zones := make([]zoneRings, 0, len(fc.Features))
+	for _, feature := range fc.Features {
+		zones = append(zones, featureZoneRings(feature, 0))
+	}
+	return zones
+}
+
+// zonesContain reports whether a point lies inside the outer ring of any zone
+func zonesContain(grid *zoneGrid, zones []zoneRings, lat, lon float64) bool {
+	if inside, ok := grid.lookup(lat, lon); ok {
+		return inside
+	}
+	point := []float64{lon, lat}
+	for _, zone := range zones {
+		for _, ring := range zone.outer {
+			if len(ring) >= 3 && ringContains(ring, point) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// layerAreaKm2 returns the area of a layer's polygons less their holes,
+// projecting each ring onto a plane at its mean latitude
+func layerAreaKm2(fc *geojson.FeatureCollection) float64 {
+	if fc == nil {
+		return 0
+	}
+
+	total := 0.0
+	for _, feature := range fc.Features {
+		g := feature.Geometry
+		if g == nil {
+			continue
+		}
+		var polygons [][][][]float64
+		switch g.Type {
+		case geojson.GeometryPolygon:
+			polygons = [][][][]float64{g.Polygon}
+		case geojson.GeometryMultiPolygon:
+			polygons = g.MultiPolygon
+		}
+		for _, polygon := range polygons {
+			for i, ring := range polygon {
+				area := ringAreaKm2(ring)
+				if i > 0 {
+					area = -area
+				}
+				total += area
+			}
+		}
+	}
+	return math.Max(total, 0)
+}
+
+// ringAreaKm2 returns the unsigned area of a ring in square kilometers
+func ringAreaKm2(ring [][]float64) float64 {
+	if len(ring) < 3 {
+		return 0
+	}
+	meanLat := 0.0
+	for _, coord := range ring {
+		meanLat += coord[1]
+	}
+	meanLat /= float64(len(ring))
+
+	metersPerDegree := EarthRadiusMeters * math.Pi / 180
+	return math.Abs(ringArea(ring)) * metersPerDegree * metersPerDegree * math.Cos(toRadians(meanLat)) / 1e6
+}
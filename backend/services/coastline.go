@@ -0,0 +1,370 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	geojson "github.com/paulmach/go.geojson"
+)
+
+// DefaultOverpassURL is the Overpass API instance coastlines are fetched from
+// when OVERPASS_URL is not set
+const DefaultOverpassURL = "https://overpass-api.de/api/interpreter"
+
+// coastlineBorderEpsilon is how close to the region border, in degrees, a
+// clipped coastline end must be to count as lying on it
+const coastlineBorderEpsilon = 1e-9
+
+// CoastlineAttribution credits the OpenStreetMap data the land mask is built
+// from, as its license requires
+const CoastlineAttribution = "© OpenStreetMap contributors, ODbL"
+
+type overpassResponse struct {
+	Elements []overpassWay `json:"elements"`
+}
+
+type overpassWay struct {
+	Type     string  `json:"type"`
+	ID       int64   `json:"id"`
+	Nodes    []int64 `json:"nodes"`
+	Geometry []struct {
+		Lat float64 `json:"lat"`
+		Lon float64 `json:"lon"`
+	} `json:"geometry"`
+}
+
+// coastlineChain is a run of joined coastline ways. OpenStreetMap draws
+// coastlines with the land on their left.
+type coastlineChain struct {
+	first, last int64
+	points      [][]float64 // lon, lat
+}
+
+// coastlinePiece is the part of a chain between where it enters the region
+// and where it leaves it, with both ends as positions along the border
+type coastlinePiece struct {
+	points      [][]float64
+	entry, exit float64
+}
+
+// FetchCoastline downloads the OpenStreetMap coastline around a region from
+// an Overpass API instance and turns it into land polygons clipped to the
+// region. Islands entirely mapped within the response are kept whole.
+func FetchCoastline(ctx context.Context, overpassURL string, region Region) (*geojson.FeatureCollection, error) {
+	query := fmt.Sprintf(`[out:json][timeout:180];way["natural"="coastline"](%f,%f,%f,%f);out geom;`,
+		region.MinLat, region.MinLon, region.MaxLat, region.MaxLon)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, overpassURL, strings.NewReader(url.Values{"data": {query}}.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", "vessel-tracker")
+
+	client := &http.Client{Timeout: 5 * time.Minute}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query Overpass: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("overpass returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var parsed overpassResponse
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse Overpass response: %w", err)
+	}
+
+	return buildLandPolygons(parsed.Elements, region)
+}
+
+// buildLandPolygons joins coastline ways into chains, keeps closed chains as
+// islands and closes the chains crossing the region along its border
+func buildLandPolygons(ways []overpassWay, region Region) (*geojson.FeatureCollection, error) {
+	if region.MinLon >= region.MaxLon || region.MinLat >= region.MaxLat {
+		return nil, fmt.Errorf("empty coastline region")
+	}
+
+	fc := geojson.NewFeatureCollection()
+	var pieces []coastlinePiece
+	for _, chain := range joinCoastline(ways) {
+		if chain.first == chain.last {
+			fc.AddFeature(landFeature(chain.points))
+			continue
+		}
+		chainPieces, err := clipCoastline(chain.points, region)
+		if err != nil {
+			return nil, err
+		}
+		pieces = append(pieces, chainPieces...)
+	}
+
+	for _, ring := range closeCoastline(pieces, region) {
+		fc.AddFeature(landFeature(ring))
+	}
+	return fc, nil
+}
+
+func landFeature(ring [][]float64) *geojson.Feature {
+	feature := geojson.NewPolygonFeature([][][]float64{ring})
+	feature.SetProperty("kind", "land")
+	feature.SetProperty("source", CoastlineAttribution)
+	return feature
+}
+
+// joinCoastline links ways that continue one another into chains. Chains
+// are started from ways no other way leads into, so every open chain is
+// complete; the remaining ways form closed rings.
+func joinCoastline(ways []overpassWay) []*coastlineChain {
+	var chains []*coastlineChain
+	for _, way := range ways {
+		if way.Type != "way" || len(way.Nodes) < 2 || len(way.Nodes) != len(way.Geometry) {
+			continue
+		}
+		chain := &coastlineChain{first: way.Nodes[0], last: way.Nodes[len(way.Nodes)-1]}
+		for _, point := range way.Geometry {
+			chain.points = append(chain.points, []float64{point.Lon, point.Lat})
+		}
+		chains = append(chains, chain)
+	}
+
+	byFirst := make(map[int64]*coastlineChain, len(chains))
+	leadsInto := make(map[int64]bool, len(chains))
+	for _, chain := range chains {
+		byFirst[chain.first] = chain
+		leadsInto[chain.last] = true
+	}
+
+	var order []*coastlineChain
+	for _, chain := range chains {
+		if !leadsInto[chain.first] {
+			order = append(order, chain)
+		}
+	}
+	order = append(order, chains...)
+
+	used := make(map[*coastlineChain]bool, len(chains))
+	var joined []*coastlineChain
+	for _, chain := range order {
+		if used[chain] {
+			continue
+		}
+		used[chain] = true
+
+		for chain.last != chain.first {
+			next, ok := byFirst[chain.last]
+			if !ok || used[next] {
+				break
+			}
+			used[next] = true
+			chain.points = append(chain.points, next.points[1:]...)
+			chain.last = next.last
+		}
+		joined = append(joined, chain)
+	}
+	return joined
+}
+
+// clipSegment clips the segment from a to b to the region (Liang-Barsky),
+// returning the part inside it as parameters along the segment
+func clipSegment(a, b []float64, region Region) (float64, float64, bool) {
+	t0, t1 := 0.0, 1.0
+	dx, dy := b[0]-a[0], b[1]-a[1]
+	edges := [4][2]float64{
+		{-dx, a[0] - region.MinLon},
+		{dx, region.MaxLon - a[0]},
+		{-dy, a[1] - region.MinLat},
+		{dy, region.MaxLat - a[1]},
+	}
+	for _, edge := range edges {
+		p, q := edge[0], edge[1]
+		if p == 0 {
+			if q < 0 {
+				return 0, 0, false
+			}
+			continue
+		}
+		t := q / p
+		if p < 0 {
+			if t > t1 {
+				return 0, 0, false
+			}
+			t0 = math.Max(t0, t)
+		} else {
+			if t < t0 {
+				return 0, 0, false
+			}
+			t1 = math.Min(t1, t)
+		}
+	}
+	return t0, t1, true
+}
+
+func interpolate(a, b []float64, t float64) []float64 {
+	return []float64{a[0] + (b[0]-a[0])*t, a[1] + (b[1]-a[1])*t}
+}
+
+// clipCoastline splits an open chain into the pieces inside the region. A
+// chain ending inside the region means the coastline is broken there in
+// OpenStreetMap, and no land polygon can be closed from it.
+func clipCoastline(points [][]float64, region Region) ([]coastlinePiece, error) {
+	var pieces []coastlinePiece
+	var current [][]float64
+
+	for i := 0; i+1 < len(points); i++ {
+		a, b := points[i], points[i+1]
+		t0, t1, ok := clipSegment(a, b, region)
+		if !ok {
+			continue
+		}
+
+		if current == nil {
+			current = [][]float64{interpolate(a, b, t0)}
+		}
+		end := interpolate(a, b, t1)
+		if last := current[len(current)-1]; last[0] != end[0] || last[1] != end[1] {
+			current = append(current, end)
+		}
+
+		if t1 < 1 {
+			piece, err := newCoastlinePiece(current, region)
+			if err != nil {
+				return nil, err
+			}
+			pieces = append(pieces, piece)
+			current = nil
+		}
+	}
+
+	if current != nil {
+		last := current[len(current)-1]
+		return nil, fmt.Errorf("coastline ends inside the region at %.6f,%.6f", last[1], last[0])
+	}
+	return pieces, nil
+}
+
+func newCoastlinePiece(points [][]float64, region Region) (coastlinePiece, error) {
+	first, last := points[0], points[len(points)-1]
+	entry, ok := borderPosition(first, region)
+	if !ok {
+		return coastlinePiece{}, fmt.Errorf("coastline starts inside the region at %.6f,%.6f", first[1], first[0])
+	}
+	exit, _ := borderPosition(last, region)
+	return coastlinePiece{points: points, entry: entry, exit: exit}, nil
+}
+
+// borderPosition locates a point on the region border by its distance along
+// the border, counter-clockwise from the south-west corner
+func borderPosition(point []float64, region Region) (float64, bool) {
+	width, height := region.MaxLon-region.MinLon, region.MaxLat-region.MinLat
+	distances := []float64{
+		point[1] - region.MinLat,
+		region.MaxLon - point[0],
+		region.MaxLat - point[1],
+		point[0] - region.MinLon,
+	}
+
+	edge := 0
+	for i, d := range distances {
+		if math.Abs(d) < math.Abs(distances[edge]) {
+			edge = i
+		}
+	}
+	onBorder := math.Abs(distances[edge]) <= coastlineBorderEpsilon
+
+	switch edge {
+	case 0:
+		return point[0] - region.MinLon, onBorder
+	case 1:
+		return width + point[1] - region.MinLat, onBorder
+	case 2:
+		return width + height + region.MaxLon - point[0], onBorder
+	default:
+		return 2*width + height + region.MaxLat - point[1], onBorder
+	}
+}
+
+// closeCoastline turns the pieces of coastline crossing the region into land
+// polygons. With the land on the left of the coastline, the border from
+// where a piece leaves the region counter-clockwise to where the next piece
+// enters it runs along land.
+func closeCoastline(pieces []coastlinePiece, region Region) [][][]float64 {
+	width, height := region.MaxLon-region.MinLon, region.MaxLat-region.MinLat
+	perimeter := 2 * (width + height)
+	corners := []struct {
+		position float64
+		point    []float64
+	}{
+		{0, []float64{region.MinLon, region.MinLat}},
+		{width, []float64{region.MaxLon, region.MinLat}},
+		{width + height, []float64{region.MaxLon, region.MaxLat}},
+		{2*width + height, []float64{region.MinLon, region.MaxLat}},
+	}
+
+	along := func(from, to float64) float64 {
+		d := math.Mod(to-from, perimeter)
+		if d < 0 {
+			d += perimeter
+		}
+		return d
+	}
+
+	used := make([]bool, len(pieces))
+	var rings [][][]float64
+	for start := range pieces {
+		if used[start] {
+			continue
+		}
+		used[start] = true
+
+		ring := append([][]float64{}, pieces[start].points...)
+		current := start
+		for {
+			exit := pieces[current].exit
+			next, distance := start, along(exit, pieces[start].entry)
+			for j := range pieces {
+				if used[j] {
+					continue
+				}
+				if d := along(exit, pieces[j].entry); d < distance {
+					next, distance = j, d
+				}
+			}
+
+			passed := make([]int, 0, len(corners))
+			for i, corner := range corners {
+				if d := along(exit, corner.position); d > 0 && d < distance {
+					passed = append(passed, i)
+				}
+			}
+			sort.Slice(passed, func(a, b int) bool {
+				return along(exit, corners[passed[a]].position) < along(exit, corners[passed[b]].position)
+			})
+			for _, i := range passed {
+				ring = append(ring, corners[i].point)
+			}
+
+			if next == start {
+				break
+			}
+			used[next] = true
+			ring = append(ring, pieces[next].points...)
+			current = next
+		}
+
+		ring = append(ring, ring[0])
+		rings = append(rings, ring)
+	}
+	return rings
+}
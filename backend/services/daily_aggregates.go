@@ -0,0 +1,209 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+	"vessel-tracker/database"
+	"vessel-tracker/logging"
+	"vessel-tracker/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// aggregateDayFormat is the layout of DailyParkAggregate.Day
+const aggregateDayFormat = "2006-01-02"
+
+// DailyAggregateService finalizes what is kept of a park's positions once
+// they expire: a daily aggregate and emission estimate of every complete
+// day, and the evidence of violations recorded without it. It runs before the retention job deletes
+// positions, which keeps them while anything derived from them is not final.
+type DailyAggregateService struct {
+	db               *gorm.DB
+	parks            *ParkRegistry
+	violationService *ViolationService
+	emissions        *EmissionService
+	logger           *slog.Logger
+}
+
+func NewDailyAggregateService(parks *ParkRegistry, violationService *ViolationService, emissions *EmissionService) *DailyAggregateService {
+	return &DailyAggregateService{
+		db:               database.GetDB(),
+		parks:            parks,
+		violationService: violationService,
+		emissions:        emissions,
+		logger:           logging.Component("aggregates"),
+	}
+}
+
+// FinalizeBefore writes the aggregate of every complete UTC day starting
+// before cutoff that has positions and no aggregate yet, in every park, and
+// captures the evidence of violations detected before cutoff that have
+// none. It returns an error when any of them could not be finalized, so the
+// positions they derive from must be kept.
+func (s *DailyAggregateService) FinalizeBefore(ctx context.Context, cutoff time.Time) error {
+	now := time.Now()
+	for _, park := range s.parks.All() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		days, err := s.finalizeDays(ctx, park, cutoff, now)
+		if err != nil {
+			return fmt.Errorf("park %s: %w", park.Record.Slug, err)
+		}
+
+		backfilled, err := s.violationService.BackfillEvidence(ctx, park, cutoff)
+		if err != nil {
+			return fmt.Errorf("park %s: %w", park.Record.Slug, err)
+		}
+
+		if days > 0 || backfilled > 0 {
+			s.logger.Info("Finalized expiring park data", "park", park.Record.Slug, "cutoff", cutoff, "days_aggregated", days, "evidence_backfilled", backfilled)
+		}
+	}
+	return nil
+}
+
+// finalizeDays aggregates the complete days of a park from its oldest
+// position up to cutoff that are not aggregated yet
+func (s *DailyAggregateService) finalizeDays(ctx context.Context, park *Park, cutoff, now time.Time) (int, error) {
+	var oldest []models.VesselPositionRecord
+	err := s.db.WithContext(ctx).Select("recorded_at").
+		Where("park_id = ? AND recorded_at < ?", park.Record.ID, cutoff).
+		Order("recorded_at ASC").
+		Limit(1).
+		Find(&oldest).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to find the oldest position: %w", err)
+	}
+	if len(oldest) == 0 {
+		return 0, nil
+	}
+	first := oldest[0].RecordedAt.UTC()
+
+	var done []string
+	err = s.db.WithContext(ctx).Model(&models.DailyParkAggregate{}).
+		Where("park_id = ? AND day >= ?", park.Record.ID, first.Format(aggregateDayFormat)).
+		Pluck("day", &done).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to load aggregated days: %w", err)
+	}
+	finalized := make(map[string]bool, len(done))
+	for _, day := range done {
+		finalized[day] = true
+	}
+
+	aggregated := 0
+	for day := time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, time.UTC); day.Before(cutoff); day = day.AddDate(0, 0, 1) {
+		end := day.AddDate(0, 0, 1)
+		if end.After(now) {
+			// The day is not over; a retention window ends in a past day
+			break
+		}
+		if finalized[day.Format(aggregateDayFormat)] {
+			continue
+		}
+
+		aggregate, err := s.aggregateDay(ctx, park.Record.ID, day, end)
+		if err != nil {
+			return aggregated, fmt.Errorf("failed to aggregate %s: %w", day.Format(aggregateDayFormat), err)
+		}
+		if aggregate.Positions == 0 {
+			continue
+		}
+		err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			created := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(aggregate)
+			if created.Error != nil || created.RowsAffected == 0 {
+				// Another instance finalized the day first
+				return created.Error
+			}
+			return s.emissions.finalizeDay(ctx, tx, park.Record.ID, day)
+		})
+		if err != nil {
+			return aggregated, fmt.Errorf("failed to store the aggregate of %s: %w", aggregate.Day, err)
+		}
+		aggregated++
+	}
+	return aggregated, nil
+}
+
+// aggregateDay summarizes the positions, park entries and violations of a
+// park between start and end
+func (s *DailyAggregateService) aggregateDay(ctx context.Context, parkID uint, start, end time.Time) (*models.DailyParkAggregate, error) {
+	aggregate := &models.DailyParkAggregate{
+		ParkID:      parkID,
+		Day:         start.Format(aggregateDayFormat),
+		FinalizedAt: time.Now(),
+	}
+
+	rows, err := s.db.WithContext(ctx).Model(&models.VesselPositionRecord{}).
+		Select("vessel_uuid, is_in_park, on_land, speed").
+		Where("park_id = ? AND recorded_at >= ? AND recorded_at < ?", parkID, start, end).
+		Rows()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load positions: %w", err)
+	}
+	defer rows.Close()
+
+	vessels := make(map[string]bool)
+	for rows.Next() {
+		var sample struct {
+			VesselUUID string
+			IsInPark   bool
+			OnLand     bool
+			Speed      float64
+		}
+		if err := s.db.ScanRows(rows, &sample); err != nil {
+			return nil, fmt.Errorf("failed to read positions: %w", err)
+		}
+
+		aggregate.Positions++
+		if sample.OnLand {
+			aggregate.PositionsOnLand++
+			if _, ok := vessels[sample.VesselUUID]; !ok {
+				vessels[sample.VesselUUID] = false
+			}
+			continue
+		}
+		if sample.IsInPark {
+			aggregate.PositionsInPark++
+		}
+		if sample.Speed > aggregate.MaxSpeedKnots {
+			aggregate.MaxSpeedKnots = sample.Speed
+		}
+		vessels[sample.VesselUUID] = vessels[sample.VesselUUID] || sample.IsInPark
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read positions: %w", err)
+	}
+	aggregate.Vessels, aggregate.VesselsInPark = countVessels(vessels)
+
+	err = s.db.WithContext(ctx).Model(&models.ZoneEvent{}).
+		Where("park_id = ? AND type = ? AND occurred_at >= ? AND occurred_at < ?", parkID, models.ZoneEventEnteredPark, start, end).
+		Count(&aggregate.ParkEntries).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to count park entries: %w", err)
+	}
+
+	err = s.db.WithContext(ctx).Model(&models.Violation{}).
+		Where("park_id = ? AND detected_at >= ? AND detected_at < ?", parkID, start, end).
+		Count(&aggregate.Violations).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to count violations: %w", err)
+	}
+
+	return aggregate, nil
+}
+
+// GetDailyAggregates returns the aggregates of a park for the UTC days from
+// start to end, oldest first
+func (s *DailyAggregateService) GetDailyAggregates(parkID uint, start, end time.Time) ([]models.DailyParkAggregate, error) {
+	var aggregates []models.DailyParkAggregate
+	err := s.db.Where("park_id = ? AND day >= ? AND day <= ?", parkID, start.UTC().Format(aggregateDayFormat), end.UTC().Format(aggregateDayFormat)).
+		Order("day ASC").
+		Find(&aggregates).Error
+	return aggregates, err
+}
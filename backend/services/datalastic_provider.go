@@ -0,0 +1,269 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"time"
+	"vessel-tracker/logging"
+	"vessel-tracker/models"
+)
+
+const (
+	BaseURL = "https://api.datalastic.com/api/v0"
+)
+
+// DatalasticProvider serves vessel data from the Datalastic API
+type DatalasticProvider struct {
+	apiKey string
+	client *http.Client
+	logger *slog.Logger
+}
+
+// NewDatalasticProvider gives up on each request after timeout
+func NewDatalasticProvider(apiKey string, timeout time.Duration) *DatalasticProvider {
+	return &DatalasticProvider{
+		apiKey: apiKey,
+		client: &http.Client{Timeout: timeout},
+		logger: logging.Component("datalastic"),
+	}
+}
+
+// NewDatalasticProviderWithClient uses the given HTTP client for all
+// Datalastic requests, e.g. one whose transport serves synthetic data
+func NewDatalasticProviderWithClient(apiKey string, client *http.Client) *DatalasticProvider {
+	return &DatalasticProvider{
+		apiKey: apiKey,
+		client: client,
+		logger: logging.Component("datalastic"),
+	}
+}
+
+func (s *DatalasticProvider) Name() string {
+	return ProviderDatalastic
+}
+
+func (s *DatalasticProvider) SearchVessels(ctx context.Context, params map[string]string) (*models.VesselResponse, error) {
+	endpoint := fmt.Sprintf("%s/vessel_find", BaseURL)
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse URL: %w", err)
+	}
+
+	q := u.Query()
+	q.Set("api-key", s.apiKey)
+
+	for key, value := range params {
+		q.Set(key, value)
+	}
+
+	u.RawQuery = q.Encode()
+
+	resp, err := s.get(ctx, u.String())
+	if err != nil {
+		if quotaErr := quotaError(err); quotaErr != nil {
+			return nil, quotaErr
+		}
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusPaymentRequired {
+		return nil, ErrQuotaExhausted
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var vesselResp models.VesselResponse
+	if err := json.NewDecoder(resp.Body).Decode(&vesselResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &vesselResp, nil
+}
+
+// GetHistory fetches historical vessel data from Datalastic API
+func (s *DatalasticProvider) GetHistory(ctx context.Context, params map[string]string) (*models.VesselHistoryResponse, error) {
+	endpoint := fmt.Sprintf("%s/vessel_history", BaseURL)
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse URL: %w", err)
+	}
+
+	q := u.Query()
+	q.Set("api-key", s.apiKey)
+
+	// Add all parameters (uuid, mmsi, imo, days, from, to)
+	for key, value := range params {
+		q.Set(key, value)
+	}
+
+	u.RawQuery = q.Encode()
+
+	resp, err := s.get(ctx, u.String())
+	if err != nil {
+		if quotaErr := quotaError(err); quotaErr != nil {
+			return nil, quotaErr
+		}
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusPaymentRequired {
+		return nil, ErrQuotaExhausted
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var historyResp models.VesselHistoryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&historyResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &historyResp, nil
+}
+
+func (s *DatalasticProvider) GetVesselsInRadius(ctx context.Context, lat, lon float64, radius int) (*models.VesselPositionResponse, error) {
+	return s.getVesselsInRadiusWithRetry(ctx, lat, lon, radius, 3)
+}
+
+func (s *DatalasticProvider) getVesselsInRadiusWithRetry(ctx context.Context, lat, lon float64, radius int, maxRetries int) (*models.VesselPositionResponse, error) {
+	endpoint := fmt.Sprintf("%s/vessel_inradius", BaseURL)
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse URL: %w", err)
+	}
+
+	q := u.Query()
+	q.Set("api-key", s.apiKey)
+	q.Set("lat", fmt.Sprintf("%.6f", lat))
+	q.Set("lon", fmt.Sprintf("%.6f", lon))
+	q.Set("radius", fmt.Sprintf("%d", radius))
+
+	u.RawQuery = q.Encode()
+
+	var lastErr error
+
+	for attempt := 0; attempt < maxRetries; attempt++ {
+		if attempt > 0 {
+			// Exponential backoff: 2^attempt seconds with jitter
+			backoffSeconds := math.Pow(2, float64(attempt))
+			backoffDuration := time.Duration(backoffSeconds) * time.Second
+			s.logger.Warn("Rate limit encountered, retrying",
+				"backoff", backoffDuration.String(), "attempt", attempt+1, "max_retries", maxRetries)
+			select {
+			case <-ctx.Done():
+				return nil, fmt.Errorf("gave up retrying: %w", ctx.Err())
+			case <-time.After(backoffDuration):
+			}
+		}
+
+		resp, err := s.get(ctx, u.String())
+		if err != nil {
+			if quotaErr := quotaError(err); quotaErr != nil {
+				return nil, quotaErr
+			}
+			if ctx.Err() != nil {
+				return nil, fmt.Errorf("failed to make request: %w", err)
+			}
+			lastErr = fmt.Errorf("failed to make request: %w", err)
+			continue
+		}
+
+		if resp.StatusCode == http.StatusOK {
+			// Success - decode and return
+			var vesselResp models.VesselPositionResponse
+			if err := json.NewDecoder(resp.Body).Decode(&vesselResp); err != nil {
+				resp.Body.Close()
+				return nil, fmt.Errorf("failed to decode response: %w", err)
+			}
+			resp.Body.Close()
+			return &vesselResp, nil
+		}
+
+		// Read error response
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		// No credits left - retrying would not help
+		if resp.StatusCode == http.StatusPaymentRequired {
+			return nil, ErrQuotaExhausted
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests {
+			// Rate limit - continue retrying
+			lastErr = fmt.Errorf("API rate limit (status %d): %s", resp.StatusCode, string(body))
+			continue
+		}
+
+		// Other error - don't retry
+		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	return nil, fmt.Errorf("max retries exceeded, last error: %v", lastErr)
+}
+
+// Ping checks that the Datalastic API is reachable and accepts the API key.
+// It queries the account stat endpoint, which does not consume credits.
+func (s *DatalasticProvider) Ping(ctx context.Context) error {
+	u, err := url.Parse(fmt.Sprintf("%s/stat", BaseURL))
+	if err != nil {
+		return fmt.Errorf("failed to parse URL: %w", err)
+	}
+
+	q := u.Query()
+	q.Set("api-key", s.apiKey)
+	u.RawQuery = q.Encode()
+
+	resp, err := s.get(ctx, u.String())
+	if err != nil {
+		// The result ends up on the public health endpoint, so leave out the
+		// request URL and the API key it carries
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	return nil
+}
+
+// quotaError returns the quota error a request was refused with, leaving
+// out the request URL and the API key it carries
+func quotaError(err error) error {
+	for _, quotaErr := range []error{ErrQuotaExhausted, ErrQuotaDeferred} {
+		if errors.Is(err, quotaErr) {
+			return quotaErr
+		}
+	}
+	return nil
+}
+
+// get issues a GET request that is abandoned when ctx is done
+func (s *DatalasticProvider) get(ctx context.Context, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	return s.client.Do(req)
+}
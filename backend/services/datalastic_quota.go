@@ -0,0 +1,309 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"vessel-tracker/database"
+	"vessel-tracker/logging"
+	"vessel-tracker/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrQuotaExhausted is returned when the day's Datalastic credits are spent,
+// or Datalastic reported that none are left
+var ErrQuotaExhausted = errors.New("Datalastic daily credit quota exhausted")
+
+// ErrQuotaDeferred is returned for a call outside a scheduled fetch when the
+// remaining credits are held in reserve for the scheduled fetches
+var ErrQuotaDeferred = errors.New("Datalastic credits are reserved for scheduled fetches")
+
+// DatalasticQuotaConfig bounds the use of the Datalastic API
+type DatalasticQuotaConfig struct {
+	DailyCredits      int64 // credits per UTC day, 0 for no limit
+	Reserve           int64 // credits kept for scheduled fetches
+	RequestsPerMinute int   // request rate, 0 for no limit
+}
+
+// DefaultDatalasticQuotaConfig counts credits without limiting them
+func DefaultDatalasticQuotaConfig() DatalasticQuotaConfig {
+	return DatalasticQuotaConfig{}
+}
+
+// LoadDatalasticQuotaConfig reads DATALASTIC_DAILY_CREDITS,
+// DATALASTIC_QUOTA_RESERVE (10% of the daily credits by default) and
+// DATALASTIC_REQUESTS_PER_MINUTE
+func LoadDatalasticQuotaConfig() (DatalasticQuotaConfig, error) {
+	config := DefaultDatalasticQuotaConfig()
+
+	if value := os.Getenv("DATALASTIC_DAILY_CREDITS"); value != "" {
+		credits, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || credits < 0 {
+			return config, fmt.Errorf("invalid DATALASTIC_DAILY_CREDITS %q: must be a non-negative number of credits", value)
+		}
+		config.DailyCredits = credits
+	}
+
+	config.Reserve = config.DailyCredits / 10
+	if value := os.Getenv("DATALASTIC_QUOTA_RESERVE"); value != "" {
+		reserve, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || reserve < 0 {
+			return config, fmt.Errorf("invalid DATALASTIC_QUOTA_RESERVE %q: must be a non-negative number of credits", value)
+		}
+		config.Reserve = reserve
+	}
+
+	if value := os.Getenv("DATALASTIC_REQUESTS_PER_MINUTE"); value != "" {
+		rate, err := strconv.Atoi(value)
+		if err != nil || rate < 0 {
+			return config, fmt.Errorf("invalid DATALASTIC_REQUESTS_PER_MINUTE %q: must be a non-negative number of requests", value)
+		}
+		config.RequestsPerMinute = rate
+	}
+
+	return config, config.Validate()
+}
+
+// Validate checks that the reserve leaves credits for other calls
+func (c DatalasticQuotaConfig) Validate() error {
+	if c.DailyCredits > 0 && c.Reserve >= c.DailyCredits {
+		return fmt.Errorf("quota reserve of %d credits must be below the %d daily credits", c.Reserve, c.DailyCredits)
+	}
+	return nil
+}
+
+// QuotaStatus reports the Datalastic credits spent today
+type QuotaStatus struct {
+	Provider          string    `json:"provider"`
+	Day               string    `json:"day"`
+	DailyCredits      int64     `json:"daily_credits"` // 0 for no limit
+	Used              int64     `json:"used"`
+	Remaining         *int64    `json:"remaining"` // nil without a limit
+	Reserve           int64     `json:"reserve"`
+	Deferring         bool      `json:"deferring"` // calls outside scheduled fetches are refused
+	Exhausted         bool      `json:"exhausted"`
+	ResetsAt          time.Time `json:"resets_at"`
+	RequestsPerMinute int       `json:"requests_per_minute"`
+}
+
+// DatalasticQuota counts the credits spent on the Datalastic API per UTC day
+// and spaces requests with a token bucket. Every request that reaches the
+// API is counted as a credit, retries included; the free account stat
+// endpoint is not. Once only the reserve is left, calls outside a scheduled
+// fetch are refused with ErrQuotaDeferred, and once the credits are spent or
+// Datalastic answers 402 every call is refused with ErrQuotaExhausted until
+// the next UTC day. The day's count is stored so it survives restarts.
+type DatalasticQuota struct {
+	config DatalasticQuotaConfig
+	db     *gorm.DB
+	logger *slog.Logger
+
+	mu        sync.Mutex
+	day       string
+	used      int64
+	exhausted bool
+	tokens    float64
+	refilled  time.Time
+}
+
+func NewDatalasticQuota(config DatalasticQuotaConfig) *DatalasticQuota {
+	return &DatalasticQuota{
+		config:   config,
+		db:       database.GetDB(),
+		logger:   logging.Component("datalastic_quota"),
+		tokens:   float64(config.RequestsPerMinute),
+		refilled: time.Now(),
+	}
+}
+
+// quotaDay returns the UTC day credits are counted against
+func quotaDay(now time.Time) string {
+	return now.UTC().Format("2006-01-02")
+}
+
+// rollDay starts counting a new day, loading what was already spent on it
+// before a restart. The caller holds mu.
+func (q *DatalasticQuota) rollDay(now time.Time) {
+	day := quotaDay(now)
+	if day == q.day {
+		return
+	}
+
+	q.day = day
+	q.used = 0
+	q.exhausted = false
+
+	var usage models.ProviderCreditUsage
+	err := q.db.Where("day = ? AND provider = ?", day, ProviderDatalastic).Limit(1).Find(&usage).Error
+	if err != nil {
+		q.logger.Error("Failed to load credit usage", "day", day, "error", err)
+		return
+	}
+	q.used = usage.Credits
+}
+
+// Check returns ErrQuotaExhausted or ErrQuotaDeferred when a call made with
+// ctx must not spend credits now. Calls within a scheduled fetch run may use
+// the reserve.
+func (q *DatalasticQuota) Check(ctx context.Context) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.rollDay(time.Now())
+	if q.exhausted || (q.config.DailyCredits > 0 && q.used >= q.config.DailyCredits) {
+		return ErrQuotaExhausted
+	}
+	if FetchRunID(ctx) == "" && q.deferring() {
+		return ErrQuotaDeferred
+	}
+	return nil
+}
+
+// deferring reports whether only the reserve is left. The caller holds mu.
+func (q *DatalasticQuota) deferring() bool {
+	return q.config.DailyCredits > 0 && q.used >= q.config.DailyCredits-q.config.Reserve
+}
+
+// wait blocks until the token bucket allows another request
+func (q *DatalasticQuota) wait(ctx context.Context) error {
+	if q.config.RequestsPerMinute == 0 {
+		return nil
+	}
+
+	rate := float64(q.config.RequestsPerMinute) / time.Minute.Seconds()
+	for {
+		q.mu.Lock()
+		now := time.Now()
+		q.tokens += now.Sub(q.refilled).Seconds() * rate
+		if burst := float64(q.config.RequestsPerMinute); q.tokens > burst {
+			q.tokens = burst
+		}
+		q.refilled = now
+		if q.tokens >= 1 {
+			q.tokens--
+			q.mu.Unlock()
+			return nil
+		}
+		delay := time.Duration((1 - q.tokens) / rate * float64(time.Second))
+		q.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// charge counts a request that reached the API
+func (q *DatalasticQuota) charge(credits int64) {
+	q.mu.Lock()
+	q.rollDay(time.Now())
+	q.used += credits
+	day := q.day
+	startedDeferring := q.deferring() && q.used-credits < q.config.DailyCredits-q.config.Reserve
+	q.mu.Unlock()
+
+	if startedDeferring {
+		q.logger.Warn("Datalastic credits down to the reserve, deferring calls outside scheduled fetches", "day", day, "reserve", q.config.Reserve)
+	}
+
+	err := q.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "day"}, {Name: "provider"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"credits": gorm.Expr("provider_credit_usages.credits + ?", credits), "updated_at": time.Now()}),
+	}).Create(&models.ProviderCreditUsage{Day: day, Provider: ProviderDatalastic, Credits: credits}).Error
+	if err != nil {
+		q.logger.Error("Failed to store credit usage", "day", day, "error", err)
+	}
+}
+
+// markExhausted refuses further calls for the rest of the day after
+// Datalastic reported that no credits are left
+func (q *DatalasticQuota) markExhausted() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.rollDay(time.Now())
+	if !q.exhausted {
+		q.exhausted = true
+		q.logger.Error("Datalastic reports no credits left, refusing calls until the next UTC day", "day", q.day, "used", q.used)
+	}
+}
+
+// Status returns the credits spent today and when the count resets
+func (q *DatalasticQuota) Status() QuotaStatus {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	q.rollDay(now)
+
+	status := QuotaStatus{
+		Provider:          ProviderDatalastic,
+		Day:               q.day,
+		DailyCredits:      q.config.DailyCredits,
+		Used:              q.used,
+		Reserve:           q.config.Reserve,
+		Deferring:         q.deferring(),
+		Exhausted:         q.exhausted || (q.config.DailyCredits > 0 && q.used >= q.config.DailyCredits),
+		ResetsAt:          now.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour),
+		RequestsPerMinute: q.config.RequestsPerMinute,
+	}
+	if q.config.DailyCredits > 0 {
+		remaining := q.config.DailyCredits - q.used
+		if remaining < 0 {
+			remaining = 0
+		}
+		status.Remaining = &remaining
+	}
+	return status
+}
+
+// Transport wraps an HTTP transport so each Datalastic request is checked
+// against the quota, spaced by the token bucket and counted
+func (q *DatalasticQuota) Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return quotaTransport{quota: q, next: next}
+}
+
+type quotaTransport struct {
+	quota *DatalasticQuota
+	next  http.RoundTripper
+}
+
+func (t quotaTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// The account stat endpoint is free
+	if strings.HasSuffix(req.URL.Path, "/stat") {
+		return t.next.RoundTrip(req)
+	}
+
+	if err := t.quota.Check(req.Context()); err != nil {
+		return nil, err
+	}
+	if err := t.quota.wait(req.Context()); err != nil {
+		return nil, err
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	if resp.StatusCode == http.StatusPaymentRequired {
+		t.quota.markExhausted()
+	} else {
+		t.quota.charge(1)
+	}
+	return resp, nil
+}
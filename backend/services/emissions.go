@@ -0,0 +1,515 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+	"vessel-tracker/database"
+	"vessel-tracker/models"
+
+	"gorm.io/gorm"
+)
+
+// emissionMonthFormat is the layout of EmissionMonth.Month
+const emissionMonthFormat = "2006-01"
+
+// MaxEmissionMonths bounds the months of an emission report
+const MaxEmissionMonths = 24
+
+// EmissionSizeClass is the engine a class of vessels is assumed to have.
+// Vessels fall in the first class whose MaxLengthMeters is at least their
+// length; 0 leaves a class without an upper bound.
+type EmissionSizeClass struct {
+	Name             string  `json:"name"`
+	MaxLengthMeters  float64 `json:"max_length_m"`
+	MainEngineKW     float64 `json:"main_engine_kw"`
+	AuxiliaryKW      float64 `json:"auxiliary_kw"`       // hotel load, drawn underway and at rest
+	DesignSpeedKnots float64 `json:"design_speed_knots"` // speed at full main engine load
+}
+
+// EmissionFactors convert engine energy to fuel and fuel to emissions
+type EmissionFactors struct {
+	SFOCGramsPerKWh float64 `json:"sfoc_g_per_kwh"` // specific fuel oil consumption
+	CO2PerKgFuel    float64 `json:"co2_kg_per_kg_fuel"`
+	NOxPerKgFuel    float64 `json:"nox_g_per_kg_fuel"`
+	SOxPerKgFuel    float64 `json:"sox_g_per_kg_fuel"`
+	PMPerKgFuel     float64 `json:"pm_g_per_kg_fuel"`
+}
+
+// EmissionConfig holds the size classes and factors fuel burn and emissions
+// are estimated with. The main engine load follows the propeller law, the
+// cube of speed over design speed, but not below MinLoad; below
+// RestSpeedKnots a vessel is taken to be at anchor or moored and only draws
+// its auxiliary load. Vessels of unknown length fall in UnknownClass.
+type EmissionConfig struct {
+	SizeClasses    []EmissionSizeClass `json:"size_classes"`
+	UnknownClass   string              `json:"unknown_class"`
+	Factors        EmissionFactors     `json:"factors"`
+	MinLoad        float64             `json:"min_load"`
+	RestSpeedKnots float64             `json:"rest_speed_knots"`
+}
+
+// DefaultEmissionConfig assumes marine gas oil with 0.1% sulphur, as required
+// in EU ports and emission control areas, and the engines typical of the
+// pleasure craft and ferries around the archipelago
+func DefaultEmissionConfig() EmissionConfig {
+	return EmissionConfig{
+		SizeClasses: []EmissionSizeClass{
+			{Name: "small", MaxLengthMeters: 12, MainEngineKW: 150, AuxiliaryKW: 5, DesignSpeedKnots: 20},
+			{Name: "medium", MaxLengthMeters: 24, MainEngineKW: 800, AuxiliaryKW: 30, DesignSpeedKnots: 22},
+			{Name: "large", MaxLengthMeters: 50, MainEngineKW: 2500, AuxiliaryKW: 150, DesignSpeedKnots: 18},
+			{Name: "ship", MainEngineKW: 8000, AuxiliaryKW: 600, DesignSpeedKnots: 16},
+		},
+		UnknownClass: "small",
+		Factors: EmissionFactors{
+			SFOCGramsPerKWh: 215,
+			CO2PerKgFuel:    3.206,
+			NOxPerKgFuel:    52,
+			SOxPerKgFuel:    2,
+			PMPerKgFuel:     1,
+		},
+		MinLoad:        0.02,
+		RestSpeedKnots: 1,
+	}
+}
+
+// LoadEmissionConfig reads the size classes and factors from the JSON object
+// in EMISSION_FACTORS_FILE. Fields the file leaves out keep their defaults;
+// size_classes, when given, replaces the default classes.
+func LoadEmissionConfig() (EmissionConfig, error) {
+	config := DefaultEmissionConfig()
+
+	path := os.Getenv("EMISSION_FACTORS_FILE")
+	if path == "" {
+		return config, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, fmt.Errorf("invalid EMISSION_FACTORS_FILE %q: %w", path, err)
+	}
+	// Decoded into a nil slice, classes do not pick up default fields
+	defaults := config.SizeClasses
+	config.SizeClasses = nil
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("invalid EMISSION_FACTORS_FILE %q: %w", path, err)
+	}
+	if config.SizeClasses == nil {
+		config.SizeClasses = defaults
+	}
+	if err := config.validate(); err != nil {
+		return config, fmt.Errorf("invalid EMISSION_FACTORS_FILE %q: %w", path, err)
+	}
+
+	return config, nil
+}
+
+func (c EmissionConfig) validate() error {
+	if len(c.SizeClasses) == 0 {
+		return fmt.Errorf("no size classes")
+	}
+	names := make(map[string]bool, len(c.SizeClasses))
+	previous := 0.0
+	for i, class := range c.SizeClasses {
+		if class.Name == "" {
+			return fmt.Errorf("size class %d has no name", i+1)
+		}
+		if names[class.Name] {
+			return fmt.Errorf("duplicate size class %q", class.Name)
+		}
+		names[class.Name] = true
+
+		if class.MainEngineKW < 0 || class.AuxiliaryKW < 0 {
+			return fmt.Errorf("size class %q: engine power must not be negative", class.Name)
+		}
+		if class.DesignSpeedKnots <= 0 {
+			return fmt.Errorf("size class %q: design_speed_knots must be positive", class.Name)
+		}
+		last := i == len(c.SizeClasses)-1
+		switch {
+		case class.MaxLengthMeters == 0 && !last:
+			return fmt.Errorf("size class %q: only the last class may have no max_length_m", class.Name)
+		case class.MaxLengthMeters != 0 && class.MaxLengthMeters <= previous:
+			return fmt.Errorf("size class %q: max_length_m must increase from class to class", class.Name)
+		}
+		previous = class.MaxLengthMeters
+	}
+	if !names[c.UnknownClass] {
+		return fmt.Errorf("unknown_class %q is not a size class", c.UnknownClass)
+	}
+
+	f := c.Factors
+	if f.SFOCGramsPerKWh <= 0 {
+		return fmt.Errorf("factors: sfoc_g_per_kwh must be positive")
+	}
+	if f.CO2PerKgFuel < 0 || f.NOxPerKgFuel < 0 || f.SOxPerKgFuel < 0 || f.PMPerKgFuel < 0 {
+		return fmt.Errorf("factors must not be negative")
+	}
+	if c.MinLoad < 0 || c.MinLoad > 1 {
+		return fmt.Errorf("min_load must be between 0 and 1")
+	}
+	if c.RestSpeedKnots < 0 {
+		return fmt.Errorf("rest_speed_knots must not be negative")
+	}
+	return nil
+}
+
+// sizeClass returns the class of a vessel of the given length in meters
+func (c EmissionConfig) sizeClass(length float64) EmissionSizeClass {
+	if length <= 0 {
+		for _, class := range c.SizeClasses {
+			if class.Name == c.UnknownClass {
+				return class
+			}
+		}
+	}
+	for _, class := range c.SizeClasses {
+		if class.MaxLengthMeters == 0 || length <= class.MaxLengthMeters {
+			return class
+		}
+	}
+	return c.SizeClasses[len(c.SizeClasses)-1]
+}
+
+// estimate returns the energy drawn and the emissions of a vessel of class
+// sailing at speed knots for hours
+func (c EmissionConfig) estimate(class EmissionSizeClass, speed, hours float64) models.EmissionTotals {
+	load := 0.0
+	if speed >= c.RestSpeedKnots {
+		load = math.Min(1, math.Max(c.MinLoad, math.Pow(speed/class.DesignSpeedKnots, 3)))
+	}
+
+	energy := (class.MainEngineKW*load + class.AuxiliaryKW) * hours
+	fuel := energy * c.Factors.SFOCGramsPerKWh / 1000
+	return models.EmissionTotals{
+		VesselHours: hours,
+		EnergyKWh:   energy,
+		FuelKg:      fuel,
+		CO2Kg:       fuel * c.Factors.CO2PerKgFuel,
+		NOxKg:       fuel * c.Factors.NOxPerKgFuel / 1000,
+		SOxKg:       fuel * c.Factors.SOxPerKgFuel / 1000,
+		PMKg:        fuel * c.Factors.PMPerKgFuel / 1000,
+	}
+}
+
+// EmissionService estimates the fuel burn and emissions of vessels inside a
+// park from their speed and size class, aggregated per month for the park's
+// environmental reporting
+type EmissionService struct {
+	db     *gorm.DB
+	config EmissionConfig
+}
+
+func NewEmissionService(config EmissionConfig) *EmissionService {
+	return &EmissionService{
+		db:     database.GetDB(),
+		config: config,
+	}
+}
+
+// Config returns the size classes and factors in use
+func (s *EmissionService) Config() EmissionConfig {
+	return s.config
+}
+
+// estimateDay estimates per size class the emissions of the vessels inside a
+// park between start and end. Each interval between consecutive fixes of a
+// vessel, up to maxDwellGap, is attributed to the earlier fix and counted
+// when that fix is inside the park, at its speed; fixes after end close the
+// intervals still open at end.
+func (s *EmissionService) estimateDay(ctx context.Context, db *gorm.DB, parkID uint, start, end time.Time) ([]models.DailyParkEmission, error) {
+	rows, err := db.WithContext(ctx).Model(&models.VesselPositionRecord{}).
+		Select("vessel_uuid, is_in_park, on_land, speed, recorded_at").
+		Where("park_id = ? AND recorded_at >= ? AND recorded_at < ?", parkID, start, end.Add(maxDwellGap)).
+		Order("vessel_uuid, recorded_at").
+		Rows()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load positions: %w", err)
+	}
+	defer rows.Close()
+
+	type fix struct {
+		VesselUUID string
+		IsInPark   bool
+		OnLand     bool
+		Speed      float64
+		RecordedAt time.Time
+	}
+	// hours inside the park per vessel and speed of the earlier fix
+	type interval struct {
+		speed, hours float64
+	}
+	intervals := make(map[string][]interval)
+
+	var previous *fix
+	for rows.Next() {
+		var sample fix
+		if err := db.ScanRows(rows, &sample); err != nil {
+			return nil, fmt.Errorf("failed to read positions: %w", err)
+		}
+		if sample.OnLand {
+			// A GPS error says nothing about where the vessel was
+			continue
+		}
+
+		if previous != nil && previous.VesselUUID == sample.VesselUUID && previous.IsInPark && previous.RecordedAt.Before(end) {
+			gap := sample.RecordedAt.Sub(previous.RecordedAt)
+			if gap > 0 && gap <= maxDwellGap {
+				intervals[sample.VesselUUID] = append(intervals[sample.VesselUUID], interval{speed: previous.Speed, hours: gap.Hours()})
+			}
+		}
+		current := sample
+		previous = &current
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read positions: %w", err)
+	}
+	if len(intervals) == 0 {
+		return nil, nil
+	}
+
+	uuids := make([]string, 0, len(intervals))
+	for uuid := range intervals {
+		uuids = append(uuids, uuid)
+	}
+	var vessels []models.VesselRecord
+	if err := db.WithContext(ctx).Select("uuid, length").Where("uuid IN ?", uuids).Find(&vessels).Error; err != nil {
+		return nil, fmt.Errorf("failed to load vessel lengths: %w", err)
+	}
+	lengths := make(map[string]float64, len(vessels))
+	for _, vessel := range vessels {
+		lengths[vessel.UUID] = vessel.Length
+	}
+
+	byClass := make(map[string]*models.DailyParkEmission)
+	for uuid, vesselIntervals := range intervals {
+		class := s.config.sizeClass(lengths[uuid])
+		emission, ok := byClass[class.Name]
+		if !ok {
+			emission = &models.DailyParkEmission{
+				ParkID:    parkID,
+				Day:       start.Format(aggregateDayFormat),
+				SizeClass: class.Name,
+			}
+			byClass[class.Name] = emission
+		}
+
+		emission.Totals.VesselDays++
+		for _, iv := range vesselIntervals {
+			emission.Totals.Add(s.config.estimate(class, iv.speed, iv.hours))
+		}
+	}
+
+	emissions := make([]models.DailyParkEmission, 0, len(byClass))
+	for _, emission := range byClass {
+		emissions = append(emissions, *emission)
+	}
+	sort.Slice(emissions, func(i, j int) bool {
+		return emissions[i].SizeClass < emissions[j].SizeClass
+	})
+	return emissions, nil
+}
+
+// finalizeDay stores the estimate of a park for the UTC day starting at
+// start, within tx so it is stored together with the day's aggregate
+func (s *EmissionService) finalizeDay(ctx context.Context, tx *gorm.DB, parkID uint, start time.Time) error {
+	emissions, err := s.estimateDay(ctx, tx, parkID, start, start.AddDate(0, 0, 1))
+	if err != nil {
+		return err
+	}
+	if len(emissions) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	for i := range emissions {
+		emissions[i].FinalizedAt = now
+	}
+	if err := tx.WithContext(ctx).Create(&emissions).Error; err != nil {
+		return fmt.Errorf("failed to store emissions: %w", err)
+	}
+	return nil
+}
+
+// ParseEmissionMonth parses a YYYY-MM month into its first instant in UTC
+func ParseEmissionMonth(value string) (time.Time, error) {
+	month, err := time.Parse(emissionMonthFormat, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("month %q must be YYYY-MM", value)
+	}
+	return month, nil
+}
+
+// GetMonthlyReport estimates the emissions of a park for each month from
+// from to to, inclusive. Finalized days are read from their stored estimate;
+// the days of the current retention window not finalized yet are estimated
+// from their positions.
+func (s *EmissionService) GetMonthlyReport(ctx context.Context, park *Park, from, to time.Time) (*models.EmissionReport, error) {
+	from = time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, time.UTC)
+	to = time.Date(to.Year(), to.Month(), 1, 0, 0, 0, 0, time.UTC)
+	end := to.AddDate(0, 1, 0)
+	now := time.Now()
+
+	report := &models.EmissionReport{
+		Park:        park.Record.Slug,
+		From:        from.Format(emissionMonthFormat),
+		To:          to.Format(emissionMonthFormat),
+		Months:      []models.EmissionMonth{},
+		GeneratedAt: now,
+	}
+
+	var stored []models.DailyParkEmission
+	err := s.db.WithContext(ctx).
+		Where("park_id = ? AND day >= ? AND day < ?", park.Record.ID, from.Format(aggregateDayFormat), end.Format(aggregateDayFormat)).
+		Find(&stored).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch stored emissions: %w", err)
+	}
+	byDay := make(map[string][]models.DailyParkEmission)
+	for _, emission := range stored {
+		byDay[emission.Day] = append(byDay[emission.Day], emission)
+	}
+
+	// A day is finalized together with its aggregate, even when no vessel
+	// entered the park and no estimate was stored
+	var finalized []string
+	err = s.db.WithContext(ctx).Model(&models.DailyParkAggregate{}).
+		Where("park_id = ? AND day >= ? AND day < ?", park.Record.ID, from.Format(aggregateDayFormat), end.Format(aggregateDayFormat)).
+		Pluck("day", &finalized).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load finalized days: %w", err)
+	}
+	isFinalized := make(map[string]bool, len(finalized)+len(byDay))
+	for _, day := range finalized {
+		isFinalized[day] = true
+	}
+	for day := range byDay {
+		isFinalized[day] = true
+	}
+
+	// Days before the oldest position stored have nothing left to estimate
+	var oldest []models.VesselPositionRecord
+	err = s.db.WithContext(ctx).Select("recorded_at").
+		Where("park_id = ?", park.Record.ID).
+		Order("recorded_at ASC").
+		Limit(1).
+		Find(&oldest).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the oldest position: %w", err)
+	}
+	positionsFrom := now
+	if len(oldest) > 0 {
+		positionsFrom = oldest[0].RecordedAt
+	}
+
+	for month := from; month.Before(end); month = month.AddDate(0, 1, 0) {
+		summary := models.EmissionMonth{Month: month.Format(emissionMonthFormat)}
+		classes := make(map[string]*models.EmissionClassTotals)
+		add := func(emission models.DailyParkEmission) {
+			class, ok := classes[emission.SizeClass]
+			if !ok {
+				class = &models.EmissionClassTotals{SizeClass: emission.SizeClass}
+				classes[emission.SizeClass] = class
+			}
+			class.Totals.Add(emission.Totals)
+			summary.Totals.Add(emission.Totals)
+		}
+
+		complete := true
+		for day := month; day.Before(month.AddDate(0, 1, 0)); day = day.AddDate(0, 0, 1) {
+			if !day.Before(now) {
+				complete = false
+				break
+			}
+			key := day.Format(aggregateDayFormat)
+			if isFinalized[key] {
+				summary.DaysFinalized++
+				for _, emission := range byDay[key] {
+					add(emission)
+				}
+				continue
+			}
+
+			complete = false
+			if !day.AddDate(0, 0, 1).After(positionsFrom) {
+				continue
+			}
+			emissions, err := s.estimateDay(ctx, s.db, park.Record.ID, day, day.AddDate(0, 0, 1))
+			if err != nil {
+				return nil, fmt.Errorf("failed to estimate %s: %w", key, err)
+			}
+			if len(emissions) > 0 {
+				summary.DaysEstimated++
+			}
+			for _, emission := range emissions {
+				add(emission)
+			}
+		}
+		summary.Final = complete
+
+		summary.Classes = make([]models.EmissionClassTotals, 0, len(classes))
+		for _, class := range s.config.SizeClasses {
+			if totals, ok := classes[class.Name]; ok {
+				summary.Classes = append(summary.Classes, *totals)
+				delete(classes, class.Name)
+			}
+		}
+		// Classes stored under size classes since removed from the config
+		removed := make([]models.EmissionClassTotals, 0, len(classes))
+		for _, totals := range classes {
+			removed = append(removed, *totals)
+		}
+		sort.Slice(removed, func(i, j int) bool {
+			return removed[i].SizeClass < removed[j].SizeClass
+		})
+		summary.Classes = append(summary.Classes, removed...)
+
+		report.Totals.Add(summary.Totals)
+		report.Months = append(report.Months, summary)
+	}
+
+	return report, nil
+}
+
+// RenderEmissionCSV writes one row per month and size class of the report,
+// followed by the month's total under size class "all"
+func RenderEmissionCSV(report *models.EmissionReport) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	w.Write([]string{"month", "size_class", "final", "vessel_days", "vessel_hours", "energy_kwh", "fuel_kg", "co2_kg", "nox_kg", "sox_kg", "pm_kg"})
+	row := func(month models.EmissionMonth, sizeClass string, t models.EmissionTotals) {
+		w.Write([]string{
+			month.Month,
+			sizeClass,
+			strconv.FormatBool(month.Final),
+			strconv.Itoa(t.VesselDays),
+			strconv.FormatFloat(t.VesselHours, 'f', 2, 64),
+			strconv.FormatFloat(t.EnergyKWh, 'f', 1, 64),
+			strconv.FormatFloat(t.FuelKg, 'f', 1, 64),
+			strconv.FormatFloat(t.CO2Kg, 'f', 1, 64),
+			strconv.FormatFloat(t.NOxKg, 'f', 3, 64),
+			strconv.FormatFloat(t.SOxKg, 'f', 3, 64),
+			strconv.FormatFloat(t.PMKg, 'f', 3, 64),
+		})
+	}
+	for _, month := range report.Months {
+		for _, class := range month.Classes {
+			row(month, class.SizeClass, class.Totals)
+		}
+		row(month, "all", month.Totals)
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("failed to write report: %w", err)
+	}
+	return buf.Bytes(), nil
+}
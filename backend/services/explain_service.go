@@ -0,0 +1,186 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"vessel-tracker/database"
+	"vessel-tracker/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrParkNotMonitored is returned when a stored position belongs to a park
+// that is no longer configured, so its boundaries cannot be evaluated
+var ErrParkNotMonitored = errors.New("the park of this position is no longer monitored")
+
+// ExplainService re-runs violation detection for stored positions and reports
+// every step, to review disputed violations
+type ExplainService struct {
+	db               *gorm.DB
+	parks            *ParkRegistry
+	whitelistService *WhitelistService
+}
+
+func NewExplainService(parks *ParkRegistry, whitelistService *WhitelistService) *ExplainService {
+	return &ExplainService{
+		db:               database.GetDB(),
+		parks:            parks,
+		whitelistService: whitelistService,
+	}
+}
+
+// ExplainPosition evaluates a stored position against the park boundaries,
+// the whitelist and the violation rules as they are loaded now, and links the
+// violations recorded for it. It returns gorm.ErrRecordNotFound for an
+// unknown position.
+func (s *ExplainService) ExplainPosition(ctx context.Context, positionID uint) (*models.PositionExplanation, error) {
+	var record models.VesselPositionRecord
+	if err := s.db.WithContext(ctx).Preload("Vessel").First(&record, positionID).Error; err != nil {
+		return nil, err
+	}
+
+	park, ok := s.parks.GetByID(record.ParkID)
+	if !ok {
+		return nil, ErrParkNotMonitored
+	}
+
+	pos := models.VesselPosition{
+		UUID:         record.VesselUUID,
+		Name:         record.Vessel.Name,
+		MMSI:         record.Vessel.MMSI,
+		IMO:          record.Vessel.IMO,
+		Latitude:     record.Latitude,
+		Longitude:    record.Longitude,
+		Speed:        record.Speed,
+		Course:       record.Course,
+		Heading:      record.Heading,
+		LastPosEpoch: record.LastPosEpoch,
+		LastPosUTC:   record.LastPosUTC,
+	}
+
+	explanation := &models.PositionExplanation{
+		Position:    record,
+		Park:        park.Record,
+		EvaluatedAt: time.Now(),
+		Notes:       []string{},
+	}
+
+	zones := park.Geo.classify(pos)
+	explanation.Zones = []models.ZoneMatch{
+		s.zoneMatch(park, LayerPark, zones.InPark, pos),
+		s.zoneMatch(park, LayerBuffer, zones.InBufferZone, pos),
+	}
+	if zones.InPark != record.IsInPark {
+		explanation.Notes = append(explanation.Notes, fmt.Sprintf("The position was stored with is_in_park=%t but evaluates to %t now; the park boundaries have changed since", record.IsInPark, zones.InPark))
+	}
+	if zones.OnLand {
+		explanation.Notes = append(explanation.Notes, fmt.Sprintf("The position plots on land, more than %.0f m from the coastline; it is treated as a GPS error and not as presence in any zone", park.Geo.LandMask().ToleranceMeters()))
+	}
+	if !park.Geo.BufferZoneAvailable() {
+		explanation.Notes = append(explanation.Notes, "The buffer zone layer is not loaded, so no position is in the buffer zone")
+	}
+
+	entry, matchedBy := s.whitelistService.MatchWhitelistEntry(pos.UUID, pos.MMSI, pos.IMO)
+	explanation.Whitelist = models.WhitelistDecision{
+		VesselUUID:  pos.UUID,
+		MMSI:        pos.MMSI,
+		IMO:         pos.IMO,
+		Whitelisted: entry != nil,
+		MatchedBy:   matchedBy,
+		Entry:       entry,
+		CheckedAt:   time.Now(),
+	}
+	explanation.Notes = append(explanation.Notes, "Boundaries, zone rules and whitelist are evaluated as loaded now, which may differ from when the position was stored")
+
+	vessel := newRuleVessel(pos, &record.Vessel)
+	explanation.Rules = evaluateRules(pos, vessel, zones, park.Rules, parkSpeedLimit)
+	matched := false
+	for i := range explanation.Rules {
+		rule := &explanation.Rules[i]
+		if explanation.Whitelist.Whitelisted {
+			if !exceptWhitelisted(rule, park.WhitelistExceptions, vessel) {
+				rule.Evaluated = false
+				rule.Matched = false
+				rule.Reason = fmt.Sprintf("Skipped: vessel is whitelisted by %s", matchedBy)
+			}
+		}
+		if !rule.Matched {
+			continue
+		}
+		matched = true
+
+		note, err := s.linkViolation(rule, record)
+		if err != nil {
+			return nil, err
+		}
+		if note != "" {
+			explanation.Notes = append(explanation.Notes, note)
+		}
+	}
+
+	switch {
+	case matched:
+		explanation.Classification = models.ClassificationViolation
+	case explanation.Whitelist.Whitelisted:
+		explanation.Classification = models.ClassificationWhitelisted
+	case zones.InPark || zones.InBufferZone:
+		explanation.Classification = models.ClassificationCompliant
+	default:
+		explanation.Classification = models.ClassificationOutsidePark
+	}
+
+	return explanation, nil
+}
+
+// zoneMatch describes how a position relates to one boundary layer of a park
+func (s *ExplainService) zoneMatch(park *Park, layer string, matched bool, pos models.VesselPosition) models.ZoneMatch {
+	zone := models.ZoneMatch{
+		Layer:   layer,
+		Loaded:  layer == LayerPark || park.Geo.BufferZoneAvailable(),
+		Matched: matched,
+	}
+
+	if evidence := park.Geo.ZoneEvidence(layer, pos.Latitude, pos.Longitude); evidence != nil {
+		distance := evidence.DistanceToBoundaryMeters
+		zone.Version = evidence.Version
+		zone.Inside = evidence.Inside
+		zone.DistanceToBoundaryMeters = &distance
+		zone.Properties = evidence.Properties
+	}
+
+	return zone
+}
+
+// linkViolation sets the violation recorded for a position under a matched
+// rule. Detection records a violation at the position's coordinates after
+// storing it; when there is none, an earlier open violation of the same type
+// usually suppressed it, which the returned note points to.
+func (s *ExplainService) linkViolation(rule *models.RuleEvaluation, record models.VesselPositionRecord) (string, error) {
+	var violation models.Violation
+	err := s.db.Where("vessel_uuid = ? AND park_id = ? AND type = ? AND latitude = ? AND longitude = ? AND detected_at >= ?",
+		record.VesselUUID, record.ParkID, rule.Rule, record.Latitude, record.Longitude, record.RecordedAt).
+		Order("detected_at ASC").
+		First(&violation).Error
+	if err == nil {
+		rule.ViolationID = &violation.ID
+		return "", nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", fmt.Errorf("failed to look up %s violation: %w", rule.Rule, err)
+	}
+
+	err = s.db.Where("vessel_uuid = ? AND park_id = ? AND type = ? AND detected_at < ?",
+		record.VesselUUID, record.ParkID, rule.Rule, record.RecordedAt).
+		Order("detected_at DESC").
+		First(&violation).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Sprintf("No %s violation was recorded for this position", rule.Rule), nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to look up %s violation: %w", rule.Rule, err)
+	}
+
+	return fmt.Sprintf("No new %s violation was recorded; violation %d of this type was detected earlier, at %s", rule.Rule, violation.ID, violation.DetectedAt.UTC().Format(time.RFC3339)), nil
+}
@@ -0,0 +1,222 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"vessel-tracker/logging"
+
+	"gorm.io/gorm"
+)
+
+// Faults that can be injected, each with the probability of firing per
+// request or statement
+const (
+	FaultProvider429       = "provider_429"       // Datalastic answers 429 Too Many Requests
+	FaultProviderMalformed = "provider_malformed" // Datalastic answers 200 with an unparseable body
+	FaultProviderTimeout   = "provider_timeout"   // the Datalastic request times out
+	FaultDBTimeout         = "db_timeout"         // a database statement times out
+)
+
+var knownFaults = map[string]bool{
+	FaultProvider429:       true,
+	FaultProviderMalformed: true,
+	FaultProviderTimeout:   true,
+	FaultDBTimeout:         true,
+}
+
+// FaultConfig maps fault names to the probability (0-1) of injecting them.
+// An empty config disables fault injection.
+type FaultConfig map[string]float64
+
+// LoadFaultConfig reads FAULT_INJECTION, a comma separated list of
+// fault:probability pairs such as "provider_429:0.3,db_timeout:0.05". It is
+// meant for exercising retry and degraded-mode handling outside production.
+func LoadFaultConfig() (FaultConfig, error) {
+	config := FaultConfig{}
+
+	value := os.Getenv("FAULT_INJECTION")
+	if value == "" {
+		return config, nil
+	}
+
+	for _, entry := range strings.Split(value, ",") {
+		name, rate, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid FAULT_INJECTION entry %q: expected fault:probability", entry)
+		}
+		if !knownFaults[name] {
+			return nil, fmt.Errorf("invalid FAULT_INJECTION entry %q: unknown fault %q", entry, name)
+		}
+
+		p, err := strconv.ParseFloat(rate, 64)
+		if err != nil || p < 0 || p > 1 {
+			return nil, fmt.Errorf("invalid FAULT_INJECTION entry %q: probability must be between 0 and 1", entry)
+		}
+		config[name] = p
+	}
+
+	return config, nil
+}
+
+// Enabled reports whether any fault has a non-zero probability
+func (c FaultConfig) Enabled() bool {
+	for _, p := range c {
+		if p > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (c FaultConfig) String() string {
+	names := make([]string, 0, len(c))
+	for name := range c {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s:%g", name, c[name]))
+	}
+	return strings.Join(parts, ",")
+}
+
+// FaultInjector decides which requests fail
+type FaultInjector struct {
+	config FaultConfig
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+func NewFaultInjector(config FaultConfig, seed int64) *FaultInjector {
+	return &FaultInjector{
+		config: config,
+		rng:    rand.New(rand.NewSource(seed)),
+	}
+}
+
+// fire reports whether the named fault should be injected now
+func (f *FaultInjector) fire(name string) bool {
+	p := f.config[name]
+	if p <= 0 {
+		return false
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.rng.Float64() < p
+}
+
+// injectedTimeout satisfies net.Error so callers treat it like a real timeout
+type injectedTimeout struct{ what string }
+
+func (e injectedTimeout) Error() string   { return "injected fault: " + e.what + " timed out" }
+func (e injectedTimeout) Timeout() bool   { return true }
+func (e injectedTimeout) Temporary() bool { return true }
+
+// Transport wraps an HTTP transport so provider requests fail according to
+// the provider faults
+func (f *FaultInjector) Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return faultTransport{injector: f, next: next}
+}
+
+type faultTransport struct {
+	injector *FaultInjector
+	next     http.RoundTripper
+}
+
+func (t faultTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	switch {
+	case t.injector.fire(FaultProviderTimeout):
+		return nil, injectedTimeout{what: "provider request"}
+	case t.injector.fire(FaultProvider429):
+		return fakeResponse(req, http.StatusTooManyRequests, `{"error":"injected fault: rate limited"}`), nil
+	case t.injector.fire(FaultProviderMalformed):
+		return fakeResponse(req, http.StatusOK, `{"data": {"vessels": [`), nil
+	}
+	return t.next.RoundTrip(req)
+}
+
+func fakeResponse(req *http.Request, status int, body string) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(bytes.NewBufferString(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// RegisterDB makes database statements fail according to the db_timeout
+// fault. The error is added before the statement runs, so nothing reaches
+// the database.
+func (f *FaultInjector) RegisterDB(db *gorm.DB) error {
+	inject := func(tx *gorm.DB) {
+		if f.fire(FaultDBTimeout) {
+			tx.AddError(injectedTimeout{what: "database statement"})
+		}
+	}
+
+	callbacks := db.Callback()
+	registrations := []struct {
+		before string
+		add    func(string, func(*gorm.DB)) error
+	}{
+		{"gorm:query", callbacks.Query().Before("gorm:query").Register},
+		{"gorm:create", callbacks.Create().Before("gorm:create").Register},
+		{"gorm:update", callbacks.Update().Before("gorm:update").Register},
+		{"gorm:delete", callbacks.Delete().Before("gorm:delete").Register},
+		{"gorm:row", callbacks.Row().Before("gorm:row").Register},
+		{"gorm:raw", callbacks.Raw().Before("gorm:raw").Register},
+	}
+	for _, r := range registrations {
+		if err := r.add("faults:"+strings.TrimPrefix(r.before, "gorm:"), inject); err != nil {
+			return fmt.Errorf("failed to register fault callback before %s: %w", r.before, err)
+		}
+	}
+
+	return nil
+}
+
+// EnableFaultInjection wires the configured faults into the Datalastic
+// client and the database. It logs loudly since it must never be left on by
+// accident.
+func EnableFaultInjection(config FaultConfig, provider VesselDataProvider, db *gorm.DB) error {
+	if !config.Enabled() {
+		return nil
+	}
+
+	injector := NewFaultInjector(config, rand.Int63())
+	if datalastic, ok := provider.(*DatalasticProvider); ok {
+		datalastic.client.Transport = injector.Transport(datalastic.client.Transport)
+	} else {
+		for name := range config {
+			if strings.HasPrefix(name, "provider_") {
+				return fmt.Errorf("fault %s requires VESSEL_PROVIDER=datalastic", name)
+			}
+		}
+	}
+	if err := injector.RegisterDB(db); err != nil {
+		return err
+	}
+
+	logging.Component("fault_injection").Warn("Fault injection enabled - do not run this configuration in production", "faults", config.String())
+	return nil
+}
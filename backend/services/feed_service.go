@@ -0,0 +1,418 @@
+package services
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+	"vessel-tracker/database"
+	"vessel-tracker/models"
+
+	"gorm.io/gorm"
+)
+
+// Days of daily summaries a park statistics feed covers by default and at most
+const (
+	DefaultFeedDays = 30
+	MaxFeedDays     = 90
+)
+
+// Feed formats
+const (
+	FeedFormatAtom = "atom"
+	FeedFormatRSS  = "rss"
+	FeedFormatJSON = "json"
+)
+
+// feedViolationLabels name the violation types in feed entries
+var feedViolationLabels = map[string]string{
+	models.ViolationInBufferZone:        "in the buffer zone",
+	models.ViolationInRestrictedArea:    "in a restricted area",
+	models.ViolationExcessiveSpeed:      "for excessive speed",
+	models.ViolationAnchoredOnPosidonia: "for anchoring on posidonia meadows",
+	models.ViolationWatchlistedVessel:   "for watchlisted vessel sightings",
+	models.ViolationProjectedIntrusion:  "for projected intrusions",
+	models.ViolationAnchorDragging:      "for dragging anchor",
+}
+
+// FeedService builds the public feed of a park's daily statistics, which
+// the park's communications office can syndicate to its website and
+// newsletter
+type FeedService struct {
+	db         *gorm.DB
+	aggregates *DailyAggregateService
+}
+
+func NewFeedService(aggregates *DailyAggregateService) *FeedService {
+	return &FeedService{
+		db:         database.GetDB(),
+		aggregates: aggregates,
+	}
+}
+
+// GetDailySummaries returns the summaries of the finalized days of a park
+// among the last days UTC days, newest first
+func (s *FeedService) GetDailySummaries(park *Park, days int) ([]models.ParkDaySummary, error) {
+	end := time.Now().UTC().Truncate(24 * time.Hour)
+	start := end.AddDate(0, 0, -days)
+
+	aggregates, err := s.aggregates.GetDailyAggregates(park.Record.ID, start, end.Add(-time.Nanosecond))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch daily aggregates: %w", err)
+	}
+
+	var violations []struct {
+		Type       string
+		DetectedAt time.Time
+	}
+	err = s.db.Model(&models.Violation{}).
+		Select("type, detected_at").
+		Where("park_id = ? AND detected_at >= ? AND detected_at < ?", park.Record.ID, start, end).
+		Scan(&violations).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch violations: %w", err)
+	}
+	byType := make(map[string]map[string]int64)
+	for _, violation := range violations {
+		day := violation.DetectedAt.UTC().Format(aggregateDayFormat)
+		if byType[day] == nil {
+			byType[day] = make(map[string]int64)
+		}
+		byType[day][violation.Type]++
+	}
+
+	var layers []models.HabitatLayer
+	err = s.db.Where("park_id = ? AND created_at >= ? AND created_at < ?", park.Record.ID, start, end).
+		Where("active_from IS NOT NULL OR active_until IS NOT NULL").
+		Order("id").
+		Find(&layers).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch habitat layers: %w", err)
+	}
+	zones := make(map[string][]models.FeedZone)
+	for _, layer := range layers {
+		day := layer.CreatedAt.UTC().Format(aggregateDayFormat)
+		zones[day] = append(zones[day], models.FeedZone{
+			ID:          layer.ID,
+			Name:        layer.Name,
+			Kind:        layer.Kind,
+			ActiveFrom:  layer.ActiveFrom,
+			ActiveUntil: layer.ActiveUntil,
+		})
+	}
+
+	summaries := make([]models.ParkDaySummary, 0, len(aggregates))
+	for i := len(aggregates) - 1; i >= 0; i-- {
+		aggregate := aggregates[i]
+		summary := models.ParkDaySummary{
+			Park:             park.Record.Slug,
+			Day:              aggregate.Day,
+			Vessels:          aggregate.Vessels,
+			VesselsInPark:    aggregate.VesselsInPark,
+			ParkEntries:      aggregate.ParkEntries,
+			MaxSpeedKnots:    aggregate.MaxSpeedKnots,
+			Violations:       aggregate.Violations,
+			ViolationsByType: byType[aggregate.Day],
+			NewZones:         zones[aggregate.Day],
+			FinalizedAt:      aggregate.FinalizedAt,
+		}
+		if summary.ViolationsByType == nil {
+			summary.ViolationsByType = map[string]int64{}
+		}
+		if summary.NewZones == nil {
+			summary.NewZones = []models.FeedZone{}
+		}
+		summaries = append(summaries, summary)
+	}
+
+	return summaries, nil
+}
+
+// Feed is a park statistics feed, rendered as Atom, RSS or JSON Feed
+type Feed struct {
+	ID      string
+	Title   string
+	Author  string
+	HomeURL string // the park's website, or the tracker's
+	FeedURL string
+	Updated time.Time
+	Items   []FeedItem
+}
+
+// FeedItem is the entry of one day
+type FeedItem struct {
+	ID        string
+	Title     string
+	URL       string // the day's statistics
+	Published time.Time
+	Text      string
+	Summary   models.ParkDaySummary
+}
+
+// NewParkFeed lays out the daily summaries of a park as a feed. baseURL is
+// the tracker's address, which links are made absolute against.
+func NewParkFeed(park *Park, summaries []models.ParkDaySummary, baseURL, feedURL string) *Feed {
+	title := park.Site.Title
+	if title == "" {
+		title = park.Record.Name
+	}
+	author := park.Site.Contact.Organization
+	if author == "" {
+		author = park.Record.Name
+	}
+	home := park.Site.Contact.URL
+	if home == "" {
+		home = baseURL + "/"
+	}
+
+	feed := &Feed{
+		ID:      "urn:vessel-tracker:" + park.Record.Slug + ":daily",
+		Title:   title + " daily statistics",
+		Author:  author,
+		HomeURL: home,
+		FeedURL: feedURL,
+		Updated: time.Now().UTC(),
+		Items:   make([]FeedItem, 0, len(summaries)),
+	}
+	if len(summaries) > 0 {
+		feed.Updated = summaries[0].FinalizedAt.UTC()
+	}
+
+	for _, summary := range summaries {
+		day, _ := time.Parse(aggregateDayFormat, summary.Day)
+		query := url.Values{
+			"park":  {park.Record.Slug},
+			"start": {day.Format(time.RFC3339)},
+			"end":   {day.Add(24*time.Hour - time.Second).Format(time.RFC3339)},
+		}
+		feed.Items = append(feed.Items, FeedItem{
+			ID:        feed.ID + ":" + summary.Day,
+			Title:     fmt.Sprintf("%s: %d vessels, %d in the park", day.Format("2 January 2006"), summary.Vessels, summary.VesselsInPark),
+			URL:       baseURL + "/api/stats/daily?" + query.Encode(),
+			Published: summary.FinalizedAt.UTC(),
+			Text:      describeParkDay(summary),
+			Summary:   summary,
+		})
+	}
+
+	return feed
+}
+
+// describeParkDay writes the text of a day's entry
+func describeParkDay(summary models.ParkDaySummary) string {
+	var lines []string
+	lines = append(lines, fmt.Sprintf("%d vessels were tracked around the park, %d of them inside it, with %d entries into the park.",
+		summary.Vessels, summary.VesselsInPark, summary.ParkEntries))
+	if summary.MaxSpeedKnots > 0 {
+		lines = append(lines, fmt.Sprintf("The fastest vessel sailed at %.1f kn.", summary.MaxSpeedKnots))
+	}
+
+	if summary.Violations == 0 {
+		lines = append(lines, "No violations were recorded.")
+	} else {
+		types := make([]string, 0, len(summary.ViolationsByType))
+		for violationType := range summary.ViolationsByType {
+			types = append(types, violationType)
+		}
+		sort.Strings(types)
+
+		var parts []string
+		for _, violationType := range types {
+			label, ok := feedViolationLabels[violationType]
+			if !ok {
+				label = "of type " + violationType
+			}
+			parts = append(parts, fmt.Sprintf("%d %s", summary.ViolationsByType[violationType], label))
+		}
+		line := fmt.Sprintf("%d violations were recorded", summary.Violations)
+		if len(parts) > 0 {
+			line += ": " + strings.Join(parts, ", ")
+		}
+		lines = append(lines, line+".")
+	}
+
+	for _, zone := range summary.NewZones {
+		line := fmt.Sprintf("New temporary zone: %s (%s)", zone.Name, zone.Kind)
+		if zone.ActiveFrom != nil {
+			line += ", in force from " + zone.ActiveFrom.UTC().Format("2 January 2006")
+		}
+		if zone.ActiveUntil != nil {
+			line += ", until " + zone.ActiveUntil.UTC().Format("2 January 2006")
+		}
+		lines = append(lines, line+".")
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID        string   `xml:"id"`
+	Title     string   `xml:"title"`
+	Updated   string   `xml:"updated"`
+	Published string   `xml:"published"`
+	Link      atomLink `xml:"link"`
+	Content   atomText `xml:"content"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// RenderFeedAtom writes the feed as an Atom document
+func RenderFeedAtom(feed *Feed) ([]byte, error) {
+	doc := atomFeed{
+		ID:      feed.ID,
+		Title:   feed.Title,
+		Updated: feed.Updated.Format(time.RFC3339),
+		Author:  atomAuthor{Name: feed.Author},
+		Links: []atomLink{
+			{Href: feed.FeedURL, Rel: "self", Type: "application/atom+xml"},
+			{Href: feed.HomeURL, Rel: "alternate"},
+		},
+	}
+	for _, item := range feed.Items {
+		published := item.Published.Format(time.RFC3339)
+		doc.Entries = append(doc.Entries, atomEntry{
+			ID:        item.ID,
+			Title:     item.Title,
+			Updated:   published,
+			Published: published,
+			Link:      atomLink{Href: item.URL, Rel: "alternate", Type: "application/json"},
+			Content:   atomText{Type: "text", Body: item.Text},
+		})
+	}
+	return marshalFeedXML(doc)
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+	Description string  `xml:"description"`
+}
+
+type rssGUID struct {
+	IsPermaLink string `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// RenderFeedRSS writes the feed as an RSS 2.0 document
+func RenderFeedRSS(feed *Feed) ([]byte, error) {
+	doc := rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:         feed.Title,
+			Link:          feed.HomeURL,
+			Description:   "Daily vessel traffic, violations and new temporary zones of " + feed.Author,
+			LastBuildDate: feed.Updated.Format(time.RFC1123Z),
+		},
+	}
+	for _, item := range feed.Items {
+		doc.Channel.Items = append(doc.Channel.Items, rssItem{
+			Title:       item.Title,
+			Link:        item.URL,
+			GUID:        rssGUID{IsPermaLink: "false", Value: item.ID},
+			PubDate:     item.Published.Format(time.RFC1123Z),
+			Description: item.Text,
+		})
+	}
+	return marshalFeedXML(doc)
+}
+
+func marshalFeedXML(doc interface{}) ([]byte, error) {
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to write feed: %w", err)
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+type jsonFeed struct {
+	Version     string           `json:"version"`
+	Title       string           `json:"title"`
+	HomePageURL string           `json:"home_page_url"`
+	FeedURL     string           `json:"feed_url"`
+	Authors     []jsonFeedAuthor `json:"authors"`
+	Items       []jsonFeedItem   `json:"items"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+// jsonFeedItem carries the figures of the day in the _park_stats extension,
+// for sites that lay the entry out themselves
+type jsonFeedItem struct {
+	ID            string                `json:"id"`
+	URL           string                `json:"url"`
+	Title         string                `json:"title"`
+	ContentText   string                `json:"content_text"`
+	DatePublished time.Time             `json:"date_published"`
+	ParkStats     models.ParkDaySummary `json:"_park_stats"`
+}
+
+// RenderFeedJSON writes the feed as a JSON Feed 1.1 document
+func RenderFeedJSON(feed *Feed) ([]byte, error) {
+	doc := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       feed.Title,
+		HomePageURL: feed.HomeURL,
+		FeedURL:     feed.FeedURL,
+		Authors:     []jsonFeedAuthor{{Name: feed.Author}},
+		Items:       make([]jsonFeedItem, 0, len(feed.Items)),
+	}
+	for _, item := range feed.Items {
+		doc.Items = append(doc.Items, jsonFeedItem{
+			ID:            item.ID,
+			URL:           item.URL,
+			Title:         item.Title,
+			ContentText:   item.Text,
+			DatePublished: item.Published,
+			ParkStats:     item.Summary,
+		})
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write feed: %w", err)
+	}
+	return data, nil
+}
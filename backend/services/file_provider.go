@@ -0,0 +1,273 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"vessel-tracker/models"
+)
+
+// FileProvider serves vessel data from a JSON file holding an array of
+// positions in the Datalastic vessel_inradius format. Several positions of a
+// vessel (same uuid, different last_position_epoch) form its track; queries
+// for current positions use the latest. The file is read on every request,
+// so it can be edited to play scenarios back without a restart.
+type FileProvider struct {
+	path string
+}
+
+func NewFileProvider(path string) *FileProvider {
+	return &FileProvider{path: path}
+}
+
+func (p *FileProvider) Name() string {
+	return ProviderFile
+}
+
+func (p *FileProvider) load() ([]models.VesselPosition, error) {
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vessel file: %w", err)
+	}
+
+	var positions []models.VesselPosition
+	if err := json.Unmarshal(data, &positions); err != nil {
+		return nil, fmt.Errorf("failed to decode vessel file: %w", err)
+	}
+	for i, pos := range positions {
+		if pos.UUID == "" {
+			return nil, fmt.Errorf("position %d has no uuid", i)
+		}
+	}
+
+	return positions, nil
+}
+
+// latest returns the most recent position of each vessel, ordered by UUID
+func (p *FileProvider) latest() ([]models.VesselPosition, error) {
+	positions, err := p.load()
+	if err != nil {
+		return nil, err
+	}
+
+	byUUID := make(map[string]models.VesselPosition, len(positions))
+	for _, pos := range positions {
+		if current, ok := byUUID[pos.UUID]; !ok || pos.LastPosEpoch > current.LastPosEpoch {
+			byUUID[pos.UUID] = pos
+		}
+	}
+
+	latest := make([]models.VesselPosition, 0, len(byUUID))
+	for _, pos := range byUUID {
+		latest = append(latest, pos)
+	}
+	sort.Slice(latest, func(i, j int) bool { return latest[i].UUID < latest[j].UUID })
+	return latest, nil
+}
+
+func (p *FileProvider) GetVesselsInRadius(ctx context.Context, lat, lon float64, radius int) (*models.VesselPositionResponse, error) {
+	latest, err := p.latest()
+	if err != nil {
+		return nil, err
+	}
+
+	vessels := []models.VesselPosition{}
+	for _, pos := range latest {
+		distance := HaversineDistance(lat, lon, pos.Latitude, pos.Longitude) / metersPerNauticalMile
+		if distance > float64(radius) {
+			continue
+		}
+		pos.Distance = distance
+		vessels = append(vessels, pos)
+	}
+
+	return &models.VesselPositionResponse{
+		Data: models.VesselPositionData{
+			Point:   map[string]interface{}{"lat": lat, "lon": lon, "radius": radius},
+			Total:   len(vessels),
+			Vessels: vessels,
+		},
+	}, nil
+}
+
+// SearchVessels matches the latest positions against the search parameters
+func (p *FileProvider) SearchVessels(ctx context.Context, params map[string]string) (*models.VesselResponse, error) {
+	latest, err := p.latest()
+	if err != nil {
+		return nil, err
+	}
+
+	vessels := []models.Vessel{}
+	for _, pos := range latest {
+		if matchesVesselQuery(params, pos) {
+			vessels = append(vessels, vesselFromPosition(pos))
+		}
+	}
+
+	return &models.VesselResponse{
+		Data: vessels,
+		Meta: models.Meta{Endpoint: "vessel_find", Success: true},
+	}, nil
+}
+
+// GetHistory returns the track of the vessel identified by uuid, mmsi or imo
+// over the last days (2 by default) or between from and to (dates, or
+// RFC3339 times)
+func (p *FileProvider) GetHistory(ctx context.Context, params map[string]string) (*models.VesselHistoryResponse, error) {
+	start, end, err := historyWindow(params, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	positions, err := p.load()
+	if err != nil {
+		return nil, err
+	}
+
+	var history models.VesselHistoryData
+	for _, pos := range positions {
+		switch {
+		case params["uuid"] != "" && params["uuid"] != pos.UUID:
+			continue
+		case params["mmsi"] != "" && params["mmsi"] != pos.MMSI:
+			continue
+		case params["imo"] != "" && params["imo"] != pos.IMO:
+			continue
+		}
+
+		if history.UUID == "" {
+			history = models.VesselHistoryData{
+				UUID:         pos.UUID,
+				Name:         pos.Name,
+				MMSI:         pos.MMSI,
+				IMO:          pos.IMO,
+				CountryISO:   pos.CountryISO,
+				Type:         pos.Type,
+				TypeSpecific: pos.TypeSpecific,
+			}
+		}
+		if pos.UUID != history.UUID {
+			continue
+		}
+
+		recordedAt := time.Unix(pos.LastPosEpoch, 0)
+		if recordedAt.Before(start) || !recordedAt.Before(end) {
+			continue
+		}
+		history.Positions = append(history.Positions, models.VesselHistoryPosition{
+			Latitude:          pos.Latitude,
+			Longitude:         pos.Longitude,
+			Speed:             pos.Speed,
+			Course:            pos.Course,
+			Heading:           pos.Heading,
+			Destination:       pos.Destination,
+			LastPositionEpoch: pos.LastPosEpoch,
+			LastPositionUTC:   pos.LastPosUTC,
+		})
+	}
+
+	sort.Slice(history.Positions, func(i, j int) bool {
+		return history.Positions[i].LastPositionEpoch < history.Positions[j].LastPositionEpoch
+	})
+
+	return &models.VesselHistoryResponse{
+		Data: history,
+		Meta: models.Meta{Endpoint: "vessel_history", Success: true},
+	}, nil
+}
+
+// historyWindow reads the Datalastic history period parameters: days, or a
+// from/to pair of dates where to is inclusive
+func historyWindow(params map[string]string, now time.Time) (time.Time, time.Time, error) {
+	if params["from"] != "" && params["to"] != "" {
+		start, err := parseHistoryTime(params["from"], false)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid from %q: %w", params["from"], err)
+		}
+		end, err := parseHistoryTime(params["to"], true)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid to %q: %w", params["to"], err)
+		}
+		return start, end, nil
+	}
+
+	days := 2
+	if params["days"] != "" {
+		parsed, err := strconv.Atoi(params["days"])
+		if err != nil || parsed < 1 {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid days %q: must be a positive integer", params["days"])
+		}
+		days = parsed
+	}
+	return now.AddDate(0, 0, -days), now.Add(time.Second), nil
+}
+
+// parseHistoryTime parses a date or an RFC3339 time. A date used as the end
+// of a period covers the whole day.
+func parseHistoryTime(value string, end bool) (time.Time, error) {
+	if date, err := time.Parse("2006-01-02", value); err == nil {
+		if end {
+			date = date.AddDate(0, 0, 1)
+		}
+		return date, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// matchesVesselQuery matches a position by name (a case-insensitive
+// substring), comma separated types, country_iso, uuid, mmsi and imo, for
+// providers that search the positions they hold
+func matchesVesselQuery(params map[string]string, pos models.VesselPosition) bool {
+	switch {
+	case params["name"] != "" && !strings.Contains(strings.ToLower(pos.Name), strings.ToLower(params["name"])):
+		return false
+	case params["type"] != "" && !containsFold(strings.Split(params["type"], ","), pos.Type):
+		return false
+	case params["country_iso"] != "" && !strings.EqualFold(params["country_iso"], pos.CountryISO):
+		return false
+	case params["uuid"] != "" && params["uuid"] != pos.UUID:
+		return false
+	case params["mmsi"] != "" && params["mmsi"] != pos.MMSI:
+		return false
+	case params["imo"] != "" && params["imo"] != pos.IMO:
+		return false
+	}
+	return true
+}
+
+// vesselFromPosition fills the vessel_find format from a position
+func vesselFromPosition(pos models.VesselPosition) models.Vessel {
+	return models.Vessel{
+		UUID:         pos.UUID,
+		Name:         pos.Name,
+		NameAIS:      pos.Name,
+		MMSI:         pos.MMSI,
+		IMO:          pos.IMO,
+		CountryISO:   pos.CountryISO,
+		Type:         pos.Type,
+		TypeSpecific: pos.TypeSpecific,
+		Latitude:     pos.Latitude,
+		Longitude:    pos.Longitude,
+		LastUpdated:  pos.LastPosUTC,
+	}
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(strings.TrimSpace(v), value) {
+			return true
+		}
+	}
+	return false
+}
+
+// Ping checks that the file can be read and decoded
+func (p *FileProvider) Ping(ctx context.Context) error {
+	_, err := p.load()
+	return err
+}
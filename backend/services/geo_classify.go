@@ -0,0 +1,137 @@
+package services
+
+import (
+	"runtime"
+	"sync"
+	"time"
+	"vessel-tracker/models"
+)
+
+// minParallelClassify is the batch size below which positions are classified
+// on the calling goroutine; for smaller batches the worker hand-off costs more
+// than the polygon tests it spreads out
+const minParallelClassify = 64
+
+// PositionZones is the classification of a position against the loaded
+// boundary layers, with the tidal current predicted there when the position
+// was reported. A position on land is a GPS error and is in no zone.
+type PositionZones struct {
+	InPark       bool
+	InBufferZone bool
+	OnLand       bool
+	Current      *models.TidalCurrent
+}
+
+// defaultClassifyWorkers uses one worker per CPU available to the process
+func defaultClassifyWorkers() int {
+	return runtime.GOMAXPROCS(0)
+}
+
+// SetClassifyWorkers changes the number of goroutines ClassifyPositions uses.
+// Values below 1 reset it to the default.
+func (s *GeoService) SetClassifyWorkers(workers int) {
+	if workers < 1 {
+		workers = defaultClassifyWorkers()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.classifyWorkers = workers
+}
+
+func (s *GeoService) workers() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.classifyWorkers
+}
+
+// ClassifyPositions checks every position against the land mask and the park
+// and buffer zone boundaries. Large batches are split across a bounded pool of workers; the
+// result is in the same order as the input.
+func (s *GeoService) ClassifyPositions(positions []models.VesselPosition) []PositionZones {
+	zones := make([]PositionZones, len(positions))
+
+	workers := s.workers()
+	if workers > len(positions)/minParallelClassify {
+		workers = len(positions) / minParallelClassify
+	}
+
+	if workers <= 1 {
+		for i := range positions {
+			zones[i] = s.classify(positions[i])
+		}
+		return zones
+	}
+
+	// Each worker classifies a contiguous chunk and writes only its own slots
+	chunk := (len(positions) + workers - 1) / workers
+
+	var wg sync.WaitGroup
+	for start := 0; start < len(positions); start += chunk {
+		end := start + chunk
+		if end > len(positions) {
+			end = len(positions)
+		}
+
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			for i := start; i < end; i++ {
+				zones[i] = s.classify(positions[i])
+			}
+		}(start, end)
+	}
+	wg.Wait()
+
+	return zones
+}
+
+// SetLandMask sets the land mask positions are checked against; nil turns
+// the check off
+func (s *GeoService) SetLandMask(mask *LandMask) {
+	s.mu.Lock()
+	s.landMask = mask
+	s.mu.Unlock()
+}
+
+// LandMask returns the land mask positions are checked against, or nil
+func (s *GeoService) LandMask() *LandMask {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.landMask
+}
+
+// SetTidalCurrents sets the tidal current predictions attached to
+// positions; nil attaches none
+func (s *GeoService) SetTidalCurrents(currents *TidalCurrents) {
+	s.mu.Lock()
+	s.currents = currents
+	s.mu.Unlock()
+}
+
+// TidalCurrents returns the tidal current predictions, or nil
+func (s *GeoService) TidalCurrents() *TidalCurrents {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.currents
+}
+
+// positionTime returns when a position was reported, or now when the
+// provider did not say
+func positionTime(pos models.VesselPosition) time.Time {
+	if pos.LastPosEpoch > 0 {
+		return time.Unix(pos.LastPosEpoch, 0)
+	}
+	return time.Now()
+}
+
+func (s *GeoService) classify(pos models.VesselPosition) PositionZones {
+	if s.LandMask().OnLand(pos.Latitude, pos.Longitude) {
+		return PositionZones{OnLand: true}
+	}
+	return PositionZones{
+		InPark:       s.IsPointInPark(pos.Latitude, pos.Longitude),
+		InBufferZone: s.IsPointInBufferZone(pos.Latitude, pos.Longitude),
+		Current:      s.TidalCurrents().At(pos.Latitude, pos.Longitude, positionTime(pos)),
+	}
+}
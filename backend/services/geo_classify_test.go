@@ -0,0 +1,103 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+	"vessel-tracker/models"
+)
+
+// classifyBatchSize is large enough for ClassifyPositions to use the pool
+const classifyBatchSize = 2000
+
+func newTestGeoService(t testing.TB) *GeoService {
+	t.Helper()
+	geoService, err := NewGeoService("../data/national-park.geojson", "../data/buffered.geojson", DefaultBoundaryConfig())
+	if err != nil {
+		t.Fatalf("NewGeoService: %v", err)
+	}
+	return geoService
+}
+
+// syntheticPositions scatters positions uniformly over the disc of radiusNM
+// nautical miles around the park center, so a batch mixes points inside the
+// park, inside the buffer zone and outside both
+func syntheticPositions(geoService *GeoService, count int, radiusNM float64) []models.VesselPosition {
+	const earthRadius = 6371000.0
+
+	centerLat, centerLon := geoService.GetParkCenter()
+	rng := rand.New(rand.NewSource(1))
+	radiusMeters := radiusNM * 1852
+
+	positions := make([]models.VesselPosition, count)
+	for i := range positions {
+		distance := radiusMeters * math.Sqrt(rng.Float64())
+		bearing := rng.Float64() * 2 * math.Pi
+
+		dLat := distance * math.Cos(bearing) / earthRadius
+		dLon := distance * math.Sin(bearing) / (earthRadius * math.Cos(centerLat*math.Pi/180))
+
+		positions[i] = models.VesselPosition{
+			UUID:      fmt.Sprintf("synthetic-%06d", i),
+			Latitude:  centerLat + dLat*180/math.Pi,
+			Longitude: centerLon + dLon*180/math.Pi,
+		}
+	}
+	return positions
+}
+
+func TestClassifyPositionsPooledMatchesSerial(t *testing.T) {
+	geoService := newTestGeoService(t)
+	positions := syntheticPositions(geoService, classifyBatchSize, 20)
+
+	geoService.SetClassifyWorkers(1)
+	serial := geoService.ClassifyPositions(positions)
+	geoService.SetClassifyWorkers(8)
+	pooled := geoService.ClassifyPositions(positions)
+
+	if !reflect.DeepEqual(serial, pooled) {
+		for i := range serial {
+			if !reflect.DeepEqual(serial[i], pooled[i]) {
+				t.Fatalf("position %d classified as %+v serially and %+v pooled", i, serial[i], pooled[i])
+			}
+		}
+		t.Fatalf("pooled classification differs from serial")
+	}
+
+	var inPark, inBuffer int
+	for _, zones := range serial {
+		if zones.InPark {
+			inPark++
+		}
+		if zones.InBufferZone {
+			inBuffer++
+		}
+	}
+	if inPark == 0 || inPark == len(serial) || inBuffer == 0 {
+		t.Fatalf("batch should mix zones, got %d in park and %d in buffer zone of %d", inPark, inBuffer, len(serial))
+	}
+}
+
+func BenchmarkClassifyPositions(b *testing.B) {
+	geoService := newTestGeoService(b)
+	positions := syntheticPositions(geoService, classifyBatchSize, 20)
+
+	for _, bench := range []struct {
+		name    string
+		workers int
+	}{
+		{"serial", 1},
+		{"pooled", 0}, // one worker per CPU
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			geoService.SetClassifyWorkers(bench.workers)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				geoService.ClassifyPositions(positions)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"time"
+	"vessel-tracker/models"
+
+	geojson "github.com/paulmach/go.geojson"
+)
+
+// LoadedHabitatLayer is an uploaded habitat layer with its parsed features
+type LoadedHabitatLayer struct {
+	Layer    models.HabitatLayer
+	Features *geojson.FeatureCollection
+}
+
+// activeAt reports whether the layer applies at t
+func (l LoadedHabitatLayer) activeAt(t time.Time) bool {
+	if l.Layer.ActiveFrom != nil && t.Before(*l.Layer.ActiveFrom) {
+		return false
+	}
+	if l.Layer.ActiveUntil != nil && !t.Before(*l.Layer.ActiveUntil) {
+		return false
+	}
+	return true
+}
+
+// SetHabitatLayers replaces the uploaded habitat layers of the park
+func (s *GeoService) SetHabitatLayers(layers []LoadedHabitatLayer) {
+	s.mu.Lock()
+	s.habitatLayers = layers
+	s.mu.Unlock()
+}
+
+// HabitatLayersAt returns the habitat layers active at t with a polygon
+// containing the point, such as the closure areas in force at that time
+func (s *GeoService) HabitatLayersAt(lat, lon float64, at time.Time) []models.HabitatLayer {
+	s.mu.RLock()
+	layers := s.habitatLayers
+	s.mu.RUnlock()
+
+	point := []float64{lon, lat}
+	matches := []models.HabitatLayer{}
+	for _, layer := range layers {
+		if !layer.activeAt(at) {
+			continue
+		}
+		for _, feature := range layer.Features.Features {
+			if s.isPointInFeature(point, feature) {
+				matches = append(matches, layer.Layer)
+				break
+			}
+		}
+	}
+	return matches
+}
@@ -4,73 +4,687 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
+	"math"
 	"os"
+	"strconv"
+	"sync"
+	"time"
+	"vessel-tracker/logging"
 
 	geojson "github.com/paulmach/go.geojson"
 )
 
+// BoundaryDistance describes the nearest point on a set of zone boundaries
+type BoundaryDistance struct {
+	DistanceMeters float64 `json:"distance_meters"`
+	NearestLat     float64 `json:"nearest_latitude"`
+	NearestLon     float64 `json:"nearest_longitude"`
+}
+
+// DefaultParkBufferMeters is the tolerance around park boundaries used when
+// neither PARK_BUFFER_METERS nor a zone's buffer_meters property is set
+const DefaultParkBufferMeters = 500.0
+
+// BoundaryConfig holds the deployment-wide settings of the park boundaries.
+// A park's own buffer and expected region take precedence.
+type BoundaryConfig struct {
+	BufferMeters      float64 // tolerance around park boundaries when a zone sets no buffer_meters
+	ExpectedRegion    Region  // area the boundary layers must lie in
+	StrictRegionCheck bool    // refuse to start when park boundaries fail the region check
+	ClassifyWorkers   int     // goroutines classifying a batch of positions
+	GridCellDegrees   float64 // side of a zone grid cell, 0 disables the grid
+}
+
+func DefaultBoundaryConfig() BoundaryConfig {
+	return BoundaryConfig{
+		BufferMeters:    DefaultParkBufferMeters,
+		ExpectedRegion:  DefaultExpectedRegion,
+		ClassifyWorkers: defaultClassifyWorkers(),
+		GridCellDegrees: DefaultZoneGridCellDegrees,
+	}
+}
+
+// LoadBoundaryConfig reads PARK_BUFFER_METERS, EXPECTED_REGION_BBOX,
+// STRICT_REGION_CHECK, CLASSIFY_WORKERS (default one per CPU) and
+// ZONE_GRID_CELL_DEGREES, falling back to the defaults when unset
+func LoadBoundaryConfig() (BoundaryConfig, error) {
+	config := DefaultBoundaryConfig()
+
+	floatVars := map[string]*float64{
+		"PARK_BUFFER_METERS":     &config.BufferMeters,
+		"ZONE_GRID_CELL_DEGREES": &config.GridCellDegrees,
+	}
+	for name, target := range floatVars {
+		if value := os.Getenv(name); value != "" {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil || parsed < 0 {
+				return config, fmt.Errorf("invalid %s %q: must be a non-negative number", name, value)
+			}
+			*target = parsed
+		}
+	}
+
+	if value := os.Getenv("EXPECTED_REGION_BBOX"); value != "" {
+		region, err := ParseRegion(value)
+		if err != nil {
+			return config, fmt.Errorf("invalid EXPECTED_REGION_BBOX %q: %w", value, err)
+		}
+		config.ExpectedRegion = region
+	}
+
+	if value := os.Getenv("STRICT_REGION_CHECK"); value != "" {
+		strict, err := strconv.ParseBool(value)
+		if err != nil {
+			return config, fmt.Errorf("invalid STRICT_REGION_CHECK %q: must be true or false", value)
+		}
+		config.StrictRegionCheck = strict
+	}
+
+	if value := os.Getenv("CLASSIFY_WORKERS"); value != "" {
+		workers, err := strconv.Atoi(value)
+		if err != nil || workers < 1 {
+			return config, fmt.Errorf("invalid CLASSIFY_WORKERS %q: must be a positive integer", value)
+		}
+		config.ClassifyWorkers = workers
+	}
+
+	return config, nil
+}
+
+// Boundary layers that can be replaced by an import
+const (
+	LayerPark   = "park"
+	LayerBuffer = "buffer"
+)
+
 type GeoService struct {
-	parkBoundaries     *geojson.FeatureCollection
-	bufferedBoundaries *geojson.FeatureCollection
+	mu                  sync.RWMutex
+	parkBoundaries      *geojson.FeatureCollection
+	bufferedBoundaries  *geojson.FeatureCollection
+	parkPath            string
+	bufferedPath        string
+	defaultBufferMeters float64
+	centerLat           *float64
+	centerLon           *float64
+	expectedRegion      Region
+	regionChecks        map[string]RegionCheck
+	classifyWorkers     int
+	gridCellDegrees     float64
+	parkGrid            *zoneGrid
+	bufferGrid          *zoneGrid
+	parkPayload         *StaticPayload
+	bufferPayload       *StaticPayload
+	layerFiles          map[string]fileStamp // file version each layer was last read from
+	reloadMu            sync.Mutex
+	layerStatus         map[string]LayerStatus
+	layerAlert          func(LayerStatus)
+	habitatLayers       []LoadedHabitatLayer
+	landMask            *LandMask
+	currents            *TidalCurrents
+	logger              *slog.Logger
+}
+
+// LayerStatus reports whether a boundary layer is loaded. While the buffer
+// layer is unavailable no point is in the buffer zone, so buffer zone
+// violations cannot be detected.
+type LayerStatus struct {
+	Layer     string    `json:"layer"`
+	Available bool      `json:"available"`
+	Path      string    `json:"path,omitempty"`
+	Features  int       `json:"features"`
+	Error     string    `json:"error,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
-func NewGeoService(geojsonPath string, bufferedPath string) (*GeoService, error) {
+// RegionCheck reports whether a boundary layer lies inside the expected region.
+// A layer outside it usually means a wrong or corrupted file, which would make
+// every vessel appear to be outside the park.
+type RegionCheck struct {
+	Layer       string    `json:"layer"`
+	Loaded      bool      `json:"loaded"`
+	Features    int       `json:"features"`
+	BBox        []float64 `json:"bbox,omitempty"`
+	InRegionPct float64   `json:"in_region_pct"`
+	OK          bool      `json:"ok"`
+	Message     string    `json:"message,omitempty"`
+	CheckedAt   time.Time `json:"checked_at"`
+}
+
+// GeoConfig describes the boundaries of one park. Each layer is read from a
+// GeoJSON file unless its geometry is given inline; inline layers cannot be
+// written back by ReplaceBoundaries and are only replaced in memory.
+type GeoConfig struct {
+	Name         string // identifies the park in logs
+	ParkPath     string
+	BufferedPath string
+	Park         *geojson.FeatureCollection
+	Buffered     *geojson.FeatureCollection
+
+	// Defaults holds the deployment-wide settings
+	Defaults BoundaryConfig
+
+	// BufferMeters overrides Defaults.BufferMeters for this park
+	BufferMeters *float64
+
+	// CenterLat and CenterLon override the center computed from the park
+	// boundaries, which vessel fetches are centered on
+	CenterLat *float64
+	CenterLon *float64
+
+	// ExpectedRegion overrides Defaults.ExpectedRegion for this park
+	ExpectedRegion *Region
+}
+
+func NewGeoService(geojsonPath string, bufferedPath string, defaults BoundaryConfig) (*GeoService, error) {
+	return NewGeoServiceFromConfig(GeoConfig{ParkPath: geojsonPath, BufferedPath: bufferedPath, Defaults: defaults})
+}
+
+// NewGeoServiceFromConfig loads the boundaries described by config
+func NewGeoServiceFromConfig(config GeoConfig) (*GeoService, error) {
+	logger := logging.Component("geo")
+	if config.Name != "" {
+		logger = logger.With("park", config.Name)
+	}
+
 	// Load park boundaries
-	file, err := os.Open(geojsonPath)
+	fc := config.Park
+	if fc == nil {
+		file, err := os.Open(config.ParkPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open geojson file: %w", err)
+		}
+		defer file.Close()
+
+		data, err := io.ReadAll(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read geojson file: %w", err)
+		}
+
+		fc, err = geojson.UnmarshalFeatureCollection(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse geojson: %w", err)
+		}
+	} else if len(fc.Features) == 0 {
+		return nil, fmt.Errorf("park boundaries contain no features")
+	}
+
+	// Load buffered boundaries. A missing or broken file leaves the buffer
+	// layer unavailable rather than failing startup; the status is reported by
+	// LayerStatuses and raised through the layer alert.
+	bufferedFC, bufferErr := config.Buffered, error(nil)
+	if bufferedFC == nil {
+		bufferedFC, bufferErr = loadBoundaryFile(config.BufferedPath)
+	} else if len(bufferedFC.Features) == 0 {
+		bufferedFC, bufferErr = nil, fmt.Errorf("buffered boundaries contain no features")
+	}
+	if bufferErr != nil {
+		logger.Error("ALERT: buffer zone boundaries unavailable; buffer zone violations will not be detected", "path", config.BufferedPath, "error", bufferErr)
+	} else {
+		logger.Info("Loaded buffered boundaries", "features", len(bufferedFC.Features))
+	}
+
+	bufferMeters := config.Defaults.BufferMeters
+	if config.BufferMeters != nil {
+		if *config.BufferMeters < 0 {
+			return nil, fmt.Errorf("invalid buffer of %g meters: must be non-negative", *config.BufferMeters)
+		}
+		bufferMeters = *config.BufferMeters
+	}
+
+	region := config.Defaults.ExpectedRegion
+	if config.ExpectedRegion != nil {
+		region = *config.ExpectedRegion
+	}
+
+	classifyWorkers := config.Defaults.ClassifyWorkers
+	if classifyWorkers < 1 {
+		classifyWorkers = defaultClassifyWorkers()
+	}
+
+	s := &GeoService{
+		parkBoundaries:      fc,
+		bufferedBoundaries:  bufferedFC,
+		parkPath:            config.ParkPath,
+		bufferedPath:        config.BufferedPath,
+		centerLat:           config.CenterLat,
+		centerLon:           config.CenterLon,
+		defaultBufferMeters: bufferMeters,
+		expectedRegion:      region,
+		regionChecks:        make(map[string]RegionCheck),
+		layerFiles:          make(map[string]fileStamp),
+		layerStatus:         make(map[string]LayerStatus),
+		classifyWorkers:     classifyWorkers,
+		gridCellDegrees:     config.Defaults.GridCellDegrees,
+		logger:              logger,
+	}
+	s.parkGrid = s.buildGrid(LayerPark, fc)
+	s.bufferGrid = s.buildGrid(LayerBuffer, bufferedFC)
+
+	loadedAt := time.Now()
+	if config.Park == nil {
+		s.layerFiles[LayerPark], _ = statFile(config.ParkPath)
+	}
+	if config.Buffered == nil && config.BufferedPath != "" {
+		s.layerFiles[LayerBuffer], _ = statFile(config.BufferedPath)
+	}
+
+	var err error
+	s.parkPayload, err = newBoundaryPayload(fc, s.layerModTime(LayerPark, loadedAt))
 	if err != nil {
-		return nil, fmt.Errorf("failed to open geojson file: %w", err)
+		return nil, err
+	}
+	if bufferedFC != nil {
+		s.bufferPayload, err = newBoundaryPayload(bufferedFC, s.layerModTime(LayerBuffer, loadedAt))
+		if err != nil {
+			return nil, err
+		}
 	}
-	defer file.Close()
 
-	data, err := io.ReadAll(file)
+	s.setLayerStatus(LayerPark, fc, nil)
+	s.setLayerStatus(LayerBuffer, bufferedFC, bufferErr)
+
+	parkCheck := s.checkRegion(LayerPark, fc)
+	s.checkRegion(LayerBuffer, bufferedFC)
+
+	if !parkCheck.OK && config.Defaults.StrictRegionCheck {
+		return nil, fmt.Errorf("park boundaries failed the region check: %s", parkCheck.Message)
+	}
+
+	return s, nil
+}
+
+// loadBoundaryFile reads a GeoJSON FeatureCollection from path
+func loadBoundaryFile(path string) (*geojson.FeatureCollection, error) {
+	if path == "" {
+		return nil, fmt.Errorf("no boundaries file configured")
+	}
+
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read geojson file: %w", err)
+		return nil, fmt.Errorf("failed to read boundaries file: %w", err)
 	}
 
 	fc, err := geojson.UnmarshalFeatureCollection(data)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse geojson: %w", err)
+		return nil, fmt.Errorf("failed to parse boundaries GeoJSON: %w", err)
+	}
+	if len(fc.Features) == 0 {
+		return nil, fmt.Errorf("boundaries file contains no features")
 	}
 
-	// Load buffered boundaries
-	var bufferedFC *geojson.FeatureCollection
-	if bufferedPath != "" {
-		bufferedFile, err := os.Open(bufferedPath)
-		if err != nil {
-			fmt.Printf("Warning: Failed to open buffered boundaries file %s: %v\n", bufferedPath, err)
-		} else {
-			defer bufferedFile.Close()
-			bufferedData, err := io.ReadAll(bufferedFile)
-			if err != nil {
-				fmt.Printf("Warning: Failed to read buffered boundaries file: %v\n", err)
-			} else {
-				bufferedFC, err = geojson.UnmarshalFeatureCollection(bufferedData)
-				if err != nil {
-					fmt.Printf("Warning: Failed to parse buffered boundaries GeoJSON: %v\n", err)
-				} else {
-					fmt.Printf("Successfully loaded buffered boundaries with %d features\n", len(bufferedFC.Features))
-				}
+	return fc, nil
+}
+
+// setLayerStatus records whether a layer is loaded and raises the layer
+// alert when it became unavailable
+func (s *GeoService) setLayerStatus(layer string, fc *geojson.FeatureCollection, loadErr error) {
+	status := LayerStatus{
+		Layer:     layer,
+		Available: fc != nil && loadErr == nil,
+		UpdatedAt: time.Now(),
+	}
+	if layer == LayerPark {
+		status.Path = s.parkPath
+	} else {
+		status.Path = s.bufferedPath
+	}
+	if fc != nil {
+		status.Features = len(fc.Features)
+	}
+	if loadErr != nil {
+		status.Error = loadErr.Error()
+	}
+
+	s.mu.Lock()
+	s.layerStatus[layer] = status
+	alert := s.layerAlert
+	s.mu.Unlock()
+
+	if !status.Available && alert != nil {
+		alert(status)
+	}
+}
+
+// SetLayerAlert registers a function called whenever a boundary layer is
+// unavailable, so enforcement gaps reach administrators instead of only the
+// log. Layers that are already unavailable are reported right away.
+func (s *GeoService) SetLayerAlert(alert func(LayerStatus)) {
+	s.mu.Lock()
+	s.layerAlert = alert
+	s.mu.Unlock()
+
+	for _, status := range s.LayerStatuses() {
+		if !status.Available {
+			alert(status)
+		}
+	}
+}
+
+// LayerStatuses returns the load status of each boundary layer
+func (s *GeoService) LayerStatuses() []LayerStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	statuses := make([]LayerStatus, 0, len(s.layerStatus))
+	for _, layer := range []string{LayerPark, LayerBuffer} {
+		if status, ok := s.layerStatus[layer]; ok {
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses
+}
+
+// BufferZoneAvailable reports whether the buffer zone layer is loaded. When
+// it is not, IsPointInBufferZone always returns false.
+func (s *GeoService) BufferZoneAvailable() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.layerStatus[LayerBuffer].Available
+}
+
+// checkRegion verifies that every vertex of a layer lies in the expected
+// region, records the result and logs a prominent alert when it does not
+func (s *GeoService) checkRegion(layer string, fc *geojson.FeatureCollection) RegionCheck {
+	check := RegionCheck{
+		Layer:     layer,
+		OK:        true,
+		CheckedAt: time.Now(),
+	}
+
+	if fc != nil {
+		report := AnalyzeGeoInput(fc, s.expectedRegion)
+		check.Loaded = true
+		check.Features = report.Features
+		check.BBox = report.BBox
+		check.InRegionPct = report.InRegionPct
+
+		switch {
+		case report.Coordinates == 0:
+			check.OK = false
+			check.Message = "layer contains no polygon coordinates"
+		case report.LikelySwapped:
+			check.OK = false
+			check.Message = "coordinates appear to be in [lat, lon] order"
+		case report.InRegionPct < 100:
+			check.OK = false
+			check.Message = fmt.Sprintf("only %.1f%% of vertices lie within the expected region", report.InRegionPct)
+		}
+	} else if layer == LayerPark {
+		check.OK = false
+		check.Message = "park boundaries not loaded"
+	}
+
+	if !check.OK {
+		r := s.expectedRegion
+		s.logger.Error("ALERT: boundaries failed the region check; vessel park/buffer classification is unreliable until this is fixed",
+			"layer", layer,
+			"reason", check.Message,
+			"expected_region", []float64{r.MinLon, r.MinLat, r.MaxLon, r.MaxLat},
+			"layer_bbox", check.BBox)
+	}
+
+	s.mu.Lock()
+	s.regionChecks[layer] = check
+	s.mu.Unlock()
+
+	return check
+}
+
+// BoundaryChecks returns the latest region check of each boundary layer
+func (s *GeoService) BoundaryChecks() []RegionCheck {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	checks := make([]RegionCheck, 0, len(s.regionChecks))
+	for _, layer := range []string{LayerPark, LayerBuffer} {
+		if check, ok := s.regionChecks[layer]; ok {
+			checks = append(checks, check)
+		}
+	}
+	return checks
+}
+
+// BoundariesHealthy reports whether every boundary layer is loaded and passed
+// its region check
+func (s *GeoService) BoundariesHealthy() bool {
+	for _, status := range s.LayerStatuses() {
+		if !status.Available {
+			return false
+		}
+	}
+	for _, check := range s.BoundaryChecks() {
+		if !check.OK {
+			return false
+		}
+	}
+	return true
+}
+
+// park and buffer return the current boundary layers; imports replace them
+// as a whole so callers can keep using a snapshot without holding the lock
+func (s *GeoService) park() *geojson.FeatureCollection {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.parkBoundaries
+}
+
+func (s *GeoService) buffer() *geojson.FeatureCollection {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.bufferedBoundaries
+}
+
+// buildGrid precomputes the zone grid of a layer. Park cells within a zone's
+// buffer distance of its boundary count as boundary cells, so IsPointInPark
+// keeps its near-boundary tolerance.
+func (s *GeoService) buildGrid(layer string, fc *geojson.FeatureCollection) *zoneGrid {
+	if fc == nil {
+		return nil
+	}
+
+	zones := make([]zoneRings, 0, len(fc.Features))
+	for _, feature := range fc.Features {
+		margin := 0.0
+		if layer == LayerPark {
+			margin = s.featureBufferMeters(feature)
+		}
+		zones = append(zones, featureZoneRings(feature, margin))
+	}
+
+	start := time.Now()
+	grid := buildZoneGrid(zones, s.gridCellDegrees)
+	if grid != nil {
+		stats := grid.stats(layer)
+		s.logger.Info("Built zone grid",
+			"layer", layer,
+			"rows", stats.Rows,
+			"cols", stats.Cols,
+			"cell_degrees", stats.CellDegrees,
+			"boundary_pct", stats.BoundaryPct,
+			"duration", time.Since(start).Round(time.Millisecond).String())
+	}
+	return grid
+}
+
+// grids returns the current zone grids of the park and buffer layers
+func (s *GeoService) grids() (*zoneGrid, *zoneGrid) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.parkGrid, s.bufferGrid
+}
+
+// ZoneGridStats describes the precomputed grid of each loaded layer
+func (s *GeoService) ZoneGridStats() []ZoneGridStats {
+	parkGrid, bufferGrid := s.grids()
+
+	stats := make([]ZoneGridStats, 0, 2)
+	if parkGrid != nil {
+		stats = append(stats, parkGrid.stats(LayerPark))
+	}
+	if bufferGrid != nil {
+		stats = append(stats, bufferGrid.stats(LayerBuffer))
+	}
+	return stats
+}
+
+// ExpectedRegion returns the bounding box boundaries are expected to fall in
+func (s *GeoService) ExpectedRegion() Region {
+	return s.expectedRegion
+}
+
+// AnalyzeInput checks boundaries against the expected region
+func (s *GeoService) AnalyzeInput(fc *geojson.FeatureCollection) *GeoInputReport {
+	return AnalyzeGeoInput(fc, s.expectedRegion)
+}
+
+// ReplaceBoundaries swaps in a new boundary layer and writes it to the file
+// it was loaded from, keeping the previous file as a .bak copy
+func (s *GeoService) ReplaceBoundaries(layer string, fc *geojson.FeatureCollection) error {
+	path, err := s.layerPath(layer)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(fc, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode boundaries: %w", err)
+	}
+
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	var stamp fileStamp
+	if path != "" {
+		if previous, err := os.ReadFile(path); err == nil {
+			if err := os.WriteFile(path+".bak", previous, 0644); err != nil {
+				return fmt.Errorf("failed to back up %s: %w", path, err)
 			}
 		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		stamp, _ = statFile(path)
+	}
+
+	return s.swapLayer(layer, fc, stamp)
+}
+
+// layerPath returns the file a layer is read from, empty for an inline layer
+func (s *GeoService) layerPath(layer string) (string, error) {
+	switch layer {
+	case LayerPark:
+		return s.parkPath, nil
+	case LayerBuffer:
+		return s.bufferedPath, nil
+	default:
+		return "", fmt.Errorf("unknown boundary layer %q", layer)
 	}
+}
 
-	return &GeoService{
-		parkBoundaries:     fc,
-		bufferedBoundaries: bufferedFC,
-	}, nil
+// layerModTime returns when the file of a layer was modified, or fallback
+// for a layer that was not read from a file
+func (s *GeoService) layerModTime(layer string, fallback time.Time) time.Time {
+	if stamp := s.layerFiles[layer]; !stamp.modTime.IsZero() {
+		return stamp.modTime
+	}
+	return fallback
+}
+
+// swapLayer replaces a layer in memory along with its grid and payload. The
+// caller holds reloadMu.
+func (s *GeoService) swapLayer(layer string, fc *geojson.FeatureCollection, stamp fileStamp) error {
+	lastModified := stamp.modTime
+	if lastModified.IsZero() {
+		lastModified = time.Now()
+	}
+	payload, err := newBoundaryPayload(fc, lastModified)
+	if err != nil {
+		return err
+	}
+
+	grid := s.buildGrid(layer, fc)
+
+	s.mu.Lock()
+	if layer == LayerPark {
+		s.parkBoundaries = fc
+		s.parkGrid = grid
+		s.parkPayload = payload
+	} else {
+		s.bufferedBoundaries = fc
+		s.bufferGrid = grid
+		s.bufferPayload = payload
+	}
+	s.layerFiles[layer] = stamp
+	s.mu.Unlock()
+
+	s.setLayerStatus(layer, fc, nil)
+	s.checkRegion(layer, fc)
+
+	return nil
+}
+
+// reloadChangedLayer reads a layer again when its file was modified on disk
+// since it was last read, so edits made outside the import endpoint reach
+// both zone detection and the cached payload. A file that fails to load is
+// logged and remembered, keeping the previous layer until it changes again.
+func (s *GeoService) reloadChangedLayer(layer string) {
+	path, _ := s.layerPath(layer)
+	if path == "" {
+		return
+	}
+	stamp, err := statFile(path)
+	if err != nil {
+		return
+	}
+
+	s.mu.RLock()
+	unchanged := s.layerFiles[layer] == stamp
+	s.mu.RUnlock()
+	if unchanged {
+		return
+	}
+
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	s.mu.RLock()
+	unchanged = s.layerFiles[layer] == stamp
+	s.mu.RUnlock()
+	if unchanged {
+		return
+	}
+
+	fc, err := loadBoundaryFile(path)
+	if err == nil {
+		err = s.swapLayer(layer, fc, stamp)
+	}
+	if err != nil {
+		s.mu.Lock()
+		s.layerFiles[layer] = stamp
+		s.mu.Unlock()
+		s.logger.Error("Boundaries file changed on disk but could not be loaded, keeping the previous layer", "layer", layer, "path", path, "error", err)
+		return
+	}
+	s.logger.Info("Reloaded boundaries changed on disk", "layer", layer, "path", path, "features", len(fc.Features))
 }
 
 func (s *GeoService) IsPointInPark(lat, lon float64) bool {
+	parkGrid, _ := s.grids()
+	if inside, ok := parkGrid.lookup(lat, lon); ok {
+		return inside
+	}
+
 	point := []float64{lon, lat}
 
-	for _, feature := range s.parkBoundaries.Features {
+	for _, feature := range s.park().Features {
 		if s.isPointInFeature(point, feature) {
 			return true
 		}
 	}
 
-	// Add buffer zone check - if point is within ~500m (roughly 0.005 degrees) of park boundaries
-	return s.isPointNearPark(lat, lon, 0.005)
+	// Treat points within the zone buffer distance of the park boundaries as inside
+	return s.isPointNearPark(lat, lon)
 }
 
 func (s *GeoService) isPointInFeature(point []float64, feature *geojson.Feature) bool {
@@ -116,25 +730,43 @@ func (s *GeoService) isPointInPolygon(point []float64, polygon [][]float64) bool
 	return inside
 }
 
-func (s *GeoService) GetParkBoundaries() ([]byte, error) {
-	return json.Marshal(s.parkBoundaries)
+// GetParkBoundaries returns the park boundaries as serialized when loaded,
+// reading them again first if their file changed on disk
+func (s *GeoService) GetParkBoundaries() (*StaticPayload, error) {
+	s.reloadChangedLayer(LayerPark)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.parkPayload, nil
 }
 
-func (s *GeoService) GetBufferedBoundaries() ([]byte, error) {
-	if s.bufferedBoundaries == nil {
+// GetBufferedBoundaries returns the buffer zone boundaries as serialized when
+// loaded, reading them again first if their file changed on disk
+func (s *GeoService) GetBufferedBoundaries() (*StaticPayload, error) {
+	s.reloadChangedLayer(LayerBuffer)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.bufferPayload == nil {
 		return nil, fmt.Errorf("buffered boundaries not loaded")
 	}
-	return json.Marshal(s.bufferedBoundaries)
+	return s.bufferPayload, nil
 }
 
 func (s *GeoService) IsPointInBufferZone(lat, lon float64) bool {
-	if s.bufferedBoundaries == nil {
+	buffered := s.buffer()
+	if buffered == nil {
 		return false
 	}
 
+	_, bufferGrid := s.grids()
+	if inside, ok := bufferGrid.lookup(lat, lon); ok {
+		return inside
+	}
+
 	point := []float64{lon, lat}
 
-	for _, feature := range s.bufferedBoundaries.Features {
+	for _, feature := range buffered.Features {
 		if s.isPointInFeature(point, feature) {
 			return true
 		}
@@ -144,11 +776,15 @@ func (s *GeoService) IsPointInBufferZone(lat, lon float64) bool {
 }
 
 func (s *GeoService) GetParkCenter() (float64, float64) {
+	if s.centerLat != nil && s.centerLon != nil {
+		return *s.centerLat, *s.centerLon
+	}
+
 	// Calculate the center of all park boundaries
 	var totalLat, totalLon float64
 	var count int
 
-	for _, feature := range s.parkBoundaries.Features {
+	for _, feature := range s.park().Features {
 		g := feature.Geometry
 		switch g.Type {
 		case geojson.GeometryPolygon:
@@ -182,56 +818,68 @@ func (s *GeoService) GetParkCenter() (float64, float64) {
 	return 41.2167, 9.4167
 }
 
-// isPointNearPark checks if a point is within buffer distance of any park boundary
-func (s *GeoService) isPointNearPark(lat, lon, buffer float64) bool {
-	point := []float64{lon, lat}
-
-	for _, feature := range s.parkBoundaries.Features {
-		if s.isPointNearFeature(point, feature, buffer) {
-			return true
+// GetParkBounds returns the bounding box of the park boundaries as
+// minLat, minLon, maxLat, maxLon
+func (s *GeoService) GetParkBounds() (float64, float64, float64, float64) {
+	minLat, minLon := math.MaxFloat64, math.MaxFloat64
+	maxLat, maxLon := -math.MaxFloat64, -math.MaxFloat64
+
+	for _, feature := range s.park().Features {
+		for _, ring := range geometryRings(feature.Geometry) {
+			for _, coord := range ring {
+				minLon = math.Min(minLon, coord[0])
+				maxLon = math.Max(maxLon, coord[0])
+				minLat = math.Min(minLat, coord[1])
+				maxLat = math.Max(maxLat, coord[1])
+			}
 		}
 	}
 
-	return false
+	if minLat > maxLat {
+		// No polygons loaded, fall back to a box around the park center
+		lat, lon := s.GetParkCenter()
+		return lat - 0.1, lon - 0.1, lat + 0.1, lon + 0.1
+	}
+
+	return minLat, minLon, maxLat, maxLon
 }
 
-// isPointNearFeature checks if a point is within buffer distance of a feature
-func (s *GeoService) isPointNearFeature(point []float64, feature *geojson.Feature, buffer float64) bool {
-	g := feature.Geometry
-	switch g.Type {
-	case geojson.GeometryPolygon:
-		if g.Polygon != nil && len(g.Polygon) > 0 {
-			return s.isPointNearPolygon(point, g.Polygon[0], buffer)
+// isPointNearPark checks if a point is within the configured buffer distance
+// of any park feature, measured geodesically in meters
+func (s *GeoService) isPointNearPark(lat, lon float64) bool {
+	for _, feature := range s.park().Features {
+		buffer := s.featureBufferMeters(feature)
+		if buffer <= 0 {
+			continue
 		}
-	case geojson.GeometryMultiPolygon:
-		if g.MultiPolygon != nil {
-			for _, polygon := range g.MultiPolygon {
-				if len(polygon) > 0 && s.isPointNearPolygon(point, polygon[0], buffer) {
-					return true
-				}
-			}
+
+		nearest := nearestOnRings(geometryRings(feature.Geometry), lat, lon)
+		if nearest != nil && nearest.DistanceMeters <= buffer {
+			return true
 		}
 	}
+
 	return false
 }
 
-// isPointNearPolygon checks if a point is within buffer distance of a polygon boundary
-func (s *GeoService) isPointNearPolygon(point []float64, polygon [][]float64, buffer float64) bool {
-	if len(polygon) < 2 {
-		return false
+// featureBufferMeters returns the buffer distance for a zone, taken from its
+// "buffer_meters" property when present, otherwise the service default
+func (s *GeoService) featureBufferMeters(feature *geojson.Feature) float64 {
+	if value, ok := feature.Properties["buffer_meters"]; ok {
+		if meters, ok := value.(float64); ok && meters >= 0 {
+			return meters
+		}
 	}
+	return s.defaultBufferMeters
+}
 
-	x, y := point[0], point[1]
-
-	// Check distance to each edge of the polygon
-	for i := 0; i < len(polygon); i++ {
-		j := (i + 1) % len(polygon)
-		x1, y1 := polygon[i][0], polygon[i][1]
-		x2, y2 := polygon[j][0], polygon[j][1]
+// IsPointInsideParkBoundary reports strict containment in the park polygons,
+// without the near-boundary tolerance applied by IsPointInPark
+func (s *GeoService) IsPointInsideParkBoundary(lat, lon float64) bool {
+	point := []float64{lon, lat}
 
-		// Calculate squared distance from point to line segment
-		distSquared := s.pointToLineDistance(x, y, x1, y1, x2, y2)
-		if distSquared <= buffer*buffer {
+	for _, feature := range s.park().Features {
+		if s.isPointInFeature(point, feature) {
 			return true
 		}
 	}
@@ -239,32 +887,70 @@ func (s *GeoService) isPointNearPolygon(point []float64, polygon [][]float64, bu
 	return false
 }
 
-// pointToLineDistance calculates the minimum distance from a point to a line segment
-func (s *GeoService) pointToLineDistance(px, py, x1, y1, x2, y2 float64) float64 {
-	// Vector from line start to point
-	dx1 := px - x1
-	dy1 := py - y1
+// DistanceToParkBoundary returns the geodesic distance to the nearest park boundary segment
+func (s *GeoService) DistanceToParkBoundary(lat, lon float64) *BoundaryDistance {
+	return s.nearestBoundary(s.park(), lat, lon)
+}
 
-	// Vector from line start to end
-	dx2 := x2 - x1
-	dy2 := y2 - y1
+// DistanceToBufferBoundary returns the geodesic distance to the nearest buffer zone boundary
+// segment, or nil when buffered boundaries are not loaded
+func (s *GeoService) DistanceToBufferBoundary(lat, lon float64) *BoundaryDistance {
+	buffered := s.buffer()
+	if buffered == nil {
+		return nil
+	}
+	return s.nearestBoundary(buffered, lat, lon)
+}
 
-	// Calculate the parameter t for the closest point on the line segment
-	t := (dx1*dx2 + dy1*dy2) / (dx2*dx2 + dy2*dy2)
+func (s *GeoService) nearestBoundary(fc *geojson.FeatureCollection, lat, lon float64) *BoundaryDistance {
+	var nearest *BoundaryDistance
 
-	// Clamp t to the line segment
-	if t < 0 {
-		t = 0
-	} else if t > 1 {
-		t = 1
+	for _, feature := range fc.Features {
+		candidate := nearestOnRings(geometryRings(feature.Geometry), lat, lon)
+		if candidate != nil && (nearest == nil || candidate.DistanceMeters < nearest.DistanceMeters) {
+			nearest = candidate
+		}
 	}
 
-	// Calculate the closest point on the line segment
-	closestX := x1 + t*dx2
-	closestY := y1 + t*dy2
+	return nearest
+}
 
-	// Calculate distance from point to closest point
-	dx := px - closestX
-	dy := py - closestY
-	return dx*dx + dy*dy // Return squared distance for performance (we'll compare with squared buffer)
-}
\ No newline at end of file
+// nearestOnRings finds the closest point on any segment of the given rings
+func nearestOnRings(rings [][][]float64, lat, lon float64) *BoundaryDistance {
+	var nearest *BoundaryDistance
+
+	for _, ring := range rings {
+		for i := 0; i+1 < len(ring); i++ {
+			distance, nLat, nLon := PointToSegmentDistance(lat, lon, ring[i][1], ring[i][0], ring[i+1][1], ring[i+1][0])
+			if nearest == nil || distance < nearest.DistanceMeters {
+				nearest = &BoundaryDistance{
+					DistanceMeters: distance,
+					NearestLat:     nLat,
+					NearestLon:     nLon,
+				}
+			}
+		}
+	}
+
+	return nearest
+}
+
+// geometryRings returns every polygon ring (outer boundaries and holes) of a geometry
+func geometryRings(g *geojson.Geometry) [][][]float64 {
+	if g == nil {
+		return nil
+	}
+
+	switch g.Type {
+	case geojson.GeometryPolygon:
+		return g.Polygon
+	case geojson.GeometryMultiPolygon:
+		var rings [][][]float64
+		for _, polygon := range g.MultiPolygon {
+			rings = append(rings, polygon...)
+		}
+		return rings
+	}
+
+	return nil
+}
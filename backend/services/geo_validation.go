@@ -0,0 +1,159 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	geojson "github.com/paulmach/go.geojson"
+)
+
+// Region is a bounding box in GeoJSON bbox order
+type Region struct {
+	MinLon float64 `json:"min_lon"`
+	MinLat float64 `json:"min_lat"`
+	MaxLon float64 `json:"max_lon"`
+	MaxLat float64 `json:"max_lat"`
+}
+
+// DefaultExpectedRegion surrounds the La Maddalena archipelago with a margin
+// of roughly 30 km
+var DefaultExpectedRegion = Region{MinLon: 9.0, MinLat: 40.8, MaxLon: 10.0, MaxLat: 41.6}
+
+// ParseRegion reads a "minLon,minLat,maxLon,maxLat" bounding box
+func ParseRegion(value string) (Region, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 {
+		return Region{}, fmt.Errorf("expected minLon,minLat,maxLon,maxLat")
+	}
+
+	var values [4]float64
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return Region{}, fmt.Errorf("invalid number %q", part)
+		}
+		values[i] = v
+	}
+
+	region := Region{MinLon: values[0], MinLat: values[1], MaxLon: values[2], MaxLat: values[3]}
+	if region.MinLon >= region.MaxLon || region.MinLat >= region.MaxLat {
+		return Region{}, fmt.Errorf("minimum must be below maximum")
+	}
+	if region.MinLat < -90 || region.MaxLat > 90 || region.MinLon < -180 || region.MaxLon > 180 {
+		return Region{}, fmt.Errorf("bounds outside valid coordinate ranges")
+	}
+
+	return region, nil
+}
+
+// Contains reports whether a point lies in the region
+func (r Region) Contains(lat, lon float64) bool {
+	return lat >= r.MinLat && lat <= r.MaxLat && lon >= r.MinLon && lon <= r.MaxLon
+}
+
+// GeoInputReport is the result of checking imported boundaries for
+// coordinate-order mistakes and placement outside the expected region
+type GeoInputReport struct {
+	Features           int       `json:"features"`
+	Coordinates        int       `json:"coordinates"`
+	BBox               []float64 `json:"bbox"`
+	InvalidCoordinates int       `json:"invalid_coordinates"`
+	InRegionPct        float64   `json:"in_region_pct"`
+	SwappedInRegionPct float64   `json:"swapped_in_region_pct"`
+	InRegion           bool      `json:"in_region"`
+	LikelySwapped      bool      `json:"likely_swapped"`
+	Warnings           []string  `json:"warnings"`
+}
+
+// AnalyzeGeoInput inspects every polygon vertex. Coordinates are expected in
+// GeoJSON [lon, lat] order; the input is flagged as likely swapped when most
+// vertices only fall in the expected region after exchanging the axes, or
+// when latitudes are out of range while the swapped values would be valid.
+func AnalyzeGeoInput(fc *geojson.FeatureCollection, region Region) *GeoInputReport {
+	report := &GeoInputReport{
+		Features: len(fc.Features),
+		Warnings: []string{},
+	}
+
+	minLon, minLat := math.MaxFloat64, math.MaxFloat64
+	maxLon, maxLat := -math.MaxFloat64, -math.MaxFloat64
+	var inRegion, swappedInRegion, latOutOfRange, swappedValid int
+
+	for _, feature := range fc.Features {
+		for _, ring := range geometryRings(feature.Geometry) {
+			for _, coord := range ring {
+				if len(coord) < 2 {
+					report.InvalidCoordinates++
+					continue
+				}
+
+				lon, lat := coord[0], coord[1]
+				report.Coordinates++
+
+				minLon, maxLon = math.Min(minLon, lon), math.Max(maxLon, lon)
+				minLat, maxLat = math.Min(minLat, lat), math.Max(maxLat, lat)
+
+				if lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+					report.InvalidCoordinates++
+				}
+				if lat < -90 || lat > 90 {
+					latOutOfRange++
+					if lon >= -90 && lon <= 90 && lat >= -180 && lat <= 180 {
+						swappedValid++
+					}
+				}
+
+				if region.Contains(lat, lon) {
+					inRegion++
+				}
+				if region.Contains(lon, lat) {
+					swappedInRegion++
+				}
+			}
+		}
+	}
+
+	if report.Coordinates == 0 {
+		report.Warnings = append(report.Warnings, "no polygon coordinates found")
+		return report
+	}
+
+	report.BBox = []float64{minLon, minLat, maxLon, maxLat}
+	report.InRegionPct = float64(inRegion) / float64(report.Coordinates) * 100
+	report.SwappedInRegionPct = float64(swappedInRegion) / float64(report.Coordinates) * 100
+	report.InRegion = report.InRegionPct >= 50
+
+	if report.InvalidCoordinates > 0 {
+		report.Warnings = append(report.Warnings,
+			fmt.Sprintf("%d coordinates are outside valid latitude/longitude ranges", report.InvalidCoordinates))
+	}
+
+	swappedByRegion := !report.InRegion && report.SwappedInRegionPct >= 50
+	swappedByRange := latOutOfRange > 0 && swappedValid == latOutOfRange
+	report.LikelySwapped = swappedByRegion || swappedByRange
+
+	if report.LikelySwapped {
+		report.Warnings = append(report.Warnings,
+			"coordinates appear to be in [lat, lon] order; GeoJSON requires [lon, lat]")
+	} else if !report.InRegion {
+		report.Warnings = append(report.Warnings,
+			fmt.Sprintf("only %.1f%% of coordinates fall within the expected region", report.InRegionPct))
+	}
+
+	return report
+}
+
+// SwapCoordinates exchanges longitude and latitude of every vertex in place
+func SwapCoordinates(fc *geojson.FeatureCollection) {
+	for _, feature := range fc.Features {
+		for _, ring := range geometryRings(feature.Geometry) {
+			for _, coord := range ring {
+				if len(coord) >= 2 {
+					coord[0], coord[1] = coord[1], coord[0]
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package services
+
+import "math"
+
+// EarthRadiusMeters is the mean Earth radius used for haversine distances
+const EarthRadiusMeters = 6371008.8
+
+// metersPerNauticalMile converts the nautical miles used for search radii
+const metersPerNauticalMile = 1852.0
+
+func toRadians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func toDegrees(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
+
+// HaversineDistance returns the great-circle distance in meters between two points
+func HaversineDistance(lat1, lon1, lat2, lon2 float64) float64 {
+	phi1 := toRadians(lat1)
+	phi2 := toRadians(lat2)
+	dPhi := toRadians(lat2 - lat1)
+	dLambda := toRadians(lon2 - lon1)
+
+	a := math.Sin(dPhi/2)*math.Sin(dPhi/2) +
+		math.Cos(phi1)*math.Cos(phi2)*math.Sin(dLambda/2)*math.Sin(dLambda/2)
+	c := 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+
+	return EarthRadiusMeters * c
+}
+
+// IntermediatePoint returns the point the given fraction of the way along the
+// great circle from the first point to the second
+func IntermediatePoint(lat1, lon1, lat2, lon2, fraction float64) (float64, float64) {
+	delta := HaversineDistance(lat1, lon1, lat2, lon2) / EarthRadiusMeters
+	if delta == 0 {
+		return lat1, lon1
+	}
+
+	phi1, lambda1 := toRadians(lat1), toRadians(lon1)
+	phi2, lambda2 := toRadians(lat2), toRadians(lon2)
+
+	a := math.Sin((1-fraction)*delta) / math.Sin(delta)
+	b := math.Sin(fraction*delta) / math.Sin(delta)
+	x := a*math.Cos(phi1)*math.Cos(lambda1) + b*math.Cos(phi2)*math.Cos(lambda2)
+	y := a*math.Cos(phi1)*math.Sin(lambda1) + b*math.Cos(phi2)*math.Sin(lambda2)
+	z := a*math.Sin(phi1) + b*math.Sin(phi2)
+
+	return toDegrees(math.Atan2(z, math.Sqrt(x*x+y*y))), toDegrees(math.Atan2(y, x))
+}
+
+// InitialBearing returns the course in degrees clockwise from north that
+// leaves the first point along the great circle to the second
+func InitialBearing(lat1, lon1, lat2, lon2 float64) float64 {
+	phi1, phi2 := toRadians(lat1), toRadians(lat2)
+	dLambda := toRadians(lon2 - lon1)
+
+	y := math.Sin(dLambda) * math.Cos(phi2)
+	x := math.Cos(phi1)*math.Sin(phi2) - math.Sin(phi1)*math.Cos(phi2)*math.Cos(dLambda)
+
+	return math.Mod(toDegrees(math.Atan2(y, x))+360, 360)
+}
+
+// DestinationPoint returns the point reached by travelling the given distance
+// in meters along the great circle leaving the start on the given course
+func DestinationPoint(lat, lon, course, meters float64) (float64, float64) {
+	delta := meters / EarthRadiusMeters
+	theta := toRadians(course)
+	phi1, lambda1 := toRadians(lat), toRadians(lon)
+
+	phi2 := math.Asin(math.Sin(phi1)*math.Cos(delta) + math.Cos(phi1)*math.Sin(delta)*math.Cos(theta))
+	lambda2 := lambda1 + math.Atan2(math.Sin(theta)*math.Sin(delta)*math.Cos(phi1), math.Cos(delta)-math.Sin(phi1)*math.Sin(phi2))
+
+	return toDegrees(phi2), math.Mod(toDegrees(lambda2)+540, 360) - 180
+}
+
+// PointToSegmentDistance returns the distance in meters from a point to the
+// closest point on a segment, together with that closest point. Coordinates
+// are projected onto a local tangent plane centred on the query point, which
+// is accurate for the segment lengths found in park boundaries, and the final
+// distance is measured with haversine.
+func PointToSegmentDistance(lat, lon, lat1, lon1, lat2, lon2 float64) (float64, float64, float64) {
+	cosLat := math.Cos(toRadians(lat))
+
+	// Local planar coordinates in meters relative to the query point
+	x1 := toRadians(lon1-lon) * cosLat * EarthRadiusMeters
+	y1 := toRadians(lat1-lat) * EarthRadiusMeters
+	x2 := toRadians(lon2-lon) * cosLat * EarthRadiusMeters
+	y2 := toRadians(lat2-lat) * EarthRadiusMeters
+
+	dx := x2 - x1
+	dy := y2 - y1
+
+	t := 0.0
+	if lengthSquared := dx*dx + dy*dy; lengthSquared > 0 {
+		t = -(x1*dx + y1*dy) / lengthSquared
+		if t < 0 {
+			t = 0
+		} else if t > 1 {
+			t = 1
+		}
+	}
+
+	closestLat := lat1 + t*(lat2-lat1)
+	closestLon := lon1 + t*(lon2-lon1)
+
+	return HaversineDistance(lat, lon, closestLat, closestLon), closestLat, closestLon
+}
@@ -0,0 +1,344 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/glebarez/sqlite"
+)
+
+// ParseGeoPackageToGeoJSON reads every feature table of a GeoPackage. Each
+// feature gets its table's columns as properties and the table name as the
+// layer property. Multi-geometries become one feature per part, as KMZ
+// multi-geometries do.
+func ParseGeoPackageToGeoJSON(path string) (*GeoJSON, error) {
+	db, err := sql.Open(sqlite.DriverName, "file:"+path+"?mode=ro")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open GeoPackage: %w", err)
+	}
+	defer db.Close()
+
+	type featureTable struct {
+		name, column string
+		srsID        int
+	}
+	rows, err := db.Query(`SELECT c.table_name, g.column_name, g.srs_id
+		FROM gpkg_contents c JOIN gpkg_geometry_columns g ON g.table_name = c.table_name
+		WHERE c.data_type = 'features' ORDER BY c.table_name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list GeoPackage feature tables: %w", err)
+	}
+	var tables []featureTable
+	for rows.Next() {
+		var table featureTable
+		if err := rows.Scan(&table.name, &table.column, &table.srsID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		tables = append(tables, table)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(tables) == 0 {
+		return nil, fmt.Errorf("GeoPackage has no feature tables")
+	}
+
+	geoJSON := &GeoJSON{
+		Type:     "FeatureCollection",
+		Features: []Feature{},
+	}
+	for _, table := range tables {
+		project, err := geoPackageProjection(db, table.srsID)
+		if err != nil {
+			return nil, fmt.Errorf("table %s: %w", table.name, err)
+		}
+		features, err := readGeoPackageTable(db, table.name, table.column, project)
+		if err != nil {
+			return nil, fmt.Errorf("table %s: %w", table.name, err)
+		}
+		geoJSON.Features = append(geoJSON.Features, features...)
+	}
+
+	return geoJSON, nil
+}
+
+// geoPackageProjection returns the projection of a spatial reference system
+// of the GeoPackage, by EPSG code where possible and by its WKT otherwise
+func geoPackageProjection(db *sql.DB, srsID int) (projection, error) {
+	var organization, definition string
+	var code int
+	err := db.QueryRow(`SELECT organization, organization_coordsys_id, definition
+		FROM gpkg_spatial_ref_sys WHERE srs_id = ?`, srsID).Scan(&organization, &code, &definition)
+	if err != nil {
+		return nil, fmt.Errorf("unknown spatial reference system %d: %w", srsID, err)
+	}
+
+	if strings.EqualFold(organization, "EPSG") {
+		if project, ok := projectionForEPSG(code); ok {
+			return project, nil
+		}
+	}
+	// -1 and 0 are the undefined Cartesian and geographic systems
+	if srsID == 0 {
+		return geographic, nil
+	}
+
+	project, err := parseProjection(definition)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported coordinate system %s:%d: %w", organization, code, err)
+	}
+	return project, nil
+}
+
+// readGeoPackageTable converts the rows of a feature table
+func readGeoPackageTable(db *sql.DB, table, geometryColumn string, project projection) ([]Feature, error) {
+	rows, err := db.Query(`SELECT * FROM "` + strings.ReplaceAll(table, `"`, `""`) + `"`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	var features []Feature
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+
+		attributes := make(map[string]interface{}, len(columns))
+		var blob []byte
+		for i, column := range columns {
+			if strings.EqualFold(column, geometryColumn) {
+				blob, _ = values[i].([]byte)
+				continue
+			}
+			if b, ok := values[i].([]byte); ok {
+				attributes[column] = string(b)
+			} else {
+				attributes[column] = values[i]
+			}
+		}
+		if blob == nil {
+			continue
+		}
+
+		geometries, err := parseGeoPackageGeometry(blob)
+		if err != nil {
+			return nil, err
+		}
+		properties := habitatProperties(attributes)
+		properties["layer"] = table
+		for _, geometry := range geometries {
+			if err := geometry.project(project); err != nil {
+				return nil, err
+			}
+			feature, err := habitatFeature(properties, geometry.Type, geometry.coordinates())
+			if err != nil {
+				return nil, err
+			}
+			features = append(features, feature)
+		}
+	}
+	return features, rows.Err()
+}
+
+// wkbGeometry is a simple geometry decoded from WKB: the position of a Point,
+// the points of a LineString or the rings of a Polygon
+type wkbGeometry struct {
+	Type   string
+	Points [][]float64
+	Rings  [][][]float64
+}
+
+func (g wkbGeometry) project(project projection) error {
+	if err := projectPoints(g.Points, project); err != nil {
+		return err
+	}
+	for _, ring := range g.Rings {
+		if err := projectPoints(ring, project); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g wkbGeometry) coordinates() interface{} {
+	switch g.Type {
+	case "Point":
+		return g.Points[0]
+	case "LineString":
+		return g.Points
+	default:
+		return g.Rings
+	}
+}
+
+// parseGeoPackageGeometry decodes a GeoPackage geometry blob: a header with
+// an optional envelope followed by standard WKB
+func parseGeoPackageGeometry(blob []byte) ([]wkbGeometry, error) {
+	if len(blob) < 8 || blob[0] != 'G' || blob[1] != 'P' {
+		return nil, fmt.Errorf("invalid GeoPackage geometry")
+	}
+	flags := blob[3]
+	if flags&0x10 != 0 {
+		return nil, nil // empty geometry
+	}
+
+	envelopeSizes := map[byte]int{0: 0, 1: 32, 2: 48, 3: 48, 4: 64}
+	envelope, ok := envelopeSizes[(flags>>1)&0x07]
+	if !ok {
+		return nil, fmt.Errorf("invalid GeoPackage geometry envelope")
+	}
+	if len(blob) < 8+envelope {
+		return nil, fmt.Errorf("GeoPackage geometry is truncated")
+	}
+
+	reader := &wkbReader{data: blob[8+envelope:]}
+	geometries, err := reader.geometry()
+	if err != nil {
+		return nil, fmt.Errorf("invalid WKB geometry: %w", err)
+	}
+	return geometries, nil
+}
+
+// wkbReader decodes WKB, flattening multi-geometries and collections into
+// their parts and dropping Z and M values
+type wkbReader struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+func (r *wkbReader) need(n int) error {
+	if len(r.data) < n {
+		return fmt.Errorf("unexpected end of data")
+	}
+	return nil
+}
+
+func (r *wkbReader) uint32() (uint32, error) {
+	if err := r.need(4); err != nil {
+		return 0, err
+	}
+	v := r.order.Uint32(r.data)
+	r.data = r.data[4:]
+	return v, nil
+}
+
+func (r *wkbReader) points(dims int) ([][]float64, error) {
+	count, err := r.uint32()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.need(int(count) * 8 * dims); err != nil {
+		return nil, err
+	}
+	points := make([][]float64, count)
+	for i := range points {
+		points[i] = r.point(dims)
+	}
+	return points, nil
+}
+
+func (r *wkbReader) point(dims int) []float64 {
+	x := math.Float64frombits(r.order.Uint64(r.data))
+	y := math.Float64frombits(r.order.Uint64(r.data[8:]))
+	r.data = r.data[8*dims:]
+	return []float64{x, y}
+}
+
+func (r *wkbReader) geometry() ([]wkbGeometry, error) {
+	if err := r.need(5); err != nil {
+		return nil, err
+	}
+	if r.data[0] == 1 {
+		r.order = binary.LittleEndian
+	} else {
+		r.order = binary.BigEndian
+	}
+	r.data = r.data[1:]
+
+	code, err := r.uint32()
+	if err != nil {
+		return nil, err
+	}
+	// ISO WKB adds 1000 for Z, 2000 for M and 3000 for ZM; EWKB sets flags
+	dims := 2
+	switch {
+	case code&0x80000000 != 0 && code&0x40000000 != 0:
+		dims = 4
+	case code&0xC0000000 != 0:
+		dims = 3
+	}
+	code &= 0x0FFFFFFF
+	switch code / 1000 {
+	case 1, 2:
+		dims = 3
+	case 3:
+		dims = 4
+	}
+
+	switch code % 1000 {
+	case 1:
+		if err := r.need(8 * dims); err != nil {
+			return nil, err
+		}
+		point := r.point(dims)
+		if math.IsNaN(point[0]) {
+			return nil, nil // empty point
+		}
+		return []wkbGeometry{{Type: "Point", Points: [][]float64{point}}}, nil
+	case 2:
+		points, err := r.points(dims)
+		if err != nil {
+			return nil, err
+		}
+		return []wkbGeometry{{Type: "LineString", Points: points}}, nil
+	case 3:
+		count, err := r.uint32()
+		if err != nil {
+			return nil, err
+		}
+		rings := make([][][]float64, 0, count)
+		for i := uint32(0); i < count; i++ {
+			ring, err := r.points(dims)
+			if err != nil {
+				return nil, err
+			}
+			rings = append(rings, ring)
+		}
+		if len(rings) == 0 {
+			return nil, nil
+		}
+		return []wkbGeometry{{Type: "Polygon", Rings: rings}}, nil
+	case 4, 5, 6, 7:
+		count, err := r.uint32()
+		if err != nil {
+			return nil, err
+		}
+		var parts []wkbGeometry
+		for i := uint32(0); i < count; i++ {
+			part, err := r.geometry()
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, part...)
+		}
+		return parts, nil
+	default:
+		return nil, fmt.Errorf("unsupported geometry type %d", code)
+	}
+}
@@ -0,0 +1,232 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// LoadHabitatFile reads a habitat layer, such as posidonia meadows or a
+// restriction map, into GeoJSON. The format is selected by the file
+// extension: .geojson or .json (a GeoJSON FeatureCollection in WGS84), .kmz
+// (Google Earth), .shp (ESRI Shapefile, with its .dbf attributes and .prj
+// coordinate system) or .gpkg (GeoPackage). Projected coordinates are
+// converted to WGS84.
+func LoadHabitatFile(path string) (*GeoJSON, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, fmt.Errorf("habitat file not found at %s", path)
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".geojson", ".json":
+		return parseGeoJSONFile(path)
+	case ".kmz":
+		return ParseKMZToGeoJSON(path)
+	case ".shp":
+		return ParseShapefileToGeoJSON(path)
+	case ".gpkg":
+		return ParseGeoPackageToGeoJSON(path)
+	default:
+		return nil, fmt.Errorf("unsupported habitat file format %q, use .geojson, .kmz, .shp or .gpkg", ext)
+	}
+}
+
+// parseGeoJSONFile reads a GeoJSON FeatureCollection, keeping its properties
+// as they are
+func parseGeoJSONFile(path string) (*GeoJSON, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GeoJSON file: %w", err)
+	}
+	var geoJSON GeoJSON
+	if err := json.Unmarshal(data, &geoJSON); err != nil {
+		return nil, fmt.Errorf("invalid GeoJSON: %w", err)
+	}
+	if geoJSON.Type != "FeatureCollection" {
+		return nil, fmt.Errorf("GeoJSON must be a FeatureCollection, not %q", geoJSON.Type)
+	}
+	if geoJSON.Features == nil {
+		geoJSON.Features = []Feature{}
+	}
+	return &geoJSON, nil
+}
+
+// habitatStamp returns the version of a habitat file on disk. A shapefile is
+// spread over sidecar files, so rewriting any of them counts as a change.
+func habitatStamp(path string) (fileStamp, error) {
+	stamp, err := statFile(path)
+	if err != nil || !strings.EqualFold(filepath.Ext(path), ".shp") {
+		return stamp, err
+	}
+
+	base := strings.TrimSuffix(path, filepath.Ext(path))
+	for _, ext := range []string{".dbf", ".prj", ".cpg"} {
+		sidecar, err := statFile(sidecarPath(base, ext))
+		if err != nil {
+			continue
+		}
+		if sidecar.modTime.After(stamp.modTime) {
+			stamp.modTime = sidecar.modTime
+		}
+		stamp.size += sidecar.size
+	}
+	return stamp, nil
+}
+
+// sidecarPath returns the file next to a shapefile with the given extension,
+// in lower or upper case, whichever exists
+func sidecarPath(base, ext string) string {
+	lower := base + ext
+	if _, err := os.Stat(lower); err == nil {
+		return lower
+	}
+	upper := base + strings.ToUpper(ext)
+	if _, err := os.Stat(upper); err == nil {
+		return upper
+	}
+	return lower
+}
+
+// habitatProperties returns the properties of a feature read from a
+// shapefile or GeoPackage: its attributes, plus the posidonia type,
+// condition, substrate and classification derived from their text the same
+// way as from KMZ descriptions, unless attributes of those names exist
+func habitatProperties(attributes map[string]interface{}) map[string]interface{} {
+	properties := make(map[string]interface{}, len(attributes)+4)
+
+	names := make([]string, 0, len(attributes))
+	for name := range attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var text []string
+	for _, name := range names {
+		value := attributes[name]
+		properties[name] = value
+		if s, ok := value.(string); ok && s != "" {
+			text = append(text, s)
+		}
+	}
+
+	posidoniaType := parsePosidoniaType(strings.Join(text, " "))
+	for name, value := range map[string]string{
+		"type":           posidoniaType.Type,
+		"condition":      posidoniaType.Condition,
+		"substrate":      posidoniaType.Substrate,
+		"classification": posidoniaType.Classification,
+	} {
+		if _, exists := properties[name]; !exists {
+			properties[name] = value
+		}
+	}
+	return properties
+}
+
+// habitatFeature builds a feature from coordinates already in longitude and
+// latitude
+func habitatFeature(properties map[string]interface{}, geometryType string, coordinates interface{}) (Feature, error) {
+	data, err := json.Marshal(coordinates)
+	if err != nil {
+		return Feature{}, err
+	}
+	return Feature{
+		Type:       "Feature",
+		Properties: properties,
+		Geometry: Geometry{
+			Type:        geometryType,
+			Coordinates: data,
+		},
+	}, nil
+}
+
+// ringArea returns the signed area of a ring by the shoelace formula,
+// negative when the ring runs clockwise
+func ringArea(ring [][]float64) float64 {
+	area := 0.0
+	for i := range ring {
+		j := (i + 1) % len(ring)
+		area += ring[i][0]*ring[j][1] - ring[j][0]*ring[i][1]
+	}
+	return area / 2
+}
+
+// ringContains reports whether a point lies inside a ring
+func ringContains(ring [][]float64, point []float64) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		xi, yi := ring[i][0], ring[i][1]
+		xj, yj := ring[j][0], ring[j][1]
+		if (yi > point[1]) != (yj > point[1]) && point[0] < (xj-xi)*(point[1]-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// groupRings splits the rings of a shapefile polygon into polygons: clockwise
+// rings are outer boundaries and counter-clockwise rings are holes of the
+// outer ring containing them
+func groupRings(rings [][][]float64) [][][][]float64 {
+	var polygons [][][][]float64
+	var holes [][][]float64
+	for _, ring := range rings {
+		if len(ring) < 4 {
+			continue
+		}
+		if ringArea(ring) <= 0 {
+			polygons = append(polygons, [][][]float64{ring})
+		} else {
+			holes = append(holes, ring)
+		}
+	}
+
+	// Some writers ignore the orientation rule; treat all rings as outer
+	// boundaries rather than drop them
+	if len(polygons) == 0 {
+		for _, hole := range holes {
+			polygons = append(polygons, [][][]float64{hole})
+		}
+		return polygons
+	}
+
+	for _, hole := range holes {
+		owner := len(polygons) - 1
+		for i, polygon := range polygons {
+			if ringContains(polygon[0], hole[0]) {
+				owner = i
+				break
+			}
+		}
+		polygons[owner] = append(polygons[owner], hole)
+	}
+	return polygons
+}
+
+// projectPoints converts points in place to longitude and latitude, rounded
+// to about a centimeter, and rejects results that cannot be coordinates
+func projectPoints(points [][]float64, project projection) error {
+	for _, point := range points {
+		lon, lat := project(point[0], point[1])
+		if math.IsNaN(lon) || math.IsNaN(lat) || lon < -180 || lon > 180 || lat < -90 || lat > 90 {
+			return fmt.Errorf("coordinate %g,%g is not a valid position after projection, check the coordinate system", point[0], point[1])
+		}
+		point[0] = math.Round(lon*1e7) / 1e7
+		point[1] = math.Round(lat*1e7) / 1e7
+	}
+	return nil
+}
+
+// guessProjection is used when a layer declares no coordinate system: values
+// within longitude and latitude ranges are taken as WGS84, anything else is
+// an error rather than a guess at the grid
+func guessProjection(bbox [4]float64) (projection, error) {
+	if bbox[0] >= -180 && bbox[2] <= 180 && bbox[1] >= -90 && bbox[3] <= 90 {
+		return geographic, nil
+	}
+	return nil, fmt.Errorf("the layer has no coordinate system and its coordinates are not longitude and latitude")
+}
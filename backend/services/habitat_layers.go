@@ -0,0 +1,420 @@
+package services
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"vessel-tracker/database"
+	"vessel-tracker/logging"
+	"vessel-tracker/models"
+
+	geojson "github.com/paulmach/go.geojson"
+	"gorm.io/gorm"
+)
+
+// DefaultHabitatLayersDir is where uploaded habitat layers are stored when
+// HABITAT_LAYERS_DIR is not set
+var DefaultHabitatLayersDir = filepath.Join(".", "data", "layers")
+
+// MaxHabitatFileBytes caps the size of an uploaded habitat file, and of the
+// files extracted from a zipped shapefile together
+const MaxHabitatFileBytes = 50 << 20
+
+// ErrInvalidHabitatFile is returned for uploads that cannot be read as a
+// habitat layer
+var ErrInvalidHabitatFile = errors.New("invalid habitat file")
+
+// shapefileMembers are the files of a zipped shapefile that are kept
+var shapefileMembers = map[string]bool{".shp": true, ".shx": true, ".dbf": true, ".prj": true, ".cpg": true}
+
+// HabitatLayersDir returns HABITAT_LAYERS_DIR or DefaultHabitatLayersDir
+func HabitatLayersDir() string {
+	if dir := os.Getenv("HABITAT_LAYERS_DIR"); dir != "" {
+		return dir
+	}
+	return DefaultHabitatLayersDir
+}
+
+// HabitatLayerService manages habitat layers uploaded at runtime. Each layer
+// is a row in the database and a directory holding its file; changes are
+// loaded into the GeoService of the layer's park right away.
+type HabitatLayerService struct {
+	db     *gorm.DB
+	dir    string
+	parks  *ParkRegistry
+	logger *slog.Logger
+
+	mu     sync.Mutex // serializes changes, so a park is reloaded with a consistent set
+	loaded map[uint]*loadedHabitatFile
+}
+
+// loadedHabitatFile caches the parsed file of a layer
+type loadedHabitatFile struct {
+	features *geojson.FeatureCollection
+	payload  *StaticPayload
+}
+
+func NewHabitatLayerService(dir string, parks *ParkRegistry) *HabitatLayerService {
+	return &HabitatLayerService{
+		db:     database.GetDB(),
+		dir:    dir,
+		parks:  parks,
+		logger: logging.Component("habitat"),
+		loaded: make(map[uint]*loadedHabitatFile),
+	}
+}
+
+// StagedHabitatLayer is an uploaded file that was parsed but not added yet.
+// Discard removes it when it is not added.
+type StagedHabitatLayer struct {
+	Format   string
+	FileName string
+	SHA256   string
+	Features *geojson.FeatureCollection
+
+	dir  string // temporary directory holding the file
+	file string // file to load, relative to dir
+}
+
+// Discard removes the staged file
+func (l *StagedHabitatLayer) Discard() {
+	os.RemoveAll(l.dir)
+}
+
+// Stage stores an uploaded habitat file in a temporary directory and parses
+// it. The format is chosen by the file name: .geojson or .json, .kmz, .gpkg,
+// or .zip for a shapefile with its sidecar files. Errors reading the upload
+// wrap ErrInvalidHabitatFile.
+func (s *HabitatLayerService) Stage(fileName string, r io.Reader) (*StagedHabitatLayer, error) {
+	fileName = filepath.Base(fileName)
+	ext := strings.ToLower(filepath.Ext(fileName))
+
+	var format string
+	switch ext {
+	case ".geojson", ".json":
+		format = models.HabitatFormatGeoJSON
+	case ".kmz":
+		format = models.HabitatFormatKMZ
+	case ".gpkg":
+		format = models.HabitatFormatGeoPackage
+	case ".zip":
+		format = models.HabitatFormatShapefile
+	case ".shp":
+		return nil, fmt.Errorf("%w: upload a shapefile as a .zip archive together with its .dbf and .prj files", ErrInvalidHabitatFile)
+	default:
+		return nil, fmt.Errorf("%w: unsupported format %q, use .geojson, .kmz, .gpkg or a zipped shapefile", ErrInvalidHabitatFile, ext)
+	}
+
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", s.dir, err)
+	}
+	dir, err := os.MkdirTemp(s.dir, "upload-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to stage upload: %w", err)
+	}
+	staged := &StagedHabitatLayer{Format: format, FileName: fileName, dir: dir}
+
+	if err := staged.store(r, ext); err != nil {
+		staged.Discard()
+		return nil, err
+	}
+
+	data, err := LoadHabitatFile(filepath.Join(dir, staged.file))
+	if err == nil {
+		staged.Features, err = toFeatureCollection(data)
+	}
+	if err != nil {
+		staged.Discard()
+		return nil, fmt.Errorf("%w: %v", ErrInvalidHabitatFile, err)
+	}
+	if len(staged.Features.Features) == 0 {
+		staged.Discard()
+		return nil, fmt.Errorf("%w: the file has no features", ErrInvalidHabitatFile)
+	}
+
+	return staged, nil
+}
+
+// store writes the upload into the staging directory, extracting a zipped
+// shapefile, and records its checksum
+func (l *StagedHabitatLayer) store(r io.Reader, ext string) error {
+	upload := filepath.Join(l.dir, "upload"+ext)
+	out, err := os.Create(upload)
+	if err != nil {
+		return err
+	}
+	hash := sha256.New()
+	written, err := io.Copy(io.MultiWriter(out, hash), io.LimitReader(r, MaxHabitatFileBytes+1))
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("%w: failed to read upload: %v", ErrInvalidHabitatFile, err)
+	}
+	if written > MaxHabitatFileBytes {
+		return fmt.Errorf("%w: the file is larger than %d MB", ErrInvalidHabitatFile, MaxHabitatFileBytes>>20)
+	}
+	l.SHA256 = hex.EncodeToString(hash.Sum(nil))
+
+	if ext != ".zip" {
+		l.file = "layer" + ext
+		return os.Rename(upload, filepath.Join(l.dir, l.file))
+	}
+
+	defer os.Remove(upload)
+	if err := extractShapefile(upload, l.dir); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidHabitatFile, err)
+	}
+	l.file = "layer.shp"
+	return nil
+}
+
+// extractShapefile extracts the one shapefile of a zip archive into dir as
+// layer.shp and its sidecars. Only base names are used, so entries cannot
+// be written outside dir.
+func extractShapefile(archive, dir string) error {
+	reader, err := zip.OpenReader(archive)
+	if err != nil {
+		return fmt.Errorf("invalid zip archive: %w", err)
+	}
+	defer reader.Close()
+
+	var stem string
+	for _, file := range reader.File {
+		name := filepath.Base(file.Name)
+		if strings.EqualFold(filepath.Ext(name), ".shp") && !strings.HasPrefix(file.Name, "__MACOSX") {
+			if stem != "" {
+				return fmt.Errorf("the archive holds more than one shapefile")
+			}
+			stem = strings.TrimSuffix(name, filepath.Ext(name))
+		}
+	}
+	if stem == "" {
+		return fmt.Errorf("the archive holds no .shp file")
+	}
+
+	var remaining int64 = MaxHabitatFileBytes
+	for _, file := range reader.File {
+		name := filepath.Base(file.Name)
+		ext := strings.ToLower(filepath.Ext(name))
+		if file.FileInfo().IsDir() || strings.HasPrefix(file.Name, "__MACOSX") ||
+			!shapefileMembers[ext] || !strings.EqualFold(strings.TrimSuffix(name, filepath.Ext(name)), stem) {
+			continue
+		}
+
+		written, err := extractZipFile(file, filepath.Join(dir, "layer"+ext), remaining)
+		if err != nil {
+			return err
+		}
+		remaining -= written
+	}
+	return nil
+}
+
+// extractZipFile writes one archive entry, failing once limit bytes are
+// exceeded
+func extractZipFile(file *zip.File, path string, limit int64) (int64, error) {
+	in, err := file.Open()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", file.Name, err)
+	}
+	defer in.Close()
+
+	out, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	written, err := io.Copy(out, io.LimitReader(in, limit+1))
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return written, fmt.Errorf("failed to extract %s: %w", file.Name, err)
+	}
+	if written > limit {
+		return written, fmt.Errorf("the extracted shapefile is larger than %d MB", MaxHabitatFileBytes>>20)
+	}
+	return written, nil
+}
+
+// toFeatureCollection converts a parsed habitat file to the GeoJSON types
+// zone lookups use
+func toFeatureCollection(data *GeoJSON) (*geojson.FeatureCollection, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return geojson.UnmarshalFeatureCollection(encoded)
+}
+
+// Add stores a staged layer for a park and loads it into the park's
+// GeoService. The name, kind, validity window and uploader are taken from
+// layer; the rest is filled in.
+func (s *HabitatLayerService) Add(park *Park, staged *StagedHabitatLayer, layer *models.HabitatLayer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	layer.ParkID = park.Record.ID
+	layer.Format = staged.Format
+	layer.FileName = staged.FileName
+	layer.Features = len(staged.Features.Features)
+	layer.SHA256 = staged.SHA256
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(layer).Error; err != nil {
+			return fmt.Errorf("failed to create habitat layer: %w", err)
+		}
+		id := strconv.FormatUint(uint64(layer.ID), 10)
+		if err := os.Rename(staged.dir, filepath.Join(s.dir, id)); err != nil {
+			return fmt.Errorf("failed to store habitat layer: %w", err)
+		}
+		layer.Path = filepath.Join(id, staged.file)
+		if err := tx.Model(layer).Update("path", layer.Path).Error; err != nil {
+			os.RemoveAll(filepath.Join(s.dir, id))
+			return fmt.Errorf("failed to create habitat layer: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		staged.Discard()
+		return err
+	}
+
+	payload, err := newBoundaryPayload(staged.Features, layer.CreatedAt)
+	if err != nil {
+		return err
+	}
+	s.loaded[layer.ID] = &loadedHabitatFile{features: staged.Features, payload: payload}
+	s.logger.Info("Added habitat layer", "id", layer.ID, "park", park.Record.Slug, "name", layer.Name, "kind", layer.Kind, "features", layer.Features, "uploaded_by", layer.UploadedBy)
+
+	return s.reload(park)
+}
+
+// List returns the layers of a park, optionally of one kind, oldest first
+func (s *HabitatLayerService) List(parkID uint, kind string) ([]models.HabitatLayer, error) {
+	query := s.db.Where("park_id = ?", parkID)
+	if kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+	var layers []models.HabitatLayer
+	err := query.Order("id").Find(&layers).Error
+	return layers, err
+}
+
+// Get returns a layer
+func (s *HabitatLayerService) Get(id uint) (*models.HabitatLayer, error) {
+	var layer models.HabitatLayer
+	if err := s.db.First(&layer, id).Error; err != nil {
+		return nil, err
+	}
+	return &layer, nil
+}
+
+// Payload returns the features of a layer as GeoJSON
+func (s *HabitatLayerService) Payload(layer *models.HabitatLayer) (*StaticPayload, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := s.load(layer)
+	if err != nil {
+		return nil, err
+	}
+	return file.payload, nil
+}
+
+// Delete removes a layer, its file and its zones from the park
+func (s *HabitatLayerService) Delete(id uint) (*models.HabitatLayer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var layer models.HabitatLayer
+	if err := s.db.First(&layer, id).Error; err != nil {
+		return nil, err
+	}
+	if err := s.db.Delete(&layer).Error; err != nil {
+		return nil, fmt.Errorf("failed to delete habitat layer: %w", err)
+	}
+	delete(s.loaded, id)
+
+	dir := filepath.Join(s.dir, strconv.FormatUint(uint64(id), 10))
+	if err := os.RemoveAll(dir); err != nil {
+		s.logger.Warn("Failed to remove habitat layer files", "id", id, "path", dir, "error", err)
+	}
+	s.logger.Info("Deleted habitat layer", "id", id, "name", layer.Name, "kind", layer.Kind)
+
+	if park, ok := s.parks.GetByID(layer.ParkID); ok {
+		if err := s.reload(park); err != nil {
+			return &layer, err
+		}
+	}
+	return &layer, nil
+}
+
+// LoadAll loads the stored layers of every park, at startup. A layer whose
+// file cannot be read is logged and left out.
+func (s *HabitatLayerService) LoadAll() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, park := range s.parks.All() {
+		if err := s.reload(park); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reload hands the layers of a park to its GeoService. The caller holds mu.
+func (s *HabitatLayerService) reload(park *Park) error {
+	layers, err := s.List(park.Record.ID, "")
+	if err != nil {
+		return fmt.Errorf("failed to list habitat layers: %w", err)
+	}
+
+	loaded := make([]LoadedHabitatLayer, 0, len(layers))
+	for _, layer := range layers {
+		file, err := s.load(&layer)
+		if err != nil {
+			s.logger.Error("Failed to load habitat layer, leaving it out", "id", layer.ID, "park", park.Record.Slug, "path", layer.Path, "error", err)
+			continue
+		}
+		loaded = append(loaded, LoadedHabitatLayer{Layer: layer, Features: file.features})
+	}
+	park.Geo.SetHabitatLayers(loaded)
+	return nil
+}
+
+// load returns the parsed file of a layer, reading it on first use. The
+// caller holds mu.
+func (s *HabitatLayerService) load(layer *models.HabitatLayer) (*loadedHabitatFile, error) {
+	if file, ok := s.loaded[layer.ID]; ok {
+		return file, nil
+	}
+
+	data, err := LoadHabitatFile(filepath.Join(s.dir, layer.Path))
+	if err != nil {
+		return nil, err
+	}
+	features, err := toFeatureCollection(data)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := newBoundaryPayload(features, layer.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	file := &loadedHabitatFile{features: features, payload: payload}
+	s.loaded[layer.ID] = file
+	return file, nil
+}
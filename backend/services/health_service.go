@@ -0,0 +1,240 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"vessel-tracker/database"
+
+	"gorm.io/gorm"
+)
+
+// HealthConfig sets how readiness checks are run
+type HealthConfig struct {
+	Timeout       time.Duration // bound on the database and provider checks
+	ProviderCache time.Duration // how long a provider check result is reused
+}
+
+func DefaultHealthConfig() HealthConfig {
+	return HealthConfig{
+		Timeout:       3 * time.Second,
+		ProviderCache: 5 * time.Minute,
+	}
+}
+
+// LoadHealthConfig reads HEALTH_CHECK_TIMEOUT and HEALTH_PROVIDER_CACHE,
+// falling back to the defaults for unset variables
+func LoadHealthConfig() (HealthConfig, error) {
+	config := DefaultHealthConfig()
+
+	durationVars := map[string]*time.Duration{
+		"HEALTH_CHECK_TIMEOUT":  &config.Timeout,
+		"HEALTH_PROVIDER_CACHE": &config.ProviderCache,
+	}
+	for name, target := range durationVars {
+		if value := os.Getenv(name); value != "" {
+			d, err := time.ParseDuration(value)
+			if err != nil || d <= 0 {
+				return config, fmt.Errorf("invalid %s %q: must be a positive duration", name, value)
+			}
+			*target = d
+		}
+	}
+
+	return config, nil
+}
+
+// Names of the readiness checks
+const (
+	HealthCheckDatabase  = "database"
+	HealthCheckScheduler = "scheduler"
+	HealthCheckProvider  = "provider"
+	HealthCheckGeoFiles  = "geo_files"
+)
+
+// HealthCheck is the outcome of one readiness check
+type HealthCheck struct {
+	Name      string    `json:"name"`
+	OK        bool      `json:"ok"`
+	LatencyMs int64     `json:"latency_ms"`
+	Detail    string    `json:"detail,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"` // earlier than now for a cached result
+}
+
+// HealthReport is the outcome of every readiness check
+type HealthReport struct {
+	Ready  bool          `json:"ready"`
+	Checks []HealthCheck `json:"checks"`
+}
+
+// HealthService checks the dependencies the API needs to give correct
+// answers: the database, a scheduler that keeps positions fresh, the vessel
+// data provider and the geo data files on disk
+type HealthService struct {
+	db            *gorm.DB
+	config        HealthConfig
+	scheduler     *SchedulerService
+	vesselService *VesselService
+	parks         *ParkRegistry
+	startedAt     time.Time
+
+	providerMu    sync.Mutex
+	providerCheck *HealthCheck
+}
+
+func NewHealthService(config HealthConfig, scheduler *SchedulerService, vesselService *VesselService, parks *ParkRegistry) *HealthService {
+	return &HealthService{
+		db:            database.GetDB(),
+		config:        config,
+		scheduler:     scheduler,
+		vesselService: vesselService,
+		parks:         parks,
+		startedAt:     time.Now(),
+	}
+}
+
+// Check runs every readiness check. The provider is contacted at most once
+// per ProviderCache, as the checks run on every poll of a load balancer.
+func (s *HealthService) Check(ctx context.Context) HealthReport {
+	report := HealthReport{
+		Ready: true,
+		Checks: []HealthCheck{
+			s.timed(HealthCheckDatabase, func() (string, error) { return "", s.checkDatabase(ctx) }),
+			s.timed(HealthCheckScheduler, s.checkScheduler),
+			s.checkProvider(ctx),
+			s.timed(HealthCheckGeoFiles, s.checkGeoFiles),
+		},
+	}
+	for _, check := range report.Checks {
+		if !check.OK {
+			report.Ready = false
+		}
+	}
+	return report
+}
+
+func (s *HealthService) timed(name string, check func() (string, error)) HealthCheck {
+	start := time.Now()
+	detail, err := check()
+
+	result := HealthCheck{
+		Name:      name,
+		OK:        err == nil,
+		LatencyMs: time.Since(start).Milliseconds(),
+		Detail:    detail,
+		CheckedAt: start,
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+func (s *HealthService) checkDatabase(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, s.config.Timeout)
+	defer cancel()
+
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return fmt.Errorf("failed to get database connection: %w", err)
+	}
+	if err := sqlDB.PingContext(ctx); err != nil {
+		return fmt.Errorf("database unreachable: %w", err)
+	}
+	return nil
+}
+
+// checkScheduler fails when polling has not succeeded for several fetch
+// intervals, counted from startup until the first success. A paused
+// scheduler is deliberate and passes.
+func (s *HealthService) checkScheduler() (string, error) {
+	config := s.scheduler.Config()
+	if config.Source == DataSourceAIS {
+		return "positions are received over AIS", nil
+	}
+
+	status := s.scheduler.Status()
+	if status.Paused {
+		return "fetching is paused", nil
+	}
+
+	window := staleFetchIntervals * config.FetchInterval
+	now := time.Now()
+	if status.LastSuccessAt == nil {
+		if now.Sub(s.startedAt) > window {
+			return "", fmt.Errorf("no successful fetch since startup %s ago", now.Sub(s.startedAt).Round(time.Second))
+		}
+		return "waiting for the first fetch", nil
+	}
+
+	age := now.Sub(*status.LastSuccessAt)
+	if age > window {
+		return "", fmt.Errorf("last successful fetch %s ago, expected every %s", age.Round(time.Second), config.FetchInterval)
+	}
+	return fmt.Sprintf("last successful fetch %s ago", age.Round(time.Second)), nil
+}
+
+// checkProvider contacts the vessel data provider, reusing the previous
+// result while it is younger than ProviderCache
+func (s *HealthService) checkProvider(ctx context.Context) HealthCheck {
+	s.providerMu.Lock()
+	defer s.providerMu.Unlock()
+
+	if s.providerCheck != nil && time.Since(s.providerCheck.CheckedAt) < s.config.ProviderCache {
+		return *s.providerCheck
+	}
+
+	check := s.timed(HealthCheckProvider, func() (string, error) {
+		ctx, cancel := context.WithTimeout(ctx, s.config.Timeout)
+		defer cancel()
+		return s.vesselService.ProviderName(), s.vesselService.Ping(ctx)
+	})
+	s.providerCheck = &check
+	return check
+}
+
+// checkGeoFiles fails when a boundary or habitat file the parks are loaded
+// from can no longer be read, so a reload or restart would lose the layer
+func (s *HealthService) checkGeoFiles() (string, error) {
+	var paths []string
+	for _, park := range s.parks.All() {
+		for _, path := range []string{park.Record.BoundariesPath, park.Record.BufferedPath} {
+			if path != "" {
+				paths = append(paths, path)
+			}
+		}
+	}
+	paths = append(paths, PosidoniaPath())
+
+	var failed []string
+	for _, path := range paths {
+		if err := readableFile(path); err != nil {
+			failed = append(failed, err.Error())
+		}
+	}
+	if len(failed) > 0 {
+		return "", fmt.Errorf("%s", strings.Join(failed, "; "))
+	}
+	return fmt.Sprintf("%d files readable", len(paths)), nil
+}
+
+func readableFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory", path)
+	}
+	return nil
+}
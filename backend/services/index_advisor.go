@@ -0,0 +1,378 @@
+package services
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"vessel-tracker/database"
+	"vessel-tracker/logging"
+
+	"github.com/robfig/cron/v3"
+	"gorm.io/gorm"
+)
+
+// IndexAdvisorConfig sets how often and how widely slow queries are reviewed
+// for missing indexes
+type IndexAdvisorConfig struct {
+	Interval    time.Duration // how often the advisor runs
+	MinMeanTime time.Duration // queries faster than this on average are ignored
+	Queries     int           // slowest queries reviewed, by total time
+	MinRows     int64         // tables smaller than this are not worth an index
+}
+
+func DefaultIndexAdvisorConfig() IndexAdvisorConfig {
+	return IndexAdvisorConfig{
+		Interval:    6 * time.Hour,
+		MinMeanTime: 50 * time.Millisecond,
+		Queries:     20,
+		MinRows:     10000,
+	}
+}
+
+// LoadIndexAdvisorConfig reads INDEX_ADVISOR_INTERVAL,
+// INDEX_ADVISOR_MIN_MEAN_TIME, INDEX_ADVISOR_QUERIES and
+// INDEX_ADVISOR_MIN_ROWS, falling back to the defaults for unset variables
+func LoadIndexAdvisorConfig() (IndexAdvisorConfig, error) {
+	config := DefaultIndexAdvisorConfig()
+
+	if value := os.Getenv("INDEX_ADVISOR_INTERVAL"); value != "" {
+		interval, err := time.ParseDuration(value)
+		if err != nil || interval < time.Minute {
+			return config, fmt.Errorf("invalid INDEX_ADVISOR_INTERVAL %q: must be a duration of at least 1m", value)
+		}
+		config.Interval = interval
+	}
+
+	if value := os.Getenv("INDEX_ADVISOR_MIN_MEAN_TIME"); value != "" {
+		minMean, err := time.ParseDuration(value)
+		if err != nil || minMean < 0 {
+			return config, fmt.Errorf("invalid INDEX_ADVISOR_MIN_MEAN_TIME %q: must be a non-negative duration", value)
+		}
+		config.MinMeanTime = minMean
+	}
+
+	if value := os.Getenv("INDEX_ADVISOR_QUERIES"); value != "" {
+		queries, err := strconv.Atoi(value)
+		if err != nil || queries < 1 || queries > 500 {
+			return config, fmt.Errorf("invalid INDEX_ADVISOR_QUERIES %q: must be between 1 and 500", value)
+		}
+		config.Queries = queries
+	}
+
+	if value := os.Getenv("INDEX_ADVISOR_MIN_ROWS"); value != "" {
+		rows, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || rows < 0 {
+			return config, fmt.Errorf("invalid INDEX_ADVISOR_MIN_ROWS %q: must be a non-negative number", value)
+		}
+		config.MinRows = rows
+	}
+
+	return config, nil
+}
+
+// SlowQuery is a normalized statement from pg_stat_statements
+type SlowQuery struct {
+	Query       string  `json:"query"`
+	Calls       int64   `json:"calls"`
+	MeanTimeMs  float64 `json:"mean_time_ms"`
+	TotalTimeMs float64 `json:"total_time_ms"`
+	Rows        int64   `json:"rows"`
+}
+
+// IndexSuggestion is a column filtered on by slow queries that no index
+// starts with
+type IndexSuggestion struct {
+	Table       string  `json:"table"`
+	Column      string  `json:"column"`
+	Statement   string  `json:"statement"`
+	Queries     int     `json:"queries"`       // slow queries filtering on the column
+	TotalTimeMs float64 `json:"total_time_ms"` // spent in those queries
+	TableRows   int64   `json:"table_rows"`
+	SeqScans    int64   `json:"seq_scans"`
+	IndexScans  int64   `json:"index_scans"`
+}
+
+// IndexAdvisorReport is the outcome of the latest advisor run
+type IndexAdvisorReport struct {
+	Available   bool              `json:"available"`
+	Reason      string            `json:"reason,omitempty"` // why advice is unavailable
+	LastRunAt   *time.Time        `json:"last_run_at"`
+	SlowQueries []SlowQuery       `json:"slow_queries"`
+	Suggestions []IndexSuggestion `json:"suggestions"`
+}
+
+// IndexAdvisor periodically reviews the slowest queries the application has
+// run, as recorded by pg_stat_statements, and suggests indexes on the columns
+// they filter on that no index covers. The review reads normalized query text
+// and the catalog only; it never runs the queries or changes the schema.
+type IndexAdvisor struct {
+	db     *gorm.DB
+	cron   *cron.Cron
+	config IndexAdvisorConfig
+	logger *slog.Logger
+
+	mu     sync.RWMutex
+	report IndexAdvisorReport
+}
+
+func NewIndexAdvisor(config IndexAdvisorConfig) *IndexAdvisor {
+	return &IndexAdvisor{
+		db:     database.GetDB(),
+		cron:   cron.New(cron.WithSeconds()),
+		config: config,
+		logger: logging.Component("index_advisor"),
+		report: IndexAdvisorReport{SlowQueries: []SlowQuery{}, Suggestions: []IndexSuggestion{}},
+	}
+}
+
+// Start schedules the advisor on PostgreSQL; elsewhere its report says advice
+// is unavailable
+func (a *IndexAdvisor) Start() error {
+	if a.db.Dialector.Name() != "postgres" {
+		a.mu.Lock()
+		a.report.Reason = "index advice needs PostgreSQL with pg_stat_statements"
+		a.mu.Unlock()
+		return nil
+	}
+
+	if _, err := a.cron.AddFunc(fmt.Sprintf("@every %s", a.config.Interval), a.Run); err != nil {
+		return err
+	}
+	a.cron.Start()
+	a.logger.Info("Index advisor started", "interval", a.config.Interval.String())
+
+	go a.Run()
+
+	return nil
+}
+
+func (a *IndexAdvisor) Stop() {
+	a.cron.Stop()
+}
+
+// Report returns the outcome of the latest advisor run
+func (a *IndexAdvisor) Report() IndexAdvisorReport {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	report := a.report
+	report.SlowQueries = append([]SlowQuery{}, a.report.SlowQueries...)
+	report.Suggestions = append([]IndexSuggestion{}, a.report.Suggestions...)
+	return report
+}
+
+// Run reviews the slowest queries once, logs each suggestion and stores the
+// result
+func (a *IndexAdvisor) Run() {
+	now := time.Now()
+	report := IndexAdvisorReport{LastRunAt: &now, SlowQueries: []SlowQuery{}, Suggestions: []IndexSuggestion{}}
+
+	queries, err := a.slowQueries()
+	if err != nil {
+		report.Reason = err.Error()
+		a.logger.Warn("Index advice unavailable", "error", err)
+	} else {
+		report.Available = true
+		report.SlowQueries = queries
+		if report.Suggestions, err = a.suggest(queries); err != nil {
+			report.Available = false
+			report.Reason = err.Error()
+			a.logger.Error("Failed to review slow queries", "error", err)
+		}
+	}
+
+	for _, suggestion := range report.Suggestions {
+		a.logger.Warn("Missing index suggested",
+			"table", suggestion.Table,
+			"column", suggestion.Column,
+			"queries", suggestion.Queries,
+			"total_time_ms", suggestion.TotalTimeMs,
+			"statement", suggestion.Statement)
+	}
+	if report.Available {
+		a.logger.Info("Slow queries reviewed", "queries", len(report.SlowQueries), "suggestions", len(report.Suggestions))
+	}
+
+	a.mu.Lock()
+	a.report = report
+	a.mu.Unlock()
+}
+
+// maxQueryText bounds the query text kept in a report
+const maxQueryText = 1000
+
+// slowQueries reads the statements the application's database user ran in
+// its database, slowest in total first, skipping fast ones
+func (a *IndexAdvisor) slowQueries() ([]SlowQuery, error) {
+	// Creating the extension needs privileges the user may lack, so it is
+	// only attempted while it is missing
+	var installed bool
+	if err := a.db.Raw("SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'pg_stat_statements')").Scan(&installed).Error; err != nil {
+		return nil, fmt.Errorf("failed to look up pg_stat_statements: %w", err)
+	}
+	if !installed {
+		if err := a.db.Exec("CREATE EXTENSION pg_stat_statements").Error; err != nil {
+			return nil, fmt.Errorf("pg_stat_statements is not installed and cannot be created: %w", err)
+		}
+	}
+
+	var queries []SlowQuery
+	err := a.db.Raw(`SELECT query, calls, mean_exec_time AS mean_time_ms, total_exec_time AS total_time_ms, rows
+		FROM pg_stat_statements
+		WHERE dbid = (SELECT oid FROM pg_database WHERE datname = current_database())
+			AND userid = (SELECT oid FROM pg_roles WHERE rolname = current_user)
+			AND mean_exec_time >= ?
+			AND query !~* '(pg_stat_statements|pg_catalog|information_schema)'
+		ORDER BY total_exec_time DESC
+		LIMIT ?`, float64(a.config.MinMeanTime)/float64(time.Millisecond), a.config.Queries).
+		Scan(&queries).Error
+	if err != nil {
+		return nil, fmt.Errorf("pg_stat_statements unavailable, add it to shared_preload_libraries: %w", err)
+	}
+
+	for i := range queries {
+		if len(queries[i].Query) > maxQueryText {
+			queries[i].Query = queries[i].Query[:maxQueryText] + "…"
+		}
+	}
+	return queries, nil
+}
+
+var (
+	// queryTablePattern finds the tables a statement reads
+	queryTablePattern = regexp.MustCompile(`(?i)\b(?:FROM|JOIN|UPDATE)\s+"?(\w+)"?`)
+	// queryFilterPattern finds the columns a statement compares, qualified or
+	// not, with their qualifier
+	queryFilterPattern = regexp.MustCompile(`(?i)(?:"?(\w+)"?\.)?"?(\w+)"?\s*(?:=|<>|!=|<=|>=|<|>|\bIN\b|\bBETWEEN\b|\bLIKE\b|\bILIKE\b|\bIS\b)`)
+	// queryJoinPattern finds the qualified columns on the right of a join
+	// condition
+	queryJoinPattern = regexp.MustCompile(`=\s*"?(\w+)"?\."?(\w+)"?`)
+)
+
+type tableStats struct {
+	Rows       int64
+	SeqScans   int64
+	IndexScans int64
+}
+
+// suggest finds, for the tables the queries read, the columns they compare
+// that no index starts with, ranked by the time spent in those queries
+func (a *IndexAdvisor) suggest(queries []SlowQuery) ([]IndexSuggestion, error) {
+	var columnRows []struct {
+		TableName  string
+		ColumnName string
+	}
+	err := a.db.Raw("SELECT table_name, column_name FROM information_schema.columns WHERE table_schema = current_schema()").
+		Scan(&columnRows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to read table columns: %w", err)
+	}
+	columns := make(map[string]map[string]bool)
+	for _, row := range columnRows {
+		if columns[row.TableName] == nil {
+			columns[row.TableName] = make(map[string]bool)
+		}
+		columns[row.TableName][row.ColumnName] = true
+	}
+
+	var indexRows []struct {
+		TableName  string
+		ColumnName string
+	}
+	err = a.db.Raw(`SELECT t.relname AS table_name, a.attname AS column_name
+		FROM pg_index i
+		JOIN pg_class t ON t.oid = i.indrelid
+		JOIN pg_namespace n ON n.oid = t.relnamespace
+		JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = i.indkey[0]
+		WHERE n.nspname = current_schema()`).
+		Scan(&indexRows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to read indexes: %w", err)
+	}
+	indexed := make(map[string]bool)
+	for _, row := range indexRows {
+		indexed[row.TableName+"."+row.ColumnName] = true
+	}
+
+	var statRows []struct {
+		Relname  string
+		NLiveTup int64
+		SeqScan  int64
+		IdxScan  int64
+	}
+	err = a.db.Raw("SELECT relname, n_live_tup, seq_scan, COALESCE(idx_scan, 0) AS idx_scan FROM pg_stat_user_tables WHERE schemaname = current_schema()").
+		Scan(&statRows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to read table statistics: %w", err)
+	}
+	stats := make(map[string]tableStats)
+	for _, row := range statRows {
+		stats[row.Relname] = tableStats{Rows: row.NLiveTup, SeqScans: row.SeqScan, IndexScans: row.IdxScan}
+	}
+
+	suggestions := make(map[string]*IndexSuggestion)
+	for _, query := range queries {
+		tables := make(map[string]bool)
+		for _, match := range queryTablePattern.FindAllStringSubmatch(query.Query, -1) {
+			if columns[match[1]] != nil {
+				tables[match[1]] = true
+			}
+		}
+
+		// Only the filters, not the select list, say what an index would serve
+		filters := query.Query
+		if i := strings.Index(strings.ToUpper(filters), " FROM "); i >= 0 {
+			filters = filters[i:]
+		}
+
+		seen := make(map[string]bool)
+		matches := queryFilterPattern.FindAllStringSubmatch(filters, -1)
+		matches = append(matches, queryJoinPattern.FindAllStringSubmatch(filters, -1)...)
+		for _, match := range matches {
+			qualifier, column := match[1], match[2]
+			for table := range tables {
+				if qualifier != "" && tables[qualifier] && qualifier != table {
+					continue
+				}
+				key := table + "." + column
+				if !columns[table][column] || indexed[key] || seen[key] || stats[table].Rows < a.config.MinRows {
+					continue
+				}
+				seen[key] = true
+
+				suggestion, ok := suggestions[key]
+				if !ok {
+					suggestion = &IndexSuggestion{
+						Table:      table,
+						Column:     column,
+						Statement:  fmt.Sprintf("CREATE INDEX CONCURRENTLY idx_%s_%s ON %s (%s)", table, column, table, column),
+						TableRows:  stats[table].Rows,
+						SeqScans:   stats[table].SeqScans,
+						IndexScans: stats[table].IndexScans,
+					}
+					suggestions[key] = suggestion
+				}
+				suggestion.Queries++
+				suggestion.TotalTimeMs += query.TotalTimeMs
+			}
+		}
+	}
+
+	ranked := make([]IndexSuggestion, 0, len(suggestions))
+	for _, suggestion := range suggestions {
+		ranked = append(ranked, *suggestion)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].TotalTimeMs != ranked[j].TotalTimeMs {
+			return ranked[i].TotalTimeMs > ranked[j].TotalTimeMs
+		}
+		return ranked[i].Table+"."+ranked[i].Column < ranked[j].Table+"."+ranked[j].Column
+	})
+	return ranked, nil
+}
@@ -0,0 +1,210 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+	"vessel-tracker/logging"
+
+	geojson "github.com/paulmach/go.geojson"
+)
+
+// DefaultLandPath is the GeoJSON file land polygons are read from when
+// LAND_FILE is not set
+var DefaultLandPath = filepath.Join(".", "data", "land.geojson")
+
+// DefaultLandToleranceMeters is how far inland from the coastline a position
+// must plot to be flagged, so vessels in marinas and along quays, where the
+// mapped coastline is coarse, are not
+const DefaultLandToleranceMeters = 50.0
+
+// LandMaskConfig holds where the land mask is read from and whether it is
+// fetched from OpenStreetMap when the file does not exist
+type LandMaskConfig struct {
+	Path            string
+	ToleranceMeters float64
+	AutoFetch       bool
+	OverpassURL     string
+}
+
+func DefaultLandMaskConfig() LandMaskConfig {
+	return LandMaskConfig{
+		Path:            DefaultLandPath,
+		ToleranceMeters: DefaultLandToleranceMeters,
+		OverpassURL:     DefaultOverpassURL,
+	}
+}
+
+// LoadLandMaskConfig reads LAND_FILE, LAND_TOLERANCE_METERS,
+// COASTLINE_AUTO_FETCH and OVERPASS_URL, falling back to the defaults when
+// unset
+func LoadLandMaskConfig() (LandMaskConfig, error) {
+	config := DefaultLandMaskConfig()
+
+	if path := os.Getenv("LAND_FILE"); path != "" {
+		config.Path = path
+	}
+	if value := os.Getenv("LAND_TOLERANCE_METERS"); value != "" {
+		meters, err := strconv.ParseFloat(value, 64)
+		if err != nil || meters < 0 {
+			return config, fmt.Errorf("invalid LAND_TOLERANCE_METERS %q: must be a non-negative number", value)
+		}
+		config.ToleranceMeters = meters
+	}
+	if value := os.Getenv("COASTLINE_AUTO_FETCH"); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return config, fmt.Errorf("invalid COASTLINE_AUTO_FETCH %q: %w", value, err)
+		}
+		config.AutoFetch = enabled
+	}
+	if value := os.Getenv("OVERPASS_URL"); value != "" {
+		config.OverpassURL = value
+	}
+
+	return config, nil
+}
+
+// LandMask flags positions that plot on land, which can only come from GPS
+// errors. Such positions are anomalies rather than presence in a park.
+type LandMask struct {
+	zones           []zoneRings
+	grid            *zoneGrid
+	features        int
+	toleranceMeters float64
+	payload         *StaticPayload
+}
+
+// NewLandMask builds the mask from land polygons. Positions within
+// toleranceMeters of the coastline are never flagged.
+func NewLandMask(fc *geojson.FeatureCollection, toleranceMeters float64, lastModified time.Time) (*LandMask, error) {
+	payload, err := newBoundaryPayload(fc, lastModified)
+	if err != nil {
+		return nil, err
+	}
+
+	zones := make([]zoneRings, 0, len(fc.Features))
+	for _, feature := range fc.Features {
+		zones = append(zones, featureZoneRings(feature, toleranceMeters))
+	}
+
+	return &LandMask{
+		zones:           zones,
+		grid:            buildZoneGrid(zones, DefaultZoneGridCellDegrees),
+		features:        len(fc.Features),
+		toleranceMeters: toleranceMeters,
+		payload:         payload,
+	}, nil
+}
+
+// OnLand reports whether a point lies on land, further than the tolerance
+// from the coastline. A nil mask flags nothing.
+func (m *LandMask) OnLand(lat, lon float64) bool {
+	if m == nil {
+		return false
+	}
+	if inside, ok := m.grid.lookup(lat, lon); ok {
+		return inside
+	}
+
+	point := []float64{lon, lat}
+	for _, zone := range m.zones {
+		for _, ring := range zone.outer {
+			if !ringContains(ring, point) {
+				continue
+			}
+			nearest := nearestOnRings(zone.all, lat, lon)
+			return nearest == nil || nearest.DistanceMeters > m.toleranceMeters
+		}
+	}
+	return false
+}
+
+// ToleranceMeters returns how far inland a position must plot to be flagged
+func (m *LandMask) ToleranceMeters() float64 {
+	return m.toleranceMeters
+}
+
+// Features returns the number of land polygons
+func (m *LandMask) Features() int {
+	return m.features
+}
+
+// Payload returns the land polygons as GeoJSON
+func (m *LandMask) Payload() *StaticPayload {
+	return m.payload
+}
+
+// LoadLandMask reads the land mask from config.Path. When the file does not
+// exist, the coastline of the region is fetched from OpenStreetMap and saved
+// there if config.AutoFetch is set; otherwise, or if the fetch fails, it
+// returns nil and no position is flagged.
+func LoadLandMask(ctx context.Context, config LandMaskConfig, region Region) (*LandMask, error) {
+	logger := logging.Component("land_mask")
+
+	info, err := os.Stat(config.Path)
+	if os.IsNotExist(err) {
+		if !config.AutoFetch {
+			logger.Info("No land mask file, positions on land are not flagged", "path", config.Path)
+			return nil, nil
+		}
+
+		logger.Info("Fetching the coastline from OpenStreetMap", "overpass_url", config.OverpassURL, "region", region)
+		fc, err := FetchCoastline(ctx, config.OverpassURL, region)
+		if err != nil {
+			logger.Warn("Failed to fetch the coastline, positions on land are not flagged", "error", err)
+			return nil, nil
+		}
+		if err := WriteLandFile(config.Path, fc); err != nil {
+			return nil, err
+		}
+		info, err = os.Stat(config.Path)
+		if err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read land mask: %w", err)
+	}
+
+	data, err := os.ReadFile(config.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read land mask: %w", err)
+	}
+	fc, err := geojson.UnmarshalFeatureCollection(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse land mask %s: %w", config.Path, err)
+	}
+
+	mask, err := NewLandMask(fc, config.ToleranceMeters, info.ModTime())
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("Loaded land mask", "path", config.Path, "features", mask.Features(), "tolerance_meters", config.ToleranceMeters)
+	return mask, nil
+}
+
+// WriteLandFile saves land polygons as GeoJSON, replacing the file only once
+// it is completely written
+func WriteLandFile(path string, fc *geojson.FeatureCollection) error {
+	data, err := json.Marshal(fc)
+	if err != nil {
+		return fmt.Errorf("failed to encode land mask: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create land mask directory: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write land mask: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write land mask: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,242 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+	"vessel-tracker/database"
+	"vessel-tracker/logging"
+	"vessel-tracker/models"
+
+	"github.com/robfig/cron/v3"
+	"gorm.io/gorm"
+)
+
+// LogJobComponents are the components of the scheduled jobs and of position
+// ingestion, whose records are stored down to info level so a run can be
+// followed from start to end
+var LogJobComponents = []string{"scheduler", "retention", "aggregates", "datalastic", "datalastic_quota", "aisstream", "ais", "vessel_repository", "zone_events"}
+
+// LogStoreConfig sets which log records are stored for the admin log export
+type LogStoreConfig struct {
+	Enabled       bool
+	Level         slog.Level    // minimum level stored for any component
+	JobLevel      slog.Level    // minimum level stored for LogJobComponents
+	FlushInterval time.Duration // how often buffered records are written
+	MaxPending    int           // records buffered between flushes; the oldest are dropped beyond it
+}
+
+func DefaultLogStoreConfig() LogStoreConfig {
+	return LogStoreConfig{
+		Enabled:       true,
+		Level:         slog.LevelWarn,
+		JobLevel:      slog.LevelInfo,
+		FlushInterval: 5 * time.Second,
+		MaxPending:    5000,
+	}
+}
+
+// LoadLogStoreConfig reads LOG_STORE_ENABLED, LOG_STORE_LEVEL,
+// LOG_STORE_JOB_LEVEL (debug, info, warn or error) and
+// LOG_STORE_FLUSH_INTERVAL, falling back to the defaults when unset
+func LoadLogStoreConfig() (LogStoreConfig, error) {
+	config := DefaultLogStoreConfig()
+
+	if value := os.Getenv("LOG_STORE_ENABLED"); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return config, fmt.Errorf("invalid LOG_STORE_ENABLED %q: must be true or false", value)
+		}
+		config.Enabled = enabled
+	}
+
+	levelVars := map[string]*slog.Level{
+		"LOG_STORE_LEVEL":     &config.Level,
+		"LOG_STORE_JOB_LEVEL": &config.JobLevel,
+	}
+	for name, target := range levelVars {
+		if value := os.Getenv(name); value != "" {
+			if err := target.UnmarshalText([]byte(value)); err != nil {
+				return config, fmt.Errorf("invalid %s %q: expected debug, info, warn or error", name, value)
+			}
+		}
+	}
+
+	if value := os.Getenv("LOG_STORE_FLUSH_INTERVAL"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d < time.Second {
+			return config, fmt.Errorf("invalid LOG_STORE_FLUSH_INTERVAL %q: must be a duration of at least 1s", value)
+		}
+		config.FlushInterval = d
+	}
+
+	return config, nil
+}
+
+// LogFilter narrows a stored log query; empty fields match everything
+type LogFilter struct {
+	Job   string // component name
+	Level slog.Level
+	RunID string
+	Since time.Time
+	Limit int
+}
+
+// LogStore keeps log records in the database so operators without access
+// to the server's output can diagnose failed fetches and jobs. It is a
+// logging.Sink: records are buffered in memory and written every flush
+// interval and on Stop, so logging costs no database write per record.
+type LogStore struct {
+	db     *gorm.DB
+	config LogStoreConfig
+	cron   *cron.Cron
+	logger *slog.Logger
+	jobs   map[string]bool
+
+	mu      sync.Mutex
+	pending []models.LogEntry
+	dropped int
+
+	flushMu sync.Mutex
+}
+
+func NewLogStore(config LogStoreConfig) *LogStore {
+	jobs := make(map[string]bool, len(LogJobComponents))
+	for _, component := range LogJobComponents {
+		jobs[component] = true
+	}
+	return &LogStore{
+		db:     database.GetDB(),
+		config: config,
+		cron:   cron.New(),
+		logger: logging.Component("log_store"),
+		jobs:   jobs,
+	}
+}
+
+func (s *LogStore) Start() error {
+	if _, err := s.cron.AddFunc(fmt.Sprintf("@every %s", s.config.FlushInterval), s.flushLogged); err != nil {
+		return err
+	}
+	s.cron.Start()
+	return nil
+}
+
+// Stop ends the periodic flushes and writes the records not yet stored
+func (s *LogStore) Stop() {
+	<-s.cron.Stop().Done()
+	s.flushLogged()
+}
+
+// Accept reports whether records of a component at a level are stored. The
+// store's own records are not, so a failing flush cannot feed itself.
+func (s *LogStore) Accept(level slog.Level, component string) bool {
+	if !s.config.Enabled || component == "log_store" {
+		return false
+	}
+	if s.jobs[component] && level >= s.config.JobLevel {
+		return true
+	}
+	return level >= s.config.Level
+}
+
+// Write buffers a record until the next flush
+func (s *LogStore) Write(entry logging.Entry) {
+	record := models.LogEntry{
+		LoggedAt:  entry.Time,
+		Level:     entry.Level.String(),
+		Severity:  int(entry.Level),
+		Component: entry.Component,
+		Message:   entry.Message,
+		Attrs:     make(models.LogAttrs, len(entry.Attrs)),
+	}
+	for key, value := range entry.Attrs {
+		if key == "run_id" {
+			record.RunID = fmt.Sprint(value)
+			continue
+		}
+		// Values that do not encode as JSON are kept as their text
+		if _, err := json.Marshal(value); err != nil {
+			value = fmt.Sprint(value)
+		}
+		record.Attrs[key] = value
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.pending) >= s.config.MaxPending {
+		s.pending = s.pending[1:]
+		s.dropped++
+	}
+	s.pending = append(s.pending, record)
+}
+
+func (s *LogStore) flushLogged() {
+	if err := s.Flush(); err != nil {
+		s.logger.Error("Failed to store log records", "error", err)
+	}
+}
+
+// Flush writes the records buffered since the last flush. Records that fail
+// to store are kept for the next flush, up to the buffer limit.
+func (s *LogStore) Flush() error {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+
+	s.mu.Lock()
+	pending, dropped := s.pending, s.dropped
+	s.pending, s.dropped = nil, 0
+	s.mu.Unlock()
+
+	if dropped > 0 {
+		s.logger.Warn("Dropped log records buffered faster than they were stored", "dropped", dropped)
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	if err := s.db.CreateInBatches(pending, 500).Error; err != nil {
+		s.mu.Lock()
+		s.pending = append(pending, s.pending...)
+		if excess := len(s.pending) - s.config.MaxPending; excess > 0 {
+			s.pending = s.pending[excess:]
+			s.dropped += excess
+		}
+		s.mu.Unlock()
+		return fmt.Errorf("failed to store %d log records: %w", len(pending), err)
+	}
+	return nil
+}
+
+// GetLogs returns the stored records matching the filter, most recent first.
+// Buffered records are flushed first.
+func (s *LogStore) GetLogs(filter LogFilter) ([]models.LogEntry, error) {
+	if err := s.Flush(); err != nil {
+		return nil, err
+	}
+
+	logs := []models.LogEntry{}
+	query := s.db.Order("logged_at DESC, id DESC").Where("severity >= ?", int(filter.Level))
+	if filter.Job != "" {
+		query = query.Where("component = ?", filter.Job)
+	}
+	if filter.RunID != "" {
+		query = query.Where("run_id = ?", filter.RunID)
+	}
+	if !filter.Since.IsZero() {
+		query = query.Where("logged_at >= ?", filter.Since)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	if err := query.Find(&logs).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch log records: %w", err)
+	}
+	return logs, nil
+}
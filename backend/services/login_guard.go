@@ -0,0 +1,222 @@
+package services
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+	"vessel-tracker/logging"
+	"vessel-tracker/models"
+)
+
+// LoginGuardConfig holds the brute-force protection thresholds
+type LoginGuardConfig struct {
+	MaxFailures     int           // failed attempts from one client before it is locked out
+	FailureWindow   time.Duration // window in which failures are counted
+	LockoutDuration time.Duration // how long a locked out client is refused
+	RateLimit       int           // auth endpoint requests allowed per client per minute
+}
+
+func DefaultLoginGuardConfig() LoginGuardConfig {
+	return LoginGuardConfig{
+		MaxFailures:     5,
+		FailureWindow:   15 * time.Minute,
+		LockoutDuration: 15 * time.Minute,
+		RateLimit:       10,
+	}
+}
+
+// LoadLoginGuardConfig reads LOGIN_MAX_FAILURES, LOGIN_FAILURE_WINDOW,
+// LOGIN_LOCKOUT_DURATION and AUTH_RATE_LIMIT, falling back to the defaults
+func LoadLoginGuardConfig() (LoginGuardConfig, error) {
+	config := DefaultLoginGuardConfig()
+
+	intVars := map[string]*int{
+		"LOGIN_MAX_FAILURES": &config.MaxFailures,
+		"AUTH_RATE_LIMIT":    &config.RateLimit,
+	}
+	for name, target := range intVars {
+		if value := os.Getenv(name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return config, fmt.Errorf("invalid %s %q: must be a positive integer", name, value)
+			}
+			*target = n
+		}
+	}
+
+	durationVars := map[string]*time.Duration{
+		"LOGIN_FAILURE_WINDOW":   &config.FailureWindow,
+		"LOGIN_LOCKOUT_DURATION": &config.LockoutDuration,
+	}
+	for name, target := range durationVars {
+		if value := os.Getenv(name); value != "" {
+			d, err := time.ParseDuration(value)
+			if err != nil || d <= 0 {
+				return config, fmt.Errorf("invalid %s %q: must be a positive duration", name, value)
+			}
+			*target = d
+		}
+	}
+
+	return config, nil
+}
+
+// clientState tracks recent authentication activity of one client IP
+type clientState struct {
+	failures    []time.Time
+	lockedUntil time.Time
+	windowStart time.Time
+	requests    int
+}
+
+// LoginGuard counts failed authentication attempts per client IP, locks out
+// clients that exceed the limit and rate limits the auth endpoints. Failures,
+// lockouts and anomalies are recorded as security events; lockouts and token
+// reuse are raised as alerts.
+type LoginGuard struct {
+	config       LoginGuardConfig
+	auditService *AuditService
+	logger       *slog.Logger
+
+	mu      sync.Mutex
+	clients map[string]*clientState
+}
+
+func NewLoginGuard(config LoginGuardConfig, auditService *AuditService) *LoginGuard {
+	return &LoginGuard{
+		config:       config,
+		auditService: auditService,
+		logger:       logging.Component("login_guard"),
+		clients:      make(map[string]*clientState),
+	}
+}
+
+// LockedUntil reports whether a client is locked out and until when
+func (g *LoginGuard) LockedUntil(clientIP string) (time.Time, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	state, ok := g.clients[clientIP]
+	if !ok || time.Now().After(state.lockedUntil) {
+		return time.Time{}, false
+	}
+	return state.lockedUntil, true
+}
+
+// Allow applies the per-minute rate limit for auth endpoints. It returns how
+// long the client has to wait when the limit is exceeded.
+func (g *LoginGuard) Allow(clientIP, peerIP, path string) (bool, time.Duration) {
+	now := time.Now()
+
+	g.mu.Lock()
+	state := g.client(clientIP)
+	if now.Sub(state.windowStart) >= time.Minute {
+		state.windowStart = now
+		state.requests = 0
+	}
+	state.requests++
+	requests := state.requests
+	retryAfter := state.windowStart.Add(time.Minute).Sub(now)
+	g.mu.Unlock()
+
+	if requests <= g.config.RateLimit {
+		return true, 0
+	}
+
+	// Record only the first rejected request of each window
+	if requests == g.config.RateLimit+1 {
+		g.record(models.SecurityEvent{
+			Type:     models.SecurityEventRateLimited,
+			ClientIP: clientIP,
+			PeerIP:   peerIP,
+			Path:     path,
+			Details:  fmt.Sprintf("more than %d auth requests per minute", g.config.RateLimit),
+		})
+	}
+
+	return false, retryAfter
+}
+
+// RecordFailure registers a failed authentication attempt and locks the
+// client out once it reaches the failure limit. peerIP is the address the
+// request came from, which differs from clientIP behind a trusted proxy.
+func (g *LoginGuard) RecordFailure(clientIP, peerIP, actor, path, reason string) {
+	now := time.Now()
+
+	g.mu.Lock()
+	state := g.client(clientIP)
+
+	recent := state.failures[:0]
+	for _, at := range state.failures {
+		if now.Sub(at) < g.config.FailureWindow {
+			recent = append(recent, at)
+		}
+	}
+	state.failures = append(recent, now)
+	failures := len(state.failures)
+
+	locked := failures >= g.config.MaxFailures && now.After(state.lockedUntil)
+	if locked {
+		state.lockedUntil = now.Add(g.config.LockoutDuration)
+		state.failures = nil
+	}
+	g.mu.Unlock()
+
+	g.record(models.SecurityEvent{
+		Type:     models.SecurityEventLoginFailed,
+		Actor:    actor,
+		ClientIP: clientIP,
+		PeerIP:   peerIP,
+		Path:     path,
+		Details:  reason,
+	})
+
+	if locked {
+		g.Alert(models.SecurityEvent{
+			Type:     models.SecurityEventLockout,
+			Actor:    actor,
+			ClientIP: clientIP,
+			PeerIP:   peerIP,
+			Path:     path,
+			Details:  fmt.Sprintf("%d failed attempts within %s, locked out for %s", failures, g.config.FailureWindow, g.config.LockoutDuration),
+		})
+	}
+}
+
+// Alert records a security event that needs an administrator's attention
+func (g *LoginGuard) Alert(event models.SecurityEvent) {
+	event.Alert = true
+	g.logger.Error("SECURITY ALERT", "event", event.Type, "client_ip", event.ClientIP, "peer_ip", event.PeerIP, "actor", event.Actor, "details", event.Details)
+	g.record(event)
+}
+
+func (g *LoginGuard) record(event models.SecurityEvent) {
+	if err := g.auditService.RecordSecurityEvent(&event); err != nil {
+		g.logger.Error("Failed to record security event", "event", event.Type, "error", err)
+	}
+}
+
+// client returns the state for a client IP, dropping idle clients first so
+// the map does not grow without bound. Callers must hold g.mu.
+func (g *LoginGuard) client(clientIP string) *clientState {
+	if state, ok := g.clients[clientIP]; ok {
+		return state
+	}
+
+	if len(g.clients) >= 1000 {
+		now := time.Now()
+		for ip, state := range g.clients {
+			idle := now.After(state.lockedUntil) && now.Sub(state.windowStart) >= time.Minute
+			if idle && (len(state.failures) == 0 || now.Sub(state.failures[len(state.failures)-1]) >= g.config.FailureWindow) {
+				delete(g.clients, ip)
+			}
+		}
+	}
+
+	state := &clientState{}
+	g.clients[clientIP] = state
+	return state
+}
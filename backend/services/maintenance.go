@@ -0,0 +1,141 @@
+package services
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+	"vessel-tracker/logging"
+)
+
+// DefaultMaintenanceRetryAfter is how long clients are told to wait when
+// maintenance is enabled without an estimate
+const DefaultMaintenanceRetryAfter = 5 * time.Minute
+
+// DefaultMaintenanceMessage is shown to clients when maintenance is enabled
+// without a message
+const DefaultMaintenanceMessage = "The API is down for maintenance, please try again later"
+
+// MaintenanceStatus describes whether the API is in maintenance mode
+type MaintenanceStatus struct {
+	Enabled      bool       `json:"enabled"`
+	Message      string     `json:"message,omitempty"`
+	Since        *time.Time `json:"since,omitempty"`
+	Until        *time.Time `json:"until,omitempty"` // expected end, the basis of Retry-After
+	EnabledBy    string     `json:"enabled_by,omitempty"`
+	FetchRunning bool       `json:"fetch_running"`
+}
+
+// MaintenanceService holds the maintenance mode toggle. While it is enabled
+// non-admin API requests are refused and the scheduler is paused, so database
+// migrations can run without clients and jobs using half-migrated tables.
+// The toggle is kept in memory; MAINTENANCE_MODE=true starts the server with
+// it enabled.
+type MaintenanceService struct {
+	scheduler *SchedulerService
+	logger    *slog.Logger
+
+	mu     sync.Mutex
+	status MaintenanceStatus
+}
+
+func NewMaintenanceService(scheduler *SchedulerService) *MaintenanceService {
+	return &MaintenanceService{
+		scheduler: scheduler,
+		logger:    logging.Component("maintenance"),
+	}
+}
+
+// LoadMaintenanceMode reads MAINTENANCE_MODE, whether the server starts in
+// maintenance mode
+func LoadMaintenanceMode() (bool, error) {
+	value := os.Getenv("MAINTENANCE_MODE")
+	if value == "" {
+		return false, nil
+	}
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid MAINTENANCE_MODE %q: %w", value, err)
+	}
+	return enabled, nil
+}
+
+// Enable puts the API into maintenance mode and pauses the scheduler. A
+// retryAfter of zero uses DefaultMaintenanceRetryAfter; an empty message uses
+// DefaultMaintenanceMessage. Enabling it again updates the message and the
+// expected end.
+func (s *MaintenanceService) Enable(message string, retryAfter time.Duration, actor string) MaintenanceStatus {
+	if message == "" {
+		message = DefaultMaintenanceMessage
+	}
+	if retryAfter <= 0 {
+		retryAfter = DefaultMaintenanceRetryAfter
+	}
+
+	s.scheduler.Pause()
+
+	s.mu.Lock()
+	now := time.Now()
+	until := now.Add(retryAfter)
+	if !s.status.Enabled {
+		s.status.Since = &now
+	}
+	s.status.Enabled = true
+	s.status.Message = message
+	s.status.Until = &until
+	s.status.EnabledBy = actor
+	s.mu.Unlock()
+
+	s.logger.Warn("Maintenance mode enabled", "actor", actor, "until", until, "message", message)
+	return s.Status()
+}
+
+// Disable ends maintenance mode and resumes the scheduler
+func (s *MaintenanceService) Disable(actor string) MaintenanceStatus {
+	s.mu.Lock()
+	wasEnabled := s.status.Enabled
+	s.status = MaintenanceStatus{}
+	s.mu.Unlock()
+
+	s.scheduler.Resume()
+
+	if wasEnabled {
+		s.logger.Warn("Maintenance mode disabled", "actor", actor)
+	}
+	return s.Status()
+}
+
+// Status returns the maintenance mode and whether a fetch started before it
+// is still running
+func (s *MaintenanceService) Status() MaintenanceStatus {
+	s.mu.Lock()
+	status := s.status
+	s.mu.Unlock()
+
+	status.FetchRunning = s.scheduler.Status().Running
+	return status
+}
+
+// RetryAfter returns how long until maintenance is expected to end. Once
+// that has passed without maintenance being disabled, clients are told to
+// retry in a minute.
+func (s *MaintenanceService) RetryAfter() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.status.Until != nil {
+		if wait := time.Until(*s.status.Until); wait > time.Minute {
+			return wait
+		}
+	}
+	return time.Minute
+}
+
+// Enabled reports whether the API is in maintenance mode
+func (s *MaintenanceService) Enabled() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status.Enabled
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/template"
+	"time"
+	"vessel-tracker/models"
+)
+
+// Default legal references per violation type, used when the template does
+// not provide its own wording
+var noticeLegalReferences = map[string]string{
+	models.ViolationInBufferZone:        "D.P.R. 17 May 1996 establishing the La Maddalena Archipelago National Park, Art. 2 - navigation within protected marine zones.",
+	models.ViolationExcessiveSpeed:      "Park regulation on navigation speed within the protected area (max 5 knots).",
+	models.ViolationAnchoredOnPosidonia: "D.P.R. 17 May 1996, Art. 2 - anchoring restrictions; Directive 92/43/EEC, Annex I habitat 1120*.",
+	models.ViolationInRestrictedArea:    "D.P.R. 17 May 1996, Art. 2 - access to zones of integral protection.",
+}
+
+var noticeTitles = map[string]string{
+	models.ViolationInBufferZone:        "Unauthorized presence in buffer zone",
+	models.ViolationExcessiveSpeed:      "Excessive speed within the park",
+	models.ViolationAnchoredOnPosidonia: "Anchoring on Posidonia oceanica meadow",
+	models.ViolationInRestrictedArea:    "Unauthorized presence in restricted area",
+}
+
+// NoticeData holds the placeholder values available to notice templates
+type NoticeData struct {
+	Authority       string
+	Reference       string
+	IssuedAt        time.Time
+	OwnerName       string
+	Contacts        []models.OperatorContact
+	VesselName      string
+	MMSI            string
+	IMO             string
+	ViolationType   string
+	ViolationTitle  string
+	DetectedAt      time.Time
+	Latitude        float64
+	Longitude       float64
+	Speed           float64
+	Current         *models.TidalCurrent // predicted tidal current, nil when unknown
+	Details         string
+	LegalReferences string
+
+	SpeedThroughWater float64 // 0 when the current is unknown
+}
+
+// NoticeConfig sets how violation notices are issued
+type NoticeConfig struct {
+	Authority string // issuing authority named on every notice
+}
+
+func DefaultNoticeConfig() NoticeConfig {
+	return NoticeConfig{Authority: "Ente Parco Nazionale Arcipelago di La Maddalena"}
+}
+
+// LoadNoticeConfig reads NOTICE_AUTHORITY, falling back to the park authority
+// of La Maddalena
+func LoadNoticeConfig() NoticeConfig {
+	config := DefaultNoticeConfig()
+	if value := os.Getenv("NOTICE_AUTHORITY"); value != "" {
+		config.Authority = value
+	}
+	return config
+}
+
+type NoticeService struct {
+	templateDir     string
+	authority       string
+	operatorService *OperatorService
+}
+
+func NewNoticeService(templateDir string, config NoticeConfig, operatorService *OperatorService) *NoticeService {
+	return &NoticeService{
+		templateDir:     templateDir,
+		authority:       config.Authority,
+		operatorService: operatorService,
+	}
+}
+
+// BuildNoticeData fills the template placeholders for a violation
+func (s *NoticeService) BuildNoticeData(violation *models.Violation) NoticeData {
+	data := NoticeData{
+		Authority:       s.authority,
+		Reference:       fmt.Sprintf("VIOL-%d-%06d", violation.DetectedAt.Year(), violation.ID),
+		IssuedAt:        time.Now().UTC(),
+		OwnerName:       "Owner/operator of the vessel",
+		VesselName:      violation.VesselName,
+		MMSI:            violation.MMSI,
+		IMO:             violation.IMO,
+		ViolationType:   violation.Type,
+		ViolationTitle:  noticeTitles[violation.Type],
+		DetectedAt:      violation.DetectedAt.UTC(),
+		Latitude:        violation.Latitude,
+		Longitude:       violation.Longitude,
+		Speed:           violation.Speed,
+		Current:         violation.Current(),
+		Details:         violation.Details,
+		LegalReferences: noticeLegalReferences[violation.Type],
+	}
+	if violation.SpeedThroughWater != nil {
+		data.SpeedThroughWater = *violation.SpeedThroughWater
+	}
+
+	if data.ViolationTitle == "" {
+		data.ViolationTitle = violation.Type
+	}
+
+	if violation.Operator != nil {
+		data.OwnerName = violation.Operator.Name
+		if contacts, err := s.operatorService.GetContacts(violation.Operator.ID); err == nil {
+			data.Contacts = contacts
+		}
+	}
+
+	return data
+}
+
+// RenderNotice renders the notice text using default.tmpl. A template named
+// after the violation type (e.g. anchored_on_posidonia.tmpl) can override the
+// blocks defined in default.tmpl for that type.
+func (s *NoticeService) RenderNotice(violation *models.Violation) (string, error) {
+	files := []string{filepath.Join(s.templateDir, "default.tmpl")}
+
+	typeFile := filepath.Join(s.templateDir, violation.Type+".tmpl")
+	if _, err := os.Stat(typeFile); err == nil {
+		files = append(files, typeFile)
+	}
+
+	tmpl, err := template.ParseFiles(files...)
+	if err != nil {
+		return "", fmt.Errorf("failed to load notice templates: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, "default.tmpl", s.BuildNoticeData(violation)); err != nil {
+		return "", fmt.Errorf("failed to render notice: %w", err)
+	}
+
+	return buf.String(), nil
+}
@@ -0,0 +1,537 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"vessel-tracker/database"
+	"vessel-tracker/logging"
+	"vessel-tracker/models"
+
+	"gorm.io/gorm"
+)
+
+// notificationSeverities are the alert severities routes are set for, most
+// severe first
+var notificationSeverities = []string{models.SeverityCritical, models.SeverityAuthorizedInfraction, models.SeverityHigh, models.SeverityMedium, models.SeverityLow}
+
+// NotificationConfig holds the notification channels and which of them each
+// alert severity is sent on
+type NotificationConfig struct {
+	SMTP             SMTPConfig
+	SlackWebhookURL  string `secret:"true"`
+	TelegramBotToken string `secret:"true"`
+	TelegramChatID   string
+	Routes           map[string][]string // channels by severity, in order of preference
+	Timeout          time.Duration       // per delivery attempt
+	MaxSuppression   time.Duration       // longest window the alerts about a vessel may be suppressed for
+	NotifyOperators  bool                // mail violation notices to the contacts of the vessel's operator
+}
+
+func DefaultNotificationConfig() NotificationConfig {
+	return NotificationConfig{
+		SMTP:           SMTPConfig{Port: 587},
+		Timeout:        10 * time.Second,
+		MaxSuppression: defaultMaxSuppression,
+	}
+}
+
+// LoadNotificationConfig reads the channel settings NOTIFY_SMTP_*,
+// NOTIFY_SLACK_WEBHOOK_URL and NOTIFY_TELEGRAM_*, and NOTIFY_ROUTES, such as
+// "critical=telegram,slack,smtp;high=slack,smtp". Without NOTIFY_ROUTES
+// critical, authorized_infraction and high alerts go to every configured
+// channel. NOTIFY_OPERATORS mails violation notices to vessel operators
+// through the SMTP server.
+func LoadNotificationConfig() (NotificationConfig, error) {
+	config := DefaultNotificationConfig()
+
+	config.SMTP.Host = os.Getenv("NOTIFY_SMTP_HOST")
+	if value := os.Getenv("NOTIFY_SMTP_PORT"); value != "" {
+		port, err := strconv.Atoi(value)
+		if err != nil || port < 1 || port > 65535 {
+			return config, fmt.Errorf("invalid NOTIFY_SMTP_PORT %q: must be a port number", value)
+		}
+		config.SMTP.Port = port
+	}
+	config.SMTP.Username = os.Getenv("NOTIFY_SMTP_USERNAME")
+	config.SMTP.Password = os.Getenv("NOTIFY_SMTP_PASSWORD")
+	config.SMTP.From = os.Getenv("NOTIFY_SMTP_FROM")
+	for _, to := range strings.Split(os.Getenv("NOTIFY_SMTP_TO"), ",") {
+		if to = strings.TrimSpace(to); to != "" {
+			config.SMTP.To = append(config.SMTP.To, to)
+		}
+	}
+	if config.SMTP.Host != "" && (config.SMTP.From == "" || len(config.SMTP.To) == 0) {
+		return config, fmt.Errorf("NOTIFY_SMTP_HOST needs NOTIFY_SMTP_FROM and NOTIFY_SMTP_TO")
+	}
+
+	if value := os.Getenv("NOTIFY_OPERATORS"); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return config, fmt.Errorf("invalid NOTIFY_OPERATORS %q: %w", value, err)
+		}
+		if enabled && config.SMTP.Host == "" {
+			return config, fmt.Errorf("NOTIFY_OPERATORS needs NOTIFY_SMTP_HOST")
+		}
+		config.NotifyOperators = enabled
+	}
+
+	config.SlackWebhookURL = os.Getenv("NOTIFY_SLACK_WEBHOOK_URL")
+	config.TelegramBotToken = os.Getenv("NOTIFY_TELEGRAM_BOT_TOKEN")
+	config.TelegramChatID = os.Getenv("NOTIFY_TELEGRAM_CHAT_ID")
+	if (config.TelegramBotToken == "") != (config.TelegramChatID == "") {
+		return config, fmt.Errorf("NOTIFY_TELEGRAM_BOT_TOKEN and NOTIFY_TELEGRAM_CHAT_ID must be set together")
+	}
+
+	if value := os.Getenv("NOTIFY_TIMEOUT"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return config, fmt.Errorf("invalid NOTIFY_TIMEOUT %q: must be a positive duration", value)
+		}
+		config.Timeout = d
+	}
+
+	if value := os.Getenv("NOTIFY_ROUTES"); value != "" {
+		routes, err := parseNotificationRoutes(value)
+		if err != nil {
+			return config, fmt.Errorf("invalid NOTIFY_ROUTES %q: %w", value, err)
+		}
+		config.Routes = routes
+	}
+
+	if err := loadSuppressionConfig(&config); err != nil {
+		return config, err
+	}
+
+	return config, nil
+}
+
+// parseNotificationRoutes reads "severity=channel,channel;severity=..."
+func parseNotificationRoutes(value string) (map[string][]string, error) {
+	routes := make(map[string][]string)
+	for _, part := range strings.Split(value, ";") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		severity, list, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("expected severity=channels, got %q", part)
+		}
+		channels := []string{}
+		for _, channel := range strings.Split(list, ",") {
+			if channel = strings.TrimSpace(channel); channel != "" {
+				channels = append(channels, channel)
+			}
+		}
+		routes[strings.TrimSpace(severity)] = channels
+	}
+	return routes, nil
+}
+
+// notifiers builds the channels that are configured, in the default order of
+// preference
+func (c NotificationConfig) notifiers() []Notifier {
+	var notifiers []Notifier
+	if c.TelegramBotToken != "" {
+		notifiers = append(notifiers, NewTelegramNotifier(c.TelegramBotToken, c.TelegramChatID))
+	}
+	if c.SlackWebhookURL != "" {
+		notifiers = append(notifiers, NewSlackNotifier(c.SlackWebhookURL))
+	}
+	if c.SMTP.Host != "" {
+		notifiers = append(notifiers, NewSMTPNotifier(c.SMTP))
+	}
+	return notifiers
+}
+
+// ErrNoNotificationChannel is returned when an alert has no channel to go to
+var ErrNoNotificationChannel = errors.New("no notification channel is configured for this severity")
+
+// ErrUnknownNotificationChannel is returned for a channel that is not
+// configured
+var ErrUnknownNotificationChannel = errors.New("notification channel is not configured")
+
+// NotificationChannelStatus is the delivery record of a channel since startup
+type NotificationChannelStatus struct {
+	Name          string     `json:"name"`
+	Sent          int64      `json:"sent"`
+	Failed        int64      `json:"failed"`
+	LastSentAt    *time.Time `json:"last_sent_at"`
+	LastFailureAt *time.Time `json:"last_failure_at"`
+	LastError     string     `json:"last_error,omitempty"`
+}
+
+// NotificationService sends alerts for new violations. Each severity is
+// routed to a list of channels in order of preference: the alert goes to the
+// first channel that accepts it, and when every listed channel fails, to the
+// other configured channels. Every attempt is recorded as a
+// NotificationDelivery. Alerts about a vessel under a NotificationSuppression
+// are withheld. Routes changed through the API are kept in memory;
+// NOTIFY_ROUTES applies again after a restart. With NotifyOperators, the
+// contacts of the operator of the vessel are also mailed a notice of its
+// infractions.
+type NotificationService struct {
+	db               *gorm.DB
+	config           NotificationConfig
+	violationService *ViolationService
+	operatorService  *OperatorService
+	parks            *ParkRegistry
+	logger           *slog.Logger
+
+	notifiers      map[string]Notifier
+	order          []string
+	operatorMailer recipientNotifier // nil unless operators are notified
+
+	mu       sync.Mutex
+	routes   map[string][]string
+	statuses map[string]*NotificationChannelStatus
+	done     chan struct{}
+}
+
+func NewNotificationService(config NotificationConfig, violationService *ViolationService, operatorService *OperatorService, parks *ParkRegistry) (*NotificationService, error) {
+	s := &NotificationService{
+		db:               database.GetDB(),
+		config:           config,
+		violationService: violationService,
+		operatorService:  operatorService,
+		parks:            parks,
+		logger:           logging.Component("notifications"),
+		notifiers:        make(map[string]Notifier),
+		statuses:         make(map[string]*NotificationChannelStatus),
+	}
+	for _, notifier := range config.notifiers() {
+		s.notifiers[notifier.Name()] = notifier
+		s.order = append(s.order, notifier.Name())
+		s.statuses[notifier.Name()] = &NotificationChannelStatus{Name: notifier.Name()}
+	}
+	if config.NotifyOperators {
+		s.operatorMailer = NewSMTPNotifier(config.SMTP)
+	}
+
+	routes := config.Routes
+	if routes == nil {
+		routes = map[string][]string{
+			models.SeverityCritical:             s.order,
+			models.SeverityAuthorizedInfraction: s.order,
+			models.SeverityHigh:                 s.order,
+		}
+	}
+	if err := s.SetRoutes(routes); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Start sends an alert for every violation recorded from now on, until the
+// violation subscriptions are closed at shutdown
+func (s *NotificationService) Start() {
+	s.done = make(chan struct{})
+	if len(s.notifiers) == 0 {
+		s.logger.Info("No notification channels configured, violations are not sent")
+		close(s.done)
+		return
+	}
+
+	events, unsubscribe := s.violationService.Subscribe()
+	go func() {
+		defer close(s.done)
+		defer unsubscribe()
+		for event := range events {
+			alert := s.violationAlert(event)
+			suppression, err := s.activeSuppression(event.Vessel.UUID, event.DetectedAt)
+			if err != nil {
+				s.logger.Warn("Failed to check alert suppressions", "violation_id", event.ID, "error", err)
+			}
+			if suppression != nil {
+				s.logger.Info("Violation alert suppressed", "violation_id", event.ID, "suppression_id", suppression.ID)
+				s.recordSuppressed(alert, suppression)
+				continue
+			}
+			if _, err := s.Notify(alert); err != nil && !errors.Is(err, ErrNoNotificationChannel) {
+				s.logger.Error("Failed to send violation alert", "violation_id", event.ID, "severity", event.Severity, "error", err)
+			}
+			s.notifyOperator(event, alert)
+		}
+	}()
+	s.logger.Info("Sending alerts", "channels", s.order, "routes", s.Routes())
+}
+
+// Stop waits for the alert in progress once the violation subscriptions are
+// closed
+func (s *NotificationService) Stop() {
+	if s.done != nil {
+		<-s.done
+	}
+}
+
+// violationAlert describes a new violation for park staff
+func (s *NotificationService) violationAlert(event ViolationEvent) Alert {
+	parkName := fmt.Sprintf("park %d", event.ParkID)
+	if park, ok := s.parks.GetByID(event.ParkID); ok {
+		parkName = park.Record.Name
+	}
+	vessel := event.Vessel.Name
+	if vessel == "" {
+		vessel = "Unknown vessel"
+	}
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "Vessel: %s", vessel)
+	if event.Vessel.MMSI != "" {
+		fmt.Fprintf(&msg, " (MMSI %s)", event.Vessel.MMSI)
+	}
+	fmt.Fprintf(&msg, "\nPark: %s", parkName)
+	fmt.Fprintf(&msg, "\nPosition: %.5f, %.5f", event.Latitude, event.Longitude)
+	fmt.Fprintf(&msg, "\nDetected: %s", event.DetectedAt.UTC().Format("2006-01-02 15:04:05 UTC"))
+	fmt.Fprintf(&msg, "\nViolation: #%d", event.ID)
+
+	return Alert{
+		Severity: event.Severity,
+		Subject:  fmt.Sprintf("[%s] %s: %s", strings.ToUpper(event.Severity), strings.ReplaceAll(event.Type, "_", " "), vessel),
+		Message:  msg.String(),
+		Time:     event.DetectedAt,
+
+		ViolationID: event.ID,
+	}
+}
+
+// operatorNoticeTypes are the violations the operator of a vessel is told
+// about; watchlist sightings and projected intrusions are for rangers only
+var operatorNoticeTypes = map[string]bool{
+	models.ViolationAnchoredOnPosidonia: true,
+	models.ViolationInBufferZone:        true,
+	models.ViolationInRestrictedArea:    true,
+	models.ViolationExcessiveSpeed:      true,
+	models.ViolationAnchorDragging:      true,
+}
+
+// recipientNotifier delivers alerts to recipients chosen per alert
+type recipientNotifier interface {
+	SendTo(ctx context.Context, recipients []string, alert Alert) error
+}
+
+// notifyOperator mails the notice of a violation to the contacts of the
+// operator of the vessel, when operators are notified and the vessel has an
+// operator with an email address. The attempt is recorded as a delivery on
+// ChannelOperator.
+func (s *NotificationService) notifyOperator(event ViolationEvent, alert Alert) {
+	if s.operatorMailer == nil || !operatorNoticeTypes[event.Type] || event.Vessel.UUID == "" {
+		return
+	}
+
+	contacts, err := s.operatorService.GetContactsForVessel(event.Vessel.UUID)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			s.logger.Warn("Failed to resolve operator contacts", "violation_id", event.ID, "error", err)
+		}
+		return
+	}
+	var recipients []string
+	for _, contact := range contacts {
+		if contact.Email != "" {
+			recipients = append(recipients, contact.Email)
+		}
+	}
+	if len(recipients) == 0 {
+		return
+	}
+
+	notice := alert
+	notice.Subject = fmt.Sprintf("Violation notice: %s", strings.ReplaceAll(event.Type, "_", " "))
+	if event.Vessel.Name != "" {
+		notice.Subject += ": " + event.Vessel.Name
+	}
+	notice.Message = "Park staff recorded the following infraction by a vessel you operate.\n\n" + alert.Message
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.Timeout)
+	defer cancel()
+	err = s.operatorMailer.SendTo(ctx, recipients, notice)
+
+	delivery := &models.NotificationDelivery{
+		ViolationID: &notice.ViolationID,
+		Channel:     ChannelOperator,
+		Severity:    notice.Severity,
+		Subject:     notice.Subject,
+		Status:      models.NotificationStatusSent,
+		AttemptedAt: time.Now(),
+	}
+	if err != nil {
+		s.logger.Warn("Failed to notify vessel operator", "violation_id", event.ID, "error", err)
+		delivery.Status = models.NotificationStatusFailed
+		delivery.Error = err.Error()
+	}
+	if dbErr := s.db.Create(delivery).Error; dbErr != nil {
+		s.logger.Warn("Failed to record notification delivery", "channel", ChannelOperator, "error", dbErr)
+	}
+}
+
+// Notify sends an alert on the channels routed for its severity, falling
+// back to the other configured channels, and returns the channel that
+// delivered it
+func (s *NotificationService) Notify(alert Alert) (string, error) {
+	if alert.Time.IsZero() {
+		alert.Time = time.Now()
+	}
+
+	channels := s.candidates(alert.Severity)
+	if len(channels) == 0 {
+		return "", ErrNoNotificationChannel
+	}
+
+	var failures []string
+	for i, channel := range channels {
+		err := s.send(channel, alert)
+		if err == nil {
+			if i > 0 {
+				s.logger.Warn("Alert sent on a fallback channel", "channel", channel, "failed", failures, "subject", alert.Subject)
+			}
+			return channel, nil
+		}
+		s.logger.Warn("Notification channel failed", "channel", channel, "subject", alert.Subject, "error", err)
+		failures = append(failures, channel)
+	}
+	return "", fmt.Errorf("every channel failed: %s", strings.Join(failures, ", "))
+}
+
+// SendTest sends an alert on one channel only, without fallback
+func (s *NotificationService) SendTest(channel string, alert Alert) error {
+	if _, ok := s.notifiers[channel]; !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownNotificationChannel, channel)
+	}
+	if alert.Time.IsZero() {
+		alert.Time = time.Now()
+	}
+	return s.send(channel, alert)
+}
+
+// candidates lists the routed channels of a severity, then the other
+// configured ones; nothing when the severity has no route
+func (s *NotificationService) candidates(severity string) []string {
+	s.mu.Lock()
+	routed := s.routes[severity]
+	s.mu.Unlock()
+	if len(routed) == 0 {
+		return nil
+	}
+
+	channels := append([]string{}, routed...)
+	for _, channel := range s.order {
+		if !containsString(routed, channel) {
+			channels = append(channels, channel)
+		}
+	}
+	return channels
+}
+
+func (s *NotificationService) send(channel string, alert Alert) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.Timeout)
+	defer cancel()
+	err := s.notifiers[channel].Send(ctx, alert)
+	now := time.Now()
+
+	delivery := &models.NotificationDelivery{
+		Channel:     channel,
+		Severity:    alert.Severity,
+		Subject:     alert.Subject,
+		Status:      models.NotificationStatusSent,
+		AttemptedAt: now,
+	}
+	if alert.ViolationID != 0 {
+		violationID := alert.ViolationID
+		delivery.ViolationID = &violationID
+	}
+	if err != nil {
+		delivery.Status = models.NotificationStatusFailed
+		delivery.Error = err.Error()
+	}
+	if dbErr := s.db.Create(delivery).Error; dbErr != nil {
+		s.logger.Warn("Failed to record notification delivery", "channel", channel, "error", dbErr)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := s.statuses[channel]
+	if err != nil {
+		status.Failed++
+		status.LastFailureAt = &now
+		status.LastError = err.Error()
+	} else {
+		status.Sent++
+		status.LastSentAt = &now
+	}
+	return err
+}
+
+// GetDeliveries returns the delivery attempts of the alerts about a
+// violation, oldest first
+func (s *NotificationService) GetDeliveries(violationID uint) ([]models.NotificationDelivery, error) {
+	var deliveries []models.NotificationDelivery
+	err := s.db.Where("violation_id = ?", violationID).Order("attempted_at ASC, id ASC").Find(&deliveries).Error
+	return deliveries, err
+}
+
+// Routes returns the channels of every severity, in order of preference
+func (s *NotificationService) Routes() map[string][]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	routes := make(map[string][]string, len(notificationSeverities))
+	for _, severity := range notificationSeverities {
+		routes[severity] = append([]string{}, s.routes[severity]...)
+	}
+	return routes
+}
+
+// SetRoutes replaces the channels of the severities given; severities left
+// out get no alerts. Every channel must be configured.
+func (s *NotificationService) SetRoutes(routes map[string][]string) error {
+	validated := make(map[string][]string, len(routes))
+	for severity, channels := range routes {
+		if !containsString(notificationSeverities, severity) {
+			return fmt.Errorf("unknown severity %q", severity)
+		}
+		seen := make(map[string]bool, len(channels))
+		for _, channel := range channels {
+			if _, ok := s.notifiers[channel]; !ok {
+				return fmt.Errorf("%s: %w: %s", severity, ErrUnknownNotificationChannel, channel)
+			}
+			if seen[channel] {
+				return fmt.Errorf("%s: channel %q is listed twice", severity, channel)
+			}
+			seen[channel] = true
+		}
+		validated[severity] = append([]string{}, channels...)
+	}
+
+	s.mu.Lock()
+	s.routes = validated
+	s.mu.Unlock()
+	return nil
+}
+
+// Channels returns the delivery record of every configured channel
+func (s *NotificationService) Channels() []NotificationChannelStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	channels := make([]NotificationChannelStatus, 0, len(s.order))
+	for _, name := range s.order {
+		channels = append(channels, *s.statuses[name])
+	}
+	return channels
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+	"vessel-tracker/database"
+	"vessel-tracker/models"
+)
+
+// sentMail is an alert mailed by recordingMailer
+type sentMail struct {
+	recipients []string
+	alert      Alert
+}
+
+// recordingMailer stands in for the SMTP server operator notices are mailed
+// through
+type recordingMailer struct {
+	sent []sentMail
+}
+
+func (m *recordingMailer) SendTo(ctx context.Context, recipients []string, alert Alert) error {
+	m.sent = append(m.sent, sentMail{recipients: recipients, alert: alert})
+	return nil
+}
+
+func TestViolationNoticeMailedToVesselOperator(t *testing.T) {
+	openTestDatabase(t, testDatabases(t)["sqlite"])
+	operators := NewOperatorService()
+
+	operator := models.Operator{Name: "Blue Charters"}
+	if err := operators.CreateOperator(&operator); err != nil {
+		t.Fatalf("CreateOperator: %v", err)
+	}
+	for _, contact := range []models.OperatorContact{
+		{OperatorID: operator.ID, Name: "Office", Email: "office@bluecharters.example"},
+		{OperatorID: operator.ID, Name: "Skipper", Phone: "+39 000 0000"},
+	} {
+		if err := operators.AddContact(&contact); err != nil {
+			t.Fatalf("AddContact: %v", err)
+		}
+	}
+	vessels := []models.VesselRecord{
+		{UUID: "charter", Name: "Charter", OperatorID: &operator.ID},
+		{UUID: "independent", Name: "Independent"},
+	}
+	if err := database.DB.Create(&vessels).Error; err != nil {
+		t.Fatalf("create vessels: %v", err)
+	}
+
+	s, err := NewNotificationService(NotificationConfig{Timeout: time.Second}, NewViolationService(nil), operators, nil)
+	if err != nil {
+		t.Fatalf("NewNotificationService: %v", err)
+	}
+	mailer := &recordingMailer{}
+	s.operatorMailer = mailer
+
+	notify := func(id uint, vesselUUID, violationType string) {
+		event := ViolationEvent{ID: id, Type: violationType, Severity: models.SeverityHigh, Vessel: VesselRef{UUID: vesselUUID, Name: vesselUUID}}
+		s.notifyOperator(event, Alert{Severity: event.Severity, Subject: "alert", Message: "Vessel: " + vesselUUID, ViolationID: id})
+	}
+	notify(1, "charter", models.ViolationExcessiveSpeed)
+	notify(2, "independent", models.ViolationExcessiveSpeed)
+	notify(3, "charter", models.ViolationWatchlistedVessel)
+	notify(4, "unknown", models.ViolationExcessiveSpeed)
+
+	if len(mailer.sent) != 1 {
+		t.Fatalf("mailed %d notices, want 1: %+v", len(mailer.sent), mailer.sent)
+	}
+	mail := mailer.sent[0]
+	if len(mail.recipients) != 1 || mail.recipients[0] != "office@bluecharters.example" {
+		t.Fatalf("notice mailed to %v, want the operator's email contact", mail.recipients)
+	}
+	if !strings.Contains(mail.alert.Subject, "excessive speed") || !strings.Contains(mail.alert.Message, "Vessel: charter") {
+		t.Fatalf("notice = %+v, want the excessive speed alert of charter", mail.alert)
+	}
+
+	var deliveries []models.NotificationDelivery
+	database.DB.Find(&deliveries)
+	if len(deliveries) != 1 || deliveries[0].Channel != ChannelOperator || deliveries[0].Status != models.NotificationStatusSent ||
+		deliveries[0].ViolationID == nil || *deliveries[0].ViolationID != 1 {
+		t.Fatalf("deliveries = %+v, want the notice of violation 1 sent on %s", deliveries, ChannelOperator)
+	}
+}
+
+func TestLoadNotificationConfigOperatorsNeedSMTP(t *testing.T) {
+	t.Setenv("NOTIFY_OPERATORS", "true")
+	if _, err := LoadNotificationConfig(); err == nil {
+		t.Fatal("NOTIFY_OPERATORS without NOTIFY_SMTP_HOST succeeded")
+	}
+
+	t.Setenv("NOTIFY_SMTP_HOST", "mail.example")
+	t.Setenv("NOTIFY_SMTP_FROM", "alerts@park.example")
+	t.Setenv("NOTIFY_SMTP_TO", "rangers@park.example")
+	config, err := LoadNotificationConfig()
+	if err != nil {
+		t.Fatalf("LoadNotificationConfig: %v", err)
+	}
+	if !config.NotifyOperators {
+		t.Fatal("NOTIFY_OPERATORS=true left operators unnotified")
+	}
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"vessel-tracker/models"
+)
+
+// defaultMaxSuppression bounds how long the alerts about a vessel may be
+// silenced at once
+const defaultMaxSuppression = 7 * 24 * time.Hour
+
+// ErrInvalidSuppression is returned for a suppression window that is empty,
+// already over or longer than NOTIFY_SUPPRESSION_MAX
+var ErrInvalidSuppression = errors.New("invalid suppression window")
+
+// loadSuppressionConfig reads NOTIFY_SUPPRESSION_MAX
+func loadSuppressionConfig(config *NotificationConfig) error {
+	if value := os.Getenv("NOTIFY_SUPPRESSION_MAX"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid NOTIFY_SUPPRESSION_MAX %q: must be a positive duration", value)
+		}
+		config.MaxSuppression = d
+	}
+	return nil
+}
+
+// Suppress silences the alerts about a vessel from startsAt until endsAt. A
+// zero startsAt starts the window now. Violations are still recorded; only
+// their alerts are withheld.
+func (s *NotificationService) Suppress(vesselUUID, reason, actor string, startsAt, endsAt time.Time) (*models.NotificationSuppression, error) {
+	now := time.Now()
+	if startsAt.IsZero() {
+		startsAt = now
+	}
+	if strings.TrimSpace(vesselUUID) == "" || strings.TrimSpace(reason) == "" {
+		return nil, fmt.Errorf("%w: a vessel and a reason are required", ErrInvalidSuppression)
+	}
+	if !startsAt.Before(endsAt) || !endsAt.After(now) {
+		return nil, fmt.Errorf("%w: ends_at must be in the future and after starts_at", ErrInvalidSuppression)
+	}
+	if endsAt.Sub(startsAt) > s.config.MaxSuppression {
+		return nil, fmt.Errorf("%w: longer than %s", ErrInvalidSuppression, s.config.MaxSuppression)
+	}
+
+	suppression := &models.NotificationSuppression{
+		VesselUUID: vesselUUID,
+		Reason:     reason,
+		StartsAt:   startsAt,
+		EndsAt:     endsAt,
+		CreatedBy:  actor,
+	}
+	if err := s.db.Create(suppression).Error; err != nil {
+		return nil, fmt.Errorf("failed to save suppression: %w", err)
+	}
+
+	s.logger.Info("Alerts suppressed", "vessel_uuid", vesselUUID, "starts_at", startsAt, "ends_at", endsAt, "by", actor, "reason", reason)
+	return suppression, nil
+}
+
+// CancelSuppression ends a suppression before it expires
+func (s *NotificationService) CancelSuppression(id uint, actor string) (*models.NotificationSuppression, error) {
+	var suppression models.NotificationSuppression
+	if err := s.db.First(&suppression, id).Error; err != nil {
+		return nil, err
+	}
+	if suppression.CancelledAt != nil {
+		return &suppression, nil
+	}
+
+	now := time.Now()
+	suppression.CancelledAt = &now
+	suppression.CancelledBy = actor
+	if err := s.db.Save(&suppression).Error; err != nil {
+		return nil, fmt.Errorf("failed to cancel suppression: %w", err)
+	}
+
+	s.logger.Info("Alert suppression cancelled", "id", id, "vessel_uuid", suppression.VesselUUID, "by", actor)
+	return &suppression, nil
+}
+
+// GetSuppressions lists the suppressions not yet over, or every suppression
+// with includeExpired, latest ending first
+func (s *NotificationService) GetSuppressions(includeExpired bool) ([]models.NotificationSuppression, error) {
+	var suppressions []models.NotificationSuppression
+	query := s.db.Order("ends_at DESC, id DESC")
+	if !includeExpired {
+		query = query.Where("cancelled_at IS NULL AND ends_at > ?", time.Now())
+	}
+	err := query.Find(&suppressions).Error
+	return suppressions, err
+}
+
+// activeSuppression returns the suppression silencing the alerts about a
+// vessel at t, nil when there is none
+func (s *NotificationService) activeSuppression(vesselUUID string, t time.Time) (*models.NotificationSuppression, error) {
+	if vesselUUID == "" {
+		return nil, nil
+	}
+
+	var suppressions []models.NotificationSuppression
+	err := s.db.Where("vessel_uuid = ? AND cancelled_at IS NULL AND starts_at <= ? AND ends_at > ?", vesselUUID, t, t).
+		Order("ends_at DESC").
+		Limit(1).
+		Find(&suppressions).Error
+	if err != nil || len(suppressions) == 0 {
+		return nil, err
+	}
+	return &suppressions[0], nil
+}
+
+// recordSuppressed records an alert withheld by a suppression, so the
+// violation timeline shows why no alert went out
+func (s *NotificationService) recordSuppressed(alert Alert, suppression *models.NotificationSuppression) {
+	delivery := &models.NotificationDelivery{
+		Severity:      alert.Severity,
+		Subject:       alert.Subject,
+		Status:        models.NotificationStatusSuppressed,
+		SuppressionID: &suppression.ID,
+		AttemptedAt:   time.Now(),
+	}
+	if alert.ViolationID != 0 {
+		violationID := alert.ViolationID
+		delivery.ViolationID = &violationID
+	}
+	if err := s.db.Create(delivery).Error; err != nil {
+		s.logger.Warn("Failed to record suppressed alert", "suppression_id", suppression.ID, "error", err)
+	}
+}
@@ -0,0 +1,214 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Notification channel names
+const (
+	ChannelSMTP     = "smtp"
+	ChannelSlack    = "slack"
+	ChannelTelegram = "telegram"
+
+	// ChannelOperator records the notices mailed to the operator of a
+	// vessel; it is not routed like the channels of park staff
+	ChannelOperator = "operator"
+)
+
+// Alert is a message for park staff, sent on the channels routed for its
+// severity
+type Alert struct {
+	Severity    string    `json:"severity"`
+	Subject     string    `json:"subject"`
+	Message     string    `json:"message"`
+	Time        time.Time `json:"time"`
+	ViolationID uint      `json:"violation_id,omitempty"` // the violation alerted, 0 for other alerts
+}
+
+// Notifier delivers alerts on one channel
+type Notifier interface {
+	Name() string
+	Send(ctx context.Context, alert Alert) error
+}
+
+// SMTPConfig holds the mail server alerts are sent through
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string `secret:"true"`
+	From     string
+	To       []string
+}
+
+// SMTPNotifier mails alerts, upgrading the connection with STARTTLS when the
+// server offers it
+type SMTPNotifier struct {
+	config SMTPConfig
+}
+
+func NewSMTPNotifier(config SMTPConfig) *SMTPNotifier {
+	return &SMTPNotifier{config: config}
+}
+
+func (n *SMTPNotifier) Name() string {
+	return ChannelSMTP
+}
+
+func (n *SMTPNotifier) Send(ctx context.Context, alert Alert) error {
+	return n.SendTo(ctx, n.config.To, alert)
+}
+
+// SendTo mails an alert to the given recipients instead of the configured ones
+func (n *SMTPNotifier) SendTo(ctx context.Context, recipients []string, alert Alert) error {
+	addr := net.JoinHostPort(n.config.Host, fmt.Sprint(n.config.Port))
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, n.config.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to greet %s: %w", addr, err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: n.config.Host}); err != nil {
+			return fmt.Errorf("failed to start TLS: %w", err)
+		}
+	}
+	if n.config.Username != "" {
+		auth := smtp.PlainAuth("", n.config.Username, n.config.Password, n.config.Host)
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("failed to authenticate: %w", err)
+		}
+	}
+
+	if err := client.Mail(n.config.From); err != nil {
+		return err
+	}
+	for _, to := range recipients {
+		if err := client.Rcpt(to); err != nil {
+			return fmt.Errorf("recipient %s refused: %w", to, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", n.config.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(recipients, ", "))
+	// The subject comes from vessel names and other feed data; line breaks
+	// in it would start new headers, and non-ASCII text must be encoded
+	subject := strings.NewReplacer("\r", " ", "\n", " ").Replace(alert.Subject)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", alert.Time.Format(time.RFC1123Z))
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(alert.Message, "\n", "\r\n"))
+	msg.WriteString("\r\n")
+	if _, err := w.Write(msg.Bytes()); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// SlackNotifier posts alerts to a Slack incoming webhook
+type SlackNotifier struct {
+	webhookURL string
+	client     *http.Client
+}
+
+func NewSlackNotifier(webhookURL string) *SlackNotifier {
+	return &SlackNotifier{webhookURL: webhookURL, client: &http.Client{}}
+}
+
+func (n *SlackNotifier) Name() string {
+	return ChannelSlack
+}
+
+func (n *SlackNotifier) Send(ctx context.Context, alert Alert) error {
+	body, err := json.Marshal(map[string]string{
+		"text": fmt.Sprintf("*%s*\n%s", alert.Subject, alert.Message),
+	})
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, n.client, n.webhookURL, body)
+}
+
+// telegramAPIURL is the Bot API endpoint, the bot token is appended
+const telegramAPIURL = "https://api.telegram.org/bot"
+
+// TelegramNotifier sends alerts to a chat through a Telegram bot
+type TelegramNotifier struct {
+	token  string
+	chatID string
+	client *http.Client
+}
+
+func NewTelegramNotifier(token, chatID string) *TelegramNotifier {
+	return &TelegramNotifier{token: token, chatID: chatID, client: &http.Client{}}
+}
+
+func (n *TelegramNotifier) Name() string {
+	return ChannelTelegram
+}
+
+func (n *TelegramNotifier) Send(ctx context.Context, alert Alert) error {
+	body, err := json.Marshal(map[string]string{
+		"chat_id": n.chatID,
+		"text":    alert.Subject + "\n\n" + alert.Message,
+	})
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, n.client, telegramAPIURL+n.token+"/sendMessage", body)
+}
+
+// postJSON posts body to url and fails on any status but 2xx. The URL is
+// left out of errors, it holds the webhook secret or bot token.
+func postJSON(ctx context.Context, client *http.Client, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		if urlErr, ok := err.(interface{ Unwrap() error }); ok {
+			err = urlErr.Unwrap()
+		}
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
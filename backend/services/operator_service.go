@@ -0,0 +1,165 @@
+package services
+
+import (
+	"fmt"
+	"time"
+	"vessel-tracker/database"
+	"vessel-tracker/models"
+
+	"gorm.io/gorm"
+)
+
+type OperatorService struct {
+	db *gorm.DB
+}
+
+func NewOperatorService() *OperatorService {
+	return &OperatorService{
+		db: database.GetDB(),
+	}
+}
+
+// CreateOperator registers a new owner/operator
+func (s *OperatorService) CreateOperator(operator *models.Operator) error {
+	if err := s.db.Create(operator).Error; err != nil {
+		return fmt.Errorf("failed to create operator: %w", err)
+	}
+	return nil
+}
+
+// GetOperator returns an operator with its linked vessels
+func (s *OperatorService) GetOperator(id uint) (*models.Operator, error) {
+	var operator models.Operator
+	err := s.db.Preload("Vessels").First(&operator, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &operator, nil
+}
+
+// GetAllOperators returns every registered operator with its linked vessels
+func (s *OperatorService) GetAllOperators() ([]models.Operator, error) {
+	var operators []models.Operator
+	err := s.db.Preload("Vessels").Order("name").Find(&operators).Error
+	return operators, err
+}
+
+// AssignVessel links a known vessel to an operator
+func (s *OperatorService) AssignVessel(operatorID uint, vesselUUID string) error {
+	var operator models.Operator
+	if err := s.db.First(&operator, operatorID).Error; err != nil {
+		return err
+	}
+
+	result := s.db.Model(&models.VesselRecord{}).
+		Where("uuid = ?", vesselUUID).
+		Update("operator_id", operatorID)
+	if result.Error != nil {
+		return fmt.Errorf("failed to assign vessel: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// UnassignVessel removes the operator link from a vessel. It returns
+// gorm.ErrRecordNotFound when the vessel is not linked to the operator.
+func (s *OperatorService) UnassignVessel(operatorID uint, vesselUUID string) error {
+	result := s.db.Model(&models.VesselRecord{}).
+		Where("uuid = ? AND operator_id = ?", vesselUUID, operatorID).
+		Update("operator_id", nil)
+	if result.Error != nil {
+		return fmt.Errorf("failed to unassign vessel: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// GetOperatorStats aggregates position activity per operator instead of per hull
+func (s *OperatorService) GetOperatorStats(startTime, endTime time.Time) ([]models.OperatorStats, error) {
+	var stats []models.OperatorStats
+
+	err := s.db.Table("operators").
+		Select(`operators.id AS operator_id,
+			operators.name AS operator_name,
+			COUNT(DISTINCT vessel_records.uuid) AS vessel_count,
+			COUNT(vessel_position_records.id) AS positions_total,
+			COUNT(CASE WHEN vessel_position_records.is_in_park THEN 1 END) AS positions_in_park,
+			COUNT(DISTINCT DATE(vessel_position_records.recorded_at)) AS days_seen,
+			(SELECT COUNT(*) FROM violations WHERE violations.operator_id = operators.id AND violations.detected_at BETWEEN ? AND ?) AS violations`, startTime, endTime).
+		Joins("LEFT JOIN vessel_records ON vessel_records.operator_id = operators.id").
+		Joins("LEFT JOIN vessel_position_records ON vessel_position_records.vessel_uuid = vessel_records.uuid AND vessel_position_records.recorded_at BETWEEN ? AND ?", startTime, endTime).
+		Group("operators.id, operators.name").
+		Order("positions_in_park DESC").
+		Scan(&stats).Error
+
+	return stats, err
+}
+
+// AddContact stores personal contact details for an operator
+func (s *OperatorService) AddContact(contact *models.OperatorContact) error {
+	var operator models.Operator
+	if err := s.db.First(&operator, contact.OperatorID).Error; err != nil {
+		return err
+	}
+
+	if err := s.db.Create(contact).Error; err != nil {
+		return fmt.Errorf("failed to create operator contact: %w", err)
+	}
+	return nil
+}
+
+// GetContacts returns the contact details for an operator
+func (s *OperatorService) GetContacts(operatorID uint) ([]models.OperatorContact, error) {
+	var contacts []models.OperatorContact
+	err := s.db.Where("operator_id = ?", operatorID).Find(&contacts).Error
+	return contacts, err
+}
+
+// DeleteContact removes a single contact from an operator
+func (s *OperatorService) DeleteContact(operatorID, contactID uint) error {
+	return s.db.Where("id = ? AND operator_id = ?", contactID, operatorID).
+		Delete(&models.OperatorContact{}).Error
+}
+
+// GetContactsForVessel resolves the operator contacts to notify about a vessel
+func (s *OperatorService) GetContactsForVessel(vesselUUID string) ([]models.OperatorContact, error) {
+	var vessel models.VesselRecord
+	if err := s.db.Where("uuid = ?", vesselUUID).First(&vessel).Error; err != nil {
+		return nil, err
+	}
+
+	if vessel.OperatorID == nil {
+		return nil, nil
+	}
+
+	return s.GetContacts(*vessel.OperatorID)
+}
+
+// GetOperatorViolations returns violations attributed to an operator, most recent first
+func (s *OperatorService) GetOperatorViolations(operatorID uint, limit int) ([]models.Violation, error) {
+	var violations []models.Violation
+
+	query := s.db.Where("operator_id = ?", operatorID).Order("detected_at DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	err := query.Find(&violations).Error
+	return violations, err
+}
+
+// CountOperatorViolations returns the number of violations attributed to an
+// operator since the given time, used for repeat-offense tracking
+func (s *OperatorService) CountOperatorViolations(operatorID uint, since time.Time) (int64, error) {
+	var count int64
+	err := s.db.Model(&models.Violation{}).
+		Where("operator_id = ? AND detected_at >= ?", operatorID, since).
+		Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,228 @@
+package services
+
+import (
+	"fmt"
+	"time"
+	"vessel-tracker/database"
+	"vessel-tracker/models"
+
+	"gorm.io/gorm"
+)
+
+// staleFetchIntervals is how many fetch intervals may pass without a
+// successful fetch before the scheduler is reported unhealthy
+const staleFetchIntervals = 3
+
+// CacheStatus reports what an in-memory cache holds
+type CacheStatus struct {
+	Name      string     `json:"name"`
+	Loaded    bool       `json:"loaded"`
+	Entries   int        `json:"entries"`
+	Version   string     `json:"version,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at"`
+}
+
+// SchedulerHealth is the scheduler status with a verdict for a dashboard
+type SchedulerHealth struct {
+	SchedulerStatus
+	Source  string `json:"source"`
+	Healthy bool   `json:"healthy"`
+	Issue   string `json:"issue,omitempty"`
+}
+
+// DatabaseStatus reports the size and connection pool of the database.
+// WaitCount and WaitDurationMs grow when requests queue for a connection
+// because MaxOpenConnections is reached.
+type DatabaseStatus struct {
+	Driver             string `json:"driver"`
+	SizeBytes          int64  `json:"size_bytes"`
+	MaxOpenConnections int    `json:"max_open_connections"` // 0 when unlimited
+	OpenConnections    int    `json:"open_connections"`
+	InUse              int    `json:"in_use"`
+	Idle               int    `json:"idle"`
+	WaitCount          int64  `json:"wait_count"`
+	WaitDurationMs     int64  `json:"wait_duration_ms"`
+	MaxIdleClosed      int64  `json:"max_idle_closed"`
+	MaxLifetimeClosed  int64  `json:"max_lifetime_closed"`
+}
+
+// AlertSummary counts the alert deliveries of the current UTC day
+type AlertSummary struct {
+	Since    time.Time                   `json:"since"`
+	Sent     int64                       `json:"sent"`
+	Failed   int64                       `json:"failed"`
+	Channels []NotificationChannelStatus `json:"channels"`
+}
+
+// ParkBoundaryStatus reports the boundary layers loaded for a park
+type ParkBoundaryStatus struct {
+	Park    string        `json:"park"`
+	Healthy bool          `json:"healthy"`
+	Layers  []LayerStatus `json:"layers"`
+}
+
+// AdminOverview is the state of the whole system for an operations
+// dashboard
+type AdminOverview struct {
+	GeneratedAt     time.Time            `json:"generated_at"`
+	Scheduler       SchedulerHealth      `json:"scheduler"`
+	Provider        string               `json:"provider"`
+	Quota           *QuotaStatus         `json:"quota"` // nil unless fetching from Datalastic
+	Database        DatabaseStatus       `json:"database"`
+	OpenViolations  map[string]int64     `json:"open_violations"` // by severity
+	AlertsToday     AlertSummary         `json:"alerts_today"`
+	Caches          []CacheStatus        `json:"caches"`
+	Boundaries      []ParkBoundaryStatus `json:"boundaries"`
+	IndexAdvice     IndexAdvisorReport   `json:"index_advice"`
+	MaintenanceMode bool                 `json:"maintenance_mode"`
+}
+
+// OverviewService gathers the status of the scheduler, provider, database,
+// violations, notifications and caches, and the latest index advice, in one
+// report
+type OverviewService struct {
+	db               *gorm.DB
+	scheduler        *SchedulerService
+	vesselService    *VesselService
+	violationService *ViolationService
+	notifications    *NotificationService
+	whitelistService *WhitelistService
+	posidonia        *PosidoniaLayer
+	posidoniaTiles   *PosidoniaTiles
+	parks            *ParkRegistry
+	maintenance      *MaintenanceService
+	indexAdvisor     *IndexAdvisor
+}
+
+func NewOverviewService(scheduler *SchedulerService, vesselService *VesselService, violationService *ViolationService, notifications *NotificationService, whitelistService *WhitelistService, posidonia *PosidoniaLayer, posidoniaTiles *PosidoniaTiles, parks *ParkRegistry, maintenance *MaintenanceService, indexAdvisor *IndexAdvisor) *OverviewService {
+	return &OverviewService{
+		db:               database.GetDB(),
+		scheduler:        scheduler,
+		vesselService:    vesselService,
+		violationService: violationService,
+		notifications:    notifications,
+		whitelistService: whitelistService,
+		posidonia:        posidonia,
+		posidoniaTiles:   posidoniaTiles,
+		parks:            parks,
+		maintenance:      maintenance,
+		indexAdvisor:     indexAdvisor,
+	}
+}
+
+// GetOverview reports the current state of the system
+func (s *OverviewService) GetOverview() (*AdminOverview, error) {
+	now := time.Now()
+	overview := &AdminOverview{
+		GeneratedAt:     now,
+		Scheduler:       s.schedulerHealth(now),
+		Provider:        s.vesselService.ProviderName(),
+		IndexAdvice:     s.indexAdvisor.Report(),
+		MaintenanceMode: s.maintenance.Enabled(),
+	}
+
+	if quota := s.vesselService.Quota(); quota != nil {
+		status := quota.Status()
+		overview.Quota = &status
+	}
+
+	var err error
+	if overview.Database, err = s.databaseStatus(); err != nil {
+		return nil, err
+	}
+	if overview.OpenViolations, err = s.violationService.CountOpenBySeverity(); err != nil {
+		return nil, err
+	}
+	if overview.AlertsToday, err = s.alertsToday(now); err != nil {
+		return nil, err
+	}
+
+	overview.Caches = []CacheStatus{s.whitelistService.CacheStatus(), s.posidonia.CacheStatus(), s.posidoniaTiles.CacheStatus()}
+	for _, park := range s.parks.All() {
+		overview.Boundaries = append(overview.Boundaries, ParkBoundaryStatus{
+			Park:    park.Record.Slug,
+			Healthy: park.Geo.BoundariesHealthy(),
+			Layers:  park.Geo.LayerStatuses(),
+		})
+	}
+
+	return overview, nil
+}
+
+// schedulerHealth judges the scheduler unhealthy while paused, after a failed
+// fetch, or when polling has not succeeded for several intervals
+func (s *OverviewService) schedulerHealth(now time.Time) SchedulerHealth {
+	config := s.scheduler.Config()
+	health := SchedulerHealth{
+		SchedulerStatus: s.scheduler.Status(),
+		Source:          config.Source,
+		Healthy:         true,
+	}
+	status := health.SchedulerStatus
+
+	switch {
+	case status.Paused:
+		health.Issue = "fetching is paused"
+	case status.LastFailureAt != nil && (status.LastSuccessAt == nil || status.LastFailureAt.After(*status.LastSuccessAt)):
+		health.Issue = "the last fetch failed: " + status.LastError
+	case config.Source != DataSourceAIS && status.LastSuccessAt != nil && now.Sub(*status.LastSuccessAt) > staleFetchIntervals*config.FetchInterval:
+		health.Issue = fmt.Sprintf("no successful fetch since %s", status.LastSuccessAt.UTC().Format(time.RFC3339))
+	}
+	health.Healthy = health.Issue == ""
+	return health
+}
+
+func (s *OverviewService) databaseStatus() (DatabaseStatus, error) {
+	status := DatabaseStatus{Driver: s.db.Dialector.Name()}
+
+	size, err := database.Size()
+	if err != nil {
+		return status, err
+	}
+	status.SizeBytes = size
+
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return status, fmt.Errorf("failed to read the connection pool: %w", err)
+	}
+	stats := sqlDB.Stats()
+	status.MaxOpenConnections = stats.MaxOpenConnections
+	status.OpenConnections = stats.OpenConnections
+	status.InUse = stats.InUse
+	status.Idle = stats.Idle
+	status.WaitCount = stats.WaitCount
+	status.WaitDurationMs = stats.WaitDuration.Milliseconds()
+	status.MaxIdleClosed = stats.MaxIdleClosed
+	status.MaxLifetimeClosed = stats.MaxLifetimeClosed
+	return status, nil
+}
+
+func (s *OverviewService) alertsToday(now time.Time) (AlertSummary, error) {
+	summary := AlertSummary{
+		Since:    now.UTC().Truncate(24 * time.Hour),
+		Channels: s.notifications.Channels(),
+	}
+
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	err := s.db.Model(&models.NotificationDelivery{}).
+		Select("status, COUNT(*) as count").
+		Where("attempted_at >= ?", summary.Since).
+		Group("status").
+		Scan(&rows).Error
+	if err != nil {
+		return summary, fmt.Errorf("failed to count alerts: %w", err)
+	}
+
+	for _, row := range rows {
+		switch row.Status {
+		case models.NotificationStatusSent:
+			summary.Sent += row.Count
+		case models.NotificationStatusFailed:
+			summary.Failed += row.Count
+		}
+	}
+	return summary, nil
+}
@@ -0,0 +1,338 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"vessel-tracker/database"
+	"vessel-tracker/logging"
+	"vessel-tracker/models"
+
+	geojson "github.com/paulmach/go.geojson"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultParkSlug identifies the park monitored when no PARKS_FILE is set
+const DefaultParkSlug = "la-maddalena"
+
+var parkSlugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// ParkConfig describes one monitored park. Boundaries are read from a GeoJSON
+// file or given inline as a FeatureCollection; the same goes for the buffered
+// boundaries. A park with a data region is only monitored by a deployment in
+// that region, archives its rows there, and with restrict_exports its data is
+// not handed out as files (report downloads, notices, raw provider payloads).
+type ParkConfig struct {
+	Slug              string          `json:"slug"`
+	Name              string          `json:"name"`
+	Boundaries        string          `json:"boundaries,omitempty"`
+	BoundariesGeoJSON json.RawMessage `json:"boundaries_geojson,omitempty"`
+	Buffered          string          `json:"buffered,omitempty"`
+	BufferedGeoJSON   json.RawMessage `json:"buffered_geojson,omitempty"`
+	BufferMeters      *float64        `json:"buffer_meters,omitempty"`
+	CenterLat         *float64        `json:"center_lat,omitempty"`
+	CenterLon         *float64        `json:"center_lon,omitempty"`
+	RadiusNM          int             `json:"radius_nm,omitempty"`       // 0 uses SCHEDULER_RADIUS_NM
+	ExpectedRegion    string          `json:"expected_region,omitempty"` // "minLon,minLat,maxLon,maxLat", defaults to EXPECTED_REGION_BBOX
+	DataRegion        string          `json:"data_region,omitempty"`     // region the park's data must stay in, must match DEPLOYMENT_REGION
+	Archive           *ArchiveTarget  `json:"archive,omitempty"`         // storage for the park's retention archives, defaults to the deployment's
+	RestrictExports   bool            `json:"restrict_exports,omitempty"`
+	Site              SiteConfig      `json:"site"` // how the web app presents the park
+}
+
+// DefaultParkConfigs monitors the single park shipped in ./data
+func DefaultParkConfigs() []ParkConfig {
+	return []ParkConfig{{
+		Slug:       DefaultParkSlug,
+		Name:       "La Maddalena Archipelago National Park",
+		Boundaries: "./data/national-park.geojson",
+		Buffered:   "./data/buffered.geojson",
+	}}
+}
+
+// LoadParkConfigs reads the parks to monitor from the JSON array in
+// PARKS_FILE. The first park is the default for requests that name none.
+func LoadParkConfigs() ([]ParkConfig, error) {
+	path := os.Getenv("PARKS_FILE")
+	if path == "" {
+		return DefaultParkConfigs(), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PARKS_FILE %q: %w", path, err)
+	}
+
+	var configs []ParkConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("invalid PARKS_FILE %q: %w", path, err)
+	}
+	if len(configs) == 0 {
+		return nil, fmt.Errorf("invalid PARKS_FILE %q: no parks configured", path)
+	}
+
+	seen := make(map[string]bool, len(configs))
+	for i := range configs {
+		config := &configs[i]
+		if !parkSlugPattern.MatchString(config.Slug) {
+			return nil, fmt.Errorf("invalid PARKS_FILE %q: park %d slug %q must be lowercase letters, digits and dashes", path, i+1, config.Slug)
+		}
+		if seen[config.Slug] {
+			return nil, fmt.Errorf("invalid PARKS_FILE %q: duplicate park slug %q", path, config.Slug)
+		}
+		seen[config.Slug] = true
+
+		if config.Name == "" {
+			config.Name = config.Slug
+		}
+		if config.Boundaries == "" && len(config.BoundariesGeoJSON) == 0 {
+			return nil, fmt.Errorf("invalid PARKS_FILE %q: park %q has no boundaries", path, config.Slug)
+		}
+		if (config.CenterLat == nil) != (config.CenterLon == nil) {
+			return nil, fmt.Errorf("invalid PARKS_FILE %q: park %q needs both center_lat and center_lon", path, config.Slug)
+		}
+		if config.RadiusNM < 0 || config.RadiusNM > maxRadiusNM {
+			return nil, fmt.Errorf("invalid PARKS_FILE %q: park %q radius must be between 1 and %d NM", path, config.Slug, maxRadiusNM)
+		}
+		if err := config.Site.validate(); err != nil {
+			return nil, fmt.Errorf("invalid PARKS_FILE %q: park %q %w", path, config.Slug, err)
+		}
+		if config.Archive != nil && config.Archive.S3Bucket != "" && config.DataRegion != "" && config.Archive.S3Region != config.DataRegion {
+			return nil, fmt.Errorf("invalid PARKS_FILE %q: park %q archive bucket is in %q, outside its data region %q", path, config.Slug, config.Archive.S3Region, config.DataRegion)
+		}
+	}
+
+	return configs, nil
+}
+
+// geoConfig turns the park configuration into the boundaries GeoService
+// loads, with the deployment-wide defaults
+func (c ParkConfig) geoConfig(defaults BoundaryConfig) (GeoConfig, error) {
+	geo := GeoConfig{
+		Name:         c.Slug,
+		ParkPath:     c.Boundaries,
+		BufferedPath: c.Buffered,
+		Defaults:     defaults,
+		BufferMeters: c.BufferMeters,
+		CenterLat:    c.CenterLat,
+		CenterLon:    c.CenterLon,
+	}
+
+	var err error
+	if c.ExpectedRegion != "" {
+		region, err := ParseRegion(c.ExpectedRegion)
+		if err != nil {
+			return geo, fmt.Errorf("invalid expected_region %q: %w", c.ExpectedRegion, err)
+		}
+		geo.ExpectedRegion = &region
+	}
+	if len(c.BoundariesGeoJSON) > 0 {
+		if geo.Park, err = geojson.UnmarshalFeatureCollection(c.BoundariesGeoJSON); err != nil {
+			return geo, fmt.Errorf("failed to parse boundaries_geojson: %w", err)
+		}
+	}
+	if len(c.BufferedGeoJSON) > 0 {
+		if geo.Buffered, err = geojson.UnmarshalFeatureCollection(c.BufferedGeoJSON); err != nil {
+			return geo, fmt.Errorf("failed to parse buffered_geojson: %w", err)
+		}
+	}
+
+	return geo, nil
+}
+
+// Park is a monitored park with its loaded boundaries
+type Park struct {
+	Record   models.Park
+	Geo      *GeoService
+	Archiver Archiver // nil to archive with the deployment's archiver
+	Site     SiteConfig
+	Rules    []ZoneRule // evaluated against every position, see SetZoneRules
+
+	WhitelistExceptions []WhitelistException // violations reported for whitelisted vessels, see SetZoneRules
+}
+
+// ParkRegistry holds every monitored park
+type ParkRegistry struct {
+	parks  []*Park
+	bySlug map[string]*Park
+}
+
+// NewParkRegistry loads the boundaries of each configured park and stores the
+// parks, keyed by slug, so they keep their IDs across restarts. Records stored
+// before parks existed are assigned to the default park. Parks with their own
+// S3 archive target upload with the credentials. A park whose data must stay
+// in a region is only monitored by a deployment in that region.
+func NewParkRegistry(configs []ParkConfig, boundaries BoundaryConfig, credentials S3Credentials, deploymentRegion string) (*ParkRegistry, error) {
+	if len(configs) == 0 {
+		return nil, fmt.Errorf("no parks configured")
+	}
+
+	db := database.GetDB()
+	registry := &ParkRegistry{bySlug: make(map[string]*Park, len(configs))}
+
+	for _, config := range configs {
+		if config.DataRegion != "" && config.DataRegion != deploymentRegion {
+			return nil, fmt.Errorf("park %q data must stay in %q but DEPLOYMENT_REGION is %q", config.Slug, config.DataRegion, deploymentRegion)
+		}
+		geoConfig, err := config.geoConfig(boundaries)
+		if err != nil {
+			return nil, fmt.Errorf("park %q: %w", config.Slug, err)
+		}
+		geo, err := NewGeoServiceFromConfig(geoConfig)
+		if err != nil {
+			return nil, fmt.Errorf("park %q: %w", config.Slug, err)
+		}
+
+		record := models.Park{
+			Slug:            config.Slug,
+			Name:            config.Name,
+			BoundariesPath:  config.Boundaries,
+			BufferedPath:    config.Buffered,
+			BufferMeters:    config.BufferMeters,
+			CenterLat:       config.CenterLat,
+			CenterLon:       config.CenterLon,
+			RadiusNM:        config.RadiusNM,
+			DataRegion:      config.DataRegion,
+			RestrictExports: config.RestrictExports,
+		}
+		err = db.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "slug"}},
+			DoUpdates: clause.AssignmentColumns([]string{"name", "boundaries_path", "buffered_path", "buffer_meters", "center_lat", "center_lon", "radius_nm", "data_region", "restrict_exports", "updated_at"}),
+		}).Create(&record).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to store park %q: %w", config.Slug, err)
+		}
+		// The upsert does not report the ID of an existing row on every driver
+		if err := db.Where("slug = ?", config.Slug).First(&record).Error; err != nil {
+			return nil, fmt.Errorf("failed to load park %q: %w", config.Slug, err)
+		}
+
+		park := &Park{Record: record, Geo: geo, Site: config.Site}
+		if config.Archive != nil {
+			if park.Archiver, err = config.Archive.archiver(credentials, deploymentRegion); err != nil {
+				return nil, fmt.Errorf("park %q: %w", config.Slug, err)
+			}
+		}
+		registry.parks = append(registry.parks, park)
+		registry.bySlug[record.Slug] = park
+	}
+
+	if err := assignUnparkedRecords(db, registry.Default().Record.ID); err != nil {
+		return nil, err
+	}
+
+	return registry, nil
+}
+
+// assignUnparkedRecords tags positions, violations and anchoring events
+// stored before parks existed with the default park
+func assignUnparkedRecords(db *gorm.DB, parkID uint) error {
+	for _, table := range []string{"vessel_position_records", "violations", "anchoring_events"} {
+		result := db.Table(table).Where("park_id = 0").Update("park_id", parkID)
+		if result.Error != nil {
+			return fmt.Errorf("failed to assign %s to the default park: %w", table, result.Error)
+		}
+		if result.RowsAffected > 0 {
+			logging.Component("parks").Info("Assigned existing records to the default park", "table", table, "count", result.RowsAffected, "park_id", parkID)
+		}
+	}
+	return nil
+}
+
+// Default returns the first configured park, used when a request names none
+func (r *ParkRegistry) Default() *Park {
+	return r.parks[0]
+}
+
+// Get returns the park with the given slug
+func (r *ParkRegistry) Get(slug string) (*Park, bool) {
+	park, ok := r.bySlug[slug]
+	return park, ok
+}
+
+// GetByID returns the park with the given database ID
+func (r *ParkRegistry) GetByID(id uint) (*Park, bool) {
+	for _, park := range r.parks {
+		if park.Record.ID == id {
+			return park, true
+		}
+	}
+	return nil, false
+}
+
+// ForProviderEndpoint returns the park whose center an in-radius provider
+// request, given as its recorded path and query, was made around
+func (r *ParkRegistry) ForProviderEndpoint(endpoint string) (*Park, bool) {
+	parsed, err := url.Parse(endpoint)
+	if err != nil || !strings.HasSuffix(parsed.Path, "/vessel_inradius") {
+		return nil, false
+	}
+
+	query := parsed.Query()
+	for _, park := range r.parks {
+		lat, lon := park.Geo.GetParkCenter()
+		if query.Get("lat") == fmt.Sprintf("%.6f", lat) && query.Get("lon") == fmt.Sprintf("%.6f", lon) {
+			return park, true
+		}
+	}
+	return nil, false
+}
+
+// All returns every park in configuration order
+func (r *ParkRegistry) All() []*Park {
+	return r.parks
+}
+
+// BoundariesHealthy reports whether the boundaries of every park loaded and
+// passed the region check
+func (r *ParkRegistry) BoundariesHealthy() bool {
+	for _, park := range r.parks {
+		if !park.Geo.BoundariesHealthy() {
+			return false
+		}
+	}
+	return true
+}
+
+// ExpectedRegion returns the smallest region covering the expected region of
+// every park
+func (r *ParkRegistry) ExpectedRegion() Region {
+	region := r.parks[0].Geo.ExpectedRegion()
+	for _, park := range r.parks[1:] {
+		other := park.Geo.ExpectedRegion()
+		region.MinLon = math.Min(region.MinLon, other.MinLon)
+		region.MinLat = math.Min(region.MinLat, other.MinLat)
+		region.MaxLon = math.Max(region.MaxLon, other.MaxLon)
+		region.MaxLat = math.Max(region.MaxLat, other.MaxLat)
+	}
+	return region
+}
+
+// SetLandMask sets the land mask of every park
+func (r *ParkRegistry) SetLandMask(mask *LandMask) {
+	for _, park := range r.parks {
+		park.Geo.SetLandMask(mask)
+	}
+}
+
+// SetTidalCurrents sets the tidal current predictions of every park
+func (r *ParkRegistry) SetTidalCurrents(currents *TidalCurrents) {
+	for _, park := range r.parks {
+		park.Geo.SetTidalCurrents(currents)
+	}
+}
+
+// SetLayerAlert registers the layer alert of every park
+func (r *ParkRegistry) SetLayerAlert(fn func(park *Park, status LayerStatus)) {
+	for _, park := range r.parks {
+		park := park
+		park.Geo.SetLayerAlert(func(status LayerStatus) {
+			fn(park, status)
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// CalendarPeriod is a named run of peak days, inclusive. Dates are MM-DD to
+// recur every year or YYYY-MM-DD for a single year; End defaults to Start. A
+// recurring period may wrap around the new year, e.g. 12-24 to 01-06.
+type CalendarPeriod struct {
+	Name  string `json:"name"`
+	Start string `json:"start"`
+	End   string `json:"end,omitempty"`
+}
+
+// CalendarConfig lists the days on which traffic peaks. Easter adds Easter
+// Sunday and Monday, whose dates move every year.
+type CalendarConfig struct {
+	Periods  []CalendarPeriod `json:"periods"`
+	Easter   bool             `json:"easter"`
+	Weekends bool             `json:"weekends"`
+}
+
+// DefaultCalendarConfig holds the Italian national holidays and the week of
+// Ferragosto, when the archipelago sees most of its pleasure craft
+func DefaultCalendarConfig() CalendarConfig {
+	return CalendarConfig{
+		Periods: []CalendarPeriod{
+			{Name: "Capodanno", Start: "01-01"},
+			{Name: "Epifania", Start: "01-06"},
+			{Name: "Festa della Liberazione", Start: "04-25"},
+			{Name: "Festa dei Lavoratori", Start: "05-01"},
+			{Name: "Festa della Repubblica", Start: "06-02"},
+			{Name: "Settimana di Ferragosto", Start: "08-12", End: "08-18"},
+			{Name: "Ognissanti", Start: "11-01"},
+			{Name: "Immacolata Concezione", Start: "12-08"},
+			{Name: "Natale", Start: "12-25"},
+			{Name: "Santo Stefano", Start: "12-26"},
+		},
+		Easter: true,
+	}
+}
+
+// LoadCalendarConfig reads the peak calendar from the JSON object in
+// PEAK_CALENDAR_FILE, falling back to the default calendar when it is unset
+func LoadCalendarConfig() (CalendarConfig, error) {
+	path := os.Getenv("PEAK_CALENDAR_FILE")
+	if path == "" {
+		return DefaultCalendarConfig(), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return CalendarConfig{}, fmt.Errorf("invalid PEAK_CALENDAR_FILE %q: %w", path, err)
+	}
+
+	var config CalendarConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return CalendarConfig{}, fmt.Errorf("invalid PEAK_CALENDAR_FILE %q: %w", path, err)
+	}
+	if _, err := parsePeriods(config.Periods); err != nil {
+		return CalendarConfig{}, fmt.Errorf("invalid PEAK_CALENDAR_FILE %q: %w", path, err)
+	}
+
+	return config, nil
+}
+
+// calendarDate is a period bound; year is 0 for a recurring date
+type calendarDate struct {
+	year     int
+	monthDay int // month*100 + day
+}
+
+func parseCalendarDate(value string) (calendarDate, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return calendarDate{year: t.Year(), monthDay: int(t.Month())*100 + t.Day()}, nil
+	}
+	// Parse against a leap year so 02-29 is accepted
+	if t, err := time.Parse("2006-01-02", "2024-"+value); err == nil {
+		return calendarDate{monthDay: int(t.Month())*100 + t.Day()}, nil
+	}
+	return calendarDate{}, fmt.Errorf("date %q must be MM-DD or YYYY-MM-DD", value)
+}
+
+type peakPeriod struct {
+	name       string
+	start, end calendarDate
+}
+
+func parsePeriods(periods []CalendarPeriod) ([]peakPeriod, error) {
+	parsed := make([]peakPeriod, 0, len(periods))
+	for i, period := range periods {
+		if period.Name == "" {
+			return nil, fmt.Errorf("period %d has no name", i+1)
+		}
+		start, err := parseCalendarDate(period.Start)
+		if err != nil {
+			return nil, fmt.Errorf("period %q: %w", period.Name, err)
+		}
+		end := start
+		if period.End != "" {
+			if end, err = parseCalendarDate(period.End); err != nil {
+				return nil, fmt.Errorf("period %q: %w", period.Name, err)
+			}
+		}
+		if (start.year == 0) != (end.year == 0) {
+			return nil, fmt.Errorf("period %q: start and end must both be MM-DD or both YYYY-MM-DD", period.Name)
+		}
+		if start.year != 0 && start.year*10000+start.monthDay > end.year*10000+end.monthDay {
+			return nil, fmt.Errorf("period %q ends before it starts", period.Name)
+		}
+		parsed = append(parsed, peakPeriod{name: period.Name, start: start, end: end})
+	}
+	return parsed, nil
+}
+
+// PeakCalendar tells peak days from off-peak ones
+type PeakCalendar struct {
+	config  CalendarConfig
+	periods []peakPeriod
+}
+
+func NewPeakCalendar(config CalendarConfig) (*PeakCalendar, error) {
+	periods, err := parsePeriods(config.Periods)
+	if err != nil {
+		return nil, err
+	}
+	return &PeakCalendar{config: config, periods: periods}, nil
+}
+
+// Config returns the calendar the peak days are taken from
+func (c *PeakCalendar) Config() CalendarConfig {
+	return c.config
+}
+
+// PeakReason returns the name of the first period, holiday or weekend that
+// makes the UTC day of t a peak day, and whether it is one
+func (c *PeakCalendar) PeakReason(t time.Time) (string, bool) {
+	t = t.UTC()
+	year, monthDay := t.Year(), int(t.Month())*100+t.Day()
+
+	for _, period := range c.periods {
+		if period.start.year != 0 {
+			date := year*10000 + monthDay
+			if date >= period.start.year*10000+period.start.monthDay && date <= period.end.year*10000+period.end.monthDay {
+				return period.name, true
+			}
+			continue
+		}
+		if period.start.monthDay <= period.end.monthDay {
+			if monthDay >= period.start.monthDay && monthDay <= period.end.monthDay {
+				return period.name, true
+			}
+		} else if monthDay >= period.start.monthDay || monthDay <= period.end.monthDay {
+			return period.name, true
+		}
+	}
+
+	if c.config.Easter {
+		easter := easterSunday(year)
+		switch t.Truncate(24 * time.Hour) {
+		case easter:
+			return "Easter", true
+		case easter.AddDate(0, 0, 1):
+			return "Easter Monday", true
+		}
+	}
+
+	if c.config.Weekends && (t.Weekday() == time.Saturday || t.Weekday() == time.Sunday) {
+		return "Weekend", true
+	}
+
+	return "", false
+}
+
+// easterSunday returns the date of Western Easter in a year, at midnight UTC,
+// with the anonymous Gregorian algorithm
+func easterSunday(year int) time.Time {
+	a := year % 19
+	b, c := year/100, year%100
+	d, e := b/4, b%4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i, k := c/4, c%4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+	"vessel-tracker/models"
+)
+
+// MaxPlaybackFrames bounds the frames a single playback returns
+const MaxPlaybackFrames = 1000
+
+// maxPlaybackPositions bounds the stored positions read for a playback, so a
+// long range over a busy park is refused rather than exhausting memory
+const maxPlaybackPositions = 200000
+
+// ErrPlaybackTooLarge is returned for a playback covering more positions
+// than are read at once
+var ErrPlaybackTooLarge = errors.New("playback covers too many positions")
+
+// PlaybackOptions sets how the frames of a playback are placed
+type PlaybackOptions struct {
+	Start       time.Time
+	End         time.Time
+	Step        time.Duration
+	Interpolate bool
+	MaxGap      time.Duration // longest time between two fixes to interpolate across
+	MaxAge      time.Duration // positions older than it at a frame are left out, 0 to keep them all
+}
+
+// PlaybackService builds the frames the web app replays vessel history from
+type PlaybackService struct {
+	vesselRepo *VesselRepository
+}
+
+func NewPlaybackService(vesselRepo *VesselRepository) *PlaybackService {
+	return &PlaybackService{
+		vesselRepo: vesselRepo,
+	}
+}
+
+// PlaybackFrameCount returns the number of frames from start to end, both
+// included, step apart
+func PlaybackFrameCount(start, end time.Time, step time.Duration) int {
+	return int(end.Sub(start)/step) + 1
+}
+
+// BuildPlayback places the vessels of a park at each frame time from start to
+// end, step apart, the way /vessels/at-time places them at a single time. The
+// fix of each vessel at start and the fixes stored up to end are read in two
+// queries, then the frames are filled in one pass over them.
+func (s *PlaybackService) BuildPlayback(ctx context.Context, park *Park, options PlaybackOptions) (*models.Playback, error) {
+	initial, err := s.vesselRepo.GetVesselPositionsAtTime(ctx, park.Record.ID, options.Start)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch positions at start: %w", err)
+	}
+
+	// Fixes after the last frame are only needed to interpolate up to it
+	until := options.End
+	if options.Interpolate {
+		until = until.Add(options.MaxGap)
+	}
+	later, err := s.vesselRepo.GetPositionsAfter(ctx, park.Record.ID, options.Start, until, maxPlaybackPositions+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch positions: %w", err)
+	}
+	if len(later) > maxPlaybackPositions {
+		return nil, fmt.Errorf("%w: more than %d stored between %s and %s", ErrPlaybackTooLarge, maxPlaybackPositions, options.Start.Format(time.RFC3339), until.Format(time.RFC3339))
+	}
+
+	playback := &models.Playback{
+		Park:        park.Record.Slug,
+		Start:       options.Start,
+		End:         options.End,
+		Step:        options.Step.String(),
+		Interpolate: options.Interpolate,
+		MaxGap:      options.MaxGap.String(),
+		Vessels:     make(map[string]models.PlaybackVessel),
+		Frames:      make([]models.PlaybackFrame, 0, PlaybackFrameCount(options.Start, options.End, options.Step)),
+	}
+	if options.MaxAge > 0 {
+		playback.MaxAge = options.MaxAge.String()
+	}
+
+	// Each vessel's fixes, oldest first; the initial fix precedes the others
+	tracks := make(map[string][]models.VesselPositionRecord)
+	for _, fix := range append(initial, later...) {
+		tracks[fix.VesselUUID] = append(tracks[fix.VesselUUID], fix)
+	}
+	uuids := make([]string, 0, len(tracks))
+	for uuid := range tracks {
+		uuids = append(uuids, uuid)
+	}
+	sort.Strings(uuids)
+
+	// cursor[uuid] is the index of the vessel's last fix at or before the
+	// frame, -1 before its first
+	cursor := make(map[string]int, len(uuids))
+	for _, uuid := range uuids {
+		cursor[uuid] = -1
+	}
+
+	for at := options.Start; !at.After(options.End); at = at.Add(options.Step) {
+		before := make([]models.VesselPositionRecord, 0, len(uuids))
+		after := make(map[string]models.VesselPositionRecord)
+		for _, uuid := range uuids {
+			track := tracks[uuid]
+			i := cursor[uuid]
+			for i+1 < len(track) && !track[i+1].RecordedAt.After(at) {
+				i++
+			}
+			cursor[uuid] = i
+			if i < 0 {
+				continue
+			}
+			before = append(before, track[i])
+			if options.Interpolate && i+1 < len(track) {
+				after[uuid] = track[i+1]
+			}
+		}
+
+		frame := models.PlaybackFrame{
+			Timestamp: at,
+			Positions: make([]models.PlaybackPosition, 0, len(before)),
+		}
+		for _, pos := range PlacePositions(park.Geo, before, after, at, options.MaxGap) {
+			if options.MaxAge > 0 && pos.Age > options.MaxAge {
+				continue
+			}
+
+			fixTime := pos.RecordedAt
+			if pos.Interpolated {
+				fixTime = at
+			}
+			frame.Positions = append(frame.Positions, models.PlaybackPosition{
+				VesselUUID:   pos.VesselUUID,
+				Latitude:     pos.Latitude,
+				Longitude:    pos.Longitude,
+				Speed:        pos.Speed,
+				Course:       pos.Course,
+				Heading:      pos.Heading,
+				IsInPark:     pos.IsInPark,
+				FixTime:      fixTime,
+				AgeSeconds:   int64(pos.Age.Round(time.Second) / time.Second),
+				Interpolated: pos.Interpolated,
+			})
+
+			if _, ok := playback.Vessels[pos.VesselUUID]; !ok {
+				playback.Vessels[pos.VesselUUID] = models.PlaybackVessel{
+					UUID:         pos.VesselUUID,
+					Name:         pos.Vessel.Name,
+					MMSI:         pos.Vessel.MMSI,
+					IMO:          pos.Vessel.IMO,
+					Type:         pos.Vessel.Type,
+					TypeSpecific: pos.Vessel.TypeSpecific,
+					CountryISO:   pos.Vessel.CountryISO,
+				}
+			}
+		}
+		frame.Count = len(frame.Positions)
+		playback.Frames = append(playback.Frames, frame)
+	}
+
+	return playback, nil
+}
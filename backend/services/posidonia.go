@@ -6,12 +6,19 @@ import (
 	"encoding/xml"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+	"vessel-tracker/logging"
 )
 
+// DefaultPosidoniaPath is the file posidonia meadows are read from when
+// POSIDONIA_FILE is not set
+var DefaultPosidoniaPath = filepath.Join(".", "data", "posidonia-maddalena.kmz")
+
 type KML struct {
 	XMLName  xml.Name `xml:"kml"`
 	Document Document `xml:"Document"`
@@ -267,13 +274,115 @@ func parseCoordinates(coordString string) [][]float64 {
 }
 
 func LoadPosidoniaData() (*GeoJSON, error) {
-	kmzPath := filepath.Join(".", "data", "posidonia-maddalena.kmz")
+	return LoadHabitatFile(PosidoniaPath())
+}
+
+// PosidoniaPath returns the habitat file posidonia meadows are read from,
+// POSIDONIA_FILE or DefaultPosidoniaPath. See LoadHabitatFile for the
+// supported formats.
+func PosidoniaPath() string {
+	if path := os.Getenv("POSIDONIA_FILE"); path != "" {
+		return path
+	}
+	return DefaultPosidoniaPath
+}
+
+// PosidoniaLayer caches the posidonia meadows parsed from a habitat file as
+// a StaticPayload, parsing the file again only when it changes on disk. If a
+// changed file fails to parse, the previous payload keeps being served.
+type PosidoniaLayer struct {
+	path   string
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	stamp   fileStamp
+	data    *GeoJSON
+	payload *StaticPayload
+}
+
+func NewPosidoniaLayer(path string) *PosidoniaLayer {
+	return &PosidoniaLayer{
+		path:   path,
+		logger: logging.Component("posidonia"),
+	}
+}
+
+// CacheStatus reports the version of the posidonia payload being served
+func (l *PosidoniaLayer) CacheStatus() CacheStatus {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 
-	if _, err := os.Stat(kmzPath); os.IsNotExist(err) {
-		return nil, fmt.Errorf("posidonia KMZ file not found at %s", kmzPath)
+	status := CacheStatus{Name: "posidonia", Loaded: l.payload != nil}
+	if l.payload != nil {
+		updatedAt := l.payload.LastModified
+		status.Version = l.payload.ETag
+		status.UpdatedAt = &updatedAt
 	}
+	return status
+}
+
+// Available reports whether the posidonia file exists
+func (l *PosidoniaLayer) Available() bool {
+	_, err := os.Stat(l.path)
+	return err == nil
+}
 
-	return ParseKMZToGeoJSON(kmzPath)
+// Payload returns the meadows as GeoJSON, parsed from the current file
+func (l *PosidoniaLayer) Payload() (*StaticPayload, error) {
+	_, payload, err := l.Data()
+	return payload, err
+}
+
+// Data returns the meadows parsed from the current file along with their
+// payload, whose ETag tells the versions of the file apart. The GeoJSON is
+// shared between callers and must not be modified.
+func (l *PosidoniaLayer) Data() (*GeoJSON, *StaticPayload, error) {
+	stamp, err := habitatStamp(l.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = fmt.Errorf("posidonia file not found at %s", l.path)
+		}
+		return nil, nil, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if stamp == l.stamp && l.payload != nil {
+		return l.data, l.payload, nil
+	}
+
+	data, payload, err := l.load(stamp)
+	if err != nil {
+		if l.payload == nil {
+			return nil, nil, err
+		}
+		l.logger.Error("Posidonia file changed on disk but could not be parsed, serving the previous data", "path", l.path, "error", err)
+		l.stamp = stamp
+		return l.data, l.payload, nil
+	}
+
+	l.stamp = stamp
+	l.data = data
+	l.payload = payload
+	l.logger.Info("Loaded posidonia data", "path", l.path, "bytes", len(payload.JSON), "gzip_bytes", len(payload.Gzip))
+	return data, payload, nil
+}
+
+func (l *PosidoniaLayer) load(stamp fileStamp) (*GeoJSON, *StaticPayload, error) {
+	geoJSON, err := LoadHabitatFile(l.path)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := json.Marshal(geoJSON)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode posidonia data: %w", err)
+	}
+	payload, err := newStaticPayload(data, stamp.modTime)
+	if err != nil {
+		return nil, nil, err
+	}
+	return geoJSON, payload, nil
 }
 
 // parsePosidoniaType extracts posidonia bed type information from KML descriptions
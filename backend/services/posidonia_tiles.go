@@ -0,0 +1,221 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"container/list"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"vessel-tracker/logging"
+)
+
+// ErrInvalidTile is returned for tile coordinates outside the tile grid or
+// beyond TILE_MAX_ZOOM
+var ErrInvalidTile = errors.New("invalid tile")
+
+// posidoniaTileLayer names the layer of the posidonia tiles, the source-layer
+// a map styles them by
+const posidoniaTileLayer = "posidonia"
+
+// TileConfig holds how vector tiles are cut and how many are kept in memory
+type TileConfig struct {
+	MaxZoom   int
+	CacheSize int     // tiles kept in memory, 0 to encode every request
+	Tolerance float64 // simplification, in tile units of 1/4096 of a tile side
+	Buffer    int     // geometry kept past the tile edges, in tile units
+}
+
+func DefaultTileConfig() TileConfig {
+	return TileConfig{
+		MaxZoom:   18,
+		CacheSize: 4096,
+		Tolerance: 4,
+		Buffer:    64,
+	}
+}
+
+// LoadTileConfig reads TILE_MAX_ZOOM, TILE_CACHE_SIZE,
+// TILE_SIMPLIFY_TOLERANCE and TILE_BUFFER
+func LoadTileConfig() (TileConfig, error) {
+	config := DefaultTileConfig()
+
+	if value := os.Getenv("TILE_MAX_ZOOM"); value != "" {
+		zoom, err := strconv.Atoi(value)
+		if err != nil || zoom < 0 || zoom > 24 {
+			return config, fmt.Errorf("invalid TILE_MAX_ZOOM %q: must be between 0 and 24", value)
+		}
+		config.MaxZoom = zoom
+	}
+	if value := os.Getenv("TILE_CACHE_SIZE"); value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil || size < 0 {
+			return config, fmt.Errorf("invalid TILE_CACHE_SIZE %q: must be a non-negative integer", value)
+		}
+		config.CacheSize = size
+	}
+	if value := os.Getenv("TILE_SIMPLIFY_TOLERANCE"); value != "" {
+		tolerance, err := strconv.ParseFloat(value, 64)
+		if err != nil || tolerance < 0 {
+			return config, fmt.Errorf("invalid TILE_SIMPLIFY_TOLERANCE %q: must be a non-negative number", value)
+		}
+		config.Tolerance = tolerance
+	}
+	if value := os.Getenv("TILE_BUFFER"); value != "" {
+		buffer, err := strconv.Atoi(value)
+		if err != nil || buffer < 0 || buffer > mvtExtent/2 {
+			return config, fmt.Errorf("invalid TILE_BUFFER %q: must be between 0 and %d", value, mvtExtent/2)
+		}
+		config.Buffer = buffer
+	}
+
+	return config, nil
+}
+
+// VectorTile is an encoded Mapbox Vector Tile. Data is empty for a tile
+// without features. The slices are shared between requests and must not be
+// modified.
+type VectorTile struct {
+	Data         []byte
+	Gzip         []byte
+	ETag         string
+	LastModified time.Time
+}
+
+// cachedTile is a tile in the cache, under its z/x/y key
+type cachedTile struct {
+	key  string
+	tile *VectorTile
+}
+
+// PosidoniaTiles cuts the posidonia meadows into vector tiles on request, so
+// a map only loads the meadows in view, simplified for its zoom level. The
+// most recently requested tiles are kept in memory; they are dropped when
+// the posidonia file changes.
+type PosidoniaTiles struct {
+	layer  *PosidoniaLayer
+	config TileConfig
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	version string // ETag of the posidonia payload the source was built from
+	source  *vectorTileSource
+	tiles   map[string]*list.Element
+	recent  *list.List // most recently used first
+}
+
+func NewPosidoniaTiles(layer *PosidoniaLayer, config TileConfig) *PosidoniaTiles {
+	return &PosidoniaTiles{
+		layer:  layer,
+		config: config,
+		logger: logging.Component("posidonia-tiles"),
+		tiles:  make(map[string]*list.Element),
+		recent: list.New(),
+	}
+}
+
+// Config returns the active tile settings
+func (t *PosidoniaTiles) Config() TileConfig {
+	return t.config
+}
+
+// CacheStatus reports how many tiles are cached and of which posidonia
+// version
+func (t *PosidoniaTiles) CacheStatus() CacheStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return CacheStatus{
+		Name:    "posidonia-tiles",
+		Loaded:  t.source != nil,
+		Entries: len(t.tiles),
+		Version: t.version,
+	}
+}
+
+// Tile returns tile z/x/y of the posidonia layer, from the cache when the
+// posidonia file has not changed since it was encoded
+func (t *PosidoniaTiles) Tile(z, x, y int) (*VectorTile, error) {
+	if z < 0 || z > t.config.MaxZoom {
+		return nil, fmt.Errorf("%w: zoom must be between 0 and %d", ErrInvalidTile, t.config.MaxZoom)
+	}
+	if n := 1 << z; x < 0 || x >= n || y < 0 || y >= n {
+		return nil, fmt.Errorf("%w: x and y must be between 0 and %d at zoom %d", ErrInvalidTile, n-1, z)
+	}
+
+	data, payload, err := t.layer.Data()
+	if err != nil {
+		return nil, err
+	}
+	key := fmt.Sprintf("%d/%d/%d", z, x, y)
+
+	t.mu.Lock()
+	if t.version != payload.ETag {
+		source, skipped := newVectorTileSource(posidoniaTileLayer, data)
+		if skipped > 0 {
+			t.logger.Warn("Posidonia features left out of the tiles for an unreadable geometry", "skipped", skipped)
+		}
+		t.source = source
+		t.version = payload.ETag
+		t.tiles = make(map[string]*list.Element)
+		t.recent.Init()
+	} else if element, ok := t.tiles[key]; ok {
+		t.recent.MoveToFront(element)
+		t.mu.Unlock()
+		return element.Value.(*cachedTile).tile, nil
+	}
+	source, version := t.source, t.version
+	t.mu.Unlock()
+
+	// Encoded outside the lock, so the tiles of one map view are cut in
+	// parallel
+	tile, err := newVectorTile(source.encodeTile(z, x, y, float64(t.config.Buffer), t.config.Tolerance), version, key, payload.LastModified)
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.version == version && t.config.CacheSize > 0 {
+		if element, ok := t.tiles[key]; ok {
+			t.recent.MoveToFront(element)
+			return element.Value.(*cachedTile).tile, nil
+		}
+		t.tiles[key] = t.recent.PushFront(&cachedTile{key: key, tile: tile})
+		for t.recent.Len() > t.config.CacheSize {
+			oldest := t.recent.Back()
+			t.recent.Remove(oldest)
+			delete(t.tiles, oldest.Value.(*cachedTile).key)
+		}
+	}
+	return tile, nil
+}
+
+// newVectorTile gzips an encoded tile, which tile clients accept as the
+// Content-Encoding, and tags it with the posidonia version and its position
+func newVectorTile(data []byte, version, key string, lastModified time.Time) (*VectorTile, error) {
+	tile := &VectorTile{
+		Data:         data,
+		ETag:         `"` + strings.Trim(version, `"`) + "-" + strings.ReplaceAll(key, "/", "-") + `"`,
+		LastModified: lastModified,
+	}
+	if len(data) == 0 {
+		return tile, nil
+	}
+
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	if _, err := writer.Write(data); err != nil {
+		return nil, fmt.Errorf("failed to compress tile: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress tile: %w", err)
+	}
+	tile.Gzip = compressed.Bytes()
+	return tile, nil
+}
@@ -0,0 +1,246 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"vessel-tracker/database"
+	"vessel-tracker/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PositionPrivacyConfig sets how vessel positions are degraded for the public:
+// how old they must be, and how coarsely small pleasure craft are placed
+type PositionPrivacyConfig struct {
+	Delay         time.Duration // positions younger than this are withheld; 0 serves them live
+	FuzzMeters    float64       // grid small craft are snapped to; 0 serves them precisely
+	FuzzTypes     []string      // vessel types fuzzed, matched case-insensitively within the type
+	FuzzMaxLength float64       // meters; longer vessels are not fuzzed, 0 fuzzes any length
+}
+
+func DefaultPositionPrivacyConfig() PositionPrivacyConfig {
+	return PositionPrivacyConfig{
+		Delay:         time.Hour,
+		FuzzMeters:    500,
+		FuzzTypes:     []string{"pleasure", "sailing", "yacht"},
+		FuzzMaxLength: 24,
+	}
+}
+
+// LoadPositionPrivacyConfig reads PUBLIC_POSITION_DELAY,
+// PUBLIC_POSITION_FUZZ_METERS, PUBLIC_FUZZ_VESSEL_TYPES (comma separated) and
+// PUBLIC_FUZZ_MAX_LENGTH, falling back to the defaults when unset
+func LoadPositionPrivacyConfig() (PositionPrivacyConfig, error) {
+	config := DefaultPositionPrivacyConfig()
+
+	if value := os.Getenv("PUBLIC_POSITION_DELAY"); value != "" {
+		delay, err := time.ParseDuration(value)
+		if err != nil || delay < 0 {
+			return config, fmt.Errorf("invalid PUBLIC_POSITION_DELAY %q: must be a non-negative duration", value)
+		}
+		config.Delay = delay
+	}
+
+	if value := os.Getenv("PUBLIC_POSITION_FUZZ_METERS"); value != "" {
+		meters, err := strconv.ParseFloat(value, 64)
+		if err != nil || meters < 0 {
+			return config, fmt.Errorf("invalid PUBLIC_POSITION_FUZZ_METERS %q: must be a non-negative number of meters", value)
+		}
+		config.FuzzMeters = meters
+	}
+
+	if value := os.Getenv("PUBLIC_FUZZ_VESSEL_TYPES"); value != "" {
+		config.FuzzTypes = nil
+		for _, part := range strings.Split(value, ",") {
+			if part = strings.ToLower(strings.TrimSpace(part)); part != "" {
+				config.FuzzTypes = append(config.FuzzTypes, part)
+			}
+		}
+	}
+
+	if value := os.Getenv("PUBLIC_FUZZ_MAX_LENGTH"); value != "" {
+		length, err := strconv.ParseFloat(value, 64)
+		if err != nil || length < 0 {
+			return config, fmt.Errorf("invalid PUBLIC_FUZZ_MAX_LENGTH %q: must be a non-negative number of meters", value)
+		}
+		config.FuzzMaxLength = length
+	}
+
+	return config, nil
+}
+
+// PositionPrivacy degrades the vessel positions served to the public: it
+// withholds positions younger than the configured delay and snaps those of
+// small pleasure craft to a coarse grid
+type PositionPrivacy struct {
+	config PositionPrivacyConfig
+	db     *gorm.DB
+}
+
+func NewPositionPrivacy(config PositionPrivacyConfig) *PositionPrivacy {
+	return &PositionPrivacy{config: config, db: database.GetDB()}
+}
+
+func (p *PositionPrivacy) Config() PositionPrivacyConfig {
+	return p.config
+}
+
+// Enabled reports whether public positions are degraded at all
+func (p *PositionPrivacy) Enabled() bool {
+	return p.config.Delay > 0 || p.config.FuzzMeters > 0
+}
+
+// Cutoff is the time of the newest position the public may see
+func (p *PositionPrivacy) Cutoff(now time.Time) time.Time {
+	return now.Add(-p.config.Delay)
+}
+
+// Withheld reports whether a position from the given time is too recent to
+// be served to the public
+func (p *PositionPrivacy) Withheld(at, now time.Time) bool {
+	return p.config.Delay > 0 && at.After(p.Cutoff(now))
+}
+
+// Fuzzes reports whether the positions of a vessel are served coarsely
+func (p *PositionPrivacy) Fuzzes(vessel *models.VesselRecord) bool {
+	if p.config.FuzzMeters <= 0 || vessel == nil {
+		return false
+	}
+	if p.config.FuzzMaxLength > 0 && vessel.Length > p.config.FuzzMaxLength {
+		return false
+	}
+	vesselType := strings.ToLower(vessel.Type + " " + vessel.TypeSpecific)
+	for _, fuzzed := range p.config.FuzzTypes {
+		if strings.Contains(vesselType, fuzzed) {
+			return true
+		}
+	}
+	return false
+}
+
+// FuzzedVessels returns which of the given vessels are served coarsely
+func (p *PositionPrivacy) FuzzedVessels(vesselUUIDs []string) (map[string]bool, error) {
+	fuzzed := make(map[string]bool)
+	if p.config.FuzzMeters <= 0 || len(vesselUUIDs) == 0 {
+		return fuzzed, nil
+	}
+
+	var vessels []models.VesselRecord
+	if err := p.db.Select("uuid, type, type_specific, length").Where("uuid IN ?", vesselUUIDs).Find(&vessels).Error; err != nil {
+		return nil, fmt.Errorf("failed to classify vessels: %w", err)
+	}
+	for i := range vessels {
+		if p.Fuzzes(&vessels[i]) {
+			fuzzed[vessels[i].UUID] = true
+		}
+	}
+	return fuzzed, nil
+}
+
+// Fuzz snaps a position to the center of its grid cell. The grid is fixed,
+// so repeated requests cannot be averaged back to the precise position.
+func (p *PositionPrivacy) Fuzz(lat, lon float64) (float64, float64) {
+	if p.config.FuzzMeters <= 0 {
+		return lat, lon
+	}
+
+	latStep := p.config.FuzzMeters / metersPerDegreeLat
+	row := math.Floor(lat / latStep)
+	lat = (row + 0.5) * latStep
+
+	// Cells of a row share their width, taken at the row's center
+	lonStep := p.config.FuzzMeters / (metersPerDegreeLat * math.Max(math.Cos(lat*math.Pi/180), 0.01))
+	lon = (math.Floor(lon/lonStep) + 0.5) * lonStep
+	return lat, lon
+}
+
+// metersPerDegreeLat is the length of a degree of latitude
+const metersPerDegreeLat = 111320.0
+
+// FuzzTrack drops the points of a track too recent for the public and fuzzes
+// the rest when the vessel is served coarsely
+func (p *PositionPrivacy) FuzzTrack(track *Track, vessel *models.VesselRecord, now time.Time) {
+	fuzz := p.Fuzzes(vessel)
+	points := track.Points[:0]
+	for _, point := range track.Points {
+		if p.Withheld(point.Time, now) {
+			continue
+		}
+		if fuzz {
+			point.Latitude, point.Longitude = p.Fuzz(point.Latitude, point.Longitude)
+		}
+		points = append(points, point)
+	}
+	track.Points = points
+}
+
+type positionCutoffKey struct{}
+
+// WithPositionCutoff limits the stored positions read with the returned
+// context to those recorded up to the cutoff, see InstallPositionCutoff
+func WithPositionCutoff(ctx context.Context, cutoff time.Time) context.Context {
+	return context.WithValue(ctx, positionCutoffKey{}, cutoff)
+}
+
+// PublicContext returns the context the public reads stored positions with:
+// limited to those old enough to be served to them, when there is a delay
+func (p *PositionPrivacy) PublicContext(ctx context.Context, now time.Time) context.Context {
+	if p.config.Delay <= 0 {
+		return ctx
+	}
+	return WithPositionCutoff(ctx, p.Cutoff(now))
+}
+
+// positionDating is how the rows of a table holding vessel positions are
+// dated: by column, and final span after it, as an hourly rollup bucket only
+// holds all of its positions once the hour is over
+type positionDating struct {
+	column string
+	span   time.Duration
+}
+
+// positionTables lists every table holding vessel positions. Reads of them
+// with a cutoff leave out the rows not final by then.
+var positionTables = map[string]positionDating{
+	"vessel_position_records": {column: "recorded_at"},
+	"violations":              {column: "detected_at"},
+	"zone_events":             {column: "occurred_at"},
+	"park_arrivals":           {column: "first_seen_at"},
+	// An anchoring event follows its vessel until the event ends
+	"anchoring_events":          {column: "last_seen_at"},
+	database.PositionRollupView: {column: "bucket", span: database.PositionRollupBucket},
+}
+
+// InstallPositionCutoff makes every query of a table in positionTables made
+// with a context from WithPositionCutoff, subqueries included, ignore the
+// rows dated after the cutoff. Queries for the latest position of each vessel
+// then return the positions as of the cutoff.
+func InstallPositionCutoff(db *gorm.DB) error {
+	limit := func(db *gorm.DB) {
+		if db.Statement.Context == nil {
+			return
+		}
+		dating, ok := positionTables[db.Statement.Table]
+		if !ok {
+			return
+		}
+		cutoff, ok := db.Statement.Context.Value(positionCutoffKey{}).(time.Time)
+		if !ok {
+			return
+		}
+		db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+			clause.Lte{Column: clause.Column{Table: clause.CurrentTable, Name: dating.column}, Value: cutoff.Add(-dating.span)},
+		}})
+	}
+
+	if err := db.Callback().Query().Before("gorm:query").Register("privacy:position_cutoff", limit); err != nil {
+		return err
+	}
+	return db.Callback().Row().Before("gorm:row").Register("privacy:position_cutoff", limit)
+}
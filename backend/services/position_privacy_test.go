@@ -0,0 +1,147 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"vessel-tracker/database"
+	"vessel-tracker/models"
+
+	"gorm.io/gorm"
+)
+
+// openTestPrivacy migrates a fresh in-memory SQLite database with the
+// position cutoff installed and returns the privacy served to the public
+func openTestPrivacy(t *testing.T) *PositionPrivacy {
+	openTestDatabase(t, testDatabases(t)["sqlite"])
+	if err := InstallPositionCutoff(database.DB); err != nil {
+		t.Fatalf("InstallPositionCutoff: %v", err)
+	}
+	return NewPositionPrivacy(PositionPrivacyConfig{Delay: time.Hour})
+}
+
+func TestPublicTrackExportsLeaveOutRecentPositions(t *testing.T) {
+	privacy := openTestPrivacy(t)
+	now := time.Now().UTC().Truncate(time.Second)
+	vessel := models.VesselRecord{UUID: "ferry", Name: "Ferry", MMSI: "247000301"}
+	if err := database.DB.Create(&vessel).Error; err != nil {
+		t.Fatalf("create vessel: %v", err)
+	}
+	old, recent := now.Add(-2*time.Hour), now.Add(-10*time.Minute)
+	positions := []models.VesselPositionRecord{
+		{VesselUUID: vessel.UUID, ParkID: 1, Latitude: 41.2001, Longitude: 9.4001, RecordedAt: old},
+		{VesselUUID: vessel.UUID, ParkID: 1, Latitude: 41.2999, Longitude: 9.4999, RecordedAt: recent},
+	}
+	if err := database.DB.Create(&positions).Error; err != nil {
+		t.Fatalf("create positions: %v", err)
+	}
+
+	repo := NewVesselRepository(DefaultPositionDedupConfig())
+	history, err := repo.GetVesselHistory(privacy.PublicContext(context.Background(), now), 1, vessel.UUID, now.Add(-24*time.Hour), now, 0)
+	if err != nil {
+		t.Fatalf("GetVesselHistory: %v", err)
+	}
+	if len(history) != 1 || !history[0].RecordedAt.Equal(old) {
+		t.Fatalf("public history = %+v, want only the position at %v", history, old)
+	}
+
+	track := NewTrack(&Park{Record: models.Park{Name: "La Maddalena"}}, &vessel, history)
+	renderers := map[string]func(*Track) ([]byte, error){"gpx": RenderTrackGPX, "kml": RenderTrackKML}
+	for format, render := range renderers {
+		data, err := render(track)
+		if err != nil {
+			t.Fatalf("render %s: %v", format, err)
+		}
+		if !bytes.Contains(data, []byte("41.2001")) {
+			t.Fatalf("public %s track is missing the delayed position:\n%s", format, data)
+		}
+		if bytes.Contains(data, []byte("41.2999")) {
+			t.Fatalf("public %s track holds a position younger than the delay:\n%s", format, data)
+		}
+	}
+
+	history, err = repo.GetVesselHistory(context.Background(), 1, vessel.UUID, now.Add(-24*time.Hour), now, 0)
+	if err != nil {
+		t.Fatalf("GetVesselHistory: %v", err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("history without a cutoff has %d positions, want 2", len(history))
+	}
+}
+
+func TestPublicNoticeWithheldUntilDelayPassed(t *testing.T) {
+	privacy := openTestPrivacy(t)
+	now := time.Now().UTC()
+	violations := []models.Violation{
+		{VesselUUID: "speeder", VesselName: "Old Speeder", MMSI: "247000401", Type: models.ViolationExcessiveSpeed, DetectedAt: now.Add(-2 * time.Hour)},
+		{VesselUUID: "speeder", VesselName: "New Speeder", MMSI: "247000401", Type: models.ViolationExcessiveSpeed, DetectedAt: now.Add(-10 * time.Minute)},
+	}
+	if err := database.DB.Create(&violations).Error; err != nil {
+		t.Fatalf("create violations: %v", err)
+	}
+
+	service := NewViolationService(nil)
+	public := privacy.PublicContext(context.Background(), now)
+	if _, err := service.GetViolation(public, violations[1].ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("public notice of a recent violation = %v, want gorm.ErrRecordNotFound", err)
+	}
+
+	violation, err := service.GetViolation(public, violations[0].ID)
+	if err != nil {
+		t.Fatalf("GetViolation: %v", err)
+	}
+	notice, err := NewNoticeService("../templates/notices", DefaultNoticeConfig(), nil).RenderNotice(violation)
+	if err != nil {
+		t.Fatalf("RenderNotice: %v", err)
+	}
+	if !strings.Contains(notice, "Old Speeder") {
+		t.Fatalf("notice does not name the vessel:\n%s", notice)
+	}
+	if pdf := RenderTextPDF("Violation notice", notice); !bytes.Contains(pdf, []byte("Old Speeder")) {
+		t.Fatal("PDF notice does not name the vessel")
+	}
+}
+
+func TestPublicViolationStreamIsDelayed(t *testing.T) {
+	privacy := openTestPrivacy(t)
+	service := NewViolationService(nil)
+	now := time.Now().UTC()
+	create := func(detectedAt time.Time) models.Violation {
+		violation := models.Violation{VesselUUID: "speeder", Type: models.ViolationExcessiveSpeed, DetectedAt: detectedAt}
+		if err := database.DB.Create(&violation).Error; err != nil {
+			t.Fatalf("create violation: %v", err)
+		}
+		return violation
+	}
+	old := create(now.Add(-2 * time.Hour))
+	recent := create(now.Add(-10 * time.Minute))
+
+	public := privacy.PublicContext(context.Background(), now)
+	latest, err := service.GetLatestViolationID(public)
+	if err != nil {
+		t.Fatalf("GetLatestViolationID: %v", err)
+	}
+	if latest != old.ID {
+		t.Fatalf("public stream starts after violation %d, want %d", latest, old.ID)
+	}
+	since, err := service.GetViolationsSince(public, 0, 100)
+	if err != nil {
+		t.Fatalf("GetViolationsSince: %v", err)
+	}
+	if len(since) != 1 || since[0].ID != old.ID {
+		t.Fatalf("public replay = %+v, want only violation %d", since, old.ID)
+	}
+
+	// Once the delay has passed the recent violation is streamed too
+	later := privacy.PublicContext(context.Background(), now.Add(time.Hour))
+	since, err = service.GetViolationsSince(later, latest, 100)
+	if err != nil {
+		t.Fatalf("GetViolationsSince: %v", err)
+	}
+	if len(since) != 1 || since[0].ID != recent.ID {
+		t.Fatalf("public stream an hour later = %+v, want violation %d", since, recent.ID)
+	}
+}
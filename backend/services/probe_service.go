@@ -0,0 +1,275 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"vessel-tracker/database"
+	"vessel-tracker/logging"
+
+	"github.com/robfig/cron/v3"
+	"gorm.io/gorm"
+)
+
+// ProbeConfig holds the synthetic monitoring settings. The inside and outside
+// points are reference positions whose classification is known, so a
+// misplaced or swapped boundary file shows up as a failed probe.
+type ProbeConfig struct {
+	Interval        time.Duration // how often the probe runs
+	ProviderTimeout time.Duration // timeout for the provider reachability check
+	InsideLat       float64       // a point known to lie inside the park
+	InsideLon       float64
+	OutsideLat      float64 // a point known to lie outside the park and buffer zone
+	OutsideLon      float64
+}
+
+func DefaultProbeConfig() ProbeConfig {
+	return ProbeConfig{
+		Interval:        5 * time.Minute,
+		ProviderTimeout: 10 * time.Second,
+		// Open water between La Maddalena and Caprera
+		InsideLat: 41.2167,
+		InsideLon: 9.4167,
+		// Tyrrhenian Sea east of Olbia
+		OutsideLat: 40.9,
+		OutsideLon: 9.7,
+	}
+}
+
+// LoadProbeConfig reads PROBE_INTERVAL, PROBE_PROVIDER_TIMEOUT and the
+// "lat,lon" reference points PROBE_INSIDE_POINT and PROBE_OUTSIDE_POINT,
+// falling back to the defaults for unset variables
+func LoadProbeConfig() (ProbeConfig, error) {
+	config := DefaultProbeConfig()
+
+	durationVars := map[string]*time.Duration{
+		"PROBE_INTERVAL":         &config.Interval,
+		"PROBE_PROVIDER_TIMEOUT": &config.ProviderTimeout,
+	}
+	for name, target := range durationVars {
+		if value := os.Getenv(name); value != "" {
+			d, err := time.ParseDuration(value)
+			if err != nil || d <= 0 {
+				return config, fmt.Errorf("invalid %s %q: must be a positive duration", name, value)
+			}
+			*target = d
+		}
+	}
+
+	pointVars := map[string][2]*float64{
+		"PROBE_INSIDE_POINT":  {&config.InsideLat, &config.InsideLon},
+		"PROBE_OUTSIDE_POINT": {&config.OutsideLat, &config.OutsideLon},
+	}
+	for name, target := range pointVars {
+		if value := os.Getenv(name); value != "" {
+			lat, lon, err := parsePoint(value)
+			if err != nil {
+				return config, fmt.Errorf("invalid %s %q: %w", name, value, err)
+			}
+			*target[0], *target[1] = lat, lon
+		}
+	}
+
+	if config.Interval < time.Minute {
+		return config, fmt.Errorf("probe interval must be at least 1m, got %s", config.Interval)
+	}
+
+	return config, nil
+}
+
+// parsePoint reads a "lat,lon" pair
+func parsePoint(value string) (float64, float64, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("expected lat,lon")
+	}
+
+	lat, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return 0, 0, fmt.Errorf("latitude must be between -90 and 90")
+	}
+	lon, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil || lon < -180 || lon > 180 {
+		return 0, 0, fmt.Errorf("longitude must be between -180 and 180")
+	}
+
+	return lat, lon, nil
+}
+
+// Names of the individual probe checks
+const (
+	ProbeCheckInsidePoint  = "inside_point"
+	ProbeCheckOutsidePoint = "outside_point"
+	ProbeCheckDatabase     = "database"
+	ProbeCheckProvider     = "provider"
+)
+
+// ProbeCheck is the outcome of one check of a probe run
+type ProbeCheck struct {
+	Name      string `json:"name"`
+	OK        bool   `json:"ok"`
+	LatencyMs int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ProbeReport is the outcome of the latest probe run together with running
+// totals across runs
+type ProbeReport struct {
+	OK                  bool         `json:"ok"`
+	Checks              []ProbeCheck `json:"checks"`
+	LastRunAt           *time.Time   `json:"last_run_at"`
+	LastSuccessAt       *time.Time   `json:"last_success_at"`
+	Runs                int64        `json:"runs"`
+	Failures            int64        `json:"failures"`
+	ConsecutiveFailures int64        `json:"consecutive_failures"`
+}
+
+// ProbeService periodically runs an end-to-end self test: reference points
+// are classified against the loaded boundaries, the database is round-tripped
+// and the vessel data provider is contacted
+type ProbeService struct {
+	db            *gorm.DB
+	cron          *cron.Cron
+	config        ProbeConfig
+	geoService    *GeoService
+	vesselService *VesselService
+	logger        *slog.Logger
+
+	mu     sync.RWMutex
+	report ProbeReport
+}
+
+func NewProbeService(config ProbeConfig, geoService *GeoService, vesselService *VesselService) *ProbeService {
+	return &ProbeService{
+		db:            database.GetDB(),
+		cron:          cron.New(cron.WithSeconds()),
+		config:        config,
+		geoService:    geoService,
+		vesselService: vesselService,
+		logger:        logging.Component("probe"),
+	}
+}
+
+func (s *ProbeService) Start() error {
+	if _, err := s.cron.AddFunc(fmt.Sprintf("@every %s", s.config.Interval), s.Run); err != nil {
+		return err
+	}
+
+	s.cron.Start()
+	s.logger.Info("Probe started", "interval", s.config.Interval.String())
+
+	go s.Run()
+
+	return nil
+}
+
+func (s *ProbeService) Stop() {
+	s.cron.Stop()
+}
+
+// Report returns the outcome of the latest probe run
+func (s *ProbeService) Report() ProbeReport {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	report := s.report
+	report.Checks = append([]ProbeCheck(nil), s.report.Checks...)
+	return report
+}
+
+// Healthy reports whether the latest probe run passed. It is true until the
+// first run has completed.
+func (s *ProbeService) Healthy() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.report.LastRunAt == nil || s.report.OK
+}
+
+// Run executes every check once and stores the result
+func (s *ProbeService) Run() {
+	checks := []ProbeCheck{
+		s.timed(ProbeCheckInsidePoint, s.checkInsidePoint),
+		s.timed(ProbeCheckOutsidePoint, s.checkOutsidePoint),
+		s.timed(ProbeCheckDatabase, s.checkDatabase),
+		s.timed(ProbeCheckProvider, s.checkProvider),
+	}
+
+	ok := true
+	for _, check := range checks {
+		if !check.OK {
+			ok = false
+			s.logger.Warn("Probe check failed", "check", check.Name, "error", check.Error)
+		}
+	}
+
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.report.OK = ok
+	s.report.Checks = checks
+	s.report.LastRunAt = &now
+	s.report.Runs++
+	if ok {
+		s.report.LastSuccessAt = &now
+		s.report.ConsecutiveFailures = 0
+	} else {
+		s.report.Failures++
+		s.report.ConsecutiveFailures++
+	}
+}
+
+func (s *ProbeService) timed(name string, check func() error) ProbeCheck {
+	start := time.Now()
+	err := check()
+
+	result := ProbeCheck{
+		Name:      name,
+		OK:        err == nil,
+		LatencyMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+func (s *ProbeService) checkInsidePoint() error {
+	lat, lon := s.config.InsideLat, s.config.InsideLon
+	if !s.geoService.IsPointInsideParkBoundary(lat, lon) {
+		return fmt.Errorf("reference point %.5f,%.5f is not classified as inside the park", lat, lon)
+	}
+	return nil
+}
+
+func (s *ProbeService) checkOutsidePoint() error {
+	lat, lon := s.config.OutsideLat, s.config.OutsideLon
+	if s.geoService.IsPointInPark(lat, lon) || s.geoService.IsPointInBufferZone(lat, lon) {
+		return fmt.Errorf("reference point %.5f,%.5f is classified as inside the park or buffer zone", lat, lon)
+	}
+	return nil
+}
+
+func (s *ProbeService) checkDatabase() error {
+	var result int
+	if err := s.db.Raw("SELECT 1").Scan(&result).Error; err != nil {
+		return fmt.Errorf("database query failed: %w", err)
+	}
+	if result != 1 {
+		return fmt.Errorf("database returned %d for SELECT 1", result)
+	}
+	return nil
+}
+
+func (s *ProbeService) checkProvider() error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.ProviderTimeout)
+	defer cancel()
+	return s.vesselService.Ping(ctx)
+}
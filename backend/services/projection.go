@@ -0,0 +1,200 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// projection converts the coordinates of a habitat file to WGS84 longitude
+// and latitude
+type projection func(x, y float64) (lon, lat float64)
+
+// geographic leaves coordinates that already are longitude and latitude
+func geographic(x, y float64) (float64, float64) {
+	return x, y
+}
+
+// transverseMercator describes a Transverse Mercator grid such as UTM
+type transverseMercator struct {
+	SemiMajor         float64 // ellipsoid semi-major axis in meters
+	InverseFlattening float64
+	CentralMeridian   float64 // degrees
+	LatitudeOfOrigin  float64 // degrees
+	ScaleFactor       float64
+	FalseEasting      float64 // meters
+	FalseNorthing     float64 // meters
+}
+
+// utm returns the UTM zone on the WGS84 ellipsoid. ETRS89 uses GRS80, which
+// differs from WGS84 by less than a millimeter.
+func utm(zone int, south bool) transverseMercator {
+	tm := transverseMercator{
+		SemiMajor:         6378137,
+		InverseFlattening: 298.257223563,
+		CentralMeridian:   float64(zone*6 - 183),
+		ScaleFactor:       0.9996,
+		FalseEasting:      500000,
+	}
+	if south {
+		tm.FalseNorthing = 10000000
+	}
+	return tm
+}
+
+// meridianArc returns the distance along the meridian from the equator to
+// latitude phi (radians)
+func meridianArc(a, e2, phi float64) float64 {
+	e4, e6 := e2*e2, e2*e2*e2
+	return a * ((1-e2/4-3*e4/64-5*e6/256)*phi -
+		(3*e2/8+3*e4/32+45*e6/1024)*math.Sin(2*phi) +
+		(15*e4/256+45*e6/1024)*math.Sin(4*phi) -
+		(35*e6/3072)*math.Sin(6*phi))
+}
+
+// inverse converts grid coordinates to longitude and latitude with the
+// series of Snyder's Map Projections: A Working Manual, accurate to well
+// under a meter within a UTM zone
+func (tm transverseMercator) inverse(x, y float64) (float64, float64) {
+	a := tm.SemiMajor
+	f := 1 / tm.InverseFlattening
+	e2 := f * (2 - f)
+	ep2 := e2 / (1 - e2)
+	k0 := tm.ScaleFactor
+
+	m := meridianArc(a, e2, tm.LatitudeOfOrigin*math.Pi/180) + (y-tm.FalseNorthing)/k0
+	mu := m / (a * (1 - e2/4 - 3*e2*e2/64 - 5*e2*e2*e2/256))
+	e1 := (1 - math.Sqrt(1-e2)) / (1 + math.Sqrt(1-e2))
+	phi1 := mu +
+		(3*e1/2-27*math.Pow(e1, 3)/32)*math.Sin(2*mu) +
+		(21*e1*e1/16-55*math.Pow(e1, 4)/32)*math.Sin(4*mu) +
+		(151*math.Pow(e1, 3)/96)*math.Sin(6*mu) +
+		(1097*math.Pow(e1, 4)/512)*math.Sin(8*mu)
+
+	sin1, cos1, tan1 := math.Sin(phi1), math.Cos(phi1), math.Tan(phi1)
+	c1 := ep2 * cos1 * cos1
+	t1 := tan1 * tan1
+	n1 := a / math.Sqrt(1-e2*sin1*sin1)
+	r1 := a * (1 - e2) / math.Pow(1-e2*sin1*sin1, 1.5)
+	d := (x - tm.FalseEasting) / (n1 * k0)
+
+	lat := phi1 - (n1*tan1/r1)*(d*d/2-
+		(5+3*t1+10*c1-4*c1*c1-9*ep2)*math.Pow(d, 4)/24+
+		(61+90*t1+298*c1+45*t1*t1-252*ep2-3*c1*c1)*math.Pow(d, 6)/720)
+	lon := (d - (1+2*t1+c1)*math.Pow(d, 3)/6 +
+		(5-2*c1+28*t1-3*c1*c1+8*ep2+24*t1*t1)*math.Pow(d, 5)/120) / cos1
+
+	return tm.CentralMeridian + lon*180/math.Pi, lat * 180 / math.Pi
+}
+
+// projectionForEPSG returns the projection of an EPSG code: WGS84 (4326) and
+// ETRS89 (4258) geographic coordinates, WGS84 UTM zones (326xx north, 327xx
+// south) and ETRS89 UTM zones (258xx)
+func projectionForEPSG(code int) (projection, bool) {
+	switch {
+	case code == 4326 || code == 4258:
+		return geographic, true
+	case code >= 32601 && code <= 32660:
+		return utm(code-32600, false).inverse, true
+	case code >= 32701 && code <= 32760:
+		return utm(code-32700, true).inverse, true
+	case code >= 25828 && code <= 25838:
+		return utm(code-25800, false).inverse, true
+	}
+	return nil, false
+}
+
+var (
+	wktAuthority = regexp.MustCompile(`AUTHORITY\["EPSG",\s*"?(\d+)"?\]\]*\s*$`)
+	wktDatum     = regexp.MustCompile(`DATUM\["([^"]*)"`)
+	wktSpheroid  = regexp.MustCompile(`SPHEROID\["[^"]*",\s*([0-9.eE+-]+),\s*([0-9.eE+-]+)`)
+	wktProj      = regexp.MustCompile(`PROJECTION\["([^"]*)"`)
+	wktParameter = regexp.MustCompile(`PARAMETER\["([^"]*)",\s*([0-9.eE+-]+)\]`)
+	wktUnit      = regexp.MustCompile(`UNIT\["[^"]*",\s*([0-9.eE+-]+)`)
+)
+
+// supportedDatum reports whether a datum name is WGS84 or ETRS89, whose
+// coordinates agree to within a meter or so; others would need a datum shift
+func supportedDatum(name string) bool {
+	normalized := strings.ToUpper(strings.NewReplacer("_", "", " ", "", "-", "").Replace(name))
+	for _, known := range []string{"WGS1984", "WGS84", "ETRS1989", "ETRS89", "EUROPEANTERRESTRIALREFERENCESYSTEM1989"} {
+		if strings.Contains(normalized, known) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseProjection reads the coordinate system of an OGC or ESRI WKT
+// definition, such as a shapefile .prj. Geographic WGS84/ETRS89 coordinates
+// and Transverse Mercator grids on those datums (UTM among them) are
+// supported.
+func parseProjection(wkt string) (projection, error) {
+	wkt = strings.TrimSpace(wkt)
+	if match := wktAuthority.FindStringSubmatch(wkt); match != nil {
+		code, _ := strconv.Atoi(match[1])
+		if proj, ok := projectionForEPSG(code); ok {
+			return proj, nil
+		}
+	}
+
+	datum := ""
+	if match := wktDatum.FindStringSubmatch(wkt); match != nil {
+		datum = match[1]
+	}
+	if !supportedDatum(datum) {
+		return nil, fmt.Errorf("datum %q is not supported, reproject the layer to WGS84 or ETRS89 first", datum)
+	}
+
+	upper := strings.ToUpper(wkt)
+	if strings.HasPrefix(upper, "GEOGCS") || strings.HasPrefix(upper, "GEOGCRS") {
+		return geographic, nil
+	}
+	if !strings.HasPrefix(upper, "PROJCS") {
+		return nil, fmt.Errorf("unrecognized coordinate system definition")
+	}
+
+	method := ""
+	if match := wktProj.FindStringSubmatch(wkt); match != nil {
+		method = match[1]
+	}
+	if !strings.EqualFold(method, "Transverse_Mercator") {
+		return nil, fmt.Errorf("projection %q is not supported, only Transverse Mercator grids such as UTM are", method)
+	}
+
+	tm := transverseMercator{SemiMajor: 6378137, InverseFlattening: 298.257223563, ScaleFactor: 1}
+	if match := wktSpheroid.FindStringSubmatch(wkt); match != nil {
+		tm.SemiMajor, _ = strconv.ParseFloat(match[1], 64)
+		tm.InverseFlattening, _ = strconv.ParseFloat(match[2], 64)
+	}
+	for _, match := range wktParameter.FindAllStringSubmatch(wkt, -1) {
+		value, err := strconv.ParseFloat(match[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid parameter %s: %w", match[1], err)
+		}
+		switch strings.ToLower(match[1]) {
+		case "central_meridian", "longitude_of_center":
+			tm.CentralMeridian = value
+		case "latitude_of_origin", "latitude_of_center":
+			tm.LatitudeOfOrigin = value
+		case "scale_factor":
+			tm.ScaleFactor = value
+		case "false_easting":
+			tm.FalseEasting = value
+		case "false_northing":
+			tm.FalseNorthing = value
+		}
+	}
+	// The last unit is the grid's, the first ones belong to the geographic
+	// coordinate system it is based on
+	if units := wktUnit.FindAllStringSubmatch(wkt, -1); len(units) > 0 {
+		match := units[len(units)-1]
+		if meters, _ := strconv.ParseFloat(match[1], 64); meters != 1 {
+			return nil, fmt.Errorf("linear unit of %s meters is not supported, only meters are", match[1])
+		}
+	}
+
+	return tm.inverse, nil
+}
@@ -0,0 +1,163 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+	"vessel-tracker/database"
+	"vessel-tracker/logging"
+	"vessel-tracker/models"
+
+	"gorm.io/gorm"
+)
+
+// ProviderAudit keeps the raw responses the vessel data provider sends during
+// fetch runs. Responses are deleted after RETENTION_PROVIDER_RESPONSES_DAYS
+// by the retention job.
+type ProviderAudit struct {
+	db     *gorm.DB
+	logger *slog.Logger
+}
+
+func NewProviderAudit() *ProviderAudit {
+	return &ProviderAudit{
+		db:     database.GetDB(),
+		logger: logging.Component("provider_audit"),
+	}
+}
+
+// LoadProviderAuditMode reads PROVIDER_AUDIT, whether the raw responses of
+// the provider are recorded
+func LoadProviderAuditMode() (bool, error) {
+	value := os.Getenv("PROVIDER_AUDIT")
+	if value == "" {
+		return false, nil
+	}
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid PROVIDER_AUDIT %q: %w", value, err)
+	}
+	return enabled, nil
+}
+
+// EnableProviderAudit records the raw responses of the provider. Only the
+// Datalastic provider talks HTTP, so other providers cannot be audited.
+func EnableProviderAudit(provider VesselDataProvider, audit *ProviderAudit) error {
+	datalastic, ok := provider.(*DatalasticProvider)
+	if !ok {
+		return fmt.Errorf("PROVIDER_AUDIT requires VESSEL_PROVIDER=datalastic")
+	}
+	datalastic.client.Transport = audit.Transport(datalastic.client.Transport)
+	return nil
+}
+
+// Transport wraps an HTTP transport so the responses to requests made within
+// a fetch run are recorded
+func (a *ProviderAudit) Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return auditTransport{audit: a, next: next}
+}
+
+type auditTransport struct {
+	audit *ProviderAudit
+	next  http.RoundTripper
+}
+
+func (t auditTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	runID := FetchRunID(req.Context())
+	if err != nil || runID == "" {
+		return resp, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	// A failed recording must not fail the fetch
+	if err := t.audit.record(runID, req, resp.StatusCode, body); err != nil {
+		t.audit.logger.Error("Failed to record provider response", "fetch_run_id", runID, "path", req.URL.Path, "error", err)
+	}
+	return resp, nil
+}
+
+func (a *ProviderAudit) record(runID string, req *http.Request, status int, body []byte) error {
+	query := req.URL.Query()
+	query.Del("api-key")
+	endpoint := req.URL.Path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	if _, err := writer.Write(body); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	return a.db.Create(&models.ProviderResponse{
+		FetchRunID: runID,
+		Provider:   ProviderDatalastic,
+		Endpoint:   endpoint,
+		StatusCode: status,
+		Size:       len(body),
+		Payload:    compressed.Bytes(),
+		RecordedAt: time.Now(),
+	}).Error
+}
+
+// GetResponses lists the recorded responses of a fetch run, or of every run
+// when runID is empty, newest first and without their payloads
+func (a *ProviderAudit) GetResponses(runID string, limit int) ([]models.ProviderResponse, error) {
+	query := a.db.Omit("payload").Order("recorded_at DESC, id DESC").Limit(limit)
+	if runID != "" {
+		query = query.Where("fetch_run_id = ?", runID)
+	}
+
+	var responses []models.ProviderResponse
+	err := query.Find(&responses).Error
+	return responses, err
+}
+
+// GetPayload returns a recorded response with its payload decompressed
+func (a *ProviderAudit) GetPayload(id uint) (*models.ProviderResponse, []byte, error) {
+	var response models.ProviderResponse
+	if err := a.db.First(&response, id).Error; err != nil {
+		return nil, nil, err
+	}
+
+	payload, err := decompressPayload(response.Payload)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	response.Payload = nil
+	return &response, payload, nil
+}
+
+// decompressPayload returns the body of a recorded response
+func decompressPayload(compressed []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress payload: %w", err)
+	}
+	payload, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress payload: %w", err)
+	}
+	return payload, nil
+}
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+	"vessel-tracker/logging"
+)
+
+// rateLimitSweepInterval is how often buckets of clients that have gone quiet
+// are dropped
+const rateLimitSweepInterval = time.Minute
+
+// RateLimitConfig sets the request rate clients of the API are held to. Each
+// client gets a token bucket that refills at its rate and holds up to its
+// burst, so short bursts are served while the sustained rate is capped.
+type RateLimitConfig struct {
+	Enabled      bool
+	PerMinute    float64 // requests per minute per client IP without credentials, see TRUSTED_PROXIES
+	Burst        int
+	KeyPerMinute float64 // requests per minute per API key, token or session
+	KeyBurst     int
+}
+
+func DefaultRateLimitConfig() RateLimitConfig {
+	return RateLimitConfig{
+		Enabled:      true,
+		PerMinute:    120,
+		Burst:        60,
+		KeyPerMinute: 600,
+		KeyBurst:     120,
+	}
+}
+
+// LoadRateLimitConfig reads RATE_LIMIT_ENABLED, RATE_LIMIT_PER_MINUTE,
+// RATE_LIMIT_BURST, RATE_LIMIT_KEY_PER_MINUTE and RATE_LIMIT_KEY_BURST,
+// falling back to the defaults when unset
+func LoadRateLimitConfig() (RateLimitConfig, error) {
+	config := DefaultRateLimitConfig()
+
+	if value := os.Getenv("RATE_LIMIT_ENABLED"); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return config, fmt.Errorf("invalid RATE_LIMIT_ENABLED %q: must be true or false", value)
+		}
+		config.Enabled = enabled
+	}
+
+	rateVars := map[string]*float64{
+		"RATE_LIMIT_PER_MINUTE":     &config.PerMinute,
+		"RATE_LIMIT_KEY_PER_MINUTE": &config.KeyPerMinute,
+	}
+	for name, target := range rateVars {
+		if value := os.Getenv(name); value != "" {
+			rate, err := strconv.ParseFloat(value, 64)
+			if err != nil || rate <= 0 {
+				return config, fmt.Errorf("invalid %s %q: must be a positive number", name, value)
+			}
+			*target = rate
+		}
+	}
+
+	burstVars := map[string]*int{
+		"RATE_LIMIT_BURST":     &config.Burst,
+		"RATE_LIMIT_KEY_BURST": &config.KeyBurst,
+	}
+	for name, target := range burstVars {
+		if value := os.Getenv(name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return config, fmt.Errorf("invalid %s %q: must be a positive integer", name, value)
+			}
+			*target = n
+		}
+	}
+
+	return config, nil
+}
+
+// RateLimitResult is the outcome of taking a request from a client's bucket
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int           // the burst of the client's bucket
+	Remaining  int           // whole requests left in the bucket
+	RetryAfter time.Duration // until the next request is allowed, when refused
+}
+
+// tokenBucket holds the requests a client may still make
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+	limited bool // the last request was refused, so a run of refusals is logged once
+}
+
+// RateLimiter keeps a token bucket per client: per API key, token or session
+// for requests with credentials, per client IP for anonymous ones
+type RateLimiter struct {
+	config RateLimitConfig
+	logger *slog.Logger
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+func NewRateLimiter(config RateLimitConfig) *RateLimiter {
+	return &RateLimiter{
+		config:    config,
+		logger:    logging.Component("rate_limit"),
+		buckets:   make(map[string]*tokenBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Config returns the rate limit settings
+func (l *RateLimiter) Config() RateLimitConfig {
+	return l.config
+}
+
+// Allow takes a request from the bucket of a client. keyed clients made the
+// request with credentials and get the key rate and burst.
+func (l *RateLimiter) Allow(client string, keyed bool) RateLimitResult {
+	perMinute, burst := l.config.PerMinute, l.config.Burst
+	if keyed {
+		perMinute, burst = l.config.KeyPerMinute, l.config.KeyBurst
+	}
+	perSecond := perMinute / 60
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) >= rateLimitSweepInterval {
+		l.sweep(now)
+	}
+
+	bucket, ok := l.buckets[client]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(burst), updated: now}
+		l.buckets[client] = bucket
+	} else {
+		bucket.tokens = math.Min(float64(burst), bucket.tokens+now.Sub(bucket.updated).Seconds()*perSecond)
+		bucket.updated = now
+	}
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		bucket.limited = false
+		return RateLimitResult{
+			Allowed:   true,
+			Limit:     burst,
+			Remaining: int(bucket.tokens),
+		}
+	}
+
+	if !bucket.limited {
+		bucket.limited = true
+		l.logger.Warn("Client exceeded the rate limit", "client", client, "per_minute", perMinute, "burst", burst)
+	}
+
+	wait := time.Duration((1 - bucket.tokens) / perSecond * float64(time.Second))
+	return RateLimitResult{
+		Limit:      burst,
+		RetryAfter: wait,
+	}
+}
+
+// sweep drops the buckets that have refilled completely, which behave the
+// same as a new bucket. The caller holds mu.
+func (l *RateLimiter) sweep(now time.Time) {
+	// The slower rate bounds how long any bucket takes to refill
+	refill := time.Duration(float64(max(l.config.Burst, l.config.KeyBurst)) / (math.Min(l.config.PerMinute, l.config.KeyPerMinute) / 60) * float64(time.Second))
+	for client, bucket := range l.buckets {
+		if now.Sub(bucket.updated) > refill {
+			delete(l.buckets, client)
+		}
+	}
+	l.lastSweep = now
+}
@@ -0,0 +1,244 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+	"vessel-tracker/database"
+	"vessel-tracker/logging"
+	"vessel-tracker/models"
+
+	"gorm.io/gorm"
+)
+
+// ReplayResult summarizes a replay of recorded provider responses
+type ReplayResult struct {
+	Since     time.Time `json:"since"`
+	Runs      int       `json:"runs"`
+	Responses int       `json:"responses"`
+	Skipped   int       `json:"skipped"` // failed requests and responses matching no park
+
+	Deleted    map[string]int64 `json:"deleted"`
+	Reopened   int64            `json:"reopened_anchoring_events"`
+	Stored     int              `json:"stored"`
+	Duplicates int              `json:"duplicate_skipped"`
+	ZoneEvents int              `json:"zone_events"`
+	Arrivals   int              `json:"arrivals"`
+}
+
+// Replayer re-runs ingestion over the provider responses recorded with
+// PROVIDER_AUDIT, rebuilding the positions and the records derived from them
+// after a classification fix or a change to the boundaries. Positions are
+// stored at the time their response was recorded, so replaying the same
+// responses gives the same result.
+//
+// Violations are not rebuilt: they carry their own evidence and may have
+// appeals and sanctions attached.
+type Replayer struct {
+	db         *gorm.DB
+	parks      *ParkRegistry
+	vesselRepo *VesselRepository
+	zoneEvents *ZoneEventService
+	arrivals   *ArrivalService
+	anchoring  *AnchoringDetector
+	logger     *slog.Logger
+}
+
+func NewReplayer(parks *ParkRegistry, vesselRepo *VesselRepository, zoneEvents *ZoneEventService, arrivals *ArrivalService, anchoring *AnchoringDetector) *Replayer {
+	return &Replayer{
+		db:         database.GetDB(),
+		parks:      parks,
+		vesselRepo: vesselRepo,
+		zoneEvents: zoneEvents,
+		arrivals:   arrivals,
+		anchoring:  anchoring,
+		logger:     logging.Component("replay"),
+	}
+}
+
+// Responses lists the recorded responses since the given time in the order
+// they were received, without their payloads
+func (r *Replayer) Responses(since time.Time) ([]models.ProviderResponse, error) {
+	var responses []models.ProviderResponse
+	err := r.db.Omit("payload").
+		Where("recorded_at >= ?", since).
+		Order("recorded_at ASC, id ASC").
+		Find(&responses).Error
+	return responses, err
+}
+
+// DerivedRows counts the rows a replay since the given time deletes, by table
+func (r *Replayer) DerivedRows(since time.Time) (map[string]int64, error) {
+	counts := make(map[string]int64)
+	for _, table := range replayedTables {
+		var count int64
+		if err := table.scope(r.db.Table(table.name), since).Count(&count).Error; err != nil {
+			return nil, fmt.Errorf("failed to count %s: %w", table.name, err)
+		}
+		counts[table.name] = count
+	}
+	return counts, nil
+}
+
+// replayedTables are the tables rebuilt by a replay, with the rows of each
+// that a replay since a given time replaces
+var replayedTables = []struct {
+	name  string
+	scope func(db *gorm.DB, since time.Time) *gorm.DB
+}{
+	{"vessel_position_records", func(db *gorm.DB, since time.Time) *gorm.DB {
+		return db.Where("recorded_at >= ?", since)
+	}},
+	{"zone_events", func(db *gorm.DB, since time.Time) *gorm.DB {
+		return db.Where("occurred_at >= ?", since)
+	}},
+	{"park_arrivals", func(db *gorm.DB, since time.Time) *gorm.DB {
+		return db.Where("first_seen_at >= ? AND seeded = ?", since, false)
+	}},
+	{"anchoring_events", func(db *gorm.DB, since time.Time) *gorm.DB {
+		return db.Where("started_at >= ?", since)
+	}},
+}
+
+// Replay deletes the positions, zone events, first arrivals and anchoring
+// events recorded since the given time and rebuilds them from the provider
+// responses recorded since then. Anchoring events that began earlier and
+// ended since are reopened, to be closed again by the replay.
+//
+// Positions stored since that time without a recorded response, e.g. while
+// PROVIDER_AUDIT was off or from the AIS receiver, are lost. Nothing else may
+// ingest positions while a replay runs. A replay that fails part way can be
+// run again.
+func (r *Replayer) Replay(ctx context.Context, since time.Time) (*ReplayResult, error) {
+	responses, err := r.Responses(since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load provider responses: %w", err)
+	}
+	if len(responses) == 0 {
+		return nil, fmt.Errorf("no provider responses recorded since %s", since.Format(time.RFC3339))
+	}
+
+	result := &ReplayResult{Since: since, Deleted: make(map[string]int64)}
+
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, table := range replayedTables {
+			deleted := table.scope(tx.Table(table.name), since).Delete(map[string]interface{}{})
+			if deleted.Error != nil {
+				return fmt.Errorf("failed to delete %s: %w", table.name, deleted.Error)
+			}
+			result.Deleted[table.name] = deleted.RowsAffected
+		}
+
+		reopened := tx.Model(&models.AnchoringEvent{}).
+			Where("started_at < ? AND ended_at >= ?", since, since).
+			Update("ended_at", nil)
+		if reopened.Error != nil {
+			return fmt.Errorf("failed to reopen anchoring events: %w", reopened.Error)
+		}
+		result.Reopened = reopened.RowsAffected
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	lastRun := ""
+	for _, response := range responses {
+		if err := ctx.Err(); err != nil {
+			return result, fmt.Errorf("replay abandoned: %w", err)
+		}
+		if response.FetchRunID != lastRun {
+			lastRun = response.FetchRunID
+			result.Runs++
+		}
+		result.Responses++
+
+		park, ok := r.responsePark(response)
+		if !ok {
+			result.Skipped++
+			continue
+		}
+
+		if err := r.replayResponse(ctx, park, response, result); err != nil {
+			return result, fmt.Errorf("response %d of run %s: %w", response.ID, response.FetchRunID, err)
+		}
+	}
+
+	return result, nil
+}
+
+// responsePark returns the park whose center a successful in-radius request
+// was made around
+func (r *Replayer) responsePark(response models.ProviderResponse) (*Park, bool) {
+	if response.StatusCode != 200 {
+		return nil, false
+	}
+
+	endpoint, err := url.Parse(response.Endpoint)
+	if err != nil || !strings.HasSuffix(endpoint.Path, "/vessel_inradius") {
+		return nil, false
+	}
+	if park, ok := r.parks.ForProviderEndpoint(response.Endpoint); ok {
+		return park, true
+	}
+
+	r.logger.Warn("Recorded response matches no park center", "response_id", response.ID, "endpoint", response.Endpoint)
+	return nil, false
+}
+
+// replayResponse runs the positions of one recorded response through storage,
+// zone transitions, first arrivals and anchoring analysis, as of the time the
+// response was recorded
+func (r *Replayer) replayResponse(ctx context.Context, park *Park, response models.ProviderResponse, result *ReplayResult) error {
+	var stored models.ProviderResponse
+	if err := r.db.WithContext(ctx).First(&stored, response.ID).Error; err != nil {
+		return fmt.Errorf("failed to load payload: %w", err)
+	}
+	payload, err := decompressPayload(stored.Payload)
+	if err != nil {
+		return err
+	}
+
+	var vesselResp models.VesselPositionResponse
+	if err := json.Unmarshal(payload, &vesselResp); err != nil {
+		return fmt.Errorf("failed to decode payload: %w", err)
+	}
+	positions := vesselResp.Data.Vessels
+	if len(positions) == 0 {
+		return nil
+	}
+
+	at := response.RecordedAt
+	zones := park.Geo.ClassifyPositions(positions)
+
+	storeResult, err := r.vesselRepo.storeVesselData(ctx, park.Record.ID, positions, zones, at)
+	if err != nil {
+		return fmt.Errorf("failed to store vessel data: %w", err)
+	}
+	result.Stored += storeResult.Stored
+	result.Duplicates += storeResult.DuplicatesSkipped
+
+	transitions, err := r.zoneEvents.RecordTransitions(ctx, park, positions, zones, storeResult)
+	if err != nil {
+		return err
+	}
+	result.ZoneEvents += transitions
+
+	arrived, err := r.arrivals.recordArrivals(park, positions, zones, at)
+	if err != nil {
+		return err
+	}
+	result.Arrivals += arrived
+
+	vesselUUIDs := make([]string, 0, len(positions))
+	for _, vessel := range positions {
+		vesselUUIDs = append(vesselUUIDs, vessel.UUID)
+	}
+	r.anchoring.analyzeVessels(ctx, park.Record.ID, vesselUUIDs, at)
+
+	return nil
+}
@@ -0,0 +1,298 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"vessel-tracker/database"
+	"vessel-tracker/models"
+
+	"gorm.io/gorm"
+)
+
+// reportTopOffenders is how many vessels the top offenders list holds
+const reportTopOffenders = 10
+
+type ReportService struct {
+	db *gorm.DB
+}
+
+func NewReportService() *ReportService {
+	return &ReportService{
+		db: database.GetDB(),
+	}
+}
+
+// ReportRange returns the start and end of the report period ending at end
+func ReportRange(period string, end time.Time) (time.Time, time.Time, error) {
+	switch period {
+	case models.ReportPeriodDaily:
+		return end.AddDate(0, 0, -1), end, nil
+	case models.ReportPeriodWeekly:
+		return end.AddDate(0, 0, -7), end, nil
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("period must be %s or %s", models.ReportPeriodDaily, models.ReportPeriodWeekly)
+}
+
+// GenerateViolationReport compiles the violations, vessel traffic and top
+// offenders of a park in the period ending at end
+func (s *ReportService) GenerateViolationReport(park *Park, period string, end time.Time) (*models.ViolationReport, error) {
+	start, end, err := ReportRange(period, end)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.ViolationReport{
+		Park:        park.Record,
+		Period:      period,
+		Start:       start,
+		End:         end,
+		GeneratedAt: time.Now(),
+		Summary: models.ViolationTotals{
+			ByType:     make(map[string]int64),
+			BySeverity: make(map[string]int64),
+			ByStatus:   make(map[string]int64),
+		},
+	}
+
+	err = s.db.Where("park_id = ? AND detected_at >= ? AND detected_at < ?", park.Record.ID, start, end).
+		Order("detected_at").
+		Find(&report.Violations).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load violations: %w", err)
+	}
+
+	offenders := make(map[string]*models.ReportOffender)
+	for _, violation := range report.Violations {
+		report.Summary.Total++
+		report.Summary.ByType[violation.Type]++
+		report.Summary.BySeverity[violation.Severity]++
+		report.Summary.ByStatus[violation.Status]++
+
+		offender, ok := offenders[violation.VesselUUID]
+		if !ok {
+			offender = &models.ReportOffender{VesselUUID: violation.VesselUUID}
+			offenders[violation.VesselUUID] = offender
+		}
+		// Keep the most recent name and MMSI the vessel reported
+		offender.VesselName = violation.VesselName
+		offender.MMSI = violation.MMSI
+		offender.Violations++
+	}
+
+	report.TopOffenders = make([]models.ReportOffender, 0, len(offenders))
+	for _, offender := range offenders {
+		report.TopOffenders = append(report.TopOffenders, *offender)
+	}
+	sort.Slice(report.TopOffenders, func(i, j int) bool {
+		a, b := report.TopOffenders[i], report.TopOffenders[j]
+		if a.Violations != b.Violations {
+			return a.Violations > b.Violations
+		}
+		return a.VesselUUID < b.VesselUUID
+	})
+	if len(report.TopOffenders) > reportTopOffenders {
+		report.TopOffenders = report.TopOffenders[:reportTopOffenders]
+	}
+
+	report.Traffic, err = s.trafficCounts(park.Record.ID, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// trafficCounts counts the positions and distinct vessels recorded in a park
+// in a period, overall and per UTC day
+func (s *ReportService) trafficCounts(parkID uint, start, end time.Time) (models.TrafficCounts, error) {
+	var counts models.TrafficCounts
+
+	rows, err := s.db.Model(&models.VesselPositionRecord{}).
+		Select("vessel_uuid, is_in_park, recorded_at").
+		Where("park_id = ? AND recorded_at >= ? AND recorded_at < ?", parkID, start, end).
+		Rows()
+	if err != nil {
+		return counts, fmt.Errorf("failed to load positions: %w", err)
+	}
+	defer rows.Close()
+
+	vessels := make(map[string]bool)
+	daily := make(map[string]map[string]bool)
+
+	for rows.Next() {
+		var sample struct {
+			VesselUUID string
+			IsInPark   bool
+			RecordedAt time.Time
+		}
+		if err := s.db.ScanRows(rows, &sample); err != nil {
+			return counts, fmt.Errorf("failed to read positions: %w", err)
+		}
+
+		counts.Positions++
+		vessels[sample.VesselUUID] = vessels[sample.VesselUUID] || sample.IsInPark
+
+		day := sample.RecordedAt.UTC().Format("2006-01-02")
+		if daily[day] == nil {
+			daily[day] = make(map[string]bool)
+		}
+		daily[day][sample.VesselUUID] = daily[day][sample.VesselUUID] || sample.IsInPark
+	}
+	if err := rows.Err(); err != nil {
+		return counts, fmt.Errorf("failed to read positions: %w", err)
+	}
+
+	counts.Vessels, counts.VesselsInPark = countVessels(vessels)
+
+	counts.Daily = make([]models.DailyTraffic, 0, len(daily))
+	for day, dayVessels := range daily {
+		traffic := models.DailyTraffic{Date: day}
+		traffic.Vessels, traffic.VesselsInPark = countVessels(dayVessels)
+		counts.Daily = append(counts.Daily, traffic)
+	}
+	sort.Slice(counts.Daily, func(i, j int) bool {
+		return counts.Daily[i].Date < counts.Daily[j].Date
+	})
+
+	return counts, nil
+}
+
+// countVessels counts the vessels of a set and those that were in the park
+func countVessels(inPark map[string]bool) (int, int) {
+	parked := 0
+	for _, in := range inPark {
+		if in {
+			parked++
+		}
+	}
+	return len(inPark), parked
+}
+
+// RenderReportCSV writes one row per violation of the report
+func RenderReportCSV(report *models.ViolationReport) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	w.Write([]string{"id", "detected_at", "type", "severity", "status", "duration_minutes", "vessel_uuid", "vessel_name", "mmsi", "imo", "latitude", "longitude", "speed", "speed_through_water", "current_speed_knots", "current_direction_deg", "details"})
+	for _, v := range report.Violations {
+		// The current is left empty where no predictions covered the
+		// position, the duration while the violation is open
+		var speedThroughWater, currentSpeed, currentDirection, duration string
+		if current := v.Current(); current != nil {
+			currentSpeed = strconv.FormatFloat(current.SpeedKnots, 'f', 2, 64)
+			currentDirection = strconv.FormatFloat(current.DirectionDeg, 'f', 1, 64)
+		}
+		if v.SpeedThroughWater != nil {
+			speedThroughWater = strconv.FormatFloat(*v.SpeedThroughWater, 'f', 2, 64)
+		}
+		if v.DurationMinutes != nil {
+			duration = strconv.FormatFloat(*v.DurationMinutes, 'f', 1, 64)
+		}
+
+		w.Write([]string{
+			strconv.FormatUint(uint64(v.ID), 10),
+			v.DetectedAt.UTC().Format(time.RFC3339),
+			v.Type,
+			v.Severity,
+			v.Status,
+			duration,
+			v.VesselUUID,
+			v.VesselName,
+			v.MMSI,
+			v.IMO,
+			strconv.FormatFloat(v.Latitude, 'f', 6, 64),
+			strconv.FormatFloat(v.Longitude, 'f', 6, 64),
+			strconv.FormatFloat(v.Speed, 'f', 2, 64),
+			speedThroughWater,
+			currentSpeed,
+			currentDirection,
+			v.Details,
+		})
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("failed to write report: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// RenderReportPDF lays the report out as a printable document
+func RenderReportPDF(report *models.ViolationReport) []byte {
+	title := fmt.Sprintf("Violation report (%s)", report.Period)
+	return RenderTextPDF(title, renderReportText(title, report))
+}
+
+func renderReportText(title string, report *models.ViolationReport) string {
+	const timeFormat = "2006-01-02 15:04 UTC"
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%s\n", strings.ToUpper(title))
+	fmt.Fprintf(&b, "Park: %s\n", report.Park.Name)
+	fmt.Fprintf(&b, "Period: %s to %s\n", report.Start.UTC().Format(timeFormat), report.End.UTC().Format(timeFormat))
+	fmt.Fprintf(&b, "Generated: %s\n\n", report.GeneratedAt.UTC().Format(timeFormat))
+
+	fmt.Fprintf(&b, "VIOLATIONS\n")
+	fmt.Fprintf(&b, "Total: %d\n", report.Summary.Total)
+	writeCounts(&b, "By type", report.Summary.ByType)
+	writeCounts(&b, "By severity", report.Summary.BySeverity)
+	writeCounts(&b, "By status", report.Summary.ByStatus)
+	b.WriteString("\n")
+
+	fmt.Fprintf(&b, "VESSEL TRAFFIC\n")
+	fmt.Fprintf(&b, "Vessels seen: %d (%d inside the park)\n", report.Traffic.Vessels, report.Traffic.VesselsInPark)
+	fmt.Fprintf(&b, "Positions recorded: %d\n", report.Traffic.Positions)
+	for _, day := range report.Traffic.Daily {
+		fmt.Fprintf(&b, "  %s: %d vessels, %d inside the park\n", day.Date, day.Vessels, day.VesselsInPark)
+	}
+	b.WriteString("\n")
+
+	fmt.Fprintf(&b, "TOP OFFENDERS\n")
+	if len(report.TopOffenders) == 0 {
+		b.WriteString("None\n")
+	}
+	for i, offender := range report.TopOffenders {
+		fmt.Fprintf(&b, "%2d. %s (MMSI %s): %d violations\n", i+1, offender.VesselName, offender.MMSI, offender.Violations)
+	}
+	b.WriteString("\n")
+
+	fmt.Fprintf(&b, "VIOLATION LIST\n")
+	if len(report.Violations) == 0 {
+		b.WriteString("None\n")
+	}
+	for _, v := range report.Violations {
+		fmt.Fprintf(&b, "#%d %s %s [%s, %s] %s (MMSI %s) at %.5f, %.5f\n",
+			v.ID, v.DetectedAt.UTC().Format(timeFormat), v.Type, v.Severity, v.Status, v.VesselName, v.MMSI, v.Latitude, v.Longitude)
+		if current := v.Current(); current != nil && v.SpeedThroughWater != nil {
+			fmt.Fprintf(&b, "    %.1f kn over ground, %.1f kn through the water, tidal current %.1f kn toward %03.0f deg\n", v.Speed, *v.SpeedThroughWater, current.SpeedKnots, current.DirectionDeg)
+		} else if current != nil {
+			fmt.Fprintf(&b, "    %.1f kn over ground, tidal current %.1f kn toward %03.0f deg\n", v.Speed, current.SpeedKnots, current.DirectionDeg)
+		}
+	}
+
+	return b.String()
+}
+
+// writeCounts writes a map of counts as one sorted line
+func writeCounts(b *strings.Builder, label string, counts map[string]int64) {
+	if len(counts) == 0 {
+		return
+	}
+
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = fmt.Sprintf("%s %d", key, counts[key])
+	}
+	fmt.Fprintf(b, "%s: %s\n", label, strings.Join(parts, ", "))
+}
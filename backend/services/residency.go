@@ -0,0 +1,68 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+)
+
+// regionPattern matches region names such as "eu-central-1"
+var regionPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// LoadDeploymentRegion reads DEPLOYMENT_REGION, the region this deployment
+// and its database run in, e.g. "eu-central-1". It is empty when unset.
+func LoadDeploymentRegion() (string, error) {
+	region := os.Getenv("DEPLOYMENT_REGION")
+	if region != "" && !regionPattern.MatchString(region) {
+		return "", fmt.Errorf("invalid DEPLOYMENT_REGION %q: must be lowercase letters, digits and dashes, e.g. eu-central-1", region)
+	}
+	return region, nil
+}
+
+// ArchiveTarget is a park's own storage for its retention archives, in place
+// of the deployment's ARCHIVE_DIR or ARCHIVE_S3_BUCKET. S3 targets use the
+// deployment's AWS_* credentials.
+type ArchiveTarget struct {
+	Dir        string `json:"dir,omitempty"`
+	S3Bucket   string `json:"s3_bucket,omitempty"`
+	S3Region   string `json:"s3_region,omitempty"`
+	S3Endpoint string `json:"s3_endpoint,omitempty"`
+	S3Prefix   string `json:"s3_prefix,omitempty"`
+}
+
+// archiver builds the archiver that stores in the target, signing S3
+// uploads with the credentials. A directory is in the deployment's region.
+func (t ArchiveTarget) archiver(credentials S3Credentials, deploymentRegion string) (Archiver, error) {
+	switch {
+	case t.Dir != "" && t.S3Bucket != "":
+		return nil, fmt.Errorf("archive dir and s3_bucket are mutually exclusive")
+	case t.Dir != "":
+		return &LocalArchiver{Dir: t.Dir, Region: deploymentRegion}, nil
+	case t.S3Bucket != "":
+		archiver, err := newS3Archiver(t.S3Bucket, t.S3Region, t.S3Endpoint, t.S3Prefix, credentials)
+		if err != nil {
+			return nil, err
+		}
+		return archiver, nil
+	}
+	return nil, fmt.Errorf("archive needs a dir or an s3_bucket")
+}
+
+// CheckArchiveResidency verifies that the deployment's archiver stores in the
+// data region of every park that has one. Tables that are not tagged with a
+// park, such as the raw provider responses, always go to the deployment's
+// archiver, so a park's own archive target does not exempt it.
+func CheckArchiveResidency(parks *ParkRegistry, archiver Archiver) error {
+	if archiver == nil {
+		return nil
+	}
+
+	region := archiverRegion(archiver)
+	for _, park := range parks.All() {
+		dataRegion := park.Record.DataRegion
+		if dataRegion != "" && region != dataRegion {
+			return fmt.Errorf("archives are stored in region %q but park %q data must stay in %q", region, park.Record.Slug, dataRegion)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadDeploymentRegion(t *testing.T) {
+	for _, region := range []string{"", "eu-south-1", "westeurope"} {
+		t.Setenv("DEPLOYMENT_REGION", region)
+		if got, err := LoadDeploymentRegion(); err != nil || got != region {
+			t.Fatalf("LoadDeploymentRegion with %q = %q, %v", region, got, err)
+		}
+	}
+	for _, region := range []string{"EU-South-1", "eu south 1", "eu-", "-eu"} {
+		t.Setenv("DEPLOYMENT_REGION", region)
+		if _, err := LoadDeploymentRegion(); err == nil {
+			t.Fatalf("LoadDeploymentRegion accepted %q", region)
+		}
+	}
+}
+
+func TestParksAndArchivesFollowDeploymentRegion(t *testing.T) {
+	configs := []ParkConfig{{Slug: "maddalena", Boundaries: "park.geojson", DataRegion: "eu-south-1"}}
+	_, err := NewParkRegistry(configs, BoundaryConfig{}, S3Credentials{}, "us-east-1")
+	if err == nil || !strings.Contains(err.Error(), "must stay in") {
+		t.Fatalf("NewParkRegistry of a park outside the deployment's region = %v, want a residency error", err)
+	}
+
+	archiver, err := NewArchiver(ArchiveConfig{Target: ArchiveTarget{Dir: t.TempDir()}, DeploymentRegion: "eu-south-1"})
+	if err != nil {
+		t.Fatalf("NewArchiver: %v", err)
+	}
+	if region := archiverRegion(archiver); region != "eu-south-1" {
+		t.Fatalf("local archives stored in %q, want the deployment's region eu-south-1", region)
+	}
+}
@@ -0,0 +1,496 @@
+package services
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"vessel-tracker/database"
+	"vessel-tracker/logging"
+	"vessel-tracker/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// retentionBatchSize is the number of rows read per query while archiving
+const retentionBatchSize = 1000
+
+// Archive rows drop the preloadable vessel association so it is not written
+// as an empty object on every line
+type archivedPosition struct {
+	models.VesselPositionRecord
+	Vessel *models.VesselRecord `gorm:"-" json:"vessel,omitempty"`
+}
+
+func (archivedPosition) TableName() string { return "vessel_position_records" }
+
+type archivedAnchoringEvent struct {
+	models.AnchoringEvent
+	Vessel *models.VesselRecord `gorm:"-" json:"vessel,omitempty"`
+}
+
+func (archivedAnchoringEvent) TableName() string { return "anchoring_events" }
+
+type archivedZoneEvent struct {
+	models.ZoneEvent
+	Vessel *models.VesselRecord `gorm:"-" json:"vessel,omitempty"`
+}
+
+func (archivedZoneEvent) TableName() string { return "zone_events" }
+
+// retainedTable describes a table the retention job prunes: the column that
+// ages its rows, the column tagging them with a park, if any, and how to read
+// them for archiving
+type retainedTable struct {
+	name       string
+	envName    string
+	timeColumn string
+	parkColumn string
+	newRows    func() interface{}
+}
+
+var retainedTables = []retainedTable{
+	{"vessel_position_records", "RETENTION_POSITIONS_DAYS", "recorded_at", "park_id", func() interface{} { return &[]archivedPosition{} }},
+	{"anchoring_events", "RETENTION_ANCHORING_EVENTS_DAYS", "last_seen_at", "park_id", func() interface{} { return &[]archivedAnchoringEvent{} }},
+	{"access_logs", "RETENTION_ACCESS_LOGS_DAYS", "accessed_at", "", func() interface{} { return &[]models.AccessLog{} }},
+	{"security_events", "RETENTION_SECURITY_EVENTS_DAYS", "created_at", "", func() interface{} { return &[]models.SecurityEvent{} }},
+	{"provider_responses", "RETENTION_PROVIDER_RESPONSES_DAYS", "recorded_at", "", func() interface{} { return &[]models.ProviderResponse{} }},
+	{"zone_events", "RETENTION_ZONE_EVENTS_DAYS", "occurred_at", "park_id", func() interface{} { return &[]archivedZoneEvent{} }},
+	{"log_entries", "RETENTION_LOG_ENTRIES_DAYS", "logged_at", "", func() interface{} { return &[]models.LogEntry{} }},
+}
+
+func findRetainedTable(name string) (retainedTable, bool) {
+	for _, table := range retainedTables {
+		if table.name == name {
+			return table, true
+		}
+	}
+	return retainedTable{}, false
+}
+
+// RetentionTables lists the tables the retention job can prune and restore
+func RetentionTables() []string {
+	names := make([]string, len(retainedTables))
+	for i, table := range retainedTables {
+		names[i] = table.name
+	}
+	return names
+}
+
+// RetentionConfig holds how many days rows of each table are kept. Tables
+// with 0 days, or without an entry, are kept forever.
+type RetentionConfig struct {
+	Days map[string]int
+}
+
+// DefaultRetentionConfig keeps vessel positions for the given number of days,
+// raw provider responses and stored log records for a week and everything
+// else forever, matching the behavior before per-table retention existed
+func DefaultRetentionConfig(positionDays int) RetentionConfig {
+	return RetentionConfig{
+		Days: map[string]int{
+			"vessel_position_records": positionDays,
+			"provider_responses":      7,
+			"log_entries":             7,
+		},
+	}
+}
+
+// LoadRetentionConfig reads RETENTION_POSITIONS_DAYS,
+// RETENTION_ANCHORING_EVENTS_DAYS, RETENTION_ACCESS_LOGS_DAYS,
+// RETENTION_SECURITY_EVENTS_DAYS, RETENTION_PROVIDER_RESPONSES_DAYS,
+// RETENTION_ZONE_EVENTS_DAYS and RETENTION_LOG_ENTRIES_DAYS.
+// Positions default to positionDays (SCHEDULER_RETENTION_DAYS), provider
+// responses and log records to 7 days; the other tables are kept forever
+// unless set.
+func LoadRetentionConfig(positionDays int) (RetentionConfig, error) {
+	config := DefaultRetentionConfig(positionDays)
+
+	for _, table := range retainedTables {
+		value := os.Getenv(table.envName)
+		if value == "" {
+			continue
+		}
+		days, err := strconv.Atoi(value)
+		if err != nil || days < 0 {
+			return config, fmt.Errorf("invalid %s %q: must be a non-negative number of days", table.envName, value)
+		}
+		config.Days[table.name] = days
+	}
+
+	return config, nil
+}
+
+// RetentionPolicy is the retention applied to one table
+type RetentionPolicy struct {
+	Table string `json:"table"`
+	Days  int    `json:"days"`
+}
+
+// RetentionResult is the outcome of pruning one table
+type RetentionResult struct {
+	Table    string    `json:"table"`
+	Park     string    `json:"park,omitempty"` // set for the rows of a park archived to its own target
+	Cutoff   time.Time `json:"cutoff"`
+	Archived int64     `json:"archived"`
+	Deleted  int64     `json:"deleted"`
+	Archive  string    `json:"archive,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// RetentionRun is the outcome of one run of the retention job
+type RetentionRun struct {
+	StartedAt  time.Time         `json:"started_at"`
+	FinishedAt time.Time         `json:"finished_at"`
+	Results    []RetentionResult `json:"results"`
+}
+
+// PositionFinalizer finalizes the records derived from a park's positions up
+// to a cutoff, e.g. daily aggregates and violation evidence, before the
+// positions are deleted
+type PositionFinalizer interface {
+	FinalizeBefore(ctx context.Context, cutoff time.Time) error
+}
+
+// finalizedTables are the tables whose rows the finalizer derives records from
+var finalizedTables = []string{"vessel_position_records", "zone_events"}
+
+// RetentionService deletes rows older than each table's retention period.
+// With an archiver configured, the rows are first written to a
+// gzip-compressed NDJSON file, one JSON object per row, and only deleted once
+// the archive is stored; a table whose archive fails keeps its rows until the
+// next run. The rows of a park with its own archive target are archived
+// there, separately from the rest of the table. With a finalizer configured,
+// positions and zone events are only deleted once the records derived from
+// them are finalized; they are kept until the next run otherwise.
+type RetentionService struct {
+	db        *gorm.DB
+	config    RetentionConfig
+	archiver  Archiver
+	parks     *ParkRegistry
+	finalizer PositionFinalizer
+	logger    *slog.Logger
+
+	mu      sync.Mutex
+	lastRun *RetentionRun
+}
+
+func NewRetentionService(config RetentionConfig, archiver Archiver, parks *ParkRegistry, finalizer PositionFinalizer) *RetentionService {
+	return &RetentionService{
+		db:        database.GetDB(),
+		config:    config,
+		archiver:  archiver,
+		parks:     parks,
+		finalizer: finalizer,
+		logger:    logging.Component("retention"),
+	}
+}
+
+// Policies returns the retention of every prunable table
+func (s *RetentionService) Policies() []RetentionPolicy {
+	policies := make([]RetentionPolicy, 0, len(retainedTables))
+	for _, table := range retainedTables {
+		policies = append(policies, RetentionPolicy{Table: table.name, Days: s.config.Days[table.name]})
+	}
+	return policies
+}
+
+// ArchivingEnabled reports whether expired rows are archived before deletion,
+// for the deployment or for any park
+func (s *RetentionService) ArchivingEnabled() bool {
+	if s.archiver != nil {
+		return true
+	}
+	for _, park := range s.parks.All() {
+		if park.Archiver != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// retentionPartition is a share of a table's rows archived to one target
+type retentionPartition struct {
+	park     string // slug of the park with its own archive target, empty for the rest
+	scope    func(db *gorm.DB) *gorm.DB
+	archiver Archiver
+}
+
+// partitions splits a table by archive target: one partition for each park
+// with its own target and one for the remaining rows. Tables not tagged with
+// a park are a single partition archived by the deployment's archiver.
+func (s *RetentionService) partitions(table retainedTable) []retentionPartition {
+	var partitions []retentionPartition
+	var own []uint
+
+	if table.parkColumn != "" {
+		for _, park := range s.parks.All() {
+			if park.Archiver == nil {
+				continue
+			}
+			parkID := park.Record.ID
+			own = append(own, parkID)
+			partitions = append(partitions, retentionPartition{
+				park: park.Record.Slug,
+				scope: func(db *gorm.DB) *gorm.DB {
+					return db.Where(table.parkColumn+" = ?", parkID)
+				},
+				archiver: park.Archiver,
+			})
+		}
+	}
+
+	rest := retentionPartition{
+		scope:    func(db *gorm.DB) *gorm.DB { return db },
+		archiver: s.archiver,
+	}
+	if len(own) > 0 {
+		rest.scope = func(db *gorm.DB) *gorm.DB {
+			return db.Where(table.parkColumn+" NOT IN ?", own)
+		}
+	}
+	return append(partitions, rest)
+}
+
+// LastRun returns the outcome of the most recent run, or nil before the first
+func (s *RetentionService) LastRun() *RetentionRun {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastRun
+}
+
+// Run prunes every table with a retention period. Tables not reached before
+// ctx is done are left for the next run.
+func (s *RetentionService) Run(ctx context.Context) RetentionRun {
+	run := RetentionRun{StartedAt: time.Now()}
+	finalizeErr := s.finalize(ctx, run.StartedAt)
+
+	for _, table := range retainedTables {
+		days := s.config.Days[table.name]
+		if days <= 0 || ctx.Err() != nil {
+			continue
+		}
+
+		if finalizeErr != nil && isFinalizedTable(table.name) {
+			result := RetentionResult{
+				Table:  table.name,
+				Cutoff: run.StartedAt.AddDate(0, 0, -days),
+				Error:  "rows kept: derived records not finalized: " + finalizeErr.Error(),
+			}
+			s.logger.Error("Retention skipped", "table", table.name, "error", finalizeErr)
+			run.Results = append(run.Results, result)
+			continue
+		}
+
+		for _, partition := range s.partitions(table) {
+			result := s.prune(ctx, table, partition, run.StartedAt.AddDate(0, 0, -days))
+			if result.Error != "" {
+				s.logger.Error("Retention failed", "table", table.name, "park", result.Park, "error", result.Error)
+			} else if result.Deleted > 0 {
+				s.logger.Info("Pruned expired rows", "table", table.name, "park", result.Park, "cutoff", result.Cutoff, "archived", result.Archived, "deleted", result.Deleted, "archive", result.Archive)
+			}
+			run.Results = append(run.Results, result)
+		}
+	}
+
+	run.FinishedAt = time.Now()
+
+	s.mu.Lock()
+	s.lastRun = &run
+	s.mu.Unlock()
+
+	return run
+}
+
+// finalize has the finalizer finalize everything derived from the positions
+// and zone events the run deletes. The cutoff is that of whichever of them is
+// kept for less time, or the start of the run when neither is pruned, so
+// complete days are finalized even when positions are kept forever.
+func (s *RetentionService) finalize(ctx context.Context, startedAt time.Time) error {
+	if s.finalizer == nil {
+		return nil
+	}
+
+	cutoff := startedAt
+	pruned := false
+	for _, name := range finalizedTables {
+		days := s.config.Days[name]
+		if days <= 0 {
+			continue
+		}
+		if tableCutoff := startedAt.AddDate(0, 0, -days); !pruned || tableCutoff.After(cutoff) {
+			cutoff = tableCutoff
+		}
+		pruned = true
+	}
+
+	if err := s.finalizer.FinalizeBefore(ctx, cutoff); err != nil {
+		s.logger.Error("Failed to finalize records derived from expiring positions", "cutoff", cutoff, "error", err)
+		return err
+	}
+	return nil
+}
+
+func isFinalizedTable(name string) bool {
+	for _, finalized := range finalizedTables {
+		if finalized == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *RetentionService) prune(ctx context.Context, table retainedTable, partition retentionPartition, cutoff time.Time) RetentionResult {
+	result := RetentionResult{Table: table.name, Park: partition.park, Cutoff: cutoff}
+	expired := table.timeColumn + " < ?"
+
+	if partition.archiver == nil {
+		deleted := partition.scope(s.db.WithContext(ctx).Table(table.name)).Where(expired, cutoff).Delete(map[string]interface{}{})
+		if deleted.Error != nil {
+			result.Error = deleted.Error.Error()
+		}
+		result.Deleted = deleted.RowsAffected
+		return result
+	}
+
+	archived, maxID, location, err := s.archive(ctx, table, partition, cutoff)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Archived = archived
+	result.Archive = location
+	if archived == 0 {
+		return result
+	}
+
+	// Rows that expired while the archive was written are left for the next run
+	deleted := partition.scope(s.db.WithContext(ctx).Table(table.name)).Where(expired+" AND id <= ?", cutoff, maxID).Delete(map[string]interface{}{})
+	if deleted.Error != nil {
+		result.Error = deleted.Error.Error()
+	}
+	result.Deleted = deleted.RowsAffected
+	return result
+}
+
+// archive writes the partition's expired rows to a temporary file and hands
+// it to the partition's archiver. It returns the number of rows and the
+// highest ID archived.
+func (s *RetentionService) archive(ctx context.Context, table retainedTable, partition retentionPartition, cutoff time.Time) (int64, uint64, string, error) {
+	file, err := os.CreateTemp("", "retention-*.ndjson.gz")
+	if err != nil {
+		return 0, 0, "", fmt.Errorf("failed to create archive file: %w", err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	buffered := bufio.NewWriter(file)
+	compressed := gzip.NewWriter(buffered)
+	encoder := json.NewEncoder(compressed)
+
+	var count int64
+	var maxID uint64
+
+	rows := table.newRows()
+	err = partition.scope(s.db.WithContext(ctx)).Where(table.timeColumn+" < ?", cutoff).
+		Order("id").
+		FindInBatches(rows, retentionBatchSize, func(tx *gorm.DB, batch int) error {
+			slice := reflect.ValueOf(rows).Elem()
+			for i := 0; i < slice.Len(); i++ {
+				row := slice.Index(i)
+				if err := encoder.Encode(row.Interface()); err != nil {
+					return err
+				}
+				if id := row.FieldByName("ID").Uint(); id > maxID {
+					maxID = id
+				}
+				count++
+			}
+			return nil
+		}).Error
+	if err != nil {
+		return 0, 0, "", fmt.Errorf("failed to read expired rows: %w", err)
+	}
+
+	if count == 0 {
+		return 0, 0, "", nil
+	}
+
+	if err := compressed.Close(); err != nil {
+		return 0, 0, "", fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := buffered.Flush(); err != nil {
+		return 0, 0, "", fmt.Errorf("failed to write archive: %w", err)
+	}
+
+	key := fmt.Sprintf("%s/%s-%s.ndjson.gz", table.name, table.name, time.Now().UTC().Format("20060102T150405Z"))
+	if partition.park != "" {
+		key = partition.park + "/" + key
+	}
+	location, err := partition.archiver.Put(ctx, key, file)
+	if err != nil {
+		return 0, 0, "", err
+	}
+
+	return count, maxID, location, nil
+}
+
+// RestoreArchive inserts the rows of a retention archive back into its
+// table. Rows that still exist are skipped, so restoring twice is harmless.
+func RestoreArchive(db *gorm.DB, tableName string, r io.Reader) (int64, error) {
+	table, ok := findRetainedTable(tableName)
+	if !ok {
+		return 0, fmt.Errorf("unknown table %q (expected one of %s)", tableName, strings.Join(RetentionTables(), ", "))
+	}
+
+	decompressed, err := gzip.NewReader(r)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer decompressed.Close()
+
+	decoder := json.NewDecoder(decompressed)
+	var restored int64
+
+	for {
+		rows := table.newRows()
+		slice := reflect.ValueOf(rows).Elem()
+
+		for slice.Len() < retentionBatchSize {
+			row := reflect.New(slice.Type().Elem())
+			if err := decoder.Decode(row.Interface()); err == io.EOF {
+				break
+			} else if err != nil {
+				return restored, fmt.Errorf("failed to decode row %d: %w", restored+int64(slice.Len())+1, err)
+			}
+			slice = reflect.Append(slice, row.Elem())
+		}
+
+		if slice.Len() == 0 {
+			return restored, nil
+		}
+		reflect.ValueOf(rows).Elem().Set(slice)
+
+		result := db.Omit(clause.Associations).
+			Clauses(clause.OnConflict{DoNothing: true}).
+			Create(rows)
+		if result.Error != nil {
+			return restored, fmt.Errorf("failed to insert rows: %w", result.Error)
+		}
+		restored += result.RowsAffected
+
+		if slice.Len() < retentionBatchSize {
+			return restored, nil
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"fmt"
+	"time"
+	"vessel-tracker/database"
+	"vessel-tracker/models"
+
+	"gorm.io/gorm"
+)
+
+// Allowed manual sanction status transitions. Overdue is normally set by the
+// scheduler but can still be paid or cancelled afterwards.
+var sanctionTransitions = map[string][]string{
+	models.SanctionStatusIssued:  {models.SanctionStatusPaid, models.SanctionStatusOverdue, models.SanctionStatusCancelled},
+	models.SanctionStatusOverdue: {models.SanctionStatusPaid, models.SanctionStatusCancelled},
+}
+
+type SanctionService struct {
+	db *gorm.DB
+}
+
+func NewSanctionService() *SanctionService {
+	return &SanctionService{
+		db: database.GetDB(),
+	}
+}
+
+// IssueSanction records a fine for a violation
+func (s *SanctionService) IssueSanction(sanction *models.Sanction) error {
+	var violation models.Violation
+	if err := s.db.First(&violation, sanction.ViolationID).Error; err != nil {
+		return err
+	}
+
+	sanction.Status = models.SanctionStatusIssued
+	if sanction.IssuedAt.IsZero() {
+		sanction.IssuedAt = time.Now()
+	}
+	if sanction.DueDate.IsZero() {
+		sanction.DueDate = sanction.IssuedAt.AddDate(0, 0, 60)
+	}
+	if sanction.Currency == "" {
+		sanction.Currency = "EUR"
+	}
+
+	if err := s.db.Create(sanction).Error; err != nil {
+		return fmt.Errorf("failed to issue sanction: %w", err)
+	}
+	return nil
+}
+
+// GetSanctions lists sanctions, optionally filtered by status
+func (s *SanctionService) GetSanctions(status string) ([]models.Sanction, error) {
+	var sanctions []models.Sanction
+
+	query := s.db.Order("issued_at DESC")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	err := query.Find(&sanctions).Error
+	return sanctions, err
+}
+
+// GetSanctionsForViolation returns the sanctions issued for a violation
+func (s *SanctionService) GetSanctionsForViolation(violationID uint) ([]models.Sanction, error) {
+	var sanctions []models.Sanction
+	err := s.db.Where("violation_id = ?", violationID).Order("issued_at ASC").Find(&sanctions).Error
+	return sanctions, err
+}
+
+// UpdateStatus moves a sanction to a new payment state
+func (s *SanctionService) UpdateStatus(id uint, status string, amountPaid float64, reference, notes string) (*models.Sanction, error) {
+	var sanction models.Sanction
+	if err := s.db.First(&sanction, id).Error; err != nil {
+		return nil, err
+	}
+
+	if err := s.applyStatus(&sanction, status, amountPaid, reference, notes); err != nil {
+		return nil, err
+	}
+
+	return &sanction, nil
+}
+
+// RecordPayment marks the sanction with the given payment reference as paid,
+// used by the payment provider webhook
+func (s *SanctionService) RecordPayment(reference string, amountPaid float64) (*models.Sanction, error) {
+	var sanction models.Sanction
+	if err := s.db.Where("payment_reference = ?", reference).First(&sanction).Error; err != nil {
+		return nil, err
+	}
+
+	if sanction.Status == models.SanctionStatusPaid {
+		// Providers retry webhooks, treat repeated notifications as no-ops
+		return &sanction, nil
+	}
+
+	if err := s.applyStatus(&sanction, models.SanctionStatusPaid, amountPaid, reference, ""); err != nil {
+		return nil, err
+	}
+
+	return &sanction, nil
+}
+
+func (s *SanctionService) applyStatus(sanction *models.Sanction, status string, amountPaid float64, reference, notes string) error {
+	allowed := false
+	for _, next := range sanctionTransitions[sanction.Status] {
+		if next == status {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, sanction.Status, status)
+	}
+
+	if status == models.SanctionStatusPaid {
+		now := time.Now()
+		sanction.PaidAt = &now
+		sanction.AmountPaid = amountPaid
+		if sanction.AmountPaid == 0 {
+			sanction.AmountPaid = sanction.Amount
+		}
+	}
+	if reference != "" {
+		sanction.PaymentReference = reference
+	}
+	if notes != "" {
+		sanction.Notes = notes
+	}
+
+	sanction.Status = status
+	if err := s.db.Save(sanction).Error; err != nil {
+		return fmt.Errorf("failed to update sanction: %w", err)
+	}
+	return nil
+}
+
+// MarkOverdue flags issued sanctions whose due date has passed
+func (s *SanctionService) MarkOverdue() (int64, error) {
+	result := s.db.Model(&models.Sanction{}).
+		Where("status = ? AND due_date < ?", models.SanctionStatusIssued, time.Now()).
+		Update("status", models.SanctionStatusOverdue)
+	return result.RowsAffected, result.Error
+}
+
+// GetCollectionStats computes fine collection figures for sanctions issued in a period
+func (s *SanctionService) GetCollectionStats(startTime, endTime time.Time) (*models.SanctionCollectionStats, error) {
+	var stats models.SanctionCollectionStats
+
+	err := s.db.Model(&models.Sanction{}).
+		Select(`COUNT(*) AS issued,
+			COUNT(CASE WHEN status = ? THEN 1 END) AS paid,
+			COUNT(CASE WHEN status = ? THEN 1 END) AS overdue,
+			COUNT(CASE WHEN status = ? THEN 1 END) AS cancelled,
+			COALESCE(SUM(CASE WHEN status <> ? THEN amount END), 0) AS amount_issued,
+			COALESCE(SUM(amount_paid), 0) AS amount_collected`,
+			models.SanctionStatusPaid, models.SanctionStatusOverdue, models.SanctionStatusCancelled,
+			models.SanctionStatusCancelled).
+		Where("issued_at BETWEEN ? AND ?", startTime, endTime).
+		Scan(&stats).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if stats.AmountIssued > 0 {
+		stats.CollectionRate = stats.AmountCollected / stats.AmountIssued
+	}
+
+	return &stats, nil
+}
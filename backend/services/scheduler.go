@@ -1,95 +1,773 @@
 package services
 
 import (
-	"log"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
 	"time"
+	"vessel-tracker/logging"
+	"vessel-tracker/models"
 
 	"github.com/robfig/cron/v3"
 )
 
+// SchedulerConfig holds the intervals and limits of the scheduled jobs
+type SchedulerConfig struct {
+	Source        string        // where vessel positions come from, DataSourceDatalastic or DataSourceAIS
+	FetchInterval time.Duration // how often vessel positions are fetched
+	RadiusNM      int           // search radius around each park center, in nautical miles, unless the park sets its own
+	RetentionDays int           // how long position records are kept
+	FetchTimeout  time.Duration // how long a fetch may run before its outstanding API calls and queries are cancelled
+}
+
+// Bounds accepted for scheduler settings. The Datalastic in-radius endpoint
+// rejects radii above 50 NM, and fetching more often than every minute only
+// burns API credits.
+const (
+	minFetchInterval = time.Minute
+	maxRadiusNM      = 50
+)
+
+// Vessel position sources. Datalastic is polled every fetch interval; the AIS
+// receiver pushes positions decoded from a local base station instead.
+const (
+	DataSourceDatalastic = "datalastic"
+	DataSourceAIS        = "ais"
+)
+
+func DefaultSchedulerConfig() SchedulerConfig {
+	return SchedulerConfig{
+		Source:        DataSourceDatalastic,
+		FetchInterval: 30 * time.Minute,
+		RadiusNM:      20,
+		RetentionDays: 30,
+		FetchTimeout:  10 * time.Minute,
+	}
+}
+
+// LoadSchedulerConfig reads VESSEL_DATA_SOURCE, SCHEDULER_FETCH_INTERVAL (a
+// duration such as "15m"), SCHEDULER_RADIUS_NM, SCHEDULER_RETENTION_DAYS and
+// SCHEDULER_FETCH_TIMEOUT, falling back to the defaults for unset variables
+func LoadSchedulerConfig() (SchedulerConfig, error) {
+	config := DefaultSchedulerConfig()
+
+	if value := os.Getenv("VESSEL_DATA_SOURCE"); value != "" {
+		config.Source = value
+	}
+
+	if value := os.Getenv("SCHEDULER_FETCH_INTERVAL"); value != "" {
+		interval, err := time.ParseDuration(value)
+		if err != nil {
+			return config, fmt.Errorf("invalid SCHEDULER_FETCH_INTERVAL %q: %w", value, err)
+		}
+		config.FetchInterval = interval
+	}
+
+	if value := os.Getenv("SCHEDULER_RADIUS_NM"); value != "" {
+		radius, err := strconv.Atoi(value)
+		if err != nil {
+			return config, fmt.Errorf("invalid SCHEDULER_RADIUS_NM %q: %w", value, err)
+		}
+		config.RadiusNM = radius
+	}
+
+	if value := os.Getenv("SCHEDULER_RETENTION_DAYS"); value != "" {
+		days, err := strconv.Atoi(value)
+		if err != nil {
+			return config, fmt.Errorf("invalid SCHEDULER_RETENTION_DAYS %q: %w", value, err)
+		}
+		config.RetentionDays = days
+	}
+
+	if value := os.Getenv("SCHEDULER_FETCH_TIMEOUT"); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			return config, fmt.Errorf("invalid SCHEDULER_FETCH_TIMEOUT %q: %w", value, err)
+		}
+		config.FetchTimeout = timeout
+	}
+
+	return config, config.Validate()
+}
+
+// Validate checks that the settings are within usable bounds
+func (c SchedulerConfig) Validate() error {
+	if c.Source != DataSourceDatalastic && c.Source != DataSourceAIS {
+		return fmt.Errorf("data source must be %s or %s, got %q", DataSourceDatalastic, DataSourceAIS, c.Source)
+	}
+	if c.FetchInterval < minFetchInterval {
+		return fmt.Errorf("fetch interval must be at least %s, got %s", minFetchInterval, c.FetchInterval)
+	}
+	if c.RadiusNM < 1 || c.RadiusNM > maxRadiusNM {
+		return fmt.Errorf("radius must be between 1 and %d NM, got %d", maxRadiusNM, c.RadiusNM)
+	}
+	if c.RetentionDays < 1 {
+		return fmt.Errorf("retention must be at least 1 day, got %d", c.RetentionDays)
+	}
+	if c.FetchTimeout <= 0 {
+		return fmt.Errorf("fetch timeout must be positive, got %s", c.FetchTimeout)
+	}
+	return nil
+}
+
+// SchedulerStatus reports the outcome of recent vessel data fetches
+type SchedulerStatus struct {
+	Running               bool       `json:"running"`
+	Paused                bool       `json:"paused"`
+	LastRunID             string     `json:"last_run_id,omitempty"`
+	LastRunAt             *time.Time `json:"last_run_at"`
+	LastSuccessAt         *time.Time `json:"last_success_at"`
+	LastFailureAt         *time.Time `json:"last_failure_at"`
+	LastError             string     `json:"last_error,omitempty"`
+	VesselsFetched        int        `json:"vessels_fetched"`
+	PositionsStored       int        `json:"positions_stored"`
+	DuplicateSkipped      int        `json:"duplicate_skipped"`
+	DuplicateSkippedTotal int64      `json:"duplicate_skipped_total"`
+	NextRunAt             *time.Time `json:"next_run_at"`
+}
+
 type SchedulerService struct {
-	cron           *cron.Cron
-	vesselService  *VesselService
-	geoService     *GeoService
-	vesselRepo     *VesselRepository
+	cron              *cron.Cron
+	config            SchedulerConfig
+	fetchEntryID      cron.EntryID
+	vesselService     *VesselService
+	parks             *ParkRegistry
+	vesselRepo        *VesselRepository
+	violationService  *ViolationService
+	watchlistService  *WatchlistService
+	trajectoryService *TrajectoryService
+	anchoringDetector *AnchoringDetector
+	shadowDetector    *ShadowDetector
+	arrivalService    *ArrivalService
+	zoneEventService  *ZoneEventService
+	sanctionService   *SanctionService
+	retentionService  *RetentionService
+	weatherService    *WeatherService
+	anchorDrag        *AnchorDragDetector
+	logger            *slog.Logger
+
+	// ctx is cancelled by Stop, abandoning the API calls and queries of
+	// running jobs
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	status  SchedulerStatus
+	stopped bool // set by Stop, after which no fetch starts
+
+	// fetches counts the fetches in flight, including those FetchNow runs in
+	// the background, so Stop can wait for them to return
+	fetches sync.WaitGroup
+
+	// fetchedAt is when positions around each park were last fetched or
+	// ingested successfully, by park ID
+	fetchedAt map[uint]time.Time
 }
 
-func NewSchedulerService(vesselService *VesselService, geoService *GeoService, vesselRepo *VesselRepository) *SchedulerService {
+func NewSchedulerService(config SchedulerConfig, vesselService *VesselService, parks *ParkRegistry, vesselRepo *VesselRepository, violationService *ViolationService, watchlistService *WatchlistService, trajectoryService *TrajectoryService, anchoringDetector *AnchoringDetector, shadowDetector *ShadowDetector, arrivalService *ArrivalService, zoneEventService *ZoneEventService, sanctionService *SanctionService, retentionService *RetentionService, weatherService *WeatherService, anchorDrag *AnchorDragDetector) *SchedulerService {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &SchedulerService{
-		cron:          cron.New(cron.WithSeconds()),
-		vesselService: vesselService,
-		geoService:    geoService,
-		vesselRepo:    vesselRepo,
+		cron:              cron.New(cron.WithSeconds()),
+		config:            config,
+		vesselService:     vesselService,
+		parks:             parks,
+		vesselRepo:        vesselRepo,
+		violationService:  violationService,
+		watchlistService:  watchlistService,
+		trajectoryService: trajectoryService,
+		anchoringDetector: anchoringDetector,
+		shadowDetector:    shadowDetector,
+		arrivalService:    arrivalService,
+		zoneEventService:  zoneEventService,
+		sanctionService:   sanctionService,
+		retentionService:  retentionService,
+		weatherService:    weatherService,
+		anchorDrag:        anchorDrag,
+		logger:            logging.Component("scheduler"),
+		ctx:               ctx,
+		cancel:            cancel,
+		fetchedAt:         make(map[uint]time.Time),
 	}
 }
 
 func (s *SchedulerService) Start() error {
-	// Fetch vessel data every 30 minutes
-	_, err := s.cron.AddFunc("0 */30 * * * *", s.fetchVesselData)
+	// Fetch vessel data at the configured interval, unless the AIS receiver
+	// pushes positions instead
+	if s.config.Source == DataSourceDatalastic {
+		entryID, err := s.cron.AddFunc(fmt.Sprintf("@every %s", s.config.FetchInterval), s.fetchVesselData)
+		if err != nil {
+			return err
+		}
+		s.fetchEntryID = entryID
+	}
+
+	// Archive and delete expired records daily at 2 AM
+	_, err := s.cron.AddFunc("0 0 2 * * *", s.cleanupOldRecords)
+	if err != nil {
+		return err
+	}
+
+	// Flag unpaid fines past their due date daily at 3 AM
+	_, err = s.cron.AddFunc("0 0 3 * * *", s.markOverdueSanctions)
 	if err != nil {
 		return err
 	}
 
-	// Clean up old records daily at 2 AM
-	_, err = s.cron.AddFunc("0 0 2 * * *", s.cleanupOldRecords)
+	// Log the previous day's first arrivals in each park shortly after midnight UTC
+	_, err = s.cron.AddFunc("CRON_TZ=UTC 0 5 0 * * *", func() {
+		if !s.skipWhilePaused("arrival digest") {
+			s.arrivalService.LogDailyDigest(s.parks.All())
+		}
+	})
 	if err != nil {
 		return err
 	}
 
 	s.cron.Start()
-	log.Println("Scheduler started - will fetch vessel data every 30 minutes")
+	s.logger.Info("Scheduler started", "source", s.config.Source, "fetch_interval", s.config.FetchInterval.String(), "fetch_timeout", s.config.FetchTimeout.String(), "radius_nm", s.config.RadiusNM, "parks", len(s.parks.All()))
 
 	// Run initial fetch
-	go s.fetchVesselData()
+	if s.config.Source == DataSourceDatalastic {
+		go s.fetchVesselData()
+	}
 
 	return nil
 }
 
+// Config returns the active scheduler settings
+func (s *SchedulerService) Config() SchedulerConfig {
+	return s.config
+}
+
+// Stop cancels running jobs and fetches and waits for them to return, so
+// the database can be closed afterwards
 func (s *SchedulerService) Stop() {
-	s.cron.Stop()
-	log.Println("Scheduler stopped")
+	s.mu.Lock()
+	s.stopped = true
+	s.mu.Unlock()
+
+	s.cancel()
+	<-s.cron.Stop().Done()
+	s.fetches.Wait()
+	s.logger.Info("Scheduler stopped")
+}
+
+// ErrFetchRunning is returned when a fetch is requested while one is running
+var ErrFetchRunning = errors.New("a vessel data fetch is already running")
+
+// ErrPollingDisabled is returned when a fetch is requested while positions
+// come from the AIS receiver
+var ErrPollingDisabled = errors.New("vessel data is not polled, positions come from the AIS receiver")
+
+// ErrSchedulerPaused is returned when a fetch is requested while the
+// scheduler is paused for maintenance
+var ErrSchedulerPaused = errors.New("the scheduler is paused for maintenance")
+
+// ErrSchedulerStopped is returned when a fetch is requested after Stop
+var ErrSchedulerStopped = errors.New("the scheduler has stopped")
+
+// Pause stops the scheduled jobs, fetches and AIS ingestion from starting
+// until Resume is called, e.g. while database migrations run. A fetch that is
+// already running is not interrupted; Status reports when it has finished.
+func (s *SchedulerService) Pause() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.status.Paused {
+		s.status.Paused = true
+		s.logger.Info("Scheduler paused", "fetch_running", s.status.Running)
+	}
+}
+
+// Resume lets the scheduled jobs run again after Pause
+func (s *SchedulerService) Resume() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.status.Paused {
+		s.status.Paused = false
+		s.logger.Info("Scheduler resumed")
+	}
+}
+
+// skipWhilePaused reports whether the scheduler is paused, logging that the
+// named job is skipped
+func (s *SchedulerService) skipWhilePaused(job string) bool {
+	s.mu.Lock()
+	paused := s.status.Paused
+	s.mu.Unlock()
+
+	if paused {
+		s.logger.Info("Skipping scheduled job, scheduler paused", "job", job)
+	}
+	return paused
 }
 
 func (s *SchedulerService) fetchVesselData() {
-	log.Println("Starting scheduled vessel data fetch...")
+	switch err := s.RunFetch(); {
+	case errors.Is(err, ErrFetchRunning):
+		s.logger.Warn("Skipping vessel data fetch, previous fetch still running")
+	case errors.Is(err, ErrSchedulerPaused):
+		s.logger.Info("Skipping vessel data fetch, scheduler paused")
+	}
+}
+
+// RunFetch fetches, stores and analyzes vessel positions once and waits for
+// the result, exactly as the scheduled job does
+func (s *SchedulerService) RunFetch() error {
+	if s.config.Source != DataSourceDatalastic {
+		return ErrPollingDisabled
+	}
+	runID, err := s.beginFetch()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := s.fetchContext(runID)
+	defer cancel()
+
+	result, err := s.runFetch(ctx)
+	s.endFetch(result, err)
+	return err
+}
+
+// fetchResult summarizes one fetch run
+type fetchResult struct {
+	vesselsFetched int
+	stored         StoreResult
+}
+
+// runFetch fetches, stores and analyzes vessel positions around every park.
+// A park whose fetch fails does not stop the others, but fails the run.
+func (s *SchedulerService) runFetch(ctx context.Context) (fetchResult, error) {
+	var result fetchResult
 
-	centerLat, centerLon := s.geoService.GetParkCenter()
+	s.logger.Info("Starting scheduled vessel data fetch", "run_id", FetchRunID(ctx))
 
-	vesselPositions, err := s.vesselService.GetVesselsInRadius(centerLat, centerLon, 20)
+	var failures []error
+	for _, park := range s.parks.All() {
+		if err := ctx.Err(); err != nil {
+			failures = append(failures, fmt.Errorf("fetch abandoned: %w", err))
+			break
+		}
+		parkResult, err := s.fetchPark(ctx, park)
+		result.vesselsFetched += parkResult.vesselsFetched
+		result.stored.Stored += parkResult.stored.Stored
+		result.stored.DuplicatesSkipped += parkResult.stored.DuplicatesSkipped
+		if err != nil {
+			failures = append(failures, fmt.Errorf("park %s: %w", park.Record.Slug, err))
+		}
+	}
+
+	return result, errors.Join(failures...)
+}
+
+// parkRadius returns the search radius around a park center in nautical miles
+func (s *SchedulerService) parkRadius(park *Park) int {
+	if park.Record.RadiusNM != 0 {
+		return park.Record.RadiusNM
+	}
+	return s.config.RadiusNM
+}
+
+// fetchPark records the weather at the center of one park and fetches, stores
+// and analyzes the vessel positions around it
+func (s *SchedulerService) fetchPark(ctx context.Context, park *Park) (fetchResult, error) {
+	var result fetchResult
+	logger := s.logger.With("park", park.Record.Slug, "run_id", FetchRunID(ctx))
+
+	centerLat, centerLon := park.Geo.GetParkCenter()
+
+	// The weather is kept for playback; failing to fetch it does not fail
+	// the run
+	if s.weatherService.Config().Enabled {
+		if _, err := s.weatherService.RecordObservation(ctx, park); err != nil {
+			logger.Warn("Failed to record the weather", "error", err)
+		}
+	}
+
+	fetchedAt := time.Now()
+	vesselPositions, err := s.vesselService.GetVesselsInRadius(ctx, centerLat, centerLon, s.parkRadius(park))
 	if err != nil {
-		log.Printf("Failed to fetch vessels: %v", err)
-		return
+		logger.Error("Failed to fetch vessels", "error", err)
+		return result, fmt.Errorf("failed to fetch vessels: %w", err)
 	}
 
-	if len(vesselPositions.Data.Vessels) == 0 {
-		log.Println("No vessels found in the area")
-		return
+	result.vesselsFetched = len(vesselPositions.Data.Vessels)
+	if result.vesselsFetched == 0 {
+		logger.Info("No vessels found in the area")
+		s.markFetched(park, fetchedAt)
+		return result, nil
+	}
+
+	result.stored, err = s.processPark(ctx, park, vesselPositions.Data.Vessels, logger)
+	if err == nil {
+		s.markFetched(park, fetchedAt)
+	}
+	return result, err
+}
+
+// processPark stores the positions around one park, detects violations,
+// watchlisted vessels, projected intrusions, zone transitions and first
+// arrivals, analyzes anchoring, checks for dragging anchors and runs shadow
+// detection, whichever source the positions came from
+func (s *SchedulerService) processPark(ctx context.Context, park *Park, positions []models.VesselPosition, logger *slog.Logger) (StoreResult, error) {
+	// Classify once up front; storage and violation detection share the result
+	zones := park.Geo.ClassifyPositions(positions)
+	if !park.Geo.BufferZoneAvailable() {
+		logger.Warn("Buffer zone layer unavailable; buffer zone violations are not being detected this cycle")
 	}
 
-	err = s.vesselRepo.StoreVesselData(vesselPositions.Data.Vessels, s.geoService)
+	stored, err := s.vesselRepo.StoreVesselData(ctx, park.Record.ID, positions, zones)
 	if err != nil {
-		log.Printf("Failed to store vessel data: %v", err)
-		return
+		logger.Error("Failed to store vessel data", "error", err)
+		return StoreResult{}, fmt.Errorf("failed to store vessel data: %w", err)
+	}
+
+	logger.Info("Stored vessel positions", "stored", stored.Stored, "duplicates_skipped", stored.DuplicatesSkipped)
+
+	detected, resolved := s.violationService.DetectViolations(park, positions, zones)
+	if detected > 0 {
+		logger.Info("Detected new violations", "count", detected)
+	}
+	if resolved > 0 {
+		logger.Info("Resolved violations of vessels that left", "count", resolved)
+	}
+
+	// Repeat offenders are flagged first so the vessel that just reached
+	// the threshold is alerted this cycle
+	flagged, err := s.watchlistService.FlagRepeatOffenders(positions)
+	if err != nil {
+		logger.Error("Failed to flag repeat offenders", "error", err)
+	} else if flagged > 0 {
+		logger.Info("Added repeat offenders to the watchlist", "count", flagged)
+	}
+
+	alerts, err := s.watchlistService.CheckPositions(park, positions)
+	if err != nil {
+		logger.Error("Failed to check the watchlist", "error", err)
+	} else if alerts > 0 {
+		logger.Info("Watchlisted vessels sighted", "count", alerts)
+	}
+
+	projected := s.trajectoryService.CheckIntrusions(park, positions, zones)
+	if projected > 0 {
+		logger.Info("Vessels projected to enter the park", "count", projected)
+	}
+
+	transitions, err := s.zoneEventService.RecordTransitions(ctx, park, positions, zones, stored)
+	if err != nil {
+		logger.Error("Failed to record zone events", "error", err)
+	} else if transitions > 0 {
+		logger.Info("Recorded zone events", "count", transitions)
+	}
+
+	arrived, err := s.arrivalService.RecordArrivals(park, positions, zones)
+	if err != nil {
+		logger.Error("Failed to record park arrivals", "error", err)
+	} else if arrived > 0 {
+		logger.Info("Vessels seen in the park for the first time", "count", arrived)
 	}
 
-	log.Printf("Successfully stored %d vessel positions", len(vesselPositions.Data.Vessels))
+	vesselUUIDs := make([]string, 0, len(positions))
+	for _, vessel := range positions {
+		vesselUUIDs = append(vesselUUIDs, vessel.UUID)
+	}
+
+	anchored := s.anchoringDetector.AnalyzeVessels(ctx, park.Record.ID, vesselUUIDs)
+	if anchored > 0 {
+		logger.Info("Vessels currently anchored in the park", "count", anchored)
+	}
+
+	onMeadow, offMeadow := s.violationService.DetectPosidoniaAnchoring(park, positions)
+	if onMeadow > 0 {
+		logger.Info("Vessels anchored on posidonia", "count", onMeadow)
+	}
+	if offMeadow > 0 {
+		logger.Info("Resolved violations of vessels no longer anchored on posidonia", "count", offMeadow)
+	}
+
+	if s.anchorDrag != nil {
+		dragging, weighed := s.anchorDrag.Check(ctx, park, positions)
+		if dragging > 0 {
+			logger.Warn("Vessels dragging anchor", "count", dragging)
+		}
+		if weighed > 0 {
+			logger.Info("Resolved anchor drag alerts of vessels that weighed anchor", "count", weighed)
+		}
+	}
+
+	// Candidate detection logic only logs where it would decide differently
+	if s.shadowDetector != nil {
+		s.shadowDetector.Compare(ctx, park, positions, zones)
+	}
+
+	return *stored, nil
+}
+
+// IngestPositions runs positions pushed by the AIS receiver through the same
+// pipeline as a fetch. Each park gets the positions within its search radius,
+// with their distance from the park center in nautical miles.
+func (s *SchedulerService) IngestPositions(positions []models.VesselPosition) error {
+	runID, err := s.beginFetch()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := s.fetchContext(runID)
+	defer cancel()
+
+	result, err := s.ingest(ctx, positions)
+	s.endFetch(result, err)
+	return err
+}
+
+func (s *SchedulerService) ingest(ctx context.Context, positions []models.VesselPosition) (fetchResult, error) {
+	var result fetchResult
+	var failures []error
+	receivedAt := time.Now()
+
+	for _, park := range s.parks.All() {
+		if err := ctx.Err(); err != nil {
+			failures = append(failures, fmt.Errorf("ingest abandoned: %w", err))
+			break
+		}
+		logger := s.logger.With("park", park.Record.Slug, "run_id", FetchRunID(ctx))
+		centerLat, centerLon := park.Geo.GetParkCenter()
+		radiusMeters := float64(s.parkRadius(park)) * metersPerNauticalMile
+
+		nearby := make([]models.VesselPosition, 0, len(positions))
+		for _, pos := range positions {
+			distance := HaversineDistance(centerLat, centerLon, pos.Latitude, pos.Longitude)
+			if distance <= radiusMeters {
+				pos.Distance = distance / metersPerNauticalMile
+				nearby = append(nearby, pos)
+			}
+		}
+		if len(nearby) == 0 {
+			// The receiver is live, so no positions is current data too
+			s.markFetched(park, receivedAt)
+			continue
+		}
+
+		result.vesselsFetched += len(nearby)
+		stored, err := s.processPark(ctx, park, nearby, logger)
+		result.stored.Stored += stored.Stored
+		result.stored.DuplicatesSkipped += stored.DuplicatesSkipped
+		if err != nil {
+			failures = append(failures, fmt.Errorf("park %s: %w", park.Record.Slug, err))
+			continue
+		}
+		s.markFetched(park, receivedAt)
+	}
+
+	return result, errors.Join(failures...)
+}
+
+// beginFetch marks a fetch as running and returns its run ID. It returns
+// ErrFetchRunning if one already is running, ErrSchedulerPaused while the
+// scheduler is paused and ErrSchedulerStopped after Stop. Every fetch begun
+// must be ended with endFetch.
+func (s *SchedulerService) beginFetch() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopped {
+		return "", ErrSchedulerStopped
+	}
+	if s.status.Paused {
+		return "", ErrSchedulerPaused
+	}
+	if s.status.Running {
+		return "", ErrFetchRunning
+	}
+
+	s.fetches.Add(1)
+	now := time.Now()
+	s.status.Running = true
+	s.status.LastRunAt = &now
+	s.status.LastRunID = newFetchRunID(now)
+	return s.status.LastRunID, nil
+}
+
+// fetchContext bounds a fetch run by the fetch timeout and carries its run ID
+// to the provider
+func (s *SchedulerService) fetchContext(runID string) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(s.ctx, s.config.FetchTimeout)
+	return WithFetchRun(ctx, runID), cancel
+}
+
+// newFetchRunID returns an ID for a fetch run that sorts by start time
+func newFetchRunID(start time.Time) string {
+	id := start.UTC().Format("20060102T150405Z")
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return id
+	}
+	return id + "-" + hex.EncodeToString(b)
+}
+
+type fetchRunKey struct{}
+
+// WithFetchRun returns a context carrying the ID of the fetch run it belongs to
+func WithFetchRun(ctx context.Context, runID string) context.Context {
+	return context.WithValue(ctx, fetchRunKey{}, runID)
+}
+
+// FetchRunID returns the ID of the fetch run a context belongs to, or "" outside
+// a fetch run
+func FetchRunID(ctx context.Context) string {
+	runID, _ := ctx.Value(fetchRunKey{}).(string)
+	return runID
+}
+
+func (s *SchedulerService) endFetch(result fetchResult, err error) {
+	defer s.fetches.Done()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.status.Running = false
+	s.status.VesselsFetched = result.vesselsFetched
+	s.status.PositionsStored = result.stored.Stored
+	s.status.DuplicateSkipped = result.stored.DuplicatesSkipped
+	if err != nil {
+		s.status.LastFailureAt = &now
+		s.status.LastError = err.Error()
+		s.logger.Error("Vessel data fetch failed", "run_id", s.status.LastRunID, "vessels_fetched", result.vesselsFetched, "stored", result.stored.Stored, "error", err)
+	} else {
+		s.status.LastSuccessAt = &now
+		s.status.LastError = ""
+	}
+}
+
+// Sources of the vessels in API responses, besides the provider named by
+// VesselService.ProviderName for vessels fetched on request
+const (
+	ResponseSourceDatabase = "database" // positions stored by the scheduler
+	ResponseSourceDemo     = "demo"     // placeholder vessels shown when no data is available
+)
+
+// markFetched records that the positions around a park were fetched or
+// ingested successfully at a time
+func (s *SchedulerService) markFetched(park *Park, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fetchedAt[park.Record.ID] = at
+}
+
+// DataAsOf returns when the stored positions of a park were last brought up
+// to date: the start of its last successful fetch, or the last ingestion of
+// positions from the AIS receiver. Before the first since startup it falls
+// back to the time of the park's most recent stored position. For a nil park
+// it returns the most recent of all parks. It returns nil when no positions
+// have been stored.
+func (s *SchedulerService) DataAsOf(ctx context.Context, park *Park) *time.Time {
+	if park == nil {
+		var latest *time.Time
+		for _, p := range s.parks.All() {
+			if asOf := s.DataAsOf(ctx, p); asOf != nil && (latest == nil || asOf.After(*latest)) {
+				latest = asOf
+			}
+		}
+		return latest
+	}
+
+	s.mu.Lock()
+	at, ok := s.fetchedAt[park.Record.ID]
+	s.mu.Unlock()
+	if ok {
+		return &at
+	}
+
+	latest, err := s.vesselRepo.GetLatestRecordedAt(ctx, park.Record.ID)
+	if err != nil {
+		s.logger.Warn("Failed to find the latest stored position", "park", park.Record.Slug, "error", err)
+		return nil
+	}
+	return latest
+}
+
+// Status returns the outcome of recent fetches and the next scheduled run
+func (s *SchedulerService) Status() SchedulerStatus {
+	s.mu.Lock()
+	status := s.status
+	s.mu.Unlock()
+
+	status.DuplicateSkippedTotal = s.vesselRepo.DuplicatesSkipped()
+
+	if s.fetchEntryID != 0 {
+		if next := s.cron.Entry(s.fetchEntryID).Next; !next.IsZero() {
+			status.NextRunAt = &next
+		}
+	}
+
+	return status
 }
 
 func (s *SchedulerService) cleanupOldRecords() {
-	log.Println("Starting cleanup of old vessel records...")
+	if s.skipWhilePaused("cleanup") {
+		return
+	}
+	s.logger.Info("Starting cleanup of expired records", "archiving", s.retentionService.ArchivingEnabled())
+
+	run := s.retentionService.Run(s.ctx)
+
+	var archived, deleted int64
+	failed := 0
+	for _, result := range run.Results {
+		archived += result.Archived
+		deleted += result.Deleted
+		if result.Error != "" {
+			failed++
+		}
+	}
+
+	s.logger.Info("Cleanup completed", "archived", archived, "deleted", deleted, "failed_tables", failed, "duration", run.FinishedAt.Sub(run.StartedAt))
+}
 
-	// Keep records for 30 days
-	cutoffTime := time.Now().AddDate(0, 0, -30)
+// Retention returns the retention service used by the daily cleanup
+func (s *SchedulerService) Retention() *RetentionService {
+	return s.retentionService
+}
 
-	err := s.vesselRepo.DeleteOldRecords(cutoffTime)
+func (s *SchedulerService) markOverdueSanctions() {
+	if s.skipWhilePaused("overdue sanctions") {
+		return
+	}
+	count, err := s.sanctionService.MarkOverdue()
 	if err != nil {
-		log.Printf("Failed to cleanup old records: %v", err)
+		s.logger.Error("Failed to mark overdue sanctions", "error", err)
 		return
 	}
 
-	log.Println("Cleanup completed")
+	if count > 0 {
+		s.logger.Info("Marked sanctions as overdue", "count", count)
+	}
 }
 
-func (s *SchedulerService) FetchNow() {
-	go s.fetchVesselData()
-}
\ No newline at end of file
+// FetchNow starts a fetch in the background outside the regular schedule. It
+// returns ErrFetchRunning if a fetch is already running, ErrSchedulerPaused
+// while the scheduler is paused and ErrPollingDisabled when positions come
+// from the AIS receiver. Stop waits for the fetch to return.
+func (s *SchedulerService) FetchNow() error {
+	if s.config.Source != DataSourceDatalastic {
+		return ErrPollingDisabled
+	}
+	runID, err := s.beginFetch()
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		ctx, cancel := s.fetchContext(runID)
+		defer cancel()
+		s.endFetch(s.runFetch(ctx))
+	}()
+
+	return nil
+}
@@ -0,0 +1,485 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"vessel-tracker/database"
+	"vessel-tracker/logging"
+	"vessel-tracker/models"
+
+	"gorm.io/gorm"
+)
+
+// accessTokenPrefix marks session access tokens so the auth middleware can
+// tell them apart from static role tokens
+const accessTokenPrefix = "vt1."
+
+// sessionChannel is the notification channel instances use to tell each
+// other that a session or access token was revoked
+const sessionChannel = "sessions_revoked"
+
+var (
+	ErrInvalidToken       = errors.New("invalid token")
+	ErrTokenExpired       = errors.New("token has expired")
+	ErrTokenRevoked       = errors.New("token has been revoked")
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected, session revoked")
+)
+
+// AccessClaims is the signed payload of an access token
+type AccessClaims struct {
+	JTI       string `json:"jti"`
+	SessionID uint   `json:"sid"`
+	Actor     string `json:"sub"`
+	Role      string `json:"role"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// TokenPair is returned on login and refresh
+type TokenPair struct {
+	AccessToken      string    `json:"access_token"`
+	AccessExpiresAt  time.Time `json:"access_expires_at"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+	SessionID        uint      `json:"session_id"`
+}
+
+// SessionService issues device sessions with short-lived HMAC-signed access
+// tokens and rotating refresh tokens. Revoked sessions and access tokens are
+// kept in an in-memory blacklist so the auth middleware can reject them
+// without a database round trip; instances sharing a PostgreSQL database
+// reload theirs when another one revokes.
+type SessionService struct {
+	db         *gorm.DB
+	secret     []byte
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+
+	mu              sync.RWMutex
+	revokedSessions map[uint]time.Time   // session ID -> session expiry
+	revokedTokens   map[string]time.Time // access token JTI -> token expiry
+
+	// instanceID tags this instance's revocation notifications so it can
+	// skip its own
+	instanceID string
+	logger     *slog.Logger
+}
+
+// SessionConfig sets how session tokens are signed and how long they last
+type SessionConfig struct {
+	Secret     string        `secret:"true"`
+	AccessTTL  time.Duration // lifetime of an access token
+	RefreshTTL time.Duration // lifetime of a session and its refresh tokens
+}
+
+// LoadSessionConfig reads SESSION_SECRET, which is required, SESSION_ACCESS_TTL
+// (default 15m) and SESSION_REFRESH_TTL (default 720h)
+func LoadSessionConfig() (SessionConfig, error) {
+	config := SessionConfig{
+		Secret:     os.Getenv("SESSION_SECRET"),
+		AccessTTL:  15 * time.Minute,
+		RefreshTTL: 30 * 24 * time.Hour,
+	}
+
+	// A secret of its own per instance would make every instance reject the
+	// tokens the others issued, and a restart log everyone out
+	if config.Secret == "" {
+		return config, errors.New("SESSION_SECRET is required to sign session tokens")
+	}
+
+	if value := os.Getenv("SESSION_ACCESS_TTL"); value != "" {
+		ttl, err := time.ParseDuration(value)
+		if err != nil || ttl <= 0 {
+			return config, fmt.Errorf("invalid SESSION_ACCESS_TTL %q: must be a positive duration", value)
+		}
+		config.AccessTTL = ttl
+	}
+
+	if value := os.Getenv("SESSION_REFRESH_TTL"); value != "" {
+		ttl, err := time.ParseDuration(value)
+		if err != nil || ttl < config.AccessTTL {
+			return config, fmt.Errorf("invalid SESSION_REFRESH_TTL %q: must be a duration of at least SESSION_ACCESS_TTL", value)
+		}
+		config.RefreshTTL = ttl
+	}
+
+	return config, nil
+}
+
+// NewSessionService signs tokens with the configured secret
+func NewSessionService(config SessionConfig) (*SessionService, error) {
+	if config.Secret == "" {
+		return nil, errors.New("session secret is required")
+	}
+
+	s := &SessionService{
+		db:              database.GetDB(),
+		secret:          []byte(config.Secret),
+		accessTTL:       config.AccessTTL,
+		refreshTTL:      config.RefreshTTL,
+		revokedSessions: make(map[uint]time.Time),
+		revokedTokens:   make(map[string]time.Time),
+		instanceID:      newInstanceID(),
+		logger:          logging.Component("sessions"),
+	}
+
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Reload rebuilds the blacklist from the database, dropping entries whose
+// tokens have expired anyway
+func (s *SessionService) Reload() error {
+	now := time.Now()
+
+	var sessions []models.Session
+	if err := s.db.Where("revoked_at IS NOT NULL AND expires_at > ?", now).Find(&sessions).Error; err != nil {
+		return fmt.Errorf("failed to load revoked sessions: %w", err)
+	}
+
+	var tokens []models.RevokedToken
+	if err := s.db.Where("expires_at > ?", now).Find(&tokens).Error; err != nil {
+		return fmt.Errorf("failed to load revoked tokens: %w", err)
+	}
+
+	revokedSessions := make(map[uint]time.Time, len(sessions))
+	for _, session := range sessions {
+		revokedSessions[session.ID] = session.ExpiresAt
+	}
+
+	revokedTokens := make(map[string]time.Time, len(tokens))
+	for _, token := range tokens {
+		revokedTokens[token.JTI] = token.ExpiresAt
+	}
+
+	s.mu.Lock()
+	s.revokedSessions = revokedSessions
+	s.revokedTokens = revokedTokens
+	s.mu.Unlock()
+
+	return nil
+}
+
+// revoked tells the other instances to reload their blacklist
+func (s *SessionService) revoked() {
+	if err := database.Notify(sessionChannel, s.instanceID); err != nil {
+		// Other instances keep accepting the revoked tokens until they
+		// reload, at the latest when their listener reconnects
+		s.logger.Warn("Failed to notify other instances of revocation", "error", err)
+	}
+}
+
+// StartSync keeps the blacklist in step with other instances sharing the
+// database: it reloads whenever another instance revokes a session or access
+// token, and whenever the notification connection is (re)established to
+// catch revocations made in between. It returns false when the database has
+// no notification channel.
+func (s *SessionService) StartSync(ctx context.Context) bool {
+	reload := func(reason string) {
+		if err := s.Reload(); err != nil {
+			s.logger.Error("Failed to reload revoked sessions", "reason", reason, "error", err)
+			return
+		}
+		s.logger.Debug("Reloaded revoked sessions", "reason", reason)
+	}
+
+	return database.Listen(ctx, sessionChannel,
+		func() { reload("listener connected") },
+		func(payload string) {
+			if payload != s.instanceID {
+				reload("revoked by another instance")
+			}
+		})
+}
+
+// IsAccessToken reports whether the token has the session access token format
+func IsAccessToken(token string) bool {
+	return strings.HasPrefix(token, accessTokenPrefix)
+}
+
+// CreateSession starts a new device session for an authenticated actor
+func (s *SessionService) CreateSession(actor, role, deviceName string) (*TokenPair, error) {
+	refreshToken, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	session := models.Session{
+		Actor:            actor,
+		Role:             role,
+		DeviceName:       deviceName,
+		RefreshTokenHash: hashToken(refreshToken),
+		ExpiresAt:        now.Add(s.refreshTTL),
+		LastUsedAt:       now,
+	}
+
+	if err := s.db.Create(&session).Error; err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	return s.tokenPair(&session, refreshToken)
+}
+
+// Refresh exchanges a refresh token for a new token pair. The presented
+// refresh token is invalidated; presenting it again is treated as theft and
+// revokes the whole session.
+func (s *SessionService) Refresh(refreshToken string) (*TokenPair, error) {
+	hash := hashToken(refreshToken)
+
+	var session models.Session
+	err := s.db.Where("refresh_token_hash = ?", hash).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if err := s.db.Where("previous_token_hash = ?", hash).First(&session).Error; err == nil {
+			if session.RevokedAt == nil {
+				if _, err := s.RevokeSession(session.ID, "system", "refresh token reuse"); err != nil {
+					return nil, err
+				}
+			}
+			return nil, ErrRefreshTokenReused
+		}
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if session.RevokedAt != nil {
+		return nil, ErrTokenRevoked
+	}
+	if time.Now().After(session.ExpiresAt) {
+		return nil, ErrTokenExpired
+	}
+
+	newRefreshToken, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	// Conditional update so two concurrent refreshes cannot both succeed, nor
+	// a refresh racing the revocation of its session
+	result := s.db.Model(&models.Session{}).
+		Where("id = ? AND refresh_token_hash = ? AND revoked_at IS NULL", session.ID, hash).
+		Updates(map[string]interface{}{
+			"refresh_token_hash":  hashToken(newRefreshToken),
+			"previous_token_hash": hash,
+			"last_used_at":        time.Now(),
+		})
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to rotate refresh token: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrInvalidToken
+	}
+
+	return s.tokenPair(&session, newRefreshToken)
+}
+
+// ValidateAccessToken verifies the signature, expiry and blacklist status of
+// an access token
+func (s *SessionService) ValidateAccessToken(token string) (*AccessClaims, error) {
+	encoded, signature, ok := strings.Cut(strings.TrimPrefix(token, accessTokenPrefix), ".")
+	if !IsAccessToken(token) || !ok {
+		return nil, ErrInvalidToken
+	}
+
+	expected := s.sign(encoded)
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return nil, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	var claims AccessClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return nil, ErrTokenExpired
+	}
+
+	s.mu.RLock()
+	_, sessionRevoked := s.revokedSessions[claims.SessionID]
+	_, tokenRevoked := s.revokedTokens[claims.JTI]
+	s.mu.RUnlock()
+
+	if sessionRevoked || tokenRevoked {
+		return nil, ErrTokenRevoked
+	}
+
+	return &claims, nil
+}
+
+// RevokeSession revokes a session; its refresh token stops working and its
+// access tokens are rejected immediately
+func (s *SessionService) RevokeSession(id uint, revokedBy, reason string) (*models.Session, error) {
+	session, err := s.revokeSession(id, revokedBy, reason)
+	if err != nil {
+		return nil, err
+	}
+	s.revoked()
+	return session, nil
+}
+
+// revokeSession revokes a session without notifying the other instances
+func (s *SessionService) revokeSession(id uint, revokedBy, reason string) (*models.Session, error) {
+	var session models.Session
+	if err := s.db.First(&session, id).Error; err != nil {
+		return nil, err
+	}
+
+	if session.RevokedAt == nil {
+		now := time.Now()
+		session.RevokedAt = &now
+		session.RevokedBy = revokedBy
+		session.RevokeReason = reason
+
+		if err := s.db.Save(&session).Error; err != nil {
+			return nil, fmt.Errorf("failed to revoke session: %w", err)
+		}
+	}
+
+	s.mu.Lock()
+	s.revokedSessions[session.ID] = session.ExpiresAt
+	s.mu.Unlock()
+
+	return &session, nil
+}
+
+// RevokeActorSessions revokes every active session of an actor, e.g. when a
+// ranger reports a lost device without knowing which session it held
+func (s *SessionService) RevokeActorSessions(actor, revokedBy, reason string) (int, error) {
+	var sessions []models.Session
+	if err := s.db.Where("actor = ? AND revoked_at IS NULL", actor).Find(&sessions).Error; err != nil {
+		return 0, err
+	}
+
+	for i, session := range sessions {
+		if _, err := s.revokeSession(session.ID, revokedBy, reason); err != nil {
+			if i > 0 {
+				s.revoked()
+			}
+			return 0, err
+		}
+	}
+	if len(sessions) > 0 {
+		s.revoked()
+	}
+
+	return len(sessions), nil
+}
+
+// RevokeAccessToken blacklists a single access token until it expires
+func (s *SessionService) RevokeAccessToken(token, revokedBy, reason string) error {
+	claims, err := s.ValidateAccessToken(token)
+	if errors.Is(err, ErrTokenRevoked) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	entry := models.RevokedToken{
+		JTI:       claims.JTI,
+		SessionID: claims.SessionID,
+		Reason:    reason,
+		RevokedBy: revokedBy,
+		ExpiresAt: time.Unix(claims.ExpiresAt, 0),
+	}
+	if err := s.db.Create(&entry).Error; err != nil {
+		return fmt.Errorf("failed to blacklist token: %w", err)
+	}
+
+	s.mu.Lock()
+	s.revokedTokens[entry.JTI] = entry.ExpiresAt
+	s.mu.Unlock()
+
+	s.revoked()
+	return nil
+}
+
+// GetSessions lists sessions, optionally for one actor and only active ones
+func (s *SessionService) GetSessions(actor string, activeOnly bool) ([]models.Session, error) {
+	var sessions []models.Session
+
+	query := s.db.Order("created_at DESC")
+	if actor != "" {
+		query = query.Where("actor = ?", actor)
+	}
+	if activeOnly {
+		query = query.Where("revoked_at IS NULL AND expires_at > ?", time.Now())
+	}
+
+	err := query.Find(&sessions).Error
+	return sessions, err
+}
+
+func (s *SessionService) tokenPair(session *models.Session, refreshToken string) (*TokenPair, error) {
+	jti, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(s.accessTTL)
+	if expiresAt.After(session.ExpiresAt) {
+		expiresAt = session.ExpiresAt
+	}
+
+	payload, err := json.Marshal(AccessClaims{
+		JTI:       jti,
+		SessionID: session.ID,
+		Actor:     session.Actor,
+		Role:      session.Role,
+		ExpiresAt: expiresAt.Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+
+	return &TokenPair{
+		AccessToken:      accessTokenPrefix + encoded + "." + s.sign(encoded),
+		AccessExpiresAt:  expiresAt,
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: session.ExpiresAt,
+		SessionID:        session.ID,
+	}, nil
+}
+
+func (s *SessionService) sign(encoded string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func randomToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"vessel-tracker/models"
+)
+
+func newTestSessionService(t *testing.T) *SessionService {
+	openTestDatabase(t, testDatabases(t)["sqlite"])
+	s, err := NewSessionService(SessionConfig{Secret: "test-secret", AccessTTL: time.Minute, RefreshTTL: time.Hour})
+	if err != nil {
+		t.Fatalf("NewSessionService: %v", err)
+	}
+	return s
+}
+
+func TestLoadSessionConfigRequiresSecret(t *testing.T) {
+	t.Setenv("SESSION_SECRET", "")
+	if _, err := LoadSessionConfig(); err == nil {
+		t.Fatal("LoadSessionConfig without SESSION_SECRET succeeded")
+	}
+
+	t.Setenv("SESSION_SECRET", "shared-secret")
+	config, err := LoadSessionConfig()
+	if err != nil {
+		t.Fatalf("LoadSessionConfig: %v", err)
+	}
+	if config.Secret != "shared-secret" {
+		t.Fatalf("secret = %q, want shared-secret", config.Secret)
+	}
+}
+
+func TestRefreshRotatesToken(t *testing.T) {
+	s := newTestSessionService(t)
+
+	first, err := s.CreateSession("ranger-1", "ranger", "patrol tablet")
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	second, err := s.Refresh(first.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken || second.AccessToken == first.AccessToken {
+		t.Fatal("refresh returned the tokens it was given")
+	}
+	if second.SessionID != first.SessionID {
+		t.Fatalf("refresh moved to session %d, want %d", second.SessionID, first.SessionID)
+	}
+
+	claims, err := s.ValidateAccessToken(second.AccessToken)
+	if err != nil {
+		t.Fatalf("ValidateAccessToken: %v", err)
+	}
+	if claims.Actor != "ranger-1" || claims.Role != "ranger" {
+		t.Fatalf("claims = %+v, want ranger-1 as ranger", claims)
+	}
+
+	// The rotated token keeps working for the next refresh
+	if _, err := s.Refresh(second.RefreshToken); err != nil {
+		t.Fatalf("Refresh with the rotated token: %v", err)
+	}
+}
+
+func TestRefreshTokenReuseRevokesSession(t *testing.T) {
+	s := newTestSessionService(t)
+	other, err := NewSessionService(SessionConfig{Secret: "test-secret", AccessTTL: time.Minute, RefreshTTL: time.Hour})
+	if err != nil {
+		t.Fatalf("NewSessionService: %v", err)
+	}
+
+	stolen, err := s.CreateSession("ranger-1", "ranger", "patrol tablet")
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	rotated, err := s.Refresh(stolen.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+
+	// Presenting the replaced token again means two parties hold the session
+	if _, err := s.Refresh(stolen.RefreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("reused refresh token = %v, want ErrRefreshTokenReused", err)
+	}
+	if _, err := s.Refresh(rotated.RefreshToken); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("refresh of the revoked session = %v, want ErrTokenRevoked", err)
+	}
+	if _, err := s.ValidateAccessToken(rotated.AccessToken); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("access token of the revoked session = %v, want ErrTokenRevoked", err)
+	}
+
+	// Another instance rejects the session once it reloads its blacklist
+	if _, err := other.ValidateAccessToken(rotated.AccessToken); err != nil {
+		t.Fatalf("other instance before reload: %v", err)
+	}
+	if err := other.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if _, err := other.ValidateAccessToken(rotated.AccessToken); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("other instance after reload = %v, want ErrTokenRevoked", err)
+	}
+
+	var session models.Session
+	if err := s.db.First(&session, rotated.SessionID).Error; err != nil {
+		t.Fatalf("load session: %v", err)
+	}
+	if session.RevokedAt == nil || session.RevokeReason != "refresh token reuse" {
+		t.Fatalf("session = %+v, want revoked for refresh token reuse", session)
+	}
+}
+
+func TestAccessTokenSignedWithAnotherSecretIsRejected(t *testing.T) {
+	s := newTestSessionService(t)
+	other, err := NewSessionService(SessionConfig{Secret: "another-secret", AccessTTL: time.Minute, RefreshTTL: time.Hour})
+	if err != nil {
+		t.Fatalf("NewSessionService: %v", err)
+	}
+
+	pair, err := s.CreateSession("ranger-1", "ranger", "patrol tablet")
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	if _, err := other.ValidateAccessToken(pair.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("token of another secret = %v, want ErrInvalidToken", err)
+	}
+}
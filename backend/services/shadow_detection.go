@@ -0,0 +1,360 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+	"vessel-tracker/database"
+	"vessel-tracker/logging"
+	"vessel-tracker/models"
+
+	"gorm.io/gorm"
+)
+
+// ShadowConfig holds the candidate detection parameters run in shadow mode
+// next to the live ones. Shadow mode stops at Until, when set.
+type ShadowConfig struct {
+	Enabled         bool
+	SpeedLimitKnots float64
+	Anchoring       AnchoringConfig
+	Until           *time.Time
+}
+
+// LoadShadowConfig reads SHADOW_MODE ("true" to enable), the candidate
+// parameters SHADOW_SPEED_LIMIT_KNOTS, SHADOW_ANCHORING_MAX_SPEED_KNOTS,
+// SHADOW_ANCHORING_MAX_DRIFT_METERS, SHADOW_ANCHORING_MIN_POSITIONS and
+// SHADOW_ANCHORING_LOOKBACK, and SHADOW_UNTIL (RFC3339). Unset parameters
+// take the live value, so only the changed heuristic diverges.
+func LoadShadowConfig(liveAnchoring AnchoringConfig) (ShadowConfig, error) {
+	config := ShadowConfig{
+		SpeedLimitKnots: parkSpeedLimit,
+		Anchoring:       liveAnchoring,
+	}
+
+	if value := os.Getenv("SHADOW_MODE"); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return config, fmt.Errorf("invalid SHADOW_MODE %q: %w", value, err)
+		}
+		config.Enabled = enabled
+	}
+
+	floats := []struct {
+		name   string
+		target *float64
+	}{
+		{"SHADOW_SPEED_LIMIT_KNOTS", &config.SpeedLimitKnots},
+		{"SHADOW_ANCHORING_MAX_SPEED_KNOTS", &config.Anchoring.MaxSpeedKnots},
+		{"SHADOW_ANCHORING_MAX_DRIFT_METERS", &config.Anchoring.MaxDriftMeters},
+	}
+	for _, f := range floats {
+		value := os.Getenv(f.name)
+		if value == "" {
+			continue
+		}
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || parsed <= 0 {
+			return config, fmt.Errorf("invalid %s %q: must be a positive number", f.name, value)
+		}
+		*f.target = parsed
+	}
+
+	if value := os.Getenv("SHADOW_ANCHORING_MIN_POSITIONS"); value != "" {
+		positions, err := strconv.Atoi(value)
+		if err != nil || positions < 1 {
+			return config, fmt.Errorf("invalid SHADOW_ANCHORING_MIN_POSITIONS %q: must be at least 1", value)
+		}
+		config.Anchoring.MinPositions = positions
+	}
+
+	if value := os.Getenv("SHADOW_ANCHORING_LOOKBACK"); value != "" {
+		lookback, err := time.ParseDuration(value)
+		if err != nil || lookback <= 0 {
+			return config, fmt.Errorf("invalid SHADOW_ANCHORING_LOOKBACK %q: must be a positive duration", value)
+		}
+		config.Anchoring.LookbackWindow = lookback
+	}
+
+	if value := os.Getenv("SHADOW_UNTIL"); value != "" {
+		until, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return config, fmt.Errorf("invalid SHADOW_UNTIL %q: %w", value, err)
+		}
+		config.Until = &until
+	}
+
+	return config, nil
+}
+
+// ShadowDetector runs candidate detection logic alongside the live logic on
+// every detection cycle. Decisions on which they disagree are logged and
+// stored as divergences, never as violations or anchoring events, and each
+// cycle's decisions are tallied for the comparison report.
+type ShadowDetector struct {
+	db               *gorm.DB
+	vesselRepo       *VesselRepository
+	whitelistService *WhitelistService
+	live             AnchoringConfig
+	config           ShadowConfig
+	logger           *slog.Logger
+	endedOnce        sync.Once
+}
+
+func NewShadowDetector(config ShadowConfig, liveAnchoring AnchoringConfig, vesselRepo *VesselRepository, whitelistService *WhitelistService) *ShadowDetector {
+	return &ShadowDetector{
+		db:               database.GetDB(),
+		vesselRepo:       vesselRepo,
+		whitelistService: whitelistService,
+		live:             liveAnchoring,
+		config:           config,
+		logger:           logging.Component("shadow"),
+	}
+}
+
+// Active reports whether shadow detection runs at the given time
+func (d *ShadowDetector) Active(at time.Time) bool {
+	return d.config.Enabled && (d.config.Until == nil || at.Before(*d.config.Until))
+}
+
+// shadowTallies counts the decisions of each check in one cycle
+type shadowTallies map[string]*models.ShadowTally
+
+// add counts one decision and reports whether live and shadow disagreed
+func (t shadowTallies) add(parkID uint, check string, live, shadow bool, at time.Time) bool {
+	tally, ok := t[check]
+	if !ok {
+		tally = &models.ShadowTally{ParkID: parkID, Check: check, RecordedAt: at}
+		t[check] = tally
+	}
+
+	tally.Evaluated++
+	if live {
+		tally.LiveMatched++
+	}
+	if shadow {
+		tally.ShadowMatched++
+	}
+	switch {
+	case live && !shadow:
+		tally.LiveOnly++
+	case shadow && !live:
+		tally.ShadowOnly++
+	default:
+		return false
+	}
+	return true
+}
+
+// Compare evaluates the positions of a detection cycle with the live and the
+// shadow logic: the violation rules for every position of a vessel that is
+// not whitelisted, and anchoring for every vessel. It runs after the live
+// detection, on the same positions and zone classification.
+func (d *ShadowDetector) Compare(ctx context.Context, park *Park, positions []models.VesselPosition, zones []PositionZones) {
+	now := time.Now()
+	if !d.Active(now) {
+		if d.config.Enabled {
+			d.endedOnce.Do(func() {
+				d.logger.Info("Shadow period ended, shadow detection stopped", "until", d.config.Until)
+			})
+		}
+		return
+	}
+
+	logger := d.logger.With("park", park.Record.Slug)
+	tallies := make(shadowTallies)
+	var divergences []models.ShadowDivergence
+
+	records, err := vesselRecords(d.db, positions)
+	if err != nil {
+		logger.Error("Failed to load vessel details for the zone rules", "error", err)
+	}
+
+	for i, pos := range positions {
+		if d.whitelistService.IsVesselWhitelisted(pos.UUID, pos.MMSI, pos.IMO) {
+			continue
+		}
+
+		vessel := newRuleVessel(pos, records[pos.UUID])
+		live := evaluateRules(pos, vessel, zones[i], park.Rules, parkSpeedLimit)
+		shadow := evaluateRules(pos, vessel, zones[i], park.Rules, d.config.SpeedLimitKnots)
+		for j := range live {
+			if tallies.add(park.Record.ID, live[j].Rule, live[j].Matched, shadow[j].Matched, now) {
+				divergences = append(divergences, models.ShadowDivergence{
+					ParkID:        park.Record.ID,
+					VesselUUID:    pos.UUID,
+					Check:         live[j].Rule,
+					LiveMatched:   live[j].Matched,
+					ShadowMatched: shadow[j].Matched,
+					Latitude:      pos.Latitude,
+					Longitude:     pos.Longitude,
+					Speed:         pos.Speed,
+					LiveReason:    live[j].Reason,
+					ShadowReason:  shadow[j].Reason,
+					DetectedAt:    now,
+				})
+			}
+		}
+	}
+
+	seen := make(map[string]bool, len(positions))
+	for _, pos := range positions {
+		if seen[pos.UUID] || ctx.Err() != nil {
+			continue
+		}
+		seen[pos.UUID] = true
+
+		divergence, err := d.compareAnchoring(ctx, park, pos, tallies, now)
+		if err != nil {
+			logger.Error("Shadow anchoring analysis failed", "vessel_uuid", pos.UUID, "error", err)
+			continue
+		}
+		if divergence != nil {
+			divergences = append(divergences, *divergence)
+		}
+	}
+
+	for _, divergence := range divergences {
+		logger.Info("Shadow detection diverged", "check", divergence.Check, "vessel_uuid", divergence.VesselUUID,
+			"live", divergence.LiveMatched, "shadow", divergence.ShadowMatched, "shadow_reason", divergence.ShadowReason)
+	}
+
+	err = d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, tally := range tallies {
+			if err := tx.Create(tally).Error; err != nil {
+				return err
+			}
+		}
+		if len(divergences) > 0 {
+			return tx.Create(&divergences).Error
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Error("Failed to store shadow detection results", "error", err)
+	}
+}
+
+// compareAnchoring classifies a vessel as anchored or not with the live and
+// the shadow thresholds, from the positions stored in the park
+func (d *ShadowDetector) compareAnchoring(ctx context.Context, park *Park, pos models.VesselPosition, tallies shadowTallies, now time.Time) (*models.ShadowDivergence, error) {
+	lookback := d.live.LookbackWindow
+	if d.config.Anchoring.LookbackWindow > lookback {
+		lookback = d.config.Anchoring.LookbackWindow
+	}
+
+	recent, err := d.vesselRepo.GetRecentPositions(ctx, park.Record.ID, pos.UUID, now.Add(-lookback))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load recent positions: %w", err)
+	}
+
+	liveAnchored, liveReason := anchoringDecision(recent, d.live, now)
+	shadowAnchored, shadowReason := anchoringDecision(recent, d.config.Anchoring, now)
+	if !tallies.add(park.Record.ID, models.ShadowCheckAnchoring, liveAnchored, shadowAnchored, now) {
+		return nil, nil
+	}
+
+	return &models.ShadowDivergence{
+		ParkID:        park.Record.ID,
+		VesselUUID:    pos.UUID,
+		Check:         models.ShadowCheckAnchoring,
+		LiveMatched:   liveAnchored,
+		ShadowMatched: shadowAnchored,
+		Latitude:      pos.Latitude,
+		Longitude:     pos.Longitude,
+		Speed:         pos.Speed,
+		LiveReason:    liveReason,
+		ShadowReason:  shadowReason,
+		DetectedAt:    now,
+	}, nil
+}
+
+// anchoringDecision applies the anchoring thresholds to positions in ascending
+// order, as the anchoring detector does, and explains the outcome
+func anchoringDecision(positions []models.VesselPositionRecord, config AnchoringConfig, now time.Time) (bool, string) {
+	since := now.Add(-config.LookbackWindow)
+	start := len(positions)
+	for start > 0 && !positions[start-1].RecordedAt.Before(since) {
+		start--
+	}
+
+	run := findStationaryRun(positions[start:], config)
+	anchored := len(run.positions) >= config.MinPositions
+	reason := fmt.Sprintf("%d stationary positions (at most %.1f kn within %.0f m), %d required",
+		len(run.positions), config.MaxSpeedKnots, config.MaxDriftMeters, config.MinPositions)
+	return anchored, reason
+}
+
+// detectionSettings describes one version of the detection parameters
+func detectionSettings(speedLimit float64, anchoring AnchoringConfig) models.DetectionSettings {
+	return models.DetectionSettings{
+		SpeedLimitKnots:         speedLimit,
+		AnchoringMaxSpeedKnots:  anchoring.MaxSpeedKnots,
+		AnchoringMaxDriftMeters: anchoring.MaxDriftMeters,
+		AnchoringMinPositions:   anchoring.MinPositions,
+		AnchoringLookback:       anchoring.LookbackWindow.String(),
+	}
+}
+
+// Report compares the shadow logic with the live logic in a park between
+// start and end, listing up to limit of the most recent divergences
+func (d *ShadowDetector) Report(park *Park, start, end time.Time, limit int) (*models.ShadowReport, error) {
+	report := &models.ShadowReport{
+		Park:        park.Record,
+		Start:       start,
+		End:         end,
+		Active:      d.Active(time.Now()),
+		Until:       d.config.Until,
+		Live:        detectionSettings(parkSpeedLimit, d.live),
+		Shadow:      detectionSettings(d.config.SpeedLimitKnots, d.config.Anchoring),
+		Checks:      []models.ShadowCheckSummary{},
+		Divergences: []models.ShadowDivergence{},
+	}
+
+	var rows []struct {
+		CheckName     string
+		Evaluated     int64
+		LiveMatched   int64
+		ShadowMatched int64
+		LiveOnly      int64
+		ShadowOnly    int64
+	}
+	err := d.db.Model(&models.ShadowTally{}).
+		Select("check_name, SUM(evaluated) AS evaluated, SUM(live_matched) AS live_matched, SUM(shadow_matched) AS shadow_matched, SUM(live_only) AS live_only, SUM(shadow_only) AS shadow_only").
+		Where("park_id = ? AND recorded_at >= ? AND recorded_at < ?", park.Record.ID, start, end).
+		Group("check_name").
+		Order("check_name").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum shadow tallies: %w", err)
+	}
+
+	for _, row := range rows {
+		summary := models.ShadowCheckSummary{
+			Check:         row.CheckName,
+			Evaluated:     row.Evaluated,
+			LiveMatched:   row.LiveMatched,
+			ShadowMatched: row.ShadowMatched,
+			LiveOnly:      row.LiveOnly,
+			ShadowOnly:    row.ShadowOnly,
+		}
+		if row.Evaluated > 0 {
+			rate := float64(row.Evaluated-row.LiveOnly-row.ShadowOnly) / float64(row.Evaluated)
+			summary.AgreementRate = &rate
+		}
+		report.Checks = append(report.Checks, summary)
+	}
+
+	err = d.db.Where("park_id = ? AND detected_at >= ? AND detected_at < ?", park.Record.ID, start, end).
+		Order("detected_at DESC, id DESC").
+		Limit(limit).
+		Find(&report.Divergences).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load shadow divergences: %w", err)
+	}
+
+	return report, nil
+}
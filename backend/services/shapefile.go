@@ -0,0 +1,309 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Shapefile shape types; the Z and M variants (+10, +20) carry the same x/y
+// layout followed by measures this parser ignores
+const (
+	shapeNull       = 0
+	shapePoint      = 1
+	shapePolyLine   = 3
+	shapePolygon    = 5
+	shapeMultiPoint = 8
+)
+
+// ParseShapefileToGeoJSON reads an ESRI Shapefile: the geometries of the .shp
+// file, the attributes of the .dbf file next to it and the coordinate system
+// of the .prj file. Polygons with several outer rings and multipart lines
+// become one feature per part, as KMZ multi-geometries do.
+func ParseShapefileToGeoJSON(shpPath string) (*GeoJSON, error) {
+	data, err := os.ReadFile(shpPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read shapefile: %w", err)
+	}
+	if len(data) < 100 || binary.BigEndian.Uint32(data[0:4]) != 9994 {
+		return nil, fmt.Errorf("%s is not a shapefile", shpPath)
+	}
+
+	base := strings.TrimSuffix(shpPath, filepath.Ext(shpPath))
+
+	var records []map[string]interface{}
+	if dbf, err := os.ReadFile(sidecarPath(base, ".dbf")); err == nil {
+		records, err = parseDBF(dbf)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read shapefile attributes: %w", err)
+		}
+	}
+
+	var project projection
+	if prj, err := os.ReadFile(sidecarPath(base, ".prj")); err == nil {
+		project, err = parseProjection(string(prj))
+		if err != nil {
+			return nil, fmt.Errorf("unsupported shapefile coordinate system: %w", err)
+		}
+	} else {
+		var bbox [4]float64
+		for i := range bbox {
+			bbox[i] = math.Float64frombits(binary.LittleEndian.Uint64(data[36+8*i:]))
+		}
+		if project, err = guessProjection(bbox); err != nil {
+			return nil, err
+		}
+	}
+
+	geoJSON := &GeoJSON{
+		Type:     "FeatureCollection",
+		Features: []Feature{},
+	}
+
+	for offset, index := 100, 0; offset+8 <= len(data); index++ {
+		length := int(binary.BigEndian.Uint32(data[offset+4:])) * 2
+		start := offset + 8
+		offset = start + length
+		if offset > len(data) {
+			return nil, fmt.Errorf("shapefile record %d is truncated", index+1)
+		}
+
+		var attributes map[string]interface{}
+		if index < len(records) {
+			attributes = records[index]
+			if attributes == nil {
+				continue // deleted record
+			}
+		}
+		features, err := shapeFeatures(data[start:offset], habitatProperties(attributes), project)
+		if err != nil {
+			return nil, fmt.Errorf("shapefile record %d: %w", index+1, err)
+		}
+		geoJSON.Features = append(geoJSON.Features, features...)
+	}
+
+	return geoJSON, nil
+}
+
+// shapeFeatures converts one shapefile record to features
+func shapeFeatures(content []byte, properties map[string]interface{}, project projection) ([]Feature, error) {
+	if len(content) < 4 {
+		return nil, fmt.Errorf("record is too short")
+	}
+	shapeType := int(binary.LittleEndian.Uint32(content))
+	if shapeType != shapeNull {
+		shapeType %= 10
+	}
+
+	readPoints := func(offset, count int) ([][]float64, error) {
+		if count < 0 || offset+16*count > len(content) {
+			return nil, fmt.Errorf("record is truncated")
+		}
+		points := make([][]float64, count)
+		for i := range points {
+			x := math.Float64frombits(binary.LittleEndian.Uint64(content[offset+16*i:]))
+			y := math.Float64frombits(binary.LittleEndian.Uint64(content[offset+16*i+8:]))
+			points[i] = []float64{x, y}
+		}
+		return points, projectPoints(points, project)
+	}
+
+	var features []Feature
+	add := func(geometryType string, coordinates interface{}) error {
+		feature, err := habitatFeature(properties, geometryType, coordinates)
+		if err != nil {
+			return err
+		}
+		features = append(features, feature)
+		return nil
+	}
+
+	switch shapeType {
+	case shapeNull:
+		return nil, nil
+
+	case shapePoint:
+		points, err := readPoints(4, 1)
+		if err != nil {
+			return nil, err
+		}
+		return features, add("Point", points[0])
+
+	case shapeMultiPoint:
+		if len(content) < 40 {
+			return nil, fmt.Errorf("record is truncated")
+		}
+		points, err := readPoints(40, int(int32(binary.LittleEndian.Uint32(content[36:]))))
+		if err != nil {
+			return nil, err
+		}
+		for _, point := range points {
+			if err := add("Point", point); err != nil {
+				return nil, err
+			}
+		}
+		return features, nil
+
+	case shapePolyLine, shapePolygon:
+		if len(content) < 44 {
+			return nil, fmt.Errorf("record is truncated")
+		}
+		numParts := int(int32(binary.LittleEndian.Uint32(content[36:])))
+		numPoints := int(int32(binary.LittleEndian.Uint32(content[40:])))
+		if numParts < 0 || 44+4*numParts > len(content) {
+			return nil, fmt.Errorf("record is truncated")
+		}
+		points, err := readPoints(44+4*numParts, numPoints)
+		if err != nil {
+			return nil, err
+		}
+
+		parts := make([][][]float64, 0, numParts)
+		for i := 0; i < numParts; i++ {
+			from := int(binary.LittleEndian.Uint32(content[44+4*i:]))
+			to := numPoints
+			if i+1 < numParts {
+				to = int(binary.LittleEndian.Uint32(content[44+4*(i+1):]))
+			}
+			if from < 0 || from > to || to > numPoints {
+				return nil, fmt.Errorf("invalid part index")
+			}
+			parts = append(parts, points[from:to])
+		}
+
+		if shapeType == shapePolyLine {
+			for _, part := range parts {
+				if err := add("LineString", part); err != nil {
+					return nil, err
+				}
+			}
+			return features, nil
+		}
+		for _, polygon := range groupRings(parts) {
+			if err := add("Polygon", polygon); err != nil {
+				return nil, err
+			}
+		}
+		return features, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported shape type %d", shapeType)
+	}
+}
+
+// dbfField is a column of a dBASE table
+type dbfField struct {
+	name     string
+	kind     byte
+	length   int
+	decimals int
+}
+
+// parseDBF reads the records of a dBASE III table, the attribute store of a
+// shapefile. Deleted records are returned as nil so record numbers still
+// line up with the shapes. Text that is not UTF-8 is read as Latin-1, the
+// usual encoding of older Italian and European data sets.
+func parseDBF(data []byte) ([]map[string]interface{}, error) {
+	if len(data) < 32 {
+		return nil, fmt.Errorf("dBASE header is truncated")
+	}
+	numRecords := int(binary.LittleEndian.Uint32(data[4:]))
+	headerLength := int(binary.LittleEndian.Uint16(data[8:]))
+	recordLength := int(binary.LittleEndian.Uint16(data[10:]))
+	if headerLength > len(data) {
+		return nil, fmt.Errorf("dBASE header is truncated")
+	}
+
+	var fields []dbfField
+	for offset := 32; offset+32 <= headerLength && data[offset] != 0x0D; offset += 32 {
+		name := data[offset : offset+11]
+		if end := bytes.IndexByte(name, 0); end >= 0 {
+			name = name[:end]
+		}
+		fields = append(fields, dbfField{
+			name:     decodeDBFText(name),
+			kind:     data[offset+11],
+			length:   int(data[offset+16]),
+			decimals: int(data[offset+17]),
+		})
+	}
+
+	records := make([]map[string]interface{}, 0, numRecords)
+	for i := 0; i < numRecords; i++ {
+		start := headerLength + i*recordLength
+		if start+recordLength > len(data) {
+			return nil, fmt.Errorf("dBASE record %d is truncated", i+1)
+		}
+		record := data[start : start+recordLength]
+		if record[0] == '*' {
+			records = append(records, nil)
+			continue
+		}
+
+		attributes := make(map[string]interface{}, len(fields))
+		offset := 1
+		for _, field := range fields {
+			if offset+field.length > len(record) {
+				return nil, fmt.Errorf("dBASE record %d is truncated", i+1)
+			}
+			raw := strings.TrimSpace(decodeDBFText(record[offset : offset+field.length]))
+			offset += field.length
+			attributes[field.name] = dbfValue(field, raw)
+		}
+		records = append(records, attributes)
+	}
+	return records, nil
+}
+
+// dbfValue converts a field's text to a JSON value, nil when empty
+func dbfValue(field dbfField, raw string) interface{} {
+	if raw == "" {
+		return nil
+	}
+	switch field.kind {
+	case 'N', 'F':
+		if field.decimals == 0 {
+			if n, err := strconv.ParseInt(raw, 10, 64); err == nil {
+				return n
+			}
+		}
+		if f, err := strconv.ParseFloat(raw, 64); err == nil {
+			return f
+		}
+		return nil
+	case 'L':
+		switch raw {
+		case "T", "t", "Y", "y":
+			return true
+		case "F", "f", "N", "n":
+			return false
+		}
+		return nil
+	case 'D':
+		if t, err := time.Parse("20060102", raw); err == nil {
+			return t.Format("2006-01-02")
+		}
+		return raw
+	default:
+		return raw
+	}
+}
+
+// decodeDBFText returns UTF-8 text, converting Latin-1 bytes
+func decodeDBFText(b []byte) string {
+	if utf8.Valid(b) {
+		return string(b)
+	}
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
@@ -0,0 +1,198 @@
+package services
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+	"vessel-tracker/models"
+)
+
+// DefaultMapZoom is the zoom level the web app opens a park's map at when its
+// site configuration sets none
+const DefaultMapZoom = 10
+
+// Features of the web app that a deployment may or may not offer
+const (
+	FeatureBufferZone    = "buffer_zone"
+	FeaturePosidonia     = "posidonia"
+	FeatureHabitatLayers = "habitat_layers"
+	FeatureExports       = "exports"
+	FeatureAISReceiver   = "ais_receiver"
+	FeatureLandMask      = "land_mask"
+)
+
+var siteFeatures = map[string]bool{
+	FeatureBufferZone:    true,
+	FeaturePosidonia:     true,
+	FeatureHabitatLayers: true,
+	FeatureExports:       true,
+	FeatureAISReceiver:   true,
+	FeatureLandMask:      true,
+}
+
+// SiteConfig is how the web app presents a park, so one frontend build can
+// serve every deployment. Features override those detected from the
+// deployment, e.g. to hide posidonia meadows a park has no survey of.
+type SiteConfig struct {
+	Title    string          `json:"title,omitempty"` // defaults to the park name
+	LogoURL  string          `json:"logo_url,omitempty"`
+	MapZoom  int             `json:"map_zoom,omitempty"` // defaults to DefaultMapZoom
+	Contact  SiteContact     `json:"contact"`
+	Features map[string]bool `json:"features,omitempty"`
+}
+
+// SiteContact is who the public can reach about a park
+type SiteContact struct {
+	Organization string `json:"organization,omitempty"`
+	Email        string `json:"email,omitempty"`
+	Phone        string `json:"phone,omitempty"`
+	URL          string `json:"url,omitempty"`
+}
+
+// validate checks the site configuration of a park
+func (c SiteConfig) validate() error {
+	if c.MapZoom < 0 || c.MapZoom > 22 {
+		return fmt.Errorf("site map_zoom must be between 1 and 22, or 0 for the default")
+	}
+	for name := range c.Features {
+		if !siteFeatures[name] {
+			return fmt.Errorf("unknown site feature %q", name)
+		}
+	}
+	return nil
+}
+
+// MapView is the view the map opens on. Bounds are minLon, minLat, maxLon,
+// maxLat, the park boundaries to fit the view to.
+type MapView struct {
+	Latitude  float64   `json:"latitude"`
+	Longitude float64   `json:"longitude"`
+	Zoom      int       `json:"zoom"`
+	Bounds    []float64 `json:"bounds"`
+}
+
+// SiteLayer is a GeoJSON map layer the web app can show
+type SiteLayer struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Kind string `json:"kind"` // park, buffer, posidonia, closure, other or land
+	URL  string `json:"url"`
+}
+
+// SiteInfo is what the web app needs to present a park
+type SiteInfo struct {
+	Park     string          `json:"park"`
+	Name     string          `json:"name"`
+	Title    string          `json:"title"`
+	LogoURL  string          `json:"logo_url,omitempty"`
+	Map      MapView         `json:"map"`
+	Layers   []SiteLayer     `json:"layers"`
+	Contact  SiteContact     `json:"contact"`
+	Features map[string]bool `json:"features"`
+}
+
+// SiteService describes the monitored parks to the web app
+type SiteService struct {
+	posidonia     *PosidoniaLayer
+	habitatLayers *HabitatLayerService
+	features      map[string]bool // detected for the whole deployment
+}
+
+// NewSiteService takes the features that depend on the deployment rather
+// than the park, such as FeatureAISReceiver
+func NewSiteService(posidonia *PosidoniaLayer, habitatLayers *HabitatLayerService, features map[string]bool) *SiteService {
+	return &SiteService{
+		posidonia:     posidonia,
+		habitatLayers: habitatLayers,
+		features:      features,
+	}
+}
+
+// Site returns the presentation of a park, with the layers available now
+func (s *SiteService) Site(park *Park) (*SiteInfo, error) {
+	site := park.Site
+	slug := url.QueryEscape(park.Record.Slug)
+
+	info := &SiteInfo{
+		Park:     park.Record.Slug,
+		Name:     park.Record.Name,
+		Title:    site.Title,
+		LogoURL:  site.LogoURL,
+		Contact:  site.Contact,
+		Features: make(map[string]bool, len(siteFeatures)),
+		Layers: []SiteLayer{{
+			ID: LayerPark, Name: "Park boundaries", Kind: LayerPark,
+			URL: "/api/park-boundaries?park=" + slug,
+		}},
+	}
+	if info.Title == "" {
+		info.Title = park.Record.Name
+	}
+
+	lat, lon := park.Geo.GetParkCenter()
+	minLat, minLon, maxLat, maxLon := park.Geo.GetParkBounds()
+	info.Map = MapView{
+		Latitude:  lat,
+		Longitude: lon,
+		Zoom:      site.MapZoom,
+		Bounds:    []float64{minLon, minLat, maxLon, maxLat},
+	}
+	if info.Map.Zoom == 0 {
+		info.Map.Zoom = DefaultMapZoom
+	}
+
+	for name, enabled := range s.features {
+		info.Features[name] = enabled
+	}
+	info.Features[FeatureBufferZone] = park.Geo.BufferZoneAvailable()
+	info.Features[FeaturePosidonia] = s.posidonia.Available()
+	info.Features[FeatureExports] = !park.Record.RestrictExports
+	info.Features[FeatureLandMask] = park.Geo.LandMask() != nil
+
+	layers, err := s.habitatLayers.List(park.Record.ID, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list habitat layers: %w", err)
+	}
+	now := time.Now()
+	var habitat []SiteLayer
+	for _, layer := range layers {
+		if (LoadedHabitatLayer{Layer: layer}).activeAt(now) {
+			habitat = append(habitat, SiteLayer{
+				ID:   fmt.Sprintf("habitat-%d", layer.ID),
+				Name: layer.Name,
+				Kind: layer.Kind,
+				URL:  fmt.Sprintf("/api/layers/%d", layer.ID),
+			})
+		}
+	}
+	info.Features[FeatureHabitatLayers] = len(habitat) > 0
+
+	for name, enabled := range site.Features {
+		info.Features[name] = enabled
+	}
+
+	// Layers of disabled features are left out
+	if info.Features[FeatureBufferZone] {
+		info.Layers = append(info.Layers, SiteLayer{
+			ID: LayerBuffer, Name: "Buffer zone", Kind: LayerBuffer,
+			URL: "/api/buffered-boundaries?park=" + slug,
+		})
+	}
+	if info.Features[FeaturePosidonia] {
+		info.Layers = append(info.Layers, SiteLayer{
+			ID: "posidonia", Name: "Posidonia oceanica", Kind: models.HabitatKindPosidonia,
+			URL: "/api/posidonia",
+		})
+	}
+	if info.Features[FeatureHabitatLayers] {
+		info.Layers = append(info.Layers, habitat...)
+	}
+	if info.Features[FeatureLandMask] {
+		info.Layers = append(info.Layers, SiteLayer{
+			ID: "land", Name: "Land", Kind: "land",
+			URL: "/api/land-mask?park=" + slug,
+		})
+	}
+
+	return info, nil
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	geojson "github.com/paulmach/go.geojson"
+)
+
+// StaticPayload is a large, rarely changing response serialized once when it
+// is loaded, so serving it costs no marshaling or compression per request.
+// The slices are shared between requests and must not be modified.
+type StaticPayload struct {
+	JSON         []byte
+	Gzip         []byte
+	ETag         string
+	LastModified time.Time // modification time of the source, to the second
+}
+
+// newStaticPayload gzips encoded JSON at the best compression level, which
+// only has to be paid for on load
+func newStaticPayload(data []byte, lastModified time.Time) (*StaticPayload, error) {
+	var compressed bytes.Buffer
+	writer, err := gzip.NewWriterLevel(&compressed, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(data); err != nil {
+		return nil, fmt.Errorf("failed to compress payload: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress payload: %w", err)
+	}
+
+	sum := sha256.Sum256(data)
+
+	return &StaticPayload{
+		JSON:         data,
+		Gzip:         compressed.Bytes(),
+		ETag:         `"` + hex.EncodeToString(sum[:16]) + `"`,
+		LastModified: lastModified.UTC().Truncate(time.Second),
+	}, nil
+}
+
+// newBoundaryPayload encodes a boundary layer as compact JSON
+func newBoundaryPayload(fc *geojson.FeatureCollection, lastModified time.Time) (*StaticPayload, error) {
+	data, err := json.Marshal(fc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode boundaries: %w", err)
+	}
+	return newStaticPayload(data, lastModified)
+}
+
+// fileStamp identifies a version of a file on disk, so a cache built from it
+// can tell when it was rewritten
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// statFile returns the current stamp of the file at path
+func statFile(path string) (fileStamp, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}, err
+	}
+	return fileStamp{modTime: info.ModTime(), size: info.Size()}, nil
+}
@@ -0,0 +1,596 @@
+package services
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+	"vessel-tracker/database"
+	"vessel-tracker/models"
+
+	"gorm.io/gorm"
+)
+
+// maxDwellGap is the longest gap between two fixes that is still counted as
+// continuous presence. Larger gaps mean the vessel was out of coverage.
+const maxDwellGap = 90 * time.Minute
+
+type StatsService struct {
+	db       *gorm.DB
+	calendar *PeakCalendar
+}
+
+func NewStatsService(calendar *PeakCalendar) *StatsService {
+	return &StatsService{
+		db:       database.GetDB(),
+		calendar: calendar,
+	}
+}
+
+// Calendar returns the calendar peak comparisons are made with
+func (s *StatsService) Calendar() *PeakCalendar {
+	return s.calendar
+}
+
+// positionSample is the subset of position columns needed for aggregations
+type positionSample struct {
+	VesselUUID string
+	Latitude   float64
+	Longitude  float64
+	IsInPark   bool
+	RecordedAt time.Time
+}
+
+// forEachPosition streams the positions recorded in a park in a period
+// ordered by vessel and time
+func (s *StatsService) forEachPosition(parkID uint, startTime, endTime time.Time, fn func(positionSample)) error {
+	rows, err := s.db.Model(&models.VesselPositionRecord{}).
+		Select("vessel_uuid, latitude, longitude, is_in_park, recorded_at").
+		Where("park_id = ? AND recorded_at BETWEEN ? AND ?", parkID, startTime, endTime).
+		Order("vessel_uuid, recorded_at").
+		Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var sample positionSample
+		if err := s.db.ScanRows(rows, &sample); err != nil {
+			return err
+		}
+		fn(sample)
+	}
+
+	return rows.Err()
+}
+
+// GetDwellTimes aggregates per vessel the time spent in a park and its buffer
+// zone. Each interval between consecutive fixes is attributed to the zone of
+// the earlier fix. sortBy is "park" or "buffer".
+func (s *StatsService) GetDwellTimes(park *Park, startTime, endTime time.Time, sortBy string, limit int) ([]models.VesselDwellTime, error) {
+	dwell := make(map[string]*models.VesselDwellTime)
+	var previous *positionSample
+
+	err := s.forEachPosition(park.Record.ID, startTime, endTime, func(sample positionSample) {
+		entry, ok := dwell[sample.VesselUUID]
+		if !ok {
+			entry = &models.VesselDwellTime{VesselUUID: sample.VesselUUID}
+			dwell[sample.VesselUUID] = entry
+		}
+		entry.PositionCount++
+
+		if previous != nil && previous.VesselUUID == sample.VesselUUID {
+			gap := sample.RecordedAt.Sub(previous.RecordedAt)
+			if gap > 0 && gap <= maxDwellGap {
+				if previous.IsInPark {
+					entry.ParkMinutes += gap.Minutes()
+				}
+				if park.Geo.IsPointInBufferZone(previous.Latitude, previous.Longitude) {
+					entry.BufferMinutes += gap.Minutes()
+				}
+			}
+		}
+
+		current := sample
+		previous = &current
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]models.VesselDwellTime, 0, len(dwell))
+	uuids := make([]string, 0, len(dwell))
+	for uuid, entry := range dwell {
+		if entry.ParkMinutes == 0 && entry.BufferMinutes == 0 {
+			continue
+		}
+		results = append(results, *entry)
+		uuids = append(uuids, uuid)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if sortBy == "buffer" {
+			return results[i].BufferMinutes > results[j].BufferMinutes
+		}
+		return results[i].ParkMinutes > results[j].ParkMinutes
+	})
+
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+
+	// Attach vessel identity for the returned rows only
+	var vessels []models.VesselRecord
+	if len(uuids) > 0 {
+		if err := s.db.Where("uuid IN ?", uuids).Find(&vessels).Error; err != nil {
+			return nil, err
+		}
+	}
+	byUUID := make(map[string]models.VesselRecord, len(vessels))
+	for _, vessel := range vessels {
+		byUUID[vessel.UUID] = vessel
+	}
+	for i := range results {
+		if vessel, ok := byUUID[results[i].VesselUUID]; ok {
+			results[i].Name = vessel.Name
+			results[i].MMSI = vessel.MMSI
+			results[i].Type = vessel.Type
+		}
+	}
+
+	return results, nil
+}
+
+// movementLookback is how far before a log's period positions are read, to
+// know which vessels were already inside and since when
+const movementLookback = 48 * time.Hour
+
+// GetMovements lists the entries into and exits from a park between start and
+// end, oldest first. A vessel enters at its first fix inside the park and
+// leaves at its first fix outside; a vessel inside the park that is not heard
+// for longer than maxDwellGap is taken to have left at its last fix.
+func (s *StatsService) GetMovements(park *Park, start, end time.Time) ([]models.ParkMovement, error) {
+	movements := []models.ParkMovement{}
+	now := time.Now()
+
+	var last *positionSample
+	var enteredAt *time.Time
+	inPeriod := func(t time.Time) bool { return !t.Before(start) && t.Before(end) }
+
+	// exit closes the visit of the vessel of the last fix
+	exit := func(at positionSample, signalLost bool) {
+		if inPeriod(at.RecordedAt) {
+			movement := models.ParkMovement{
+				Time:       at.RecordedAt,
+				Kind:       models.MovementExit,
+				VesselUUID: at.VesselUUID,
+				Latitude:   at.Latitude,
+				Longitude:  at.Longitude,
+				EnteredAt:  enteredAt,
+				SignalLost: signalLost,
+			}
+			if enteredAt != nil {
+				dwell := at.RecordedAt.Sub(*enteredAt).Minutes()
+				movement.DwellMinutes = &dwell
+			}
+			movements = append(movements, movement)
+		}
+		enteredAt = nil
+	}
+
+	// Fixes up to maxDwellGap after the period tell whether a vessel last
+	// heard inside near its end had left
+	inside := false
+	err := s.forEachPosition(park.Record.ID, start.Add(-movementLookback), end.Add(maxDwellGap), func(sample positionSample) {
+		first := last == nil || last.VesselUUID != sample.VesselUUID
+		switch {
+		case inside && first:
+			if last.RecordedAt.Add(maxDwellGap).Before(now) {
+				exit(*last, true)
+			}
+			enteredAt = nil
+			inside = false
+		case inside && sample.RecordedAt.Sub(last.RecordedAt) > maxDwellGap:
+			exit(*last, true)
+			inside = false
+		}
+
+		switch {
+		case sample.IsInPark && !inside:
+			inside = true
+			// A vessel first heard inside before the period may have entered
+			// before the lookback began, so its visit has no known start
+			if !first || !sample.RecordedAt.Before(start) {
+				entered := sample.RecordedAt
+				enteredAt = &entered
+			}
+			if inPeriod(sample.RecordedAt) {
+				movements = append(movements, models.ParkMovement{
+					Time:       sample.RecordedAt,
+					Kind:       models.MovementEntry,
+					VesselUUID: sample.VesselUUID,
+					Latitude:   sample.Latitude,
+					Longitude:  sample.Longitude,
+				})
+			}
+		case !sample.IsInPark && inside:
+			exit(sample, false)
+			inside = false
+		}
+
+		current := sample
+		last = &current
+	})
+	if err != nil {
+		return nil, err
+	}
+	// The last vessel only counts as gone once the gap has passed
+	if inside && last.RecordedAt.Add(maxDwellGap).Before(now) {
+		exit(*last, true)
+	}
+
+	sort.SliceStable(movements, func(i, j int) bool {
+		return movements[i].Time.Before(movements[j].Time)
+	})
+
+	uuids := make([]string, 0, len(movements))
+	for _, movement := range movements {
+		uuids = append(uuids, movement.VesselUUID)
+	}
+	var vessels []models.VesselRecord
+	if len(uuids) > 0 {
+		if err := s.db.Where("uuid IN ?", uuids).Find(&vessels).Error; err != nil {
+			return nil, err
+		}
+	}
+	byUUID := make(map[string]models.VesselRecord, len(vessels))
+	for _, vessel := range vessels {
+		byUUID[vessel.UUID] = vessel
+	}
+	for i := range movements {
+		if vessel, ok := byUUID[movements[i].VesselUUID]; ok {
+			movements[i].Name = vessel.Name
+			movements[i].MMSI = vessel.MMSI
+			movements[i].IMO = vessel.IMO
+			movements[i].Callsign = vessel.Callsign
+			movements[i].Type = vessel.Type
+			movements[i].CountryISO = vessel.CountryISO
+		}
+	}
+
+	return movements, nil
+}
+
+// ComparePeak splits the traffic and violations of a park into peak and
+// off-peak days of the calendar, over the whole UTC days from start to end.
+// Days without any recorded position count as days with no traffic.
+func (s *StatsService) ComparePeak(park *Park, start, end time.Time) (*models.PeakComparison, error) {
+	start = start.UTC().Truncate(24 * time.Hour)
+	if truncated := end.UTC().Truncate(24 * time.Hour); truncated.Before(end) {
+		end = truncated.Add(24 * time.Hour)
+	}
+
+	comparison := &models.PeakComparison{
+		Start:   start,
+		End:     end,
+		Peak:    models.PeakSegment{ViolationsByType: make(map[string]int64)},
+		OffPeak: models.PeakSegment{ViolationsByType: make(map[string]int64)},
+		Days:    []models.PeakDay{},
+	}
+
+	index := make(map[string]int)
+	for day := start; day.Before(end); day = day.Add(24 * time.Hour) {
+		reason, peak := s.calendar.PeakReason(day)
+		date := day.Format("2006-01-02")
+		index[date] = len(comparison.Days)
+		comparison.Days = append(comparison.Days, models.PeakDay{Date: date, Peak: peak, Reason: reason})
+	}
+
+	// Each vessel is counted once a day, as in the park if any of its fixes was
+	daily := make([]map[string]bool, len(comparison.Days))
+	err := s.forEachPosition(park.Record.ID, start, end, func(sample positionSample) {
+		i, ok := index[sample.RecordedAt.UTC().Format("2006-01-02")]
+		if !ok {
+			return
+		}
+		comparison.Days[i].Positions++
+		if daily[i] == nil {
+			daily[i] = make(map[string]bool)
+		}
+		daily[i][sample.VesselUUID] = daily[i][sample.VesselUUID] || sample.IsInPark
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i, vessels := range daily {
+		comparison.Days[i].Vessels, comparison.Days[i].VesselsInPark = countVessels(vessels)
+	}
+
+	var violations []struct {
+		Type       string
+		DetectedAt time.Time
+	}
+	err = s.db.Model(&models.Violation{}).
+		Select("type, detected_at").
+		Where("park_id = ? AND detected_at >= ? AND detected_at < ?", park.Record.ID, start, end).
+		Scan(&violations).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, violation := range violations {
+		i, ok := index[violation.DetectedAt.UTC().Format("2006-01-02")]
+		if !ok {
+			continue
+		}
+		comparison.Days[i].Violations++
+		segment := &comparison.OffPeak
+		if comparison.Days[i].Peak {
+			segment = &comparison.Peak
+		}
+		segment.ViolationsByType[violation.Type]++
+	}
+
+	var vesselDays, inParkDays [2]int
+	for _, day := range comparison.Days {
+		segment, k := &comparison.OffPeak, 0
+		if day.Peak {
+			segment, k = &comparison.Peak, 1
+		}
+		segment.Days++
+		segment.Positions += day.Positions
+		segment.Violations += day.Violations
+		vesselDays[k] += day.Vessels
+		inParkDays[k] += day.VesselsInPark
+	}
+	for k, segment := range []*models.PeakSegment{&comparison.OffPeak, &comparison.Peak} {
+		if segment.Days == 0 {
+			continue
+		}
+		days := float64(segment.Days)
+		segment.VesselsPerDay = float64(vesselDays[k]) / days
+		segment.VesselsInParkPerDay = float64(inParkDays[k]) / days
+		segment.ViolationsPerDay = float64(segment.Violations) / days
+	}
+
+	return comparison, nil
+}
+
+// heatmapMarginDegrees extends the park bounding box so traffic approaching
+// the park is included in the density grid
+const heatmapMarginDegrees = 0.05
+
+// heatmapRollupMinCell is the smallest heatmap cell, in meters, counted from
+// the hourly rollup of positions. Rolled up positions stand at the center of a
+// rollup cell, a few tens of meters from where they were recorded.
+const heatmapRollupMinCell = 500.0
+
+// GetHeatmap counts positions per grid cell of roughly cellMeters on a side
+// over a park's area, grouping in SQL so raw positions never leave the database
+func (s *StatsService) GetHeatmap(ctx context.Context, park *Park, startTime, endTime time.Time, cellMeters float64) ([]models.HeatmapCell, error) {
+	minLat, minLon, maxLat, maxLon := park.Geo.GetParkBounds()
+	minLat -= heatmapMarginDegrees
+	minLon -= heatmapMarginDegrees
+	maxLat += heatmapMarginDegrees
+	maxLon += heatmapMarginDegrees
+
+	centerLat := (minLat + maxLat) / 2
+	latStep := cellMeters / 111320.0
+	lonStep := cellMeters / (111320.0 * math.Cos(toRadians(centerLat)))
+
+	var rows []struct {
+		GridRow int64
+		GridCol int64
+		Count   int64
+		Vessels int64
+		InPark  int64
+	}
+
+	err := s.db.WithContext(ctx).Table("(?) AS samples", s.heatmapSamples(ctx, park.Record.ID, startTime, endTime, cellMeters)).
+		Select(`FLOOR((latitude - ?) / ?) AS grid_row,
+			FLOOR((longitude - ?) / ?) AS grid_col,
+			SUM(positions) AS count,
+			COUNT(DISTINCT vessel_uuid) AS vessels,
+			SUM(in_park) AS in_park`,
+			minLat, latStep, minLon, lonStep).
+		Where("latitude BETWEEN ? AND ? AND longitude BETWEEN ? AND ?", minLat, maxLat, minLon, maxLon).
+		Group("grid_row, grid_col").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	cells := make([]models.HeatmapCell, 0, len(rows))
+	for _, r := range rows {
+		cell := models.HeatmapCell{
+			Row:     r.GridRow,
+			Col:     r.GridCol,
+			MinLat:  minLat + float64(r.GridRow)*latStep,
+			MinLon:  minLon + float64(r.GridCol)*lonStep,
+			MaxLat:  minLat + float64(r.GridRow+1)*latStep,
+			MaxLon:  minLon + float64(r.GridCol+1)*lonStep,
+			Count:   r.Count,
+			Vessels: r.Vessels,
+		}
+		if r.Count > 0 {
+			cell.InParkPct = float64(r.InPark) / float64(r.Count) * 100
+		}
+		cells = append(cells, cell)
+	}
+
+	return cells, nil
+}
+
+// heatmapSamples selects the positions of a park in a period as rows of
+// vessel_uuid, latitude, longitude, positions and in_park. When the hourly
+// rollup can be used its rows stand for the whole hours of the period, and
+// only the partial hours at either end are read position by position.
+func (s *StatsService) heatmapSamples(ctx context.Context, parkID uint, startTime, endTime time.Time, cellMeters float64) *gorm.DB {
+	db := s.db.WithContext(ctx)
+	positions := func(query string, args ...interface{}) *gorm.DB {
+		return db.Model(&models.VesselPositionRecord{}).
+			Select("vessel_uuid, latitude, longitude, 1 AS positions, CASE WHEN is_in_park THEN 1 ELSE 0 END AS in_park").
+			Where("park_id = ?", parkID).
+			Where(query, args...)
+	}
+
+	from, to, ok := rollupHours(startTime, endTime)
+	if !ok || cellMeters < heatmapRollupMinCell {
+		return positions("recorded_at BETWEEN ? AND ?", startTime, endTime)
+	}
+
+	cell := database.PositionRollupCellDegrees
+	rollup := db.Table(database.PositionRollupView).
+		Select("vessel_uuid, (cell_row + 0.5) * ? AS latitude, (cell_col + 0.5) * ? AS longitude, positions, in_park", cell, cell).
+		Where("park_id = ? AND bucket >= ? AND bucket < ?", parkID, from, to)
+	return db.Raw("? UNION ALL ? UNION ALL ?",
+		rollup,
+		positions("recorded_at >= ? AND recorded_at < ?", startTime, from),
+		positions("recorded_at BETWEEN ? AND ?", to, endTime))
+}
+
+// rollupHours returns the whole hours of a period, from and to, that can be
+// read from the hourly rollup of positions, and false when there is no
+// rollup or no whole hour
+func rollupHours(start, end time.Time) (time.Time, time.Time, bool) {
+	if !database.TimescaleEnabled() {
+		return start, end, false
+	}
+	from := start.UTC().Truncate(database.PositionRollupBucket)
+	if from.Before(start) {
+		from = from.Add(database.PositionRollupBucket)
+	}
+	to := end.UTC().Truncate(database.PositionRollupBucket)
+	return from, to, from.Before(to)
+}
+
+// forEachOccupancySample streams the positions of a park in a period like
+// forEachPosition, reading the whole hours from the hourly rollup when the
+// intervals are made of whole hours. Rolled up positions stand at the center
+// of their rollup cell at the start of their hour, and samples arrive in no
+// particular order.
+func (s *StatsService) forEachOccupancySample(parkID uint, start, end time.Time, interval time.Duration, fn func(positionSample)) error {
+	from, to, ok := rollupHours(start, end)
+	if !ok || interval%database.PositionRollupBucket != 0 {
+		return s.forEachPosition(parkID, start, end, fn)
+	}
+
+	if start.Before(from) {
+		if err := s.forEachPosition(parkID, start, from, fn); err != nil {
+			return err
+		}
+	}
+
+	cell := database.PositionRollupCellDegrees
+	rows, err := s.db.Table(database.PositionRollupView).
+		Select("vessel_uuid, (cell_row + 0.5) * ? AS latitude, (cell_col + 0.5) * ? AS longitude, in_park > 0 AS is_in_park, bucket AS recorded_at", cell, cell).
+		Where("park_id = ? AND bucket >= ? AND bucket < ?", parkID, from, to).
+		Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var sample positionSample
+		if err := s.db.ScanRows(rows, &sample); err != nil {
+			return err
+		}
+		fn(sample)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	return s.forEachPosition(parkID, to, end, fn)
+}
+
+// MaxOccupancyIntervals bounds the length of an occupancy time series
+const MaxOccupancyIntervals = 5000
+
+// unknownVesselType groups vessels without a recorded type
+const unknownVesselType = "unknown"
+
+// GetOccupancy counts per interval the distinct vessels with a fix in a park
+// and in its buffer zone, in total and per vessel type. Intervals are aligned
+// to multiples of interval in UTC and cover start to end.
+func (s *StatsService) GetOccupancy(park *Park, start, end time.Time, interval time.Duration) ([]models.OccupancyInterval, error) {
+	start = start.UTC().Truncate(interval)
+	end = end.UTC()
+
+	type zones struct{ inPark, inBuffer bool }
+	count := int((end.Sub(start) + interval - 1) / interval)
+	occupants := make([]map[string]*zones, count)
+
+	err := s.forEachOccupancySample(park.Record.ID, start, end, interval, func(sample positionSample) {
+		i := int(sample.RecordedAt.Sub(start) / interval)
+		if i < 0 || i >= count {
+			return
+		}
+		if occupants[i] == nil {
+			occupants[i] = make(map[string]*zones)
+		}
+		z, ok := occupants[i][sample.VesselUUID]
+		if !ok {
+			z = &zones{}
+			occupants[i][sample.VesselUUID] = z
+		}
+		z.inPark = z.inPark || sample.IsInPark
+		z.inBuffer = z.inBuffer || park.Geo.IsPointInBufferZone(sample.Latitude, sample.Longitude)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	for _, vessels := range occupants {
+		for uuid := range vessels {
+			seen[uuid] = true
+		}
+	}
+	uuids := make([]string, 0, len(seen))
+	for uuid := range seen {
+		uuids = append(uuids, uuid)
+	}
+	types := make(map[string]string, len(uuids))
+	if len(uuids) > 0 {
+		var vessels []models.VesselRecord
+		if err := s.db.Select("uuid, type").Where("uuid IN ?", uuids).Find(&vessels).Error; err != nil {
+			return nil, err
+		}
+		for _, vessel := range vessels {
+			types[vessel.UUID] = vessel.Type
+		}
+	}
+
+	series := make([]models.OccupancyInterval, count)
+	for i := range series {
+		bucket := &series[i]
+		bucket.Start = start.Add(time.Duration(i) * interval)
+		bucket.End = bucket.Start.Add(interval)
+		bucket.ByType = make(map[string]models.OccupancyCount)
+
+		for uuid, z := range occupants[i] {
+			if !z.inPark && !z.inBuffer {
+				continue
+			}
+			vesselType := types[uuid]
+			if vesselType == "" {
+				vesselType = unknownVesselType
+			}
+			byType := bucket.ByType[vesselType]
+			if z.inPark {
+				bucket.InPark++
+				byType.InPark++
+			}
+			if z.inBuffer {
+				bucket.InBuffer++
+				byType.InBuffer++
+			}
+			bucket.ByType[vesselType] = byType
+		}
+	}
+
+	return series, nil
+}
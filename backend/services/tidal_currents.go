@@ -0,0 +1,356 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+	"vessel-tracker/logging"
+	"vessel-tracker/models"
+)
+
+// DefaultCurrentsPath is the file tidal current predictions are read from
+// when CURRENTS_FILE is not set
+var DefaultCurrentsPath = filepath.Join(".", "data", "currents.json")
+
+// DefaultCurrentsMaxDistanceKm is how far from the nearest prediction station
+// a position may be for a current to be attached to it
+const DefaultCurrentsMaxDistanceKm = 15.0
+
+// currentsMaxGap is the longest interval between two predictions of a
+// station that is interpolated across; tidal currents turn every few hours
+const currentsMaxGap = 3 * time.Hour
+
+// CurrentsConfig holds where tidal current predictions are read from and how
+// far they reach
+type CurrentsConfig struct {
+	Path              string
+	MaxDistanceMeters float64
+}
+
+func DefaultCurrentsConfig() CurrentsConfig {
+	return CurrentsConfig{
+		Path:              DefaultCurrentsPath,
+		MaxDistanceMeters: DefaultCurrentsMaxDistanceKm * 1000,
+	}
+}
+
+// LoadCurrentsConfig reads CURRENTS_FILE and CURRENTS_MAX_DISTANCE_KM,
+// falling back to the defaults when unset
+func LoadCurrentsConfig() (CurrentsConfig, error) {
+	config := DefaultCurrentsConfig()
+
+	if path := os.Getenv("CURRENTS_FILE"); path != "" {
+		config.Path = path
+	}
+	if value := os.Getenv("CURRENTS_MAX_DISTANCE_KM"); value != "" {
+		km, err := strconv.ParseFloat(value, 64)
+		if err != nil || km <= 0 {
+			return config, fmt.Errorf("invalid CURRENTS_MAX_DISTANCE_KM %q: must be a positive number", value)
+		}
+		config.MaxDistanceMeters = km * 1000
+	}
+
+	return config, nil
+}
+
+// CurrentPrediction is the current predicted at a station at one time
+type CurrentPrediction struct {
+	Time         time.Time `json:"time"`
+	SpeedKnots   float64   `json:"speed_knots"`
+	DirectionDeg float64   `json:"direction_deg"` // toward which the current flows, degrees true
+}
+
+// CurrentStation is a point tidal currents are predicted at, such as a
+// harmonic station of the hydrographic office or a cell of a current model
+type CurrentStation struct {
+	ID          string              `json:"id"`
+	Name        string              `json:"name,omitempty"`
+	Latitude    float64             `json:"lat"`
+	Longitude   float64             `json:"lon"`
+	Predictions []CurrentPrediction `json:"predictions"`
+}
+
+// CurrentPredictions is the file format of tidal current predictions
+type CurrentPredictions struct {
+	Source   string           `json:"source"`
+	Stations []CurrentStation `json:"stations"`
+}
+
+// Validate checks the stations and sorts their predictions by time
+func (p *CurrentPredictions) Validate() error {
+	if len(p.Stations) == 0 {
+		return fmt.Errorf("no stations")
+	}
+
+	ids := make(map[string]bool, len(p.Stations))
+	for i := range p.Stations {
+		station := &p.Stations[i]
+		if station.ID == "" {
+			return fmt.Errorf("station %d has no id", i+1)
+		}
+		if ids[station.ID] {
+			return fmt.Errorf("duplicate station id %q", station.ID)
+		}
+		ids[station.ID] = true
+
+		if station.Latitude < -90 || station.Latitude > 90 || station.Longitude < -180 || station.Longitude > 180 {
+			return fmt.Errorf("station %q: coordinates out of range", station.ID)
+		}
+		if len(station.Predictions) == 0 {
+			return fmt.Errorf("station %q has no predictions", station.ID)
+		}
+		for _, prediction := range station.Predictions {
+			if prediction.Time.IsZero() {
+				return fmt.Errorf("station %q: prediction without a time", station.ID)
+			}
+			if prediction.SpeedKnots < 0 {
+				return fmt.Errorf("station %q: negative speed at %s", station.ID, prediction.Time.Format(time.RFC3339))
+			}
+			if prediction.DirectionDeg < 0 || prediction.DirectionDeg >= 360 {
+				return fmt.Errorf("station %q: direction must be in [0, 360) at %s", station.ID, prediction.Time.Format(time.RFC3339))
+			}
+		}
+		sort.Slice(station.Predictions, func(a, b int) bool {
+			return station.Predictions[a].Time.Before(station.Predictions[b].Time)
+		})
+	}
+	return nil
+}
+
+// TidalCurrents predicts the tidal current at a position and time from the
+// predictions of nearby stations
+type TidalCurrents struct {
+	predictions       CurrentPredictions
+	maxDistanceMeters float64
+	lastModified      time.Time
+	start, end        time.Time
+}
+
+// NewTidalCurrents builds the current model from validated predictions.
+// Positions further than maxDistanceMeters from every station get no current.
+func NewTidalCurrents(predictions CurrentPredictions, maxDistanceMeters float64, lastModified time.Time) (*TidalCurrents, error) {
+	if err := predictions.Validate(); err != nil {
+		return nil, err
+	}
+
+	currents := &TidalCurrents{
+		predictions:       predictions,
+		maxDistanceMeters: maxDistanceMeters,
+		lastModified:      lastModified,
+	}
+	for _, station := range predictions.Stations {
+		first, last := station.Predictions[0].Time, station.Predictions[len(station.Predictions)-1].Time
+		if currents.start.IsZero() || first.Before(currents.start) {
+			currents.start = first
+		}
+		if last.After(currents.end) {
+			currents.end = last
+		}
+	}
+	return currents, nil
+}
+
+// currentVector splits a current into its east and north components
+func currentVector(speed, direction float64) (float64, float64) {
+	rad := direction * math.Pi / 180
+	return speed * math.Sin(rad), speed * math.Cos(rad)
+}
+
+// vectorCurrent joins east and north components into a current
+func vectorCurrent(east, north float64) models.TidalCurrent {
+	direction := math.Mod(math.Atan2(east, north)*180/math.Pi+360, 360)
+	return models.TidalCurrent{
+		SpeedKnots:   math.Round(math.Hypot(east, north)*100) / 100,
+		DirectionDeg: math.Round(direction*10) / 10,
+	}
+}
+
+// stationAt interpolates the current of a station at t, as components. It
+// fails outside the predicted period and across gaps longer than
+// currentsMaxGap.
+func stationAt(station CurrentStation, t time.Time) (float64, float64, bool) {
+	predictions := station.Predictions
+	i := sort.Search(len(predictions), func(i int) bool {
+		return !predictions[i].Time.Before(t)
+	})
+	if i == len(predictions) {
+		return 0, 0, false
+	}
+	after := predictions[i]
+	if after.Time.Equal(t) {
+		east, north := currentVector(after.SpeedKnots, after.DirectionDeg)
+		return east, north, true
+	}
+	if i == 0 {
+		return 0, 0, false
+	}
+
+	before := predictions[i-1]
+	span := after.Time.Sub(before.Time)
+	if span > currentsMaxGap {
+		return 0, 0, false
+	}
+	f := float64(t.Sub(before.Time)) / float64(span)
+
+	east0, north0 := currentVector(before.SpeedKnots, before.DirectionDeg)
+	east1, north1 := currentVector(after.SpeedKnots, after.DirectionDeg)
+	return east0 + (east1-east0)*f, north0 + (north1-north0)*f, true
+}
+
+// At returns the current predicted at a position and time, weighting the
+// stations in reach by inverse squared distance, or nil when no station
+// covers it. A nil model predicts nothing.
+func (c *TidalCurrents) At(lat, lon float64, t time.Time) *models.TidalCurrent {
+	if c == nil {
+		return nil
+	}
+
+	var east, north, weights float64
+	for _, station := range c.predictions.Stations {
+		distance := HaversineDistance(lat, lon, station.Latitude, station.Longitude)
+		if distance > c.maxDistanceMeters {
+			continue
+		}
+		stationEast, stationNorth, ok := stationAt(station, t)
+		if !ok {
+			continue
+		}
+
+		weight := 1 / math.Max(distance*distance, 1)
+		east += stationEast * weight
+		north += stationNorth * weight
+		weights += weight
+	}
+	if weights == 0 {
+		return nil
+	}
+
+	current := vectorCurrent(east/weights, north/weights)
+	return &current
+}
+
+// CurrentsStationStatus is the current predicted at a station at one time
+type CurrentsStationStatus struct {
+	ID        string               `json:"id"`
+	Name      string               `json:"name,omitempty"`
+	Latitude  float64              `json:"lat"`
+	Longitude float64              `json:"lon"`
+	Current   *models.TidalCurrent `json:"current"`
+}
+
+// StationsAt returns every station with its current at t, nil where t is
+// outside its predictions
+func (c *TidalCurrents) StationsAt(t time.Time) []CurrentsStationStatus {
+	stations := make([]CurrentsStationStatus, 0, len(c.predictions.Stations))
+	for _, station := range c.predictions.Stations {
+		status := CurrentsStationStatus{
+			ID:        station.ID,
+			Name:      station.Name,
+			Latitude:  station.Latitude,
+			Longitude: station.Longitude,
+		}
+		if east, north, ok := stationAt(station, t); ok {
+			current := vectorCurrent(east, north)
+			status.Current = &current
+		}
+		stations = append(stations, status)
+	}
+	return stations
+}
+
+// Source returns who produced the predictions
+func (c *TidalCurrents) Source() string {
+	return c.predictions.Source
+}
+
+// Span returns the first and last predicted time over all stations
+func (c *TidalCurrents) Span() (time.Time, time.Time) {
+	return c.start, c.end
+}
+
+// LastModified returns when the predictions were last written
+func (c *TidalCurrents) LastModified() time.Time {
+	return c.lastModified
+}
+
+// MaxDistanceMeters returns how far from a station its predictions apply
+func (c *TidalCurrents) MaxDistanceMeters() float64 {
+	return c.maxDistanceMeters
+}
+
+// LoadTidalCurrents reads the tidal current predictions in config.Path. When
+// the file does not exist it returns nil and no current is attached to
+// positions.
+func LoadTidalCurrents(config CurrentsConfig) (*TidalCurrents, error) {
+	logger := logging.Component("currents")
+
+	info, err := os.Stat(config.Path)
+	if os.IsNotExist(err) {
+		logger.Info("No tidal current predictions, positions get no current", "path", config.Path)
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read tidal currents: %w", err)
+	}
+
+	data, err := os.ReadFile(config.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tidal currents: %w", err)
+	}
+	var predictions CurrentPredictions
+	if err := json.Unmarshal(data, &predictions); err != nil {
+		return nil, fmt.Errorf("failed to parse tidal currents %s: %w", config.Path, err)
+	}
+
+	currents, err := NewTidalCurrents(predictions, config.MaxDistanceMeters, info.ModTime())
+	if err != nil {
+		return nil, fmt.Errorf("invalid tidal currents %s: %w", config.Path, err)
+	}
+
+	start, end := currents.Span()
+	logger.Info("Loaded tidal current predictions", "path", config.Path, "source", predictions.Source, "stations", len(predictions.Stations), "start", start, "end", end)
+	if time.Now().After(end) {
+		logger.Warn("Tidal current predictions have run out, positions get no current", "end", end)
+	}
+	return currents, nil
+}
+
+// WriteCurrentsFile saves tidal current predictions, replacing the file only
+// once it is completely written
+func WriteCurrentsFile(path string, predictions CurrentPredictions) error {
+	data, err := json.MarshalIndent(predictions, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode tidal currents: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create tidal currents directory: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write tidal currents: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write tidal currents: %w", err)
+	}
+	return nil
+}
+
+// ImportTidalCurrents replaces the tidal current predictions in config.Path
+// with validated ones and returns the new model. Predictions are only
+// written once they are valid.
+func ImportTidalCurrents(config CurrentsConfig, predictions CurrentPredictions) (*TidalCurrents, error) {
+	currents, err := NewTidalCurrents(predictions, config.MaxDistanceMeters, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if err := WriteCurrentsFile(config.Path, currents.predictions); err != nil {
+		return nil, err
+	}
+	return currents, nil
+}
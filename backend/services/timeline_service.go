@@ -0,0 +1,404 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"vessel-tracker/database"
+	"vessel-tracker/models"
+
+	"gorm.io/gorm"
+)
+
+// DefaultTimelineWindow is how far before and after an incident the vessel's
+// track is included in its timeline
+const DefaultTimelineWindow = 2 * time.Hour
+
+// MaxTimelineWindow bounds the window a timeline can be requested for
+const MaxTimelineWindow = 24 * time.Hour
+
+// timelinePositionLimit caps the positions of a timeline, keeping the ones
+// nearest the incident
+const timelinePositionLimit = 500
+
+// timelineAccessRecords names the audited case records of a violation and
+// what reading each of them means
+var timelineAccessRecords = map[string]string{
+	"violation_notice":   "generated the notice of violation",
+	"violation_evidence": "viewed the evidence",
+	"violation_appeals":  "viewed the appeals",
+	"violation_timeline": "viewed the incident timeline",
+}
+
+// TimelineService reconstructs what happened around a violation from the
+// records every other service keeps
+type TimelineService struct {
+	db                  *gorm.DB
+	zoneEventService    *ZoneEventService
+	auditService        *AuditService
+	appealService       *AppealService
+	sanctionService     *SanctionService
+	notificationService *NotificationService
+}
+
+func NewTimelineService(zoneEventService *ZoneEventService, auditService *AuditService, appealService *AppealService, sanctionService *SanctionService, notificationService *NotificationService) *TimelineService {
+	return &TimelineService{
+		db:                  database.GetDB(),
+		zoneEventService:    zoneEventService,
+		auditService:        auditService,
+		appealService:       appealService,
+		sanctionService:     sanctionService,
+		notificationService: notificationService,
+	}
+}
+
+// GetTimeline assembles the timeline of a violation, with the vessel's track
+// from window before to window after the triggering position. A missing
+// violation is gorm.ErrRecordNotFound.
+func (s *TimelineService) GetTimeline(violationID uint, window time.Duration) (*models.IncidentTimeline, error) {
+	var violation models.Violation
+	if err := s.db.Preload("Operator").First(&violation, violationID).Error; err != nil {
+		return nil, err
+	}
+
+	// Positions are stored when fetched and may have been reported well
+	// before the violation was detected, so the track is centred on the
+	// triggering position
+	incident := violation.DetectedAt
+	if violation.Evidence != nil && violation.Evidence.TriggeringPosition.LastPosEpoch > 0 {
+		incident = time.Unix(violation.Evidence.TriggeringPosition.LastPosEpoch, 0).UTC()
+	}
+
+	timeline := &models.IncidentTimeline{
+		Violation:    violation,
+		IncidentTime: incident,
+		WindowStart:  incident.Add(-window),
+		WindowEnd:    incident.Add(window),
+		Entries:      []models.TimelineEntry{},
+		Counts:       make(map[string]int),
+		Notes:        []string{},
+	}
+
+	steps := []func(*models.IncidentTimeline) error{
+		s.addPositions,
+		s.addZoneTransitions,
+		s.addViolations,
+		s.addAlerts,
+		s.addRecordAccesses,
+		s.addAppeals,
+		s.addSanctions,
+	}
+	for _, step := range steps {
+		if err := step(timeline); err != nil {
+			return nil, err
+		}
+	}
+
+	timeline.Notes = append(timeline.Notes, "No weather observations are recorded; positions carry the predicted tidal current where predictions cover them")
+
+	sort.SliceStable(timeline.Entries, func(i, j int) bool {
+		return timeline.Entries[i].Time.Before(timeline.Entries[j].Time)
+	})
+	for _, entry := range timeline.Entries {
+		timeline.Counts[entry.Kind]++
+	}
+	return timeline, nil
+}
+
+func (s *TimelineService) addPositions(timeline *models.IncidentTimeline) error {
+	v := timeline.Violation
+	incident := timeline.IncidentTime.Unix()
+
+	// Up to the limit on either side of the incident, so the nearest ones
+	// are among them however the track is spread
+	var before, after []models.VesselPositionRecord
+	if err := s.db.Where("vessel_uuid = ? AND park_id = ? AND last_pos_epoch BETWEEN ? AND ?", v.VesselUUID, v.ParkID, timeline.WindowStart.Unix(), incident).
+		Order("last_pos_epoch DESC, id DESC").
+		Limit(timelinePositionLimit).
+		Find(&before).Error; err != nil {
+		return fmt.Errorf("failed to load positions: %w", err)
+	}
+	if err := s.db.Where("vessel_uuid = ? AND park_id = ? AND last_pos_epoch > ? AND last_pos_epoch <= ?", v.VesselUUID, v.ParkID, incident, timeline.WindowEnd.Unix()).
+		Order("last_pos_epoch ASC, id ASC").
+		Limit(timelinePositionLimit).
+		Find(&after).Error; err != nil {
+		return fmt.Errorf("failed to load positions: %w", err)
+	}
+
+	records := make([]models.VesselPositionRecord, 0, len(before)+len(after))
+	for i := len(before) - 1; i >= 0; i-- {
+		records = append(records, before[i])
+	}
+	records = append(records, after...)
+	if len(records) > timelinePositionLimit {
+		records = nearestPositions(records, timeline.IncidentTime, timelinePositionLimit)
+		timeline.Notes = append(timeline.Notes, fmt.Sprintf("The track is limited to the %d positions nearest the incident; narrow the window for the rest", timelinePositionLimit))
+	}
+	if len(records) == 0 {
+		timeline.Notes = append(timeline.Notes, "No positions of the vessel were stored within the window")
+	}
+
+	for _, record := range records {
+		zone := "outside the park"
+		switch {
+		case record.OnLand:
+			zone = "on land, a GPS error"
+		case record.IsInPark:
+			zone = "inside the park"
+		}
+		summary := fmt.Sprintf("Position reported at %.1f kn over ground, course %03.0f, %s", record.Speed, record.Course, zone)
+		if current := record.Current(); current != nil {
+			summary += fmt.Sprintf("; tidal current %.1f kn toward %03.0f", current.SpeedKnots, current.DirectionDeg)
+		}
+
+		data := map[string]interface{}{
+			"speed":      record.Speed,
+			"course":     record.Course,
+			"heading":    record.Heading,
+			"is_in_park": record.IsInPark,
+			"on_land":    record.OnLand,
+		}
+		if current := record.Current(); current != nil {
+			data["current"] = current
+			data["speed_through_water"] = record.SpeedThroughWater
+		}
+
+		lat, lon := record.Latitude, record.Longitude
+		timeline.Entries = append(timeline.Entries, models.TimelineEntry{
+			Time:      time.Unix(record.LastPosEpoch, 0).UTC(),
+			Kind:      models.TimelineKindPosition,
+			Summary:   summary,
+			Latitude:  &lat,
+			Longitude: &lon,
+			Data:      data,
+		})
+	}
+	return nil
+}
+
+// nearestPositions keeps the limit positions reported closest to t, in
+// their original order
+func nearestPositions(records []models.VesselPositionRecord, t time.Time, limit int) []models.VesselPositionRecord {
+	distance := func(r models.VesselPositionRecord) int64 {
+		d := r.LastPosEpoch - t.Unix()
+		if d < 0 {
+			return -d
+		}
+		return d
+	}
+	nearest := append([]models.VesselPositionRecord{}, records...)
+	sort.SliceStable(nearest, func(i, j int) bool {
+		return distance(nearest[i]) < distance(nearest[j])
+	})
+	nearest = nearest[:limit]
+	sort.SliceStable(nearest, func(i, j int) bool {
+		return nearest[i].LastPosEpoch < nearest[j].LastPosEpoch
+	})
+	return nearest
+}
+
+func (s *TimelineService) addZoneTransitions(timeline *models.IncidentTimeline) error {
+	v := timeline.Violation
+	events, err := s.zoneEventService.GetVesselEvents(context.Background(), v.ParkID, v.VesselUUID, timeline.WindowStart, timeline.WindowEnd, timelinePositionLimit)
+	if err != nil {
+		return fmt.Errorf("failed to load zone events: %w", err)
+	}
+
+	for _, event := range events {
+		lat, lon := event.Latitude, event.Longitude
+		timeline.Entries = append(timeline.Entries, models.TimelineEntry{
+			Time:      event.OccurredAt,
+			Kind:      models.TimelineKindZoneTransition,
+			Summary:   fmt.Sprintf("Moved from %s to %s at %.1f kn, crossing after the position of %s UTC", zoneName(event.FromZone), zoneName(event.ToZone), event.Speed, event.PreviousAt.UTC().Format("15:04:05")),
+			Latitude:  &lat,
+			Longitude: &lon,
+			Data: map[string]interface{}{
+				"id":          event.ID,
+				"type":        event.Type,
+				"from_zone":   event.FromZone,
+				"to_zone":     event.ToZone,
+				"previous_at": event.PreviousAt,
+			},
+		})
+	}
+	return nil
+}
+
+// zoneName describes a zone in a sentence
+func zoneName(zone string) string {
+	switch zone {
+	case models.ZonePark:
+		return "the park"
+	case models.ZoneBuffer:
+		return "the buffer zone"
+	}
+	return "outside the park"
+}
+
+// addViolations adds the violation and the vessel's other violations in the
+// park detected within the window around its detection
+func (s *TimelineService) addViolations(timeline *models.IncidentTimeline) error {
+	v := timeline.Violation
+	window := timeline.WindowEnd.Sub(timeline.IncidentTime)
+	start, end := v.DetectedAt.Add(-window), v.DetectedAt.Add(window)
+
+	var violations []models.Violation
+	err := s.db.Where("vessel_uuid = ? AND park_id = ? AND detected_at BETWEEN ? AND ?", v.VesselUUID, v.ParkID, start, end).
+		Or("id = ?", v.ID).
+		Order("detected_at ASC, id ASC").
+		Find(&violations).Error
+	if err != nil {
+		return fmt.Errorf("failed to load violations: %w", err)
+	}
+
+	for _, violation := range violations {
+		summary := fmt.Sprintf("Violation #%d detected: %s (%s). %s", violation.ID, strings.ReplaceAll(violation.Type, "_", " "), violation.Severity, violation.Details)
+		if violation.ID == v.ID {
+			summary = fmt.Sprintf("This violation detected: %s (%s). %s", strings.ReplaceAll(violation.Type, "_", " "), violation.Severity, violation.Details)
+		}
+		lat, lon := violation.Latitude, violation.Longitude
+		timeline.Entries = append(timeline.Entries, models.TimelineEntry{
+			Time:      violation.DetectedAt,
+			Kind:      models.TimelineKindViolation,
+			Summary:   summary,
+			Latitude:  &lat,
+			Longitude: &lon,
+			Data: map[string]interface{}{
+				"id":       violation.ID,
+				"type":     violation.Type,
+				"severity": violation.Severity,
+				"status":   violation.Status,
+				"rule":     violation.Rule,
+			},
+		})
+	}
+	return nil
+}
+
+func (s *TimelineService) addAlerts(timeline *models.IncidentTimeline) error {
+	deliveries, err := s.notificationService.GetDeliveries(timeline.Violation.ID)
+	if err != nil {
+		return fmt.Errorf("failed to load alerts: %w", err)
+	}
+
+	for _, delivery := range deliveries {
+		summary := fmt.Sprintf("Alert sent on %s: %s", delivery.Channel, delivery.Subject)
+		switch delivery.Status {
+		case models.NotificationStatusFailed:
+			summary = fmt.Sprintf("Alert on %s failed: %s", delivery.Channel, delivery.Error)
+		case models.NotificationStatusSuppressed:
+			summary = "Alert withheld while the vessel's alerts were suppressed: " + delivery.Subject
+		}
+		timeline.Entries = append(timeline.Entries, models.TimelineEntry{
+			Time:    delivery.AttemptedAt,
+			Kind:    models.TimelineKindAlert,
+			Summary: summary,
+			Data:    delivery,
+		})
+	}
+	return nil
+}
+
+func (s *TimelineService) addRecordAccesses(timeline *models.IncidentTimeline) error {
+	id := strconv.FormatUint(uint64(timeline.Violation.ID), 10)
+	for recordType, action := range timelineAccessRecords {
+		logs, err := s.auditService.GetAccessLogs(AccessLogFilter{RecordType: recordType, RecordID: id})
+		if err != nil {
+			return fmt.Errorf("failed to load access logs: %w", err)
+		}
+		for _, log := range logs {
+			summary := fmt.Sprintf("%s %s", log.Actor, action)
+			if log.Status >= 400 {
+				summary += fmt.Sprintf(" (refused with status %d)", log.Status)
+			}
+			timeline.Entries = append(timeline.Entries, models.TimelineEntry{
+				Time:    log.AccessedAt,
+				Kind:    models.TimelineKindRecordAccess,
+				Summary: summary,
+				Actor:   log.Actor,
+				Data: map[string]interface{}{
+					"record_type": log.RecordType,
+					"method":      log.Method,
+					"path":        log.Path,
+					"status":      log.Status,
+				},
+			})
+		}
+	}
+	return nil
+}
+
+func (s *TimelineService) addAppeals(timeline *models.IncidentTimeline) error {
+	appeals, err := s.appealService.GetAppeals(timeline.Violation.ID)
+	if err != nil {
+		return fmt.Errorf("failed to load appeals: %w", err)
+	}
+
+	for _, appeal := range appeals {
+		data := map[string]interface{}{"id": appeal.ID, "status": appeal.Status}
+		timeline.Entries = append(timeline.Entries, models.TimelineEntry{
+			Time:    appeal.FiledAt,
+			Kind:    models.TimelineKindAppeal,
+			Summary: fmt.Sprintf("Appeal #%d filed: %s", appeal.ID, appeal.Grounds),
+			Actor:   appeal.FiledBy,
+			Data:    data,
+		})
+
+		switch {
+		case appeal.DecidedAt != nil:
+			timeline.Entries = append(timeline.Entries, models.TimelineEntry{
+				Time:    *appeal.DecidedAt,
+				Kind:    models.TimelineKindAppeal,
+				Summary: fmt.Sprintf("Appeal #%d decided, violation %s: %s", appeal.ID, appeal.Outcome, appeal.Decision),
+				Actor:   appeal.DecidedBy,
+				Data:    data,
+			})
+		case appeal.Status != models.AppealStatusFiled:
+			timeline.Entries = append(timeline.Entries, models.TimelineEntry{
+				Time:    appeal.UpdatedAt,
+				Kind:    models.TimelineKindAppeal,
+				Summary: fmt.Sprintf("Appeal #%d %s", appeal.ID, strings.ReplaceAll(appeal.Status, "_", " ")),
+				Data:    data,
+			})
+		}
+	}
+	return nil
+}
+
+func (s *TimelineService) addSanctions(timeline *models.IncidentTimeline) error {
+	sanctions, err := s.sanctionService.GetSanctionsForViolation(timeline.Violation.ID)
+	if err != nil {
+		return fmt.Errorf("failed to load sanctions: %w", err)
+	}
+
+	for _, sanction := range sanctions {
+		data := map[string]interface{}{"id": sanction.ID, "status": sanction.Status, "amount": sanction.Amount, "currency": sanction.Currency}
+		timeline.Entries = append(timeline.Entries, models.TimelineEntry{
+			Time:    sanction.IssuedAt,
+			Kind:    models.TimelineKindSanction,
+			Summary: fmt.Sprintf("Fine #%d of %.2f %s issued, due %s", sanction.ID, sanction.Amount, sanction.Currency, sanction.DueDate.UTC().Format("2006-01-02")),
+			Data:    data,
+		})
+
+		switch {
+		case sanction.PaidAt != nil:
+			timeline.Entries = append(timeline.Entries, models.TimelineEntry{
+				Time:    *sanction.PaidAt,
+				Kind:    models.TimelineKindSanction,
+				Summary: fmt.Sprintf("Fine #%d paid: %.2f %s", sanction.ID, sanction.AmountPaid, sanction.Currency),
+				Data:    data,
+			})
+		case sanction.Status != models.SanctionStatusIssued:
+			timeline.Entries = append(timeline.Entries, models.TimelineEntry{
+				Time:    sanction.UpdatedAt,
+				Kind:    models.TimelineKindSanction,
+				Summary: fmt.Sprintf("Fine #%d %s", sanction.ID, sanction.Status),
+				Data:    data,
+			})
+		}
+	}
+	return nil
+}
@@ -0,0 +1,276 @@
+package services
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+	"vessel-tracker/models"
+)
+
+// Track export formats
+const (
+	TrackFormatGPX = "gpx"
+	TrackFormatKML = "kml"
+)
+
+// Positions a track export includes by default and at most
+const (
+	DefaultTrackPoints = 10000
+	MaxTrackPoints     = 100000
+)
+
+const metersPerSecondPerKnot = 1852.0 / 3600
+
+// Track is the position history of a vessel prepared for export, oldest
+// position first
+type Track struct {
+	VesselUUID string
+	Name       string
+	MMSI       string
+	Park       string
+	Points     []TrackPoint
+}
+
+// TrackPoint is one position of an exported track
+type TrackPoint struct {
+	Time      time.Time
+	Latitude  float64
+	Longitude float64
+	Speed     float64 // over ground, knots
+	Course    float64 // degrees true
+	Heading   *int
+}
+
+// NewTrack builds a track from stored positions in any order. Points are
+// timed by the provider's fix time, or when they were stored without one.
+func NewTrack(park *Park, vessel *models.VesselRecord, positions []models.VesselPositionRecord) *Track {
+	track := &Track{
+		VesselUUID: vessel.UUID,
+		Name:       vessel.Name,
+		MMSI:       vessel.MMSI,
+		Park:       park.Record.Name,
+		Points:     make([]TrackPoint, 0, len(positions)),
+	}
+	if track.Name == "" {
+		track.Name = vessel.UUID
+	}
+
+	for _, pos := range positions {
+		at := pos.RecordedAt
+		if pos.LastPosEpoch > 0 {
+			at = time.Unix(pos.LastPosEpoch, 0)
+		}
+		track.Points = append(track.Points, TrackPoint{
+			Time:      at.UTC(),
+			Latitude:  pos.Latitude,
+			Longitude: pos.Longitude,
+			Speed:     pos.Speed,
+			Course:    pos.Course,
+			Heading:   pos.Heading,
+		})
+	}
+	sort.SliceStable(track.Points, func(i, j int) bool {
+		return track.Points[i].Time.Before(track.Points[j].Time)
+	})
+	return track
+}
+
+func formatCoordinate(value float64) string {
+	return strconv.FormatFloat(value, 'f', 6, 64)
+}
+
+type gpxDocument struct {
+	XMLName        xml.Name    `xml:"http://www.topografix.com/GPX/1/1 gpx"`
+	Version        string      `xml:"version,attr"`
+	Creator        string      `xml:"creator,attr"`
+	XMLNSXSI       string      `xml:"xmlns:xsi,attr"`
+	XMLNSTPX       string      `xml:"xmlns:gpxtpx,attr"`
+	SchemaLocation string      `xml:"xsi:schemaLocation,attr"`
+	Metadata       gpxMetadata `xml:"metadata"`
+	Track          gpxTrack    `xml:"trk"`
+}
+
+type gpxMetadata struct {
+	Name string `xml:"name"`
+	Desc string `xml:"desc"`
+	Time string `xml:"time"`
+}
+
+type gpxTrack struct {
+	Name    string     `xml:"name"`
+	Desc    string     `xml:"desc,omitempty"`
+	Segment gpxSegment `xml:"trkseg"`
+}
+
+type gpxSegment struct {
+	Points []gpxPoint `xml:"trkpt"`
+}
+
+type gpxPoint struct {
+	Lat        string        `xml:"lat,attr"`
+	Lon        string        `xml:"lon,attr"`
+	Time       string        `xml:"time"`
+	Extensions gpxExtensions `xml:"extensions"`
+}
+
+type gpxExtensions struct {
+	TrackPoint gpxTrackPointExtension `xml:"gpxtpx:TrackPointExtension"`
+}
+
+// gpxTrackPointExtension is the Garmin TrackPointExtension v2, which carries
+// speed in meters per second and course in degrees
+type gpxTrackPointExtension struct {
+	Speed  string `xml:"gpxtpx:speed"`
+	Course string `xml:"gpxtpx:course"`
+}
+
+// RenderTrackGPX writes the track as a GPX 1.1 document with one track
+// segment, the speed and course of each point in Garmin's TrackPointExtension
+func RenderTrackGPX(track *Track) ([]byte, error) {
+	doc := gpxDocument{
+		Version:        "1.1",
+		Creator:        "Vessel Tracker",
+		XMLNSXSI:       "http://www.w3.org/2001/XMLSchema-instance",
+		XMLNSTPX:       "http://www.garmin.com/xmlschemas/TrackPointExtension/v2",
+		SchemaLocation: "http://www.topografix.com/GPX/1/1 http://www.topografix.com/GPX/1/1/gpx.xsd http://www.garmin.com/xmlschemas/TrackPointExtension/v2 http://www8.garmin.com/xmlschemas/TrackPointExtensionv2.xsd",
+		Metadata: gpxMetadata{
+			Name: track.Name,
+			Desc: trackDescription(track),
+			Time: time.Now().UTC().Format(time.RFC3339),
+		},
+		Track: gpxTrack{
+			Name: track.Name,
+			Desc: "MMSI " + track.MMSI,
+		},
+	}
+	if track.MMSI == "" {
+		doc.Track.Desc = ""
+	}
+
+	for _, point := range track.Points {
+		doc.Track.Segment.Points = append(doc.Track.Segment.Points, gpxPoint{
+			Lat:  formatCoordinate(point.Latitude),
+			Lon:  formatCoordinate(point.Longitude),
+			Time: point.Time.Format(time.RFC3339),
+			Extensions: gpxExtensions{TrackPoint: gpxTrackPointExtension{
+				Speed:  strconv.FormatFloat(point.Speed*metersPerSecondPerKnot, 'f', 2, 64),
+				Course: strconv.FormatFloat(point.Course, 'f', 1, 64),
+			}},
+		})
+	}
+	return marshalTrackXML(doc)
+}
+
+func trackDescription(track *Track) string {
+	return fmt.Sprintf("Positions of vessel %s recorded in %s", track.VesselUUID, track.Park)
+}
+
+type kmlDocument struct {
+	XMLName  xml.Name   `xml:"http://www.opengis.net/kml/2.2 kml"`
+	XMLNSGX  string     `xml:"xmlns:gx,attr"`
+	Document kmlContent `xml:"Document"`
+}
+
+type kmlContent struct {
+	Name        string       `xml:"name"`
+	Description string       `xml:"description"`
+	Schema      kmlSchema    `xml:"Schema"`
+	Placemark   kmlPlacemark `xml:"Placemark"`
+}
+
+type kmlSchema struct {
+	ID     string          `xml:"id,attr"`
+	Fields []kmlArrayField `xml:"gx:SimpleArrayField"`
+}
+
+type kmlArrayField struct {
+	Name        string `xml:"name,attr"`
+	Type        string `xml:"type,attr"`
+	DisplayName string `xml:"displayName"`
+}
+
+type kmlPlacemark struct {
+	Name        string   `xml:"name"`
+	Description string   `xml:"description"`
+	Track       kmlTrack `xml:"gx:Track"`
+}
+
+type kmlTrack struct {
+	When         []string        `xml:"when"`
+	Coords       []string        `xml:"gx:coord"`
+	ExtendedData kmlExtendedData `xml:"ExtendedData"`
+}
+
+type kmlExtendedData struct {
+	SchemaData kmlSchemaData `xml:"SchemaData"`
+}
+
+type kmlSchemaData struct {
+	SchemaURL string         `xml:"schemaUrl,attr"`
+	Arrays    []kmlArrayData `xml:"gx:SimpleArrayData"`
+}
+
+type kmlArrayData struct {
+	Name   string   `xml:"name,attr"`
+	Values []string `xml:"gx:value"`
+}
+
+// RenderTrackKML writes the track as a KML 2.2 document holding one gx:Track,
+// which Google Earth plays back on its time slider, with the speed, course
+// and heading of each point as extended data
+func RenderTrackKML(track *Track) ([]byte, error) {
+	var gxTrack kmlTrack
+	speeds := kmlArrayData{Name: "speed"}
+	courses := kmlArrayData{Name: "course"}
+	headings := kmlArrayData{Name: "heading"}
+	for _, point := range track.Points {
+		gxTrack.When = append(gxTrack.When, point.Time.Format(time.RFC3339))
+		gxTrack.Coords = append(gxTrack.Coords, formatCoordinate(point.Longitude)+" "+formatCoordinate(point.Latitude)+" 0")
+		speeds.Values = append(speeds.Values, strconv.FormatFloat(point.Speed, 'f', 1, 64))
+		courses.Values = append(courses.Values, strconv.FormatFloat(point.Course, 'f', 1, 64))
+		heading := ""
+		if point.Heading != nil {
+			heading = strconv.Itoa(*point.Heading)
+		}
+		headings.Values = append(headings.Values, heading)
+	}
+	gxTrack.ExtendedData = kmlExtendedData{SchemaData: kmlSchemaData{
+		SchemaURL: "#vessel-track",
+		Arrays:    []kmlArrayData{speeds, courses, headings},
+	}}
+
+	doc := kmlDocument{
+		XMLNSGX: "http://www.google.com/kml/ext/2.2",
+		Document: kmlContent{
+			Name:        track.Name,
+			Description: trackDescription(track),
+			Schema: kmlSchema{
+				ID: "vessel-track",
+				Fields: []kmlArrayField{
+					{Name: "speed", Type: "float", DisplayName: "Speed over ground (kn)"},
+					{Name: "course", Type: "float", DisplayName: "Course over ground (°)"},
+					{Name: "heading", Type: "int", DisplayName: "Heading (°)"},
+				},
+			},
+			Placemark: kmlPlacemark{
+				Name:        track.Name,
+				Description: "MMSI " + track.MMSI,
+				Track:       gxTrack,
+			},
+		},
+	}
+	if track.MMSI == "" {
+		doc.Document.Placemark.Description = ""
+	}
+	return marshalTrackXML(doc)
+}
+
+func marshalTrackXML(doc interface{}) ([]byte, error) {
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to write track: %w", err)
+	}
+	return append([]byte(xml.Header), data...), nil
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"time"
+	"vessel-tracker/models"
+)
+
+// DefaultInterpolationMaxGap is the longest time between two fixes that a
+// position is interpolated across, twice the default fetch interval
+const DefaultInterpolationMaxGap = time.Hour
+
+// PositionAtTime is where a vessel was at a requested time: its last stored
+// fix, or a point interpolated between that fix and the next one
+type PositionAtTime struct {
+	models.VesselPositionRecord
+	Interpolated bool
+	Age          time.Duration // from the requested time to the nearest fix the position is based on
+}
+
+// PlacePositions places each vessel at the requested time, given its last fix
+// at or before that time and, optionally, its first fix after it. A vessel
+// with both, no more than maxGap apart, is placed along the great circle
+// between them, with its speed interpolated and its course set to the
+// bearing of that track. Interpolated positions are classified against the
+// park boundaries again.
+func PlacePositions(geo *GeoService, before []models.VesselPositionRecord, after map[string]models.VesselPositionRecord, at time.Time, maxGap time.Duration) []PositionAtTime {
+	placed := make([]PositionAtTime, 0, len(before))
+
+	for _, fix := range before {
+		position := PositionAtTime{VesselPositionRecord: fix, Age: at.Sub(fix.RecordedAt)}
+
+		next, ok := after[fix.VesselUUID]
+		gap := next.RecordedAt.Sub(fix.RecordedAt)
+		if !ok || gap <= 0 || gap > maxGap || !fix.RecordedAt.Before(at) {
+			placed = append(placed, position)
+			continue
+		}
+
+		fraction := float64(at.Sub(fix.RecordedAt)) / float64(gap)
+		lat, lon := IntermediatePoint(fix.Latitude, fix.Longitude, next.Latitude, next.Longitude, fraction)
+
+		position.Latitude = lat
+		position.Longitude = lon
+		position.Speed = fix.Speed + (next.Speed-fix.Speed)*fraction
+		if lat != next.Latitude || lon != next.Longitude {
+			position.Course = InitialBearing(lat, lon, next.Latitude, next.Longitude)
+		}
+		position.IsInPark = geo.IsPointInPark(lat, lon)
+		centerLat, centerLon := geo.GetParkCenter()
+		position.Distance = HaversineDistance(centerLat, centerLon, lat, lon) / metersPerNauticalMile
+		position.Interpolated = true
+		if untilNext := next.RecordedAt.Sub(at); untilNext < position.Age {
+			position.Age = untilNext
+		}
+
+		placed = append(placed, position)
+	}
+
+	return placed
+}
@@ -0,0 +1,226 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"vessel-tracker/logging"
+	"vessel-tracker/models"
+)
+
+// projectionStep is the spacing of the projected points checked against the
+// park boundaries, about 150 m at 20 kn
+const projectionStep = 15 * time.Second
+
+// maxProjectionHorizon bounds how far ahead tracks are projected; courses
+// are rarely held longer
+const maxProjectionHorizon = 6 * time.Hour
+
+// TrajectoryConfig sets how far ahead vessel tracks are projected and when a
+// projected intrusion raises a pre-alert
+type TrajectoryConfig struct {
+	Horizons      []time.Duration // times ahead of the fix reported, ascending; intrusions are looked for up to the last
+	MinSpeed      float64         // knots under which a vessel is not projected
+	AlertCooldown time.Duration   // a vessel is pre-alerted at most once per park within it
+}
+
+func DefaultTrajectoryConfig() TrajectoryConfig {
+	return TrajectoryConfig{
+		Horizons:      []time.Duration{15 * time.Minute, 30 * time.Minute},
+		MinSpeed:      1.0,
+		AlertCooldown: time.Hour,
+	}
+}
+
+// LoadTrajectoryConfig reads PROJECTION_HORIZONS (comma separated durations
+// in whole minutes, such as "15m,30m"), PROJECTION_MIN_SPEED and
+// PROJECTION_ALERT_COOLDOWN, falling back to the defaults when unset
+func LoadTrajectoryConfig() (TrajectoryConfig, error) {
+	config := DefaultTrajectoryConfig()
+
+	if value := os.Getenv("PROJECTION_HORIZONS"); value != "" {
+		var horizons []time.Duration
+		seen := make(map[time.Duration]bool)
+		for _, part := range strings.Split(value, ",") {
+			horizon, err := time.ParseDuration(strings.TrimSpace(part))
+			if err != nil || horizon <= 0 || horizon%time.Minute != 0 || horizon > maxProjectionHorizon {
+				return config, fmt.Errorf("invalid PROJECTION_HORIZONS %q: %q must be a whole number of minutes up to %s", value, strings.TrimSpace(part), maxProjectionHorizon)
+			}
+			if !seen[horizon] {
+				seen[horizon] = true
+				horizons = append(horizons, horizon)
+			}
+		}
+		sort.Slice(horizons, func(i, j int) bool { return horizons[i] < horizons[j] })
+		config.Horizons = horizons
+	}
+
+	if value := os.Getenv("PROJECTION_MIN_SPEED"); value != "" {
+		speed, err := strconv.ParseFloat(value, 64)
+		if err != nil || speed < 0 {
+			return config, fmt.Errorf("invalid PROJECTION_MIN_SPEED %q: must be a non-negative number of knots", value)
+		}
+		config.MinSpeed = speed
+	}
+
+	if value := os.Getenv("PROJECTION_ALERT_COOLDOWN"); value != "" {
+		cooldown, err := time.ParseDuration(value)
+		if err != nil || cooldown <= 0 {
+			return config, fmt.Errorf("invalid PROJECTION_ALERT_COOLDOWN %q: must be a positive duration", value)
+		}
+		config.AlertCooldown = cooldown
+	}
+
+	return config, nil
+}
+
+// TrajectoryService projects vessel tracks forward from their latest fix,
+// assuming speed and course are held, and raises a pre-alert for vessels
+// outside a park whose projected track enters it
+type TrajectoryService struct {
+	config           TrajectoryConfig
+	vesselRepo       *VesselRepository
+	violationService *ViolationService
+	logger           *slog.Logger
+}
+
+func NewTrajectoryService(config TrajectoryConfig, vesselRepo *VesselRepository, violationService *ViolationService) *TrajectoryService {
+	return &TrajectoryService{
+		config:           config,
+		vesselRepo:       vesselRepo,
+		violationService: violationService,
+		logger:           logging.Component("trajectory"),
+	}
+}
+
+// Config returns the projection settings
+func (s *TrajectoryService) Config() TrajectoryConfig {
+	return s.config
+}
+
+// GetProjection projects a vessel's track from its latest stored position in
+// a park. It returns nil when no position has been recorded.
+func (s *TrajectoryService) GetProjection(ctx context.Context, park *Park, vesselUUID string) (*models.VesselProjection, error) {
+	record, err := s.vesselRepo.GetLatestPosition(ctx, park.Record.ID, vesselUUID)
+	if err != nil || record == nil {
+		return nil, err
+	}
+
+	projection := s.Project(park, models.VesselPosition{
+		UUID:         record.VesselUUID,
+		Latitude:     record.Latitude,
+		Longitude:    record.Longitude,
+		Speed:        record.Speed,
+		Course:       record.Course,
+		LastPosEpoch: record.LastPosEpoch,
+	})
+	if projection.FixTime.Add(s.config.Horizons[len(s.config.Horizons)-1]).Before(time.Now()) {
+		projection.Notes = append(projection.Notes, "The latest fix is older than the projection, every projected point is in the past")
+	}
+	return &projection, nil
+}
+
+// Project dead-reckons a position forward along the great circle of its
+// course at its speed over ground, giving the point at each horizon and the
+// first point inside the park. The track stops where it would run aground.
+func (s *TrajectoryService) Project(park *Park, pos models.VesselPosition) models.VesselProjection {
+	geo := park.Geo
+	projection := models.VesselProjection{
+		VesselUUID: pos.UUID,
+		ParkID:     park.Record.ID,
+		FixTime:    positionTime(pos).UTC(),
+		Latitude:   pos.Latitude,
+		Longitude:  pos.Longitude,
+		Speed:      pos.Speed,
+		Course:     pos.Course,
+		InPark:     geo.IsPointInPark(pos.Latitude, pos.Longitude),
+		Points:     []models.ProjectedPoint{},
+		Notes:      []string{},
+	}
+
+	mask := geo.LandMask()
+	switch {
+	case mask.OnLand(pos.Latitude, pos.Longitude):
+		projection.Notes = append(projection.Notes, "Not projected, the fix is plotted on land")
+		return projection
+	case pos.Speed < s.config.MinSpeed:
+		projection.Notes = append(projection.Notes, fmt.Sprintf("Not projected, the vessel is making %.1f kn, under the %.1f kn it is taken to be under way at", pos.Speed, s.config.MinSpeed))
+		return projection
+	case pos.Course < 0 || pos.Course >= 360:
+		projection.Notes = append(projection.Notes, fmt.Sprintf("Not projected, the course %.1f is not valid", pos.Course))
+		return projection
+	}
+
+	horizons := s.config.Horizons
+	next := 0
+	inPark := projection.InPark
+	for ahead := projectionStep; ahead <= horizons[len(horizons)-1]; ahead += projectionStep {
+		lat, lon := DestinationPoint(pos.Latitude, pos.Longitude, pos.Course, pos.Speed*metersPerNauticalMile*ahead.Hours())
+		if mask.OnLand(lat, lon) {
+			projection.Notes = append(projection.Notes, fmt.Sprintf("The track runs aground %s after the fix and is not projected further", ahead))
+			break
+		}
+
+		wasInPark := inPark
+		inPark = geo.IsPointInPark(lat, lon)
+		at := projection.FixTime.Add(ahead)
+		if inPark && !wasInPark && projection.Intrusion == nil {
+			projection.Intrusion = &models.ProjectedIntrusion{
+				Minutes:   ahead.Minutes(),
+				Time:      at,
+				Latitude:  lat,
+				Longitude: lon,
+			}
+		}
+
+		if ahead == horizons[next] {
+			projection.Points = append(projection.Points, models.ProjectedPoint{
+				Minutes:      int(ahead.Minutes()),
+				Time:         at,
+				Latitude:     lat,
+				Longitude:    lon,
+				InPark:       inPark,
+				InBufferZone: geo.IsPointInBufferZone(lat, lon),
+			})
+			next++
+		}
+	}
+
+	return projection
+}
+
+// CheckIntrusions projects the positions fetched around a park that are
+// outside it and raises a pre-alert for each vessel due to enter it within
+// the longest horizon. Entries predicted for a time already past, from a
+// stale fix, are not alerted. zones holds the classification of each
+// position, in the same order. It returns the number of pre-alerts raised.
+func (s *TrajectoryService) CheckIntrusions(park *Park, positions []models.VesselPosition, zones []PositionZones) int {
+	raised := 0
+	now := time.Now()
+	for i, pos := range positions {
+		if zones[i].InPark || zones[i].OnLand {
+			continue
+		}
+
+		projection := s.Project(park, pos)
+		if projection.Intrusion == nil || projection.Intrusion.Time.Before(now) {
+			continue
+		}
+
+		recorded, err := s.violationService.RecordProjectedIntrusion(park, pos, projection.Intrusion, s.config.AlertCooldown)
+		if err != nil {
+			s.logger.Error("Failed to record projected intrusion", "park", park.Record.Slug, "vessel_uuid", pos.UUID, "error", err)
+			continue
+		}
+		if recorded {
+			s.logger.Warn("Vessel projected to enter the park", "park", park.Record.Slug, "vessel_uuid", pos.UUID, "mmsi", pos.MMSI, "name", pos.Name, "minutes", projection.Intrusion.Minutes)
+			raised++
+		}
+	}
+	return raised
+}
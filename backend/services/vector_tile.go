@@ -0,0 +1,623 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// mvtExtent is the size of a vector tile in its own integer coordinates
+const mvtExtent = 4096
+
+// maxMercatorLatitude is where Web Mercator tiles end
+const maxMercatorLatitude = 85.0511287798066
+
+// Geometry types of the Mapbox Vector Tile specification
+const (
+	mvtPoint      = 1
+	mvtLineString = 2
+	mvtPolygon    = 3
+)
+
+// Geometry commands of the Mapbox Vector Tile specification
+const (
+	mvtMoveTo    = 1
+	mvtLineTo    = 2
+	mvtClosePath = 7
+)
+
+// tileFeature is a GeoJSON feature projected to Web Mercator, x and y
+// running from 0 to 1 across the world, ready to be cut into tiles
+type tileFeature struct {
+	id         uint64
+	geomType   int
+	points     [][]float64
+	lines      [][][]float64
+	polygons   [][][][]float64 // rings, the outer boundary first
+	properties map[string]interface{}
+	bbox       [4]float64 // min x, min y, max x, max y
+}
+
+// vectorTileSource holds the features of one tile layer, projected once so
+// each tile only clips, simplifies and encodes them
+type vectorTileSource struct {
+	name     string
+	features []*tileFeature
+}
+
+// newVectorTileSource projects the features of a GeoJSON layer and returns
+// the number of features skipped for a geometry that could not be read
+func newVectorTileSource(name string, data *GeoJSON) (*vectorTileSource, int) {
+	source := &vectorTileSource{name: name}
+	skipped := 0
+	for i, feature := range data.Features {
+		tf, err := newTileFeature(feature)
+		if err != nil || tf == nil {
+			skipped++
+			continue
+		}
+		tf.id = uint64(i + 1)
+		source.features = append(source.features, tf)
+	}
+	return source, skipped
+}
+
+// mercator projects a WGS84 position to Web Mercator, 0 to 1 across the world
+func mercator(lon, lat float64) []float64 {
+	lat = math.Max(-maxMercatorLatitude, math.Min(maxMercatorLatitude, lat))
+	sin := math.Sin(toRadians(lat))
+	x := lon/360 + 0.5
+	y := 0.5 - math.Log((1+sin)/(1-sin))/(4*math.Pi)
+	return []float64{x, y}
+}
+
+func mercatorLine(coords [][]float64) [][]float64 {
+	line := make([][]float64, 0, len(coords))
+	for _, c := range coords {
+		if len(c) >= 2 {
+			line = append(line, mercator(c[0], c[1]))
+		}
+	}
+	return line
+}
+
+func mercatorPolygon(rings [][][]float64) [][][]float64 {
+	polygon := make([][][]float64, 0, len(rings))
+	for _, ring := range rings {
+		polygon = append(polygon, mercatorLine(ring))
+	}
+	return polygon
+}
+
+// newTileFeature reads the geometry of a feature, nil when it has none
+func newTileFeature(feature Feature) (*tileFeature, error) {
+	tf := &tileFeature{properties: feature.Properties}
+	coords := feature.Geometry.Coordinates
+
+	var err error
+	switch feature.Geometry.Type {
+	case "Point":
+		var point []float64
+		if err = json.Unmarshal(coords, &point); err == nil && len(point) >= 2 {
+			tf.geomType = mvtPoint
+			tf.points = [][]float64{mercator(point[0], point[1])}
+		}
+	case "MultiPoint":
+		var points [][]float64
+		if err = json.Unmarshal(coords, &points); err == nil {
+			tf.geomType = mvtPoint
+			tf.points = mercatorLine(points)
+		}
+	case "LineString":
+		var line [][]float64
+		if err = json.Unmarshal(coords, &line); err == nil {
+			tf.geomType = mvtLineString
+			tf.lines = [][][]float64{mercatorLine(line)}
+		}
+	case "MultiLineString":
+		var lines [][][]float64
+		if err = json.Unmarshal(coords, &lines); err == nil {
+			tf.geomType = mvtLineString
+			for _, line := range lines {
+				tf.lines = append(tf.lines, mercatorLine(line))
+			}
+		}
+	case "Polygon":
+		var rings [][][]float64
+		if err = json.Unmarshal(coords, &rings); err == nil {
+			tf.geomType = mvtPolygon
+			tf.polygons = [][][][]float64{mercatorPolygon(rings)}
+		}
+	case "MultiPolygon":
+		var polygons [][][][]float64
+		if err = json.Unmarshal(coords, &polygons); err == nil {
+			tf.geomType = mvtPolygon
+			for _, rings := range polygons {
+				tf.polygons = append(tf.polygons, mercatorPolygon(rings))
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported geometry type %q", feature.Geometry.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s coordinates: %w", feature.Geometry.Type, err)
+	}
+	if tf.geomType == 0 {
+		return nil, nil
+	}
+
+	tf.bbox = [4]float64{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+	extend := func(points [][]float64) {
+		for _, p := range points {
+			tf.bbox[0] = math.Min(tf.bbox[0], p[0])
+			tf.bbox[1] = math.Min(tf.bbox[1], p[1])
+			tf.bbox[2] = math.Max(tf.bbox[2], p[0])
+			tf.bbox[3] = math.Max(tf.bbox[3], p[1])
+		}
+	}
+	extend(tf.points)
+	for _, line := range tf.lines {
+		extend(line)
+	}
+	for _, polygon := range tf.polygons {
+		if len(polygon) > 0 {
+			extend(polygon[0])
+		}
+	}
+	if math.IsInf(tf.bbox[0], 1) {
+		return nil, nil
+	}
+	return tf, nil
+}
+
+// inWindow reports whether a point lies inside a [min x, min y, max x, max
+// y] window
+func inWindow(p []float64, window [4]float64) bool {
+	return p[0] >= window[0] && p[0] <= window[2] && p[1] >= window[1] && p[1] <= window[3]
+}
+
+// encodeTile encodes the features of the source that fall on tile z/x/y as
+// a vector tile with one layer. buffer is how far past its edges, in tile
+// units, a tile keeps geometry so polygons join up without seams, and
+// tolerance how far, also in tile units, simplified lines may stray from the
+// original. A tile spans a smaller area at each zoom level, so the same
+// tolerance keeps more detail the further the map is zoomed in. Tiles
+// without features encode to nothing.
+func (s *vectorTileSource) encodeTile(z, x, y int, buffer, tolerance float64) []byte {
+	scale := float64(int(1) << z)
+	margin := buffer / mvtExtent
+	worldMinX, worldMinY := (float64(x)-margin)/scale, (float64(y)-margin)/scale
+	worldMaxX, worldMaxY := (float64(x)+1+margin)/scale, (float64(y)+1+margin)/scale
+
+	toTile := func(p []float64) []float64 {
+		return []float64{(p[0]*scale - float64(x)) * mvtExtent, (p[1]*scale - float64(y)) * mvtExtent}
+	}
+	transform := func(points [][]float64) [][]float64 {
+		out := make([][]float64, len(points))
+		for i, p := range points {
+			out[i] = toTile(p)
+		}
+		return out
+	}
+	window := [4]float64{-buffer, -buffer, mvtExtent + buffer, mvtExtent + buffer}
+
+	layer := newMVTLayer(s.name)
+	for _, f := range s.features {
+		if f.bbox[2] < worldMinX || f.bbox[0] > worldMaxX || f.bbox[3] < worldMinY || f.bbox[1] > worldMaxY {
+			continue
+		}
+
+		var geometry []uint32
+		switch f.geomType {
+		case mvtPoint:
+			var points [][]int32
+			for _, p := range f.points {
+				if tp := toTile(p); inWindow(tp, window) {
+					points = append(points, roundPoint(tp))
+				}
+			}
+			geometry = encodePoints(points)
+		case mvtLineString:
+			var lines [][][]int32
+			for _, line := range f.lines {
+				for _, piece := range clipLine(transform(line), window) {
+					if rounded := roundLine(simplifyLine(piece, tolerance)); len(rounded) >= 2 {
+						lines = append(lines, rounded)
+					}
+				}
+			}
+			geometry = encodeLines(lines)
+		case mvtPolygon:
+			var rings [][][]int32
+			for _, polygon := range f.polygons {
+				rings = append(rings, tilePolygon(polygon, transform, window, tolerance)...)
+			}
+			geometry = encodeRings(rings)
+		}
+		if len(geometry) > 0 {
+			layer.addFeature(f.id, f.geomType, geometry, f.properties)
+		}
+	}
+
+	if len(layer.features) == 0 {
+		return nil
+	}
+	return protowire.AppendBytes(protowire.AppendTag(nil, 3, protowire.BytesType), layer.encode())
+}
+
+// tilePolygon clips and simplifies the rings of a polygon, dropping holes
+// and whole polygons that shrink below a tile unit. The outer ring is wound
+// clockwise as the specification asks, holes counter-clockwise.
+func tilePolygon(polygon [][][]float64, transform func([][]float64) [][]float64, window [4]float64, tolerance float64) [][][]int32 {
+	var rings [][][]int32
+	for i, ring := range polygon {
+		clipped := clipRing(transform(ring), window)
+		if len(clipped) < 4 {
+			if i == 0 {
+				return nil
+			}
+			continue
+		}
+		rounded := roundRing(simplifyRing(clipped, tolerance))
+		area := ringArea(intRing(rounded))
+		if len(rounded) < 3 || math.Abs(area) < 1 {
+			if i == 0 {
+				return nil
+			}
+			continue
+		}
+		// With y pointing down a positive shoelace area runs clockwise
+		if (i == 0) != (area > 0) {
+			for l, r := 0, len(rounded)-1; l < r; l, r = l+1, r-1 {
+				rounded[l], rounded[r] = rounded[r], rounded[l]
+			}
+		}
+		rings = append(rings, rounded)
+	}
+	return rings
+}
+
+// clipLine clips a line to a window, returning the pieces of it inside
+func clipLine(line [][]float64, window [4]float64) [][][]float64 {
+	region := Region{MinLon: window[0], MinLat: window[1], MaxLon: window[2], MaxLat: window[3]}
+
+	var pieces [][][]float64
+	var current [][]float64
+	for i := 1; i < len(line); i++ {
+		a, b := line[i-1], line[i]
+		t0, t1, ok := clipSegment(a, b, region)
+		if !ok {
+			if len(current) > 1 {
+				pieces = append(pieces, current)
+			}
+			current = nil
+			continue
+		}
+		if len(current) == 0 {
+			current = append(current, interpolate(a, b, t0))
+		}
+		current = append(current, interpolate(a, b, t1))
+		// A segment leaving the window ends the piece
+		if t1 < 1 {
+			pieces = append(pieces, current)
+			current = nil
+		}
+	}
+	if len(current) > 1 {
+		pieces = append(pieces, current)
+	}
+	return pieces
+}
+
+// simplifyLine drops the points of a line closer than tolerance to the line
+// through the points kept around them (Douglas-Peucker)
+func simplifyLine(line [][]float64, tolerance float64) [][]float64 {
+	if tolerance <= 0 || len(line) < 3 {
+		return line
+	}
+	keep := make([]bool, len(line))
+	keep[0], keep[len(line)-1] = true, true
+
+	sqTolerance := tolerance * tolerance
+	stack := [][2]int{{0, len(line) - 1}}
+	for len(stack) > 0 {
+		span := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		maxDist, index := 0.0, -1
+		for i := span[0] + 1; i < span[1]; i++ {
+			if d := sqSegmentDistance(line[i], line[span[0]], line[span[1]]); d > maxDist {
+				maxDist, index = d, i
+			}
+		}
+		if index >= 0 && maxDist > sqTolerance {
+			keep[index] = true
+			stack = append(stack, [2]int{span[0], index}, [2]int{index, span[1]})
+		}
+	}
+
+	simplified := make([][]float64, 0, len(line))
+	for i, p := range line {
+		if keep[i] {
+			simplified = append(simplified, p)
+		}
+	}
+	return simplified
+}
+
+// simplifyRing simplifies an unclosed ring, split at its furthest point from
+// the first so both halves keep their ends
+func simplifyRing(ring [][]float64, tolerance float64) [][]float64 {
+	if tolerance <= 0 || len(ring) < 4 {
+		return ring
+	}
+	far, maxDist := 0, 0.0
+	for i, p := range ring {
+		dx, dy := p[0]-ring[0][0], p[1]-ring[0][1]
+		if d := dx*dx + dy*dy; d > maxDist {
+			far, maxDist = i, d
+		}
+	}
+	if far == 0 {
+		return ring[:1]
+	}
+	closed := append(append([][]float64{}, ring...), ring[0])
+	first := simplifyLine(closed[:far+1], tolerance)
+	second := simplifyLine(closed[far:], tolerance)
+	simplified := make([][]float64, 0, len(first)+len(second)-2)
+	simplified = append(simplified, first...)
+	return append(simplified, second[1:len(second)-1]...)
+}
+
+// sqSegmentDistance returns the squared distance from p to the segment a-b
+func sqSegmentDistance(p, a, b []float64) float64 {
+	x, y := a[0], a[1]
+	dx, dy := b[0]-x, b[1]-y
+	if dx != 0 || dy != 0 {
+		t := ((p[0]-x)*dx + (p[1]-y)*dy) / (dx*dx + dy*dy)
+		if t > 1 {
+			x, y = b[0], b[1]
+		} else if t > 0 {
+			x, y = x+dx*t, y+dy*t
+		}
+	}
+	dx, dy = p[0]-x, p[1]-y
+	return dx*dx + dy*dy
+}
+
+func roundPoint(p []float64) []int32 {
+	return []int32{int32(math.Round(p[0])), int32(math.Round(p[1]))}
+}
+
+// roundLine rounds a line to tile units, dropping the repeated points that
+// leaves
+func roundLine(line [][]float64) [][]int32 {
+	out := make([][]int32, 0, len(line))
+	for _, p := range line {
+		r := roundPoint(p)
+		if n := len(out); n > 0 && out[n-1][0] == r[0] && out[n-1][1] == r[1] {
+			continue
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+func roundRing(ring [][]float64) [][]int32 {
+	out := roundLine(ring)
+	if n := len(out); n > 1 && out[0][0] == out[n-1][0] && out[0][1] == out[n-1][1] {
+		out = out[:n-1]
+	}
+	return out
+}
+
+func intRing(ring [][]int32) [][]float64 {
+	out := make([][]float64, len(ring))
+	for i, p := range ring {
+		out[i] = []float64{float64(p[0]), float64(p[1])}
+	}
+	return out
+}
+
+func mvtCommand(id, count int) uint32 {
+	return uint32(id&0x7) | uint32(count)<<3
+}
+
+func zigzag(v int32) uint32 {
+	return uint32((v << 1) ^ (v >> 31))
+}
+
+// geometryCursor encodes points as deltas from the one before, as the
+// commands of a feature do
+type geometryCursor struct {
+	x, y     int32
+	commands []uint32
+}
+
+func (c *geometryCursor) move(p []int32) {
+	c.commands = append(c.commands, zigzag(p[0]-c.x), zigzag(p[1]-c.y))
+	c.x, c.y = p[0], p[1]
+}
+
+func encodePoints(points [][]int32) []uint32 {
+	if len(points) == 0 {
+		return nil
+	}
+	c := &geometryCursor{}
+	c.commands = append(c.commands, mvtCommand(mvtMoveTo, len(points)))
+	for _, p := range points {
+		c.move(p)
+	}
+	return c.commands
+}
+
+func encodeLines(lines [][][]int32) []uint32 {
+	c := &geometryCursor{}
+	for _, line := range lines {
+		c.commands = append(c.commands, mvtCommand(mvtMoveTo, 1))
+		c.move(line[0])
+		c.commands = append(c.commands, mvtCommand(mvtLineTo, len(line)-1))
+		for _, p := range line[1:] {
+			c.move(p)
+		}
+	}
+	return c.commands
+}
+
+func encodeRings(rings [][][]int32) []uint32 {
+	c := &geometryCursor{}
+	for _, ring := range rings {
+		c.commands = append(c.commands, mvtCommand(mvtMoveTo, 1))
+		c.move(ring[0])
+		c.commands = append(c.commands, mvtCommand(mvtLineTo, len(ring)-1))
+		for _, p := range ring[1:] {
+			c.move(p)
+		}
+		c.commands = append(c.commands, mvtCommand(mvtClosePath, 1))
+	}
+	return c.commands
+}
+
+// mvtLayer builds a layer of a vector tile, sharing the keys and values of
+// the feature properties between features
+type mvtLayer struct {
+	name     string
+	keys     []string
+	keyIndex map[string]uint32
+	values   [][]byte
+	valIndex map[interface{}]uint32
+	features [][]byte
+}
+
+func newMVTLayer(name string) *mvtLayer {
+	return &mvtLayer{
+		name:     name,
+		keyIndex: make(map[string]uint32),
+		valIndex: make(map[interface{}]uint32),
+	}
+}
+
+func (l *mvtLayer) addFeature(id uint64, geomType int, geometry []uint32, properties map[string]interface{}) {
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var tags []byte
+	for _, name := range names {
+		value, ok := mvtValue(properties[name])
+		if !ok {
+			continue
+		}
+		tags = protowire.AppendVarint(tags, uint64(l.key(name)))
+		tags = protowire.AppendVarint(tags, uint64(l.value(value)))
+	}
+
+	var packed []byte
+	for _, command := range geometry {
+		packed = protowire.AppendVarint(packed, uint64(command))
+	}
+
+	var feature []byte
+	feature = protowire.AppendTag(feature, 1, protowire.VarintType)
+	feature = protowire.AppendVarint(feature, id)
+	if len(tags) > 0 {
+		feature = protowire.AppendTag(feature, 2, protowire.BytesType)
+		feature = protowire.AppendBytes(feature, tags)
+	}
+	feature = protowire.AppendTag(feature, 3, protowire.VarintType)
+	feature = protowire.AppendVarint(feature, uint64(geomType))
+	feature = protowire.AppendTag(feature, 4, protowire.BytesType)
+	feature = protowire.AppendBytes(feature, packed)
+	l.features = append(l.features, feature)
+}
+
+func (l *mvtLayer) key(name string) uint32 {
+	if index, ok := l.keyIndex[name]; ok {
+		return index
+	}
+	index := uint32(len(l.keys))
+	l.keys = append(l.keys, name)
+	l.keyIndex[name] = index
+	return index
+}
+
+func (l *mvtLayer) value(value interface{}) uint32 {
+	if index, ok := l.valIndex[value]; ok {
+		return index
+	}
+
+	var encoded []byte
+	switch v := value.(type) {
+	case string:
+		encoded = protowire.AppendTag(encoded, 1, protowire.BytesType)
+		encoded = protowire.AppendString(encoded, v)
+	case float64:
+		encoded = protowire.AppendTag(encoded, 3, protowire.Fixed64Type)
+		encoded = protowire.AppendFixed64(encoded, math.Float64bits(v))
+	case int64:
+		encoded = protowire.AppendTag(encoded, 6, protowire.VarintType)
+		encoded = protowire.AppendVarint(encoded, protowire.EncodeZigZag(v))
+	case bool:
+		encoded = protowire.AppendTag(encoded, 7, protowire.VarintType)
+		encoded = protowire.AppendVarint(encoded, protowire.EncodeBool(v))
+	}
+
+	index := uint32(len(l.values))
+	l.values = append(l.values, encoded)
+	l.valIndex[value] = index
+	return index
+}
+
+// mvtValue converts a GeoJSON property to a value a tile can hold: strings,
+// whole numbers, other numbers and booleans as they are, and objects and
+// arrays as their JSON. Null properties are left out.
+func mvtValue(value interface{}) (interface{}, bool) {
+	switch v := value.(type) {
+	case nil:
+		return nil, false
+	case string, bool:
+		return v, true
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return int64(v), true
+		}
+		return v, true
+	case int:
+		return int64(v), true
+	case int64:
+		return v, true
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, false
+	}
+	return string(data), true
+}
+
+func (l *mvtLayer) encode() []byte {
+	var layer []byte
+	layer = protowire.AppendTag(layer, 15, protowire.VarintType)
+	layer = protowire.AppendVarint(layer, 2)
+	layer = protowire.AppendTag(layer, 1, protowire.BytesType)
+	layer = protowire.AppendString(layer, l.name)
+	for _, feature := range l.features {
+		layer = protowire.AppendTag(layer, 2, protowire.BytesType)
+		layer = protowire.AppendBytes(layer, feature)
+	}
+	for _, key := range l.keys {
+		layer = protowire.AppendTag(layer, 3, protowire.BytesType)
+		layer = protowire.AppendString(layer, key)
+	}
+	for _, value := range l.values {
+		layer = protowire.AppendTag(layer, 4, protowire.BytesType)
+		layer = protowire.AppendBytes(layer, value)
+	}
+	layer = protowire.AppendTag(layer, 5, protowire.VarintType)
+	layer = protowire.AppendVarint(layer, mvtExtent)
+	return layer
+}
@@ -0,0 +1,187 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"time"
+	"vessel-tracker/database"
+	"vessel-tracker/logging"
+	"vessel-tracker/models"
+
+	"gorm.io/gorm"
+)
+
+// vesselDataTables hold rows keyed by vessel UUID, besides the vessel record
+// itself and the whitelist
+var vesselDataTables = []string{
+	"vessel_position_records",
+	"anchoring_events",
+	"park_arrivals",
+	"zone_events",
+	"shadow_divergences",
+	"violations",
+}
+
+// ErasureService honors data removal requests for a vessel, e.g. from the
+// owner of a private pleasure craft. Raw provider responses and retention
+// archives are not rewritten; they expire with their retention period.
+type ErasureService struct {
+	db        *gorm.DB
+	whitelist *WhitelistService
+	logger    *slog.Logger
+}
+
+func NewErasureService(whitelist *WhitelistService) *ErasureService {
+	return &ErasureService{
+		db:        database.GetDB(),
+		whitelist: whitelist,
+		logger:    logging.Component("erasure"),
+	}
+}
+
+// EraseVessel removes a vessel's data in one transaction. ErasurePurge
+// deletes the vessel, its positions, events, arrivals and violations with
+// their appeals, sanctions and status history. ErasureAnonymize keeps those rows for
+// statistics under a random pseudonym instead, with the vessel's name,
+// identifiers, operator link and the appellant names blanked. Whitelist
+// entries are deleted either way, each deletion recorded in the whitelist
+// audit. It returns gorm.ErrRecordNotFound when the vessel is unknown.
+func (s *ErasureService) EraseVessel(ctx context.Context, vesselUUID, mode string, by ChangedBy) (*models.VesselErasure, error) {
+	if mode != models.ErasurePurge && mode != models.ErasureAnonymize {
+		return nil, fmt.Errorf("mode must be %s or %s, got %q", models.ErasurePurge, models.ErasureAnonymize, mode)
+	}
+
+	erasure := &models.VesselErasure{
+		VesselUUID: vesselUUID,
+		Mode:       mode,
+		Rows:       make(map[string]int64),
+		ErasedBy:   by.Actor,
+		ErasedAt:   time.Now(),
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var vessel models.VesselRecord
+		if err := tx.Where("uuid = ?", vesselUUID).First(&vessel).Error; err != nil {
+			return err
+		}
+
+		violations := tx.Model(&models.Violation{}).Select("id").Where("vessel_uuid = ?", vesselUUID)
+
+		if mode == models.ErasurePurge {
+			appeals := tx.Where("violation_id IN (?)", violations).Delete(&models.ViolationAppeal{})
+			if appeals.Error != nil {
+				return fmt.Errorf("failed to delete appeals: %w", appeals.Error)
+			}
+			erasure.Rows["violation_appeals"] = appeals.RowsAffected
+
+			sanctions := tx.Where("violation_id IN (?)", violations).Delete(&models.Sanction{})
+			if sanctions.Error != nil {
+				return fmt.Errorf("failed to delete sanctions: %w", sanctions.Error)
+			}
+			erasure.Rows["sanctions"] = sanctions.RowsAffected
+
+			history := tx.Where("violation_id IN (?)", violations).Delete(&models.ViolationStatusChange{})
+			if history.Error != nil {
+				return fmt.Errorf("failed to delete violation history: %w", history.Error)
+			}
+			erasure.Rows["violation_status_changes"] = history.RowsAffected
+
+			for _, table := range vesselDataTables {
+				deleted := tx.Table(table).Where("vessel_uuid = ?", vesselUUID).Delete(map[string]interface{}{})
+				if deleted.Error != nil {
+					return fmt.Errorf("failed to delete %s: %w", table, deleted.Error)
+				}
+				erasure.Rows[table] = deleted.RowsAffected
+			}
+		} else {
+			pseudonym, err := newPseudonym()
+			if err != nil {
+				return err
+			}
+			erasure.Pseudonym = pseudonym
+
+			// The pseudonymous vessel keeps what traffic statistics group by
+			anonymous := models.VesselRecord{
+				UUID:         pseudonym,
+				Type:         vessel.Type,
+				TypeSpecific: vessel.TypeSpecific,
+				Length:       vessel.Length,
+				Breadth:      vessel.Breadth,
+				IsNavaid:     vessel.IsNavaid,
+			}
+			if err := tx.Create(&anonymous).Error; err != nil {
+				return fmt.Errorf("failed to create pseudonymous vessel: %w", err)
+			}
+
+			appeals := tx.Model(&models.ViolationAppeal{}).Where("violation_id IN (?)", violations).Update("filed_by", "")
+			if appeals.Error != nil {
+				return fmt.Errorf("failed to anonymize appeals: %w", appeals.Error)
+			}
+			erasure.Rows["violation_appeals"] = appeals.RowsAffected
+
+			for _, table := range vesselDataTables {
+				updates := map[string]interface{}{"vessel_uuid": pseudonym}
+				switch table {
+				case "violations":
+					updates["mmsi"] = ""
+					updates["imo"] = ""
+					updates["vessel_name"] = ""
+					updates["operator_id"] = nil
+					updates["evidence"] = nil
+				case "park_arrivals":
+					updates["mmsi"] = ""
+				}
+				updated := tx.Table(table).Where("vessel_uuid = ?", vesselUUID).Updates(updates)
+				if updated.Error != nil {
+					return fmt.Errorf("failed to anonymize %s: %w", table, updated.Error)
+				}
+				erasure.Rows[table] = updated.RowsAffected
+			}
+		}
+
+		var entries []models.WhitelistEntry
+		if err := tx.Where("vessel_uuid = ?", vesselUUID).Find(&entries).Error; err != nil {
+			return fmt.Errorf("failed to load whitelist entries: %w", err)
+		}
+		for i := range entries {
+			if err := recordWhitelistChange(tx, models.WhitelistAuditErase, entries[i].ID, models.NewWhitelistSnapshot(&entries[i]), nil, by); err != nil {
+				return err
+			}
+		}
+		whitelisted := tx.Where("vessel_uuid = ?", vesselUUID).Delete(&models.WhitelistEntry{})
+		if whitelisted.Error != nil {
+			return fmt.Errorf("failed to delete whitelist entries: %w", whitelisted.Error)
+		}
+		erasure.Rows["whitelist_entries"] = whitelisted.RowsAffected
+
+		if err := tx.Delete(&vessel).Error; err != nil {
+			return fmt.Errorf("failed to delete vessel record: %w", err)
+		}
+		erasure.Rows["vessel_records"] = 1
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if erasure.Rows["whitelist_entries"] > 0 {
+		if err := s.whitelist.Reload(); err != nil {
+			s.logger.Error("Failed to reload whitelist after erasure", "error", err)
+		}
+	}
+
+	s.logger.Info("Vessel data erased", "vessel_uuid", vesselUUID, "mode", mode, "pseudonym", erasure.Pseudonym, "actor", by.Actor, "rows", erasure.Rows)
+	return erasure, nil
+}
+
+// newPseudonym returns a random vessel UUID for anonymized data
+func newPseudonym() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate pseudonym: %w", err)
+	}
+	return "anonymized-" + hex.EncodeToString(b), nil
+}
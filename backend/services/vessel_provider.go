@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+	"vessel-tracker/models"
+)
+
+const defaultProviderRequestTimeout = 30 * time.Second
+
+// Vessel data providers
+const (
+	ProviderDatalastic = "datalastic"
+	ProviderFile       = "file"
+	ProviderAISStream  = "aisstream"
+)
+
+// ErrProviderUnsupported is returned for a query the vessel data provider
+// cannot answer, e.g. history from a live-only stream
+var ErrProviderUnsupported = errors.New("not supported by the vessel data provider")
+
+// VesselDataProvider is a source of vessel positions, details and tracks.
+// Responses use the Datalastic formats, which the rest of the tracker is
+// built around; radius is in nautical miles. Calls give up when their
+// context is done.
+type VesselDataProvider interface {
+	Name() string
+	GetVesselsInRadius(ctx context.Context, lat, lon float64, radius int) (*models.VesselPositionResponse, error)
+	SearchVessels(ctx context.Context, params map[string]string) (*models.VesselResponse, error)
+	GetHistory(ctx context.Context, params map[string]string) (*models.VesselHistoryResponse, error)
+	// Ping checks that the provider is reachable and usable
+	Ping(ctx context.Context) error
+}
+
+var (
+	_ VesselDataProvider = (*DatalasticProvider)(nil)
+	_ VesselDataProvider = (*FileProvider)(nil)
+	_ VesselDataProvider = (*AISStreamProvider)(nil)
+)
+
+// ProviderConfig selects the vessel data provider and how to reach it
+type ProviderConfig struct {
+	Name             string
+	DatalasticAPIKey string        `secret:"true"`
+	RequestTimeout   time.Duration // per Datalastic request
+	File             string        // positions served by the file provider
+	AISStreamAPIKey  string        `secret:"true"`
+	AISStreamURL     string
+}
+
+// LoadProviderConfig reads VESSEL_PROVIDER (datalastic by default) and the
+// settings of the provider it names:
+//   - datalastic needs DATALASTIC_API_KEY; each request times out after
+//     PROVIDER_REQUEST_TIMEOUT (30s by default)
+//   - file serves the positions in the JSON file at VESSEL_PROVIDER_FILE
+//   - aisstream streams from AISStream.io with AISSTREAM_API_KEY
+func LoadProviderConfig() (ProviderConfig, error) {
+	config := ProviderConfig{
+		Name:             ProviderDatalastic,
+		DatalasticAPIKey: os.Getenv("DATALASTIC_API_KEY"),
+		RequestTimeout:   defaultProviderRequestTimeout,
+		File:             os.Getenv("VESSEL_PROVIDER_FILE"),
+		AISStreamAPIKey:  os.Getenv("AISSTREAM_API_KEY"),
+		AISStreamURL:     aisStreamURL,
+	}
+	if value := os.Getenv("VESSEL_PROVIDER"); value != "" {
+		config.Name = value
+	}
+	if value := os.Getenv("AISSTREAM_URL"); value != "" {
+		config.AISStreamURL = value
+	}
+
+	switch config.Name {
+	case ProviderDatalastic:
+		if config.DatalasticAPIKey == "" {
+			return config, fmt.Errorf("VESSEL_PROVIDER=datalastic requires DATALASTIC_API_KEY")
+		}
+		if v := os.Getenv("PROVIDER_REQUEST_TIMEOUT"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return config, fmt.Errorf("invalid PROVIDER_REQUEST_TIMEOUT %q: %w", v, err)
+			}
+			if d <= 0 {
+				return config, fmt.Errorf("invalid PROVIDER_REQUEST_TIMEOUT %q: must be positive", v)
+			}
+			config.RequestTimeout = d
+		}
+	case ProviderFile:
+		if config.File == "" {
+			return config, fmt.Errorf("VESSEL_PROVIDER=file requires VESSEL_PROVIDER_FILE")
+		}
+	case ProviderAISStream:
+		if config.AISStreamAPIKey == "" {
+			return config, fmt.Errorf("VESSEL_PROVIDER=aisstream requires AISSTREAM_API_KEY")
+		}
+	default:
+		return config, fmt.Errorf("invalid VESSEL_PROVIDER %q: must be %s, %s or %s", config.Name, ProviderDatalastic, ProviderFile, ProviderAISStream)
+	}
+
+	return config, nil
+}
+
+// NewVesselDataProvider creates the configured provider. The file provider's
+// positions are read once to check them.
+func NewVesselDataProvider(config ProviderConfig) (VesselDataProvider, error) {
+	switch config.Name {
+	case ProviderDatalastic:
+		return NewDatalasticProvider(config.DatalasticAPIKey, config.RequestTimeout), nil
+	case ProviderFile:
+		provider := NewFileProvider(config.File)
+		if _, err := provider.load(); err != nil {
+			return nil, fmt.Errorf("invalid VESSEL_PROVIDER_FILE %q: %w", config.File, err)
+		}
+		return provider, nil
+	case ProviderAISStream:
+		return NewAISStreamProvider(config.AISStreamAPIKey, config.AISStreamURL), nil
+	}
+
+	return nil, fmt.Errorf("invalid VESSEL_PROVIDER %q: must be %s, %s or %s", config.Name, ProviderDatalastic, ProviderFile, ProviderAISStream)
+}
@@ -1,62 +1,218 @@
 package services
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 	"vessel-tracker/database"
+	"vessel-tracker/logging"
 	"vessel-tracker/models"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// PositionDedupConfig controls when a fetched position repeats the latest
+// stored one closely enough to be skipped
+type PositionDedupConfig struct {
+	ToleranceMeters   float64       // positions this close to the latest stored one count as unchanged
+	HeartbeatInterval time.Duration // an unchanged position is still stored once per interval
+}
+
+// DefaultPositionDedupConfig keeps an hourly heartbeat row for stationary
+// vessels so dwell-time and anchoring analysis still see them
+func DefaultPositionDedupConfig() PositionDedupConfig {
+	return PositionDedupConfig{
+		ToleranceMeters:   10,
+		HeartbeatInterval: time.Hour,
+	}
+}
+
+// LoadPositionDedupConfig reads POSITION_DEDUP_METERS (0 disables the
+// coordinate check) and POSITION_HEARTBEAT_INTERVAL
+func LoadPositionDedupConfig() (PositionDedupConfig, error) {
+	config := DefaultPositionDedupConfig()
+
+	if value := os.Getenv("POSITION_DEDUP_METERS"); value != "" {
+		meters, err := strconv.ParseFloat(value, 64)
+		if err != nil || meters < 0 {
+			return config, fmt.Errorf("invalid POSITION_DEDUP_METERS %q", value)
+		}
+		config.ToleranceMeters = meters
+	}
+
+	if value := os.Getenv("POSITION_HEARTBEAT_INTERVAL"); value != "" {
+		interval, err := time.ParseDuration(value)
+		if err != nil || interval <= 0 {
+			return config, fmt.Errorf("invalid POSITION_HEARTBEAT_INTERVAL %q", value)
+		}
+		config.HeartbeatInterval = interval
+	}
+
+	return config, nil
+}
+
+// storeBatchSize is the number of rows per INSERT statement, well below the
+// bind parameter limits of PostgreSQL and SQLite
+const storeBatchSize = 500
+
+// StoreResult summarizes a StoreVesselData call
+type StoreResult struct {
+	Stored            int `json:"stored"`
+	DuplicatesSkipped int `json:"duplicate_skipped"`
+
+	// RecordedAt is the time the positions were stored at, Previous the
+	// latest position of each vessel stored before them and Duplicate which
+	// of the given positions were skipped, in the same order
+	RecordedAt time.Time                              `json:"-"`
+	Previous   map[string]models.VesselPositionRecord `json:"-"`
+	Duplicate  []bool                                 `json:"-"`
+}
+
+// VesselRepository stores and queries vessels and their positions. Queries
+// are abandoned when their context is done.
 type VesselRepository struct {
-	db *gorm.DB
+	db     *gorm.DB
+	dedup  PositionDedupConfig
+	logger *slog.Logger
+
+	duplicatesSkipped atomic.Int64
 }
 
-func NewVesselRepository() *VesselRepository {
+func NewVesselRepository(dedup PositionDedupConfig) *VesselRepository {
 	return &VesselRepository{
-		db: database.GetDB(),
+		db:     database.GetDB(),
+		dedup:  dedup,
+		logger: logging.Component("vessel_repository"),
 	}
 }
 
-func (r *VesselRepository) StoreVesselData(vesselPositions []models.VesselPosition, geoService *GeoService) error {
-	tx := r.db.Begin()
-	if tx.Error != nil {
-		return tx.Error
+// DuplicatesSkipped returns how many unchanged positions have been skipped
+// since startup
+func (r *VesselRepository) DuplicatesSkipped() int64 {
+	return r.duplicatesSkipped.Load()
+}
+
+// latestPositions returns the most recent stored position of each vessel in a park
+func (r *VesselRepository) latestPositions(ctx context.Context, parkID uint, vesselUUIDs []string) (map[string]models.VesselPositionRecord, error) {
+	var positions []models.VesselPositionRecord
+
+	subQuery := r.db.WithContext(ctx).Model(&models.VesselPositionRecord{}).
+		Select("vessel_uuid, MAX(recorded_at) as max_recorded_at").
+		Where("park_id = ? AND vessel_uuid IN ?", parkID, vesselUUIDs).
+		Group("vessel_uuid")
+
+	err := r.db.WithContext(ctx).Joins("JOIN (?) as latest ON vessel_position_records.vessel_uuid = latest.vessel_uuid AND vessel_position_records.recorded_at = latest.max_recorded_at", subQuery).
+		Where("vessel_position_records.park_id = ?", parkID).
+		Find(&positions).Error
+	if err != nil {
+		return nil, err
 	}
 
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-		}
-	}()
+	latest := make(map[string]models.VesselPositionRecord, len(positions))
+	for _, position := range positions {
+		latest[position.VesselUUID] = position
+	}
+	return latest, nil
+}
+
+// isDuplicatePosition reports whether a fetched position repeats the latest
+// stored one: the same AIS report, or an unchanged location within the
+// heartbeat interval
+func (r *VesselRepository) isDuplicatePosition(pos models.VesselPosition, latest models.VesselPositionRecord, recordedAt time.Time) bool {
+	if pos.LastPosEpoch != 0 && pos.LastPosEpoch == latest.LastPosEpoch {
+		return true
+	}
+
+	if r.dedup.ToleranceMeters <= 0 || recordedAt.Sub(latest.RecordedAt) >= r.dedup.HeartbeatInterval {
+		return false
+	}
 
-	recordedAt := time.Now()
+	return HaversineDistance(pos.Latitude, pos.Longitude, latest.Latitude, latest.Longitude) <= r.dedup.ToleranceMeters
+}
 
+// StoreVesselData upserts the vessels of a park's fetch and stores their new
+// positions. zones holds the classification of each position, in the same
+// order, as returned by GeoService.ClassifyPositions. A vessel seen by the
+// fetches of two parks gets a position in each.
+func (r *VesselRepository) StoreVesselData(ctx context.Context, parkID uint, vesselPositions []models.VesselPosition, zones []PositionZones) (*StoreResult, error) {
+	return r.storeVesselData(ctx, parkID, vesselPositions, zones, time.Now())
+}
+
+// storeVesselData stores positions as recorded at the given time, which a
+// replay takes from the recorded provider response
+func (r *VesselRepository) storeVesselData(ctx context.Context, parkID uint, vesselPositions []models.VesselPosition, zones []PositionZones, recordedAt time.Time) (*StoreResult, error) {
+	if len(zones) != len(vesselPositions) {
+		return nil, fmt.Errorf("got %d zone classifications for %d positions", len(zones), len(vesselPositions))
+	}
+
+	result := &StoreResult{Duplicate: make([]bool, len(vesselPositions))}
+
+	vesselUUIDs := make([]string, 0, len(vesselPositions))
 	for _, vesselPos := range vesselPositions {
-		// Create or update vessel record
-		vesselRecord := models.VesselRecord{
-			UUID:         vesselPos.UUID,
-			Name:         vesselPos.Name,
-			MMSI:         vesselPos.MMSI,
-			IMO:          vesselPos.IMO,
-			Type:         vesselPos.Type,
-			TypeSpecific: vesselPos.TypeSpecific,
-			CountryISO:   vesselPos.CountryISO,
+		vesselUUIDs = append(vesselUUIDs, vesselPos.UUID)
+	}
+
+	latest, err := r.latestPositions(ctx, parkID, vesselUUIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load latest positions: %w", err)
+	}
+	result.Previous = latest
+
+	result.RecordedAt = recordedAt
+
+	vesselRecords := make([]models.VesselRecord, 0, len(vesselPositions))
+	positionRecords := make([]models.VesselPositionRecord, 0, len(vesselPositions))
+	seen := make(map[string]bool, len(vesselPositions))
+	// queued locates, by vessel, the position record queued for it and the
+	// fetched position it was made from
+	type queuedPosition struct{ record, fetched int }
+	queued := make(map[string]queuedPosition, len(vesselPositions))
+
+	for i, vesselPos := range vesselPositions {
+		// The feed can list a vessel twice; a row may only be upserted once per statement
+		if !seen[vesselPos.UUID] {
+			seen[vesselPos.UUID] = true
+			vesselRecords = append(vesselRecords, models.VesselRecord{
+				UUID:         vesselPos.UUID,
+				Name:         vesselPos.Name,
+				MMSI:         vesselPos.MMSI,
+				IMO:          vesselPos.IMO,
+				Type:         vesselPos.Type,
+				TypeSpecific: vesselPos.TypeSpecific,
+				CountryISO:   vesselPos.CountryISO,
+				Destination:  vesselPos.Destination,
+				LastSeenAt:   &recordedAt,
+			})
 		}
 
-		err := tx.Where("uuid = ?", vesselPos.UUID).FirstOrCreate(&vesselRecord).Error
-		if err != nil {
-			tx.Rollback()
-			return err
+		if previous, ok := latest[vesselPos.UUID]; ok && r.isDuplicatePosition(vesselPos, previous, recordedAt) {
+			result.DuplicatesSkipped++
+			result.Duplicate[i] = true
+			continue
 		}
 
-		// Check if vessel is in park
-		isInPark := geoService.IsPointInPark(vesselPos.Latitude, vesselPos.Longitude)
+		// A vessel listed twice gets one position, from its latest report;
+		// two stored at the same time would both be its latest
+		earlier, listed := queued[vesselPos.UUID]
+		if listed {
+			result.DuplicatesSkipped++
+			if vesselPos.LastPosEpoch <= positionRecords[earlier.record].LastPosEpoch {
+				result.Duplicate[i] = true
+				continue
+			}
+			result.Duplicate[earlier.fetched] = true
+		}
 
-		// Store position record
-		positionRecord := models.VesselPositionRecord{
+		currentSpeed, currentDirection := zones[i].Current.Columns()
+		record := models.VesselPositionRecord{
 			VesselUUID:   vesselPos.UUID,
+			ParkID:       parkID,
 			Latitude:     vesselPos.Latitude,
 			Longitude:    vesselPos.Longitude,
 			Speed:        vesselPos.Speed,
@@ -64,78 +220,148 @@ func (r *VesselRepository) StoreVesselData(vesselPositions []models.VesselPositi
 			Heading:      vesselPos.Heading,
 			Destination:  vesselPos.Destination,
 			Distance:     vesselPos.Distance,
-			IsInPark:     isInPark,
+			IsInPark:     zones[i].InPark,
+			OnLand:       zones[i].OnLand,
 			LastPosEpoch: vesselPos.LastPosEpoch,
 			LastPosUTC:   vesselPos.LastPosUTC,
 			ETAEpoch:     vesselPos.ETAEpoch,
 			ETAUTC:       vesselPos.ETAUTC,
 			RecordedAt:   recordedAt,
+
+			CurrentSpeed:      currentSpeed,
+			CurrentDirection:  currentDirection,
+			SpeedThroughWater: zones[i].Current.SpeedThroughWater(vesselPos.Speed, vesselPos.Course),
+		}
+		if listed {
+			positionRecords[earlier.record] = record
+			queued[vesselPos.UUID] = queuedPosition{earlier.record, i}
+			continue
 		}
+		queued[vesselPos.UUID] = queuedPosition{len(positionRecords), i}
+		positionRecords = append(positionRecords, record)
+	}
 
-		err = tx.Create(&positionRecord).Error
-		if err != nil {
-			tx.Rollback()
-			return err
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if len(vesselRecords) > 0 {
+			if err := upsertVessels(tx, &vesselRecords, feedVesselColumns); err != nil {
+				return fmt.Errorf("failed to upsert vessel records: %w", err)
+			}
+		}
+
+		if len(positionRecords) > 0 {
+			if err := tx.CreateInBatches(&positionRecords, storeBatchSize).Error; err != nil {
+				return fmt.Errorf("failed to insert vessel positions: %w", err)
+			}
 		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
+	result.Stored = len(positionRecords)
+
+	r.duplicatesSkipped.Add(int64(result.DuplicatesSkipped))
+	return result, nil
+}
 
-	return tx.Commit().Error
+// GetLatestRecordedAt returns the time of the most recent stored position in
+// a park, or nil when none has been recorded
+func (r *VesselRepository) GetLatestRecordedAt(ctx context.Context, parkID uint) (*time.Time, error) {
+	var positions []models.VesselPositionRecord
+	err := r.db.WithContext(ctx).Select("recorded_at").
+		Where("park_id = ?", parkID).
+		Order("recorded_at DESC").
+		Limit(1).
+		Find(&positions).Error
+	if err != nil || len(positions) == 0 {
+		return nil, err
+	}
+	return &positions[0].RecordedAt, nil
 }
 
-func (r *VesselRepository) GetLatestVesselPositions() ([]models.VesselPositionRecord, error) {
+func (r *VesselRepository) GetLatestVesselPositions(ctx context.Context, parkID uint) ([]models.VesselPositionRecord, error) {
 	var positions []models.VesselPositionRecord
 
 	// Get the latest position for each vessel that is within the park
-	subQuery := r.db.Model(&models.VesselPositionRecord{}).
+	subQuery := r.db.WithContext(ctx).Model(&models.VesselPositionRecord{}).
 		Select("vessel_uuid, MAX(recorded_at) as max_recorded_at").
-		Where("is_in_park = ?", true).
+		Where("park_id = ? AND is_in_park = ?", parkID, true).
 		Group("vessel_uuid")
 
-	err := r.db.Joins("JOIN (?) as latest ON vessel_position_records.vessel_uuid = latest.vessel_uuid AND vessel_position_records.recorded_at = latest.max_recorded_at", subQuery).
-		Where("vessel_position_records.is_in_park = ?", true).
+	err := r.db.WithContext(ctx).Joins("JOIN (?) as latest ON vessel_position_records.vessel_uuid = latest.vessel_uuid AND vessel_position_records.recorded_at = latest.max_recorded_at", subQuery).
+		Where("vessel_position_records.park_id = ? AND vessel_position_records.is_in_park = ?", parkID, true).
 		Preload("Vessel").
 		Find(&positions).Error
 
 	return positions, err
 }
 
-func (r *VesselRepository) GetVesselPositionsAtTime(timestamp time.Time) ([]models.VesselPositionRecord, error) {
+func (r *VesselRepository) GetVesselPositionsAtTime(ctx context.Context, parkID uint, timestamp time.Time) ([]models.VesselPositionRecord, error) {
 	var positions []models.VesselPositionRecord
 
 	// Get the most recent position for each vessel before or at the specified time
-	subQuery := r.db.Model(&models.VesselPositionRecord{}).
+	subQuery := r.db.WithContext(ctx).Model(&models.VesselPositionRecord{}).
 		Select("vessel_uuid, MAX(recorded_at) as max_recorded_at").
-		Where("recorded_at <= ?", timestamp).
+		Where("park_id = ? AND recorded_at <= ?", parkID, timestamp).
 		Group("vessel_uuid")
 
-	err := r.db.Joins("JOIN (?) as latest ON vessel_position_records.vessel_uuid = latest.vessel_uuid AND vessel_position_records.recorded_at = latest.max_recorded_at", subQuery).
+	err := r.db.WithContext(ctx).Joins("JOIN (?) as latest ON vessel_position_records.vessel_uuid = latest.vessel_uuid AND vessel_position_records.recorded_at = latest.max_recorded_at", subQuery).
+		Where("vessel_position_records.park_id = ?", parkID).
 		Preload("Vessel").
 		Find(&positions).Error
 
 	return positions, err
 }
 
-func (r *VesselRepository) GetVesselsInParkAtTime(timestamp time.Time) ([]models.VesselPositionRecord, error) {
+// GetNextVesselPositions returns the first position of each of the given
+// vessels stored after the timestamp and no later than until
+func (r *VesselRepository) GetNextVesselPositions(ctx context.Context, parkID uint, vesselUUIDs []string, timestamp, until time.Time) (map[string]models.VesselPositionRecord, error) {
+	next := make(map[string]models.VesselPositionRecord)
+	if len(vesselUUIDs) == 0 {
+		return next, nil
+	}
+
+	var positions []models.VesselPositionRecord
+	subQuery := r.db.WithContext(ctx).Model(&models.VesselPositionRecord{}).
+		Select("vessel_uuid, MIN(recorded_at) as min_recorded_at").
+		Where("park_id = ? AND vessel_uuid IN ? AND recorded_at > ? AND recorded_at <= ?", parkID, vesselUUIDs, timestamp, until).
+		Group("vessel_uuid")
+
+	err := r.db.WithContext(ctx).Joins("JOIN (?) as following ON vessel_position_records.vessel_uuid = following.vessel_uuid AND vessel_position_records.recorded_at = following.min_recorded_at", subQuery).
+		Where("vessel_position_records.park_id = ?", parkID).
+		Find(&positions).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, position := range positions {
+		next[position.VesselUUID] = position
+	}
+	return next, nil
+}
+
+func (r *VesselRepository) GetVesselsInParkAtTime(ctx context.Context, parkID uint, timestamp time.Time) ([]models.VesselPositionRecord, error) {
 	var positions []models.VesselPositionRecord
 
 	// Get the most recent position for each vessel before or at the specified time, filtered by is_in_park
-	subQuery := r.db.Model(&models.VesselPositionRecord{}).
+	subQuery := r.db.WithContext(ctx).Model(&models.VesselPositionRecord{}).
 		Select("vessel_uuid, MAX(recorded_at) as max_recorded_at").
-		Where("recorded_at <= ?", timestamp).
+		Where("park_id = ? AND recorded_at <= ?", parkID, timestamp).
 		Group("vessel_uuid")
 
-	err := r.db.Joins("JOIN (?) as latest ON vessel_position_records.vessel_uuid = latest.vessel_uuid AND vessel_position_records.recorded_at = latest.max_recorded_at", subQuery).
-		Where("vessel_position_records.is_in_park = ?", true).
+	err := r.db.WithContext(ctx).Joins("JOIN (?) as latest ON vessel_position_records.vessel_uuid = latest.vessel_uuid AND vessel_position_records.recorded_at = latest.max_recorded_at", subQuery).
+		Where("vessel_position_records.park_id = ? AND vessel_position_records.is_in_park = ?", parkID, true).
 		Preload("Vessel").
 		Find(&positions).Error
 
 	return positions, err
 }
 
-func (r *VesselRepository) GetVesselHistory(vesselUUID string, startTime, endTime time.Time, limit int) ([]models.VesselPositionRecord, error) {
+func (r *VesselRepository) GetVesselHistory(ctx context.Context, parkID uint, vesselUUID string, startTime, endTime time.Time, limit int) ([]models.VesselPositionRecord, error) {
 	var positions []models.VesselPositionRecord
 
-	query := r.db.Where("vessel_uuid = ? AND recorded_at BETWEEN ? AND ?", vesselUUID, startTime, endTime).
+	query := r.db.WithContext(ctx).Where("park_id = ? AND vessel_uuid = ? AND recorded_at BETWEEN ? AND ?", parkID, vesselUUID, startTime, endTime).
 		Order("recorded_at DESC").
 		Preload("Vessel")
 
@@ -148,34 +374,179 @@ func (r *VesselRepository) GetVesselHistory(vesselUUID string, startTime, endTim
 	return positions, err
 }
 
-// StoreVessel stores or updates a single vessel record
-func (r *VesselRepository) StoreVessel(vessel *models.VesselRecord) error {
-	// Use GORM's FirstOrCreate to either create or update
-	err := r.db.Where("uuid = ?", vessel.UUID).FirstOrCreate(vessel).Error
-	if err != nil {
+// GetOnLandPositions returns the positions in a park plotted on land between
+// the given times, most recent first
+func (r *VesselRepository) GetOnLandPositions(ctx context.Context, parkID uint, startTime, endTime time.Time, limit int) ([]models.VesselPositionRecord, error) {
+	var positions []models.VesselPositionRecord
+
+	err := r.db.WithContext(ctx).Where("park_id = ? AND on_land = ? AND recorded_at BETWEEN ? AND ?", parkID, true, startTime, endTime).
+		Order("recorded_at DESC").
+		Limit(limit).
+		Preload("Vessel").
+		Find(&positions).Error
+	return positions, err
+}
+
+// GetPositionsBetween returns up to limit positions in a park recorded
+// between the given times, most recent first, leaving out positions plotted
+// on land
+func (r *VesselRepository) GetPositionsBetween(ctx context.Context, parkID uint, startTime, endTime time.Time, limit int) ([]models.VesselPositionRecord, error) {
+	var positions []models.VesselPositionRecord
+
+	err := r.db.WithContext(ctx).Where("park_id = ? AND on_land = ? AND recorded_at BETWEEN ? AND ?", parkID, false, startTime, endTime).
+		Order("recorded_at DESC").
+		Limit(limit).
+		Find(&positions).Error
+	return positions, err
+}
+
+// GetPositionsAfter returns up to limit positions in a park recorded after
+// one time and no later than another, oldest first
+func (r *VesselRepository) GetPositionsAfter(ctx context.Context, parkID uint, after, until time.Time, limit int) ([]models.VesselPositionRecord, error) {
+	var positions []models.VesselPositionRecord
+
+	err := r.db.WithContext(ctx).Where("park_id = ? AND recorded_at > ? AND recorded_at <= ?", parkID, after, until).
+		Order("recorded_at ASC").
+		Limit(limit).
+		Preload("Vessel").
+		Find(&positions).Error
+	return positions, err
+}
+
+// GetRecentPositions returns a vessel's positions in a park recorded since the given time, oldest first
+func (r *VesselRepository) GetRecentPositions(ctx context.Context, parkID uint, vesselUUID string, since time.Time) ([]models.VesselPositionRecord, error) {
+	var positions []models.VesselPositionRecord
+
+	err := r.db.WithContext(ctx).Where("park_id = ? AND vessel_uuid = ? AND recorded_at >= ?", parkID, vesselUUID, since).
+		Order("recorded_at ASC").
+		Find(&positions).Error
+
+	return positions, err
+}
+
+// Vessel metadata refreshed on every upsert. Fields the source does not
+// carry are left alone rather than blanked: the position feed has no ENI and
+// vessel history has no current destination.
+var (
+	feedVesselColumns    = []string{"name", "mmsi", "imo", "type", "type_specific", "country_iso", "destination", "updated_at"}
+	historyVesselColumns = []string{"name", "mmsi", "imo", "eni", "type", "type_specific", "country_iso", "updated_at"}
+)
+
+// upsertVessels inserts new vessel records and refreshes the given columns of
+// known ones. last_seen_at only ever moves forward, so backfilled history
+// cannot make a vessel look older than it is.
+func upsertVessels(tx *gorm.DB, vessels *[]models.VesselRecord, columns []string) error {
+	lastSeen := clause.Expr{SQL: "CASE WHEN vessel_records.last_seen_at IS NULL OR excluded.last_seen_at > vessel_records.last_seen_at THEN excluded.last_seen_at ELSE vessel_records.last_seen_at END"}
+
+	assignments := clause.AssignmentColumns(columns)
+	assignments = append(assignments, clause.Assignment{Column: clause.Column{Name: "last_seen_at"}, Value: lastSeen})
+
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "uuid"}},
+		DoUpdates: assignments,
+	}).CreateInBatches(vessels, storeBatchSize).Error
+}
+
+// StoreVessel stores a vessel record from the vessel history endpoint,
+// refreshing the metadata of a known vessel
+func (r *VesselRepository) StoreVessel(ctx context.Context, vessel *models.VesselRecord) error {
+	vessels := []models.VesselRecord{*vessel}
+	if err := upsertVessels(r.db.WithContext(ctx), &vessels, historyVesselColumns); err != nil {
 		return fmt.Errorf("failed to store vessel: %w", err)
 	}
 
-	// If vessel already exists, update it
-	if vessel.ID != 0 {
-		err = r.db.Save(vessel).Error
-		if err != nil {
-			return fmt.Errorf("failed to update vessel: %w", err)
+	return nil
+}
+
+// GetKnownVessels returns every vessel ever observed, most recently seen first
+func (r *VesselRepository) GetKnownVessels(ctx context.Context) ([]models.VesselRecord, error) {
+	var vessels []models.VesselRecord
+	err := r.db.WithContext(ctx).Order("last_seen_at DESC NULLS LAST").Order("uuid").Find(&vessels).Error
+	return vessels, err
+}
+
+// VesselSearch narrows a search of the stored vessels; empty fields match
+// everything
+type VesselSearch struct {
+	Name  string // part of the name or AIS name, or a similar name where trigram search is supported
+	MMSI  string // MMSI prefix
+	Type  string // vessel type, case-insensitive
+	Limit int
+}
+
+// likeEscaper escapes the wildcards of LIKE patterns
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// SearchVessels returns the stored vessels matching the search, so vessels
+// already observed can be looked up without calling the provider. With
+// trigram search the closest names come first; otherwise, and among equally
+// close names, the most recently seen vessels do.
+func (r *VesselRepository) SearchVessels(ctx context.Context, search VesselSearch) ([]models.VesselRecord, error) {
+	query := r.db.WithContext(ctx)
+	order := clause.Expr{SQL: "last_seen_at DESC NULLS LAST, uuid", WithoutParentheses: true}
+
+	if search.Name != "" {
+		like := "LIKE"
+		if r.db.Dialector.Name() == "postgres" {
+			like = "ILIKE"
+		}
+		pattern := "%" + likeEscaper.Replace(search.Name) + "%"
+
+		if database.TrigramSearchSupported() {
+			query = query.Where("name "+like+` ? ESCAPE '\' OR name_ais `+like+` ? ESCAPE '\' OR name % ? OR name_ais % ?`, pattern, pattern, search.Name, search.Name)
+			order.SQL = "GREATEST(similarity(name, ?), similarity(name_ais, ?)) DESC, " + order.SQL
+			order.Vars = []interface{}{search.Name, search.Name}
+		} else {
+			query = query.Where("name "+like+` ? ESCAPE '\' OR name_ais `+like+` ? ESCAPE '\'`, pattern, pattern)
 		}
 	}
+	if search.MMSI != "" {
+		query = query.Where(`mmsi LIKE ? ESCAPE '\'`, likeEscaper.Replace(search.MMSI)+"%")
+	}
+	if search.Type != "" {
+		query = query.Where("LOWER(type) = LOWER(?)", search.Type)
+	}
+	if search.Limit > 0 {
+		query = query.Limit(search.Limit)
+	}
 
-	return nil
+	vessels := []models.VesselRecord{}
+	err := query.Clauses(clause.OrderBy{Expression: order}).Find(&vessels).Error
+	return vessels, err
+}
+
+// GetVessel returns the stored record of a vessel
+func (r *VesselRepository) GetVessel(ctx context.Context, vesselUUID string) (*models.VesselRecord, error) {
+	var vessel models.VesselRecord
+	if err := r.db.WithContext(ctx).Where("uuid = ?", vesselUUID).First(&vessel).Error; err != nil {
+		return nil, err
+	}
+	return &vessel, nil
+}
+
+// GetLatestPosition returns the most recent stored position of a vessel in a
+// park, or nil when none has been recorded
+func (r *VesselRepository) GetLatestPosition(ctx context.Context, parkID uint, vesselUUID string) (*models.VesselPositionRecord, error) {
+	var positions []models.VesselPositionRecord
+	err := r.db.WithContext(ctx).Where("park_id = ? AND vessel_uuid = ?", parkID, vesselUUID).
+		Order("recorded_at DESC").
+		Limit(1).
+		Find(&positions).Error
+	if err != nil || len(positions) == 0 {
+		return nil, err
+	}
+	return &positions[0], nil
 }
 
 // StoreVesselPosition stores a single vessel position record
-func (r *VesselRepository) StoreVesselPosition(position *models.VesselPositionRecord) error {
-	// Check if a position with the same vessel_uuid and last_pos_epoch already exists
+func (r *VesselRepository) StoreVesselPosition(ctx context.Context, position *models.VesselPositionRecord) error {
+	// Check if a position with the same vessel_uuid and last_pos_epoch already exists in the park
 	var existingPosition models.VesselPositionRecord
-	err := r.db.Where("vessel_uuid = ? AND last_position_epoch = ?", position.VesselUUID, position.LastPosEpoch).First(&existingPosition).Error
+	err := r.db.WithContext(ctx).Where("vessel_uuid = ? AND park_id = ? AND last_pos_epoch = ?", position.VesselUUID, position.ParkID, position.LastPosEpoch).First(&existingPosition).Error
 
 	if err == gorm.ErrRecordNotFound {
 		// Position doesn't exist, create new one
-		err = r.db.Create(position).Error
+		err = r.db.WithContext(ctx).Create(position).Error
 		if err != nil {
 			return fmt.Errorf("failed to create vessel position: %w", err)
 		}
@@ -187,29 +558,23 @@ func (r *VesselRepository) StoreVesselPosition(position *models.VesselPositionRe
 	return nil
 }
 
-func (r *VesselRepository) GetAvailableTimeRange() (time.Time, time.Time, error) {
+func (r *VesselRepository) GetAvailableTimeRange(ctx context.Context) (time.Time, time.Time, error) {
 	var earliest, latest time.Time
 
-	err := r.db.Model(&models.VesselPositionRecord{}).
-		Select("MIN(recorded_at)").
-		Scan(&earliest).Error
+	// Pluck the ordered column rather than MIN/MAX so drivers that keep
+	// timestamps as text (SQLite) still scan the result into time.Time
+	err := r.db.WithContext(ctx).Model(&models.VesselPositionRecord{}).
+		Order("recorded_at ASC").
+		Limit(1).
+		Pluck("recorded_at", &earliest).Error
 	if err != nil {
 		return earliest, latest, err
 	}
 
-	err = r.db.Model(&models.VesselPositionRecord{}).
-		Select("MAX(recorded_at)").
-		Scan(&latest).Error
+	err = r.db.WithContext(ctx).Model(&models.VesselPositionRecord{}).
+		Order("recorded_at DESC").
+		Limit(1).
+		Pluck("recorded_at", &latest).Error
 
 	return earliest, latest, err
 }
-
-func (r *VesselRepository) DeleteOldRecords(olderThan time.Time) error {
-	result := r.db.Where("recorded_at < ?", olderThan).Delete(&models.VesselPositionRecord{})
-	if result.Error != nil {
-		return result.Error
-	}
-
-	fmt.Printf("Deleted %d old vessel position records\n", result.RowsAffected)
-	return nil
-}
\ No newline at end of file
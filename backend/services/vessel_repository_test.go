@@ -0,0 +1,352 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+	"vessel-tracker/database"
+	"vessel-tracker/models"
+)
+
+// testDatabases returns the databases the repository tests run against:
+// in-memory SQLite always, and PostgreSQL when TEST_DATABASE_URL is set
+func testDatabases(t *testing.T) map[string]database.Config {
+	configs := map[string]database.Config{
+		"sqlite": {Driver: "sqlite", Path: ":memory:", LogLevel: "silent"},
+	}
+
+	rawURL := os.Getenv("TEST_DATABASE_URL")
+	if rawURL == "" {
+		return configs
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatalf("invalid TEST_DATABASE_URL: %v", err)
+	}
+	port := 5432
+	if parsed.Port() != "" {
+		if port, err = strconv.Atoi(parsed.Port()); err != nil {
+			t.Fatalf("invalid TEST_DATABASE_URL port: %v", err)
+		}
+	}
+	password, _ := parsed.User.Password()
+	sslMode := parsed.Query().Get("sslmode")
+	if sslMode == "" {
+		sslMode = "disable"
+	}
+	configs["postgres"] = database.Config{
+		Driver:   "postgres",
+		Host:     parsed.Hostname(),
+		Port:     port,
+		User:     parsed.User.Username(),
+		Password: password,
+		Name:     parsed.Path[1:],
+		SSLMode:  sslMode,
+		LogLevel: "silent",
+	}
+	return configs
+}
+
+// openTestDatabase connects to and migrates the database, closing it when
+// the test ends
+func openTestDatabase(t *testing.T, config database.Config) {
+	if err := database.InitDatabase(config); err != nil {
+		t.Fatalf("InitDatabase: %v", err)
+	}
+	t.Cleanup(func() {
+		database.Close()
+		database.DB = nil
+	})
+}
+
+func TestVesselRepositoryQueries(t *testing.T) {
+	for driver, config := range testDatabases(t) {
+		t.Run(driver, func(t *testing.T) {
+			openTestDatabase(t, config)
+			testVesselRepositoryQueries(t)
+		})
+	}
+}
+
+func testVesselRepositoryQueries(t *testing.T) {
+	ctx := context.Background()
+	repo := NewVesselRepository(DefaultPositionDedupConfig())
+
+	// A park and vessels of their own keep a shared PostgreSQL database usable
+	// across runs
+	run := time.Now().UnixNano()
+	parkID := uint(run%1_000_000) + 1_000_000
+	inside := fmt.Sprintf("test-inside-%d", run)
+	outside := fmt.Sprintf("test-outside-%d", run)
+	t.Cleanup(func() {
+		database.DB.Where("park_id = ?", parkID).Delete(&models.VesselPositionRecord{})
+		database.DB.Where("uuid IN ?", []string{inside, outside}).Delete(&models.VesselRecord{})
+	})
+
+	first := time.Date(2026, 6, 1, 10, 0, 0, 0, time.UTC)
+	second := first.Add(2 * time.Hour)
+	fetch := func(epoch int64, lat float64) []models.VesselPosition {
+		return []models.VesselPosition{
+			{UUID: inside, Name: "Inside", MMSI: "247000001", Latitude: lat, Longitude: 15.0, LastPosEpoch: epoch},
+			{UUID: outside, Name: "Outside", MMSI: "247000002", Latitude: lat, Longitude: 16.0, LastPosEpoch: epoch},
+		}
+	}
+	zones := []PositionZones{{InPark: true}, {InPark: false}}
+
+	result, err := repo.storeVesselData(ctx, parkID, fetch(first.Unix(), 43.0), zones, first)
+	if err != nil {
+		t.Fatalf("storeVesselData: %v", err)
+	}
+	if result.Stored != 2 {
+		t.Fatalf("stored %d positions, want 2", result.Stored)
+	}
+
+	// The same AIS reports again are skipped as duplicates
+	result, err = repo.storeVesselData(ctx, parkID, fetch(first.Unix(), 43.0), zones, first.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("storeVesselData: %v", err)
+	}
+	if result.Stored != 0 || result.DuplicatesSkipped != 2 {
+		t.Fatalf("stored %d and skipped %d repeated positions, want 0 and 2", result.Stored, result.DuplicatesSkipped)
+	}
+
+	if _, err := repo.storeVesselData(ctx, parkID, fetch(second.Unix(), 43.1), zones, second); err != nil {
+		t.Fatalf("storeVesselData: %v", err)
+	}
+
+	latestAt, err := repo.GetLatestRecordedAt(ctx, parkID)
+	if err != nil {
+		t.Fatalf("GetLatestRecordedAt: %v", err)
+	}
+	if latestAt == nil || !latestAt.Equal(second) {
+		t.Fatalf("latest recorded at %v, want %v", latestAt, second)
+	}
+
+	latest, err := repo.GetLatestVesselPositions(ctx, parkID)
+	if err != nil {
+		t.Fatalf("GetLatestVesselPositions: %v", err)
+	}
+	if len(latest) != 1 || latest[0].VesselUUID != inside || !latest[0].RecordedAt.Equal(second) {
+		t.Fatalf("latest positions in park = %+v, want only %s at %v", latest, inside, second)
+	}
+	if latest[0].Vessel.UUID != inside {
+		t.Fatalf("latest position vessel not preloaded: %+v", latest[0].Vessel)
+	}
+
+	atTime, err := repo.GetVesselPositionsAtTime(ctx, parkID, first.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("GetVesselPositionsAtTime: %v", err)
+	}
+	if len(atTime) != 2 {
+		t.Fatalf("got %d positions at time, want 2", len(atTime))
+	}
+	for _, position := range atTime {
+		if !position.RecordedAt.Equal(first) {
+			t.Fatalf("position of %s at time recorded at %v, want %v", position.VesselUUID, position.RecordedAt, first)
+		}
+	}
+
+	inPark, err := repo.GetVesselsInParkAtTime(ctx, parkID, second)
+	if err != nil {
+		t.Fatalf("GetVesselsInParkAtTime: %v", err)
+	}
+	if len(inPark) != 1 || inPark[0].VesselUUID != inside {
+		t.Fatalf("vessels in park at time = %+v, want only %s", inPark, inside)
+	}
+
+	next, err := repo.GetNextVesselPositions(ctx, parkID, []string{inside, outside}, first, second)
+	if err != nil {
+		t.Fatalf("GetNextVesselPositions: %v", err)
+	}
+	if len(next) != 2 || !next[inside].RecordedAt.Equal(second) {
+		t.Fatalf("next positions = %+v, want both vessels at %v", next, second)
+	}
+
+	history, err := repo.GetVesselHistory(ctx, parkID, inside, first, second, 0)
+	if err != nil {
+		t.Fatalf("GetVesselHistory: %v", err)
+	}
+	if len(history) != 2 || !history[0].RecordedAt.Equal(second) {
+		t.Fatalf("history = %+v, want 2 positions, most recent first", history)
+	}
+
+	between, err := repo.GetPositionsBetween(ctx, parkID, first, second, 10)
+	if err != nil {
+		t.Fatalf("GetPositionsBetween: %v", err)
+	}
+	if len(between) != 4 {
+		t.Fatalf("got %d positions between, want 4", len(between))
+	}
+
+	after, err := repo.GetPositionsAfter(ctx, parkID, first, second, 10)
+	if err != nil {
+		t.Fatalf("GetPositionsAfter: %v", err)
+	}
+	if len(after) != 2 {
+		t.Fatalf("got %d positions after, want 2", len(after))
+	}
+
+	position, err := repo.GetLatestPosition(ctx, parkID, outside)
+	if err != nil {
+		t.Fatalf("GetLatestPosition: %v", err)
+	}
+	if position == nil || position.Latitude != 43.1 {
+		t.Fatalf("latest position of %s = %+v, want latitude 43.1", outside, position)
+	}
+
+	// A replayed position already stored is not stored twice
+	replayed := &models.VesselPositionRecord{VesselUUID: inside, ParkID: parkID, Latitude: 43.1, Longitude: 15.0, LastPosEpoch: second.Unix(), RecordedAt: second}
+	if err := repo.StoreVesselPosition(ctx, replayed); err != nil {
+		t.Fatalf("StoreVesselPosition: %v", err)
+	}
+	recent, err := repo.GetRecentPositions(ctx, parkID, inside, first)
+	if err != nil {
+		t.Fatalf("GetRecentPositions: %v", err)
+	}
+	if len(recent) != 2 || !recent[0].RecordedAt.Equal(first) {
+		t.Fatalf("recent positions = %+v, want 2 positions, oldest first", recent)
+	}
+
+	vessels, err := repo.SearchVessels(ctx, VesselSearch{Name: "insi", MMSI: "247"})
+	if err != nil {
+		t.Fatalf("SearchVessels: %v", err)
+	}
+	found := false
+	for _, vessel := range vessels {
+		if vessel.UUID == outside {
+			t.Fatalf("search by name matched %s", outside)
+		}
+		found = found || vessel.UUID == inside
+	}
+	if !found {
+		t.Fatalf("search by name did not find %s", inside)
+	}
+
+	vessel, err := repo.GetVessel(ctx, inside)
+	if err != nil {
+		t.Fatalf("GetVessel: %v", err)
+	}
+	if vessel.LastSeenAt == nil || !vessel.LastSeenAt.Equal(second) {
+		t.Fatalf("vessel last seen at %v, want %v", vessel.LastSeenAt, second)
+	}
+
+	earliest, latestRange, err := repo.GetAvailableTimeRange(ctx)
+	if err != nil {
+		t.Fatalf("GetAvailableTimeRange: %v", err)
+	}
+	if earliest.After(first) || latestRange.Before(second) {
+		t.Fatalf("available range %v to %v does not cover %v to %v", earliest, latestRange, first, second)
+	}
+}
+
+func TestStoreVesselPositionSkipsStoredReport(t *testing.T) {
+	for driver, config := range testDatabases(t) {
+		t.Run(driver, func(t *testing.T) {
+			openTestDatabase(t, config)
+			testStoreVesselPositionSkipsStoredReport(t)
+		})
+	}
+}
+
+func testStoreVesselPositionSkipsStoredReport(t *testing.T) {
+	ctx := context.Background()
+	repo := NewVesselRepository(DefaultPositionDedupConfig())
+
+	uuid := fmt.Sprintf("test-replayed-%d", time.Now().UnixNano())
+	t.Cleanup(func() {
+		database.DB.Where("vessel_uuid = ?", uuid).Delete(&models.VesselPositionRecord{})
+		database.DB.Where("uuid = ?", uuid).Delete(&models.VesselRecord{})
+	})
+	if err := repo.StoreVessel(ctx, &models.VesselRecord{UUID: uuid, Name: "Test"}); err != nil {
+		t.Fatalf("StoreVessel: %v", err)
+	}
+
+	// The same AIS report replayed twice is stored once
+	reportedAt := time.Date(2026, 6, 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 2; i++ {
+		position := &models.VesselPositionRecord{
+			VesselUUID:   uuid,
+			Latitude:     41.2,
+			Longitude:    9.4,
+			LastPosEpoch: reportedAt.Unix(),
+			RecordedAt:   reportedAt,
+		}
+		if err := repo.StoreVesselPosition(ctx, position); err != nil {
+			t.Fatalf("StoreVesselPosition %d: %v", i+1, err)
+		}
+	}
+
+	var stored int64
+	if err := database.DB.Model(&models.VesselPositionRecord{}).Where("vessel_uuid = ?", uuid).Count(&stored).Error; err != nil {
+		t.Fatalf("count positions: %v", err)
+	}
+	if stored != 1 {
+		t.Fatalf("stored %d positions, want 1", stored)
+	}
+}
+
+func TestStoreVesselDataStoresVesselListedTwiceOnce(t *testing.T) {
+	for driver, config := range testDatabases(t) {
+		t.Run(driver, func(t *testing.T) {
+			openTestDatabase(t, config)
+			testStoreVesselDataStoresVesselListedTwiceOnce(t)
+		})
+	}
+}
+
+func testStoreVesselDataStoresVesselListedTwiceOnce(t *testing.T) {
+	ctx := context.Background()
+	repo := NewVesselRepository(DefaultPositionDedupConfig())
+
+	run := time.Now().UnixNano()
+	parkID := uint(run%1_000_000) + 2_000_000
+	uuid := fmt.Sprintf("test-listed-twice-%d", run)
+	t.Cleanup(func() {
+		database.DB.Where("park_id = ?", parkID).Delete(&models.VesselPositionRecord{})
+		database.DB.Where("uuid = ?", uuid).Delete(&models.VesselRecord{})
+	})
+
+	// The feed lists the vessel with an older report after its latest one
+	reportedAt := time.Date(2026, 6, 1, 10, 0, 0, 0, time.UTC)
+	fetch := []models.VesselPosition{
+		{UUID: uuid, Name: "Listed Twice", Latitude: 41.21, Longitude: 9.41, LastPosEpoch: reportedAt.Unix()},
+		{UUID: uuid, Name: "Listed Twice", Latitude: 41.20, Longitude: 9.40, LastPosEpoch: reportedAt.Add(-time.Minute).Unix()},
+	}
+	result, err := repo.storeVesselData(ctx, parkID, fetch, make([]PositionZones, len(fetch)), reportedAt)
+	if err != nil {
+		t.Fatalf("storeVesselData: %v", err)
+	}
+	if result.Stored != 1 || result.DuplicatesSkipped != 1 || result.Duplicate[0] || !result.Duplicate[1] {
+		t.Fatalf("result = %+v, want the first listing stored and the second skipped", result)
+	}
+
+	var positions []models.VesselPositionRecord
+	if err := database.DB.Where("park_id = ?", parkID).Find(&positions).Error; err != nil {
+		t.Fatalf("load positions: %v", err)
+	}
+	if len(positions) != 1 || positions[0].LastPosEpoch != reportedAt.Unix() {
+		t.Fatalf("stored positions = %+v, want only the report at %v", positions, reportedAt)
+	}
+
+	// A later listing with a newer report takes the place of the earlier one
+	fetch[1].LastPosEpoch = reportedAt.Add(2 * time.Minute).Unix()
+	fetch[0].LastPosEpoch = reportedAt.Add(time.Minute).Unix()
+	result, err = repo.storeVesselData(ctx, parkID, fetch, make([]PositionZones, len(fetch)), reportedAt.Add(5*time.Minute))
+	if err != nil {
+		t.Fatalf("storeVesselData: %v", err)
+	}
+	if result.Stored != 1 || !result.Duplicate[0] || result.Duplicate[1] {
+		t.Fatalf("result = %+v, want the second listing stored and the first skipped", result)
+	}
+	latest, err := repo.latestPositions(ctx, parkID, []string{uuid})
+	if err != nil {
+		t.Fatalf("latestPositions: %v", err)
+	}
+	if latest[uuid].LastPosEpoch != fetch[1].LastPosEpoch {
+		t.Fatalf("latest position = %+v, want the report at epoch %d", latest[uuid], fetch[1].LastPosEpoch)
+	}
+}
@@ -1,69 +1,63 @@
 package services
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
-	"math"
-	"net/http"
-	"net/url"
-	"time"
 	"vessel-tracker/models"
 )
 
-const (
-	BaseURL = "https://api.datalastic.com/api/v0"
-)
-
+// VesselService queries the configured vessel data provider
 type VesselService struct {
-	apiKey string
-	client *http.Client
+	provider VesselDataProvider
+	quota    *DatalasticQuota
 }
 
-func NewVesselService(apiKey string) *VesselService {
+func NewVesselService(provider VesselDataProvider) *VesselService {
 	return &VesselService{
-		apiKey: apiKey,
-		client: &http.Client{},
+		provider: provider,
 	}
 }
 
-func (s *VesselService) SearchVessels(params map[string]string) (*models.VesselResponse, error) {
-	endpoint := fmt.Sprintf("%s/vessel_find", BaseURL)
-
-	u, err := url.Parse(endpoint)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse URL: %w", err)
-	}
-
-	q := u.Query()
-	q.Set("api-key", s.apiKey)
-
-	for key, value := range params {
-		q.Set(key, value)
-	}
+// ProviderName returns the name of the vessel data provider in use
+func (s *VesselService) ProviderName() string {
+	return s.provider.Name()
+}
 
-	u.RawQuery = q.Encode()
+// EnableQuota counts the credits the Datalastic provider spends and refuses
+// calls the quota does not allow with ErrQuotaExhausted or ErrQuotaDeferred
+// before they reach the provider
+func (s *VesselService) EnableQuota(quota *DatalasticQuota) error {
+	datalastic, ok := s.provider.(*DatalasticProvider)
+	if !ok {
+		return fmt.Errorf("the credit quota requires VESSEL_PROVIDER=datalastic")
+	}
+	datalastic.client.Transport = quota.Transport(datalastic.client.Transport)
+	s.quota = quota
+	return nil
+}
 
-	resp, err := s.client.Get(u.String())
-	if err != nil {
-		return nil, fmt.Errorf("failed to make request: %w", err)
-	}
-	defer resp.Body.Close()
+// Quota returns the Datalastic credit quota, or nil when none is tracked
+func (s *VesselService) Quota() *DatalasticQuota {
+	return s.quota
+}
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
+// checkQuota returns the quota error a provider call made with ctx would
+// be refused with
+func (s *VesselService) checkQuota(ctx context.Context) error {
+	if s.quota == nil {
+		return nil
 	}
+	return s.quota.Check(ctx)
+}
 
-	var vesselResp models.VesselResponse
-	if err := json.NewDecoder(resp.Body).Decode(&vesselResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+func (s *VesselService) SearchVessels(ctx context.Context, params map[string]string) (*models.VesselResponse, error) {
+	if err := s.checkQuota(ctx); err != nil {
+		return nil, err
 	}
-
-	return &vesselResp, nil
+	return s.provider.SearchVessels(ctx, params)
 }
 
-func (s *VesselService) GetAllVessels(params map[string]string, maxResults int) ([]models.Vessel, error) {
+func (s *VesselService) GetAllVessels(ctx context.Context, params map[string]string, maxResults int) ([]models.Vessel, error) {
 	var allVessels []models.Vessel
 	nextToken := ""
 
@@ -72,7 +66,7 @@ func (s *VesselService) GetAllVessels(params map[string]string, maxResults int)
 			params["next"] = nextToken
 		}
 
-		response, err := s.SearchVessels(params)
+		response, err := s.SearchVessels(ctx, params)
 		if err != nil {
 			return nil, err
 		}
@@ -93,45 +87,16 @@ func (s *VesselService) GetAllVessels(params map[string]string, maxResults int)
 	return allVessels, nil
 }
 
-// GetVesselHistory fetches historical vessel data from Datalastic API
-func (s *VesselService) GetVesselHistoryFromAPI(params map[string]string) (*models.VesselHistoryResponse, error) {
-	endpoint := fmt.Sprintf("%s/vessel_history", BaseURL)
-
-	u, err := url.Parse(endpoint)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse URL: %w", err)
-	}
-
-	q := u.Query()
-	q.Set("api-key", s.apiKey)
-
-	// Add all parameters (uuid, mmsi, imo, days, from, to)
-	for key, value := range params {
-		q.Set(key, value)
+// GetVesselHistory fetches the track of a vessel from the provider. It
+// returns ErrProviderUnsupported when the provider keeps no history.
+func (s *VesselService) GetVesselHistory(ctx context.Context, params map[string]string) (*models.VesselHistoryResponse, error) {
+	if err := s.checkQuota(ctx); err != nil {
+		return nil, err
 	}
-
-	u.RawQuery = q.Encode()
-
-	resp, err := s.client.Get(u.String())
-	if err != nil {
-		return nil, fmt.Errorf("failed to make request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
-	}
-
-	var historyResp models.VesselHistoryResponse
-	if err := json.NewDecoder(resp.Body).Decode(&historyResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
-	}
-
-	return &historyResp, nil
+	return s.provider.GetHistory(ctx, params)
 }
 
-func (s *VesselService) GetVesselsByArea(minLat, maxLat, minLon, maxLon float64) ([]models.Vessel, error) {
+func (s *VesselService) GetVesselsByArea(ctx context.Context, minLat, maxLat, minLon, maxLon float64) ([]models.Vessel, error) {
 	// Note: The Datalastic API doesn't directly support area filtering
 	// You would need to use their vessel position endpoint or filter after fetching
 	// For now, we'll fetch vessels and you'll need to filter by position separately
@@ -140,71 +105,17 @@ func (s *VesselService) GetVesselsByArea(minLat, maxLat, minLon, maxLon float64)
 		"type": "Cargo,Tanker,Passenger,Fishing",
 	}
 
-	return s.GetAllVessels(params, 0) // No limit - return all vessels in area
-}
-
-func (s *VesselService) GetVesselsInRadius(lat, lon float64, radius int) (*models.VesselPositionResponse, error) {
-	return s.getVesselsInRadiusWithRetry(lat, lon, radius, 3)
+	return s.GetAllVessels(ctx, params, 0) // No limit - return all vessels in area
 }
 
-func (s *VesselService) getVesselsInRadiusWithRetry(lat, lon float64, radius int, maxRetries int) (*models.VesselPositionResponse, error) {
-	endpoint := fmt.Sprintf("%s/vessel_inradius", BaseURL)
-
-	u, err := url.Parse(endpoint)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse URL: %w", err)
-	}
-
-	q := u.Query()
-	q.Set("api-key", s.apiKey)
-	q.Set("lat", fmt.Sprintf("%.6f", lat))
-	q.Set("lon", fmt.Sprintf("%.6f", lon))
-	q.Set("radius", fmt.Sprintf("%d", radius))
-
-	u.RawQuery = q.Encode()
-
-	var lastErr error
-
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		if attempt > 0 {
-			// Exponential backoff: 2^attempt seconds with jitter
-			backoffSeconds := math.Pow(2, float64(attempt))
-			backoffDuration := time.Duration(backoffSeconds) * time.Second
-			fmt.Printf("Rate limit encountered, retrying in %.0f seconds (attempt %d/%d)...\n",
-				backoffSeconds, attempt+1, maxRetries)
-			time.Sleep(backoffDuration)
-		}
-
-		resp, err := s.client.Get(u.String())
-		if err != nil {
-			lastErr = fmt.Errorf("failed to make request: %w", err)
-			continue
-		}
-
-		if resp.StatusCode == http.StatusOK {
-			// Success - decode and return
-			var vesselResp models.VesselPositionResponse
-			if err := json.NewDecoder(resp.Body).Decode(&vesselResp); err != nil {
-				resp.Body.Close()
-				return nil, fmt.Errorf("failed to decode response: %w", err)
-			}
-			resp.Body.Close()
-			return &vesselResp, nil
-		}
-
-		// Read error response
-		body, _ := io.ReadAll(resp.Body)
-		resp.Body.Close()
-
-		if resp.StatusCode == 402 || resp.StatusCode == 429 {
-			// Rate limit - continue retrying
-			lastErr = fmt.Errorf("API rate limit (status %d): %s", resp.StatusCode, string(body))
-			continue
-		}
-
-		// Other error - don't retry
-		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
+func (s *VesselService) GetVesselsInRadius(ctx context.Context, lat, lon float64, radius int) (*models.VesselPositionResponse, error) {
+	if err := s.checkQuota(ctx); err != nil {
+		return nil, err
 	}
+	return s.provider.GetVesselsInRadius(ctx, lat, lon, radius)
+}
 
-	return nil, fmt.Errorf("max retries exceeded, last error: %v", lastErr)
-}
\ No newline at end of file
+// Ping checks that the provider is reachable
+func (s *VesselService) Ping(ctx context.Context) error {
+	return s.provider.Ping(ctx)
+}
@@ -0,0 +1,316 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+	"vessel-tracker/models"
+
+	geojson "github.com/paulmach/go.geojson"
+)
+
+const (
+	// evidenceTrailPositions is how many stored positions before the
+	// triggering one are kept as evidence
+	evidenceTrailPositions = 20
+
+	// evidenceWindowMeters is half the size of the square window around the
+	// triggering position that zone geometry is clipped to
+	evidenceWindowMeters = 2000.0
+)
+
+// ZoneEvidence returns the polygon of a layer that contains the point, or the
+// nearest one when none does, together with the version of the loaded layer.
+// A single buffer polygon can hold thousands of vertices, so the polygon is
+// clipped to a window of evidenceWindowMeters around the point. It returns
+// nil when the layer is not loaded.
+func (s *GeoService) ZoneEvidence(layer string, lat, lon float64) *models.EvidenceZone {
+	s.mu.RLock()
+	fc, payload := s.parkBoundaries, s.parkPayload
+	if layer == LayerBuffer {
+		fc, payload = s.bufferedBoundaries, s.bufferPayload
+	}
+	s.mu.RUnlock()
+
+	if fc == nil {
+		return nil
+	}
+
+	point := []float64{lon, lat}
+	var best [][][]float64
+	var bestFeature *geojson.Feature
+	var bestDistance *BoundaryDistance
+	inside := false
+
+	for _, feature := range fc.Features {
+		for _, polygon := range featurePolygons(feature.Geometry) {
+			if len(polygon) == 0 {
+				continue
+			}
+			distance := nearestOnRings(polygon, lat, lon)
+			if distance == nil {
+				continue
+			}
+			if s.isPointInPolygon(point, polygon[0]) {
+				if !inside || distance.DistanceMeters < bestDistance.DistanceMeters {
+					best, bestFeature, bestDistance = polygon, feature, distance
+				}
+				inside = true
+				continue
+			}
+			if !inside && (bestDistance == nil || distance.DistanceMeters < bestDistance.DistanceMeters) {
+				best, bestFeature, bestDistance = polygon, feature, distance
+			}
+		}
+	}
+
+	if best == nil {
+		return nil
+	}
+
+	window := evidenceWindow(lat, lon)
+	clipped := make([][][]float64, 0, len(best))
+	for _, ring := range best {
+		if ring = clipRing(ring, window); len(ring) >= 4 {
+			clipped = append(clipped, ring)
+		}
+	}
+
+	geometry, err := json.Marshal(geojson.NewPolygonGeometry(clipped))
+	if err != nil {
+		return nil
+	}
+
+	zone := &models.EvidenceZone{
+		Layer:                    layer,
+		Inside:                   inside,
+		DistanceToBoundaryMeters: bestDistance.DistanceMeters,
+		Properties:               bestFeature.Properties,
+		Window:                   window[:],
+		Geometry:                 geometry,
+	}
+	if payload != nil {
+		zone.Version = payload.ETag
+	}
+	return zone
+}
+
+// evidenceWindow returns the [min lon, min lat, max lon, max lat] window
+// around a point that evidence geometry is clipped to
+func evidenceWindow(lat, lon float64) [4]float64 {
+	dLat := evidenceWindowMeters / 111320.0
+	dLon := evidenceWindowMeters / (111320.0 * math.Cos(toRadians(lat)))
+	return [4]float64{lon - dLon, lat - dLat, lon + dLon, lat + dLat}
+}
+
+// clipRing clips a closed [lon, lat] ring to a window with the
+// Sutherland-Hodgman algorithm. Parts outside the window collapse onto its
+// edges; a ring entirely outside returns empty.
+func clipRing(ring [][]float64, window [4]float64) [][]float64 {
+	edges := []struct {
+		inside    func(p []float64) bool
+		intersect func(a, b []float64) []float64
+	}{
+		{func(p []float64) bool { return p[0] >= window[0] }, func(a, b []float64) []float64 { return intersectLon(a, b, window[0]) }},
+		{func(p []float64) bool { return p[0] <= window[2] }, func(a, b []float64) []float64 { return intersectLon(a, b, window[2]) }},
+		{func(p []float64) bool { return p[1] >= window[1] }, func(a, b []float64) []float64 { return intersectLat(a, b, window[1]) }},
+		{func(p []float64) bool { return p[1] <= window[3] }, func(a, b []float64) []float64 { return intersectLat(a, b, window[3]) }},
+	}
+
+	// Work on the open ring; it is closed again at the end
+	points := ring
+	if len(points) > 1 && points[0][0] == points[len(points)-1][0] && points[0][1] == points[len(points)-1][1] {
+		points = points[:len(points)-1]
+	}
+
+	for _, edge := range edges {
+		if len(points) == 0 {
+			return nil
+		}
+		input := points
+		points = make([][]float64, 0, len(input))
+		previous := input[len(input)-1]
+		for _, current := range input {
+			switch {
+			case edge.inside(current):
+				if !edge.inside(previous) {
+					points = append(points, edge.intersect(previous, current))
+				}
+				points = append(points, current)
+			case edge.inside(previous):
+				points = append(points, edge.intersect(previous, current))
+			}
+			previous = current
+		}
+	}
+
+	if len(points) == 0 {
+		return nil
+	}
+	return append(points, points[0])
+}
+
+func intersectLon(a, b []float64, lon float64) []float64 {
+	t := (lon - a[0]) / (b[0] - a[0])
+	return []float64{lon, a[1] + t*(b[1]-a[1])}
+}
+
+func intersectLat(a, b []float64, lat float64) []float64 {
+	t := (lat - a[1]) / (b[1] - a[1])
+	return []float64{a[0] + t*(b[0]-a[0]), lat}
+}
+
+// featurePolygons returns each polygon of a Polygon or MultiPolygon geometry
+func featurePolygons(g *geojson.Geometry) [][][][]float64 {
+	if g == nil {
+		return nil
+	}
+
+	switch g.Type {
+	case geojson.GeometryPolygon:
+		return [][][][]float64{g.Polygon}
+	case geojson.GeometryMultiPolygon:
+		return g.MultiPolygon
+	}
+
+	return nil
+}
+
+// captureEvidence collects the evidence for a violation detected at pos: the
+// vessel's preceding stored positions in the park, the park zones around the
+// position, the speed profile against the speed limit applying to the vessel
+// there and the whitelist check that let the violation through
+func (s *ViolationService) captureEvidence(park *Park, pos models.VesselPosition, speedLimit float64, whitelistCheckedAt time.Time) *models.ViolationEvidence {
+	evidence := &models.ViolationEvidence{
+		CapturedAt: time.Now(),
+		TriggeringPosition: models.EvidencePosition{
+			Latitude:     pos.Latitude,
+			Longitude:    pos.Longitude,
+			Speed:        pos.Speed,
+			Course:       pos.Course,
+			Heading:      pos.Heading,
+			LastPosEpoch: pos.LastPosEpoch,
+			LastPosUTC:   pos.LastPosUTC,
+		},
+		PreviousPositions: []models.EvidencePosition{},
+		Zones:             []models.EvidenceZone{},
+		Whitelist: models.WhitelistCheck{
+			VesselUUID:  pos.UUID,
+			MMSI:        pos.MMSI,
+			IMO:         pos.IMO,
+			Whitelisted: false,
+			CheckedAt:   whitelistCheckedAt,
+		},
+	}
+
+	current := park.Geo.TidalCurrents().At(pos.Latitude, pos.Longitude, positionTime(pos))
+	evidence.TriggeringPosition.Current = current
+	evidence.TriggeringPosition.SpeedThroughWater = current.SpeedThroughWater(pos.Speed, pos.Course)
+
+	// The triggering position has usually been stored already, so only
+	// positions reported before it form the trail
+	query := s.db.Where("vessel_uuid = ? AND park_id = ?", pos.UUID, park.Record.ID)
+	if pos.LastPosEpoch > 0 {
+		query = query.Where("last_pos_epoch < ?", pos.LastPosEpoch)
+	}
+
+	var trail []models.VesselPositionRecord
+	err := query.Order("last_pos_epoch DESC, recorded_at DESC").
+		Limit(evidenceTrailPositions).
+		Find(&trail).Error
+	if err != nil {
+		s.logger.Warn("Failed to load position trail for evidence", "vessel_uuid", pos.UUID, "error", err)
+	}
+
+	for _, record := range trail {
+		recordedAt := record.RecordedAt
+		evidence.PreviousPositions = append(evidence.PreviousPositions, models.EvidencePosition{
+			Latitude:     record.Latitude,
+			Longitude:    record.Longitude,
+			Speed:        record.Speed,
+			Course:       record.Course,
+			Heading:      record.Heading,
+			LastPosEpoch: record.LastPosEpoch,
+			LastPosUTC:   record.LastPosUTC,
+			RecordedAt:   &recordedAt,
+			Current:      record.Current(),
+
+			SpeedThroughWater: record.SpeedThroughWater,
+		})
+	}
+
+	for _, layer := range []string{LayerPark, LayerBuffer} {
+		if zone := park.Geo.ZoneEvidence(layer, pos.Latitude, pos.Longitude); zone != nil {
+			evidence.Zones = append(evidence.Zones, *zone)
+		}
+	}
+
+	evidence.SpeedProfile = speedProfile(evidence.TriggeringPosition, evidence.PreviousPositions, speedLimit)
+
+	return evidence
+}
+
+// speedProfile summarizes the speeds over ground over the trail and the
+// triggering position. Samples are over the limit by their speed through the
+// water where the current is known.
+func speedProfile(triggering models.EvidencePosition, previous []models.EvidencePosition, limit float64) models.SpeedProfile {
+	profile := models.SpeedProfile{
+		MinKnots:   math.Inf(1),
+		LimitKnots: limit,
+	}
+
+	var total float64
+	for _, position := range append([]models.EvidencePosition{triggering}, previous...) {
+		profile.Samples++
+		total += position.Speed
+		profile.MinKnots = math.Min(profile.MinKnots, position.Speed)
+		profile.MaxKnots = math.Max(profile.MaxKnots, position.Speed)
+		if limit > 0 && position.LimitSpeed() > limit {
+			profile.SamplesOverLimit++
+		}
+	}
+	profile.MeanKnots = total / float64(profile.Samples)
+
+	return profile
+}
+
+// BackfillEvidence captures the evidence of the violations of a park detected
+// before cutoff that were recorded without it, while the positions it is
+// drawn from are still stored. The whitelist check is taken at detection and
+// no speed limit is judged against, as neither is known any more.
+func (s *ViolationService) BackfillEvidence(ctx context.Context, park *Park, cutoff time.Time) (int, error) {
+	var violations []models.Violation
+	err := s.db.WithContext(ctx).
+		Where("park_id = ? AND detected_at < ? AND evidence IS NULL", park.Record.ID, cutoff).
+		Order("detected_at ASC").
+		Find(&violations).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to load violations without evidence: %w", err)
+	}
+
+	for i, violation := range violations {
+		pos := models.VesselPosition{
+			UUID:         violation.VesselUUID,
+			Name:         violation.VesselName,
+			MMSI:         violation.MMSI,
+			IMO:          violation.IMO,
+			Latitude:     violation.Latitude,
+			Longitude:    violation.Longitude,
+			Speed:        violation.Speed,
+			LastPosEpoch: violation.DetectedAt.Unix(),
+			LastPosUTC:   violation.DetectedAt.UTC().Format(time.RFC3339),
+		}
+		evidence := s.captureEvidence(park, pos, 0, violation.DetectedAt)
+
+		err := s.db.WithContext(ctx).Model(&models.Violation{}).
+			Where("id = ?", violation.ID).
+			Update("evidence", evidence).Error
+		if err != nil {
+			return i, fmt.Errorf("failed to store evidence of violation %d: %w", violation.ID, err)
+		}
+	}
+
+	return len(violations), nil
+}
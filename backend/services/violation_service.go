@@ -0,0 +1,854 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+	"vessel-tracker/database"
+	"vessel-tracker/logging"
+	"vessel-tracker/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// Speed limit inside the park in knots, matching the frontend default
+	parkSpeedLimit = 5.0
+)
+
+// ViolationEvent is the payload published to violation stream subscribers
+type ViolationEvent struct {
+	ID         uint      `json:"id"`
+	ParkID     uint      `json:"park_id"`
+	Type       string    `json:"type"`
+	Severity   string    `json:"severity"`
+	Vessel     VesselRef `json:"vessel"`
+	Latitude   float64   `json:"latitude"`
+	Longitude  float64   `json:"longitude"`
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+// VesselRef is a short vessel summary embedded in events
+type VesselRef struct {
+	UUID string `json:"uuid"`
+	Name string `json:"name"`
+	MMSI string `json:"mmsi"`
+	IMO  string `json:"imo"`
+}
+
+func NewViolationEvent(v *models.Violation) ViolationEvent {
+	return ViolationEvent{
+		ID:       v.ID,
+		ParkID:   v.ParkID,
+		Type:     v.Type,
+		Severity: v.Severity,
+		Vessel: VesselRef{
+			UUID: v.VesselUUID,
+			Name: v.VesselName,
+			MMSI: v.MMSI,
+			IMO:  v.IMO,
+		},
+		Latitude:   v.Latitude,
+		Longitude:  v.Longitude,
+		DetectedAt: v.DetectedAt,
+	}
+}
+
+type ViolationService struct {
+	db               *gorm.DB
+	whitelistService *WhitelistService
+	logger           *slog.Logger
+
+	mu          sync.RWMutex
+	subscribers map[chan ViolationEvent]struct{}
+	closed      bool
+}
+
+func NewViolationService(whitelistService *WhitelistService) *ViolationService {
+	return &ViolationService{
+		db:               database.GetDB(),
+		whitelistService: whitelistService,
+		logger:           logging.Component("violations"),
+		subscribers:      make(map[chan ViolationEvent]struct{}),
+	}
+}
+
+// Subscribe registers a listener for new violations. The returned function
+// must be called to release the subscription. The channel is closed by
+// CloseSubscriptions.
+func (s *ViolationService) Subscribe() (<-chan ViolationEvent, func()) {
+	ch := make(chan ViolationEvent, 32)
+
+	s.mu.Lock()
+	if s.closed {
+		close(ch)
+	} else {
+		s.subscribers[ch] = struct{}{}
+	}
+	s.mu.Unlock()
+
+	return ch, func() {
+		s.mu.Lock()
+		delete(s.subscribers, ch)
+		s.mu.Unlock()
+	}
+}
+
+// CloseSubscriptions closes the channel of every subscriber, current and
+// future, so open violation streams end and the server can shut down
+func (s *ViolationService) CloseSubscriptions() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	for ch := range s.subscribers {
+		close(ch)
+		delete(s.subscribers, ch)
+	}
+}
+
+func (s *ViolationService) publish(event ViolationEvent) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for ch := range s.subscribers {
+		select {
+		case ch <- event:
+		default:
+			// Slow subscriber, drop the event rather than block ingestion.
+			// Clients can catch up through Last-Event-ID on reconnect.
+		}
+	}
+}
+
+// RecordViolation persists a violation and notifies stream subscribers
+func (s *ViolationService) RecordViolation(violation *models.Violation) error {
+	if violation.Status == "" {
+		violation.Status = models.ViolationStatusOpen
+	}
+	if violation.DetectedAt.IsZero() {
+		violation.DetectedAt = time.Now()
+	}
+
+	if err := s.db.Create(violation).Error; err != nil {
+		return fmt.Errorf("failed to store violation: %w", err)
+	}
+
+	s.publish(NewViolationEvent(violation))
+	return nil
+}
+
+// GetViolation returns a single violation with its operator
+func (s *ViolationService) GetViolation(ctx context.Context, id uint) (*models.Violation, error) {
+	var violation models.Violation
+	if err := s.db.WithContext(ctx).Preload("Operator").First(&violation, id).Error; err != nil {
+		return nil, err
+	}
+	return &violation, nil
+}
+
+// GetLatestViolationID returns the ID of the latest violation, or 0 when there
+// is none
+func (s *ViolationService) GetLatestViolationID(ctx context.Context) (uint, error) {
+	var violation models.Violation
+	err := s.db.WithContext(ctx).Select("id").Order("id DESC").Take(&violation).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	return violation.ID, err
+}
+
+// GetViolationsSince returns violations with an ID greater than lastID in
+// ascending order, used to resume a stream from Last-Event-ID
+func (s *ViolationService) GetViolationsSince(ctx context.Context, lastID uint, limit int) ([]models.Violation, error) {
+	var violations []models.Violation
+	err := s.db.WithContext(ctx).Where("id > ?", lastID).Order("id ASC").Limit(limit).Find(&violations).Error
+	return violations, err
+}
+
+// GetVesselViolationSummary counts a vessel's violations detected since the
+// given time, in total, still open and by type
+func (s *ViolationService) GetVesselViolationSummary(vesselUUID string, since time.Time) (*models.VesselViolationSummary, error) {
+	var rows []struct {
+		Type   string
+		Status string
+		Count  int64
+	}
+
+	err := s.db.Model(&models.Violation{}).
+		Select("type, status, COUNT(*) as count").
+		Where("vessel_uuid = ? AND detected_at >= ?", vesselUUID, since).
+		Group("type, status").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &models.VesselViolationSummary{
+		Since:  since,
+		ByType: make(map[string]int64),
+	}
+	for _, row := range rows {
+		summary.Total += row.Count
+		summary.ByType[row.Type] += row.Count
+		if row.Status == models.ViolationStatusOpen {
+			summary.Open += row.Count
+		}
+	}
+
+	return summary, nil
+}
+
+// CountOpenBySeverity counts the open violations of every park by severity
+func (s *ViolationService) CountOpenBySeverity() (map[string]int64, error) {
+	var rows []struct {
+		Severity string
+		Count    int64
+	}
+
+	err := s.db.Model(&models.Violation{}).
+		Select("severity, COUNT(*) as count").
+		Where("status = ?", models.ViolationStatusOpen).
+		Group("severity").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to count open violations: %w", err)
+	}
+
+	counts := map[string]int64{
+		models.SeverityCritical:             0,
+		models.SeverityAuthorizedInfraction: 0,
+		models.SeverityHigh:                 0,
+		models.SeverityMedium:               0,
+		models.SeverityLow:                  0,
+	}
+	for _, row := range rows {
+		counts[row.Severity] += row.Count
+	}
+	return counts, nil
+}
+
+// vesselRecords loads the stored records of the vessels of the positions,
+// keyed by UUID; vessels without a record are left out
+func vesselRecords(db *gorm.DB, positions []models.VesselPosition) (map[string]*models.VesselRecord, error) {
+	uuids := make([]string, 0, len(positions))
+	seen := make(map[string]bool, len(positions))
+	for _, pos := range positions {
+		if !seen[pos.UUID] {
+			seen[pos.UUID] = true
+			uuids = append(uuids, pos.UUID)
+		}
+	}
+
+	records := make(map[string]*models.VesselRecord, len(uuids))
+	for start := 0; start < len(uuids); start += storeBatchSize {
+		end := start + storeBatchSize
+		if end > len(uuids) {
+			end = len(uuids)
+		}
+
+		var batch []models.VesselRecord
+		if err := db.Where("uuid IN ?", uuids[start:end]).Find(&batch).Error; err != nil {
+			return records, err
+		}
+		for i := range batch {
+			records[batch[i].UUID] = &batch[i]
+		}
+	}
+	return records, nil
+}
+
+// hasUnresolvedViolation checks whether a vessel already has a violation of
+// the given type in a park that was not resolved yet, whatever its triage
+// status, so acknowledging or dismissing a violation does not raise it again
+// while the vessel is still there
+func (s *ViolationService) hasUnresolvedViolation(parkID uint, vesselUUID, violationType string) bool {
+	var count int64
+	s.db.Model(&models.Violation{}).
+		Where("park_id = ? AND vessel_uuid = ? AND type = ? AND resolved_at IS NULL", parkID, vesselUUID, violationType).
+		Count(&count)
+	return count > 0
+}
+
+// resolvableViolationTypes are the violations evaluated on every fix by
+// DetectViolations, which resolves them once a fix no longer matches
+var resolvableViolationTypes = []string{
+	models.ViolationInBufferZone,
+	models.ViolationInRestrictedArea,
+	models.ViolationExcessiveSpeed,
+}
+
+// unresolvedViolations loads the unresolved violations of the given types in
+// a park, keyed by vessel UUID and type
+func (s *ViolationService) unresolvedViolations(parkID uint, types []string) (map[string]map[string]*models.Violation, error) {
+	var violations []models.Violation
+	err := s.db.Select("id, vessel_uuid, type, detected_at").
+		Where("park_id = ? AND type IN ? AND resolved_at IS NULL", parkID, types).
+		Find(&violations).Error
+	if err != nil {
+		return nil, err
+	}
+
+	unresolved := make(map[string]map[string]*models.Violation)
+	for i := range violations {
+		violation := &violations[i]
+		if unresolved[violation.VesselUUID] == nil {
+			unresolved[violation.VesselUUID] = make(map[string]*models.Violation)
+		}
+		unresolved[violation.VesselUUID][violation.Type] = violation
+	}
+	return unresolved, nil
+}
+
+// resolveViolation resolves a violation at the time of the fix showing the
+// vessel no longer breaks the rule, recording how long it lasted in the
+// violation and its history. An open violation becomes resolved; a triaged
+// one keeps its status. A fix reported before the violation was detected
+// resolves it at detection. It reports false when the violation was already
+// resolved or changed meanwhile.
+func (s *ViolationService) resolveViolation(violation *models.Violation, at time.Time) (bool, error) {
+	if at.Before(violation.DetectedAt) {
+		at = violation.DetectedAt
+	}
+	duration := at.Sub(violation.DetectedAt).Minutes()
+
+	resolved := false
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var current models.Violation
+		if err := tx.Select("id, status").First(&current, violation.ID).Error; err != nil {
+			return err
+		}
+		status := current.Status
+		if status == models.ViolationStatusOpen {
+			status = models.ViolationStatusResolved
+		}
+
+		result := tx.Model(&models.Violation{}).
+			Where("id = ? AND status = ? AND resolved_at IS NULL", violation.ID, current.Status).
+			Updates(map[string]interface{}{
+				"status":           status,
+				"resolved_at":      at,
+				"duration_minutes": duration,
+			})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		resolved = true
+		return tx.Create(&models.ViolationStatusChange{
+			ViolationID: violation.ID,
+			FromStatus:  current.Status,
+			ToStatus:    status,
+			Note:        fmt.Sprintf("Vessel no longer breaks the rule after %.0f min", duration),
+			Actor:       ViolationActorSystem,
+			ChangedAt:   at,
+		}).Error
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to resolve violation %d: %w", violation.ID, err)
+	}
+	return resolved, nil
+}
+
+// evaluateRules checks a position against every violation rule: access to
+// the buffer zone and to the park, and the speed limit, decided by the
+// park's zone rules for the vessel or else by the zone defaults, with
+// speedLimit as the default park limit. The reason of a matched rule becomes
+// the details of the violation.
+func evaluateRules(pos models.VesselPosition, vessel RuleVessel, zones PositionZones, rules []ZoneRule, speedLimit float64) []models.RuleEvaluation {
+	buffer := models.RuleEvaluation{
+		Rule:      models.ViolationInBufferZone,
+		Severity:  models.SeverityMedium,
+		Evaluated: true,
+		Reason:    "Position is outside the park buffer zone",
+	}
+	if zones.InBufferZone {
+		rule := accessRule(rules, LayerBuffer, vessel)
+		buffer.Matched = rule == nil || rule.Action == RuleActionDeny
+		buffer.Severity = ruleSeverity(rule, models.SeverityMedium)
+		switch {
+		case rule == nil:
+			buffer.Reason = "Vessel detected inside the park buffer zone"
+		case buffer.Matched:
+			buffer.ZoneRule = rule.Name
+			buffer.Reason = fmt.Sprintf("%s detected inside the park buffer zone, denied by rule %q", vessel.describe(), rule.Name)
+		default:
+			buffer.ZoneRule = rule.Name
+			buffer.Reason = fmt.Sprintf("%s is allowed in the park buffer zone by rule %q", vessel.describe(), rule.Name)
+		}
+	}
+
+	restricted := models.RuleEvaluation{
+		Rule:      models.ViolationInRestrictedArea,
+		Severity:  models.SeverityHigh,
+		Evaluated: true,
+		Reason:    "Position is outside the park",
+	}
+	if zones.InPark {
+		rule := accessRule(rules, LayerPark, vessel)
+		restricted.Reason = "Vessels are allowed in the park unless a rule denies them"
+		if rule != nil {
+			restricted.ZoneRule = rule.Name
+			restricted.Matched = rule.Action == RuleActionDeny
+			restricted.Severity = ruleSeverity(rule, models.SeverityHigh)
+			if restricted.Matched {
+				restricted.Reason = fmt.Sprintf("%s is not allowed in the park by rule %q", vessel.describe(), rule.Name)
+			} else {
+				restricted.Reason = fmt.Sprintf("%s is allowed in the park by rule %q", vessel.describe(), rule.Name)
+			}
+		}
+	}
+
+	// Speed limits are judged on the speed through the water, so a vessel
+	// carried by the tidal current is not taken for a speeding one
+	limit, limitRule := zoneSpeedLimit(rules, zones, vessel, speedLimit)
+	judged := zones.Current.ThroughWater(pos.Speed, pos.Course)
+	speed := models.RuleEvaluation{
+		Rule:      models.ViolationExcessiveSpeed,
+		Severity:  ruleSeverity(limitRule, models.SeverityMedium),
+		Evaluated: true,
+		Matched:   limit > 0 && judged > limit,
+	}
+	setBy := "park limit"
+	if limitRule != nil {
+		speed.ZoneRule = limitRule.Name
+		setBy = fmt.Sprintf("limit of rule %q", limitRule.Name)
+	}
+	described := fmt.Sprintf("Speed %.1f kn", pos.Speed)
+	if zones.Current != nil {
+		described = fmt.Sprintf("Speed through the water %.1f kn (%.1f kn over ground, current %.1f kn toward %03.0f deg)", judged, pos.Speed, zones.Current.SpeedKnots, zones.Current.DirectionDeg)
+	}
+	switch {
+	case speed.Matched:
+		speed.Reason = fmt.Sprintf("%s exceeds the %s of %.1f kn", described, setBy, limit)
+	case !zones.InPark && !zones.InBufferZone:
+		speed.Reason = "Position is outside the park, where the speed limit does not apply"
+	case limit == 0:
+		speed.Reason = "No speed limit applies to the vessel in the park buffer zone"
+	default:
+		speed.Reason = fmt.Sprintf("%s is within the %s of %.1f kn", described, setBy, limit)
+	}
+
+	return []models.RuleEvaluation{buffer, restricted, speed}
+}
+
+// RecordWatchlistSighting raises the alert for a watchlisted vessel sighted
+// around a park, as a critical violation, unless the vessel already has an
+// unresolved watchlist alert in the park. It reports whether an alert was recorded.
+func (s *ViolationService) RecordWatchlistSighting(park *Park, pos models.VesselPosition, entry *models.WatchlistEntry) (bool, error) {
+	if s.hasUnresolvedViolation(park.Record.ID, pos.UUID, models.ViolationWatchlistedVessel) {
+		return false, nil
+	}
+
+	whitelistCheckedAt := time.Now()
+	whitelisted := s.whitelistService.IsVesselWhitelisted(pos.UUID, pos.MMSI, pos.IMO)
+
+	details := fmt.Sprintf("Watchlisted vessel sighted %.1f NM from the park center", pos.Distance)
+	if entry.Reason != "" {
+		details += ": " + entry.Reason
+	}
+
+	evidence := s.captureEvidence(park, pos, 0, whitelistCheckedAt)
+	evidence.Whitelist.Whitelisted = whitelisted
+	currentSpeed, currentDirection := evidence.TriggeringPosition.Current.Columns()
+
+	violation := &models.Violation{
+		VesselUUID: pos.UUID,
+		ParkID:     park.Record.ID,
+		MMSI:       pos.MMSI,
+		IMO:        pos.IMO,
+		VesselName: pos.Name,
+		OperatorID: s.vesselOperatorID(pos.UUID),
+		Type:       models.ViolationWatchlistedVessel,
+		Severity:   models.SeverityCritical,
+		Latitude:   pos.Latitude,
+		Longitude:  pos.Longitude,
+		Speed:      pos.Speed,
+		Details:    details,
+		Evidence:   evidence,
+
+		CurrentSpeed:      currentSpeed,
+		CurrentDirection:  currentDirection,
+		SpeedThroughWater: evidence.TriggeringPosition.SpeedThroughWater,
+	}
+	if err := s.RecordViolation(violation); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RecordProjectedIntrusion raises the pre-alert for a vessel outside a park
+// whose projected track enters it, as a high severity violation at its
+// current position. Whitelisted vessels are not alerted unless a whitelist
+// exception reports them, nor vessels already pre-alerted in the park within
+// cooldown. It reports whether an alert was recorded.
+func (s *ViolationService) RecordProjectedIntrusion(park *Park, pos models.VesselPosition, intrusion *models.ProjectedIntrusion, cooldown time.Duration) (bool, error) {
+	var recent int64
+	err := s.db.Model(&models.Violation{}).
+		Where("park_id = ? AND vessel_uuid = ? AND type = ? AND detected_at >= ?", park.Record.ID, pos.UUID, models.ViolationProjectedIntrusion, time.Now().Add(-cooldown)).
+		Count(&recent).Error
+	if err != nil || recent > 0 {
+		return false, err
+	}
+
+	details := fmt.Sprintf("Projected to enter the park in %.0f min, around %s UTC at %.5f, %.5f, holding %.1f kn on course %.0f deg",
+		intrusion.Minutes, intrusion.Time.UTC().Format("15:04"), intrusion.Latitude, intrusion.Longitude, pos.Speed, pos.Course)
+	severity := models.SeverityHigh
+
+	whitelistCheckedAt := time.Now()
+	if s.whitelistService.IsVesselWhitelisted(pos.UUID, pos.MMSI, pos.IMO) {
+		exception := whitelistException(park.WhitelistExceptions, models.ViolationProjectedIntrusion, newRuleVessel(pos, s.vesselRecord(pos.UUID)))
+		if exception == nil {
+			return false, nil
+		}
+		severity = models.SeverityAuthorizedInfraction
+		details = fmt.Sprintf("%s; reported for a whitelisted vessel by exception %q", details, exception.Name)
+	}
+
+	evidence := s.captureEvidence(park, pos, 0, whitelistCheckedAt)
+	currentSpeed, currentDirection := evidence.TriggeringPosition.Current.Columns()
+
+	violation := &models.Violation{
+		VesselUUID: pos.UUID,
+		ParkID:     park.Record.ID,
+		MMSI:       pos.MMSI,
+		IMO:        pos.IMO,
+		VesselName: pos.Name,
+		OperatorID: s.vesselOperatorID(pos.UUID),
+		Type:       models.ViolationProjectedIntrusion,
+		Severity:   severity,
+		Latitude:   pos.Latitude,
+		Longitude:  pos.Longitude,
+		Speed:      pos.Speed,
+		Details:    details,
+		Evidence:   evidence,
+
+		CurrentSpeed:      currentSpeed,
+		CurrentDirection:  currentDirection,
+		SpeedThroughWater: evidence.TriggeringPosition.SpeedThroughWater,
+	}
+	if err := s.RecordViolation(violation); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RecordAnchorDrag raises the safety alert for a vessel dragging its anchor,
+// as a high severity violation at its latest fix, critical when the anchor is
+// dragged across a posidonia meadow. Whitelisted vessels are not alerted
+// unless a whitelist exception reports them, nor vessels whose drag is
+// already alerted and not resolved. It reports whether an alert was recorded.
+func (s *ViolationService) RecordAnchorDrag(park *Park, pos models.VesselPosition, drag *AnchorDrag) (bool, error) {
+	if s.hasUnresolvedViolation(park.Record.ID, pos.UUID, models.ViolationAnchorDragging) {
+		return false, nil
+	}
+
+	details := fmt.Sprintf("Dragging anchor: %.0f m from where it was set at %.5f, %.5f, beyond its %.0f m swing radius, bearing %.0f deg, moving out since %s UTC",
+		drag.DistanceMeters, drag.AnchorLatitude, drag.AnchorLongitude, drag.SwingRadiusMeters, drag.BearingDeg, drag.Since.UTC().Format("15:04"))
+	if drag.Wind != nil {
+		details = fmt.Sprintf("%s; wind %.0f kn gusting %.0f kn from %.0f deg", details, drag.Wind.WindSpeedKnots, drag.Wind.WindGustKnots, drag.Wind.WindDirectionDeg)
+	}
+	severity := models.SeverityHigh
+	if drag.OnPosidonia != nil {
+		severity = models.SeverityCritical
+		details = fmt.Sprintf("%s; dragged across the posidonia meadow of layer %q", details, drag.OnPosidonia.Name)
+	}
+	record := s.vesselRecord(pos.UUID)
+
+	whitelistCheckedAt := time.Now()
+	if s.whitelistService.IsVesselWhitelisted(pos.UUID, pos.MMSI, pos.IMO) {
+		exception := whitelistException(park.WhitelistExceptions, models.ViolationAnchorDragging, newRuleVessel(pos, record))
+		if exception == nil {
+			return false, nil
+		}
+		severity = models.SeverityAuthorizedInfraction
+		details = fmt.Sprintf("%s; reported for a whitelisted vessel by exception %q", details, exception.Name)
+	}
+
+	evidence := s.captureEvidence(park, pos, 0, whitelistCheckedAt)
+	currentSpeed, currentDirection := evidence.TriggeringPosition.Current.Columns()
+
+	violation := &models.Violation{
+		VesselUUID: pos.UUID,
+		ParkID:     park.Record.ID,
+		MMSI:       pos.MMSI,
+		IMO:        pos.IMO,
+		VesselName: pos.Name,
+		Type:       models.ViolationAnchorDragging,
+		Severity:   severity,
+		Latitude:   drag.Latest.Latitude,
+		Longitude:  drag.Latest.Longitude,
+		Speed:      drag.Latest.Speed,
+		Details:    details,
+		Evidence:   evidence,
+
+		CurrentSpeed:      currentSpeed,
+		CurrentDirection:  currentDirection,
+		SpeedThroughWater: evidence.TriggeringPosition.SpeedThroughWater,
+	}
+	if record != nil {
+		violation.OperatorID = record.OperatorID
+	}
+	if err := s.RecordViolation(violation); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ResolveAnchorDrag resolves the anchor drag alert of a vessel that weighed
+// anchor, at its fix. It reports whether an alert was resolved.
+func (s *ViolationService) ResolveAnchorDrag(park *Park, pos models.VesselPosition) (bool, error) {
+	var violations []models.Violation
+	err := s.db.Select("id, vessel_uuid, type, detected_at").
+		Where("park_id = ? AND vessel_uuid = ? AND type = ? AND resolved_at IS NULL", park.Record.ID, pos.UUID, models.ViolationAnchorDragging).
+		Find(&violations).Error
+	if err != nil {
+		return false, err
+	}
+
+	resolved := false
+	for i := range violations {
+		closed, err := s.resolveViolation(&violations[i], positionTime(pos))
+		if err != nil {
+			return resolved, err
+		}
+		resolved = resolved || closed
+	}
+	return resolved, nil
+}
+
+// DetectPosidoniaAnchoring records a high severity violation for each vessel
+// of the positions whose active anchoring event lies on a posidonia habitat
+// layer of the park, and resolves those of vessels that weighed anchor or
+// never were on a meadow. Whitelisted vessels are not alerted unless a
+// whitelist exception reports them. It runs after the anchoring analysis of
+// the positions and returns the number of violations detected and resolved.
+func (s *ViolationService) DetectPosidoniaAnchoring(park *Park, positions []models.VesselPosition) (int, int) {
+	detected, resolved := 0, 0
+	if len(positions) == 0 {
+		return 0, 0
+	}
+
+	uuids := make([]string, 0, len(positions))
+	for _, pos := range positions {
+		uuids = append(uuids, pos.UUID)
+	}
+	var events []models.AnchoringEvent
+	err := s.db.Where("park_id = ? AND vessel_uuid IN ? AND ended_at IS NULL", park.Record.ID, uuids).Find(&events).Error
+	if err != nil {
+		s.logger.Error("Failed to load anchoring events for posidonia detection", "park", park.Record.Slug, "error", err)
+		return 0, 0
+	}
+	anchored := make(map[string]*models.AnchoringEvent, len(events))
+	for i := range events {
+		anchored[events[i].VesselUUID] = &events[i]
+	}
+
+	unresolved, err := s.unresolvedViolations(park.Record.ID, []string{models.ViolationAnchoredOnPosidonia})
+	if err != nil {
+		s.logger.Error("Failed to load unresolved posidonia violations; none are resolved this cycle", "park", park.Record.Slug, "error", err)
+	}
+
+	now := time.Now()
+	for _, pos := range positions {
+		event := anchored[pos.UUID]
+		var meadow *models.HabitatLayer
+		if event != nil {
+			for _, layer := range park.Geo.HabitatLayersAt(event.Latitude, event.Longitude, now) {
+				if layer.Kind == models.HabitatKindPosidonia {
+					meadow = &layer
+					break
+				}
+			}
+		}
+
+		if meadow == nil {
+			violation, ok := unresolved[pos.UUID][models.ViolationAnchoredOnPosidonia]
+			if !ok {
+				continue
+			}
+			closed, err := s.resolveViolation(violation, positionTime(pos))
+			if err != nil {
+				s.logger.Error("Failed to resolve violation", "vessel_uuid", pos.UUID, "violation_id", violation.ID, "error", err)
+				continue
+			}
+			if closed {
+				resolved++
+			}
+			continue
+		}
+		if s.hasUnresolvedViolation(park.Record.ID, pos.UUID, models.ViolationAnchoredOnPosidonia) {
+			continue
+		}
+
+		details := fmt.Sprintf("Anchored on the posidonia meadow of layer %q for %.0f min, within %.0f m", meadow.Name, event.DwellMinutes, event.DriftRadiusMeters)
+		severity := models.SeverityHigh
+		record := s.vesselRecord(pos.UUID)
+
+		whitelistCheckedAt := time.Now()
+		if s.whitelistService.IsVesselWhitelisted(pos.UUID, pos.MMSI, pos.IMO) {
+			exception := whitelistException(park.WhitelistExceptions, models.ViolationAnchoredOnPosidonia, newRuleVessel(pos, record))
+			if exception == nil {
+				continue
+			}
+			severity = models.SeverityAuthorizedInfraction
+			details = fmt.Sprintf("%s; reported for a whitelisted vessel by exception %q", details, exception.Name)
+		}
+
+		evidence := s.captureEvidence(park, pos, 0, whitelistCheckedAt)
+		currentSpeed, currentDirection := evidence.TriggeringPosition.Current.Columns()
+
+		violation := &models.Violation{
+			VesselUUID: pos.UUID,
+			ParkID:     park.Record.ID,
+			MMSI:       pos.MMSI,
+			IMO:        pos.IMO,
+			VesselName: pos.Name,
+			Type:       models.ViolationAnchoredOnPosidonia,
+			Severity:   severity,
+			Latitude:   event.Latitude,
+			Longitude:  event.Longitude,
+			Speed:      pos.Speed,
+			Details:    details,
+			Evidence:   evidence,
+
+			CurrentSpeed:      currentSpeed,
+			CurrentDirection:  currentDirection,
+			SpeedThroughWater: evidence.TriggeringPosition.SpeedThroughWater,
+		}
+		if record != nil {
+			violation.OperatorID = record.OperatorID
+		}
+		if err := s.RecordViolation(violation); err != nil {
+			s.logger.Error("Failed to record violation", "vessel_uuid", pos.UUID, "type", violation.Type, "error", err)
+			continue
+		}
+		detected++
+	}
+
+	return detected, resolved
+}
+
+// vesselRecord returns the stored record of a vessel, or nil
+func (s *ViolationService) vesselRecord(vesselUUID string) *models.VesselRecord {
+	var record models.VesselRecord
+	if err := s.db.Where("uuid = ?", vesselUUID).Limit(1).Find(&record).Error; err == nil && record.UUID != "" {
+		return &record
+	}
+	return nil
+}
+
+// vesselOperatorID returns the operator a vessel is assigned to, or nil
+func (s *ViolationService) vesselOperatorID(vesselUUID string) *uint {
+	if record := s.vesselRecord(vesselUUID); record != nil {
+		return record.OperatorID
+	}
+	return nil
+}
+
+// DetectViolations evaluates positions freshly fetched for a park, records
+// new violations and resolves the unresolved violations of vessels whose fix no
+// longer breaks the rule. zones holds the classification of each position,
+// in the same order, as returned by the park's GeoService.ClassifyPositions.
+// It returns the number of violations detected and resolved.
+func (s *ViolationService) DetectViolations(park *Park, positions []models.VesselPosition, zones []PositionZones) (int, int) {
+	detected, resolved := 0, 0
+
+	records, err := vesselRecords(s.db, positions)
+	if err != nil {
+		s.logger.Error("Failed to load vessel details for the zone rules", "park", park.Record.Slug, "error", err)
+	}
+
+	unresolved, err := s.unresolvedViolations(park.Record.ID, resolvableViolationTypes)
+	if err != nil {
+		s.logger.Error("Failed to load unresolved violations; none are resolved this cycle", "park", park.Record.Slug, "error", err)
+	}
+
+	for i, pos := range positions {
+		whitelistCheckedAt := time.Now()
+		whitelisted := s.whitelistService.IsVesselWhitelisted(pos.UUID, pos.MMSI, pos.IMO)
+		// A vessel whitelisted after its violation was recorded still gets
+		// the violation resolved once the fix no longer breaks the rule
+		if whitelisted && len(park.WhitelistExceptions) == 0 && len(unresolved[pos.UUID]) == 0 {
+			continue
+		}
+
+		var operatorID *uint
+		record, ok := records[pos.UUID]
+		if ok {
+			operatorID = record.OperatorID
+		}
+		vessel := newRuleVessel(pos, record)
+
+		candidates := make([]models.Violation, 0, 3)
+		for _, rule := range evaluateRules(pos, vessel, zones[i], park.Rules, parkSpeedLimit) {
+			if !rule.Matched {
+				// A fix plotted on land is a bad fix and shows nothing about
+				// where the vessel is
+				violation, ok := unresolved[pos.UUID][rule.Rule]
+				if !ok || zones[i].OnLand {
+					continue
+				}
+				closed, err := s.resolveViolation(violation, positionTime(pos))
+				if err != nil {
+					s.logger.Error("Failed to resolve violation", "vessel_uuid", pos.UUID, "violation_id", violation.ID, "error", err)
+					continue
+				}
+				if closed {
+					resolved++
+				}
+				continue
+			}
+
+			if whitelisted && !exceptWhitelisted(&rule, park.WhitelistExceptions, vessel) {
+				continue
+			}
+			candidates = append(candidates, models.Violation{
+				Type:     rule.Rule,
+				Severity: rule.Severity,
+				Details:  rule.Reason,
+				Rule:     rule.ZoneRule,
+			})
+		}
+
+		// Evidence is shared by the violations of one position and only
+		// captured once one of them is actually recorded
+		var evidence *models.ViolationEvidence
+
+		for j := range candidates {
+			violation := &candidates[j]
+			if s.hasUnresolvedViolation(park.Record.ID, pos.UUID, violation.Type) {
+				continue
+			}
+
+			if evidence == nil {
+				limit, _ := zoneSpeedLimit(park.Rules, zones[i], vessel, parkSpeedLimit)
+				evidence = s.captureEvidence(park, pos, limit, whitelistCheckedAt)
+			}
+
+			violation.VesselUUID = pos.UUID
+			violation.ParkID = park.Record.ID
+			violation.MMSI = pos.MMSI
+			violation.IMO = pos.IMO
+			violation.VesselName = pos.Name
+			violation.OperatorID = operatorID
+			violation.Latitude = pos.Latitude
+			violation.Longitude = pos.Longitude
+			violation.Speed = pos.Speed
+			violation.CurrentSpeed, violation.CurrentDirection = zones[i].Current.Columns()
+			violation.SpeedThroughWater = zones[i].Current.SpeedThroughWater(pos.Speed, pos.Course)
+			violation.Evidence = evidence
+
+			if err := s.RecordViolation(violation); err != nil {
+				s.logger.Error("Failed to record violation", "vessel_uuid", pos.UUID, "type", violation.Type, "error", err)
+				continue
+			}
+			detected++
+		}
+	}
+
+	return detected, resolved
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"fmt"
+	"time"
+	"vessel-tracker/models"
+
+	"gorm.io/gorm"
+)
+
+// ViolationActorSystem is the actor of status changes made by the tracker
+// itself, such as the automatic resolution
+const ViolationActorSystem = "system"
+
+// Allowed manual violation status transitions. Dismissed and fined are final;
+// a violation resolved automatically can still be triaged.
+var violationTransitions = map[string][]string{
+	models.ViolationStatusOpen:         {models.ViolationStatusAcknowledged, models.ViolationStatusDismissed, models.ViolationStatusEscalated, models.ViolationStatusFined},
+	models.ViolationStatusResolved:     {models.ViolationStatusAcknowledged, models.ViolationStatusDismissed, models.ViolationStatusEscalated, models.ViolationStatusFined},
+	models.ViolationStatusAcknowledged: {models.ViolationStatusDismissed, models.ViolationStatusEscalated, models.ViolationStatusFined},
+	models.ViolationStatusEscalated:    {models.ViolationStatusDismissed, models.ViolationStatusFined},
+}
+
+// UpdateViolationStatus moves a violation to a new triage status and records
+// the change, with its note and actor, in the violation's history. An empty
+// status, or the current one, only adds the note to the history.
+func (s *ViolationService) UpdateViolationStatus(id uint, status, note, actor string) (*models.Violation, error) {
+	var violation models.Violation
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&violation, id).Error; err != nil {
+			return err
+		}
+
+		from := violation.Status
+		if status == "" {
+			status = from
+		}
+		if status != from {
+			allowed := false
+			for _, next := range violationTransitions[from] {
+				if next == status {
+					allowed = true
+					break
+				}
+			}
+			if !allowed {
+				return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, from, status)
+			}
+
+			// The automatic resolution may change the status meanwhile
+			result := tx.Model(&models.Violation{}).
+				Where("id = ? AND status = ?", id, from).
+				Update("status", status)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return fmt.Errorf("%w: violation %d changed meanwhile, retry", ErrInvalidTransition, id)
+			}
+			violation.Status = status
+		}
+
+		return tx.Create(&models.ViolationStatusChange{
+			ViolationID: id,
+			FromStatus:  from,
+			ToStatus:    status,
+			Note:        note,
+			Actor:       actor,
+			ChangedAt:   time.Now(),
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Violation status updated", "violation_id", id, "status", violation.Status, "actor", actor)
+	return &violation, nil
+}
+
+// ViolationHistoryFilter narrows a status history query; empty fields match
+// everything
+type ViolationHistoryFilter struct {
+	ViolationID uint
+	Actor       string
+	Status      string // the status changed to
+	Since       time.Time
+	Limit       int
+}
+
+// GetStatusHistory returns the status changes matching the filter, oldest
+// first
+func (s *ViolationService) GetStatusHistory(filter ViolationHistoryFilter) ([]models.ViolationStatusChange, error) {
+	changes := []models.ViolationStatusChange{}
+	query := s.db.Order("changed_at ASC, id ASC")
+	if filter.ViolationID != 0 {
+		query = query.Where("violation_id = ?", filter.ViolationID)
+	}
+	if filter.Actor != "" {
+		query = query.Where("actor = ?", filter.Actor)
+	}
+	if filter.Status != "" {
+		query = query.Where("to_status = ?", filter.Status)
+	}
+	if !filter.Since.IsZero() {
+		query = query.Where("changed_at >= ?", filter.Since)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	if err := query.Find(&changes).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch violation history: %w", err)
+	}
+	return changes, nil
+}
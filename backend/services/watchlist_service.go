@@ -0,0 +1,368 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+	"vessel-tracker/database"
+	"vessel-tracker/logging"
+	"vessel-tracker/models"
+
+	"gorm.io/gorm"
+)
+
+// WatchlistConfig sets when repeat offenders are added to the watchlist
+// without an administrator
+type WatchlistConfig struct {
+	AutoViolations int           // violations within AutoWindow that flag a vessel, 0 disables automatic flagging
+	AutoWindow     time.Duration // how far back violations are counted
+}
+
+func DefaultWatchlistConfig() WatchlistConfig {
+	return WatchlistConfig{
+		AutoViolations: 3,
+		AutoWindow:     90 * 24 * time.Hour,
+	}
+}
+
+// LoadWatchlistConfig reads WATCHLIST_AUTO_VIOLATIONS and
+// WATCHLIST_AUTO_WINDOW_DAYS, falling back to the defaults when unset
+func LoadWatchlistConfig() (WatchlistConfig, error) {
+	config := DefaultWatchlistConfig()
+
+	if value := os.Getenv("WATCHLIST_AUTO_VIOLATIONS"); value != "" {
+		count, err := strconv.Atoi(value)
+		if err != nil || count < 0 {
+			return config, fmt.Errorf("invalid WATCHLIST_AUTO_VIOLATIONS %q: must be a non-negative integer", value)
+		}
+		config.AutoViolations = count
+	}
+
+	if value := os.Getenv("WATCHLIST_AUTO_WINDOW_DAYS"); value != "" {
+		days, err := strconv.Atoi(value)
+		if err != nil || days < 1 {
+			return config, fmt.Errorf("invalid WATCHLIST_AUTO_WINDOW_DAYS %q: must be a positive integer", value)
+		}
+		config.AutoWindow = time.Duration(days) * 24 * time.Hour
+	}
+
+	return config, nil
+}
+
+// ErrWatchlistEntryExists is returned when a vessel already has an active
+// watchlist entry
+var ErrWatchlistEntryExists = errors.New("the vessel is already on the watchlist")
+
+// WatchlistService manages the watchlist and raises an alert whenever a
+// watchlisted vessel is sighted. Entries are read from the database on every
+// check, so changes made on any instance apply from the next fetch.
+type WatchlistService struct {
+	db               *gorm.DB
+	config           WatchlistConfig
+	violationService *ViolationService
+	logger           *slog.Logger
+}
+
+func NewWatchlistService(config WatchlistConfig, violationService *ViolationService) *WatchlistService {
+	return &WatchlistService{
+		db:               database.GetDB(),
+		config:           config,
+		violationService: violationService,
+		logger:           logging.Component("watchlist"),
+	}
+}
+
+// Config returns the automatic flagging settings
+func (s *WatchlistService) Config() WatchlistConfig {
+	return s.config
+}
+
+// GetEntries lists watchlist entries, newest first, only the active ones
+// unless includeInactive is set
+func (s *WatchlistService) GetEntries(includeInactive bool) ([]models.WatchlistEntry, error) {
+	var entries []models.WatchlistEntry
+	query := s.db.Order("created_at DESC")
+	if !includeInactive {
+		query = query.Where("is_active = ?", true)
+	}
+	err := query.Find(&entries).Error
+	return entries, err
+}
+
+// GetEntry returns a single watchlist entry
+func (s *WatchlistService) GetEntry(id uint) (*models.WatchlistEntry, error) {
+	var entry models.WatchlistEntry
+	if err := s.db.First(&entry, id).Error; err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// AddEntry puts a vessel on the watchlist. A vessel matching an active entry
+// by UUID, MMSI or IMO is refused with ErrWatchlistEntryExists.
+func (s *WatchlistService) AddEntry(entry *models.WatchlistEntry) error {
+	if existing, err := s.findActive(entry.VesselUUID, entry.MMSI, entry.IMO); err != nil {
+		return err
+	} else if existing != nil {
+		return ErrWatchlistEntryExists
+	}
+
+	if entry.Source == "" {
+		entry.Source = models.WatchlistSourceManual
+	}
+	entry.IsActive = true
+	if err := s.db.Create(entry).Error; err != nil {
+		return fmt.Errorf("failed to add watchlist entry: %w", err)
+	}
+	return nil
+}
+
+// UpdateEntry changes the name and reason of an entry, and reactivates or
+// removes it when active is set
+func (s *WatchlistService) UpdateEntry(id uint, name, reason *string, active *bool) (*models.WatchlistEntry, error) {
+	entry, err := s.GetEntry(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if name != nil {
+		entry.Name = *name
+	}
+	if reason != nil {
+		entry.Reason = *reason
+	}
+	if active != nil && *active && !entry.IsActive {
+		existing, err := s.findActive(entry.VesselUUID, entry.MMSI, entry.IMO)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			return nil, ErrWatchlistEntryExists
+		}
+	}
+	if active != nil {
+		entry.IsActive = *active
+	}
+
+	if err := s.db.Save(entry).Error; err != nil {
+		return nil, fmt.Errorf("failed to update watchlist entry: %w", err)
+	}
+	return entry, nil
+}
+
+// RemoveEntry takes a vessel off the watchlist. The entry is kept inactive.
+func (s *WatchlistService) RemoveEntry(id uint) (*models.WatchlistEntry, error) {
+	inactive := false
+	return s.UpdateEntry(id, nil, nil, &inactive)
+}
+
+// findActive returns the active entry matching any of the identifiers, or
+// nil when there is none
+func (s *WatchlistService) findActive(uuid, mmsi, imo string) (*models.WatchlistEntry, error) {
+	query := s.db.Where("1 = 0")
+	if uuid != "" {
+		query = query.Or("vessel_uuid = ?", uuid)
+	}
+	if mmsi != "" {
+		query = query.Or("mmsi = ?", mmsi)
+	}
+	if imo != "" {
+		query = query.Or("imo = ?", imo)
+	}
+
+	var entries []models.WatchlistEntry
+	if err := s.db.Where("is_active = ?", true).Where(query).Limit(1).Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	return &entries[0], nil
+}
+
+// watchlistIndex looks entries up by UUID, MMSI and IMO
+type watchlistIndex map[string]*models.WatchlistEntry
+
+func newWatchlistIndex(entries []models.WatchlistEntry) watchlistIndex {
+	index := make(watchlistIndex, len(entries))
+	for i := range entries {
+		entry := &entries[i]
+		if entry.VesselUUID != "" {
+			index[entry.VesselUUID] = entry
+		}
+		if entry.MMSI != "" {
+			index["mmsi:"+entry.MMSI] = entry
+		}
+		if entry.IMO != "" {
+			index["imo:"+entry.IMO] = entry
+		}
+	}
+	return index
+}
+
+func (index watchlistIndex) match(pos models.VesselPosition) *models.WatchlistEntry {
+	if entry, ok := index[pos.UUID]; ok && pos.UUID != "" {
+		return entry
+	}
+	if entry, ok := index["mmsi:"+pos.MMSI]; ok && pos.MMSI != "" {
+		return entry
+	}
+	if entry, ok := index["imo:"+pos.IMO]; ok && pos.IMO != "" {
+		return entry
+	}
+	return nil
+}
+
+// CheckPositions raises an alert for every watchlisted vessel among the
+// positions fetched around a park, wherever it is within the search radius.
+// The alert is a critical violation published on the violation stream; a
+// vessel is alerted once per park until its alert is closed. It returns the
+// number of alerts raised.
+func (s *WatchlistService) CheckPositions(park *Park, positions []models.VesselPosition) (int, error) {
+	entries, err := s.GetEntries(false)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load watchlist: %w", err)
+	}
+	if len(entries) == 0 {
+		return 0, nil
+	}
+
+	index := newWatchlistIndex(entries)
+	alerted := make(map[string]bool)
+	raised := 0
+	for _, pos := range positions {
+		entry := index.match(pos)
+		if entry == nil || alerted[pos.UUID] {
+			continue
+		}
+		alerted[pos.UUID] = true
+
+		recorded, err := s.violationService.RecordWatchlistSighting(park, pos, entry)
+		if err != nil {
+			s.logger.Error("Failed to record watchlist alert", "park", park.Record.Slug, "vessel_uuid", pos.UUID, "error", err)
+			continue
+		}
+		if recorded {
+			s.logger.Error("WATCHLIST ALERT", "park", park.Record.Slug, "vessel_uuid", pos.UUID, "mmsi", pos.MMSI, "name", pos.Name, "entry_id", entry.ID, "distance_nm", pos.Distance)
+			raised++
+		}
+	}
+	return raised, nil
+}
+
+// alertViolationTypes are the violations raised as alerts rather than for
+// anything the vessel did
+var alertViolationTypes = []string{models.ViolationWatchlistedVessel, models.ViolationProjectedIntrusion, models.ViolationAnchorDragging}
+
+// FlagRepeatOffenders adds the vessels among the positions with at least
+// AutoViolations violations within AutoWindow to the watchlist. Watchlist
+// alerts, projected intrusion pre-alerts and anchor dragging alerts are not
+// counted. A vessel an administrator took off the watchlist is only flagged
+// again for violations detected after its removal. It returns the number of
+// vessels added.
+func (s *WatchlistService) FlagRepeatOffenders(positions []models.VesselPosition) (int, error) {
+	if s.config.AutoViolations == 0 || len(positions) == 0 {
+		return 0, nil
+	}
+
+	byUUID := make(map[string]models.VesselPosition, len(positions))
+	uuids := make([]string, 0, len(positions))
+	for _, pos := range positions {
+		if _, seen := byUUID[pos.UUID]; !seen && pos.UUID != "" {
+			byUUID[pos.UUID] = pos
+			uuids = append(uuids, pos.UUID)
+		}
+	}
+
+	since := time.Now().Add(-s.config.AutoWindow)
+	var offenders []struct {
+		VesselUUID string
+		Count      int64
+	}
+	for start := 0; start < len(uuids); start += storeBatchSize {
+		end := start + storeBatchSize
+		if end > len(uuids) {
+			end = len(uuids)
+		}
+
+		var batch []struct {
+			VesselUUID string
+			Count      int64
+		}
+		err := s.db.Model(&models.Violation{}).
+			Select("vessel_uuid, COUNT(*) as count").
+			Where("vessel_uuid IN ? AND detected_at >= ? AND type NOT IN ?", uuids[start:end], since, alertViolationTypes).
+			Group("vessel_uuid").
+			Having("COUNT(*) >= ?", s.config.AutoViolations).
+			Scan(&batch).Error
+		if err != nil {
+			return 0, fmt.Errorf("failed to count violations: %w", err)
+		}
+		offenders = append(offenders, batch...)
+	}
+
+	added := 0
+	for _, offender := range offenders {
+		pos := byUUID[offender.VesselUUID]
+		flagged, err := s.flagOffender(pos, offender.Count, since)
+		if err != nil {
+			s.logger.Error("Failed to add repeat offender to the watchlist", "vessel_uuid", pos.UUID, "error", err)
+			continue
+		}
+		if flagged {
+			added++
+		}
+	}
+	return added, nil
+}
+
+// flagOffender adds a vessel with count recent violations to the watchlist
+// unless it is on it already or was taken off it since
+func (s *WatchlistService) flagOffender(pos models.VesselPosition, count int64, since time.Time) (bool, error) {
+	existing, err := s.findActive(pos.UUID, pos.MMSI, pos.IMO)
+	if err != nil || existing != nil {
+		return false, err
+	}
+
+	var removed models.WatchlistEntry
+	err = s.db.Where("vessel_uuid = ? AND is_active = ?", pos.UUID, false).
+		Order("updated_at DESC").
+		Limit(1).
+		Find(&removed).Error
+	if err != nil {
+		return false, err
+	}
+	if removed.ID != 0 && removed.UpdatedAt.After(since) {
+		if err := s.db.Model(&models.Violation{}).
+			Where("vessel_uuid = ? AND detected_at > ? AND type NOT IN ?", pos.UUID, removed.UpdatedAt, alertViolationTypes).
+			Count(&count).Error; err != nil {
+			return false, err
+		}
+		if count < int64(s.config.AutoViolations) {
+			return false, nil
+		}
+	}
+
+	noun := "violations"
+	if count == 1 {
+		noun = "violation"
+	}
+	entry := &models.WatchlistEntry{
+		VesselUUID: pos.UUID,
+		MMSI:       pos.MMSI,
+		IMO:        pos.IMO,
+		Name:       pos.Name,
+		Reason:     fmt.Sprintf("%d %s in the last %d days", count, noun, int(s.config.AutoWindow.Hours()/24)),
+		Source:     models.WatchlistSourceAutomatic,
+		Violations: count,
+		AddedBy:    "system",
+	}
+	if err := s.AddEntry(entry); err != nil {
+		return false, err
+	}
+	s.logger.Warn("Added repeat offender to the watchlist", "vessel_uuid", pos.UUID, "mmsi", pos.MMSI, "name", pos.Name, "violations", count)
+	return true, nil
+}
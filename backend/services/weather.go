@@ -0,0 +1,252 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+	"vessel-tracker/database"
+	"vessel-tracker/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultWeatherURL is the Open-Meteo forecast API, which reports current
+// conditions without an API key
+const DefaultWeatherURL = "https://api.open-meteo.com/v1/forecast"
+
+// weatherSource names the source stored with each observation
+const weatherSource = "open-meteo"
+
+// weatherVariables are the current conditions requested from Open-Meteo
+const weatherVariables = "temperature_2m,wind_speed_10m,wind_gusts_10m,wind_direction_10m,pressure_msl,precipitation,cloud_cover,weather_code"
+
+// WeatherConfig holds whether the weather at each park is fetched with the
+// vessel data and how long an observation describes the conditions after it
+type WeatherConfig struct {
+	Enabled bool
+	URL     string
+	Timeout time.Duration
+	MaxAge  time.Duration // frames further than this from the latest observation get none
+}
+
+func DefaultWeatherConfig() WeatherConfig {
+	return WeatherConfig{
+		URL:     DefaultWeatherURL,
+		Timeout: 10 * time.Second,
+		MaxAge:  2 * time.Hour,
+	}
+}
+
+// LoadWeatherConfig reads WEATHER_ENABLED, WEATHER_API_URL, WEATHER_TIMEOUT
+// and WEATHER_MAX_AGE
+func LoadWeatherConfig() (WeatherConfig, error) {
+	config := DefaultWeatherConfig()
+
+	if value := os.Getenv("WEATHER_ENABLED"); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return config, fmt.Errorf("invalid WEATHER_ENABLED %q: %w", value, err)
+		}
+		config.Enabled = enabled
+	}
+	if value := os.Getenv("WEATHER_API_URL"); value != "" {
+		if _, err := url.ParseRequestURI(value); err != nil {
+			return config, fmt.Errorf("invalid WEATHER_API_URL %q: %w", value, err)
+		}
+		config.URL = value
+	}
+
+	durations := map[string]*time.Duration{
+		"WEATHER_TIMEOUT": &config.Timeout,
+		"WEATHER_MAX_AGE": &config.MaxAge,
+	}
+	for name, target := range durations {
+		if value := os.Getenv(name); value != "" {
+			d, err := time.ParseDuration(value)
+			if err != nil || d <= 0 {
+				return config, fmt.Errorf("invalid %s %q: must be a positive duration", name, value)
+			}
+			*target = d
+		}
+	}
+
+	return config, nil
+}
+
+// WeatherService stores the weather at each park once per fetch cycle, so the
+// conditions during a past incident can be shown next to its playback
+type WeatherService struct {
+	db     *gorm.DB
+	config WeatherConfig
+	client *http.Client
+}
+
+func NewWeatherService(config WeatherConfig) *WeatherService {
+	return &WeatherService{
+		db:     database.GetDB(),
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+	}
+}
+
+// Config returns the active weather settings
+func (s *WeatherService) Config() WeatherConfig {
+	return s.config
+}
+
+// openMeteoResponse is the part of an Open-Meteo forecast response read for
+// the current conditions
+type openMeteoResponse struct {
+	Current struct {
+		Time          string  `json:"time"`
+		Temperature   float64 `json:"temperature_2m"`
+		WindSpeed     float64 `json:"wind_speed_10m"`
+		WindGusts     float64 `json:"wind_gusts_10m"`
+		WindDirection float64 `json:"wind_direction_10m"`
+		Pressure      float64 `json:"pressure_msl"`
+		Precipitation float64 `json:"precipitation"`
+		CloudCover    float64 `json:"cloud_cover"`
+		WeatherCode   int     `json:"weather_code"`
+	} `json:"current"`
+}
+
+// RecordObservation fetches the current weather at the center of a park and
+// stores it. An observation already stored for the same time is kept.
+func (s *WeatherService) RecordObservation(ctx context.Context, park *Park) (*models.WeatherObservation, error) {
+	lat, lon := park.Geo.GetParkCenter()
+
+	u, err := url.Parse(s.config.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse URL: %w", err)
+	}
+	q := u.Query()
+	q.Set("latitude", strconv.FormatFloat(lat, 'f', 5, 64))
+	q.Set("longitude", strconv.FormatFloat(lon, 'f', 5, 64))
+	q.Set("current", weatherVariables)
+	q.Set("wind_speed_unit", "kn")
+	q.Set("timezone", "UTC")
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("weather API returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var weather openMeteoResponse
+	if err := json.NewDecoder(resp.Body).Decode(&weather); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	observedAt, err := time.Parse("2006-01-02T15:04", weather.Current.Time)
+	if err != nil {
+		return nil, fmt.Errorf("invalid observation time %q: %w", weather.Current.Time, err)
+	}
+
+	observation := &models.WeatherObservation{
+		ParkID:           park.Record.ID,
+		ObservedAt:       observedAt,
+		FetchedAt:        time.Now(),
+		Source:           weatherSource,
+		Latitude:         lat,
+		Longitude:        lon,
+		TemperatureC:     weather.Current.Temperature,
+		WindSpeedKnots:   weather.Current.WindSpeed,
+		WindGustKnots:    weather.Current.WindGusts,
+		WindDirectionDeg: weather.Current.WindDirection,
+		PressureHPa:      weather.Current.Pressure,
+		PrecipitationMm:  weather.Current.Precipitation,
+		CloudCoverPct:    weather.Current.CloudCover,
+		WeatherCode:      weather.Current.WeatherCode,
+	}
+	err = s.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(observation).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to store weather observation: %w", err)
+	}
+	return observation, nil
+}
+
+// GetHistory returns the observations of a park from start to end and, for
+// each frame time start to end step apart (the frames of a playback with the
+// same range and step), the latest observation at or before it within MaxAge
+func (s *WeatherService) GetHistory(ctx context.Context, park *Park, start, end time.Time, step time.Duration) (*models.WeatherHistory, error) {
+	history := &models.WeatherHistory{
+		Park:   park.Record.Slug,
+		Start:  start,
+		End:    end,
+		Step:   step.String(),
+		MaxAge: s.config.MaxAge.String(),
+		Frames: make([]models.WeatherFrame, 0, PlaybackFrameCount(start, end, step)),
+	}
+
+	// The observation in force at start may predate it by up to MaxAge
+	var observations []models.WeatherObservation
+	err := s.db.WithContext(ctx).
+		Where("park_id = ? AND observed_at >= ? AND observed_at <= ?", park.Record.ID, start.Add(-s.config.MaxAge), end).
+		Order("observed_at ASC").
+		Find(&observations).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch weather observations: %w", err)
+	}
+
+	history.Observations = make([]models.WeatherObservation, 0, len(observations))
+	for _, observation := range observations {
+		if !observation.ObservedAt.Before(start) {
+			history.Observations = append(history.Observations, observation)
+		}
+	}
+
+	// i is the index of the last observation at or before the frame
+	i := -1
+	for at := start; !at.After(end); at = at.Add(step) {
+		for i+1 < len(observations) && !observations[i+1].ObservedAt.After(at) {
+			i++
+		}
+
+		frame := models.WeatherFrame{Timestamp: at}
+		if i >= 0 {
+			if age := at.Sub(observations[i].ObservedAt); age <= s.config.MaxAge {
+				frame.Observation = &observations[i]
+				frame.AgeSeconds = int64(age.Round(time.Second) / time.Second)
+			}
+		}
+		history.Frames = append(history.Frames, frame)
+	}
+
+	return history, nil
+}
+
+// Latest returns the latest observation of a park at or before at and
+// within MaxAge of it, nil when there is none
+func (s *WeatherService) Latest(ctx context.Context, parkID uint, at time.Time) (*models.WeatherObservation, error) {
+	var observations []models.WeatherObservation
+	err := s.db.WithContext(ctx).
+		Where("park_id = ? AND observed_at <= ? AND observed_at >= ?", parkID, at, at.Add(-s.config.MaxAge)).
+		Order("observed_at DESC").
+		Limit(1).
+		Find(&observations).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the latest weather observation: %w", err)
+	}
+	if len(observations) == 0 {
+		return nil, nil
+	}
+	return &observations[0], nil
+}
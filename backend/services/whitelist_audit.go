@@ -0,0 +1,94 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"vessel-tracker/database"
+	"vessel-tracker/models"
+
+	"gorm.io/gorm"
+)
+
+// ChangedBy identifies who made a change and from where, for audit records.
+// Changes made by the server itself, such as seeding, have no client IP.
+type ChangedBy struct {
+	Actor    string
+	Role     string
+	ClientIP string
+}
+
+// SystemChange marks changes the server makes on its own
+var SystemChange = ChangedBy{Actor: "system"}
+
+// ErrUnattributedChange is returned for whitelist changes without an
+// authenticated actor, which would leave the audit unable to say who made
+// them
+var ErrUnattributedChange = errors.New("whitelist changes need an authenticated actor")
+
+// WhitelistAuditFilter narrows a whitelist audit query; empty fields match
+// everything
+type WhitelistAuditFilter struct {
+	VesselUUID string
+	Actor      string
+	Action     string
+	Since      time.Time
+	Limit      int
+}
+
+// recordWhitelistChange writes the audit record of a change to an entry in
+// the transaction that made it, so no change is stored without its record.
+// before is nil for an addition and after for an erasure. Changes without an
+// actor are refused.
+func recordWhitelistChange(tx *gorm.DB, action string, entryID uint, before, after *models.WhitelistSnapshot, by ChangedBy) error {
+	if by.Actor == "" || by.Actor == "anonymous" {
+		return ErrUnattributedChange
+	}
+
+	audit := &models.WhitelistAudit{
+		EntryID:   entryID,
+		Action:    action,
+		Actor:     by.Actor,
+		Role:      by.Role,
+		ClientIP:  by.ClientIP,
+		Before:    before,
+		After:     after,
+		CreatedAt: time.Now(),
+	}
+	if after != nil {
+		audit.VesselUUID = after.VesselUUID
+	} else if before != nil {
+		audit.VesselUUID = before.VesselUUID
+	}
+
+	if err := tx.Create(audit).Error; err != nil {
+		return fmt.Errorf("failed to write whitelist audit: %w", err)
+	}
+	return nil
+}
+
+// GetWhitelistAudit returns the whitelist changes matching the filter, most
+// recent first
+func (ws *WhitelistService) GetWhitelistAudit(filter WhitelistAuditFilter) ([]models.WhitelistAudit, error) {
+	var audits []models.WhitelistAudit
+
+	query := database.DB.Order("created_at DESC, id DESC")
+	if filter.VesselUUID != "" {
+		query = query.Where("vessel_uuid = ?", filter.VesselUUID)
+	}
+	if filter.Actor != "" {
+		query = query.Where("actor = ?", filter.Actor)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if !filter.Since.IsZero() {
+		query = query.Where("created_at >= ?", filter.Since)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	err := query.Find(&audits).Error
+	return audits, err
+}
@@ -0,0 +1,248 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"vessel-tracker/database"
+	"vessel-tracker/models"
+
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+)
+
+// ErrNoWhitelistSeed is returned when the whitelist is seeded without
+// WHITELIST_SEED_FILE set
+var ErrNoWhitelistSeed = errors.New("no whitelist seed file is configured (WHITELIST_SEED_FILE)")
+
+// WhitelistSeedEntry is a permit listed in the seed file. The vessel is
+// identified by vessel_uuid, or else by mmsi or imo among the vessels already
+// stored.
+type WhitelistSeedEntry struct {
+	VesselUUID string     `json:"vessel_uuid" yaml:"vessel_uuid"`
+	MMSI       string     `json:"mmsi" yaml:"mmsi"`
+	IMO        string     `json:"imo" yaml:"imo"`
+	Name       string     `json:"name" yaml:"name"`
+	Reason     string     `json:"reason" yaml:"reason"`
+	OperatorID *uint      `json:"operator_id" yaml:"operator_id"`
+	ExpiresAt  *time.Time `json:"expires_at" yaml:"expires_at"`
+}
+
+// WhitelistSeed is the file format of the whitelist seed
+type WhitelistSeed struct {
+	Entries []WhitelistSeedEntry `json:"entries" yaml:"entries"`
+}
+
+// WhitelistSeedResult counts what seeding did with the entries of the file.
+// Pending entries name a vessel not stored yet; they are added by a later
+// seeding once the vessel has been seen.
+type WhitelistSeedResult struct {
+	Path      string   `json:"path"`
+	Created   int      `json:"created"`
+	Updated   int      `json:"updated"`
+	Unchanged int      `json:"unchanged"`
+	Pending   []string `json:"pending"`
+}
+
+// LoadWhitelistSeedFile reads WHITELIST_SEED_FILE and checks the file it
+// names, so a broken seed stops the server at startup. Unset, no entries are
+// seeded.
+func LoadWhitelistSeedFile() (string, error) {
+	path := os.Getenv("WHITELIST_SEED_FILE")
+	if path == "" {
+		return "", nil
+	}
+	if _, err := ReadWhitelistSeed(path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// ReadWhitelistSeed reads and validates a seed file, YAML when its extension
+// is .yaml or .yml and JSON otherwise
+func ReadWhitelistSeed(path string) (*WhitelistSeed, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WHITELIST_SEED_FILE %q: %w", path, err)
+	}
+
+	var seed WhitelistSeed
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &seed)
+	default:
+		err = json.Unmarshal(data, &seed)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid WHITELIST_SEED_FILE %q: %w", path, err)
+	}
+	if err := seed.validate(); err != nil {
+		return nil, fmt.Errorf("invalid WHITELIST_SEED_FILE %q: %w", path, err)
+	}
+	return &seed, nil
+}
+
+func (s *WhitelistSeed) validate() error {
+	seen := make(map[string]bool, len(s.Entries))
+	for i, entry := range s.Entries {
+		key := entry.key()
+		if key == "" {
+			return fmt.Errorf("entry %d needs a vessel_uuid, mmsi or imo", i+1)
+		}
+		if seen[key] {
+			return fmt.Errorf("entry %d: %s is listed twice", i+1, key)
+		}
+		seen[key] = true
+		if strings.TrimSpace(entry.Reason) == "" {
+			return fmt.Errorf("entry %d (%s) has no reason", i+1, key)
+		}
+	}
+	return nil
+}
+
+// key names the vessel of a seed entry for messages and duplicate checks
+func (e WhitelistSeedEntry) key() string {
+	switch {
+	case e.VesselUUID != "":
+		return "uuid " + e.VesselUUID
+	case e.MMSI != "":
+		return "mmsi " + e.MMSI
+	case e.IMO != "":
+		return "imo " + e.IMO
+	}
+	return ""
+}
+
+// SeedFromFile upserts the entries of a seed file: missing entries are
+// added, and the name, reason, operator and expiry of existing ones are
+// updated when the file changed them. Entries removed by an administrator
+// stay removed, and entries the file no longer lists are left alone. Every
+// change is recorded in the whitelist audit.
+func (ws *WhitelistService) SeedFromFile(path string, by ChangedBy) (*WhitelistSeedResult, error) {
+	if path == "" {
+		return nil, ErrNoWhitelistSeed
+	}
+	seed, err := ReadWhitelistSeed(path)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &WhitelistSeedResult{Path: path, Pending: []string{}}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		for _, entry := range seed.Entries {
+			vesselUUID, err := resolveSeedVessel(tx, entry)
+			if err != nil {
+				return err
+			}
+			if vesselUUID == "" {
+				result.Pending = append(result.Pending, entry.key())
+				continue
+			}
+
+			outcome, err := upsertSeedEntry(tx, vesselUUID, entry, by)
+			if err != nil {
+				return fmt.Errorf("%s: %w", entry.key(), err)
+			}
+			switch outcome {
+			case models.WhitelistAuditAdd:
+				result.Created++
+			case models.WhitelistAuditModify:
+				result.Updated++
+			default:
+				result.Unchanged++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result.Pending) > 0 {
+		ws.logger.Warn("Whitelist seed entries wait for their vessel to be seen", "pending", result.Pending)
+	}
+	ws.logger.Info("Whitelist seeded", "path", path, "created", result.Created, "updated", result.Updated, "unchanged", result.Unchanged)
+
+	if result.Created > 0 || result.Updated > 0 {
+		return result, ws.changed()
+	}
+	return result, nil
+}
+
+// resolveSeedVessel returns the UUID of the vessel a seed entry names, empty
+// when it is not stored yet
+func resolveSeedVessel(tx *gorm.DB, entry WhitelistSeedEntry) (string, error) {
+	query := tx.Model(&models.VesselRecord{}).Select("uuid")
+	switch {
+	case entry.VesselUUID != "":
+		query = query.Where("uuid = ?", entry.VesselUUID)
+	case entry.MMSI != "":
+		query = query.Where("mmsi = ?", entry.MMSI)
+	default:
+		query = query.Where("imo = ?", entry.IMO)
+	}
+
+	var uuids []string
+	if err := query.Limit(1).Pluck("uuid", &uuids).Error; err != nil {
+		return "", fmt.Errorf("failed to look up %s: %w", entry.key(), err)
+	}
+	if len(uuids) == 0 {
+		return "", nil
+	}
+	return uuids[0], nil
+}
+
+// upsertSeedEntry adds or updates the entry of a vessel and returns the audit
+// action taken, empty when the entry was already up to date
+func upsertSeedEntry(tx *gorm.DB, vesselUUID string, seed WhitelistSeedEntry, by ChangedBy) (string, error) {
+	var existing models.WhitelistEntry
+	err := tx.Where("vessel_uuid = ?", vesselUUID).First(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		entry := models.WhitelistEntry{
+			VesselUUID: vesselUUID,
+			MMSI:       seed.MMSI,
+			IMO:        seed.IMO,
+			Name:       seed.Name,
+			Reason:     seed.Reason,
+			AddedBy:    "seed",
+			OperatorID: seed.OperatorID,
+			ExpiresAt:  seed.ExpiresAt,
+			IsActive:   true,
+		}
+		if err := tx.Create(&entry).Error; err != nil {
+			return "", err
+		}
+		return models.WhitelistAuditAdd, recordWhitelistChange(tx, models.WhitelistAuditAdd, entry.ID, nil, models.NewWhitelistSnapshot(&entry), by)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	before := models.NewWhitelistSnapshot(&existing)
+	updated := existing
+	if seed.Name != "" {
+		updated.Name = seed.Name
+	}
+	updated.Reason = seed.Reason
+	updated.OperatorID = seed.OperatorID
+	updated.ExpiresAt = seed.ExpiresAt
+	after := models.NewWhitelistSnapshot(&updated)
+	if before.Equal(after) {
+		return "", nil
+	}
+
+	err = tx.Model(&existing).Updates(map[string]interface{}{
+		"name":        updated.Name,
+		"reason":      updated.Reason,
+		"operator_id": updated.OperatorID,
+		"expires_at":  updated.ExpiresAt,
+	}).Error
+	if err != nil {
+		return "", err
+	}
+	return models.WhitelistAuditModify, recordWhitelistChange(tx, models.WhitelistAuditModify, existing.ID, before, after, by)
+}
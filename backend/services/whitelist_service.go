@@ -1,58 +1,173 @@
 package services
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"sync"
 	"time"
 	"vessel-tracker/database"
+	"vessel-tracker/logging"
 	"vessel-tracker/models"
+
+	"gorm.io/gorm"
 )
 
+// whitelistChannel is the notification channel instances use to tell each
+// other that the whitelist changed
+const whitelistChannel = "whitelist_changed"
+
 type WhitelistService struct {
 	// In-memory cache for fast lookups
+	mu             sync.RWMutex
 	whitelistCache map[string]*models.WhitelistEntry
 	lastUpdate     time.Time
+
+	// instanceID tags this instance's change notifications so it can skip
+	// its own
+	instanceID string
+	logger     *slog.Logger
 }
 
 func NewWhitelistService() *WhitelistService {
 	ws := &WhitelistService{
 		whitelistCache: make(map[string]*models.WhitelistEntry),
+		instanceID:     newInstanceID(),
+		logger:         logging.Component("whitelist"),
 	}
 	ws.loadWhitelist()
 	return ws
 }
 
+func newInstanceID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return time.Now().Format(time.RFC3339Nano)
+	}
+	return hex.EncodeToString(b)
+}
+
 // Load whitelist from database into memory cache
 func (ws *WhitelistService) loadWhitelist() error {
 	var entries []models.WhitelistEntry
-	if err := database.DB.Where("is_active = ?", true).Find(&entries).Error; err != nil {
+	if err := activeWhitelistEntries(database.DB).Find(&entries).Error; err != nil {
 		return err
 	}
 
-	// Clear cache and reload
-	ws.whitelistCache = make(map[string]*models.WhitelistEntry)
+	// Build a new cache and swap it in, so lookups never see a partial one
+	cache := make(map[string]*models.WhitelistEntry)
 	for i := range entries {
 		entry := &entries[i]
 		// Index by UUID, MMSI, and IMO for fast lookups
 		if entry.VesselUUID != "" {
-			ws.whitelistCache[entry.VesselUUID] = entry
+			cache[entry.VesselUUID] = entry
 		}
 		if entry.MMSI != "" {
-			ws.whitelistCache["mmsi:"+entry.MMSI] = entry
+			cache["mmsi:"+entry.MMSI] = entry
 		}
 		if entry.IMO != "" {
-			ws.whitelistCache["imo:"+entry.IMO] = entry
+			cache["imo:"+entry.IMO] = entry
+		}
+		if entry.OperatorID != nil {
+			indexOperatorVessels(cache, *entry.OperatorID, entry)
 		}
 	}
 
+	ws.mu.Lock()
+	ws.whitelistCache = cache
 	ws.lastUpdate = time.Now()
+	ws.mu.Unlock()
 	return nil
 }
 
+// Index every vessel linked to an operator under the operator's permit, so a
+// season pass carries over to replacement or additional boats
+func indexOperatorVessels(cache map[string]*models.WhitelistEntry, operatorID uint, entry *models.WhitelistEntry) {
+	var vessels []models.VesselRecord
+	if err := database.DB.Where("operator_id = ?", operatorID).Find(&vessels).Error; err != nil {
+		return
+	}
+
+	for _, vessel := range vessels {
+		if vessel.UUID != "" {
+			if _, exists := cache[vessel.UUID]; !exists {
+				cache[vessel.UUID] = entry
+			}
+		}
+		if vessel.MMSI != "" {
+			if _, exists := cache["mmsi:"+vessel.MMSI]; !exists {
+				cache["mmsi:"+vessel.MMSI] = entry
+			}
+		}
+		if vessel.IMO != "" {
+			if _, exists := cache["imo:"+vessel.IMO]; !exists {
+				cache["imo:"+vessel.IMO] = entry
+			}
+		}
+	}
+}
+
+// changed reloads the cache after a local change and tells the other
+// instances to reload theirs
+func (ws *WhitelistService) changed() error {
+	if err := ws.loadWhitelist(); err != nil {
+		return err
+	}
+	if err := database.Notify(whitelistChannel, ws.instanceID); err != nil {
+		// The other instances still pick the change up on their periodic refresh
+		ws.logger.Warn("Failed to notify other instances of whitelist change", "error", err)
+	}
+	return nil
+}
+
+// StartSync keeps the cache in step with other instances sharing the
+// database: it reloads whenever another instance changes the whitelist, and
+// whenever the notification connection is (re)established to catch changes
+// made in between. It returns false when the database has no notification
+// channel, leaving the periodic refresh as the only sync.
+func (ws *WhitelistService) StartSync(ctx context.Context) bool {
+	reload := func(reason string) {
+		if err := ws.loadWhitelist(); err != nil {
+			ws.logger.Error("Failed to reload whitelist", "reason", reason, "error", err)
+			return
+		}
+		ws.logger.Debug("Reloaded whitelist", "reason", reason)
+	}
+
+	return database.Listen(ctx, whitelistChannel,
+		func() { reload("listener connected") },
+		func(payload string) {
+			if payload != ws.instanceID {
+				reload("changed by another instance")
+			}
+		})
+}
+
+// activeWhitelistEntries narrows a query to the entries in force: not
+// removed and not past their expiry
+func activeWhitelistEntries(db *gorm.DB) *gorm.DB {
+	return db.Where("is_active = ? AND (expires_at IS NULL OR expires_at > ?)", true, time.Now())
+}
+
+// lookup returns the cached entry under key. An entry that expired since the
+// cache was loaded no longer matches.
+func (ws *WhitelistService) lookup(key string) (*models.WhitelistEntry, bool) {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+	entry, exists := ws.whitelistCache[key]
+	if exists && entry.ExpiresAt != nil && !entry.ExpiresAt.After(time.Now()) {
+		return nil, false
+	}
+	return entry, exists
+}
+
 // Check if a vessel is whitelisted by UUID
 func (ws *WhitelistService) IsVesselWhitelistedByUUID(uuid string) bool {
 	if uuid == "" {
 		return false
 	}
-	_, exists := ws.whitelistCache[uuid]
+	_, exists := ws.lookup(uuid)
 	return exists
 }
 
@@ -61,7 +176,7 @@ func (ws *WhitelistService) IsVesselWhitelistedByMMSI(mmsi string) bool {
 	if mmsi == "" {
 		return false
 	}
-	_, exists := ws.whitelistCache["mmsi:"+mmsi]
+	_, exists := ws.lookup("mmsi:" + mmsi)
 	return exists
 }
 
@@ -70,7 +185,7 @@ func (ws *WhitelistService) IsVesselWhitelistedByIMO(imo string) bool {
 	if imo == "" {
 		return false
 	}
-	_, exists := ws.whitelistCache["imo:"+imo]
+	_, exists := ws.lookup("imo:" + imo)
 	return exists
 }
 
@@ -83,26 +198,34 @@ func (ws *WhitelistService) IsVesselWhitelisted(uuid, mmsi, imo string) bool {
 
 // Get whitelist entry for a vessel
 func (ws *WhitelistService) GetWhitelistEntry(uuid, mmsi, imo string) *models.WhitelistEntry {
+	entry, _ := ws.MatchWhitelistEntry(uuid, mmsi, imo)
+	return entry
+}
+
+// MatchWhitelistEntry returns the whitelist entry for a vessel and the
+// identifier that matched it ("uuid", "mmsi" or "imo"), checked in that order
+func (ws *WhitelistService) MatchWhitelistEntry(uuid, mmsi, imo string) (*models.WhitelistEntry, string) {
 	if uuid != "" {
-		if entry, exists := ws.whitelistCache[uuid]; exists {
-			return entry
+		if entry, exists := ws.lookup(uuid); exists {
+			return entry, "uuid"
 		}
 	}
 	if mmsi != "" {
-		if entry, exists := ws.whitelistCache["mmsi:"+mmsi]; exists {
-			return entry
+		if entry, exists := ws.lookup("mmsi:" + mmsi); exists {
+			return entry, "mmsi"
 		}
 	}
 	if imo != "" {
-		if entry, exists := ws.whitelistCache["imo:"+imo]; exists {
-			return entry
+		if entry, exists := ws.lookup("imo:" + imo); exists {
+			return entry, "imo"
 		}
 	}
-	return nil
+	return nil, ""
 }
 
-// Add vessel to whitelist
-func (ws *WhitelistService) AddToWhitelist(vesselUUID, mmsi, imo, name, reason, addedBy string) error {
+// Add vessel to whitelist. When operatorID is set the entry acts as a permit
+// for every vessel linked to that operator.
+func (ws *WhitelistService) AddToWhitelist(vesselUUID, mmsi, imo, name, reason, addedBy string, operatorID *uint, by ChangedBy) error {
 	entry := models.WhitelistEntry{
 		VesselUUID: vesselUUID,
 		MMSI:       mmsi,
@@ -110,89 +233,130 @@ func (ws *WhitelistService) AddToWhitelist(vesselUUID, mmsi, imo, name, reason,
 		Name:       name,
 		Reason:     reason,
 		AddedBy:    addedBy,
+		OperatorID: operatorID,
 		IsActive:   true,
 		CreatedAt:  time.Now(),
 		UpdatedAt:  time.Now(),
 	}
 
-	if err := database.DB.Create(&entry).Error; err != nil {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&entry).Error; err != nil {
+			return err
+		}
+		return recordWhitelistChange(tx, models.WhitelistAuditAdd, entry.ID, nil, models.NewWhitelistSnapshot(&entry), by)
+	})
+	if err != nil {
 		return err
 	}
 
 	// Refresh cache
-	return ws.loadWhitelist()
+	return ws.changed()
 }
 
 // Remove vessel from whitelist (mark as inactive)
-func (ws *WhitelistService) RemoveFromWhitelist(vesselUUID string) error {
-	if err := database.DB.Model(&models.WhitelistEntry{}).
-		Where("vessel_uuid = ?", vesselUUID).
-		Update("is_active", false).Error; err != nil {
-		return err
+func (ws *WhitelistService) RemoveFromWhitelist(vesselUUID string, by ChangedBy) error {
+	_, err := ws.removeEntries(func(tx *gorm.DB) *gorm.DB {
+		return tx.Where("vessel_uuid = ?", vesselUUID)
+	}, by)
+	return err
+}
+
+// RemoveWhitelistEntry removes a single entry by its ID. It returns
+// gorm.ErrRecordNotFound when no active entry has that ID.
+func (ws *WhitelistService) RemoveWhitelistEntry(id uint, by ChangedBy) error {
+	removed, err := ws.removeEntries(func(tx *gorm.DB) *gorm.DB {
+		return tx.Where("id = ?", id)
+	}, by)
+	if err == nil && removed == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return err
+}
+
+// RemoveOperatorFromWhitelist revokes the permits of an operator, which stop
+// covering every vessel linked to it. It returns gorm.ErrRecordNotFound when
+// the operator has no active permit.
+func (ws *WhitelistService) RemoveOperatorFromWhitelist(operatorID uint, by ChangedBy) error {
+	removed, err := ws.removeEntries(func(tx *gorm.DB) *gorm.DB {
+		return tx.Where("operator_id = ?", operatorID)
+	}, by)
+	if err == nil && removed == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return err
+}
+
+// removeEntries marks the active entries selected by match inactive,
+// recording each removal, and returns how many it removed
+func (ws *WhitelistService) removeEntries(match func(tx *gorm.DB) *gorm.DB, by ChangedBy) (int, error) {
+	var removed int
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var entries []models.WhitelistEntry
+		if err := match(tx).Where("is_active = ?", true).Find(&entries).Error; err != nil {
+			return err
+		}
+
+		for i := range entries {
+			entry := &entries[i]
+			before := models.NewWhitelistSnapshot(entry)
+			if err := tx.Model(entry).Update("is_active", false).Error; err != nil {
+				return err
+			}
+			entry.IsActive = false
+			if err := recordWhitelistChange(tx, models.WhitelistAuditRemove, entry.ID, before, models.NewWhitelistSnapshot(entry), by); err != nil {
+				return err
+			}
+		}
+		removed = len(entries)
+		return nil
+	})
+	if err != nil {
+		return 0, err
 	}
 
 	// Refresh cache
-	return ws.loadWhitelist()
+	return removed, ws.changed()
 }
 
 // Get all active whitelist entries
 func (ws *WhitelistService) GetAllWhitelistEntries() ([]models.WhitelistEntry, error) {
 	var entries []models.WhitelistEntry
-	err := database.DB.Where("is_active = ?", true).Preload("Vessel").Find(&entries).Error
+	err := activeWhitelistEntries(database.DB).Preload("Vessel").Find(&entries).Error
 	return entries, err
 }
 
-// Refresh cache if it's older than 5 minutes
+// Reload the cache immediately, e.g. after a vessel is linked to an operator,
+// and on every other instance
+func (ws *WhitelistService) Reload() error {
+	return ws.changed()
+}
+
+// CacheStatus reports the size and age of the in-memory whitelist
+func (ws *WhitelistService) CacheStatus() CacheStatus {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+
+	entries := make(map[*models.WhitelistEntry]bool, len(ws.whitelistCache))
+	for _, entry := range ws.whitelistCache {
+		entries[entry] = true
+	}
+	status := CacheStatus{Name: "whitelist", Loaded: !ws.lastUpdate.IsZero(), Entries: len(entries)}
+	if status.Loaded {
+		updatedAt := ws.lastUpdate
+		status.UpdatedAt = &updatedAt
+	}
+	return status
+}
+
+// Refresh cache if it's older than 5 minutes. With StartSync running this
+// is only a fallback for missed notifications.
 func (ws *WhitelistService) RefreshIfNeeded() error {
-	if time.Since(ws.lastUpdate) > 5*time.Minute {
+	ws.mu.RLock()
+	lastUpdate := ws.lastUpdate
+	ws.mu.RUnlock()
+
+	if time.Since(lastUpdate) > 5*time.Minute {
 		return ws.loadWhitelist()
 	}
 	return nil
 }
-
-// Initialize hardcoded whitelist entries
-func (ws *WhitelistService) InitializeHardcodedWhitelist() error {
-	// Define hardcoded whitelist entries
-	hardcodedEntries := []models.WhitelistEntry{
-		{
-			MMSI:       "123456789",
-			Name:       "Coast Guard Vessel Alpha",
-			Reason:     "Official coast guard patrol vessel",
-			AddedBy:    "system",
-			IsActive:   true,
-		},
-		{
-			MMSI:       "987654321",
-			Name:       "Research Vessel Beta",
-			Reason:     "Authorized marine research vessel",
-			AddedBy:    "system",
-			IsActive:   true,
-		},
-		{
-			IMO:        "IMO1234567",
-			Name:       "Marine Sanctuary Patrol",
-			Reason:     "Official sanctuary enforcement vessel",
-			AddedBy:    "system",
-			IsActive:   true,
-		},
-		// Add more hardcoded entries as needed
-	}
-
-	for _, entry := range hardcodedEntries {
-		// Check if entry already exists
-		var existing models.WhitelistEntry
-		err := database.DB.Where("mmsi = ? OR imo = ?", entry.MMSI, entry.IMO).First(&existing).Error
-
-		if err != nil {
-			// Entry doesn't exist, create it
-			entry.CreatedAt = time.Now()
-			entry.UpdatedAt = time.Now()
-			if err := database.DB.Create(&entry).Error; err != nil {
-				return err
-			}
-		}
-	}
-
-	// Refresh cache after adding hardcoded entries
-	return ws.loadWhitelist()
-}
\ No newline at end of file
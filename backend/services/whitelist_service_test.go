@@ -0,0 +1,127 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"vessel-tracker/database"
+	"vessel-tracker/models"
+
+	"gorm.io/gorm"
+)
+
+// ranger is the authenticated caller test changes are made by
+var ranger = ChangedBy{Actor: "ranger-1", Role: "ranger", ClientIP: "192.0.2.10"}
+
+// openTestWhitelist migrates a fresh in-memory SQLite database and loads an
+// empty whitelist from it
+func openTestWhitelist(t *testing.T) *WhitelistService {
+	openTestDatabase(t, testDatabases(t)["sqlite"])
+	return NewWhitelistService()
+}
+
+func TestWhitelistChangesNeedAnActor(t *testing.T) {
+	ws := openTestWhitelist(t)
+	for _, uuid := range []string{"unattributed", "attributed"} {
+		if err := database.DB.Create(&models.VesselRecord{UUID: uuid, Name: uuid}).Error; err != nil {
+			t.Fatalf("create vessel: %v", err)
+		}
+	}
+
+	for _, by := range []ChangedBy{{}, {Actor: "anonymous", Role: "public", ClientIP: "192.0.2.20"}} {
+		err := ws.AddToWhitelist("unattributed", "", "", "Unattributed", "test", "manual", nil, by)
+		if !errors.Is(err, ErrUnattributedChange) {
+			t.Fatalf("AddToWhitelist by %+v = %v, want ErrUnattributedChange", by, err)
+		}
+	}
+	if ws.IsVesselWhitelistedByUUID("unattributed") {
+		t.Fatal("an unattributed addition whitelisted the vessel")
+	}
+	var stored int64
+	database.DB.Model(&models.WhitelistEntry{}).Count(&stored)
+	if stored != 0 {
+		t.Fatalf("stored %d entries for unattributed additions, want 0", stored)
+	}
+
+	if err := ws.AddToWhitelist("attributed", "", "", "Attributed", "test", "manual", nil, ranger); err != nil {
+		t.Fatalf("AddToWhitelist: %v", err)
+	}
+	var audits []models.WhitelistAudit
+	database.DB.Find(&audits)
+	if len(audits) != 1 || audits[0].Actor != ranger.Actor || audits[0].VesselUUID != "attributed" {
+		t.Fatalf("audit = %+v, want one addition by %s", audits, ranger.Actor)
+	}
+}
+
+func TestRemoveOperatorFromWhitelistRevokesItsVessels(t *testing.T) {
+	ws := openTestWhitelist(t)
+
+	operator := models.Operator{Name: "Blue Charters"}
+	if err := database.DB.Create(&operator).Error; err != nil {
+		t.Fatalf("create operator: %v", err)
+	}
+	vessels := []models.VesselRecord{
+		{UUID: "charter-1", Name: "Charter 1", MMSI: "247000101", OperatorID: &operator.ID},
+		{UUID: "charter-2", Name: "Charter 2", MMSI: "247000102", OperatorID: &operator.ID},
+		{UUID: "independent", Name: "Independent", MMSI: "247000103"},
+	}
+	if err := database.DB.Create(&vessels).Error; err != nil {
+		t.Fatalf("create vessels: %v", err)
+	}
+
+	// The season pass is issued for one boat and covers the whole fleet
+	if err := ws.AddToWhitelist("charter-1", "", "", "Blue Charters", "season pass", "manual", &operator.ID, ranger); err != nil {
+		t.Fatalf("AddToWhitelist: %v", err)
+	}
+	if err := ws.AddToWhitelist("independent", "", "", "Independent", "research", "manual", nil, ranger); err != nil {
+		t.Fatalf("AddToWhitelist: %v", err)
+	}
+	for _, vessel := range vessels {
+		if !ws.IsVesselWhitelisted(vessel.UUID, vessel.MMSI, "") {
+			t.Fatalf("%s not whitelisted before the permit is revoked", vessel.UUID)
+		}
+	}
+
+	if err := ws.RemoveOperatorFromWhitelist(operator.ID, ranger); err != nil {
+		t.Fatalf("RemoveOperatorFromWhitelist: %v", err)
+	}
+	for _, vessel := range vessels[:2] {
+		if ws.IsVesselWhitelisted(vessel.UUID, vessel.MMSI, "") {
+			t.Fatalf("%s still whitelisted after its operator's permit was revoked", vessel.UUID)
+		}
+	}
+	if !ws.IsVesselWhitelistedByUUID("independent") {
+		t.Fatal("revoking the operator's permit removed an unrelated entry")
+	}
+
+	if err := ws.RemoveOperatorFromWhitelist(operator.ID, ranger); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("revoking a revoked permit = %v, want gorm.ErrRecordNotFound", err)
+	}
+}
+
+func TestRemoveWhitelistEntryByID(t *testing.T) {
+	ws := openTestWhitelist(t)
+	if err := database.DB.Create(&models.VesselRecord{UUID: "escort", Name: "Escort"}).Error; err != nil {
+		t.Fatalf("create vessel: %v", err)
+	}
+
+	if err := ws.AddToWhitelist("escort", "247000201", "", "Escort", "patrol escort", "manual", nil, ranger); err != nil {
+		t.Fatalf("AddToWhitelist: %v", err)
+	}
+	if !ws.IsVesselWhitelistedByMMSI("247000201") {
+		t.Fatal("entry by MMSI not whitelisted")
+	}
+	var entry models.WhitelistEntry
+	if err := database.DB.Where("vessel_uuid = ?", "escort").First(&entry).Error; err != nil {
+		t.Fatalf("find entry: %v", err)
+	}
+
+	if err := ws.RemoveWhitelistEntry(entry.ID, ranger); err != nil {
+		t.Fatalf("RemoveWhitelistEntry: %v", err)
+	}
+	if ws.IsVesselWhitelistedByMMSI("247000201") {
+		t.Fatal("entry still whitelisted after its removal")
+	}
+	if err := ws.RemoveWhitelistEntry(entry.ID, ranger); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("removing a removed entry = %v, want gorm.ErrRecordNotFound", err)
+	}
+}
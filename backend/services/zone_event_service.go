@@ -0,0 +1,164 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+	"vessel-tracker/database"
+	"vessel-tracker/logging"
+	"vessel-tracker/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ZoneEventService turns consecutive stored positions of a vessel into
+// entered/left events for the park and its buffer zone, so a vessel's
+// movements can be shown as a timeline instead of raw points
+type ZoneEventService struct {
+	db     *gorm.DB
+	logger *slog.Logger
+}
+
+func NewZoneEventService() *ZoneEventService {
+	return &ZoneEventService{
+		db:     database.GetDB(),
+		logger: logging.Component("zone_events"),
+	}
+}
+
+// positionZone returns the zone a classified position is in
+func positionZone(zones PositionZones) string {
+	switch {
+	case zones.InPark:
+		return models.ZonePark
+	case zones.InBufferZone:
+		return models.ZoneBuffer
+	default:
+		return models.ZoneOutside
+	}
+}
+
+// zoneEventType names the move from one zone to another. A vessel crossing
+// the buffer zone between two positions is recorded by the zone it ends up in.
+func zoneEventType(from, to string) string {
+	switch {
+	case to == models.ZonePark:
+		return models.ZoneEventEnteredPark
+	case from == models.ZonePark:
+		return models.ZoneEventLeftPark
+	case to == models.ZoneBuffer:
+		return models.ZoneEventEnteredBuffer
+	default:
+		return models.ZoneEventLeftBuffer
+	}
+}
+
+// RecordTransitions compares the positions just stored for a park with the
+// position of each vessel stored before them and records an event for every
+// vessel that changed zone. The previous positions are classified against the
+// current boundaries, so reloading a layer does not produce events on its
+// own. Vessels without an earlier position, skipped duplicates and positions
+// on land are left out, so a GPS error does not take a vessel out of the
+// park and back. It returns how many events were recorded.
+func (s *ZoneEventService) RecordTransitions(ctx context.Context, park *Park, positions []models.VesselPosition, zones []PositionZones, stored *StoreResult) (int, error) {
+	type lastPosition struct {
+		zone string
+		at   time.Time
+	}
+	last := make(map[string]lastPosition)
+
+	var events []models.ZoneEvent
+	for i, pos := range positions {
+		if stored.Duplicate[i] || zones[i].OnLand {
+			continue
+		}
+
+		previous, ok := last[pos.UUID]
+		if !ok {
+			record, found := stored.Previous[pos.UUID]
+			if !found || park.Geo.LandMask().OnLand(record.Latitude, record.Longitude) {
+				last[pos.UUID] = lastPosition{zone: positionZone(zones[i]), at: stored.RecordedAt}
+				continue
+			}
+			previous = lastPosition{
+				zone: positionZone(PositionZones{
+					InPark:       park.Geo.IsPointInPark(record.Latitude, record.Longitude),
+					InBufferZone: park.Geo.IsPointInBufferZone(record.Latitude, record.Longitude),
+				}),
+				at: record.RecordedAt,
+			}
+		}
+
+		zone := positionZone(zones[i])
+		last[pos.UUID] = lastPosition{zone: zone, at: stored.RecordedAt}
+		if zone == previous.zone {
+			continue
+		}
+
+		events = append(events, models.ZoneEvent{
+			ParkID:     park.Record.ID,
+			VesselUUID: pos.UUID,
+			Type:       zoneEventType(previous.zone, zone),
+			FromZone:   previous.zone,
+			ToZone:     zone,
+			Latitude:   pos.Latitude,
+			Longitude:  pos.Longitude,
+			Speed:      pos.Speed,
+			OccurredAt: stored.RecordedAt,
+			PreviousAt: previous.at,
+		})
+	}
+	if len(events) == 0 {
+		return 0, nil
+	}
+
+	if err := s.db.WithContext(ctx).Omit(clause.Associations).Create(&events).Error; err != nil {
+		return 0, fmt.Errorf("failed to record zone events: %w", err)
+	}
+
+	for _, event := range events {
+		s.logger.Debug("Vessel changed zone", "park", park.Record.Slug, "vessel_uuid", event.VesselUUID,
+			"type", event.Type, "from", event.FromZone, "to", event.ToZone)
+	}
+
+	return len(events), nil
+}
+
+// GetEvents lists the zone events in a park since the given time, oldest
+// first. An empty eventType returns every type.
+func (s *ZoneEventService) GetEvents(ctx context.Context, parkID uint, since time.Time, eventType string, limit int) ([]models.ZoneEvent, error) {
+	query := s.db.WithContext(ctx).Preload("Vessel").
+		Where("park_id = ? AND occurred_at >= ?", parkID, since)
+	if eventType != "" {
+		query = query.Where("type = ?", eventType)
+	}
+
+	var events []models.ZoneEvent
+	err := query.Order("occurred_at ASC, id ASC").
+		Limit(limit).
+		Find(&events).Error
+	return events, err
+}
+
+// GetVesselEvents lists the zone events of a vessel in a park between start
+// and end, oldest first
+func (s *ZoneEventService) GetVesselEvents(ctx context.Context, parkID uint, vesselUUID string, start, end time.Time, limit int) ([]models.ZoneEvent, error) {
+	var events []models.ZoneEvent
+	err := s.db.WithContext(ctx).Preload("Vessel").
+		Where("park_id = ? AND vessel_uuid = ? AND occurred_at >= ? AND occurred_at < ?", parkID, vesselUUID, start, end).
+		Order("occurred_at ASC, id ASC").
+		Limit(limit).
+		Find(&events).Error
+	return events, err
+}
+
+// IsZoneEventType reports whether t names a zone event type
+func IsZoneEventType(t string) bool {
+	switch t {
+	case models.ZoneEventEnteredPark, models.ZoneEventLeftPark, models.ZoneEventEnteredBuffer, models.ZoneEventLeftBuffer:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,316 @@
+package services
+
+import (
+	"math"
+	"sort"
+
+	geojson "github.com/paulmach/go.geojson"
+)
+
+// DefaultZoneGridCellDegrees is the side of a zone grid cell, roughly 110 m
+// north-south at the park's latitude
+const DefaultZoneGridCellDegrees = 0.001
+
+// maxZoneGridCells caps the size of a layer's grid; a layer spanning a larger
+// area gets coarser cells instead
+const maxZoneGridCells = 1 << 22
+
+// zoneMarginSlack widens the near-boundary tolerance when marking boundary
+// cells, covering the difference between the planar margin used here and
+// the geodesic distance used by the exact test
+const zoneMarginSlack = 1.05
+
+// bitmap is a fixed-size set of grid cell indexes
+type bitmap []uint64
+
+func newBitmap(size int) bitmap {
+	return make(bitmap, (size+63)/64)
+}
+
+func (b bitmap) set(i int) {
+	b[i/64] |= 1 << (uint(i) % 64)
+}
+
+func (b bitmap) has(i int) bool {
+	return b[i/64]&(1<<(uint(i)%64)) != 0
+}
+
+func (b bitmap) count() int {
+	n := 0
+	for _, word := range b {
+		for ; word != 0; word &= word - 1 {
+			n++
+		}
+	}
+	return n
+}
+
+// zoneGrid records, for each cell of a lat/lon grid over a boundary layer,
+// whether the whole cell lies inside the zone or whether a boundary (or the
+// near-boundary tolerance around it) passes through it. Points in interior
+// and exterior cells are classified with a single lookup; only boundary
+// cells need the exact polygon test.
+type zoneGrid struct {
+	minLat, minLon float64
+	cell           float64
+	rows, cols     int
+	inside         bitmap
+	boundary       bitmap
+}
+
+// zoneRings are the rings of one zone feature together with the distance
+// around them that still counts as inside the zone
+type zoneRings struct {
+	outer        [][][]float64
+	all          [][][]float64
+	marginMeters float64
+}
+
+// featureZoneRings splits a feature into the outer rings used for
+// containment and every ring used for the near-boundary tolerance, matching
+// isPointInFeature and isPointNearPark
+func featureZoneRings(feature *geojson.Feature, marginMeters float64) zoneRings {
+	zone := zoneRings{
+		all:          geometryRings(feature.Geometry),
+		marginMeters: math.Max(marginMeters, 0),
+	}
+
+	g := feature.Geometry
+	if g == nil {
+		return zone
+	}
+	switch g.Type {
+	case geojson.GeometryPolygon:
+		if len(g.Polygon) > 0 {
+			zone.outer = append(zone.outer, g.Polygon[0])
+		}
+	case geojson.GeometryMultiPolygon:
+		for _, polygon := range g.MultiPolygon {
+			if len(polygon) > 0 {
+				zone.outer = append(zone.outer, polygon[0])
+			}
+		}
+	}
+
+	return zone
+}
+
+// marginDegrees converts a distance in meters into latitude and longitude
+// spans that cover it everywhere below maxAbsLat
+func marginDegrees(meters, maxAbsLat float64) (float64, float64) {
+	if meters <= 0 {
+		return 0, 0
+	}
+	metersPerDegree := EarthRadiusMeters * math.Pi / 180
+	lat := meters * zoneMarginSlack / metersPerDegree
+	lon := lat / math.Max(math.Cos(toRadians(maxAbsLat)), 0.01)
+	return lat, lon
+}
+
+// buildZoneGrid precomputes the grid for a layer, or returns nil when the
+// layer has no polygons or cellDegrees is not positive
+func buildZoneGrid(zones []zoneRings, cellDegrees float64) *zoneGrid {
+	if cellDegrees <= 0 {
+		return nil
+	}
+
+	minLat, minLon := math.MaxFloat64, math.MaxFloat64
+	maxLat, maxLon := -math.MaxFloat64, -math.MaxFloat64
+	maxMargin := 0.0
+	for _, zone := range zones {
+		for _, ring := range zone.all {
+			for _, coord := range ring {
+				minLon = math.Min(minLon, coord[0])
+				maxLon = math.Max(maxLon, coord[0])
+				minLat = math.Min(minLat, coord[1])
+				maxLat = math.Max(maxLat, coord[1])
+			}
+		}
+		maxMargin = math.Max(maxMargin, zone.marginMeters)
+	}
+	if minLat > maxLat {
+		return nil
+	}
+
+	maxAbsLat := math.Min(math.Max(math.Abs(minLat), math.Abs(maxLat))+1, 89)
+	latMargin, lonMargin := marginDegrees(maxMargin, maxAbsLat)
+
+	// Everything beyond the margin around the layer's bounding box is outside
+	minLat -= latMargin + cellDegrees
+	maxLat += latMargin + cellDegrees
+	minLon -= lonMargin + cellDegrees
+	maxLon += lonMargin + cellDegrees
+
+	cell := cellDegrees
+	if cells := (maxLat - minLat) * (maxLon - minLon) / (cell * cell); cells > maxZoneGridCells {
+		cell = math.Sqrt((maxLat - minLat) * (maxLon - minLon) / maxZoneGridCells)
+	}
+
+	g := &zoneGrid{
+		minLat: minLat,
+		minLon: minLon,
+		cell:   cell,
+		rows:   int(math.Ceil((maxLat - minLat) / cell)),
+		cols:   int(math.Ceil((maxLon - minLon) / cell)),
+	}
+	g.inside = newBitmap(g.rows * g.cols)
+	g.boundary = newBitmap(g.rows * g.cols)
+
+	for _, zone := range zones {
+		zoneLat, zoneLon := marginDegrees(zone.marginMeters, maxAbsLat)
+		for _, ring := range zone.all {
+			for i := 0; i+1 < len(ring); i++ {
+				g.markSegment(ring[i], ring[i+1], zoneLat, zoneLon)
+			}
+		}
+		for _, ring := range zone.outer {
+			g.fillRing(ring)
+		}
+	}
+
+	return g
+}
+
+// markSegment flags every cell the segment, widened by the given margins,
+// passes through. The segment is walked in steps no longer than a cell so
+// long diagonal edges do not flag their whole bounding box.
+func (g *zoneGrid) markSegment(a, b []float64, latMargin, lonMargin float64) {
+	steps := int(math.Ceil(math.Max(math.Abs(b[0]-a[0]), math.Abs(b[1]-a[1])) / g.cell))
+	if steps < 1 {
+		steps = 1
+	}
+
+	// A little slack keeps edges lying exactly on a cell border in both cells
+	epsilon := g.cell * 1e-6
+
+	for step := 0; step < steps; step++ {
+		t0 := float64(step) / float64(steps)
+		t1 := float64(step+1) / float64(steps)
+		lon0, lat0 := a[0]+(b[0]-a[0])*t0, a[1]+(b[1]-a[1])*t0
+		lon1, lat1 := a[0]+(b[0]-a[0])*t1, a[1]+(b[1]-a[1])*t1
+
+		rowMin := g.row(math.Min(lat0, lat1) - latMargin - epsilon)
+		rowMax := g.row(math.Max(lat0, lat1) + latMargin + epsilon)
+		colMin := g.col(math.Min(lon0, lon1) - lonMargin - epsilon)
+		colMax := g.col(math.Max(lon0, lon1) + lonMargin + epsilon)
+
+		for row := rowMin; row <= rowMax; row++ {
+			for col := colMin; col <= colMax; col++ {
+				g.boundary.set(row*g.cols + col)
+			}
+		}
+	}
+}
+
+// fillRing flags the cells whose center lies inside the ring, scanning each
+// row with the same crossing rule as isPointInPolygon. Cells without a
+// boundary through them are entirely on the side of their center.
+func (g *zoneGrid) fillRing(ring [][]float64) {
+	if len(ring) < 3 {
+		return
+	}
+
+	crossings := make([]float64, 0, 8)
+	for row := 0; row < g.rows; row++ {
+		y := g.minLat + (float64(row)+0.5)*g.cell
+
+		crossings = crossings[:0]
+		j := len(ring) - 1
+		for i := 0; i < len(ring); i++ {
+			xi, yi := ring[i][0], ring[i][1]
+			xj, yj := ring[j][0], ring[j][1]
+			if (yi > y) != (yj > y) {
+				crossings = append(crossings, (xj-xi)*(y-yi)/(yj-yi)+xi)
+			}
+			j = i
+		}
+		sort.Float64s(crossings)
+
+		for k := 0; k+1 < len(crossings); k += 2 {
+			// Cells whose center x lies between the two crossings
+			first := int(math.Ceil((crossings[k]-g.minLon)/g.cell - 0.5))
+			last := int(math.Ceil((crossings[k+1]-g.minLon)/g.cell-0.5)) - 1
+			if first < 0 {
+				first = 0
+			}
+			if last >= g.cols {
+				last = g.cols - 1
+			}
+			for col := first; col <= last; col++ {
+				g.inside.set(row*g.cols + col)
+			}
+		}
+	}
+}
+
+func (g *zoneGrid) row(lat float64) int {
+	return clampIndex(int(math.Floor((lat-g.minLat)/g.cell)), g.rows)
+}
+
+func (g *zoneGrid) col(lon float64) int {
+	return clampIndex(int(math.Floor((lon-g.minLon)/g.cell)), g.cols)
+}
+
+func clampIndex(i, n int) int {
+	if i < 0 {
+		return 0
+	}
+	if i >= n {
+		return n - 1
+	}
+	return i
+}
+
+// lookup classifies a point from the grid. ok is false when the point falls
+// in a boundary cell and needs the exact test, or when there is no grid.
+func (g *zoneGrid) lookup(lat, lon float64) (inside bool, ok bool) {
+	if g == nil {
+		return false, false
+	}
+
+	row := int(math.Floor((lat - g.minLat) / g.cell))
+	col := int(math.Floor((lon - g.minLon) / g.cell))
+	if row < 0 || row >= g.rows || col < 0 || col >= g.cols {
+		return false, true
+	}
+
+	i := row*g.cols + col
+	if g.boundary.has(i) {
+		return false, false
+	}
+	return g.inside.has(i), true
+}
+
+// ZoneGridStats describes the precomputed grid of a boundary layer
+type ZoneGridStats struct {
+	Layer         string  `json:"layer"`
+	CellDegrees   float64 `json:"cell_degrees"`
+	Rows          int     `json:"rows"`
+	Cols          int     `json:"cols"`
+	BoundaryCells int     `json:"boundary_cells"`
+	InsideCells   int     `json:"inside_cells"`
+	BoundaryPct   float64 `json:"boundary_pct"`
+}
+
+func (g *zoneGrid) stats(layer string) ZoneGridStats {
+	total := g.rows * g.cols
+	boundary := g.boundary.count()
+
+	// Interior cells are those inside that are not boundary cells
+	inside := 0
+	for i := 0; i < total; i++ {
+		if g.inside.has(i) && !g.boundary.has(i) {
+			inside++
+		}
+	}
+
+	return ZoneGridStats{
+		Layer:         layer,
+		CellDegrees:   g.cell,
+		Rows:          g.rows,
+		Cols:          g.cols,
+		BoundaryCells: boundary,
+		InsideCells:   inside,
+		BoundaryPct:   float64(boundary) / float64(total) * 100,
+	}
+}
@@ -0,0 +1,385 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"vessel-tracker/models"
+)
+
+// Zone rule actions
+const (
+	RuleActionAllow = "allow"
+	RuleActionDeny  = "deny"
+	RuleActionSpeed = "speed"
+)
+
+// VesselMatch selects the vessels a zone rule applies to; empty criteria
+// match every vessel. Types are compared with the type and the specific type
+// of the vessel, ignoring case. A vessel whose length or gross tonnage is not
+// known never matches a rule bounding it.
+type VesselMatch struct {
+	Types           []string `json:"types,omitempty"`
+	MinLengthM      *float64 `json:"min_length_m,omitempty"`
+	MaxLengthM      *float64 `json:"max_length_m,omitempty"`
+	MinGrossTonnage *float64 `json:"min_gross_tonnage,omitempty"`
+	MaxGrossTonnage *float64 `json:"max_gross_tonnage,omitempty"`
+}
+
+// ZoneRule allows or denies matching vessels in a zone, or sets the speed
+// limit they must keep there
+type ZoneRule struct {
+	Name            string      `json:"name"`
+	Description     string      `json:"description,omitempty"`
+	Park            string      `json:"park,omitempty"` // park slug, every park when empty
+	Zone            string      `json:"zone"`           // park or buffer
+	Action          string      `json:"action"`         // allow, deny or speed
+	Vessels         VesselMatch `json:"vessels"`
+	SpeedLimitKnots float64     `json:"speed_limit_knots,omitempty"` // speed rules only
+	Severity        string      `json:"severity,omitempty"`          // of the violations, that of the violation type by default
+}
+
+// WhitelistException reports violations of the listed types by whitelisted
+// matching vessels, which are otherwise never alerted, with the severity
+// models.SeverityAuthorizedInfraction. Authorization to be in a zone does not
+// cover damaging it, such as anchoring on a posidonia meadow.
+type WhitelistException struct {
+	Name           string      `json:"name"`
+	Description    string      `json:"description,omitempty"`
+	Park           string      `json:"park,omitempty"` // park slug, every park when empty
+	ViolationTypes []string    `json:"violation_types"`
+	Vessels        VesselMatch `json:"vessels"`
+}
+
+// exceptableViolationTypes are the violation types a whitelist exception can
+// report. Watchlist alerts are raised for whitelisted vessels already.
+var exceptableViolationTypes = []string{
+	models.ViolationInBufferZone,
+	models.ViolationInRestrictedArea,
+	models.ViolationExcessiveSpeed,
+	models.ViolationAnchoredOnPosidonia,
+	models.ViolationProjectedIntrusion,
+	models.ViolationAnchorDragging,
+}
+
+// ZoneDefault is how a zone treats vessels no rule matches
+type ZoneDefault struct {
+	Zone            string  `json:"zone"`
+	Access          string  `json:"access"` // allow or deny
+	SpeedLimitKnots float64 `json:"speed_limit_knots,omitempty"`
+}
+
+// ZoneDefaults returns the behavior of each zone without rules: the park
+// admits every vessel at up to its speed limit, and any vessel in the buffer
+// zone is a violation
+func ZoneDefaults() []ZoneDefault {
+	return []ZoneDefault{
+		{Zone: LayerPark, Access: RuleActionAllow, SpeedLimitKnots: parkSpeedLimit},
+		{Zone: LayerBuffer, Access: RuleActionDeny},
+	}
+}
+
+// RulesConfig lists the zone rules of every park, in the order they are
+// evaluated, and the whitelist exceptions
+type RulesConfig struct {
+	Rules               []ZoneRule           `json:"rules"`
+	WhitelistExceptions []WhitelistException `json:"whitelist_exceptions"`
+}
+
+// LoadRulesConfig reads the zone rules and whitelist exceptions from the
+// JSON object in RULES_FILE. Without RULES_FILE no rules apply, every zone
+// keeps its default and whitelisted vessels are never alerted.
+func LoadRulesConfig() (RulesConfig, error) {
+	path := os.Getenv("RULES_FILE")
+	if path == "" {
+		return RulesConfig{}, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return RulesConfig{}, fmt.Errorf("invalid RULES_FILE %q: %w", path, err)
+	}
+
+	var config RulesConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return RulesConfig{}, fmt.Errorf("invalid RULES_FILE %q: %w", path, err)
+	}
+	if err := config.validate(); err != nil {
+		return RulesConfig{}, fmt.Errorf("invalid RULES_FILE %q: %w", path, err)
+	}
+
+	return config, nil
+}
+
+func (c RulesConfig) validate() error {
+	names := make(map[string]bool, len(c.Rules))
+	for i, rule := range c.Rules {
+		if rule.Name == "" {
+			return fmt.Errorf("rule %d has no name", i+1)
+		}
+		if names[rule.Name] {
+			return fmt.Errorf("duplicate rule name %q", rule.Name)
+		}
+		names[rule.Name] = true
+
+		if rule.Zone != LayerPark && rule.Zone != LayerBuffer {
+			return fmt.Errorf("rule %q: zone must be park or buffer", rule.Name)
+		}
+		switch rule.Action {
+		case RuleActionAllow, RuleActionDeny:
+			if rule.SpeedLimitKnots != 0 {
+				return fmt.Errorf("rule %q: speed_limit_knots only applies to speed rules", rule.Name)
+			}
+		case RuleActionSpeed:
+			if rule.SpeedLimitKnots <= 0 {
+				return fmt.Errorf("rule %q: speed rules need a positive speed_limit_knots", rule.Name)
+			}
+		default:
+			return fmt.Errorf("rule %q: action must be allow, deny or speed", rule.Name)
+		}
+		switch rule.Severity {
+		case "", models.SeverityLow, models.SeverityMedium, models.SeverityHigh, models.SeverityCritical:
+		default:
+			return fmt.Errorf("rule %q: severity must be low, medium, high or critical", rule.Name)
+		}
+
+		match := rule.Vessels
+		if match.MinLengthM != nil && match.MaxLengthM != nil && *match.MinLengthM > *match.MaxLengthM {
+			return fmt.Errorf("rule %q: min_length_m is above max_length_m", rule.Name)
+		}
+		if match.MinGrossTonnage != nil && match.MaxGrossTonnage != nil && *match.MinGrossTonnage > *match.MaxGrossTonnage {
+			return fmt.Errorf("rule %q: min_gross_tonnage is above max_gross_tonnage", rule.Name)
+		}
+	}
+
+	exceptionNames := make(map[string]bool, len(c.WhitelistExceptions))
+	for i, exception := range c.WhitelistExceptions {
+		if exception.Name == "" {
+			return fmt.Errorf("whitelist exception %d has no name", i+1)
+		}
+		if exceptionNames[exception.Name] {
+			return fmt.Errorf("duplicate whitelist exception name %q", exception.Name)
+		}
+		exceptionNames[exception.Name] = true
+
+		if len(exception.ViolationTypes) == 0 {
+			return fmt.Errorf("whitelist exception %q: violation_types is empty", exception.Name)
+		}
+		for _, violationType := range exception.ViolationTypes {
+			if !containsString(exceptableViolationTypes, violationType) {
+				return fmt.Errorf("whitelist exception %q: violation type must be one of %s", exception.Name, strings.Join(exceptableViolationTypes, ", "))
+			}
+		}
+
+		match := exception.Vessels
+		if match.MinLengthM != nil && match.MaxLengthM != nil && *match.MinLengthM > *match.MaxLengthM {
+			return fmt.Errorf("whitelist exception %q: min_length_m is above max_length_m", exception.Name)
+		}
+		if match.MinGrossTonnage != nil && match.MaxGrossTonnage != nil && *match.MinGrossTonnage > *match.MaxGrossTonnage {
+			return fmt.Errorf("whitelist exception %q: min_gross_tonnage is above max_gross_tonnage", exception.Name)
+		}
+	}
+	return nil
+}
+
+// RuleVessel is what zone rules know about a vessel. The position reports the
+// type; length and tonnage come from the stored vessel details.
+type RuleVessel struct {
+	Type         string
+	TypeSpecific string
+	LengthM      float64 // 0 when unknown
+	GrossTonnage *float64
+}
+
+// newRuleVessel describes the vessel of a position, completed by its stored
+// record when there is one
+func newRuleVessel(pos models.VesselPosition, record *models.VesselRecord) RuleVessel {
+	vessel := RuleVessel{Type: pos.Type, TypeSpecific: pos.TypeSpecific}
+	if record == nil {
+		return vessel
+	}
+	if vessel.Type == "" {
+		vessel.Type = record.Type
+	}
+	if vessel.TypeSpecific == "" {
+		vessel.TypeSpecific = record.TypeSpecific
+	}
+	vessel.LengthM = record.Length
+	vessel.GrossTonnage = record.GrossTonnage
+	return vessel
+}
+
+// describe names the vessel's type for violation details
+func (v RuleVessel) describe() string {
+	switch {
+	case v.TypeSpecific != "":
+		return v.TypeSpecific
+	case v.Type != "":
+		return v.Type
+	}
+	return "vessel of unknown type"
+}
+
+func (m VesselMatch) matches(v RuleVessel) bool {
+	if len(m.Types) > 0 {
+		found := false
+		for _, t := range m.Types {
+			if strings.EqualFold(t, v.Type) || strings.EqualFold(t, v.TypeSpecific) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if m.MinLengthM != nil || m.MaxLengthM != nil {
+		if v.LengthM <= 0 {
+			return false
+		}
+		if m.MinLengthM != nil && v.LengthM < *m.MinLengthM {
+			return false
+		}
+		if m.MaxLengthM != nil && v.LengthM > *m.MaxLengthM {
+			return false
+		}
+	}
+
+	if m.MinGrossTonnage != nil || m.MaxGrossTonnage != nil {
+		if v.GrossTonnage == nil {
+			return false
+		}
+		if m.MinGrossTonnage != nil && *v.GrossTonnage < *m.MinGrossTonnage {
+			return false
+		}
+		if m.MaxGrossTonnage != nil && *v.GrossTonnage > *m.MaxGrossTonnage {
+			return false
+		}
+	}
+
+	return true
+}
+
+// accessRule returns the first allow or deny rule of the zone matching the
+// vessel, or nil when the zone default applies
+func accessRule(rules []ZoneRule, zone string, vessel RuleVessel) *ZoneRule {
+	for i := range rules {
+		rule := &rules[i]
+		if rule.Zone == zone && rule.Action != RuleActionSpeed && rule.Vessels.matches(vessel) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// speedRule returns the first speed rule of the zone matching the vessel, or
+// nil when the zone default applies
+func speedRule(rules []ZoneRule, zone string, vessel RuleVessel) *ZoneRule {
+	for i := range rules {
+		rule := &rules[i]
+		if rule.Zone == zone && rule.Action == RuleActionSpeed && rule.Vessels.matches(vessel) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// zoneSpeedLimit returns the speed limit a vessel must keep at a position
+// and the rule setting it, nil for the park default. The limit of the park
+// applies where the park and the buffer zone overlap; 0 means no limit.
+func zoneSpeedLimit(rules []ZoneRule, zones PositionZones, vessel RuleVessel, parkLimit float64) (float64, *ZoneRule) {
+	var zone string
+	switch {
+	case zones.InPark:
+		zone = LayerPark
+	case zones.InBufferZone:
+		zone = LayerBuffer
+	default:
+		return 0, nil
+	}
+
+	if rule := speedRule(rules, zone, vessel); rule != nil {
+		return rule.SpeedLimitKnots, rule
+	}
+	if zone == LayerPark {
+		return parkLimit, nil
+	}
+	return 0, nil
+}
+
+// whitelistException returns the first exception reporting violations of the
+// type by the whitelisted vessel, or nil when the whitelist applies
+func whitelistException(exceptions []WhitelistException, violationType string, vessel RuleVessel) *WhitelistException {
+	for i := range exceptions {
+		exception := &exceptions[i]
+		if containsString(exception.ViolationTypes, violationType) && exception.Vessels.matches(vessel) {
+			return exception
+		}
+	}
+	return nil
+}
+
+// exceptWhitelisted applies the exceptions to a rule evaluated for a
+// whitelisted vessel. A violation an exception reports becomes an authorized
+// infraction; it returns false when the whitelist waives the rule.
+func exceptWhitelisted(evaluation *models.RuleEvaluation, exceptions []WhitelistException, vessel RuleVessel) bool {
+	exception := whitelistException(exceptions, evaluation.Rule, vessel)
+	if exception == nil {
+		return false
+	}
+	evaluation.Exception = exception.Name
+	if evaluation.Matched {
+		evaluation.Severity = models.SeverityAuthorizedInfraction
+		evaluation.Reason = fmt.Sprintf("%s; reported for a whitelisted vessel by exception %q", evaluation.Reason, exception.Name)
+	}
+	return true
+}
+
+// ruleSeverity returns the severity of violations of a rule
+func ruleSeverity(rule *ZoneRule, fallback string) string {
+	if rule != nil && rule.Severity != "" {
+		return rule.Severity
+	}
+	return fallback
+}
+
+// SetZoneRules gives every park the rules and whitelist exceptions that
+// apply to it, in file order. Those naming a park that is not monitored are
+// rejected.
+func (r *ParkRegistry) SetZoneRules(config RulesConfig) error {
+	for _, rule := range config.Rules {
+		if rule.Park != "" {
+			if _, ok := r.bySlug[rule.Park]; !ok {
+				return fmt.Errorf("rule %q: unknown park %q", rule.Name, rule.Park)
+			}
+		}
+	}
+	for _, exception := range config.WhitelistExceptions {
+		if exception.Park != "" {
+			if _, ok := r.bySlug[exception.Park]; !ok {
+				return fmt.Errorf("whitelist exception %q: unknown park %q", exception.Name, exception.Park)
+			}
+		}
+	}
+
+	for _, park := range r.parks {
+		rules := []ZoneRule{}
+		for _, rule := range config.Rules {
+			if rule.Park == "" || rule.Park == park.Record.Slug {
+				rules = append(rules, rule)
+			}
+		}
+		park.Rules = rules
+
+		exceptions := []WhitelistException{}
+		for _, exception := range config.WhitelistExceptions {
+			if exception.Park == "" || exception.Park == park.Record.Slug {
+				exceptions = append(exceptions, exception)
+			}
+		}
+		park.WhitelistExceptions = exceptions
+	}
+	return nil
+}
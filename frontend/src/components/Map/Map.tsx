@@ -154,9 +154,6 @@ export default function MapComponent({
 }: MapProps) {
   const mapRef = useRef<MapRef>(null);
   const mapLoadedRef = useRef(false);
-  const [posidoniaData, setPosidoniaData] =
-    useState<GeoJSON.FeatureCollection | null>(null);
-
   // Mobile detection and vessel panel state
   const [isMobile, setIsMobile] = useState(false);
   const [selectedVessel, setSelectedVessel] = useState<VesselProperties | null>(
@@ -257,25 +254,6 @@ export default function MapComponent({
     updateLayerVisibility(layerVisibility);
   }, [layerVisibility, updateLayerVisibility]);
 
-  // Load posidonia data
-  useEffect(() => {
-    fetch(getApiUrl(API_ENDPOINTS.posidonia))
-      .then((res) => {
-        if (!res.ok) {
-          throw new Error(`HTTP error! status: ${res.status}`);
-        }
-        return res.json();
-      })
-      .then((data) => {
-        if (data && data.type === "FeatureCollection" && data.features) {
-          setPosidoniaData(data);
-        } else {
-          // Invalid posidonia GeoJSON data received
-        }
-      })
-      .catch((err) => console.error("Failed to load posidonia data:", err));
-  }, []);
-
   // Spatial analysis is now handled by the violations worker for consistency
 
   // Memoize vessel GeoJSON data to prevent expensive recalculations
@@ -942,168 +920,150 @@ export default function MapComponent({
             </Source>
           )}
 
-          {/* Posidonia Beds Layers - Middle layers */}
-          {posidoniaData && (
-            <>
-              {/* Healthy Posidonia */}
-              <Source
-                id="posidonia-healthy-source"
-                type="geojson"
-                data={{
-                  type: "FeatureCollection",
-                  features: posidoniaData.features.filter(
-                    (f) => f.properties?.classification === "healthy"
-                  ),
-                }}
-              >
-                <Layer
-                  id="posidonia-healthy-fill"
-                  type="fill"
-                  layout={{
-                    visibility: layerVisibility["posidonia-healthy"]
-                      ? "visible"
-                      : "none",
-                  }}
-                  paint={{
-                    "fill-color": "#10b981",
-                    "fill-opacity": 0.3,
-                  }}
-                />
-                <Layer
-                  id="posidonia-healthy-outline"
-                  type="line"
-                  layout={{
-                    visibility: layerVisibility["posidonia-healthy"]
-                      ? "visible"
-                      : "none",
-                  }}
-                  paint={{
-                    "line-color": "#059669",
-                    "line-width": 2,
-                  }}
-                />
-              </Source>
-
-              {/* Degraded Posidonia */}
-              <Source
-                id="posidonia-degraded-source"
-                type="geojson"
-                data={{
-                  type: "FeatureCollection",
-                  features: posidoniaData.features.filter(
-                    (f) => f.properties?.classification === "degraded"
-                  ),
-                }}
-              >
-                <Layer
-                  id="posidonia-degraded-fill"
-                  type="fill"
-                  layout={{
-                    visibility: layerVisibility["posidonia-degraded"]
-                      ? "visible"
-                      : "none",
-                  }}
-                  paint={{
-                    "fill-color": "#f59e0b",
-                    "fill-opacity": 0.3,
-                  }}
-                />
-                <Layer
-                  id="posidonia-degraded-outline"
-                  type="line"
-                  layout={{
-                    visibility: layerVisibility["posidonia-degraded"]
-                      ? "visible"
-                      : "none",
-                  }}
-                  paint={{
-                    "line-color": "#DC143C",
-                    "line-width": 2,
-                  }}
-                />
-              </Source>
-
-              {/* Dead Posidonia */}
-              <Source
-                id="posidonia-dead-source"
-                type="geojson"
-                data={{
-                  type: "FeatureCollection",
-                  features: posidoniaData.features.filter(
-                    (f) => f.properties?.classification === "dead"
-                  ),
-                }}
-              >
-                <Layer
-                  id="posidonia-dead-fill"
-                  type="fill"
-                  layout={{
-                    visibility: layerVisibility["posidonia-dead"]
-                      ? "visible"
-                      : "none",
-                  }}
-                  paint={{
-                    "fill-color": "#059669",
-                    "fill-opacity": 0.2,
-                  }}
-                />
-                <Layer
-                  id="posidonia-dead-outline"
-                  type="line"
-                  layout={{
-                    visibility: layerVisibility["posidonia-dead"]
-                      ? "visible"
-                      : "none",
-                  }}
-                  paint={{
-                    "line-color": "#654321",
-                    "line-width": 2,
-                  }}
-                />
-              </Source>
-
-              {/* Standard/Unknown Posidonia */}
-              <Source
-                id="posidonia-standard-source"
-                type="geojson"
-                data={{
-                  type: "FeatureCollection",
-                  features: posidoniaData.features.filter(
-                    (f) =>
-                      !f.properties?.classification ||
-                      f.properties?.classification === "standard"
-                  ),
-                }}
-              >
-                <Layer
-                  id="posidonia-standard-fill"
-                  type="fill"
-                  layout={{
-                    visibility: layerVisibility["posidonia-standard"]
-                      ? "visible"
-                      : "none",
-                  }}
-                  paint={{
-                    "fill-color": "#7dd3fc",
-                    "fill-opacity": 0.3,
-                  }}
-                />
-                <Layer
-                  id="posidonia-standard-outline"
-                  type="line"
-                  layout={{
-                    visibility: layerVisibility["posidonia-standard"]
-                      ? "visible"
-                      : "none",
-                  }}
-                  paint={{
-                    "line-color": "#059669",
-                    "line-width": 2,
-                  }}
-                />
-              </Source>
-            </>
-          )}
+          {/* Posidonia Beds Layers - Middle layers, cut into vector tiles by
+              the backend so only the meadows in view are loaded */}
+          <Source
+            id="posidonia-source"
+            type="vector"
+            tiles={[getApiUrl(API_ENDPOINTS.posidoniaTiles)]}
+            maxzoom={14}
+          >
+            {/* Healthy Posidonia */}
+            <Layer
+              id="posidonia-healthy-fill"
+              type="fill"
+              source-layer="posidonia"
+              filter={["==", ["get", "classification"], "healthy"]}
+              layout={{
+                visibility: layerVisibility["posidonia-healthy"]
+                  ? "visible"
+                  : "none",
+              }}
+              paint={{
+                "fill-color": "#10b981",
+                "fill-opacity": 0.3,
+              }}
+            />
+            <Layer
+              id="posidonia-healthy-outline"
+              type="line"
+              source-layer="posidonia"
+              filter={["==", ["get", "classification"], "healthy"]}
+              layout={{
+                visibility: layerVisibility["posidonia-healthy"]
+                  ? "visible"
+                  : "none",
+              }}
+              paint={{
+                "line-color": "#059669",
+                "line-width": 2,
+              }}
+            />
+
+            {/* Degraded Posidonia */}
+            <Layer
+              id="posidonia-degraded-fill"
+              type="fill"
+              source-layer="posidonia"
+              filter={["==", ["get", "classification"], "degraded"]}
+              layout={{
+                visibility: layerVisibility["posidonia-degraded"]
+                  ? "visible"
+                  : "none",
+              }}
+              paint={{
+                "fill-color": "#f59e0b",
+                "fill-opacity": 0.3,
+              }}
+            />
+            <Layer
+              id="posidonia-degraded-outline"
+              type="line"
+              source-layer="posidonia"
+              filter={["==", ["get", "classification"], "degraded"]}
+              layout={{
+                visibility: layerVisibility["posidonia-degraded"]
+                  ? "visible"
+                  : "none",
+              }}
+              paint={{
+                "line-color": "#DC143C",
+                "line-width": 2,
+              }}
+            />
+
+            {/* Dead Posidonia */}
+            <Layer
+              id="posidonia-dead-fill"
+              type="fill"
+              source-layer="posidonia"
+              filter={["==", ["get", "classification"], "dead"]}
+              layout={{
+                visibility: layerVisibility["posidonia-dead"]
+                  ? "visible"
+                  : "none",
+              }}
+              paint={{
+                "fill-color": "#059669",
+                "fill-opacity": 0.2,
+              }}
+            />
+            <Layer
+              id="posidonia-dead-outline"
+              type="line"
+              source-layer="posidonia"
+              filter={["==", ["get", "classification"], "dead"]}
+              layout={{
+                visibility: layerVisibility["posidonia-dead"]
+                  ? "visible"
+                  : "none",
+              }}
+              paint={{
+                "line-color": "#654321",
+                "line-width": 2,
+              }}
+            />
+
+            {/* Standard/Unknown Posidonia */}
+            <Layer
+              id="posidonia-standard-fill"
+              type="fill"
+              source-layer="posidonia"
+              filter={[
+                "any",
+                ["!", ["has", "classification"]],
+                ["==", ["get", "classification"], "standard"],
+              ]}
+              layout={{
+                visibility: layerVisibility["posidonia-standard"]
+                  ? "visible"
+                  : "none",
+              }}
+              paint={{
+                "fill-color": "#7dd3fc",
+                "fill-opacity": 0.3,
+              }}
+            />
+            <Layer
+              id="posidonia-standard-outline"
+              type="line"
+              source-layer="posidonia"
+              filter={[
+                "any",
+                ["!", ["has", "classification"]],
+                ["==", ["get", "classification"], "standard"],
+              ]}
+              layout={{
+                visibility: layerVisibility["posidonia-standard"]
+                  ? "visible"
+                  : "none",
+              }}
+              paint={{
+                "line-color": "#059669",
+                "line-width": 2,
+              }}
+            />
+          </Source>
 
           {/* Vessel Markers - Rendered last to ensure they appear on top */}
           <Source
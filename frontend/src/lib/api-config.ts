@@ -24,6 +24,9 @@ export const API_ENDPOINTS = {
   parkBoundaries: '/api/park-boundaries',
   bufferedBoundaries: '/api/buffered-boundaries',
   posidonia: '/api/posidonia',
+  posidoniaTiles: '/api/tiles/posidonia/{z}/{x}/{y}.mvt',
+  site: '/api/meta/site',
+  landMask: '/api/land-mask',
   shoreline: '/api/shoreline',
   vesselPreviousPositions: (uuid: string, limit: number = 50) => `/api/vessels/${uuid}/previous-positions?limit=${limit}`,
   vesselHistoricalData: (uuid: string, days: number = 7, limit: number = 100) => `/api/vessels/historical-data?uuid=${uuid}&days=${days}&limit=${limit}`,
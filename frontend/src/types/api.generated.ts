@@ -0,0 +1,521 @@
+// Code generated by genclient. DO NOT EDIT.
+// Run `go generate ./client` in the backend to update it.
+
+export interface AnchoredVessel {
+  id: number;
+  vessel_uuid: string;
+  park_id: number;
+  started_at: string;
+  ended_at: string | null;
+  last_seen_at: string;
+  latitude: number;
+  longitude: number;
+  drift_radius_meters: number;
+  dwell_minutes: number;
+  position_count: number;
+  max_current_knots: number | null;
+  anchor_latitude: number;
+  anchor_longitude: number;
+  created_at: string;
+  updated_at: string;
+  vessel?: VesselRecord;
+  anchored_minutes: number;
+  habitat: HabitatLayer[];
+  on_posidonia: boolean;
+}
+
+export interface AnchoredVesselList {
+  park: string;
+  thresholds: AnchoringThresholds;
+  max_age: string;
+  vessels: AnchoredVessel[];
+  count: number;
+  data_as_of: string | null;
+  source: string;
+}
+
+export interface AnchoringEvent {
+  id: number;
+  vessel_uuid: string;
+  park_id: number;
+  started_at: string;
+  ended_at: string | null;
+  last_seen_at: string;
+  latitude: number;
+  longitude: number;
+  drift_radius_meters: number;
+  dwell_minutes: number;
+  position_count: number;
+  max_current_knots: number | null;
+  anchor_latitude: number;
+  anchor_longitude: number;
+  created_at: string;
+  updated_at: string;
+  vessel?: VesselRecord;
+}
+
+export interface AnchoringEventList {
+  park: string;
+  events: AnchoringEvent[];
+  count: number;
+}
+
+export interface AnchoringThresholds {
+  max_speed_knots: number;
+  max_drift_meters: number;
+  min_positions: number;
+}
+
+export interface ArrivalList {
+  park: string;
+  arrivals: ParkArrival[];
+  count: number;
+}
+
+export interface Deprecation {
+  method: string;
+  path: string;
+  deprecated_at: string;
+  sunset?: string | null;
+  successor?: string;
+  note?: string;
+}
+
+export interface DeprecationList {
+  deprecations: Deprecation[];
+  count: number;
+}
+
+export interface HabitatLayer {
+  id: number;
+  park_id: number;
+  name: string;
+  kind: string;
+  format: string;
+  file_name: string;
+  features: number;
+  sha256: string;
+  active_from?: string | null;
+  active_until?: string | null;
+  uploaded_by?: string;
+  created_at: string;
+}
+
+export interface LatLon {
+  latitude: number;
+  longitude: number;
+}
+
+export interface LatestPosition {
+  latitude: number;
+  longitude: number;
+  speed: number;
+  course: number;
+  heading: number | null;
+  destination: string;
+  distance: number;
+  is_in_park: boolean;
+  is_in_buffer_zone: boolean;
+  timestamp: string;
+  recorded_at: string;
+}
+
+export interface LocalVesselList {
+  vessels: VesselRecord[];
+  count: number;
+  data_as_of: string | null;
+  source: string;
+}
+
+export interface LoginRequest {
+  device_name: string;
+}
+
+export interface MapView {
+  latitude: number;
+  longitude: number;
+  zoom: number;
+  bounds: number[];
+}
+
+export interface Park {
+  id: number;
+  slug: string;
+  name: string;
+  boundaries_path?: string;
+  buffered_path?: string;
+  buffer_meters?: number | null;
+  center_lat?: number | null;
+  center_lon?: number | null;
+  radius_nm?: number;
+  data_region?: string;
+  restrict_exports: boolean;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface ParkArrival {
+  id: number;
+  park_id: number;
+  vessel_uuid: string;
+  mmsi: string;
+  latitude: number;
+  longitude: number;
+  speed: number;
+  first_seen_at: string;
+  seeded: boolean;
+  created_at: string;
+  vessel?: VesselRecord;
+}
+
+export interface ParkList {
+  parks: ParkSummary[];
+  count: number;
+}
+
+export interface ParkSummary {
+  park: Park;
+  default: boolean;
+  center: LatLon;
+  boundaries_healthy: boolean;
+  buffer_zone_available: boolean;
+}
+
+export interface ParkVessel {
+  vessel: VesselSummary;
+  latitude: number;
+  longitude: number;
+  is_in_park: boolean;
+  is_in_buffer_zone: boolean;
+  is_whitelisted?: boolean;
+  timestamp?: string;
+  whitelist_info?: WhitelistInfo | null;
+}
+
+export interface Playback {
+  park: string;
+  start: string;
+  end: string;
+  step: string;
+  interpolate: boolean;
+  max_gap: string;
+  max_age?: string;
+  vessels: Record<string, PlaybackVessel>;
+  frames: PlaybackFrame[];
+}
+
+export interface PlaybackFrame {
+  timestamp: string;
+  positions: PlaybackPosition[];
+  count: number;
+}
+
+export interface PlaybackPosition {
+  vessel_uuid: string;
+  latitude: number;
+  longitude: number;
+  speed: number;
+  course: number;
+  heading: number | null;
+  is_in_park: boolean;
+  fix_time: string;
+  position_age_seconds: number;
+  interpolated: boolean;
+}
+
+export interface PlaybackVessel {
+  uuid: string;
+  name: string;
+  mmsi: string;
+  imo: string;
+  type: string;
+  type_specific: string;
+  country_iso: string;
+}
+
+export interface PreviousPosition {
+  latitude: number;
+  longitude: number;
+  speed: number;
+  course: number;
+  heading: number | null;
+  destination: string;
+  distance: number;
+  is_in_park: boolean;
+  timestamp: string;
+  recorded_at: string;
+}
+
+export interface PreviousPositions {
+  vessel_uuid: string;
+  previous_positions: PreviousPosition[];
+  count: number;
+  start_time: string;
+  end_time: string;
+  limit: number;
+}
+
+export interface ProjectedIntrusion {
+  minutes: number;
+  time: string;
+  latitude: number;
+  longitude: number;
+}
+
+export interface ProjectedPoint {
+  minutes: number;
+  time: string;
+  latitude: number;
+  longitude: number;
+  in_park: boolean;
+  in_buffer_zone: boolean;
+}
+
+export interface QuotaStatus {
+  provider: string;
+  day: string;
+  daily_credits: number;
+  used: number;
+  remaining: number | null;
+  reserve: number;
+  deferring: boolean;
+  exhausted: boolean;
+  resets_at: string;
+  requests_per_minute: number;
+}
+
+export interface RefreshRequest {
+  refresh_token: string;
+}
+
+export interface SchedulerStatus {
+  running: boolean;
+  paused: boolean;
+  last_run_id?: string;
+  last_run_at: string | null;
+  last_success_at: string | null;
+  last_failure_at: string | null;
+  last_error?: string;
+  vessels_fetched: number;
+  positions_stored: number;
+  duplicate_skipped: number;
+  duplicate_skipped_total: number;
+  next_run_at: string | null;
+}
+
+export interface SiteContact {
+  organization?: string;
+  email?: string;
+  phone?: string;
+  url?: string;
+}
+
+export interface SiteInfo {
+  park: string;
+  name: string;
+  title: string;
+  logo_url?: string;
+  map: MapView;
+  layers: SiteLayer[];
+  contact: SiteContact;
+  features: Record<string, boolean>;
+}
+
+export interface SiteLayer {
+  id: string;
+  name: string;
+  kind: string;
+  url: string;
+}
+
+export interface TokenPair {
+  access_token: string;
+  access_expires_at: string;
+  refresh_token: string;
+  refresh_expires_at: string;
+  session_id: number;
+}
+
+export interface VesselEvents {
+  park: string;
+  vessel_uuid: string;
+  start: string;
+  end: string;
+  events: ZoneEvent[];
+  count: number;
+}
+
+export interface VesselProfile {
+  park: string;
+  vessel: VesselRecord;
+  latest_position: LatestPosition | null;
+  is_whitelisted: boolean;
+  whitelist_info?: WhitelistInfo | null;
+  violations: VesselViolationSummary;
+  buffer_zone_available: boolean;
+}
+
+export interface VesselProjection {
+  vessel_uuid: string;
+  park_id: number;
+  fix_time: string;
+  latitude: number;
+  longitude: number;
+  speed: number;
+  course: number;
+  in_park: boolean;
+  points: ProjectedPoint[];
+  intrusion: ProjectedIntrusion | null;
+  notes: string[];
+}
+
+export interface VesselRecord {
+  id: number;
+  uuid: string;
+  name: string;
+  name_ais: string;
+  mmsi: string;
+  imo: string;
+  eni: string | null;
+  country_iso: string;
+  country_name: string;
+  callsign: string;
+  type: string;
+  type_specific: string;
+  gross_tonnage: number | null;
+  deadweight: number | null;
+  teu: number | null;
+  liquid_gas: number | null;
+  length: number;
+  breadth: number;
+  draught_avg: number | null;
+  draught_max: number | null;
+  speed_avg: number | null;
+  speed_max: number | null;
+  year_built: string;
+  is_navaid: boolean;
+  home_port: string | null;
+  destination: string;
+  operator_id?: number | null;
+  last_seen_at: string | null;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface VesselSummary {
+  uuid?: string;
+  name: string;
+  mmsi: string;
+  imo?: string;
+  type: string;
+  type_specific?: string;
+  country_iso?: string;
+  country_name?: string;
+  speed?: number;
+  course?: number;
+  heading?: number | null;
+  destination?: string;
+  distance?: number;
+}
+
+export interface VesselViolationSummary {
+  since: string;
+  total: number;
+  open: number;
+  by_type: Record<string, number>;
+}
+
+export interface VesselsInPark {
+  park?: string;
+  vessels_in_park: ParkVessel[];
+  total_in_park: number;
+  park_center: LatLon;
+  buffer_zone_available?: boolean;
+  data_as_of: string | null;
+  source: string;
+}
+
+export interface WeatherFrame {
+  timestamp: string;
+  observation: WeatherObservation | null;
+  observation_age_seconds?: number;
+}
+
+export interface WeatherHistory {
+  park: string;
+  start: string;
+  end: string;
+  step: string;
+  max_age: string;
+  observations: WeatherObservation[];
+  frames: WeatherFrame[];
+}
+
+export interface WeatherObservation {
+  id: number;
+  park_id: number;
+  observed_at: string;
+  fetched_at: string;
+  source: string;
+  latitude: number;
+  longitude: number;
+  temperature_c: number;
+  wind_speed_knots: number;
+  wind_gust_knots: number;
+  wind_direction_deg: number;
+  pressure_hpa: number;
+  precipitation_mm: number;
+  cloud_cover_pct: number;
+  weather_code: number;
+}
+
+export interface WhitelistCheck {
+  is_whitelisted: boolean;
+  uuid: string;
+  mmsi: string;
+  imo: string;
+  whitelist_entry?: WhitelistEntry | null;
+}
+
+export interface WhitelistEntry {
+  id: number;
+  vessel_uuid: string;
+  mmsi: string;
+  imo: string;
+  name: string;
+  reason: string;
+  added_by?: string;
+  operator_id?: number | null;
+  expires_at: string | null;
+  is_active: boolean;
+  created_at: string;
+  updated_at: string;
+  vessel?: VesselRecord;
+}
+
+export interface WhitelistInfo {
+  reason: string;
+  added_by?: string;
+}
+
+export interface ZoneEvent {
+  id: number;
+  park_id: number;
+  vessel_uuid: string;
+  type: string;
+  from_zone: string;
+  to_zone: string;
+  latitude: number;
+  longitude: number;
+  speed: number;
+  occurred_at: string;
+  previous_at: string;
+  created_at: string;
+  vessel?: VesselRecord;
+}
+
+export interface ZoneEventList {
+  park: string;
+  events: ZoneEvent[];
+  count: number;
+}